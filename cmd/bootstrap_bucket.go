@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/b2"
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/token"
+	"github.com/spf13/cobra"
+)
+
+var bootstrapNoLifecycle bool
+var bootstrapReadOnlyKey string
+var bootstrapCORS bool
+var bootstrapCORSOrigins []string
+
+// bootstrapLifecycle are the lifecycle rules bootstrap-bucket applies:
+// multipart uploads an interrupted upload left behind are billed but
+// never visible, and invites nobody redeemed outlive their links (see
+// storage.MaxPresignTTL) for no reason.
+var bootstrapLifecycle = []storage.LifecycleRule{
+	{ID: "emu-sync-abort-uploads", AbortUploadDays: 7},
+	{ID: "emu-sync-expire-invites", Prefix: token.InvitePrefix, ExpireDays: 8},
+}
+
+var bootstrapBucketCmd = &cobra.Command{
+	Use:   "bootstrap-bucket",
+	Short: "Set up the configured bucket as a new library",
+	Long: `Prepares the bucket in the config for a new library, in one step:
+
+  - checks that the bucket exists, creating it if it doesn't
+  - adds lifecycle rules that clean up after interrupted uploads and
+    unredeemed invites (skip with --no-lifecycle); rules you added
+    yourself are kept
+  - writes an empty manifest, so devices can be set up before the
+    first upload
+  - with --read-only-key NAME, creates a key devices can sync with but
+    not change the library with
+  - with --cors, lets the web UI's page read from the bucket, so a
+    browser can download large files straight from it; the web UI's
+    origins are allowed, on web.port if set or any port otherwise
+    (--cors-origin replaces them)
+
+Creating keys is only possible on Backblaze B2, and needs a key with
+the writeKeys capability, such as the master key. For AWS and other
+providers, the policy such a key needs is printed instead. Steps the
+provider doesn't support are reported and skipped; running it again is
+safe.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := requireWritable(cfg); err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+
+		created, err := client.EnsureBucket(ctx)
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Printf("Bucket %s: created\n", cfg.Storage.Bucket)
+		} else {
+			fmt.Printf("Bucket %s: exists\n", cfg.Storage.Bucket)
+		}
+
+		if !bootstrapNoLifecycle {
+			if err := client.SetLifecycle(ctx, bootstrapLifecycle); err != nil {
+				fmt.Printf("Lifecycle rules: skipped (%v)\n", err)
+			} else {
+				fmt.Println("Lifecycle rules: unfinished uploads aborted after 7 days, unredeemed invites deleted after 8")
+			}
+		}
+
+		if bootstrapCORS {
+			origins := bootstrapCORSOrigins
+			if len(origins) == 0 {
+				origins = webOrigins(cfg.Web.Port)
+			}
+			rule := storage.CORSRule{ID: "emu-sync-web", Origins: origins, MaxAge: 3600}
+			if err := client.SetCORS(ctx, []storage.CORSRule{rule}); err != nil {
+				fmt.Printf("CORS: skipped (%v)\n", err)
+			} else {
+				fmt.Printf("CORS: downloads allowed from %s\n", strings.Join(origins, ", "))
+			}
+		}
+
+		wrote, err := ensureManifest(ctx, client)
+		if err != nil {
+			return err
+		}
+		if wrote {
+			fmt.Println("Manifest: wrote an empty one")
+		} else {
+			fmt.Println("Manifest: exists")
+		}
+
+		if bootstrapReadOnlyKey != "" {
+			return createReadOnlyKey(ctx, cfg, bootstrapReadOnlyKey)
+		}
+		return nil
+	},
+}
+
+// webOrigins returns the origins the web UI's page is served from: on
+// port, or on any port if it's 0 (web picks one at random then).
+func webOrigins(port int) []string {
+	p := "*"
+	if port > 0 {
+		p = strconv.Itoa(port)
+	}
+	return []string{"http://127.0.0.1:" + p, "http://localhost:" + p}
+}
+
+// ensureManifest writes an empty manifest to b unless it already has
+// one, and reports whether it wrote it.
+func ensureManifest(ctx context.Context, b storage.Backend) (bool, error) {
+	_, err := b.HeadObject(ctx, storage.ManifestKey)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return false, fmt.Errorf("checking for a manifest: %w", err)
+	}
+	data, err := manifest.New().ToJSON()
+	if err != nil {
+		return false, err
+	}
+	if err := b.UploadManifest(ctx, data); err != nil {
+		return false, fmt.Errorf("uploading manifest: %w", err)
+	}
+	return true, nil
+}
+
+// createReadOnlyKey creates a read-only key named name on B2, or for
+// other providers prints the policy one needs.
+func createReadOnlyKey(ctx context.Context, cfg *config.Config, name string) error {
+	prefix := strings.Trim(cfg.Storage.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	if !b2.IsEndpoint(cfg.Storage.EndpointURL) {
+		fmt.Println()
+		fmt.Println("Read-only keys can only be created on Backblaze B2. Create one in your")
+		fmt.Println("provider's console with this policy (AWS IAM syntax):")
+		fmt.Println(readOnlyPolicy(cfg.Storage.Bucket, prefix))
+		return nil
+	}
+
+	transport, err := cfg.Network.Transport(true)
+	if err != nil {
+		return err
+	}
+	key, err := b2.CreateReadOnlyKey(ctx, &http.Client{Transport: transport}, cfg.Storage.KeyID, cfg.Storage.SecretKey, cfg.Storage.Bucket, prefix, name)
+	if err != nil {
+		return fmt.Errorf("creating read-only key: %w", err)
+	}
+	fmt.Println()
+	fmt.Printf("Read-only key %s created. The secret is shown only once:\n", name)
+	fmt.Printf("  Key ID:          %s\n", key.ID)
+	fmt.Printf("  Application key: %s\n", key.Secret)
+	fmt.Println("Enter these in generate-token, and answer y to \"Is this key read-only?\".")
+	return nil
+}
+
+// readOnlyPolicy returns an AWS IAM policy that lets a key list bucket
+// and read the objects under prefix, which is all sync needs.
+func readOnlyPolicy(bucket, prefix string) string {
+	listBucket := map[string]any{
+		"Effect":   "Allow",
+		"Action":   "s3:ListBucket",
+		"Resource": "arn:aws:s3:::" + bucket,
+	}
+	if prefix != "" {
+		listBucket["Condition"] = map[string]any{"StringLike": map[string]string{"s3:prefix": prefix + "*"}}
+	}
+	policy := map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []any{
+			listBucket,
+			map[string]any{
+				"Effect":   "Allow",
+				"Action":   "s3:GetObject",
+				"Resource": "arn:aws:s3:::" + bucket + "/" + prefix + "*",
+			},
+		},
+	}
+	data, _ := json.MarshalIndent(policy, "", "  ")
+	return string(data)
+}
+
+func init() {
+	bootstrapBucketCmd.Flags().BoolVar(&bootstrapNoLifecycle, "no-lifecycle", false, "leave the bucket's lifecycle rules alone")
+	bootstrapBucketCmd.Flags().BoolVar(&bootstrapCORS, "cors", false, "allow the web UI's page to read from the bucket (CORS)")
+	bootstrapBucketCmd.Flags().StringSliceVar(&bootstrapCORSOrigins, "cors-origin", nil, "origins --cors allows, comma-separated (default the web UI's)")
+	bootstrapBucketCmd.Flags().StringVar(&bootstrapReadOnlyKey, "read-only-key", "", "also create a read-only key with this name for devices (B2 only)")
+	rootCmd.AddCommand(bootstrapBucketCmd)
+}
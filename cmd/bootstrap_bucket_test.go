@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestEnsureManifest(t *testing.T) {
+	mock := storage.NewMockBackend()
+	wrote, err := ensureManifest(context.Background(), mock)
+	if err != nil || !wrote {
+		t.Fatalf("ensureManifest = %v, %v; want an empty manifest written", wrote, err)
+	}
+	if _, ok := mock.Objects[storage.ManifestGzipKey]; !ok {
+		t.Error("manifest not uploaded")
+	}
+
+	mock.Objects[storage.ManifestKey] = []byte(`{"files":{"roms/a.gba":{}}}`)
+	if wrote, err := ensureManifest(context.Background(), mock); err != nil || wrote {
+		t.Errorf("ensureManifest over an existing manifest = %v, %v", wrote, err)
+	}
+	if !strings.Contains(string(mock.Objects[storage.ManifestKey]), "roms/a.gba") {
+		t.Error("existing manifest was replaced")
+	}
+}
+
+func TestReadOnlyPolicy(t *testing.T) {
+	got := readOnlyPolicy("roms", "games/")
+	for _, want := range []string{`"s3:GetObject"`, `"arn:aws:s3:::roms/games/*"`, `"s3:prefix": "games/*"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("policy missing %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "PutObject") || strings.Contains(got, "DeleteObject") {
+		t.Errorf("policy allows writes:\n%s", got)
+	}
+}
+
+func TestWebOrigins(t *testing.T) {
+	if got := webOrigins(8080); !slices.Equal(got, []string{"http://127.0.0.1:8080", "http://localhost:8080"}) {
+		t.Errorf("webOrigins(8080) = %v", got)
+	}
+	if got := webOrigins(0); got[0] != "http://127.0.0.1:*" {
+		t.Errorf("webOrigins(0) = %v, want any port", got)
+	}
+}
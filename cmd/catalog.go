@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+//go:embed catalog_assets/catalog.html
+var catalogHTML string
+
+var catalogTemplate = template.Must(template.New("catalog").Parse(catalogHTML))
+
+var catalogFormat string
+var catalogOutput string
+var catalogTitle string
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Export a listing of the library as HTML or CSV",
+	Long: `Writes every file in the remote manifest, grouped by system with
+counts and sizes, for sharing what's available with people who don't
+use emu-sync. The HTML page is a single self-contained file with a
+search box; CSV has one row per file (system, key, name, size in bytes).
+
+  emu-sync catalog --format html -o library.html
+  emu-sync catalog --format csv > library.csv`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if catalogFormat != "html" && catalogFormat != "csv" {
+			return fmt.Errorf("--format must be html or csv, not %q", catalogFormat)
+		}
+
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+		remoteData, err := client.DownloadManifest(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("downloading manifest: %w", err)
+		}
+		remote, err := manifest.ParseJSON(remoteData)
+		if err != nil {
+			return fmt.Errorf("parsing manifest: %w", err)
+		}
+		cfg.Policy.Hide(remote)
+
+		w := io.Writer(os.Stdout)
+		if catalogOutput != "" {
+			f, err := os.Create(catalogOutput)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		groups := buildGroups(remote, cfg)
+		if catalogFormat == "csv" {
+			err = writeCatalogCSV(w, groups)
+		} else {
+			err = writeCatalogHTML(w, catalogTitle, groups, remote.GeneratedAt)
+		}
+		if err != nil {
+			return err
+		}
+		if catalogOutput != "" {
+			fmt.Fprintf(os.Stderr, "Wrote %s (%s)\n", catalogOutput, pluralFiles(len(remote.Files)))
+		}
+		return nil
+	},
+}
+
+// catalogPage is the data for the HTML catalog template.
+type catalogPage struct {
+	Title   string
+	Files   int
+	Size    string
+	Updated string
+	Systems []catalogSystem
+}
+
+type catalogSystem struct {
+	Dir   string
+	Name  string
+	Icon  string
+	Size  string
+	Files []catalogFile
+}
+
+type catalogFile struct {
+	Name string
+	Size string
+}
+
+// writeCatalogHTML renders groups as a standalone, searchable HTML page.
+// updated is when the manifest was generated; zero leaves it out.
+func writeCatalogHTML(w io.Writer, title string, groups []*systemGroup, updated time.Time) error {
+	page := catalogPage{Title: title}
+	if !updated.IsZero() {
+		page.Updated = updated.Local().Format("2006-01-02")
+	}
+	var total int64
+	for _, g := range groups {
+		sys := catalogSystem{Dir: g.Dir, Name: g.label(), Icon: g.Icon, Size: formatSize(g.TotalSize)}
+		for _, f := range g.Files {
+			sys.Files = append(sys.Files, catalogFile{Name: f.Name, Size: formatSize(f.Size)})
+		}
+		page.Systems = append(page.Systems, sys)
+		page.Files += len(g.Files)
+		total += g.TotalSize
+	}
+	page.Size = formatSize(total)
+	return catalogTemplate.Execute(w, page)
+}
+
+// writeCatalogCSV writes a header and one row per file.
+func writeCatalogCSV(w io.Writer, groups []*systemGroup) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"system", "key", "name", "size"})
+	for _, g := range groups {
+		for _, f := range g.Files {
+			cw.Write([]string{g.label(), f.Key, f.Name, strconv.FormatInt(f.Size, 10)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func init() {
+	catalogCmd.Flags().StringVar(&catalogFormat, "format", "html", "output format: html or csv")
+	catalogCmd.Flags().StringVarP(&catalogOutput, "output", "o", "", "write to this file instead of stdout")
+	catalogCmd.Flags().StringVar(&catalogTitle, "title", "Game Library", "page title for the HTML catalog")
+	rootCmd.AddCommand(catalogCmd)
+}
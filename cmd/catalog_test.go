@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+func catalogGroups() []*systemGroup {
+	m := manifest.New()
+	m.Files["roms/snes/Chrono Trigger.sfc"] = manifest.FileEntry{Size: 4 << 20}
+	m.Files["roms/snes/<Hack>.sfc"] = manifest.FileEntry{Size: 1 << 20}
+	m.Files["roms/gba/Game.gba"] = manifest.FileEntry{Size: 8 << 20}
+	return buildGroups(m, &config.Config{})
+}
+
+func TestWriteCatalogHTML(t *testing.T) {
+	var buf bytes.Buffer
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := writeCatalogHTML(&buf, "Our Games", catalogGroups(), updated); err != nil {
+		t.Fatalf("writeCatalogHTML: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"<title>Our Games</title>", "Super Nintendo", "Chrono Trigger.sfc", "3 files across 2 systems", "2 files, 5.0 MiB"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("catalog is missing %q", want)
+		}
+	}
+	if strings.Contains(out, "<Hack>") {
+		t.Error("file names should be HTML-escaped")
+	}
+}
+
+func TestWriteCatalogCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCatalogCSV(&buf, catalogGroups()); err != nil {
+		t.Fatalf("writeCatalogCSV: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != "system,key,name,size" {
+		t.Fatalf("rows = %v, want a header and 3 files", rows)
+	}
+	if got := strings.Join(rows[1], ","); got != "Game Boy Advance,roms/gba/Game.gba,Game.gba,8388608" {
+		t.Errorf("first row = %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
@@ -11,33 +12,36 @@ import (
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/output"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/systems"
+	"github.com/jacobfgrant/emu-sync/internal/units"
 	"github.com/spf13/cobra"
 )
 
-// systemKey returns the first two path segments of a manifest key,
-// grouping files by top-level system (e.g., "bios/pcsx2", "roms/snes").
-// For keys with fewer than 3 segments, it falls back to path.Dir.
-func systemKey(key string) string {
-	parts := strings.SplitN(key, "/", 3)
-	if len(parts) <= 2 {
-		return path.Dir(key)
-	}
-	return parts[0] + "/" + parts[1]
-}
-
 // systemGroup holds aggregated info about a directory of files.
 type systemGroup struct {
 	Dir       string
+	Name      string // display name, e.g. "Super Nintendo"
+	Icon      string
 	Files     []fileInfo
 	TotalSize int64
 }
 
+// label returns the group's display name, falling back to its directory.
+func (g *systemGroup) label() string {
+	if g.Name == "" {
+		return g.Dir
+	}
+	return g.Name
+}
+
 type fileInfo struct {
 	Key      string
 	Name     string
 	Size     int64
 	Selected bool
+	Present  bool // current version already downloaded (in the local manifest)
 }
 
 // groupState returns the selection state of a group: "all", "none", or "partial".
@@ -77,6 +81,16 @@ func (g *systemGroup) selectedSize() int64 {
 	return size
 }
 
+func (g *systemGroup) presentCount() int {
+	n := 0
+	for _, f := range g.Files {
+		if f.Present {
+			n++
+		}
+	}
+	return n
+}
+
 // subGroup is a subset of files within a systemGroup that share the same
 // immediate child directory under the system key. Files directly in the
 // system directory have RelDir == "".
@@ -121,6 +135,16 @@ func (sg *subGroup) selectedSize() int64 {
 	return size
 }
 
+func (sg *subGroup) presentCount() int {
+	n := 0
+	for _, f := range sg.Files {
+		if f.Present {
+			n++
+		}
+	}
+	return n
+}
+
 func (sg *subGroup) totalSize() int64 {
 	var size int64
 	for _, f := range sg.Files {
@@ -176,13 +200,149 @@ func buildSubGroups(g *systemGroup) []*subGroup {
 	return sgs
 }
 
+// dirNode is a directory in a system group's file tree. Unlike subGroup,
+// which only splits one level, the tree follows the full path so deeply
+// nested sets (MAME, ScummVM) can be browsed and toggled at any level.
+type dirNode struct {
+	Name  string      // directory name; "" for the system directory itself
+	Path  string      // full key prefix, e.g. "roms/mame/neogeo"
+	Dirs  []*dirNode  // subdirectories, sorted by name
+	Files []*fileInfo // files directly in this directory; pointers into systemGroup.Files
+}
+
+// buildTree arranges a group's files into a directory tree rooted at g.Dir.
+func buildTree(g *systemGroup) *dirNode {
+	root := &dirNode{Path: g.Dir}
+	for i := range g.Files {
+		f := &g.Files[i]
+		n := root
+		parts := strings.Split(f.Name, "/")
+		for _, part := range parts[:len(parts)-1] {
+			n = n.child(part)
+		}
+		n.Files = append(n.Files, f)
+	}
+	root.sort()
+	return root
+}
+
+// child returns the subdirectory with the given name, creating it if needed.
+func (n *dirNode) child(name string) *dirNode {
+	for _, d := range n.Dirs {
+		if d.Name == name {
+			return d
+		}
+	}
+	d := &dirNode{Name: name, Path: n.Path + "/" + name}
+	n.Dirs = append(n.Dirs, d)
+	return d
+}
+
+func (n *dirNode) sort() {
+	sort.Slice(n.Dirs, func(i, j int) bool { return n.Dirs[i].Name < n.Dirs[j].Name })
+	for _, d := range n.Dirs {
+		d.sort()
+	}
+}
+
+// find returns the node for a full directory path, or nil.
+func (n *dirNode) find(dir string) *dirNode {
+	if dir == n.Path {
+		return n
+	}
+	rel, ok := strings.CutPrefix(dir, n.Path+"/")
+	if !ok {
+		return nil
+	}
+	name, _, _ := strings.Cut(rel, "/")
+	for _, d := range n.Dirs {
+		if d.Name == name {
+			return d.find(dir)
+		}
+	}
+	return nil
+}
+
+// allFiles returns every file at or below n.
+func (n *dirNode) allFiles() []*fileInfo {
+	files := append([]*fileInfo(nil), n.Files...)
+	for _, d := range n.Dirs {
+		files = append(files, d.allFiles()...)
+	}
+	return files
+}
+
+func (n *dirNode) groupState() string {
+	selected, total := n.selectedCount(), n.fileCount()
+	if selected == 0 {
+		return "none"
+	}
+	if selected == total {
+		return "all"
+	}
+	return "partial"
+}
+
+func (n *dirNode) fileCount() int {
+	return len(n.allFiles())
+}
+
+func (n *dirNode) selectedCount() int {
+	c := 0
+	for _, f := range n.allFiles() {
+		if f.Selected {
+			c++
+		}
+	}
+	return c
+}
+
+func (n *dirNode) presentCount() int {
+	c := 0
+	for _, f := range n.allFiles() {
+		if f.Present {
+			c++
+		}
+	}
+	return c
+}
+
+func (n *dirNode) totalSize() int64 {
+	var size int64
+	for _, f := range n.allFiles() {
+		size += f.Size
+	}
+	return size
+}
+
+var chooseList bool
+var chooseJSON bool
+var chooseSelect []string
+var chooseDeselect []string
+var chooseApply bool
+
 var chooseCmd = &cobra.Command{
 	Use:   "choose",
 	Short: "Interactively select which systems and games to sync",
 	Long: `Downloads the remote manifest and shows available systems with their
 sizes. Select a system by number to see its games and toggle them
 individually. Use 'all' or 'none' to select or deselect everything
-in a system. Saves selections to your config file.`,
+in a system, or filter the current list: 'only <text>' keeps just the
+matching files, 'drop <text>' deselects matches (e.g., 'drop (Japan)'),
+and 'max-size <size>' deselects anything larger (e.g., 'max-size 100MB').
+Saves selections to your config file, or on a shared machine (see
+sync.user_selections) to your own selection file, leaving the other
+users' selections alone.
+
+For scripting, --list prints every system and its selection state
+(--json for machine-readable output), and --select/--deselect change
+selections without prompting. Patterns match a file or any of its
+parent directories and may use wildcards, e.g.:
+
+  emu-sync choose --select roms/gba --deselect "roms/psx/*" --apply
+
+Selects are applied before deselects. Without --apply the resulting
+sync_dirs and sync_exclude are shown but not saved.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath := cfgFile
 		if cfgPath == "" {
@@ -193,27 +353,55 @@ in a system. Saves selections to your config file.`,
 		if err != nil {
 			return fmt.Errorf("loading config: %w", err)
 		}
+		user, err := cfg.CurrentUser()
+		if err != nil {
+			return fmt.Errorf("loading selections: %w", err)
+		}
+
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
 
-		client := storage.NewClient(&cfg.Storage)
+		scripted := chooseList || len(chooseSelect) > 0 || len(chooseDeselect) > 0
+		if scripted {
+			remoteData, err := client.DownloadManifest(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("downloading manifest: %w", err)
+			}
+			remote, err := manifest.ParseJSON(remoteData)
+			if err != nil {
+				return fmt.Errorf("parsing manifest: %w", err)
+			}
+			cfg.Policy.Hide(remote)
+			groups := buildGroups(remote, cfg.ForUser(user))
+			markPresent(groups, remote, loadLocalManifest(""))
+			return chooseScripted(groups, cfg, cfgPath, user)
+		}
 
-		fmt.Print("Downloading manifest...")
+		if !output.Quiet() {
+			fmt.Print("Downloading manifest...")
+		}
 		remoteData, err := client.DownloadManifest(cmd.Context())
 		if err != nil {
-			fmt.Println(" failed")
+			if !output.Quiet() {
+				fmt.Println(" failed")
+			}
 			return fmt.Errorf("downloading manifest: %w", err)
 		}
-		fmt.Println(" ok")
+		if !output.Quiet() {
+			fmt.Println(" ok")
+		}
 
 		remote, err := manifest.ParseJSON(remoteData)
 		if err != nil {
 			return fmt.Errorf("parsing manifest: %w", err)
 		}
+		cfg.Policy.Hide(remote)
 
-		groups := buildGroups(remote, cfg)
+		groups := buildGroups(remote, cfg.ForUser(user))
 		if len(groups) == 0 {
 			fmt.Println("No files found in remote manifest.")
 			return nil
 		}
+		markPresent(groups, remote, loadLocalManifest(""))
 
 		reader := bufio.NewReader(os.Stdin)
 
@@ -221,7 +409,7 @@ in a system. Saves selections to your config file.`,
 		for {
 			printSystems(groups)
 			fmt.Println()
-			printTotals(groups)
+			printTotals(groups, cfg.Sync.Delete)
 			fmt.Println()
 			fmt.Print("Enter a number to browse, or Enter to save: ")
 			input := prompt(reader, "")
@@ -238,22 +426,132 @@ in a system. Saves selections to your config file.`,
 		}
 
 		syncDirs, syncExclude := encodeSelections(groups)
-		cfg.Sync.SyncDirs = syncDirs
-		cfg.Sync.SyncExclude = syncExclude
+		cfg.SetSelection(user, syncDirs, syncExclude)
 
-		if err := config.Write(cfg, cfgPath); err != nil {
+		if err := cfg.SaveSelection(user, cfgPath); err != nil {
 			return err
 		}
 
-		fmt.Printf("\nConfig updated: %s\n", cfgPath)
-		fmt.Printf("  sync_dirs: %v\n", syncDirs)
-		if len(syncExclude) > 0 {
-			fmt.Printf("  sync_exclude: %v\n", syncExclude)
+		if !output.Quiet() {
+			fmt.Printf("\nConfig updated: %s\n", cfg.SelectionFile(user, cfgPath))
+			fmt.Printf("  sync_dirs: %v\n", syncDirs)
+			if len(syncExclude) > 0 {
+				fmt.Printf("  sync_exclude: %v\n", syncExclude)
+			}
+		}
+		for _, w := range selectionWarnings(cfg.ForUser(user), groups) {
+			fmt.Printf("Warning: %s\n", w)
 		}
 		return nil
 	},
 }
 
+// chooseScripted handles the non-interactive forms of choose: it applies
+// --select/--deselect, then lists and/or saves the result as user's
+// selections (see config.CurrentUser).
+func chooseScripted(groups []*systemGroup, cfg *config.Config, cfgPath, user string) error {
+	if err := applySelectionPatterns(groups, chooseSelect, chooseDeselect); err != nil {
+		return err
+	}
+
+	// Keep stdout clean for JSON consumers
+	out := os.Stdout
+	if chooseJSON {
+		out = os.Stderr
+	}
+
+	if chooseList {
+		if chooseJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(newSystemsResponse(groups)); err != nil {
+				return err
+			}
+		} else {
+			printSystems(groups)
+			fmt.Println()
+			printTotals(groups, cfg.Sync.Delete)
+			fmt.Println()
+		}
+	}
+
+	if len(chooseSelect) == 0 && len(chooseDeselect) == 0 {
+		return nil
+	}
+
+	syncDirs, syncExclude := encodeSelections(groups)
+	if !chooseApply {
+		fmt.Fprintf(out, "\nWould set (use --apply to save):\n")
+		fmt.Fprintf(out, "  sync_dirs: %v\n", syncDirs)
+		if len(syncExclude) > 0 {
+			fmt.Fprintf(out, "  sync_exclude: %v\n", syncExclude)
+		}
+		return nil
+	}
+
+	cfg.SetSelection(user, syncDirs, syncExclude)
+	if err := cfg.SaveSelection(user, cfgPath); err != nil {
+		return err
+	}
+
+	if !output.Quiet() {
+		fmt.Fprintf(out, "\nConfig updated: %s\n", cfg.SelectionFile(user, cfgPath))
+		fmt.Fprintf(out, "  sync_dirs: %v\n", syncDirs)
+		if len(syncExclude) > 0 {
+			fmt.Fprintf(out, "  sync_exclude: %v\n", syncExclude)
+		}
+	}
+	for _, w := range selectionWarnings(cfg.ForUser(user), groups) {
+		fmt.Fprintf(out, "Warning: %s\n", w)
+	}
+	return nil
+}
+
+// applySelectionPatterns selects files matching any of selects, then
+// deselects files matching any of deselects. Returns an error for an
+// invalid pattern or one that matches no files, which is almost always a
+// typo.
+func applySelectionPatterns(groups []*systemGroup, selects, deselects []string) error {
+	apply := func(patterns []string, selected bool) error {
+		for _, pattern := range patterns {
+			pattern = strings.Trim(pattern, "/")
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			matched := 0
+			for _, g := range groups {
+				for i := range g.Files {
+					if matchesSelection(pattern, g.Files[i].Key) {
+						g.Files[i].Selected = selected
+						matched++
+					}
+				}
+			}
+			if matched == 0 {
+				return fmt.Errorf("no files match %q", pattern)
+			}
+		}
+		return nil
+	}
+
+	if err := apply(selects, true); err != nil {
+		return err
+	}
+	return apply(deselects, false)
+}
+
+// matchesSelection reports whether pattern (path.Match syntax) matches key
+// or one of its parent directories, so "roms/gba" matches everything under
+// roms/gba and "roms/psx/*" everything below roms/psx.
+func matchesSelection(pattern, key string) bool {
+	for p := key; p != "." && p != "/"; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // encodeSelections converts group selections into sync_dirs and sync_exclude
 // slices for the config file. It encodes at the sub-group level when possible,
 // using directory paths instead of individual files, and picks the shorter
@@ -354,17 +652,70 @@ func encodeFlat(g *systemGroup, syncDirs, syncExclude []string) ([]string, []str
 	return syncDirs, syncExclude
 }
 
+// selectionWarnings checks cfg's sync_dirs and sync_exclude against the
+// files in groups; see config.CheckSelections.
+func selectionWarnings(cfg *config.Config, groups []*systemGroup) []string {
+	var keys []string
+	for _, g := range groups {
+		for _, f := range g.Files {
+			keys = append(keys, f.Key)
+		}
+	}
+	return cfg.CheckSelections(keys)
+}
+
+// markPresent flags files whose current remote version is recorded in the
+// local manifest, i.e. already downloaded by a previous sync.
+func markPresent(groups []*systemGroup, remote, local *manifest.Manifest) {
+	for _, g := range groups {
+		for i := range g.Files {
+			f := &g.Files[i]
+			entry, ok := local.Files[f.Key]
+			f.Present = ok && entry.MD5 == remote.Files[f.Key].MD5
+		}
+	}
+}
+
+// loadLocalManifest loads the local manifest at path (or the default
+// path), returning an empty manifest if there isn't one yet.
+func loadLocalManifest(path string) *manifest.Manifest {
+	if path == "" {
+		path = config.DefaultLocalManifestPath()
+	}
+	local, err := manifest.LoadJSON(path)
+	if err != nil {
+		return manifest.New()
+	}
+	return local
+}
+
+// presenceLabel describes a file's download state relative to its
+// selection, so the delta a sync would make is visible.
+func presenceLabel(f *fileInfo) string {
+	switch {
+	case f.Selected && f.Present:
+		return "downloaded"
+	case f.Selected:
+		return "pending"
+	case f.Present:
+		return "on disk"
+	}
+	return ""
+}
+
 // buildGroups aggregates manifest files into system-level groups and marks
 // which are currently selected based on the existing config. Files are
 // grouped by their first two path segments (e.g., "bios/pcsx2").
 func buildGroups(m *manifest.Manifest, cfg *config.Config) []*systemGroup {
 	dirMap := make(map[string]*systemGroup)
+	overrides := cfg.SystemOverrides()
 
 	for key, entry := range m.Files {
-		sk := systemKey(key)
+		sk := systems.Dir(key)
 		g, ok := dirMap[sk]
 		if !ok {
-			g = &systemGroup{Dir: sk}
+			info := systems.Lookup(sk, overrides)
+			g = &systemGroup{Dir: sk, Name: info.Name, Icon: info.Icon}
 			dirMap[sk] = g
 		}
 		// Name is the path relative to the system key
@@ -402,261 +753,269 @@ func printSystems(groups []*systemGroup) {
 	fmt.Println("Systems:")
 	for i, g := range groups {
 		state := g.groupState()
-		marker := "[ ]"
-		switch state {
-		case "all":
-			marker = "[x]"
-		case "partial":
-			marker = "[~]"
-		}
+		marker := output.Check(state)
 
 		extra := ""
 		if state == "partial" {
 			extra = fmt.Sprintf("  (%d of %d selected)", g.selectedCount(), len(g.Files))
 		}
+		if n := g.presentCount(); n > 0 {
+			extra += fmt.Sprintf("  [%d downloaded]", n)
+		}
+
+		// Show the directory too, since --select and sync_dirs use it
+		dir := ""
+		if g.label() != g.Dir {
+			dir = "  " + g.Dir
+		}
 
-		fmt.Printf("  %2d. %s %-25s %8s  (%d files)%s\n",
-			i+1, marker, g.Dir, formatSize(g.TotalSize), len(g.Files), extra)
+		fmt.Printf("  %2d. %s %-25s %8s  (%d files)%s%s\n",
+			i+1, marker, g.label(), formatSize(g.TotalSize), len(g.Files), extra, dir)
 	}
 }
 
-func printTotals(groups []*systemGroup) {
+// printTotals prints the selected and available sizes, followed by what
+// the next sync would change on disk. Removals are only counted when
+// deleteDeselected is set, since sync otherwise leaves them in place.
+func printTotals(groups []*systemGroup, deleteDeselected bool) {
 	var selectedSize, totalSize int64
 	for _, g := range groups {
 		totalSize += g.TotalSize
 		selectedSize += g.selectedSize()
 	}
 	fmt.Printf("Selected: %s  |  Total available: %s", formatSize(selectedSize), formatSize(totalSize))
-}
 
-// drillInto shows sub-groups within a system group and lets the user toggle
-// them. If the system has no sub-groups (all files in the root), it falls
-// back to a flat file list. Entering ">N" drills into a sub-group to show
-// individual files.
-func drillInto(reader *bufio.Reader, g *systemGroup) {
-	sgs := buildSubGroups(g)
-	if len(sgs) <= 1 {
-		drillIntoFiles(reader, g.Dir, g.Files)
-		return
+	d := selectionDelta(groups)
+	var parts []string
+	if d.DownloadFiles > 0 {
+		parts = append(parts, fmt.Sprintf("+%s to download (%d files)", formatSize(d.DownloadSize), d.DownloadFiles))
+	}
+	if deleteDeselected && d.RemoveFiles > 0 {
+		parts = append(parts, fmt.Sprintf("-%s to remove (%d files)", formatSize(d.RemoveSize), d.RemoveFiles))
 	}
+	if len(parts) > 0 {
+		fmt.Printf("\nNext sync: %s", strings.Join(parts, ", "))
+	}
+}
 
-	for {
-		fmt.Printf("\n%s (%d files, %s):\n", g.Dir, len(g.Files), formatSize(g.TotalSize))
-
-		// Build display items: direct files first, then sub-group rows
-		type displayItem struct {
-			isSubGroup bool
-			fileIdx    int // index into g.Files (for direct files only)
-			sgIdx      int // index into sgs
-		}
-		var items []displayItem
-		for si, sg := range sgs {
-			if sg.RelDir == "" {
-				// Direct files — show individually
-				for _, f := range sg.Files {
-					// Find index of f in g.Files
-					for fi := range g.Files {
-						if &g.Files[fi] == f {
-							items = append(items, displayItem{fileIdx: fi})
-							break
-						}
-					}
-				}
-			} else {
-				items = append(items, displayItem{isSubGroup: true, sgIdx: si})
+// delta is how far the current selections are from what's on disk.
+type delta struct {
+	DownloadFiles int
+	DownloadSize  int64
+	RemoveFiles   int
+	RemoveSize    int64
+}
+
+// selectionDelta counts selected files that aren't downloaded yet and
+// downloaded files that are no longer selected.
+func selectionDelta(groups []*systemGroup) delta {
+	var d delta
+	for _, g := range groups {
+		for _, f := range g.Files {
+			switch {
+			case f.Selected && !f.Present:
+				d.DownloadFiles++
+				d.DownloadSize += f.Size
+			case !f.Selected && f.Present:
+				d.RemoveFiles++
+				d.RemoveSize += f.Size
 			}
 		}
+	}
+	return d
+}
 
-		for i, item := range items {
-			if item.isSubGroup {
-				sg := sgs[item.sgIdx]
-				marker := "[ ]"
-				switch sg.groupState() {
-				case "all":
-					marker = "[x]"
-				case "partial":
-					marker = "[~]"
-				}
-				extra := ""
-				if sg.groupState() == "partial" {
-					extra = fmt.Sprintf("  (%d of %d)", sg.selectedCount(), len(sg.Files))
-				}
-				fmt.Printf("  %2d. %s %-35s %4d files  %8s%s\n",
-					i+1, marker, sg.RelDir+"/", len(sg.Files), formatSize(sg.totalSize()), extra)
-			} else {
-				f := g.Files[item.fileIdx]
-				marker := "[ ]"
-				if f.Selected {
-					marker = "[x]"
-				}
-				fmt.Printf("  %2d. %s %-45s %8s\n", i+1, marker, f.Name, formatSize(f.Size))
+// drillInto lets the user browse and toggle a system group's files,
+// starting at the top of its directory tree.
+func drillInto(reader *bufio.Reader, g *systemGroup) {
+	drillIntoDir(reader, g.label(), buildTree(g))
+}
+
+// drillIntoDir shows a directory's files and subdirectories and lets the
+// user toggle them. Toggling a subdirectory toggles everything beneath
+// it; entering ">N" browses into it, to any depth.
+func drillIntoDir(reader *bufio.Reader, label string, n *dirNode) {
+	for {
+		files := n.allFiles()
+		fmt.Printf("\n%s (%d files, %s):\n", label, len(files), formatSize(n.totalSize()))
+
+		// Direct files first, then subdirectories
+		for i, f := range n.Files {
+			marker := output.Check("none")
+			if f.Selected {
+				marker = output.Check("all")
+			}
+			fmt.Printf("  %2d. %s %-45s %8s  %s\n", i+1, marker, path.Base(f.Name), formatSize(f.Size), presenceLabel(f))
+		}
+		for i, d := range n.Dirs {
+			state := d.groupState()
+			marker := output.Check(state)
+			extra := ""
+			if state == "partial" {
+				extra = fmt.Sprintf("  (%d of %d)", d.selectedCount(), d.fileCount())
 			}
+			if c := d.presentCount(); c > 0 {
+				extra += fmt.Sprintf("  [%d downloaded]", c)
+			}
+			fmt.Printf("  %2d. %s %-35s %4d files  %8s%s\n",
+				len(n.Files)+i+1, marker, d.Name+"/", d.fileCount(), formatSize(d.totalSize()), extra)
 		}
 
 		fmt.Println()
-		fmt.Print("Toggle (e.g., 1 3), '>N' to browse, 'all', 'none', or Enter to go back: ")
+		if !output.Quiet() {
+			fmt.Println(chooseFilterHelp)
+		}
+		if len(n.Dirs) > 0 {
+			fmt.Print("Toggle (e.g., 1 3), '>N' to browse, 'all', 'none', or Enter to go back: ")
+		} else {
+			fmt.Print("Toggle (e.g., 1 3 5), 'all', 'none', or Enter to go back: ")
+		}
 		input := prompt(reader, "")
 		if input == "" {
 			return
 		}
-
-		lower := strings.ToLower(strings.TrimSpace(input))
-		if lower == "all" {
-			for i := range g.Files {
-				g.Files[i].Selected = true
-			}
+		if runFilter(input, files) {
 			continue
 		}
-		if lower == "none" {
-			for i := range g.Files {
-				g.Files[i].Selected = false
+
+		lower := strings.ToLower(strings.TrimSpace(input))
+		if lower == "all" || lower == "none" {
+			for _, f := range files {
+				f.Selected = lower == "all"
 			}
 			continue
 		}
 
+		count := len(n.Files) + len(n.Dirs)
 		for _, tok := range strings.Fields(input) {
-			if strings.HasPrefix(tok, ">") {
-				idx, err := strconv.Atoi(tok[1:])
-				if err != nil || idx < 1 || idx > len(items) {
-					fmt.Printf("  invalid: %s\n", tok)
+			browse := strings.HasPrefix(tok, ">")
+			idx, err := strconv.Atoi(strings.TrimPrefix(tok, ">"))
+			if err != nil || idx < 1 || idx > count {
+				fmt.Printf("  invalid: %s\n", tok)
+				continue
+			}
+			if idx <= len(n.Files) {
+				f := n.Files[idx-1]
+				if browse {
+					fmt.Printf("  %s is a file, not a directory\n", path.Base(f.Name))
 					continue
 				}
-				item := items[idx-1]
-				if item.isSubGroup {
-					sg := sgs[item.sgIdx]
-					label := g.Dir + "/" + sg.RelDir
-					drillIntoFilesPtrs(reader, label, sg.Files)
-				} else {
-					fmt.Printf("  %s is a file, not a directory\n", g.Files[item.fileIdx].Name)
-				}
+				f.Selected = !f.Selected
 				continue
 			}
-			idx, err := strconv.Atoi(tok)
-			if err != nil || idx < 1 || idx > len(items) {
-				fmt.Printf("  invalid: %s\n", tok)
+			d := n.Dirs[idx-len(n.Files)-1]
+			if browse {
+				drillIntoDir(reader, label+"/"+d.Name, d)
 				continue
 			}
-			item := items[idx-1]
-			if item.isSubGroup {
-				sg := sgs[item.sgIdx]
-				newState := sg.groupState() != "all"
-				for _, f := range sg.Files {
-					f.Selected = newState
-				}
-			} else {
-				g.Files[item.fileIdx].Selected = !g.Files[item.fileIdx].Selected
+			newState := d.groupState() != "all"
+			for _, f := range d.allFiles() {
+				f.Selected = newState
 			}
 		}
 	}
 }
 
-// drillIntoFilesPtrs shows individual files (referenced by pointer) and
-// lets the user toggle them. Used when drilling into sub-groups.
-func drillIntoFilesPtrs(reader *bufio.Reader, label string, files []*fileInfo) {
-	for {
-		fmt.Printf("\n%s (%d files):\n", label, len(files))
-		for i, f := range files {
-			marker := "[ ]"
-			if f.Selected {
-				marker = "[x]"
-			}
-			fmt.Printf("  %2d. %s %-45s %8s\n", i+1, marker, f.Name, formatSize(f.Size))
-		}
-
-		fmt.Println()
-		fmt.Print("Toggle (e.g., 1 3 5), 'all', 'none', or Enter to go back: ")
-		input := prompt(reader, "")
-		if input == "" {
-			return
+// chooseFilterHelp describes the filter commands accepted by applyFilter.
+const chooseFilterHelp = "Filters: 'only <text>' keeps matches, 'drop <text>' deselects matches, 'max-size <size>' deselects larger files"
+
+// applyFilter handles the filter commands available while browsing a
+// group: "only <pattern>" selects matching files and deselects the rest,
+// "drop <pattern>" deselects matching files, and "max-size <size>"
+// deselects files larger than size (e.g., "100MB"). Patterns match file
+// names as a case-insensitive substring, or as a wildcard if they contain
+// *, ?, or [. Reports whether input was a filter command and how many
+// files changed selection.
+func applyFilter(input string, files []*fileInfo) (handled bool, changed int, err error) {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+	arg = strings.TrimSpace(arg)
+
+	var want func(f *fileInfo) (bool, bool) // returns (selected, applies)
+	switch strings.ToLower(cmd) {
+	case "only", "drop":
+		if arg == "" {
+			return true, 0, fmt.Errorf("usage: %s <text>", cmd)
+		}
+		match, err := nameMatcher(arg)
+		if err != nil {
+			return true, 0, err
 		}
-
-		lower := strings.ToLower(strings.TrimSpace(input))
-		if lower == "all" {
-			for _, f := range files {
-				f.Selected = true
-			}
-			continue
+		if strings.ToLower(cmd) == "only" {
+			want = func(f *fileInfo) (bool, bool) { return match(f.Name), true }
+		} else {
+			want = func(f *fileInfo) (bool, bool) { return false, match(f.Name) }
 		}
-		if lower == "none" {
-			for _, f := range files {
-				f.Selected = false
-			}
-			continue
+	case "max-size":
+		limit, err := config.ParseBandwidthLimit(arg)
+		if err != nil || limit <= 0 {
+			return true, 0, fmt.Errorf("invalid size %q (e.g., 500KB, 100MB, 2GB)", arg)
 		}
+		want = func(f *fileInfo) (bool, bool) { return false, f.Size > limit }
+	default:
+		return false, 0, nil
+	}
 
-		for _, tok := range strings.Fields(input) {
-			idx, err := strconv.Atoi(tok)
-			if err != nil || idx < 1 || idx > len(files) {
-				fmt.Printf("  invalid: %s\n", tok)
-				continue
-			}
-			files[idx-1].Selected = !files[idx-1].Selected
+	for _, f := range files {
+		selected, applies := want(f)
+		if applies && f.Selected != selected {
+			f.Selected = selected
+			changed++
 		}
 	}
+	return true, changed, nil
 }
 
-// drillIntoFiles shows individual files and lets the user toggle them.
-func drillIntoFiles(reader *bufio.Reader, label string, files []fileInfo) {
-	for {
-		fmt.Printf("\n%s (%d files):\n", label, len(files))
-		for i, f := range files {
-			marker := "[ ]"
-			if f.Selected {
-				marker = "[x]"
-			}
-			fmt.Printf("  %2d. %s %-45s %8s\n", i+1, marker, f.Name, formatSize(f.Size))
-		}
-
-		fmt.Println()
-		fmt.Print("Toggle (e.g., 1 3 5), 'all', 'none', or Enter to go back: ")
-		input := prompt(reader, "")
-		if input == "" {
-			return
-		}
+// nameMatcher returns a case-insensitive matcher for a filter pattern.
+func nameMatcher(pattern string) (func(name string) bool, error) {
+	lower := strings.ToLower(pattern)
+	if !strings.ContainsAny(pattern, "*?[") {
+		return func(name string) bool {
+			return strings.Contains(strings.ToLower(name), lower)
+		}, nil
+	}
+	if _, err := path.Match(lower, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(lower, strings.ToLower(path.Base(name)))
+		return ok
+	}, nil
+}
 
-		lower := strings.ToLower(strings.TrimSpace(input))
-		if lower == "all" {
-			for i := range files {
-				files[i].Selected = true
-			}
-			continue
-		}
-		if lower == "none" {
-			for i := range files {
-				files[i].Selected = false
-			}
-			continue
-		}
+// runFilter applies a filter command and prints the outcome. Reports
+// whether input was a filter command.
+func runFilter(input string, files []*fileInfo) bool {
+	handled, changed, err := applyFilter(input, files)
+	if !handled {
+		return false
+	}
+	if err != nil {
+		fmt.Printf("  %v\n", err)
+	} else {
+		fmt.Printf("  %d files changed\n", changed)
+	}
+	return true
+}
 
-		for _, tok := range strings.Fields(input) {
-			idx, err := strconv.Atoi(tok)
-			if err != nil || idx < 1 || idx > len(files) {
-				fmt.Printf("  invalid: %s\n", tok)
-				continue
-			}
-			files[idx-1].Selected = !files[idx-1].Selected
-		}
+// filePtrs returns pointers to each element of files.
+func filePtrs(files []fileInfo) []*fileInfo {
+	ptrs := make([]*fileInfo, len(files))
+	for i := range files {
+		ptrs[i] = &files[i]
 	}
+	return ptrs
 }
 
 func formatSize(bytes int64) string {
-	const (
-		kb = 1024
-		mb = 1024 * kb
-		gb = 1024 * mb
-	)
-	switch {
-	case bytes >= gb:
-		return fmt.Sprintf("%.1f GB", float64(bytes)/float64(gb))
-	case bytes >= mb:
-		return fmt.Sprintf("%.0f MB", float64(bytes)/float64(mb))
-	case bytes >= kb:
-		return fmt.Sprintf("%.0f KB", float64(bytes)/float64(kb))
-	default:
-		return fmt.Sprintf("%d B", bytes)
-	}
+	return units.FormatSize(bytes)
 }
 
 func init() {
+	addOutputFlags(chooseCmd)
+	chooseCmd.Flags().BoolVar(&chooseList, "list", false, "print systems and selection state instead of prompting")
+	chooseCmd.Flags().BoolVar(&chooseJSON, "json", false, "with --list, print JSON")
+	chooseCmd.Flags().StringArrayVar(&chooseSelect, "select", nil, "select files matching a path or pattern (repeatable)")
+	chooseCmd.Flags().StringArrayVar(&chooseDeselect, "deselect", nil, "deselect files matching a path or pattern (repeatable)")
+	chooseCmd.Flags().BoolVar(&chooseApply, "apply", false, "save --select/--deselect changes to the config")
 	rootCmd.AddCommand(chooseCmd)
 }
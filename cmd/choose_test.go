@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+func TestMatchesSelection(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"roms/gba", "roms/gba/Game.gba", true},
+		{"roms/gba", "roms/gba/sub/Game.gba", true},
+		{"roms/gba", "roms/gbax/Game.gba", false},
+		{"roms/psx/*", "roms/psx/Game.bin", true},
+		{"roms/psx/*", "roms/psx/Disc 1/Game.bin", true},
+		{"roms/psx/*", "roms/psx", false},
+		{"roms/*/*(Japan)*", "roms/snes/Game (Japan).sfc", true},
+		{"roms/snes/Game.sfc", "roms/snes/Game.sfc", true},
+	}
+	for _, tt := range tests {
+		if got := matchesSelection(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("matchesSelection(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestApplySelectionPatterns(t *testing.T) {
+	groups := testGroups()
+
+	// Select all of snes, then deselect one gba game
+	err := applySelectionPatterns(groups, []string{"roms/snes/"}, []string{"roms/gba/*D*"})
+	if err != nil {
+		t.Fatalf("applySelectionPatterns: %v", err)
+	}
+
+	dirs, exclude := encodeSelections(groups)
+	if len(dirs) != 2 || dirs[0] != "roms/snes" || dirs[1] != "roms/gba/GameC.gba" {
+		t.Errorf("sync_dirs = %v, want [roms/snes roms/gba/GameC.gba]", dirs)
+	}
+	if len(exclude) != 0 {
+		t.Errorf("sync_exclude = %v, want empty", exclude)
+	}
+}
+
+func TestApplySelectionPatternsDeselectWins(t *testing.T) {
+	groups := testGroups()
+
+	err := applySelectionPatterns(groups, []string{"roms/*"}, []string{"roms/gba"})
+	if err != nil {
+		t.Fatalf("applySelectionPatterns: %v", err)
+	}
+	for _, g := range groups {
+		want := "all"
+		if g.Dir == "roms/gba" {
+			want = "none"
+		}
+		if got := g.groupState(); got != want {
+			t.Errorf("%s state = %q, want %q", g.Dir, got, want)
+		}
+	}
+}
+
+func TestApplySelectionPatternsErrors(t *testing.T) {
+	err := applySelectionPatterns(testGroups(), []string{"roms/n64"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no files match") {
+		t.Errorf("err = %v, want no-match error", err)
+	}
+
+	err = applySelectionPatterns(testGroups(), nil, []string{"roms/["})
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("err = %v, want invalid pattern error", err)
+	}
+}
+
+func TestApplyFilter(t *testing.T) {
+	files := []fileInfo{
+		{Name: "Game (USA).sfc", Size: 1024, Selected: true},
+		{Name: "Game (Japan).sfc", Size: 1024, Selected: true},
+		{Name: "Big Game (Europe).sfc", Size: 200 * 1024 * 1024, Selected: false},
+	}
+	ptrs := filePtrs(files)
+
+	handled, changed, err := applyFilter("drop (japan)", ptrs)
+	if !handled || err != nil || changed != 1 {
+		t.Fatalf("drop: handled=%v changed=%d err=%v", handled, changed, err)
+	}
+	if files[1].Selected {
+		t.Error("Japan file should be deselected")
+	}
+
+	if _, changed, _ = applyFilter("only *(Europe).sfc", ptrs); changed != 2 {
+		t.Errorf("only changed %d, want 2", changed)
+	}
+	if files[0].Selected || !files[2].Selected {
+		t.Errorf("only should select just the Europe file: %+v", files)
+	}
+
+	if _, changed, _ = applyFilter("max-size 100MB", ptrs); changed != 1 {
+		t.Errorf("max-size changed %d, want 1", changed)
+	}
+	if files[2].Selected {
+		t.Error("file over max-size should be deselected")
+	}
+}
+
+func TestApplyFilterNotACommand(t *testing.T) {
+	files := filePtrs([]fileInfo{{Name: "a", Selected: true}})
+	for _, input := range []string{"1 2", "all", ">3"} {
+		if handled, _, _ := applyFilter(input, files); handled {
+			t.Errorf("applyFilter(%q) handled, want not a filter", input)
+		}
+	}
+	for _, input := range []string{"only", "max-size lots", "drop ["} {
+		if handled, _, err := applyFilter(input, files); !handled || err == nil {
+			t.Errorf("applyFilter(%q) = handled %v err %v, want error", input, handled, err)
+		}
+	}
+}
+
+func TestPresenceLabel(t *testing.T) {
+	tests := []struct {
+		f    fileInfo
+		want string
+	}{
+		{fileInfo{Selected: true, Present: true}, "downloaded"},
+		{fileInfo{Selected: true}, "pending"},
+		{fileInfo{Present: true}, "on disk"},
+		{fileInfo{}, ""},
+	}
+	for _, tt := range tests {
+		if got := presenceLabel(&tt.f); got != tt.want {
+			t.Errorf("presenceLabel(%+v) = %q, want %q", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestSelectionDelta(t *testing.T) {
+	groups := []*systemGroup{{
+		Dir: "roms/snes",
+		Files: []fileInfo{
+			{Key: "roms/snes/A.sfc", Size: 100, Selected: true, Present: true},
+			{Key: "roms/snes/B.sfc", Size: 200, Selected: true},
+			{Key: "roms/snes/C.sfc", Size: 400, Present: true},
+			{Key: "roms/snes/D.sfc", Size: 800},
+		},
+	}}
+	want := delta{DownloadFiles: 1, DownloadSize: 200, RemoveFiles: 1, RemoveSize: 400}
+	if got := selectionDelta(groups); got != want {
+		t.Errorf("selectionDelta = %+v, want %+v", got, want)
+	}
+}
+
+func TestBuildGroupsDisplayNames(t *testing.T) {
+	m := manifest.New()
+	m.Files["roms/snes/A.sfc"] = manifest.FileEntry{Size: 1}
+	m.Files["roms/hacks/B.sfc"] = manifest.FileEntry{Size: 1}
+	m.Files["roms/custom/C.bin"] = manifest.FileEntry{Size: 1}
+	cfg := &config.Config{Systems: map[string]config.SystemConfig{
+		"roms/hacks": {Name: "ROM Hacks", Icon: "HACK"},
+	}}
+
+	got := make(map[string]*systemGroup)
+	for _, g := range buildGroups(m, cfg) {
+		got[g.Dir] = g
+	}
+	if g := got["roms/snes"]; g.Name != "Super Nintendo" || g.Icon != "SNES" {
+		t.Errorf("roms/snes = %q %q, want built-in name and icon", g.Name, g.Icon)
+	}
+	if g := got["roms/hacks"]; g.Name != "ROM Hacks" || g.Icon != "HACK" {
+		t.Errorf("roms/hacks = %q %q, want config override", g.Name, g.Icon)
+	}
+	if g := got["roms/custom"]; g.label() != "roms/custom" {
+		t.Errorf("roms/custom label = %q, want directory", g.label())
+	}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	gosync "sync"
+	"syscall"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/progress"
+	"github.com/spf13/cobra"
+)
+
+var daemonInterval time.Duration
+var daemonHealthAddr string
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run unattended, syncing whenever the library changes (for containers)",
+	Long: `Runs sync --follow for unattended hosts such as a container on a NAS:
+syncs at startup, then checks the library every --interval and syncs
+again whenever it changes (or when trigger.listen receives a trigger).
+
+Nothing depends on a home directory. The config file comes from
+--config or EMU_SYNC_CONFIG, and may be left out entirely when the
+EMU_SYNC_* variables accepted by upload --ci provide the settings.
+State files live in --state-dir or EMU_SYNC_STATE_DIR, which should be
+a volume so the local manifest survives restarts.
+
+GET /healthz on --health-addr (default ":8080", or EMU_SYNC_HEALTH_ADDR;
+"" to disable) answers 200 while the library has been checked
+successfully within the last three intervals, and 503 otherwise, with
+the last check and sync as JSON.
+
+SIGTERM or SIGINT stops the daemon; a sync in progress saves what it
+has downloaded before exiting.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.LoadEnv(cfgPath, "")
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := cfg.ValidateEmulationPath(); err != nil {
+			return err
+		}
+		if daemonInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+
+		client, err := newSyncClient(cfg)
+		if err != nil {
+			return err
+		}
+		opts, err := syncOptions(cfg, max(cfg.Sync.Workers, 1))
+		if err != nil {
+			return err
+		}
+		prog := progress.New(progress.NewLog(os.Stdout))
+		attachWebhook(prog, cfg)
+		opts.Progress = prog
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		health := &daemonHealth{started: time.Now(), interval: daemonInterval}
+		if daemonHealthAddr != "" {
+			srv, err := serveHealth(daemonHealthAddr, health)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				srv.Shutdown(shutdownCtx)
+			}()
+		}
+
+		err = followLibrary(ctx, client, cfg, opts, daemonInterval, health)
+		if ctx.Err() != nil {
+			log.Printf("shutting down")
+		}
+		return err
+	},
+}
+
+// daemonHealth tracks the daemon's last library check and sync for
+// /healthz. Safe for concurrent use.
+type daemonHealth struct {
+	mu        gosync.Mutex
+	started   time.Time
+	interval  time.Duration
+	lastCheck time.Time // last successful check of the library
+	checkErr  error     // error from the most recent check, if it failed
+	lastSync  time.Time
+	syncErr   error
+}
+
+// checked records a library check.
+func (h *daemonHealth) checked(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkErr = err
+	if err == nil {
+		h.lastCheck = time.Now()
+	}
+}
+
+// synced records a sync, which also counts as a successful check.
+func (h *daemonHealth) synced(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSync = time.Now()
+	h.syncErr = err
+	if err == nil {
+		h.lastCheck = h.lastSync
+		h.checkErr = nil
+	}
+}
+
+// healthStatus is the /healthz response.
+type healthStatus struct {
+	OK        bool   `json:"ok"`
+	LastCheck string `json:"last_check,omitempty"`
+	CheckErr  string `json:"check_error,omitempty"`
+	LastSync  string `json:"last_sync,omitempty"`
+	SyncErr   string `json:"sync_error,omitempty"`
+}
+
+// status reports whether the daemon is healthy at now: the library was
+// checked within three intervals, or the daemon started that recently
+// and its first sync is still running.
+func (h *daemonHealth) status(now time.Time) healthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	since := h.lastCheck
+	if since.IsZero() && h.syncErr == nil {
+		since = h.started
+	}
+	s := healthStatus{OK: now.Sub(since) <= 3*h.interval}
+	if !h.lastCheck.IsZero() {
+		s.LastCheck = h.lastCheck.UTC().Format(time.RFC3339)
+	}
+	if !h.lastSync.IsZero() {
+		s.LastSync = h.lastSync.UTC().Format(time.RFC3339)
+	}
+	if h.checkErr != nil {
+		s.CheckErr = h.checkErr.Error()
+	}
+	if h.syncErr != nil {
+		s.SyncErr = h.syncErr.Error()
+	}
+	return s
+}
+
+// ServeHTTP answers /healthz.
+func (h *daemonHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.status(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if !s.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(s)
+}
+
+// serveHealth serves h at /healthz on addr in the background.
+func serveHealth(addr string, h *daemonHealth) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening for health checks: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("warning: health endpoint stopped: %v", err)
+		}
+	}()
+	return srv, nil
+}
+
+// envOr returns the value of the environment variable name, or def if
+// it isn't set.
+func envOr(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+func init() {
+	daemonCmd.Flags().DurationVar(&daemonInterval, "interval", time.Minute, "how often to check for library changes")
+	daemonCmd.Flags().StringVar(&daemonHealthAddr, "health-addr", envOr("EMU_SYNC_HEALTH_ADDR", ":8080"), `address to serve /healthz on ("" to disable)`)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDaemonHealth(t *testing.T) {
+	start := time.Now()
+	h := &daemonHealth{started: start, interval: time.Minute}
+
+	if !h.status(start.Add(time.Minute)).OK {
+		t.Error("should be healthy while the first sync runs")
+	}
+	if h.status(start.Add(5 * time.Minute)).OK {
+		t.Error("should be unhealthy when the first sync never finishes")
+	}
+
+	h.synced(nil)
+	h.checked(errors.New("dial tcp: no route to host"))
+	s := h.status(time.Now().Add(2 * time.Minute))
+	if !s.OK || s.CheckErr == "" || s.LastSync == "" {
+		t.Errorf("one failed check after a sync: %+v, want healthy with the error", s)
+	}
+	if h.status(time.Now().Add(4 * time.Minute)).OK {
+		t.Error("should be unhealthy after three intervals without a successful check")
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz = %d, want 200", rec.Code)
+	}
+}
+
+func TestDaemonHealthFailedFirstSync(t *testing.T) {
+	h := &daemonHealth{started: time.Now(), interval: time.Minute}
+	h.synced(errors.New("access denied"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /healthz = %d, want 503 before any successful check", rec.Code)
+	}
+}
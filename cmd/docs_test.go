@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenManTree(t *testing.T) {
+	dir := t.TempDir()
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := genManTree(rootCmd, dir, date); err != nil {
+		t.Fatalf("genManTree: %v", err)
+	}
+	pages, _ := filepath.Glob(filepath.Join(dir, "*.1"))
+	if len(pages) < 10 {
+		t.Errorf("wrote %d pages, want one per command", len(pages))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "emu-sync-sync.1"))
+	if err != nil {
+		t.Fatalf("reading sync page: %v", err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		`.TH "EMU-SYNC-SYNC" "1" "Mar 2026" "emu-sync dev" "emu-sync Manual"`,
+		`Sync files from the bucket to this device`,
+		`\fB--workers\fP=1`,
+		".SH OPTIONS INHERITED FROM PARENT COMMANDS",
+		`\fBemu-sync(1)\fP`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("sync page missing %q", want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "emu-sync-help.1")); !os.IsNotExist(err) {
+		t.Error("help command should not get a man page")
+	}
+}
@@ -5,20 +5,40 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
 	"github.com/jacobfgrant/emu-sync/internal/token"
+	"github.com/jacobfgrant/emu-sync/internal/units"
 	"github.com/spf13/cobra"
 )
 
 const maskedKey = "********"
 
+var generateTokenPublish bool
+var generateTokenTTL time.Duration
+var generateTokenBandwidth string
+
 var generateTokenCmd = &cobra.Command{
 	Use:   "generate-token",
 	Short: "Generate a setup token for recipients",
 	Long: `Interactively generates a base64-encoded setup token, using the current
 config as defaults. Send this token to recipients so they can configure
-their devices with a single 'emu-sync setup <token>' command.`,
+their devices with a single 'emu-sync setup <token>' command.
+
+Long tokens are easily mangled by chat apps. With --publish, the token is
+instead encrypted with a random 8-character code and stored in the bucket,
+and a download link is printed. Send the link and the code (ideally over
+different channels); 'emu-sync setup <link>' asks for the code, and the
+invite is deleted once redeemed. Links stop working after --ttl.
+
+Before printing the token, it reports what the recipient's first sync
+will download with the token's settings: files and size per sync
+directory, and about how long that takes at --bandwidth (or the token's
+bandwidth limit, if lower), so you can warn them about a large initial
+download.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath := cfgFile
 		if cfgPath == "" {
@@ -30,6 +50,10 @@ their devices with a single 'emu-sync setup <token>' command.`,
 			return fmt.Errorf("loading config: %w", err)
 		}
 
+		if generateTokenPublish && (generateTokenTTL <= 0 || generateTokenTTL > storage.MaxPresignTTL) {
+			return fmt.Errorf("--ttl must be positive and at most %s", storage.MaxPresignTTL)
+		}
+
 		reader := bufio.NewReader(os.Stdin)
 
 		fmt.Println("Generate setup token")
@@ -64,6 +88,51 @@ their devices with a single 'emu-sync setup <token>' command.`,
 		deleteStr := promptWithDefault(reader, "Delete local files removed from bucket? (y/n)", deleteDefault)
 		deleteFiles := strings.HasPrefix(strings.ToLower(deleteStr), "y")
 
+		readOnlyStr := promptWithDefault(reader, "Is this key read-only? (y/n)", "n")
+		readOnly := strings.HasPrefix(strings.ToLower(readOnlyStr), "y")
+
+		excludeStr := promptWithDefault(reader, "Excluded paths (comma-separated)", strings.Join(cfg.Sync.SyncExclude, ","))
+		var syncExclude []string
+		for _, d := range strings.Split(excludeStr, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				syncExclude = append(syncExclude, d)
+			}
+		}
+
+		bandwidth := promptWithDefault(reader, "Bandwidth limit (e.g. 10MB, blank for none)", cfg.Sync.BandwidthLimit)
+		if bandwidth != "" {
+			if _, err := config.ParseBandwidthLimit(bandwidth); err != nil {
+				return fmt.Errorf("bandwidth limit: %w", err)
+			}
+		}
+
+		workers, err := promptInt(reader, "Parallel downloads (blank for default)", cfg.Sync.Workers)
+		if err != nil {
+			return fmt.Errorf("parallel downloads: %w", err)
+		}
+		webPort, err := promptInt(reader, "Web UI port (blank for default)", cfg.Web.Port)
+		if err != nil {
+			return fmt.Errorf("web UI port: %w", err)
+		}
+
+		restrictDefault := "n"
+		if !cfg.Policy.Empty() {
+			restrictDefault = "y"
+		}
+		var policy *config.PolicyConfig
+		restrictStr := promptWithDefault(reader, "Restrict what the recipient can see (parental controls)? (y/n)", restrictDefault)
+		if strings.HasPrefix(strings.ToLower(restrictStr), "y") {
+			policy = &config.PolicyConfig{
+				AllowSystems: promptList(reader, "  Only show these systems (comma-separated, blank for all)", cfg.Policy.AllowSystems),
+				DenySystems:  promptList(reader, "  Hide these systems", cfg.Policy.DenySystems),
+				DenyTags:     promptList(reader, "  Hide files tagged", cfg.Policy.DenyTags),
+				DenyNames:    promptList(reader, "  Hide names matching (e.g. *Mortal Kombat*)", cfg.Policy.DenyNames),
+			}
+			if err := policy.Validate(); err != nil {
+				return err
+			}
+		}
+
 		data := &token.Data{
 			EndpointURL:   endpoint,
 			Bucket:        bucket,
@@ -74,6 +143,13 @@ their devices with a single 'emu-sync setup <token>' command.`,
 			EmulationPath: emuPath,
 			SyncDirs:      syncDirs,
 			Delete:        &deleteFiles,
+
+			SyncExclude:    syncExclude,
+			BandwidthLimit: bandwidth,
+			Workers:        workers,
+			ReadOnly:       readOnly,
+			WebPort:        webPort,
+			Policy:         policy,
 		}
 
 		encoded, err := token.Encode(data)
@@ -81,6 +157,26 @@ their devices with a single 'emu-sync setup <token>' command.`,
 			return err
 		}
 
+		bps, err := config.ParseBandwidthLimit(generateTokenBandwidth)
+		if err != nil {
+			return fmt.Errorf("--bandwidth: %w", err)
+		}
+		if limit, _ := config.ParseBandwidthLimit(bandwidth); limit > 0 && (bps == 0 || limit < bps) {
+			bps = limit
+		}
+		recipient := data.ToConfig()
+		fmt.Println()
+		remote, err := downloadRemoteManifest(cmd, recipient, cfg.Network)
+		if err != nil {
+			fmt.Printf("Couldn't size the recipient's first sync: %v\n", err)
+		} else {
+			fmt.Print(recipientReport(remote, recipient, bps))
+		}
+
+		if generateTokenPublish {
+			return publishInvite(cmd, cfg, data, encoded)
+		}
+
 		fmt.Println()
 		fmt.Println("Setup token (send this to the recipient):")
 		fmt.Println(encoded)
@@ -88,6 +184,99 @@ their devices with a single 'emu-sync setup <token>' command.`,
 	},
 }
 
+// downloadRemoteManifest fetches the library's manifest with the
+// recipient's storage settings, which also checks that their key works.
+func downloadRemoteManifest(cmd *cobra.Command, recipient *config.Config, network config.NetworkConfig) (*manifest.Manifest, error) {
+	client := storage.NewClient(&recipient.Storage, network)
+	data, err := client.DownloadManifest(cmd.Context())
+	if err != nil {
+		return nil, fmt.Errorf("downloading manifest: %w", err)
+	}
+	return manifest.ParseJSON(data)
+}
+
+// recipientReport describes what a first sync with cfg downloads from
+// the library in m: files and size per sync_dir, and the total, with
+// about how long it takes at bps bytes per second (0 to leave out).
+func recipientReport(m *manifest.Manifest, cfg *config.Config, bps int64) string {
+	type total struct {
+		files int
+		size  int64
+	}
+	dirs := make([]total, len(cfg.Sync.SyncDirs))
+	var all total
+	for key, entry := range m.Files {
+		if !cfg.ShouldSync(key) || !cfg.Policy.Allows(key, entry.Tags) {
+			continue
+		}
+		for i, dir := range cfg.Sync.SyncDirs {
+			if key == dir || strings.HasPrefix(key, dir+"/") {
+				dirs[i].files++
+				dirs[i].size += entry.Size
+				break
+			}
+		}
+		all.files++
+		all.size += entry.Size
+	}
+
+	var b strings.Builder
+	b.WriteString("The recipient's first sync downloads:\n")
+	for i, dir := range cfg.Sync.SyncDirs {
+		fmt.Fprintf(&b, "  %-24s %7d files  %10s\n", dir, dirs[i].files, units.FormatSize(dirs[i].size))
+	}
+	fmt.Fprintf(&b, "  %-24s %7d files  %10s\n", "Total", all.files, units.FormatSize(all.size))
+	if bps > 0 && all.size > 0 {
+		d := time.Duration(float64(all.size) / float64(bps) * float64(time.Second))
+		fmt.Fprintf(&b, "About %s at %s/s.\n", units.FormatDuration(d), units.FormatSize(bps))
+	}
+	return b.String()
+}
+
+// publishInvite stores the token in the bucket as a code-encrypted invite
+// and prints its link and code.
+func publishInvite(cmd *cobra.Command, cfg *config.Config, data *token.Data, encoded string) error {
+	code, err := token.NewInviteCode()
+	if err != nil {
+		return err
+	}
+	key, err := token.InviteKey()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(generateTokenTTL)
+	sealed, err := token.SealInvite(encoded, code, key, expires)
+	if err != nil {
+		return err
+	}
+
+	// Store the invite under the token's prefix so the recipient can
+	// delete it with their own credentials once redeemed.
+	storageCfg := cfg.Storage
+	storageCfg.Prefix = data.Prefix
+	client := storage.NewClient(&storageCfg, cfg.Network)
+	if err := client.UploadBytes(cmd.Context(), key, sealed); err != nil {
+		return fmt.Errorf("publishing invite: %w", err)
+	}
+	link, err := client.PresignGet(cmd.Context(), key, generateTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Invite published. Send the recipient this link:")
+	fmt.Println(link)
+	fmt.Println()
+	fmt.Printf("and this code, separately: %s\n", code)
+	fmt.Println()
+	fmt.Printf("They run: emu-sync setup '<link>'\n")
+	fmt.Printf("The link expires %s.\n", expires.Format("2006-01-02 15:04"))
+	return nil
+}
+
 func init() {
+	generateTokenCmd.Flags().BoolVar(&generateTokenPublish, "publish", false, "store the token in the bucket and print a link and short code instead")
+	generateTokenCmd.Flags().DurationVar(&generateTokenTTL, "ttl", 24*time.Hour, "how long a published invite stays valid (max 168h)")
+	generateTokenCmd.Flags().StringVar(&generateTokenBandwidth, "bandwidth", "5MB", "download speed assumed when estimating the recipient's first sync, per second")
 	rootCmd.AddCommand(generateTokenCmd)
 }
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+func TestRecipientReport(t *testing.T) {
+	m := manifest.New()
+	m.Files["roms/snes/A.sfc"] = manifest.FileEntry{Size: 3 << 20}
+	m.Files["roms/snes/B.sfc"] = manifest.FileEntry{Size: 3 << 20}
+	m.Files["roms/ps2/C.iso"] = manifest.FileEntry{Size: 4 << 30}
+	m.Files["roms/n64/D.z64"] = manifest.FileEntry{Size: 64 << 20, Tags: []string{"mature"}}
+	m.Files["bios/scph1001.bin"] = manifest.FileEntry{Size: 512 << 10}
+
+	cfg := &config.Config{
+		Sync:   config.SyncConfig{SyncDirs: []string{"roms", "bios"}, SyncExclude: []string{"roms/ps2"}},
+		Policy: config.PolicyConfig{DenyTags: []string{"mature"}},
+	}
+	got := recipientReport(m, cfg, 1<<20)
+	for _, want := range []string{
+		"roms                           2 files     6.0 MiB",
+		"bios                           1 files     512 KiB",
+		"Total                          3 files     6.5 MiB",
+		"About 7s at 1.0 MiB/s.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var getOutput string
+
+var getCmd = &cobra.Command{
+	Use:     "get key",
+	Aliases: []string{"cat"},
+	Short:   "Download one file from the bucket",
+	Long: `Downloads a single object by its key, e.g. roms/gba/Game.gba, to
+stdout or to the path given by -o. Selections and the local manifest
+are ignored and left unchanged, so this works on a machine that isn't
+otherwise a sync client.
+
+If -o names a directory (or ends in a slash), the file is saved there
+under its own name. The file only appears once the download completes.
+For example:
+
+  emu-sync get roms/gba/Game.gba -o /tmp/Game.gba
+  emu-sync cat roms/snes/Game.sfc | md5sum`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		key := strings.TrimPrefix(args[0], "/")
+		if !cfg.Policy.Allows(key, nil) {
+			return fmt.Errorf("%s is hidden on this device by [policy]", key)
+		}
+		if getOutput == "" && isTerminal(os.Stdout) {
+			return fmt.Errorf("not writing %s to a terminal; use -o PATH or redirect the output", key)
+		}
+
+		client, err := newSyncClient(cfg)
+		if err != nil {
+			return err
+		}
+
+		dest, err := getObject(cmd.Context(), client, key, getOutput, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if dest != "" {
+			fmt.Fprintf(os.Stderr, "Saved %s\n", dest)
+		}
+		return nil
+	},
+}
+
+// getObject downloads key to stdout if out is empty or "-", and otherwise
+// to out (or into it, if it is a directory). It returns the path written,
+// or "" for stdout.
+func getObject(ctx context.Context, client storage.Backend, key, out string, stdout io.Writer) (string, error) {
+	if out == "" || out == "-" {
+		if err := client.DownloadTo(ctx, key, stdout); err != nil {
+			return "", notFoundHint(err, key)
+		}
+		return "", nil
+	}
+
+	dest := out
+	if info, err := os.Stat(out); (err == nil && info.IsDir()) || strings.HasSuffix(out, "/") {
+		dest = filepath.Join(out, path.Base(key))
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	err = client.DownloadTo(ctx, key, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// CreateTemp makes the file 0600; match a normal download
+		err = os.Chmod(tmp, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", notFoundHint(err, key)
+	}
+	return dest, nil
+}
+
+// notFoundHint points at ls when a key doesn't exist, since keys are
+// easy to mistype.
+func notFoundHint(err error, key string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%s is not in the bucket (see 'emu-sync ls' for keys): %w", key, err)
+	}
+	return err
+}
+
+func init() {
+	getCmd.Flags().StringVarP(&getOutput, "output", "o", "", "write to this file or directory instead of stdout")
+	getCmd.MarkFlagFilename("output")
+	rootCmd.AddCommand(getCmd)
+}
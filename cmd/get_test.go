@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestGetObject(t *testing.T) {
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/gba/Game.gba"] = []byte("rom data")
+	ctx := context.Background()
+
+	var stdout bytes.Buffer
+	if dest, err := getObject(ctx, mock, "roms/gba/Game.gba", "", &stdout); err != nil || dest != "" {
+		t.Fatalf("getObject to stdout = %q, %v", dest, err)
+	}
+	if stdout.String() != "rom data" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+
+	dir := t.TempDir()
+	dest, err := getObject(ctx, mock, "roms/gba/Game.gba", dir, nil)
+	if err != nil {
+		t.Fatalf("getObject to directory: %v", err)
+	}
+	if dest != filepath.Join(dir, "Game.gba") {
+		t.Errorf("dest = %q, want file named after the key in %s", dest, dir)
+	}
+
+	file := filepath.Join(dir, "sub", "Renamed.gba")
+	if _, err := getObject(ctx, mock, "roms/gba/Game.gba", file, nil); err != nil {
+		t.Fatalf("getObject to file: %v", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "rom data" {
+		t.Errorf("file content = %q", data)
+	}
+}
+
+func TestGetObjectMissingKey(t *testing.T) {
+	mock := storage.NewMockBackend()
+	dir := t.TempDir()
+
+	_, err := getObject(context.Background(), mock, "roms/gba/Nope.gba", filepath.Join(dir, "Nope.gba"), nil)
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed download left %d files behind", len(entries))
+	}
+}
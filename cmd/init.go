@@ -2,85 +2,67 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/token"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var b2RegionRe = regexp.MustCompile(`s3\.([^.]+)\.backblazeb2\.com`)
 
+const defaultEmulationPath = "/run/media/mmcblk0p1/Emulation"
+
+var (
+	initEndpoint      string
+	initBucket        string
+	initPrefix        string
+	initKeyID         string
+	initSecretStdin   bool
+	initRegion        string
+	initEmulationPath string
+	initSyncDirs      []string
+	initDelete        bool
+	initFromJSON      string
+)
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Interactive configuration wizard",
-	Long:  `Walks through setting up the emu-sync config file with prompts for each field.`,
+	Long: `Walks through setting up the emu-sync config file with prompts for each field.
+
+Passing any flag skips the prompts, for unattended setup from
+configuration management tools. --from-json reads the same fields as a
+setup token (endpoint_url, bucket, key_id, secret_key, region, prefix,
+emulation_path, sync_dirs, delete) from a file, or from stdin with "-";
+other flags override it. Use --secret-stdin to keep the secret key out
+of the process list.
+
+For example:
+  echo "$SECRET" | emu-sync init --endpoint s3.us-west-004.backblazeb2.com \
+      --bucket my-roms --key-id 004abc --secret-stdin
+  emu-sync init --from-json - < emu-sync.json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		reader := bufio.NewReader(os.Stdin)
-
-		fmt.Println("emu-sync configuration wizard")
-		fmt.Println("=============================")
-		fmt.Println()
-
-		endpoint := promptRequired(reader, "S3 endpoint: ")
-		if !strings.Contains(endpoint, "://") {
-			endpoint = "https://" + endpoint
-		}
-
-		bucket := promptRequired(reader, "Bucket name: ")
-		prefix := prompt(reader, "Bucket prefix (leave blank for root): ")
-		keyID := promptRequired(reader, "Access key ID: ")
-		secretKey := promptRequired(reader, "Secret access key: ")
-
-		// Auto-detect region from B2 endpoint URLs
-		var region string
-		if m := b2RegionRe.FindStringSubmatch(endpoint); m != nil {
-			region = m[1]
-			fmt.Printf("Detected region: %s\n", region)
-		} else {
-			region = prompt(reader, "Region: ")
-		}
-
-		defaultPath := "/run/media/mmcblk0p1/Emulation"
-		emuPath := prompt(reader, fmt.Sprintf("Emulation path [%s]: ", defaultPath))
-		if emuPath == "" {
-			emuPath = defaultPath
-		}
-
-		syncDirsStr := prompt(reader, "Sync directories (comma-separated) [roms,bios]: ")
-		var syncDirs []string
-		if syncDirsStr == "" {
-			syncDirs = []string{"roms", "bios"}
-		} else {
-			for _, d := range strings.Split(syncDirsStr, ",") {
-				syncDirs = append(syncDirs, strings.TrimSpace(d))
+		var cfg *config.Config
+		if initFlagsSet(cmd) {
+			var err error
+			cfg, err = initConfigFromFlags(cmd, os.Stdin)
+			if err != nil {
+				return err
 			}
-		}
-
-		deleteStr := prompt(reader, "Delete local files removed from bucket? (y/n) [y]: ")
-		deleteFiles := deleteStr == "" || strings.HasPrefix(strings.ToLower(deleteStr), "y")
-
-		cfg := &config.Config{
-			Storage: config.StorageConfig{
-				EndpointURL: endpoint,
-				Bucket:      bucket,
-				KeyID:       keyID,
-				SecretKey:   secretKey,
-				Region:      region,
-				Prefix:      prefix,
-			},
-			Sync: config.SyncConfig{
-				EmulationPath: emuPath,
-				SyncDirs:      syncDirs,
-				Delete:        deleteFiles,
-			},
+		} else {
+			cfg = initConfigInteractive()
 		}
 
 		fmt.Print("\nVerifying credentials...")
-		client := storage.NewClient(&cfg.Storage)
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
 		if err := client.Ping(cmd.Context()); err != nil {
 			fmt.Println(" failed")
 			return fmt.Errorf("credential check failed: %w", err)
@@ -101,6 +83,173 @@ var initCmd = &cobra.Command{
 	},
 }
 
+// initFlagsSet reports whether any of init's own flags were given.
+func initFlagsSet(cmd *cobra.Command) bool {
+	set := false
+	cmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			set = true
+		}
+	})
+	return set
+}
+
+// initConfigFromFlags builds a config from --from-json and the other
+// flags without prompting. stdin supplies --from-json - and --secret-stdin.
+func initConfigFromFlags(cmd *cobra.Command, stdin io.Reader) (*config.Config, error) {
+	if initFromJSON == "-" && initSecretStdin {
+		return nil, fmt.Errorf("--from-json - and --secret-stdin both read stdin; put secret_key in the JSON instead")
+	}
+
+	d := &token.Data{}
+	if initFromJSON != "" {
+		var data []byte
+		var err error
+		if initFromJSON == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(initFromJSON)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading --from-json: %w", err)
+		}
+		if err := json.Unmarshal(data, d); err != nil {
+			return nil, fmt.Errorf("parsing --from-json: %w", err)
+		}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("endpoint") {
+		d.EndpointURL = initEndpoint
+	}
+	if flags.Changed("bucket") {
+		d.Bucket = initBucket
+	}
+	if flags.Changed("prefix") {
+		d.Prefix = initPrefix
+	}
+	if flags.Changed("key-id") {
+		d.KeyID = initKeyID
+	}
+	if flags.Changed("region") {
+		d.Region = initRegion
+	}
+	if flags.Changed("emulation-path") {
+		d.EmulationPath = initEmulationPath
+	}
+	if flags.Changed("sync-dirs") {
+		d.SyncDirs = initSyncDirs
+	}
+	if flags.Changed("delete") {
+		d.Delete = &initDelete
+	}
+	if initSecretStdin {
+		secret, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("reading secret key from stdin: %w", err)
+		}
+		d.SecretKey = strings.TrimSpace(secret)
+	}
+
+	if d.EndpointURL == "" {
+		return nil, fmt.Errorf("--endpoint is required")
+	}
+	if d.Bucket == "" {
+		return nil, fmt.Errorf("--bucket is required")
+	}
+	if (d.KeyID == "") != (d.SecretKey == "") {
+		return nil, fmt.Errorf("--key-id and a secret key (--secret-stdin) must be given together")
+	}
+	if !strings.Contains(d.EndpointURL, "://") {
+		d.EndpointURL = "https://" + d.EndpointURL
+	}
+	if d.Region == "" {
+		if m := b2RegionRe.FindStringSubmatch(d.EndpointURL); m != nil {
+			d.Region = m[1]
+		}
+	}
+	if d.EmulationPath == "" {
+		d.EmulationPath = defaultEmulationPath
+	}
+	return d.ToConfig(), nil
+}
+
+// initConfigInteractive prompts for each config field.
+func initConfigInteractive() *config.Config {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println("emu-sync configuration wizard")
+	fmt.Println("=============================")
+	fmt.Println()
+
+	endpoint := promptRequired(reader, "S3 endpoint: ")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	bucket := promptRequired(reader, "Bucket name: ")
+	prefix := prompt(reader, "Bucket prefix (leave blank for root): ")
+	keyID := prompt(reader, "Access key ID (leave blank to use AWS default credentials): ")
+	var secretKey string
+	if keyID != "" {
+		secretKey = promptRequired(reader, "Secret access key: ")
+	}
+
+	// Auto-detect region from B2 endpoint URLs
+	var region string
+	if m := b2RegionRe.FindStringSubmatch(endpoint); m != nil {
+		region = m[1]
+		fmt.Printf("Detected region: %s\n", region)
+	} else {
+		region = prompt(reader, "Region: ")
+	}
+
+	emuPath := prompt(reader, fmt.Sprintf("Emulation path [%s]: ", defaultEmulationPath))
+	if emuPath == "" {
+		emuPath = defaultEmulationPath
+	}
+
+	syncDirsStr := prompt(reader, "Sync directories (comma-separated) [roms,bios]: ")
+	var syncDirs []string
+	if syncDirsStr == "" {
+		syncDirs = []string{"roms", "bios"}
+	} else {
+		for _, d := range strings.Split(syncDirsStr, ",") {
+			syncDirs = append(syncDirs, strings.TrimSpace(d))
+		}
+	}
+
+	deleteStr := prompt(reader, "Delete local files removed from bucket? (y/n) [y]: ")
+	deleteFiles := deleteStr == "" || strings.HasPrefix(strings.ToLower(deleteStr), "y")
+
+	return &config.Config{
+		Storage: config.StorageConfig{
+			EndpointURL: endpoint,
+			Bucket:      bucket,
+			KeyID:       keyID,
+			SecretKey:   secretKey,
+			Region:      region,
+			Prefix:      prefix,
+		},
+		Sync: config.SyncConfig{
+			EmulationPath: emuPath,
+			SyncDirs:      syncDirs,
+			Delete:        deleteFiles,
+		},
+	}
+}
+
 func init() {
+	initCmd.Flags().StringVar(&initEndpoint, "endpoint", "", "S3 endpoint URL")
+	initCmd.Flags().StringVar(&initBucket, "bucket", "", "bucket name")
+	initCmd.Flags().StringVar(&initPrefix, "prefix", "", "bucket prefix")
+	initCmd.Flags().StringVar(&initKeyID, "key-id", "", "access key ID (omit to use AWS default credentials)")
+	initCmd.Flags().BoolVar(&initSecretStdin, "secret-stdin", false, "read the secret access key from stdin")
+	initCmd.Flags().StringVar(&initRegion, "region", "", "region (detected from Backblaze B2 endpoints)")
+	initCmd.Flags().StringVar(&initEmulationPath, "emulation-path", "", "emulation path (default "+defaultEmulationPath+")")
+	initCmd.Flags().StringSliceVar(&initSyncDirs, "sync-dirs", nil, "directories to sync, comma-separated (default roms,bios)")
+	initCmd.Flags().BoolVar(&initDelete, "delete", true, "delete local files removed from the bucket")
+	initCmd.Flags().StringVar(&initFromJSON, "from-json", "", `read settings from a JSON file ("-" for stdin)`)
+	initCmd.MarkFlagDirname("emulation-path")
 	rootCmd.AddCommand(initCmd)
 }
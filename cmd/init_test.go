@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// parseInitFlags parses args into initCmd's flags and resets them when
+// the test ends.
+func parseInitFlags(t *testing.T, args ...string) {
+	t.Helper()
+	t.Cleanup(func() {
+		initCmd.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+			f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+		initSyncDirs = nil
+	})
+	if err := initCmd.ParseFlags(args); err != nil {
+		t.Fatalf("parsing flags: %v", err)
+	}
+}
+
+func TestInitConfigFromFlags(t *testing.T) {
+	parseInitFlags(t,
+		"--endpoint", "s3.us-west-004.backblazeb2.com",
+		"--bucket", "my-roms",
+		"--key-id", "004abc",
+		"--secret-stdin",
+		"--sync-dirs", "roms,bios,saves",
+		"--delete=false",
+	)
+	if !initFlagsSet(initCmd) {
+		t.Fatal("initFlagsSet = false, want true")
+	}
+
+	cfg, err := initConfigFromFlags(initCmd, strings.NewReader("K004secret\n"))
+	if err != nil {
+		t.Fatalf("initConfigFromFlags: %v", err)
+	}
+	if cfg.Storage.EndpointURL != "https://s3.us-west-004.backblazeb2.com" {
+		t.Errorf("endpoint = %q", cfg.Storage.EndpointURL)
+	}
+	if cfg.Storage.Region != "us-west-004" {
+		t.Errorf("region = %q, want detected us-west-004", cfg.Storage.Region)
+	}
+	if cfg.Storage.SecretKey != "K004secret" {
+		t.Errorf("secret = %q, want K004secret from stdin", cfg.Storage.SecretKey)
+	}
+	if cfg.Sync.EmulationPath != defaultEmulationPath {
+		t.Errorf("emulation path = %q, want default", cfg.Sync.EmulationPath)
+	}
+	if strings.Join(cfg.Sync.SyncDirs, ",") != "roms,bios,saves" {
+		t.Errorf("sync dirs = %v", cfg.Sync.SyncDirs)
+	}
+	if cfg.Sync.Delete {
+		t.Error("delete = true, want false")
+	}
+}
+
+func TestInitConfigFromJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emu-sync.json")
+	os.WriteFile(path, []byte(`{
+		"endpoint_url": "https://minio.local:9000",
+		"bucket": "roms",
+		"key_id": "minio",
+		"secret_key": "minio123",
+		"region": "us-east-1",
+		"emulation_path": "/home/deck/Emulation"
+	}`), 0o644)
+	parseInitFlags(t, "--from-json", path, "--bucket", "override")
+
+	cfg, err := initConfigFromFlags(initCmd, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("initConfigFromFlags: %v", err)
+	}
+	if cfg.Storage.Bucket != "override" {
+		t.Errorf("bucket = %q, want flag to override JSON", cfg.Storage.Bucket)
+	}
+	if cfg.Storage.SecretKey != "minio123" || cfg.Sync.EmulationPath != "/home/deck/Emulation" {
+		t.Errorf("config = %+v, want JSON values", cfg)
+	}
+	if !cfg.Sync.Delete {
+		t.Error("delete should default to true")
+	}
+}
+
+func TestInitConfigFromFlagsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{"missing bucket", []string{"--endpoint", "s3.example.com"}, "", "--bucket is required"},
+		{"key without secret", []string{"--endpoint", "s3.example.com", "--bucket", "b", "--key-id", "k"}, "", "together"},
+		{"stdin twice", []string{"--from-json", "-", "--secret-stdin"}, "{}", "both read stdin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseInitFlags(t, tt.args...)
+			_, err := initConfigFromFlags(initCmd, strings.NewReader(tt.stdin))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
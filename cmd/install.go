@@ -52,27 +52,28 @@ in ~/Applications that opens the web UI.
 Use --no-shortcuts to skip shortcuts/app and only install the
 timer/schedule. Syncs automatically every 6 hours.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// Resolve the actual binary path
-		binPath, err := os.Executable()
-		if err != nil {
-			return fmt.Errorf("resolving binary path: %w", err)
-		}
-		binPath, err = filepath.EvalSymlinks(binPath)
-		if err != nil {
-			return fmt.Errorf("resolving binary symlinks: %w", err)
-		}
-
-		switch runtime.GOOS {
-		case "linux":
-			return installLinux(binPath)
-		case "darwin":
-			return installMacOS(binPath)
-		default:
-			return fmt.Errorf("install is not supported on %s", runtime.GOOS)
-		}
+		return installSchedule()
 	},
 }
 
+// installSchedule installs the sync schedule (and shortcuts, unless
+// --no-shortcuts) for the running binary on this platform.
+func installSchedule() error {
+	binPath, err := executablePath()
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return installLinux(binPath)
+	case "darwin":
+		return installMacOS(binPath)
+	default:
+		return fmt.Errorf("install is not supported on %s", runtime.GOOS)
+	}
+}
+
 func installLinux(binPath string) error {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -5,6 +5,11 @@
 SYNC_OUTPUT=$("$HOME/.local/bin/emu-sync" sync 2>&1)
 EXIT_CODE=$?
 
+# Exit code 3 means nothing to do
+if [ $EXIT_CODE -eq 3 ]; then
+    EXIT_CODE=0
+fi
+
 if command -v kdialog >/dev/null 2>&1; then
     if [ $EXIT_CODE -eq 0 ]; then
         kdialog --passivepopup "$SYNC_OUTPUT" 10 --title "emu-sync"
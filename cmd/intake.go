@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+var intakeExpires time.Duration
+
+var intakeCmd = &cobra.Command{
+	Use:   "intake",
+	Short: "Collect files from friends without sharing credentials",
+	Long: `Lets someone you trust contribute a file to the library without bucket
+credentials. 'intake link' prints a presigned upload URL that can write
+one object under intake/ in the bucket. Contributions stay there, out of
+the manifest, until you review them and accept or reject each one.
+
+  emu-sync intake link "Chrono Trigger.sfc" --expires 48h
+  emu-sync intake review
+  emu-sync intake accept "Chrono Trigger.sfc" roms/snes/
+  emu-sync intake reject "Chrono Trigger.sfc"`,
+}
+
+var intakeLinkCmd = &cobra.Command{
+	Use:   "link name",
+	Short: "Print an upload link for one contribution",
+	Long: `Prints a presigned URL that uploads a single file to intake/<name>.
+Anyone with the link can upload (or replace) that one object until it
+expires (at most 7 days); it grants no other access. The contributor
+uploads with an HTTP PUT of the file, e.g.:
+
+  curl -T "Chrono Trigger.sfc" '<link>'`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadIntakeConfig()
+		if err != nil {
+			return err
+		}
+		if intakeExpires <= 0 || intakeExpires > storage.MaxPresignTTL {
+			return fmt.Errorf("--expires must be positive and at most %s", storage.MaxPresignTTL)
+		}
+
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
+		link, err := intakeLink(cmd.Context(), client, args[0], intakeExpires)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(link)
+		fmt.Fprintf(os.Stderr, "Upload with: curl -T FILE '<link>'\n")
+		fmt.Fprintf(os.Stderr, "The link expires %s. Run 'emu-sync intake review' to see what arrived.\n", time.Now().Add(intakeExpires).Format("2006-01-02 15:04"))
+		return nil
+	},
+}
+
+var intakeReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "List contributions waiting in the intake",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+		waiting, err := upload.ListIntake(cmd.Context(), client)
+		if err != nil {
+			return fmt.Errorf("listing intake: %w", err)
+		}
+		if len(waiting) == 0 {
+			fmt.Println("Nothing waiting in the intake.")
+			return nil
+		}
+
+		var total int64
+		for _, obj := range waiting {
+			total += obj.Size
+			fmt.Printf("  %s (%s, uploaded %s)\n", strings.TrimPrefix(obj.Key, upload.IntakePrefix), formatSize(obj.Size), obj.LastModified.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("%s waiting, %s\n", pluralFiles(len(waiting)), formatSize(total))
+		fmt.Println("Accept with 'emu-sync intake accept NAME DEST' or remove with 'emu-sync intake reject NAME'.")
+		return nil
+	},
+}
+
+var intakeAcceptCmd = &cobra.Command{
+	Use:   "accept name dest",
+	Short: "Move a contribution into the library",
+	Long: `Copies intake/<name> to dest inside the bucket, adds it to the
+manifest, and deletes it from the intake. If dest ends with a slash the
+file keeps its name inside that directory:
+
+  emu-sync intake accept "Chrono Trigger.sfc" roms/snes/`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadIntakeConfig()
+		if err != nil {
+			return err
+		}
+		src, err := upload.IntakeKey(args[0])
+		if err != nil {
+			return err
+		}
+		key, err := upload.PutKey(src, args[1])
+		if err != nil {
+			return err
+		}
+
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
+		maxRetries := cfg.Sync.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = 3
+		}
+		result, err := upload.Accept(cmd.Context(), client, src, key, cfg.Sync.Tuning, maxRetries)
+		if err != nil {
+			return intakeNotFound(err, args[0])
+		}
+
+		switch {
+		case result.Unchanged:
+			fmt.Printf("%s is already in the library; removed it from the intake\n", key)
+		case result.Replaced:
+			fmt.Printf("Replaced %s (%s)\n", key, formatSize(result.Entry.Size))
+		default:
+			fmt.Printf("Added %s (%s)\n", key, formatSize(result.Entry.Size))
+		}
+		return nil
+	},
+}
+
+var intakeRejectCmd = &cobra.Command{
+	Use:   "reject name...",
+	Short: "Delete contributions from the intake",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadIntakeConfig()
+		if err != nil {
+			return err
+		}
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
+		maxRetries := cfg.Sync.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = 3
+		}
+
+		for _, name := range args {
+			src, err := upload.IntakeKey(name)
+			if err != nil {
+				return err
+			}
+			if _, err := client.HeadObject(cmd.Context(), src); err != nil {
+				return intakeNotFound(err, name)
+			}
+			if err := upload.Reject(cmd.Context(), client, src, maxRetries); err != nil {
+				return fmt.Errorf("delete %s: %w", src, err)
+			}
+			fmt.Printf("Rejected %s\n", strings.TrimPrefix(src, upload.IntakePrefix))
+		}
+		return nil
+	},
+}
+
+// loadIntakeConfig loads the config for the intake commands that need
+// write access to the bucket.
+func loadIntakeConfig() (*config.Config, error) {
+	cfgPath := cfgFile
+	if cfgPath == "" {
+		cfgPath = config.DefaultConfigPath()
+	}
+
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if err := requireWritable(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// intakeLink presigns an upload of name into the intake. It refuses a
+// name that's already waiting, so a second link can't silently replace
+// a contribution that hasn't been reviewed.
+func intakeLink(ctx context.Context, client storage.Backend, name string, ttl time.Duration) (string, error) {
+	key, err := upload.IntakeKey(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.HeadObject(ctx, key); err == nil {
+		return "", fmt.Errorf("%s is already waiting in the intake; accept or reject it first", name)
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return "", err
+	}
+	return client.PresignPut(ctx, key, ttl)
+}
+
+// intakeNotFound points at 'intake review' when name isn't in the intake.
+func intakeNotFound(err error, name string) error {
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%s is not in the intake (see 'emu-sync intake review'): %w", name, err)
+	}
+	return err
+}
+
+func init() {
+	intakeLinkCmd.Flags().DurationVar(&intakeExpires, "expires", 24*time.Hour, "how long the link stays valid (max 168h)")
+	intakeCmd.AddCommand(intakeLinkCmd, intakeReviewCmd, intakeAcceptCmd, intakeRejectCmd)
+	rootCmd.AddCommand(intakeCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestIntakeLink(t *testing.T) {
+	mock := storage.NewMockBackend()
+
+	link, err := intakeLink(context.Background(), mock, "Game.sfc", time.Hour)
+	if err != nil {
+		t.Fatalf("intakeLink: %v", err)
+	}
+	if !strings.Contains(link, "intake/Game.sfc") || !strings.Contains(link, "method=PUT") {
+		t.Errorf("link = %q, want a PUT to intake/Game.sfc", link)
+	}
+
+	mock.Objects["intake/Game.sfc"] = []byte("waiting")
+	if _, err := intakeLink(context.Background(), mock, "Game.sfc", time.Hour); err == nil {
+		t.Error("a name already waiting in the intake should be refused")
+	}
+	if _, err := intakeLink(context.Background(), mock, "../manifest.json", time.Hour); err == nil {
+		t.Error("an invalid name should be refused")
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var lsJSON bool
+var lsTSV bool
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [prefix]",
+	Short: "List files in the library",
+	Long: `Prints the files in the remote manifest, optionally only those whose
+key starts with prefix, along with whether each is selected for sync and
+already downloaded. Reads the manifests only; nothing on disk changes.
+
+--json prints an array of {key, size, md5, selected, present} objects.
+--tsv prints one file per line with the same fields in that order, size
+in bytes, and true/false flags, e.g.:
+
+  emu-sync ls roms/psx --tsv | cut -f1`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		var prefix string
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+		remoteData, err := client.DownloadManifest(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("downloading manifest: %w", err)
+		}
+		remote, err := manifest.ParseJSON(remoteData)
+		if err != nil {
+			return fmt.Errorf("parsing manifest: %w", err)
+		}
+		cfg.Policy.Hide(remote)
+
+		entries := listEntries(remote, loadLocalManifest(""), cfg, prefix)
+		switch {
+		case lsJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		case lsTSV:
+			writeListingTSV(os.Stdout, entries)
+		default:
+			writeListing(os.Stdout, entries)
+		}
+		return nil
+	},
+}
+
+// lsEntry is one file as printed by ls.
+type lsEntry struct {
+	Key      string `json:"key"`
+	Size     int64  `json:"size"`
+	MD5      string `json:"md5"`
+	Selected bool   `json:"selected"`
+	Present  bool   `json:"present"` // current version already downloaded
+}
+
+// listEntries returns the remote files whose keys start with prefix,
+// sorted by key.
+func listEntries(remote, local *manifest.Manifest, cfg *config.Config, prefix string) []lsEntry {
+	entries := []lsEntry{}
+	for key, entry := range remote.Files {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		localEntry, ok := local.Files[key]
+		entries = append(entries, lsEntry{
+			Key:      key,
+			Size:     entry.Size,
+			MD5:      entry.MD5,
+			Selected: cfg.ShouldSync(key),
+			Present:  ok && localEntry.MD5 == entry.MD5,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// writeListing prints entries for reading, with the same download state
+// labels as choose, followed by a total.
+func writeListing(w io.Writer, entries []lsEntry) {
+	var total int64
+	for _, e := range entries {
+		label := presenceLabel(&fileInfo{Selected: e.Selected, Present: e.Present})
+		fmt.Fprintf(w, "%10s  %-10s  %s\n", formatSize(e.Size), label, e.Key)
+		total += e.Size
+	}
+	fmt.Fprintf(w, "%s, %s\n", pluralFiles(len(entries)), formatSize(total))
+}
+
+// writeListingTSV prints entries as tab-separated lines with no header.
+func writeListingTSV(w io.Writer, entries []lsEntry) {
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%t\t%t\n", e.Key, e.Size, e.MD5, e.Selected, e.Present)
+	}
+}
+
+func init() {
+	lsCmd.Flags().BoolVar(&lsJSON, "json", false, "print JSON")
+	lsCmd.Flags().BoolVar(&lsTSV, "tsv", false, "print tab-separated values")
+	lsCmd.MarkFlagsMutuallyExclusive("json", "tsv")
+	rootCmd.AddCommand(lsCmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+func TestListEntries(t *testing.T) {
+	remote := manifest.New()
+	remote.Files["roms/psx/B.chd"] = manifest.FileEntry{Size: 200, MD5: "b"}
+	remote.Files["roms/psx/A.chd"] = manifest.FileEntry{Size: 100, MD5: "a"}
+	remote.Files["roms/gba/C.gba"] = manifest.FileEntry{Size: 50, MD5: "c"}
+	local := manifest.New()
+	local.Files["roms/psx/A.chd"] = manifest.FileEntry{Size: 100, MD5: "a"}
+	local.Files["roms/psx/B.chd"] = manifest.FileEntry{Size: 200, MD5: "old"}
+	cfg := &config.Config{Sync: config.SyncConfig{
+		SyncDirs:    []string{"roms/psx"},
+		SyncExclude: []string{"roms/psx/B.chd"},
+	}}
+
+	entries := listEntries(remote, local, cfg, "roms/psx/")
+	want := []lsEntry{
+		{Key: "roms/psx/A.chd", Size: 100, MD5: "a", Selected: true, Present: true},
+		{Key: "roms/psx/B.chd", Size: 200, MD5: "b"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entries[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	writeListingTSV(&buf, entries)
+	wantTSV := "roms/psx/A.chd\t100\ta\ttrue\ttrue\nroms/psx/B.chd\t200\tb\tfalse\tfalse\n"
+	if buf.String() != wantTSV {
+		t.Errorf("TSV = %q, want %q", buf.String(), wantTSV)
+	}
+
+	if got := listEntries(remote, local, cfg, "roms/n64"); got == nil || len(got) != 0 {
+		t.Errorf("no matches = %#v, want empty (not nil) for JSON []", got)
+	}
+}
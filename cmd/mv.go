@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv source dest",
+	Short: "Rename or move files in the library",
+	Long: `Renames a file or directory in the bucket. Objects are copied inside
+the bucket, so nothing is downloaded or uploaded, and the manifest is
+rewritten to match. Recipients rename their copies on the next sync
+instead of downloading them again (when sync.delete is on).
+
+If source is a file, dest is its new key, or the directory to move it
+into if dest ends with a slash. If source is a directory, everything
+under it moves to dest:
+
+  emu-sync mv "roms/gba/Pokemon Emrald.gba" "roms/gba/Pokemon Emerald.gba"
+  emu-sync mv roms/psx roms/ps1
+
+A full 'upload' puts files back under their old names if they're still
+there in the source directory, so rename them there too.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := requireWritable(cfg); err != nil {
+			return err
+		}
+
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
+		remoteData, err := client.DownloadManifest(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("downloading manifest: %w", err)
+		}
+		remote, err := manifest.ParseJSON(remoteData)
+		if err != nil {
+			return fmt.Errorf("parsing manifest: %w", err)
+		}
+
+		moves, err := upload.PlanMoves(remote, args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		maxRetries := cfg.Sync.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = 3
+		}
+		result, err := upload.MoveKeys(cmd.Context(), client, moves, cfg.Sync.Tuning, maxRetries)
+		if err != nil {
+			return err
+		}
+
+		for _, mv := range result.Moved {
+			fmt.Printf("  %s -> %s\n", mv.From, mv.To)
+		}
+		fmt.Printf("Moved %s\n", pluralFiles(len(result.Moved)))
+		for _, err := range result.Errors {
+			fmt.Printf("  warning: old copy left in the bucket: %v\n", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -40,3 +42,40 @@ func promptWithDefault(reader *bufio.Reader, label, defaultVal string) string {
 	}
 	return text
 }
+
+// promptInt is promptWithDefault for a non-negative integer, where 0
+// means unset and is shown as a blank default.
+func promptInt(reader *bufio.Reader, label string, defaultVal int) (int, error) {
+	def := ""
+	if defaultVal > 0 {
+		def = strconv.Itoa(defaultVal)
+	}
+	s := promptWithDefault(reader, label, def)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a non-negative number", s)
+	}
+	return n, nil
+}
+
+// promptList is promptWithDefault for a comma-separated list. Blank
+// entries are dropped.
+func promptList(reader *bufio.Reader, label string, defaultVal []string) []string {
+	var list []string
+	for _, s := range strings.Split(promptWithDefault(reader, label, strings.Join(defaultVal, ",")), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a
+// pipe or file, e.g. stdin under 'curl | bash'.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
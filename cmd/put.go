@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+var putCmd = &cobra.Command{
+	Use:   "put file key",
+	Short: "Upload one file and add it to the manifest",
+	Long: `Uploads a single file under key and adds it to the remote manifest,
+without walking the rest of the library. If key ends with a slash, the
+file keeps its own name inside that directory:
+
+  emu-sync put ./Game.gba roms/gba/
+
+A file already in the bucket with the same content is not uploaded
+again; different content replaces it. If the manifest changes while it
+is being updated (another upload finishing), the update is redone on
+top of the new version.
+
+A full 'upload' deletes bucket files missing from the source directory,
+so copy the file there too, or upload with --merge or --delete=false.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := requireWritable(cfg); err != nil {
+			return err
+		}
+
+		key, err := upload.PutKey(args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		maxRetries := cfg.Sync.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = 3
+		}
+
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
+
+		result, err := upload.Put(cmd.Context(), client, args[0], key, cfg.Sync.Tuning, maxRetries)
+		if err != nil {
+			return err
+		}
+
+		size := formatSize(result.Entry.Size)
+		switch {
+		case result.Unchanged:
+			fmt.Printf("%s is already in the bucket with the same content\n", key)
+			return nil
+		case result.Replaced:
+			fmt.Printf("Replaced %s (%s)\n", key, size)
+		default:
+			fmt.Printf("Uploaded %s (%s)\n", key, size)
+		}
+		recordUsage("", result.Entry.Size, 0)
+
+		if note := putCaveat(cfg, key); note != "" {
+			fmt.Println(note)
+		}
+		return nil
+	},
+}
+
+// putCaveat warns when the next full upload from the emulation path would
+// delete a file that put just published.
+func putCaveat(cfg *config.Config, key string) string {
+	local := filepath.Join(cfg.Sync.EmulationPath, filepath.FromSlash(key))
+	if _, err := os.Stat(local); err == nil {
+		for _, dir := range cfg.Sync.SyncDirs {
+			if strings.HasPrefix(key, strings.TrimSuffix(dir, "/")+"/") {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("Note: %s isn't in the upload source (%s) under sync_dirs, so a full upload will remove it from the bucket unless it uses --merge or --delete=false.", key, cfg.Sync.EmulationPath)
+}
+
+func init() {
+	rootCmd.AddCommand(putCmd)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+)
+
+func TestPutCaveat(t *testing.T) {
+	emuPath := t.TempDir()
+	os.MkdirAll(filepath.Join(emuPath, "roms", "gba"), 0o755)
+	os.WriteFile(filepath.Join(emuPath, "roms", "gba", "Game.gba"), []byte("x"), 0o644)
+	os.WriteFile(filepath.Join(emuPath, "Loose.gba"), []byte("x"), 0o644)
+	cfg := &config.Config{Sync: config.SyncConfig{EmulationPath: emuPath, SyncDirs: []string{"roms/gba"}}}
+
+	if note := putCaveat(cfg, "roms/gba/Game.gba"); note != "" {
+		t.Errorf("file in the source under sync_dirs got a caveat: %s", note)
+	}
+	if putCaveat(cfg, "roms/gba/Missing.gba") == "" {
+		t.Error("file missing from the source should get a caveat")
+	}
+	if putCaveat(cfg, "Loose.gba") == "" {
+		t.Error("file outside sync_dirs should get a caveat")
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+var rmKeepObject bool
+var rmYes bool
+
+// rmListLimit is how many matching files rm shows before asking.
+const rmListLimit = 20
+
+var rmCmd = &cobra.Command{
+	Use:   "rm key...",
+	Short: "Remove files or directories from the library",
+	Long: `Removes files from the remote manifest and deletes their objects from
+the bucket. A key that names a directory (e.g. roms/psx) removes
+everything under it. Recipients delete the files on their next sync
+if sync.delete is on.
+
+The matching files are listed and you're asked to confirm; --yes skips
+the question. With --keep-object only the manifest entries are removed
+and the objects stay in the bucket.
+
+A full 'upload' adds files back if they're still in the source
+directory, so delete them there too.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := requireWritable(cfg); err != nil {
+			return err
+		}
+
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
+		remoteData, err := client.DownloadManifest(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("downloading manifest: %w", err)
+		}
+		remote, err := manifest.ParseJSON(remoteData)
+		if err != nil {
+			return fmt.Errorf("parsing manifest: %w", err)
+		}
+
+		keys := upload.MatchKeys(remote, args)
+		if len(keys) == 0 {
+			return fmt.Errorf("nothing in the library matches %v (see 'emu-sync ls')", args)
+		}
+
+		printRemoval(remote, keys)
+		action := "Remove"
+		if rmKeepObject {
+			action = "Remove from the manifest (keeping the objects)"
+		}
+		if !rmYes && !confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("%s %s?", action, pluralFiles(len(keys)))) {
+			fmt.Println("Nothing removed.")
+			return nil
+		}
+
+		maxRetries := cfg.Sync.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = 3
+		}
+		result, err := upload.Remove(cmd.Context(), client, keys, rmKeepObject, maxRetries)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed %s from the manifest", pluralFiles(len(result.Removed)))
+		if !rmKeepObject {
+			fmt.Printf(", deleted %s from the bucket", pluralFiles(len(result.Deleted)))
+		}
+		fmt.Println()
+		for _, err := range result.Errors {
+			fmt.Printf("  error: %v\n", err)
+		}
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("%d objects could not be deleted", len(result.Errors))
+		}
+		return nil
+	},
+}
+
+// printRemoval lists the files rm is about to remove and their total size.
+func printRemoval(remote *manifest.Manifest, keys []string) {
+	var total int64
+	for i, key := range keys {
+		size := remote.Files[key].Size
+		total += size
+		if i < rmListLimit {
+			fmt.Printf("  %s (%s)\n", key, formatSize(size))
+		}
+	}
+	if len(keys) > rmListLimit {
+		fmt.Printf("  ... and %d more\n", len(keys)-rmListLimit)
+	}
+	fmt.Printf("%s, %s\n", pluralFiles(len(keys)), formatSize(total))
+}
+
+func init() {
+	rmCmd.Flags().BoolVar(&rmKeepObject, "keep-object", false, "only remove manifest entries; leave the objects in the bucket")
+	rmCmd.Flags().BoolVarP(&rmYes, "yes", "y", false, "don't ask for confirmation")
+	rootCmd.AddCommand(rmCmd)
+}
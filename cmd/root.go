@@ -1,12 +1,21 @@
 package cmd
 
 import (
+	"fmt"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile     string
+	stateDir    string
+	verbosity   int
+	noColor     bool
+	asciiOutput bool
+	quietOutput bool
 )
 
 var rootCmd = &cobra.Command{
@@ -19,8 +28,35 @@ Upload from your main machine, sync to your Steam Decks.`,
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path (default ~/.config/emu-sync/config.toml)")
-	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "enable debug logging")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path (default EMU_SYNC_CONFIG, or ~/.config/emu-sync/config.toml)")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "log each file transferred; repeat (-vv) for retries, cache hits, and HTTP requests")
+	rootCmd.PersistentFlags().StringVar(&stateDir, "state-dir", "", "directory for state files such as the local manifest (default EMU_SYNC_STATE_DIR, or ~/.local/share/emu-sync)")
+	rootCmd.MarkPersistentFlagFilename("config", "toml")
+	rootCmd.MarkPersistentFlagDirname("state-dir")
+	cobra.OnInitialize(func() {
+		config.SetStateDir(stateDir)
+		logging.SetLevel(verbosity)
+		output.SetASCII(asciiOutput)
+		output.SetQuiet(quietOutput)
+	})
+}
+
+// addOutputFlags adds --no-color, --ascii, and --quiet to cmd. Commands
+// that take them format their output with the output package.
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "don't color output (emu-sync never does; accepted so wrappers can always pass it)")
+	cmd.Flags().BoolVar(&asciiOutput, "ascii", false, "spell out markers like [~] and +, for screen readers and basic consoles")
+	cmd.Flags().BoolVarP(&quietOutput, "quiet", "q", false, "print only warnings and errors")
+}
+
+// ExitError asks main to exit with Code without printing anything; the
+// command has already reported the outcome.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
 }
 
 // SetVersion sets the version string displayed by --version.
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+	"github.com/jacobfgrant/emu-sync/internal/token"
+	"github.com/jacobfgrant/emu-sync/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var runOnceToken string
+var runOnceEmulationPath string
+var runOnceYes bool
+var runOnceNoInstall bool
+var runOnceWorkers int
+
+var runOnceCmd = &cobra.Command{
+	Use:   "run-once",
+	Short: "Set up, sync, and install the schedule in one step",
+	Long: `Bootstraps a device from a setup token: writes the config, runs the
+first sync, and installs the sync schedule and shortcuts, then prints a
+report. Meant for provisioning several handhelds quickly.
+
+With --yes nothing is asked: an existing config is overwritten and a
+missing emulation path is created. Without it, run-once asks before
+doing either.
+
+For example:
+  emu-sync run-once --token <token> --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		reader := bufio.NewReader(os.Stdin)
+
+		if runOnceToken == "" {
+			if runOnceYes {
+				return fmt.Errorf("--token is required with --yes")
+			}
+			runOnceToken = prompt(reader, "Paste setup token: ")
+			if runOnceToken == "" {
+				return fmt.Errorf("no token provided")
+			}
+		}
+
+		data, err := token.Decode(runOnceToken)
+		if err != nil {
+			return err
+		}
+		cfg := data.ToConfig()
+		if runOnceEmulationPath != "" {
+			cfg.Sync.EmulationPath = runOnceEmulationPath
+		}
+		if cfg.Sync.EmulationPath == "" {
+			return fmt.Errorf("the token has no emulation path; pass --emulation-path")
+		}
+		if runOnceWorkers > 0 {
+			cfg.Sync.Workers = runOnceWorkers
+		}
+
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+		if _, err := os.Stat(cfgPath); err == nil && !confirm(reader, fmt.Sprintf("Overwrite existing config %s?", cfgPath)) {
+			return fmt.Errorf("not overwriting %s", cfgPath)
+		}
+
+		fmt.Print("Verifying credentials...")
+		if err := storage.NewClient(&cfg.Storage, cfg.Network).Ping(cmd.Context()); err != nil {
+			fmt.Println(" failed")
+			return fmt.Errorf("credential check failed: %w", err)
+		}
+		fmt.Println(" ok")
+
+		if err := config.Write(cfg, cfgPath); err != nil {
+			return err
+		}
+		fmt.Printf("Config written to %s\n", cfgPath)
+
+		// Reload so paths are expanded and defaults applied
+		cfg, err = config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if _, err := os.Stat(cfg.Sync.EmulationPath); os.IsNotExist(err) {
+			if !confirm(reader, fmt.Sprintf("Create emulation path %s?", cfg.Sync.EmulationPath)) {
+				return fmt.Errorf("emulation path %s does not exist", cfg.Sync.EmulationPath)
+			}
+			if err := os.MkdirAll(cfg.Sync.EmulationPath, 0o755); err != nil {
+				return fmt.Errorf("creating emulation path: %w", err)
+			}
+		}
+		if err := cfg.ValidateEmulationPath(); err != nil {
+			return err
+		}
+
+		fmt.Println("\nRunning first sync...")
+		client, err := newSyncClient(cfg)
+		if err != nil {
+			return err
+		}
+		workers := cfg.Sync.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		opts, err := syncOptions(cfg, workers)
+		if err != nil {
+			return err
+		}
+		result, err := intsync.Run(cmd.Context(), client, cfg, opts)
+		saveLastSync("", result, err)
+		if err != nil {
+			return fmt.Errorf("first sync: %w", err)
+		}
+		recordUsage("", 0, result.Bytes)
+
+		installed := "skipped (--no-install)"
+		if !runOnceNoInstall {
+			fmt.Println("\nInstalling schedule and shortcuts...")
+			if err := installSchedule(); err != nil {
+				installed = "failed: " + err.Error()
+			} else {
+				installed = "installed"
+			}
+		}
+
+		fmt.Print(runOnceReport(cfgPath, cfg.Sync.EmulationPath, result, installed, time.Since(start)))
+		if len(result.Errors) > 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &ExitError{Code: exitSyncFileErrors}
+		}
+		return nil
+	},
+}
+
+// confirm asks a yes/no question, defaulting to no. --yes answers yes.
+func confirm(reader *bufio.Reader, question string) bool {
+	if runOnceYes {
+		return true
+	}
+	answer := strings.ToLower(prompt(reader, question+" [y/N]: "))
+	return answer == "y" || answer == "yes"
+}
+
+// runOnceReport summarizes a bootstrap run.
+func runOnceReport(cfgPath, emuPath string, result *intsync.Result, schedule string, elapsed time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n=== emu-sync run-once report ===\n")
+	fmt.Fprintf(&b, "Config:         %s\n", cfgPath)
+	fmt.Fprintf(&b, "Emulation path: %s\n", emuPath)
+	fmt.Fprintf(&b, "Downloaded:     %d files (%s)\n", len(result.Downloaded), units.FormatSize(result.Bytes))
+	if len(result.Deferred) > 0 {
+		fmt.Fprintf(&b, "Deferred:       %d files (next sync)\n", len(result.Deferred))
+	}
+	fmt.Fprintf(&b, "Errors:         %d\n", len(result.Errors))
+	for _, err := range result.Errors {
+		fmt.Fprintf(&b, "  - %v\n", err)
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(&b, "Warning:        %s\n", w)
+	}
+	fmt.Fprintf(&b, "Schedule:       %s\n", schedule)
+	fmt.Fprintf(&b, "Elapsed:        %s\n", elapsed.Round(time.Second))
+	return b.String()
+}
+
+func init() {
+	runOnceCmd.Flags().StringVar(&runOnceToken, "token", "", "setup token (prompts if not given)")
+	runOnceCmd.Flags().StringVar(&runOnceEmulationPath, "emulation-path", "", "emulation path (overrides the token's)")
+	runOnceCmd.Flags().BoolVarP(&runOnceYes, "yes", "y", false, "don't ask; overwrite config and create the emulation path")
+	runOnceCmd.Flags().BoolVar(&runOnceNoInstall, "no-install", false, "skip installing the sync schedule and shortcuts")
+	runOnceCmd.Flags().IntVar(&runOnceWorkers, "workers", 4, "number of parallel downloads for the first sync (saved to config)")
+	runOnceCmd.MarkFlagDirname("emulation-path")
+	rootCmd.AddCommand(runOnceCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+)
+
+func TestRunOnceReport(t *testing.T) {
+	result := &intsync.Result{
+		Downloaded: []string{"roms/snes/A.sfc", "roms/snes/B.sfc"},
+		Bytes:      3 << 20,
+		Errors:     []error{errors.New("roms/gba/C.gba: timeout")},
+	}
+	got := runOnceReport("/home/deck/.config/emu-sync/config.toml", "/home/deck/Emulation", result, "installed", 95*time.Second)
+
+	for _, want := range []string{
+		"Emulation path: /home/deck/Emulation",
+		"Downloaded:     2 files (3.0 MiB)",
+		"Errors:         1",
+		"  - roms/gba/C.gba: timeout",
+		"Schedule:       installed",
+		"Elapsed:        1m35s",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	runOnceYes = false
+	defer func() { runOnceYes = false }()
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+	}
+	for _, tt := range tests {
+		if got := confirm(bufio.NewReader(strings.NewReader(tt.input)), "Continue?"); got != tt.want {
+			t.Errorf("confirm(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	runOnceYes = true
+	if !confirm(bufio.NewReader(strings.NewReader("n\n")), "Continue?") {
+		t.Error("--yes should answer yes without reading input")
+	}
+}
@@ -2,22 +2,38 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
 	"github.com/jacobfgrant/emu-sync/internal/token"
 	"github.com/spf13/cobra"
 )
 
+var setupCode string
+var setupNoGuide bool
+
 var setupCmd = &cobra.Command{
-	Use:   "setup [token]",
+	Use:   "setup [token|invite-link]",
 	Short: "Configure emu-sync from a setup token",
 	Long: `Decodes a setup token (from emu-sync generate-token) and writes
 the config file. If no token is provided as an argument, prompts
-for it interactively (keeping it out of shell history).`,
+for it interactively (keeping it out of shell history).
+
+Also accepts an invite link from 'emu-sync generate-token --publish',
+prompting for its 8-character code unless --code is given. The invite
+is deleted from the bucket once redeemed.
+
+When run from a terminal, setup then offers to pick what to sync (in the
+browser or the terminal) and to run the first sync after showing its
+size. Use --no-guide to stop after writing the config.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var tokenStr string
@@ -33,6 +49,22 @@ for it interactively (keeping it out of shell history).`,
 			}
 		}
 
+		var inviteKey string
+		if isInviteLink(tokenStr) {
+			code := setupCode
+			if code == "" {
+				reader := bufio.NewReader(os.Stdin)
+				fmt.Print("Invite code: ")
+				text, _ := reader.ReadString('\n')
+				code = strings.TrimSpace(text)
+			}
+			var err error
+			tokenStr, inviteKey, err = redeemInvite(cmd.Context(), http.DefaultClient, tokenStr, code)
+			if err != nil {
+				return err
+			}
+		}
+
 		data, err := token.Decode(tokenStr)
 		if err != nil {
 			return err
@@ -53,13 +85,21 @@ for it interactively (keeping it out of shell history).`,
 		}
 
 		fmt.Print("Verifying credentials...")
-		client := storage.NewClient(&cfg.Storage)
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
 		if err := client.Ping(cmd.Context()); err != nil {
 			fmt.Println(" failed")
 			return fmt.Errorf("credential check failed: %w", err)
 		}
 		fmt.Println(" ok")
 
+		// Best effort: a read-only key can't delete the invite, in which
+		// case it lasts until its link expires.
+		if inviteKey != "" && !cfg.Storage.ReadOnly {
+			if err := client.DeleteObject(cmd.Context(), inviteKey); err != nil {
+				fmt.Println("Note: could not delete the invite; it expires on its own.")
+			}
+		}
+
 		cfgPath := cfgFile
 		if cfgPath == "" {
 			cfgPath = config.DefaultConfigPath()
@@ -70,10 +110,123 @@ for it interactively (keeping it out of shell history).`,
 		}
 
 		fmt.Printf("Config written to %s\n", cfgPath)
-		return nil
+
+		if setupNoGuide || !isTerminal(os.Stdin) {
+			return nil
+		}
+		return guideFirstSync(cmd, bufio.NewReader(os.Stdin), cfgPath)
 	},
 }
 
+// guideFirstSync walks a new user through choosing what to sync and
+// running the first sync, so setup is the only command they need.
+func guideFirstSync(cmd *cobra.Command, reader *bufio.Reader, cfgPath string) error {
+	fmt.Println()
+	fmt.Println("Next, choose which systems and games to sync.")
+	switch strings.ToLower(prompt(reader, "Open the selection page in your [b]rowser, use the [t]erminal, or [s]kip and sync everything? [b]: ")) {
+	case "", "b", "browser":
+		webCmd.SetContext(cmd.Context())
+		if err := webCmd.RunE(webCmd, nil); err != nil {
+			return err
+		}
+	case "t", "terminal":
+		chooseCmd.SetContext(cmd.Context())
+		if err := chooseCmd.RunE(chooseCmd, nil); err != nil {
+			return err
+		}
+	}
+
+	// Reload to pick up the selection and apply defaults
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := cfg.ValidateEmulationPath(); err != nil {
+		fmt.Printf("\nSkipping the first sync: %v\n", err)
+		return nil
+	}
+
+	client, err := newSyncClient(cfg)
+	if err != nil {
+		return err
+	}
+	_, filtered, diff, err := loadPending(cmd.Context(), client, cfg)
+	if err != nil {
+		return err
+	}
+	n, size := pendingDownload(filtered, diff)
+	if n == 0 {
+		fmt.Println("\nEverything selected is already on this device.")
+		return nil
+	}
+
+	fmt.Printf("\nThe first sync will download %d files (%s).\n", n, formatSize(size))
+	answer := strings.ToLower(prompt(reader, "Start it now? [Y/n]: "))
+	if answer != "" && answer != "y" && answer != "yes" {
+		fmt.Println("Run 'emu-sync sync' whenever you're ready.")
+		return nil
+	}
+
+	workers := cfg.Sync.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	opts, err := syncOptions(cfg, workers)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Syncing...")
+	result, err := intsync.Run(cmd.Context(), client, cfg, opts)
+	saveLastSync("", result, err)
+	if err != nil {
+		return fmt.Errorf("first sync: %w", err)
+	}
+	recordUsage("", 0, result.Bytes)
+	fmt.Print(result.Summary())
+	fmt.Println("\nTo keep this device up to date automatically, run 'emu-sync install'.")
+	return nil
+}
+
+// isInviteLink reports whether arg is an invite link rather than a token.
+func isInviteLink(arg string) bool {
+	return strings.HasPrefix(arg, "https://") || strings.HasPrefix(arg, "http://")
+}
+
+// redeemInvite downloads the invite at link and decrypts it with code,
+// returning the setup token and the invite's object key.
+func redeemInvite(ctx context.Context, hc *http.Client, link, code string) (tokenStr, key string, err error) {
+	if code == "" {
+		return "", "", fmt.Errorf("no invite code provided")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid invite link: %w", err)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("downloading invite: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		return "", "", fmt.Errorf("invite link has expired or was already used; ask for a new one")
+	case resp.StatusCode != http.StatusOK:
+		return "", "", fmt.Errorf("downloading invite: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", "", fmt.Errorf("downloading invite: %w", err)
+	}
+
+	return token.OpenInvite(data, code, time.Now())
+}
+
 func init() {
+	setupCmd.Flags().BoolVar(&setupNoGuide, "no-guide", false, "only write the config; don't offer to choose files and run the first sync")
+	setupCmd.Flags().StringVar(&setupCode, "code", "", "invite code, when setting up from an invite link")
 	rootCmd.AddCommand(setupCmd)
 }
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/token"
+)
+
+func TestRedeemInvite(t *testing.T) {
+	sealed, err := token.SealInvite("tok", "ABCD-EFGH", "emu-sync-invites/k", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SealInvite: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/invite" {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		w.Write(sealed)
+	}))
+	defer srv.Close()
+
+	if !isInviteLink(srv.URL + "/invite") {
+		t.Fatal("isInviteLink = false for an http URL")
+	}
+
+	tok, key, err := redeemInvite(context.Background(), srv.Client(), srv.URL+"/invite", "abcd-efgh")
+	if err != nil {
+		t.Fatalf("redeemInvite: %v", err)
+	}
+	if tok != "tok" || key != "emu-sync-invites/k" {
+		t.Errorf("got token %q key %q", tok, key)
+	}
+
+	_, _, err = redeemInvite(context.Background(), srv.Client(), srv.URL+"/gone", "ABCD-EFGH")
+	if err == nil || !strings.Contains(err.Error(), "expired or was already used") {
+		t.Errorf("err = %v, want expired-or-used error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var shareExpires time.Duration
+
+var shareCmd = &cobra.Command{
+	Use:   "share key",
+	Short: "Print a temporary download link for one file",
+	Long: `Prints a presigned URL that downloads a single object, e.g. to hand
+one game to a friend without giving them bucket credentials. Anyone
+with the link can download the file until it expires (at most 7 days).
+The link contains your key ID but not the secret key.
+
+  emu-sync share roms/snes/Game.sfc --expires 24h`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+
+		if shareExpires <= 0 || shareExpires > storage.MaxPresignTTL {
+			return fmt.Errorf("--expires must be positive and at most %s", storage.MaxPresignTTL)
+		}
+
+		key := strings.TrimPrefix(args[0], "/")
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+		link, err := shareLink(cmd.Context(), client, key, shareExpires)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(link)
+		fmt.Fprintf(os.Stderr, "The link expires %s.\n", time.Now().Add(shareExpires).Format("2006-01-02 15:04"))
+		return nil
+	},
+}
+
+// shareLink presigns a download of key, checking first that it exists
+// so a mistyped key fails here rather than when the link is opened.
+func shareLink(ctx context.Context, client storage.Backend, key string, ttl time.Duration) (string, error) {
+	if _, err := client.HeadObject(ctx, key); err != nil {
+		return "", notFoundHint(err, key)
+	}
+	return client.PresignGet(ctx, key, ttl)
+}
+
+func init() {
+	shareCmd.Flags().DurationVar(&shareExpires, "expires", 24*time.Hour, "how long the link stays valid (max 168h)")
+	rootCmd.AddCommand(shareCmd)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestShareLink(t *testing.T) {
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/snes/Game.sfc"] = []byte("rom")
+
+	link, err := shareLink(context.Background(), mock, "roms/snes/Game.sfc", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("shareLink: %v", err)
+	}
+	if !strings.Contains(link, "roms/snes/Game.sfc") || !strings.Contains(link, "expires=86400") {
+		t.Errorf("link = %q, want the key with a 24h expiry", link)
+	}
+
+	_, err = shareLink(context.Background(), mock, "roms/snes/Nope.sfc", time.Hour)
+	if !errors.Is(err, storage.ErrNotFound) || !strings.Contains(err.Error(), "emu-sync ls") {
+		t.Errorf("missing key error = %v, want ErrNotFound with a hint", err)
+	}
+}
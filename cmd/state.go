@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var stateCleanAll bool
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Show or clean the files emu-sync keeps on this device",
+	Long: `emu-sync keeps its state (the local manifest, the last sync's result,
+bandwidth stats, and caches) in one directory: --state-dir, else
+EMU_SYNC_STATE_DIR, else [paths] state_dir from the config, else
+~/.local/share/emu-sync. Back it up with the config file to move a
+device's setup; give each config its own state_dir to keep profiles
+apart. The upload caches are named for the library (endpoint, bucket,
+and prefix), and the local manifest, scan cache, and lock for the
+library and emulation path, so configs for different libraries can share a state
+directory.
+
+  emu-sync state show
+  emu-sync state clean          # remove caches
+  emu-sync state clean --all    # start over: next sync re-checks everything`,
+}
+
+var stateShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "List the state directory and its files",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadStateConfig()
+		fmt.Printf("State directory: %s\n\n", config.StateDir())
+		for _, f := range config.StateFiles {
+			path := f.Path()
+			size := "-"
+			if info, err := os.Stat(path); err == nil {
+				size = formatSize(info.Size())
+			}
+			about := f.About
+			if f.Cache {
+				about += " (cache)"
+			}
+			fmt.Printf("  %-32s %10s  %s\n", filepath.Base(path), size, about)
+		}
+		return nil
+	},
+}
+
+var stateCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove caches from the state directory",
+	Long: `Removes the caches in the state directory; they are rebuilt as needed,
+at the cost of re-hashing or re-listing files on the next run. With
+--all, removes every state file, so the next sync treats this device
+as new.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loadStateConfig()
+		removed, err := cleanState(stateCleanAll)
+		if !output.Quiet() {
+			for _, name := range removed {
+				fmt.Printf("Removed %s\n", name)
+			}
+			if len(removed) == 0 && err == nil {
+				fmt.Println("Nothing to remove.")
+			}
+		}
+		return err
+	},
+}
+
+// cleanState removes the current library's caches, or with all every
+// state file but the lock, and returns the names of the files it
+// removed.
+func cleanState(all bool) ([]string, error) {
+	var removed []string
+	for _, f := range config.StateFiles {
+		// Removing the lock file would let a second sync start
+		// alongside one that's running.
+		if (!f.Cache && !all) || f.Name == config.LockFile {
+			continue
+		}
+		path := f.Path()
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, filepath.Base(path))
+	}
+	return removed, nil
+}
+
+// loadStateConfig loads the config, if there is one, for its
+// paths.state_dir. The state commands work without a valid config.
+func loadStateConfig() {
+	cfgPath := cfgFile
+	if cfgPath == "" {
+		cfgPath = config.DefaultConfigPath()
+	}
+	config.Load(cfgPath)
+}
+
+func init() {
+	addOutputFlags(stateCleanCmd)
+	stateCleanCmd.Flags().BoolVar(&stateCleanAll, "all", false, "also remove the local manifest, last sync result, and usage stats")
+	stateCmd.AddCommand(stateShowCmd, stateCleanCmd)
+	rootCmd.AddCommand(stateCmd)
+}
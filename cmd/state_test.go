@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+)
+
+func TestCleanState(t *testing.T) {
+	dir := t.TempDir()
+	config.SetStateDir(dir)
+	defer config.SetStateDir("")
+
+	paths := make(map[string]string)
+	for _, f := range config.StateFiles {
+		paths[f.Name] = f.Path()
+	}
+	for _, path := range []string{paths["local-manifest.json"], paths["upload-cache.json"], paths["scan-cache.json"], paths[config.LockFile], filepath.Join(dir, "notes.txt")} {
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := cleanState(false)
+	if err != nil {
+		t.Fatalf("cleanState: %v", err)
+	}
+	want := []string{filepath.Base(paths["upload-cache.json"]), filepath.Base(paths["scan-cache.json"])}
+	if !slices.Equal(removed, want) {
+		t.Errorf("removed %v, want only the caches %v", removed, want)
+	}
+
+	removed, err = cleanState(true)
+	if err != nil {
+		t.Fatalf("cleanState --all: %v", err)
+	}
+	if want := []string{filepath.Base(paths["local-manifest.json"])}; !slices.Equal(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	for _, keep := range []string{paths[config.LockFile], filepath.Join(dir, "notes.txt")} {
+		if _, err := os.Stat(keep); err != nil {
+			t.Errorf("%s should be kept: %v", keep, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/units"
+	"github.com/jacobfgrant/emu-sync/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show bandwidth usage by month",
+	Long: `Shows how many bytes emu-sync has uploaded and downloaded on this
+device, totaled by calendar month. Useful for keeping an eye on
+provider egress charges. If [storage.cost] pricing is configured,
+an estimated spend per month is shown as well.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Pricing and paths.state_dir are optional; stats work without a
+		// valid config.
+		var cost config.CostConfig
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+		if cfg, err := config.Load(cfgPath); err == nil {
+			cost = cfg.Storage.Cost
+		}
+
+		stats, err := usage.Load(config.DefaultUsagePath())
+		if err != nil {
+			return err
+		}
+
+		keys := stats.Keys()
+		if len(keys) == 0 {
+			fmt.Println("No transfers recorded yet.")
+			return nil
+		}
+
+		fmt.Printf("%-9s %12s %12s %6s", "Month", "Downloaded", "Uploaded", "Runs")
+		if cost.Enabled() {
+			fmt.Printf(" %10s", "Est. cost")
+		}
+		fmt.Println()
+		for _, k := range keys {
+			m := stats.Get(k)
+			fmt.Printf("%-9s %12s %12s %6d", k, formatSize(m.Downloaded), formatSize(m.Uploaded), m.Runs)
+			if cost.Enabled() {
+				fmt.Printf(" %10s", units.FormatCost(cost.Estimate(m.Uploaded, m.Downloaded)))
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+// recordUsage adds one run's transfer totals to the monthly usage stats.
+// Failures are logged, not returned — accounting must never fail a sync.
+func recordUsage(path string, uploaded, downloaded int64) {
+	if path == "" {
+		path = config.DefaultUsagePath()
+	}
+	if err := usage.Record(path, uploaded, downloaded); err != nil {
+		log.Printf("warning: recording bandwidth usage: %v", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+}
@@ -1,18 +1,54 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/output"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+	"github.com/jacobfgrant/emu-sync/internal/units"
 	"github.com/spf13/cobra"
 )
 
+var statusDeep bool
+var statusSample int
+var statusPing bool
+var statusSizes bool
+var statusWatch bool
+var statusInterval time.Duration
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show differences between remote and local state",
-	Long:  `Downloads the remote manifest and compares it against the local manifest to show what would change on the next sync.`,
+	Long: `Downloads the remote manifest and compares it against the local manifest to show what would change on the next sync.
+It also warns about sync_dirs and sync_exclude entries that match nothing
+in the library or are redundant, since stale selections quietly shrink
+what gets synced.
+
+Use --deep to also cross-check manifest entries against the bucket
+itself and flag objects that are missing or have a different size.
+Use --sample N with --deep to check only N random entries.
+
+Use --ping to measure request latency and download throughput, which
+helps tell a distant bucket region apart from a slow network link.
+
+Use --sizes to show the size of each pending file: from the manifest for
+downloads, and on disk for deletions.
+
+Use --watch to keep running and report each time the library changes,
+e.g. while someone is uploading from another machine. Polling uses HEAD
+requests; the manifest is downloaded only when it changed.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath := cfgFile
 		if cfgPath == "" {
@@ -24,62 +60,315 @@ var statusCmd = &cobra.Command{
 			return fmt.Errorf("loading config: %w", err)
 		}
 
-		client := storage.NewClient(&cfg.Storage)
-
-		remoteData, err := client.DownloadManifest(cmd.Context())
-		if err != nil {
-			return fmt.Errorf("downloading remote manifest: %w", err)
+		if statusWatch && statusInterval < time.Second {
+			return fmt.Errorf("--interval must be at least 1s")
 		}
 
-		remote, err := manifest.ParseJSON(remoteData)
+		printUpdateNotice := startUpdateNotice(cmd, cfg)
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+
+		remote, filtered, diff, err := loadPending(cmd.Context(), client, cfg)
 		if err != nil {
-			return fmt.Errorf("parsing remote manifest: %w", err)
+			return err
+		}
+		if !output.Quiet() {
+			sizes := diffSizes(cfg.Sync.EmulationPath, filtered, diff)
+			if statusSizes {
+				printStatusDiff(diff, sizes)
+			} else {
+				printStatusDiff(diff, nil)
+			}
+			if line := transferEstimate(cfg, remote, diff, sizes); line != "" {
+				fmt.Printf("\n%s\n", line)
+			}
+			if overlays, _ := intsync.Overlays(cfg); len(overlays) > 0 {
+				fmt.Printf("\n%s in %s replace their library versions and are not synced.\n", pluralFiles(len(overlays)), cfg.Sync.OverlayDir)
+			}
+		}
+		if problems := cfg.CheckSelections(slices.Collect(maps.Keys(remote.Files))); len(problems) > 0 {
+			fmt.Println("\nConfig warnings:")
+			for _, p := range problems {
+				fmt.Printf("  %s\n", p)
+			}
 		}
 
-		localPath := config.DefaultLocalManifestPath()
-		local, err := manifest.LoadJSON(localPath)
-		if err != nil {
-			local = manifest.New()
+		if statusPing {
+			if !output.Quiet() {
+				fmt.Println()
+				fmt.Println("Checking connection...")
+			}
+			h, err := storage.CheckHealth(cmd.Context(), client, healthSampleKey(remote))
+			if err != nil {
+				return fmt.Errorf("checking connection: %w", err)
+			}
+			printHealth(h)
 		}
 
-		// Filter to configured sync dirs / exclude
-		filtered := manifest.New()
-		for key, entry := range remote.Files {
-			if cfg.ShouldSync(key) {
-				filtered.Files[key] = entry
+		if statusDeep {
+			workers := cfg.Sync.Workers
+			if workers < 1 {
+				workers = 1
+			}
+			if !output.Quiet() {
+				fmt.Println()
+				fmt.Println("Checking bucket...")
+			}
+			drift := intsync.CheckDrift(cmd.Context(), client, filtered, statusSample, workers)
+			if !output.Quiet() || !drift.Clean() {
+				fmt.Print(drift.Summary())
 			}
 		}
 
-		diff := manifest.Diff(filtered, local)
+		printUpdateNotice()
+
+		if statusWatch {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return watchLibrary(ctx, client, cfg, remote, statusInterval)
+		}
+		return nil
+	},
+}
 
-		if len(diff.Added) == 0 && len(diff.Modified) == 0 && len(diff.Deleted) == 0 {
-			fmt.Println("Up to date.")
+// watchLibrary checks the remote manifest's version every interval and
+// prints what changed whenever it does, until ctx is canceled.
+func watchLibrary(ctx context.Context, client storage.Backend, cfg *config.Config, remote *manifest.Manifest, interval time.Duration) error {
+	version, err := storage.ManifestVersion(ctx, client)
+	if err != nil {
+		return fmt.Errorf("checking remote manifest: %w", err)
+	}
+	if !output.Quiet() {
+		fmt.Printf("\nWatching for library changes every %s (Ctrl-C to stop)\n", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
 			return nil
+		case <-ticker.C:
 		}
 
-		if len(diff.Added) > 0 {
-			fmt.Printf("New files (%d):\n", len(diff.Added))
-			for _, f := range diff.Added {
-				fmt.Printf("  + %s\n", f)
+		v, err := storage.ManifestVersion(ctx, client)
+		if err == nil && v != version {
+			var next, filtered *manifest.Manifest
+			var diff manifest.DiffResult
+			next, filtered, diff, err = loadPending(ctx, client, cfg)
+			if err == nil {
+				version = v
+				fmt.Printf("\n[%s] Library changed\n", time.Now().Format("15:04:05"))
+				printLibraryChanges(manifest.Diff(next, remote))
+				remote = next
+				if line := transferEstimate(cfg, next, diff, diffSizes(cfg.Sync.EmulationPath, filtered, diff)); line != "" {
+					fmt.Println(line)
+				} else {
+					fmt.Println("This device is up to date.")
+				}
 			}
 		}
-		if len(diff.Modified) > 0 {
-			fmt.Printf("Modified files (%d):\n", len(diff.Modified))
-			for _, f := range diff.Modified {
-				fmt.Printf("  ~ %s\n", f)
-			}
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("[%s] Check failed: %v\n", time.Now().Format("15:04:05"), err)
 		}
-		if len(diff.Deleted) > 0 {
-			fmt.Printf("Deleted files (%d):\n", len(diff.Deleted))
-			for _, f := range diff.Deleted {
-				fmt.Printf("  - %s\n", f)
-			}
+	}
+}
+
+// printLibraryChanges prints a diff between two versions of the remote
+// manifest.
+func printLibraryChanges(diff manifest.DiffResult) {
+	if len(diff.Added) == 0 && len(diff.Modified) == 0 && len(diff.Deleted) == 0 {
+		fmt.Println("Manifest rewritten with no file changes.")
+		return
+	}
+	printStatusDiff(diff, nil)
+}
+
+// loadPending downloads the remote manifest and diffs the part selected
+// by sync_dirs and sync_exclude against the local manifest.
+func loadPending(ctx context.Context, client storage.Backend, cfg *config.Config) (remote, filtered *manifest.Manifest, diff manifest.DiffResult, err error) {
+	remoteData, err := client.DownloadManifest(ctx)
+	if err != nil {
+		return nil, nil, diff, fmt.Errorf("downloading remote manifest: %w", err)
+	}
+
+	remote, err = manifest.ParseJSON(remoteData)
+	if err != nil {
+		return nil, nil, diff, fmt.Errorf("parsing remote manifest: %w", err)
+	}
+	cfg.Policy.Hide(remote)
+
+	local, err := manifest.LoadJSON(config.DefaultLocalManifestPath())
+	if err != nil {
+		local = manifest.New()
+	}
+
+	filtered = manifest.New()
+	for key, entry := range remote.Files {
+		if cfg.ShouldSync(key) {
+			filtered.Files[key] = entry
 		}
+	}
 
-		return nil
-	},
+	// Files replaced by an overlay are never synced, so they aren't pending
+	overlays, err := intsync.Overlays(cfg)
+	if err != nil {
+		return nil, nil, diff, err
+	}
+	return remote, filtered, manifest.Diff(intsync.Unshadowed(filtered, overlays), intsync.Unshadowed(local, overlays)), nil
+}
+
+// pendingDownload returns the number and total size of files the next
+// sync will download.
+func pendingDownload(filtered *manifest.Manifest, diff manifest.DiffResult) (int, int64) {
+	var size int64
+	for _, key := range append(diff.Added, diff.Modified...) {
+		size += filtered.Files[key].Size
+	}
+	return len(diff.Added) + len(diff.Modified), size
+}
+
+// diffSizes returns the size of each file in diff: the remote size for
+// files to download and the on-disk size for files to delete. Files
+// already gone from disk are omitted.
+func diffSizes(emuPath string, filtered *manifest.Manifest, diff manifest.DiffResult) map[string]int64 {
+	sizes := make(map[string]int64, len(diff.Added)+len(diff.Modified)+len(diff.Deleted))
+	for _, key := range append(diff.Added, diff.Modified...) {
+		sizes[key] = filtered.Files[key].Size
+	}
+	for _, key := range diff.Deleted {
+		if fi, err := os.Stat(filepath.Join(emuPath, filepath.FromSlash(key))); err == nil {
+			sizes[key] = fi.Size()
+		}
+	}
+	return sizes
+}
+
+// transferEstimate summarizes what the next sync will transfer and free,
+// e.g. "Next sync will download 12 files (4.2 GB) and delete 3 files
+// (312 MB)". Returns "" when there is nothing to do. remote tells
+// deselected files apart from ones removed from the library, since each
+// has its own delete setting.
+func transferEstimate(cfg *config.Config, remote *manifest.Manifest, diff manifest.DiffResult, sizes map[string]int64) string {
+	var down, del, deselected, removed int64
+	var nDel, nDeselected, nRemoved int
+	for _, key := range append(diff.Added, diff.Modified...) {
+		down += sizes[key]
+	}
+	for _, key := range diff.Deleted {
+		_, inLibrary := remote.Files[key]
+		switch {
+		case cfg.Sync.DeletesFile(inLibrary):
+			del += sizes[key]
+			nDel++
+		case inLibrary:
+			deselected += sizes[key]
+			nDeselected++
+		default:
+			removed += sizes[key]
+			nRemoved++
+		}
+	}
+
+	var parts []string
+	if n := len(diff.Added) + len(diff.Modified); n > 0 {
+		part := fmt.Sprintf("download %s (%s", pluralFiles(n), formatSize(down))
+		if cfg.Storage.Cost.Enabled() {
+			part += ", est. " + units.FormatCost(cfg.Storage.Cost.Estimate(0, down))
+		}
+		parts = append(parts, part+")")
+	}
+	if nDel > 0 {
+		parts = append(parts, fmt.Sprintf("delete %s (%s)", pluralFiles(nDel), formatSize(del)))
+	}
+	if nDeselected > 0 {
+		parts = append(parts, fmt.Sprintf("keep %s (%s) no longer selected, since delete is off for them", pluralFiles(nDeselected), formatSize(deselected)))
+	}
+	if nRemoved > 0 {
+		parts = append(parts, fmt.Sprintf("keep %s (%s) removed from the bucket, since delete is off", pluralFiles(nRemoved), formatSize(removed)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Next sync will " + strings.Join(parts, " and ") + "."
+}
+
+// pluralFiles returns "1 file" or "N files".
+func pluralFiles(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+// printStatusDiff prints the pending changes from a manifest diff, with
+// each file's size if sizes is non-nil.
+func printStatusDiff(diff manifest.DiffResult, sizes map[string]int64) {
+	if len(diff.Added) == 0 && len(diff.Modified) == 0 && len(diff.Deleted) == 0 {
+		fmt.Println("Up to date.")
+		return
+	}
+
+	if len(diff.Added) > 0 {
+		fmt.Printf("New files (%d):\n", len(diff.Added))
+		for _, f := range diff.Added {
+			fmt.Printf("  %s %s%s\n", output.Marker("+"), f, sizeSuffix(sizes, f))
+		}
+	}
+	if len(diff.Modified) > 0 {
+		fmt.Printf("Modified files (%d):\n", len(diff.Modified))
+		for _, f := range diff.Modified {
+			fmt.Printf("  %s %s%s\n", output.Marker("~"), f, sizeSuffix(sizes, f))
+		}
+	}
+	if len(diff.Deleted) > 0 {
+		fmt.Printf("Deleted files (%d):\n", len(diff.Deleted))
+		for _, f := range diff.Deleted {
+			fmt.Printf("  %s %s%s\n", output.Marker("-"), f, sizeSuffix(sizes, f))
+		}
+	}
+}
+
+// sizeSuffix formats key's size for printStatusDiff, or "" without sizes.
+func sizeSuffix(sizes map[string]int64, key string) string {
+	if sizes == nil {
+		return ""
+	}
+	size, ok := sizes[key]
+	if !ok {
+		return "  (not on disk)"
+	}
+	return "  (" + formatSize(size) + ")"
+}
+
+// healthSampleKey picks the largest file of at most 8 MB for measuring
+// throughput, so the sample is big enough to time but quick to fetch.
+// Returns "" (use the manifest) if there's no such file.
+func healthSampleKey(m *manifest.Manifest) string {
+	const maxSample = 8 * 1024 * 1024
+	best, bestSize := "", int64(0)
+	for key, entry := range m.Files {
+		if entry.Size <= maxSample && (entry.Size > bestSize || (entry.Size == bestSize && key < best)) {
+			best, bestSize = key, entry.Size
+		}
+	}
+	return best
+}
+
+func printHealth(h *storage.Health) {
+	fmt.Printf("  Latency:     %d ms\n", h.FirstByte.Milliseconds())
+	fmt.Printf("  Throughput:  %s/s (%s in %d ms)\n", formatSize(int64(h.Throughput)), formatSize(h.Bytes), h.Transfer.Milliseconds())
+	if hint := h.Hint(); hint != "" {
+		fmt.Printf("  %s\n", hint)
+	}
 }
 
 func init() {
+	addOutputFlags(statusCmd)
+	statusCmd.Flags().BoolVar(&statusDeep, "deep", false, "cross-check manifest entries against bucket objects")
+	statusCmd.Flags().IntVar(&statusSample, "sample", 0, "with --deep, check only N random entries (0 = all)")
+	statusCmd.Flags().BoolVar(&statusSizes, "sizes", false, "show the size of each pending file")
+	statusCmd.Flags().BoolVar(&statusWatch, "watch", false, "keep running and report when the library changes")
+	statusCmd.Flags().DurationVar(&statusInterval, "interval", 30*time.Second, "with --watch, how often to check for changes")
+	statusCmd.Flags().BoolVar(&statusPing, "ping", false, "measure connection latency and throughput")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+func TestTransferEstimate(t *testing.T) {
+	emuPath := t.TempDir()
+	os.MkdirAll(filepath.Join(emuPath, "roms", "gba"), 0o755)
+	os.WriteFile(filepath.Join(emuPath, "roms", "gba", "Old.gba"), make([]byte, 2048), 0o644)
+
+	filtered := manifest.New()
+	filtered.Files["roms/snes/A.sfc"] = manifest.FileEntry{Size: 3 << 20}
+	filtered.Files["roms/snes/B.sfc"] = manifest.FileEntry{Size: 1 << 20}
+	diff := manifest.DiffResult{
+		Added:    []string{"roms/snes/A.sfc"},
+		Modified: []string{"roms/snes/B.sfc"},
+		Deleted:  []string{"roms/gba/Old.gba", "roms/gba/Gone.gba"},
+	}
+
+	sizes := diffSizes(emuPath, filtered, diff)
+	if sizes["roms/gba/Old.gba"] != 2048 {
+		t.Errorf("deleted size = %d, want on-disk 2048", sizes["roms/gba/Old.gba"])
+	}
+	if _, ok := sizes["roms/gba/Gone.gba"]; ok {
+		t.Error("file missing from disk should have no size")
+	}
+	if got := sizeSuffix(sizes, "roms/gba/Gone.gba"); got != "  (not on disk)" {
+		t.Errorf("sizeSuffix = %q", got)
+	}
+
+	cfg := &config.Config{Sync: config.SyncConfig{Delete: true}}
+	want := "Next sync will download 2 files (4.0 MiB) and delete 2 files (2.0 KiB)."
+	if got := transferEstimate(cfg, filtered, diff, sizes); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	cfg.Sync.Delete = false
+	want = "Next sync will download 2 files (4.0 MiB) and keep 2 files (2.0 KiB) removed from the bucket, since delete is off."
+	if got := transferEstimate(cfg, filtered, diff, sizes); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// Old.gba is still in the library, so it was deselected; keep those
+	// but delete what left the library.
+	remote := manifest.New()
+	remote.Files["roms/gba/Old.gba"] = manifest.FileEntry{Size: 2048}
+	on := true
+	cfg.Sync.DeleteRemoved = &on
+	want = "Next sync will download 2 files (4.0 MiB) and delete 1 file (0 B) and keep 1 file (2.0 KiB) no longer selected, since delete is off for them."
+	if got := transferEstimate(cfg, remote, diff, sizes); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	if got := transferEstimate(cfg, filtered, manifest.DiffResult{}, nil); got != "" {
+		t.Errorf("nothing to do: got %q, want empty", got)
+	}
+}
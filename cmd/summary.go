@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// summaryFormats lists the values --summary-format accepts: table, the
+// full summary; short, a single line for notifications and mail
+// subjects; json, one object for scripts and CI logs.
+var summaryFormats = []string{"table", "short", "json"}
+
+// addSummaryFormatFlag registers --summary-format on cmd, storing the
+// chosen format in p.
+func addSummaryFormatFlag(cmd *cobra.Command, p *string) {
+	cmd.Flags().StringVar(p, "summary-format", "table", "how to print the summary: table, short (one line), or json")
+}
+
+// checkSummaryFormat rejects a --summary-format value other than those
+// in summaryFormats.
+func checkSummaryFormat(format string) error {
+	if !slices.Contains(summaryFormats, format) {
+		return fmt.Errorf("--summary-format must be one of %s", strings.Join(summaryFormats, ", "))
+	}
+	return nil
+}
+
+// summarizer is a run's result as the summary formats print it.
+type summarizer interface {
+	Summary() string
+	Short() string
+}
+
+// printSummary prints s in format. Under --quiet, table prints only the
+// problems of a result that reports them separately. json encodes v, or
+// s itself if v is nil.
+func printSummary(format string, s summarizer, v any) {
+	switch format {
+	case "short":
+		fmt.Println(s.Short())
+	case "json":
+		if v == nil {
+			v = s
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+	default:
+		if p, ok := s.(interface{ Problems() string }); ok && output.Quiet() {
+			fmt.Print(p.Problems())
+		} else {
+			fmt.Print(s.Summary())
+		}
+	}
+}
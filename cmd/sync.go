@@ -1,13 +1,29 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/desktop"
+	"github.com/jacobfgrant/emu-sync/internal/output"
+	"github.com/jacobfgrant/emu-sync/internal/power"
 	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/ratelimit"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
 	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+	"github.com/jacobfgrant/emu-sync/internal/systemd"
+	"github.com/jacobfgrant/emu-sync/internal/systems"
+	"github.com/jacobfgrant/emu-sync/internal/trigger"
 	"github.com/spf13/cobra"
 )
 
@@ -15,13 +31,52 @@ var syncDryRun bool
 var syncNoDelete bool
 var syncWorkers int
 var syncProgressJSON bool
+var syncSummaryFormat string
+var syncScheduled bool
+var syncOverwriteModified bool
+var syncProgressLog string
+var syncFollow bool
+var syncInterval time.Duration
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync files from the bucket to this device",
 	Long: `Downloads the remote manifest, compares against local state, and
 downloads new or changed files. Optionally deletes local files that
-were removed from the bucket.`,
+were removed from the bucket.
+
+If sync.max_duration is set (e.g., "45m"), no new downloads are started
+once that much time has passed. Remaining files are picked up by the
+next sync.
+
+A file changed on this device (e.g. a patched ROM) that also has a new
+version in the library is kept, with a warning, rather than overwritten.
+Use --overwrite-modified to replace such files with the library version.
+
+The outcome is saved to ~/.local/share/emu-sync/last-sync.json. Exit
+codes: 0 synced, 1 fatal error, 2 finished with file errors, 3 nothing
+to do.
+
+With --scheduled (used by the installed timer or launchd agent),
+sync.on_battery controls what happens on battery power or in Low Power
+Mode: "defer" skips the run, "throttle" syncs sequentially with a
+bandwidth cap (bandwidth_limit, or 2MB/s if unset), and "normal" (the
+default) ignores the power source. If notifications.desktop is set, a
+scheduled sync that changes something or fails shows a desktop
+notification (notify-send on Linux, Notification Center on macOS).
+
+Progress is drawn as a bar when stderr is a terminal. Under systemd,
+it is also reported with sd_notify (shown by systemctl status) and
+per-file journal entries with EMU_SYNC_* fields. --progress-log appends
+a timestamped line per event to a file, and sync.webhook receives the
+run's summary and warnings as JSON POSTs.
+
+With --follow, sync keeps running: after the first sync it checks the
+library every --interval (default 1m) and syncs again whenever it has
+changed. Each upload increments a small emu-sync-sequence object in the
+bucket, so a check is a single tiny download. If trigger.listen is set,
+it also serves POST /api/trigger (with trigger.token as a bearer token)
+and syncs as soon as an upload or CI job calls it.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath := cfgFile
 		if cfgPath == "" {
@@ -36,67 +91,408 @@ were removed from the bucket.`,
 		if err := cfg.ValidateEmulationPath(); err != nil {
 			return err
 		}
+		if err := checkSummaryFormat(syncSummaryFormat); err != nil {
+			return err
+		}
+		if syncFollow && syncDryRun {
+			return fmt.Errorf("--follow can't be combined with --dry-run")
+		}
+		if syncFollow && syncInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
 
 		workers := syncWorkers
 		if !cmd.Flags().Changed("workers") && cfg.Sync.Workers > 0 {
 			workers = cfg.Sync.Workers
 		}
 
-		maxRetries := cfg.Sync.MaxRetries
-		if maxRetries == 0 {
-			maxRetries = 3
-		}
+		printUpdateNotice := startUpdateNotice(cmd, cfg)
 
-		client := storage.NewClient(&cfg.Storage)
-
-		if cfg.Sync.BandwidthLimit != "" {
-			bps, err := config.ParseBandwidthLimit(cfg.Sync.BandwidthLimit)
+		if syncScheduled {
+			state, err := power.Check()
 			if err != nil {
-				return fmt.Errorf("parsing bandwidth_limit: %w", err)
+				log.Printf("warning: checking power source: %v", err)
 			}
-			if bps > 0 {
-				client.SetLimiter(ratelimit.NewLimiter(bps))
+			if state.Constrained() {
+				switch cfg.Sync.OnBattery {
+				case "defer":
+					if !output.Quiet() {
+						fmt.Println("On battery power; skipping scheduled sync (sync.on_battery = \"defer\")")
+					}
+					return nil
+				case "throttle":
+					throttleForBattery(cfg)
+					workers = 1
+					if !output.Quiet() {
+						fmt.Printf("On battery power; syncing sequentially at up to %s/s (sync.on_battery = \"throttle\")\n", cfg.Sync.BandwidthLimit)
+					}
+				}
 			}
 		}
 
-		opts := intsync.Options{
-			DryRun:     syncDryRun,
-			NoDelete:   syncNoDelete,
-			Verbose:    verbose,
-			Workers:    workers,
-			MaxRetries: maxRetries,
+		client, err := newSyncClient(cfg)
+		if err != nil {
+			return err
+		}
+
+		opts, err := syncOptions(cfg, workers)
+		if err != nil {
+			return err
 		}
+		opts.DryRun = syncDryRun
+		opts.NoDelete = syncNoDelete
+		opts.OverwriteModified = syncOverwriteModified
 
-		if cfg.Sync.SaveThreshold != "" {
-			bytes, err := config.ParseBandwidthLimit(cfg.Sync.SaveThreshold)
+		prog := progress.New()
+		if syncProgressJSON {
+			prog.Attach(progress.NewJSONSink(os.Stdout))
+		} else if !output.Quiet() && !syncDryRun && isTerminal(os.Stderr) {
+			prog.Attach(progress.NewBar(os.Stderr))
+		}
+		if systemd.Notifying() || systemd.Journaling() {
+			prog.Attach(systemd.NewProgressSink())
+			systemd.Notify("READY=1\nSTATUS=checking for changes")
+		}
+		if syncProgressLog != "" {
+			f, err := os.OpenFile(syncProgressLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
 			if err != nil {
-				return fmt.Errorf("parsing save_threshold: %w", err)
-			}
-			if bytes > 0 {
-				opts.SaveThreshold = bytes
+				return fmt.Errorf("opening progress log: %w", err)
 			}
+			defer f.Close()
+			prog.Attach(progress.NewLog(f))
+		}
+		if !syncDryRun {
+			attachWebhook(prog, cfg)
 		}
+		opts.Progress = prog
 
-		if syncProgressJSON {
-			opts.Progress = progress.NewReporter(true)
+		if syncFollow {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return followLibrary(ctx, client, cfg, opts, syncInterval, nil)
 		}
 
-		result, err := intsync.Run(cmd.Context(), client, cfg, opts)
+		result, err := runSync(cmd.Context(), client, cfg, opts)
+		if syncScheduled && cfg.Notifications.Desktop && !syncDryRun {
+			notifySync(cfg, result, err)
+		}
 		if err != nil {
 			return err
 		}
+		printUpdateNotice()
 
-		if !syncProgressJSON {
-			fmt.Print(result.Summary())
+		if code := syncExitCode(result); code != 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &ExitError{Code: code}
 		}
 		return nil
 	},
 }
 
+// runSync runs one sync, records its outcome, and prints its summary.
+func runSync(ctx context.Context, client storage.Backend, cfg *config.Config, opts intsync.Options) (*intsync.Result, error) {
+	result, err := intsync.Run(ctx, client, cfg, opts)
+	if !opts.DryRun && !errors.Is(err, intsync.ErrLocked) {
+		saveLastSync("", result, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		recordUsage("", 0, result.Bytes)
+	}
+
+	if !syncProgressJSON {
+		printSummary(syncSummaryFormat, result, intsync.NewLastRun(result, nil, time.Now()))
+	}
+	return result, nil
+}
+
+// notifySync shows a desktop notification for a sync that changed
+// something or failed, naming the new games it synced. A sync with
+// nothing to do stays quiet, so hourly schedules don't pop up a
+// notification every hour.
+func notifySync(cfg *config.Config, result *intsync.Result, err error) {
+	var body string
+	switch intsync.Status(result, err) {
+	case intsync.StatusNothingToDo:
+		return
+	case intsync.StatusFailed:
+		body = fmt.Sprintf("Sync failed: %v", err)
+	default:
+		body = result.Short()
+		if games := systems.Announcement(result.Added, cfg.SystemOverrides(), systems.AnnounceMax); games != "" {
+			body += "\n" + games
+		}
+	}
+	if err := desktop.Notify("emu-sync", body); err != nil {
+		log.Printf("warning: desktop notification: %v", err)
+	}
+}
+
+// followLibrary syncs, then checks the library every interval and syncs
+// again whenever it has changed, until ctx is canceled. A failed sync or
+// check is reported and retried at the next interval rather than ending
+// the loop. If health is not nil, each check and sync is recorded in it.
+func followLibrary(ctx context.Context, client storage.Backend, cfg *config.Config, opts intsync.Options, interval time.Duration, health *daemonHealth) error {
+	version, err := libraryVersion(ctx, client)
+	if err != nil {
+		return fmt.Errorf("checking library: %w", err)
+	}
+	_, err = runSync(ctx, client, cfg, opts)
+	if health != nil {
+		health.synced(err)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("sync failed: %v", err)
+		version = "" // try again at the next check
+	}
+	triggered, err := listenForTriggers(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if !output.Quiet() && !syncProgressJSON {
+		fmt.Printf("\nFollowing library changes every %s (Ctrl-C to stop)\n", interval)
+		if cfg.Trigger.Listen != "" {
+			fmt.Printf("Accepting sync triggers at http://%s%s\n", cfg.Trigger.Listen, trigger.Path)
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-triggered:
+			// Publishing bumps the sequence before triggering, so the
+			// check below sees the change.
+		}
+
+		v, err := libraryVersion(ctx, client)
+		if health != nil {
+			health.checked(err)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "[%s] Check failed: %v\n", time.Now().Format("15:04:05"), err)
+			}
+			continue
+		}
+		if v == version {
+			continue
+		}
+		if !output.Quiet() && !syncProgressJSON {
+			fmt.Printf("\n[%s] Library changed; syncing\n", time.Now().Format("15:04:05"))
+		}
+		opts.Progress = opts.Progress.Next()
+		_, err = runSync(ctx, client, cfg, opts)
+		if health != nil {
+			health.synced(err)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("sync failed: %v", err)
+			continue
+		}
+		version = v
+	}
+}
+
+// listenForTriggers serves trigger.Path on trigger.listen until ctx is
+// canceled. The returned channel receives a value when a trigger
+// arrives; triggers that arrive while one is pending are merged. With no
+// listen address, the channel never receives.
+func listenForTriggers(ctx context.Context, cfg *config.Config) (<-chan struct{}, error) {
+	triggered := make(chan struct{}, 1)
+	if cfg.Trigger.Listen == "" {
+		return triggered, nil
+	}
+	ln, err := net.Listen("tcp", cfg.Trigger.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("listening for triggers: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(trigger.Path, trigger.Handler(cfg.Trigger.Token, func() {
+		select {
+		case triggered <- struct{}{}:
+		default:
+		}
+	}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	return triggered, nil
+}
+
+// sendTriggers tells the devices in trigger.urls that the library was
+// just published. Failures are logged: a device that's off will still
+// pick up the change at its next poll.
+func sendTriggers(ctx context.Context, cfg *config.Config) {
+	if len(cfg.Trigger.URLs) == 0 {
+		return
+	}
+	transport, err := cfg.Network.Transport(false)
+	if err != nil {
+		log.Printf("warning: triggers not sent: %v", err)
+		return
+	}
+	for _, u := range cfg.Trigger.URLs {
+		if err := trigger.Send(ctx, u, cfg.Trigger.Token, transport); err != nil {
+			log.Printf("warning: sending sync trigger: %v", err)
+		}
+	}
+}
+
+// libraryVersion returns a token that changes whenever the library is
+// published: the emu-sync-sequence number, or for buckets last written
+// by an uploader that predates it, the manifest's version tag.
+func libraryVersion(ctx context.Context, client storage.Backend) (string, error) {
+	seq, err := storage.Sequence(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	if seq > 0 {
+		return strconv.FormatInt(seq, 10), nil
+	}
+	return storage.ManifestVersion(ctx, client)
+}
+
+// newSyncClient creates a storage client for cfg with its
+// bandwidth_limit applied.
+func newSyncClient(cfg *config.Config) (*storage.Client, error) {
+	client := storage.NewClient(&cfg.Storage, cfg.Network)
+	if cfg.Sync.BandwidthLimit != "" {
+		bps, err := config.ParseBandwidthLimit(cfg.Sync.BandwidthLimit)
+		if err != nil {
+			return nil, fmt.Errorf("parsing bandwidth_limit: %w", err)
+		}
+		if bps > 0 {
+			client.SetLimiter(ratelimit.NewLimiter(bps))
+		}
+	}
+	return client, nil
+}
+
+// attachWebhook adds a sink to prog that posts the run's summary and
+// warnings to sync.webhook, if one is set.
+func attachWebhook(prog *progress.Reporter, cfg *config.Config) {
+	if cfg.Sync.Webhook == "" {
+		return
+	}
+	transport, err := cfg.Network.Transport(false)
+	if err != nil {
+		log.Printf("warning: webhook disabled: %v", err)
+		return
+	}
+	prog.Attach(progress.NewWebhook(cfg.Sync.Webhook, transport))
+}
+
+// syncOptions builds sync options from the config's retry, threshold,
+// and duration settings.
+func syncOptions(cfg *config.Config, workers int) (intsync.Options, error) {
+	maxRetries := cfg.Sync.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+
+	opts := intsync.Options{
+		Workers:         workers,
+		MaxRetries:      maxRetries,
+		DeleteThreshold: cfg.SyncDeleteThreshold(),
+	}
+
+	if cfg.Sync.SaveThreshold != "" {
+		bytes, err := config.ParseBandwidthLimit(cfg.Sync.SaveThreshold)
+		if err != nil {
+			return opts, fmt.Errorf("parsing save_threshold: %w", err)
+		}
+		if bytes > 0 {
+			opts.SaveThreshold = bytes
+		}
+	}
+
+	if cfg.Sync.MaxDuration != "" {
+		d, err := time.ParseDuration(cfg.Sync.MaxDuration)
+		if err != nil {
+			return opts, fmt.Errorf("parsing max_duration: %w", err)
+		}
+		opts.MaxDuration = d
+	}
+	return opts, nil
+}
+
+// batteryBandwidthLimit caps transfers for on_battery = "throttle" when
+// no bandwidth_limit is configured.
+const batteryBandwidthLimit = "2MB"
+
+// throttleForBattery makes cfg sync sequentially, including directories
+// with tuned workers, and applies batteryBandwidthLimit if no limit is set.
+func throttleForBattery(cfg *config.Config) {
+	cfg.Sync.Workers = 1
+	if cfg.Sync.BandwidthLimit == "" || cfg.Sync.BandwidthLimit == "0" {
+		cfg.Sync.BandwidthLimit = batteryBandwidthLimit
+	}
+	tuning := make(map[string]config.TuningConfig, len(cfg.Sync.Tuning))
+	for dir, t := range cfg.Sync.Tuning {
+		if t.Workers > 1 {
+			t.Workers = 1
+		}
+		tuning[dir] = t
+	}
+	cfg.Sync.Tuning = tuning
+}
+
+// Exit codes for sync beyond 0 (success) and 1 (fatal error).
+const (
+	exitSyncFileErrors  = 2 // finished, but some files failed
+	exitSyncNothingToDo = 3 // already up to date
+)
+
+// syncExitCode maps a completed sync to its exit code.
+func syncExitCode(result *intsync.Result) int {
+	switch intsync.Status(result, nil) {
+	case intsync.StatusPartial:
+		return exitSyncFileErrors
+	case intsync.StatusNothingToDo:
+		return exitSyncNothingToDo
+	}
+	return 0
+}
+
+// saveLastSync records the outcome of a sync for scripts and the web UI.
+// Failures are logged rather than returned so they don't mask the sync's
+// own result. An empty path uses the default location.
+func saveLastSync(path string, result *intsync.Result, err error) {
+	if path == "" {
+		path = config.DefaultLastSyncPath()
+	}
+	if err := intsync.NewLastRun(result, err, time.Now()).Save(path); err != nil {
+		log.Printf("warning: saving last sync result: %v", err)
+	}
+}
+
 func init() {
+	addOutputFlags(syncCmd)
 	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "show what would change without downloading")
 	syncCmd.Flags().BoolVar(&syncNoDelete, "no-delete", false, "don't delete files removed from bucket")
 	syncCmd.Flags().IntVar(&syncWorkers, "workers", 1, "number of parallel downloads (1 = sequential)")
 	syncCmd.Flags().BoolVar(&syncProgressJSON, "progress-json", false, "emit JSON progress events to stdout")
+	addSummaryFormatFlag(syncCmd, &syncSummaryFormat)
+	syncCmd.Flags().BoolVar(&syncScheduled, "scheduled", false, "apply sync.on_battery (set by the installed schedule)")
+	syncCmd.Flags().StringVar(&syncProgressLog, "progress-log", "", "append a timestamped line per progress event to this file")
+	syncCmd.Flags().BoolVar(&syncFollow, "follow", false, "keep running and sync again whenever the library changes")
+	syncCmd.Flags().DurationVar(&syncInterval, "interval", time.Minute, "how often --follow checks for library changes")
+	syncCmd.Flags().BoolVar(&syncOverwriteModified, "overwrite-modified", false, "replace files changed on this device with the library version")
 	rootCmd.AddCommand(syncCmd)
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+)
+
+func TestSyncExitCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *intsync.Result
+		want   int
+	}{
+		{"synced", &intsync.Result{Downloaded: []string{"a"}}, 0},
+		{"file errors", &intsync.Result{Downloaded: []string{"a"}, Errors: []error{errors.New("b")}}, exitSyncFileErrors},
+		{"nothing to do", &intsync.Result{Skipped: 2}, exitSyncNothingToDo},
+	}
+	for _, tt := range tests {
+		if got := syncExitCode(tt.result); got != tt.want {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThrottleForBattery(t *testing.T) {
+	cfg := &config.Config{Sync: config.SyncConfig{
+		Workers: 4,
+		Tuning: map[string]config.TuningConfig{
+			"roms/ps2": {Workers: 8, StorageClass: "STANDARD_IA"},
+		},
+	}}
+	original := cfg.Sync.Tuning
+
+	throttleForBattery(cfg)
+	if cfg.Sync.Workers != 1 {
+		t.Errorf("workers = %d, want 1", cfg.Sync.Workers)
+	}
+	if cfg.Sync.BandwidthLimit != batteryBandwidthLimit {
+		t.Errorf("bandwidth_limit = %q, want %q", cfg.Sync.BandwidthLimit, batteryBandwidthLimit)
+	}
+	if got := cfg.Sync.Tuning["roms/ps2"]; got.Workers != 1 || got.StorageClass != "STANDARD_IA" {
+		t.Errorf("tuning = %+v, want workers 1 with storage class kept", got)
+	}
+	if original["roms/ps2"].Workers != 8 {
+		t.Error("original tuning map should not be modified")
+	}
+
+	cfg.Sync.BandwidthLimit = "500KB"
+	throttleForBattery(cfg)
+	if cfg.Sync.BandwidthLimit != "500KB" {
+		t.Errorf("bandwidth_limit = %q, want configured limit kept", cfg.Sync.BandwidthLimit)
+	}
+}
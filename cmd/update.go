@@ -2,41 +2,84 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/output"
 	"github.com/jacobfgrant/emu-sync/internal/update"
 	"github.com/spf13/cobra"
 )
 
 var checkOnly bool
+var updateVersion string
+var updateRollback bool
 
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update emu-sync to the latest version",
 	Long: `Checks for a newer version and updates emu-sync.
 Detects whether emu-sync was installed via Homebrew or the install
-script and updates accordingly. Use --check to only check without updating.`,
+script and updates accordingly. Use --check to only check without updating.
+
+For install-script installs, the release archive is checked against the
+release's checksums before anything is replaced, and the replaced binary
+is kept next to the new one. Use --version to install a specific release
+(including an older one) and --rollback to swap back to the previous
+binary.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		current := cmd.Root().Version
 
-		if current == "dev" {
+		if updateRollback {
+			return rollbackUpdate()
+		}
+
+		if current == "dev" && updateVersion == "" {
 			fmt.Println("Development build — update not available.")
 			return nil
 		}
 
-		fmt.Printf("Current version: %s\n", current)
-		fmt.Println("Checking for updates...")
-
-		latest, err := update.CheckLatestVersion()
-		if err != nil {
-			return fmt.Errorf("checking for updates: %w", err)
+		// Use the config's proxy and CA settings if there is a config;
+		// updating works without one.
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
 		}
-
-		if !update.IsUpdateAvailable(current, latest) {
-			fmt.Printf("Already up to date (%s).\n", current)
-			return nil
+		if cfg, err := config.Load(cfgPath); err == nil {
+			transport, err := cfg.Network.Transport(false)
+			if err != nil {
+				return fmt.Errorf("network config: %w", err)
+			}
+			update.SetTransport(transport)
 		}
 
-		fmt.Printf("Update available: %s → %s\n", current, latest)
+		fmt.Printf("Current version: %s\n", current)
+
+		target := updateVersion
+		if target != "" {
+			if !strings.HasPrefix(target, "v") {
+				target = "v" + target
+			}
+			if target == current {
+				fmt.Printf("Already at %s.\n", current)
+				return nil
+			}
+			fmt.Printf("Requested version: %s\n", target)
+		} else {
+			fmt.Println("Checking for updates...")
+			latest, err := update.CheckLatestVersion()
+			if err != nil {
+				return fmt.Errorf("checking for updates: %w", err)
+			}
+			if !update.IsUpdateAvailable(current, latest) {
+				fmt.Printf("Already up to date (%s).\n", current)
+				return nil
+			}
+			fmt.Printf("Update available: %s → %s\n", current, latest)
+			target = latest
+		}
 
 		if checkOnly {
 			return nil
@@ -45,16 +88,107 @@ script and updates accordingly. Use --check to only check without updating.`,
 		method := update.DetectInstallMethod()
 		switch method {
 		case update.MethodBrew:
+			if updateVersion != "" {
+				return fmt.Errorf("--version is not supported for Homebrew installs")
+			}
 			fmt.Println("Updating via Homebrew...")
 			return update.RunBrewUpgrade()
 		default:
-			fmt.Println("Updating via install script...")
-			return update.RunScriptUpdate(latest)
+			return installRelease(target)
 		}
 	},
 }
 
+// installRelease downloads, verifies, and installs version in place of
+// the running binary.
+func installRelease(version string) error {
+	binPath, err := executablePath()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Downloading %s (%s)...\n", version, update.AssetName())
+	data, err := update.DownloadRelease(version)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Checksum OK")
+
+	if err := update.Install(binPath, data); err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s to %s (previous version kept at %s)\n",
+		version, binPath, update.PreviousPath(binPath))
+
+	fmt.Println("Updating schedule and shortcuts...")
+	return update.RefreshSchedule(binPath)
+}
+
+// rollbackUpdate swaps back to the binary replaced by the last update.
+func rollbackUpdate() error {
+	if update.DetectInstallMethod() == update.MethodBrew {
+		return fmt.Errorf("--rollback is not supported for Homebrew installs")
+	}
+	binPath, err := executablePath()
+	if err != nil {
+		return err
+	}
+	if err := update.Rollback(binPath); err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back %s to the previous version (run --rollback again to undo)\n", binPath)
+
+	fmt.Println("Updating schedule and shortcuts...")
+	return update.RefreshSchedule(binPath)
+}
+
+// updateNoticeWait is how long a finished command waits for a background
+// update check before giving up on the notice.
+const updateNoticeWait = 2 * time.Second
+
+// startUpdateNotice checks for a newer release in the background (at most
+// once a day, see update.CachedLatestVersion). Call the returned function
+// when the command is done to print a one-line notice to stderr if an
+// update is available. There is no notice under --quiet.
+func startUpdateNotice(cmd *cobra.Command, cfg *config.Config) func() {
+	current := cmd.Root().Version
+	if current == "" || current == "dev" || !cfg.Update.NotifyEnabled() || output.Quiet() {
+		return func() {}
+	}
+	if transport, err := cfg.Network.Transport(false); err == nil {
+		update.SetTransport(transport)
+	}
+
+	latest := make(chan string, 1)
+	go func() { latest <- update.CachedLatestVersion(config.DefaultUpdateCheckPath()) }()
+
+	return func() {
+		select {
+		case v := <-latest:
+			if v != "" && update.IsUpdateAvailable(current, v) {
+				fmt.Fprintf(os.Stderr, "\nemu-sync %s is available (run `emu-sync update`)\n", v)
+			}
+		case <-time.After(updateNoticeWait):
+		}
+	}
+}
+
+// executablePath returns the running binary's path with symlinks resolved.
+func executablePath() (string, error) {
+	binPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("resolving binary path: %w", err)
+	}
+	binPath, err = filepath.EvalSymlinks(binPath)
+	if err != nil {
+		return "", fmt.Errorf("resolving binary symlinks: %w", err)
+	}
+	return binPath, nil
+}
+
 func init() {
 	updateCmd.Flags().BoolVar(&checkOnly, "check", false, "only check for updates, don't install")
+	updateCmd.Flags().StringVar(&updateVersion, "version", "", "install a specific release (e.g. v0.6.2) instead of the latest")
+	updateCmd.Flags().BoolVar(&updateRollback, "rollback", false, "swap back to the binary replaced by the last update")
 	rootCmd.AddCommand(updateCmd)
 }
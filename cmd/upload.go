@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
-	"github.com/jacobfgrant/emu-sync/internal/ratelimit"
+	"github.com/jacobfgrant/emu-sync/internal/output"
+	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
 	"github.com/jacobfgrant/emu-sync/internal/upload"
 	"github.com/spf13/cobra"
@@ -14,6 +16,19 @@ var uploadSource string
 var uploadDryRun bool
 var uploadManifestOnly bool
 var uploadWorkers int
+var uploadMerge bool
+var uploadDelete bool
+var uploadForce bool
+var uploadFullScan bool
+var uploadFromBucket bool
+var uploadProgressJSON bool
+var uploadCI bool
+var uploadSummaryFormat string
+var uploadPublishPending bool
+
+// uploadDeleteThreshold is the fraction of the remote manifest an upload
+// may delete before --force is required.
+const uploadDeleteThreshold = 0.2
 
 var uploadCmd = &cobra.Command{
 	Use:   "upload",
@@ -24,25 +39,96 @@ updated manifest after upload.
 
 Use --manifest-only to skip file uploads and just regenerate the
 manifest from local files. Useful when another tool handles file
-uploads and you just need to update the manifest.`,
+uploads and you just need to update the manifest.
+
+Use --merge when several curators upload to the same bucket. Only
+keys under this uploader's owned_dirs (default: sync_dirs) are added,
+updated, or deleted; all other manifest entries are preserved.
+
+Files missing locally are deleted from the bucket unless --delete=false
+is given. If more than 20% of the manifest would be deleted, the upload
+aborts (in case the source drive failed to mount); use --force to
+proceed anyway.
+
+Directories whose modification time hasn't changed since the last
+upload are not re-listed; the files recorded for them are still checked
+for changes in size or modification time. Use --full-scan to list every
+directory.
+
+If files reach the bucket some other way (rclone, the provider's web
+console), --manifest-only --from-bucket builds the manifest from a
+listing of the bucket instead of the source directory. Hashes come
+from the objects' ETags; objects uploaded in parts have no usable ETag
+and are downloaded once to hash them.
+
+Progress is drawn as a bar when stderr is a terminal; --progress-json
+emits the same JSON events as sync --progress-json instead, ending with
+a done event whose "uploaded" field counts the files uploaded.
+
+--ci is for publishing from a CI job such as GitHub Actions. The config
+file is optional: EMU_SYNC_BUCKET, EMU_SYNC_KEY_ID, EMU_SYNC_SECRET_KEY
+and the other EMU_SYNC_* variables override it, and --source stands in
+for emulation_path. Progress is emitted as JSON on stdout, failures and
+warnings become ::error:: and ::warning:: annotations on stderr, and the
+exit code is 2 if any file failed.
+
+If the manifest can't be uploaded at the end of a run, it is saved
+locally so the work isn't lost. The next upload publishes it before
+starting; --publish-pending publishes it without scanning anything.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath := cfgFile
 		if cfgPath == "" {
 			cfgPath = config.DefaultConfigPath()
 		}
 
-		cfg, err := config.Load(cfgPath)
+		var cfg *config.Config
+		var err error
+		if uploadCI {
+			cfg, err = config.LoadEnv(cfgPath, uploadSource)
+		} else {
+			cfg, err = config.Load(cfgPath)
+		}
 		if err != nil {
 			return fmt.Errorf("loading config: %w", err)
 		}
+		if err := requireWritable(cfg); err != nil {
+			return err
+		}
 
 		source := uploadSource
 		if source == "" {
 			source = cfg.Sync.EmulationPath
 		}
 
-		if err := config.ValidatePath(source); err != nil {
-			return fmt.Errorf("source directory: %w", err)
+		if uploadPublishPending {
+			client, err := newUploadClient(cfg)
+			if err != nil {
+				return err
+			}
+			published, err := upload.PublishPending(cmd.Context(), client, uploadOptions(cfg, source, 1, 0))
+			if err != nil {
+				return err
+			}
+			if published {
+				fmt.Println("Published the pending manifest.")
+				sendTriggers(cmd.Context(), cfg)
+			} else {
+				fmt.Println("No pending manifest to publish.")
+			}
+			return nil
+		}
+
+		if uploadFromBucket && !uploadManifestOnly {
+			return fmt.Errorf("--from-bucket only rebuilds the manifest; add --manifest-only")
+		}
+		if err := checkSummaryFormat(uploadSummaryFormat); err != nil {
+			return err
+		}
+
+		if !uploadFromBucket {
+			if err := config.ValidatePath(source); err != nil {
+				return fmt.Errorf("source directory: %w", err)
+			}
 		}
 
 		workers := uploadWorkers
@@ -55,49 +141,112 @@ uploads and you just need to update the manifest.`,
 			maxRetries = 3
 		}
 
-		client := storage.NewClient(&cfg.Storage)
+		client, err := newUploadClient(cfg)
+		if err != nil {
+			return err
+		}
 
-		if cfg.Sync.BandwidthLimit != "" {
-			bps, err := config.ParseBandwidthLimit(cfg.Sync.BandwidthLimit)
-			if err != nil {
-				return fmt.Errorf("parsing bandwidth_limit: %w", err)
-			}
-			if bps > 0 {
-				client.SetLimiter(ratelimit.NewLimiter(bps))
-			}
+		opts := uploadOptions(cfg, source, workers, maxRetries)
+		opts.DryRun = uploadDryRun
+		opts.ManifestOnly = uploadManifestOnly
+		opts.Merge = uploadMerge
+		opts.NoDelete = !uploadDelete
+		opts.Force = uploadForce
+		opts.FullScan = uploadFullScan
+		if uploadCI {
+			opts.Progress = progress.New(progress.NewJSONSink(os.Stdout), progress.NewAnnotations(os.Stderr))
+		} else if uploadProgressJSON {
+			opts.Progress = progress.NewReporter(true)
+		} else if !output.Quiet() && !uploadDryRun && isTerminal(os.Stderr) {
+			opts.Progress = progress.New(progress.NewBar(os.Stderr))
+		}
+		if uploadFromBucket {
+			opts.FromBucket = true
+			// The bucket's files aren't necessarily on this machine
+			opts.LocalManifestPath = ""
 		}
 
-		// Save a local manifest when uploading from the emulation path
-		// so a subsequent sync knows these files are already present.
-		localManifestPath := ""
-		if source == cfg.Sync.EmulationPath {
-			localManifestPath = config.DefaultLocalManifestPath()
-		}
-
-		result, err := upload.Run(cmd.Context(), client, upload.Options{
-			SourcePath:        source,
-			SyncDirs:          cfg.Sync.SyncDirs,
-			DryRun:            uploadDryRun,
-			Verbose:           verbose,
-			ManifestOnly:      uploadManifestOnly,
-			Workers:           workers,
-			MaxRetries:        maxRetries,
-			SkipDotfiles:      *cfg.Sync.SkipDotfiles,
-			LocalManifestPath: localManifestPath,
-		})
+		result, err := upload.Run(cmd.Context(), client, opts)
 		if err != nil {
 			return err
 		}
+		if !uploadDryRun {
+			recordUsage("", result.Bytes, 0)
+			sendTriggers(cmd.Context(), cfg)
+		}
 
-		fmt.Print(result.Summary())
+		if !uploadProgressJSON && !uploadCI {
+			printSummary(uploadSummaryFormat, result, nil)
+		}
+		if uploadCI && len(result.Errors) > 0 {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &ExitError{Code: exitSyncFileErrors}
+		}
 		return nil
 	},
 }
 
+// newUploadClient creates a storage client with the config's storage
+// classes and bandwidth limit applied.
+func newUploadClient(cfg *config.Config) (*storage.Client, error) {
+	client, err := newSyncClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.SetStorageClasses(cfg.Sync.Tuning)
+	return client, nil
+}
+
+// uploadOptions returns the upload options shared by upload and watch.
+func uploadOptions(cfg *config.Config, source string, workers, maxRetries int) upload.Options {
+	// Save a local manifest when uploading from the emulation path
+	// so a subsequent sync knows these files are already present.
+	localManifestPath := ""
+	if source == cfg.Sync.EmulationPath {
+		localManifestPath = config.DefaultLocalManifestPath()
+	}
+
+	return upload.Options{
+		SourcePath:        source,
+		SyncDirs:          cfg.Sync.SyncDirs,
+		Workers:           workers,
+		MaxRetries:        maxRetries,
+		SkipDotfiles:      *cfg.Sync.SkipDotfiles,
+		LocalManifestPath: localManifestPath,
+		OwnedDirs:         cfg.UploadOwnedDirs(),
+		DeleteThreshold:   uploadDeleteThreshold,
+		Tuning:            cfg.Sync.Tuning,
+		CaseCollisions:    cfg.Sync.CaseCollisions,
+		Order:             cfg.Sync.Order,
+		Priority:          cfg.Sync.Priority,
+	}
+}
+
+// requireWritable refuses to run commands that write to the bucket with
+// credentials marked read-only, rather than failing on the first request.
+func requireWritable(cfg *config.Config) error {
+	if cfg.Storage.ReadOnly {
+		return fmt.Errorf("these credentials are read-only (storage.read_only); uploading needs an admin config")
+	}
+	return nil
+}
+
 func init() {
+	addOutputFlags(uploadCmd)
 	uploadCmd.Flags().StringVar(&uploadSource, "source", "", "source directory (defaults to config emulation_path)")
+	uploadCmd.MarkFlagDirname("source")
 	uploadCmd.Flags().BoolVar(&uploadDryRun, "dry-run", false, "show what would be uploaded without uploading")
 	uploadCmd.Flags().BoolVar(&uploadManifestOnly, "manifest-only", false, "regenerate and upload manifest without uploading files")
 	uploadCmd.Flags().IntVar(&uploadWorkers, "workers", 1, "number of parallel uploads (1 = sequential)")
+	uploadCmd.Flags().BoolVar(&uploadMerge, "merge", false, "only manage owned_dirs and preserve other manifest entries")
+	uploadCmd.Flags().BoolVar(&uploadDelete, "delete", true, "delete bucket files that no longer exist locally")
+	uploadCmd.Flags().BoolVar(&uploadForce, "force", false, "delete even if more than 20% of the manifest would be removed")
+	uploadCmd.Flags().BoolVar(&uploadFullScan, "full-scan", false, "list every directory instead of reusing the file lists of unchanged ones")
+	uploadCmd.Flags().BoolVar(&uploadProgressJSON, "progress-json", false, "emit JSON progress events to stdout")
+	addSummaryFormatFlag(uploadCmd, &uploadSummaryFormat)
+	uploadCmd.Flags().BoolVar(&uploadCI, "ci", false, "run headless for CI: config from EMU_SYNC_* variables, JSON progress, annotations, non-zero exit on file errors")
+	uploadCmd.Flags().BoolVar(&uploadPublishPending, "publish-pending", false, "only publish the manifest a failed upload saved locally")
+	uploadCmd.Flags().BoolVar(&uploadFromBucket, "from-bucket", false, "with --manifest-only, build the manifest from the bucket's contents instead of the source directory")
 	rootCmd.AddCommand(uploadCmd)
 }
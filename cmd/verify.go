@@ -2,18 +2,39 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
 	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
 	"github.com/spf13/cobra"
 )
 
+var verifyRemote bool
+var verifyDeep bool
+var verifyBudget string
+var verifySummaryFormat string
+
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify local files against the manifest",
 	Long: `Re-hashes local files and compares against the local manifest.
 Files that don't match are removed from the manifest so they
-will be re-downloaded on the next sync.`,
+will be re-downloaded on the next sync.
+
+Use --remote to check the bucket instead: every object in the manifest
+is checked for presence, size, and (where the bucket knows it) MD5,
+without downloading anything.
+
+Add --deep to also download and re-hash a random sample of objects, up
+to --budget bytes per run, to catch silent corruption in the bucket.
+Objects not checked yet go first, so repeated runs (e.g. from a weekly
+timer) cover the whole library over time:
+
+  emu-sync verify --remote --deep --budget 2GB
+
+--summary-format short prints one line per check; json prints one
+object, with the --remote check under "drift" and the --deep sample
+under "deep".`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfgPath := cfgFile
 		if cfgPath == "" {
@@ -25,20 +46,81 @@ will be re-downloaded on the next sync.`,
 			return fmt.Errorf("loading config: %w", err)
 		}
 
-		if err := cfg.ValidateEmulationPath(); err != nil {
+		if err := checkSummaryFormat(verifySummaryFormat); err != nil {
 			return err
 		}
+		table := verifySummaryFormat == "table"
+
+		if !verifyRemote {
+			if verifyDeep || cmd.Flags().Changed("budget") {
+				return fmt.Errorf("--deep and --budget require --remote")
+			}
+			if err := cfg.ValidateEmulationPath(); err != nil {
+				return err
+			}
+
+			result, err := intsync.Verify(cmd.Context(), cfg, "", nil)
+			if err != nil {
+				return err
+			}
+
+			printSummary(verifySummaryFormat, result, nil)
+			return nil
+		}
+
+		budget, err := config.ParseBandwidthLimit(verifyBudget)
+		if err != nil || budget <= 0 {
+			return fmt.Errorf("invalid --budget %q (e.g., 500MB, 2GB)", verifyBudget)
+		}
 
-		result, err := intsync.Verify(cfg, "", verbose)
+		client, err := newSyncClient(cfg)
+		if err != nil {
+			return err
+		}
+		_, filtered, _, err := loadPending(cmd.Context(), client, cfg)
 		if err != nil {
 			return err
 		}
 
-		fmt.Print(result.Summary())
+		workers := cfg.Sync.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		if table {
+			fmt.Println("Checking bucket...")
+		}
+		drift := intsync.CheckDrift(cmd.Context(), client, filtered, 0, workers)
+		report := struct {
+			Drift *intsync.DriftResult        `json:"drift"`
+			Deep  *intsync.RemoteVerifyResult `json:"deep,omitempty"`
+		}{Drift: drift}
+		// With --deep, json prints both checks as one object at the end
+		if !verifyDeep || verifySummaryFormat != "json" {
+			printSummary(verifySummaryFormat, drift, report)
+		}
+
+		if !verifyDeep {
+			return nil
+		}
+		statePath := config.DefaultRemoteVerifyPath()
+		state := intsync.LoadRemoteVerifyState(statePath)
+		if table {
+			fmt.Println()
+			fmt.Printf("Re-hashing up to %s from the bucket...\n", formatSize(budget))
+		}
+		report.Deep = intsync.VerifyRemote(cmd.Context(), client, filtered, budget, state, time.Now())
+		if err := state.Save(statePath); err != nil {
+			return err
+		}
+		printSummary(verifySummaryFormat, report.Deep, report)
 		return nil
 	},
 }
 
 func init() {
+	verifyCmd.Flags().BoolVar(&verifyRemote, "remote", false, "check objects in the bucket instead of local files")
+	verifyCmd.Flags().BoolVar(&verifyDeep, "deep", false, "with --remote, download and re-hash a sample of objects")
+	verifyCmd.Flags().StringVar(&verifyBudget, "budget", "1GB", "with --deep, maximum bytes to download per run")
+	addSummaryFormatFlag(verifyCmd, &verifySummaryFormat)
 	rootCmd.AddCommand(verifyCmd)
 }
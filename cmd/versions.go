@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/upload"
+	"github.com/spf13/cobra"
+)
+
+var versionsRestore string
+
+var versionsCmd = &cobra.Command{
+	Use:   "versions key",
+	Short: "List or restore archived versions of a file",
+	Long: `Lists the previous versions of a library file that upload archived
+under versions/ in the bucket, newest first. Files are only archived if
+their directory (or the file itself) sets keep_versions in its tuning:
+
+  [sync.tuning."textures"]
+  keep_versions = 3
+
+--restore copies a version back into place and updates the manifest, so
+devices get it on their next sync. The version it replaces is archived
+first, so a restore can itself be undone:
+
+  emu-sync versions textures/pack.zip
+  emu-sync versions textures/pack.zip --restore 20261017T120000.000Z
+
+A full upload puts the source directory's copy back, so restore the file
+there too if you upload from it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		key := args[0]
+
+		if versionsRestore != "" {
+			if err := requireWritable(cfg); err != nil {
+				return err
+			}
+			client, err := newUploadClient(cfg)
+			if err != nil {
+				return err
+			}
+			maxRetries := cfg.Sync.MaxRetries
+			if maxRetries == 0 {
+				maxRetries = 3
+			}
+			result, err := upload.Restore(cmd.Context(), client, key, versionsRestore, cfg.Sync.Tuning, maxRetries)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					return fmt.Errorf("%s has no version %s (see 'emu-sync versions %s'): %w", key, versionsRestore, key, err)
+				}
+				return err
+			}
+			if result.Unchanged {
+				fmt.Printf("%s already has the content of version %s\n", key, versionsRestore)
+			} else {
+				fmt.Printf("Restored %s to version %s (%s)\n", key, versionsRestore, formatSize(result.Entry.Size))
+			}
+			return nil
+		}
+
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
+		versions, err := upload.ListVersions(cmd.Context(), client, key)
+		if err != nil {
+			return fmt.Errorf("listing versions: %w", err)
+		}
+		if len(versions) == 0 {
+			fmt.Printf("No archived versions of %s.\n", key)
+			if config.KeepVersionsFor(key, cfg.Sync.Tuning) == 0 {
+				fmt.Println("Set keep_versions under [sync.tuning] to archive it when upload replaces it.")
+			}
+			return nil
+		}
+		for _, v := range versions {
+			fmt.Printf("  %s (%s, archived %s)\n", v.ID, formatSize(v.Size), v.Archived.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("Restore one with 'emu-sync versions %s --restore ID'.\n", key)
+		return nil
+	},
+}
+
+func init() {
+	versionsCmd.Flags().StringVar(&versionsRestore, "restore", "", "make this archived version the current one")
+	rootCmd.AddCommand(versionsCmd)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/upload"
+	"github.com/jacobfgrant/emu-sync/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var watchSource string
+var watchWorkers int
+var watchDebounce time.Duration
+var watchMerge bool
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Upload changes automatically as files are added or modified",
+	Long: `Watches the sync_dirs in the source directory and runs an
+incremental upload shortly after files are added, changed, or removed.
+Changes are batched: an upload starts once nothing new has changed for
+the --debounce period.
+
+An upload runs at startup to catch anything changed while emu-sync
+wasn't watching. Runs until interrupted. Uses inotify on Linux and
+periodic rescans on other platforms.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath := cfgFile
+		if cfgPath == "" {
+			cfgPath = config.DefaultConfigPath()
+		}
+
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := requireWritable(cfg); err != nil {
+			return err
+		}
+
+		source := watchSource
+		if source == "" {
+			source = cfg.Sync.EmulationPath
+		}
+
+		if err := config.ValidatePath(source); err != nil {
+			return fmt.Errorf("source directory: %w", err)
+		}
+
+		// Builds the client and options from cfg, at startup and again
+		// whenever the config file changes.
+		setup := func(cfg *config.Config) (*storage.Client, upload.Options, error) {
+			workers := watchWorkers
+			if !cmd.Flags().Changed("workers") && cfg.Sync.Workers > 0 {
+				workers = cfg.Sync.Workers
+			}
+
+			maxRetries := cfg.Sync.MaxRetries
+			if maxRetries == 0 {
+				maxRetries = 3
+			}
+
+			client, err := newUploadClient(cfg)
+			if err != nil {
+				return nil, upload.Options{}, err
+			}
+
+			opts := uploadOptions(cfg, source, workers, maxRetries)
+			opts.Merge = watchMerge
+			return client, opts, nil
+		}
+
+		cfgVersion := config.FileVersion(cfgPath)
+		client, opts, err := setup(cfg)
+		if err != nil {
+			return err
+		}
+
+		// Replaces the settings from the config file if it's usable. The
+		// watched directories are fixed at startup, so changes to them
+		// need a restart.
+		reload := func() error {
+			newCfg, err := config.Load(cfgPath)
+			if err != nil {
+				return err
+			}
+			if err := requireWritable(newCfg); err != nil {
+				return err
+			}
+			newClient, newOpts, err := setup(newCfg)
+			if err != nil {
+				return err
+			}
+			if !slices.Equal(newCfg.Sync.SyncDirs, cfg.Sync.SyncDirs) || newCfg.Sync.EmulationPath != cfg.Sync.EmulationPath {
+				fmt.Println("sync_dirs or emulation_path changed; restart watch to watch the new directories.")
+			}
+			cfg, client, opts = newCfg, newClient, newOpts
+			return nil
+		}
+
+		var roots []string
+		for _, dir := range cfg.Sync.SyncDirs {
+			root := filepath.Join(source, dir)
+			if _, err := os.Stat(root); err == nil {
+				roots = append(roots, root)
+			}
+		}
+		if len(roots) == 0 {
+			return fmt.Errorf("none of sync_dirs %v exist in %s", cfg.Sync.SyncDirs, source)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		runWatchUpload(ctx, client, cfg, opts)
+		fmt.Printf("Watching %d directories in %s (Ctrl-C to stop)\n", len(roots), source)
+
+		return watch.Run(ctx, roots, watch.Options{
+			Debounce:     watchDebounce,
+			SkipDotfiles: opts.SkipDotfiles,
+		}, func(paths []string) {
+			fmt.Printf("\n%d changes detected, uploading...\n", len(paths))
+			for _, p := range paths {
+				logging.Printf(logging.Files, "changed: %s", p)
+			}
+
+			// Pick up config edits between uploads, never during one.
+			if v := config.FileVersion(cfgPath); !v.Equal(cfgVersion) {
+				cfgVersion = v
+				if err := reload(); err != nil {
+					fmt.Printf("Config file changed but couldn't be reloaded, keeping the old settings: %v\n", err)
+				} else {
+					fmt.Println("Config file changed; reloaded.")
+				}
+			}
+			runWatchUpload(ctx, client, cfg, opts)
+		})
+	},
+}
+
+// runWatchUpload runs one incremental upload. Failures are printed rather
+// than returned so the watcher keeps running; the next change retries.
+func runWatchUpload(ctx context.Context, client storage.Backend, cfg *config.Config, opts upload.Options) {
+	result, err := upload.Run(ctx, client, opts)
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Printf("Upload failed: %v\n", err)
+		}
+		return
+	}
+	recordUsage("", result.Bytes, 0)
+	sendTriggers(ctx, cfg)
+	fmt.Print(result.Summary())
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchSource, "source", "", "source directory (defaults to config emulation_path)")
+	watchCmd.MarkFlagDirname("source")
+	watchCmd.Flags().IntVar(&watchWorkers, "workers", 1, "number of parallel uploads (1 = sequential)")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 10*time.Second, "wait this long after the last change before uploading")
+	watchCmd.Flags().BoolVar(&watchMerge, "merge", false, "only manage owned_dirs and preserve other manifest entries")
+	rootCmd.AddCommand(watchCmd)
+}
@@ -1,99 +1,113 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
+	"reflect"
 	"runtime"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/jobs"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
 	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/ratelimit"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
 	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+	"github.com/jacobfgrant/emu-sync/internal/systems"
+	"github.com/jacobfgrant/emu-sync/internal/units"
+	"github.com/jacobfgrant/emu-sync/internal/usage"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cobra"
 )
 
-//go:embed web_assets/index.html
+//go:embed web_assets/index.html web_assets/i18n/*.json
 var webAssets embed.FS
 
-// eventLog captures JSON progress lines and fans them out to SSE clients.
-// Implements io.Writer so it can be passed to progress.NewReporterWriter.
-type eventLog struct {
-	mu     sync.Mutex
-	lines  []string
-	done   bool
-	notify chan struct{} // buffered(1), signaled on new event or finish
-}
-
-func newEventLog() *eventLog {
-	return &eventLog{notify: make(chan struct{}, 1)}
-}
-
-func (el *eventLog) Write(p []byte) (int, error) {
-	line := strings.TrimRight(string(p), "\n")
-	el.mu.Lock()
-	el.lines = append(el.lines, line)
-	el.mu.Unlock()
-	select {
-	case el.notify <- struct{}{}:
-	default:
-	}
-	return len(p), nil
-}
-
-func (el *eventLog) finish() {
-	el.mu.Lock()
-	el.done = true
-	el.mu.Unlock()
-	select {
-	case el.notify <- struct{}{}:
-	default:
-	}
-}
-
-func (el *eventLog) read(from int) ([]string, bool) {
-	el.mu.Lock()
-	defer el.mu.Unlock()
-	if from >= len(el.lines) {
-		return nil, el.done
-	}
-	return el.lines[from:], el.done
-}
+// Kinds of background job the web server runs.
+const (
+	jobSync    = "sync"
+	jobVerify  = "verify"
+	jobPreview = "preview" // dry-run sync
+)
 
 type webServer struct {
-	groups            []*systemGroup
 	cfg               *config.Config
 	cfgPath           string
-	localManifestPath string               // overrides default; used by tests
-	remoteManifest    *manifest.Manifest   // for sync status diff
+	user              string // whose selections the page shows and saves on a shared machine; "" = the config's own
+	localManifestPath string // overrides default; used by tests
+	usagePath         string // overrides default; used by tests
+	lastSyncPath      string // overrides default; used by tests
 	server            *http.Server
-	done              chan struct{}         // closed when Save & Exit is clicked
-	shutdown          chan struct{}         // closed just before server.Shutdown in all exit paths
+	done              chan struct{} // closed when Save & Exit is clicked
+	shutdown          chan struct{} // closed just before server.Shutdown in all exit paths
 	exitOnce          sync.Once
 
-	client     storage.Backend   // for sync operations
-	syncMu     sync.Mutex       // guards sync state below
-	syncLog    *eventLog        // nil when idle
-	syncDone   chan struct{}     // closed when sync goroutine finishes
-	syncResult *intsync.Result  // set when sync finishes
+	client  storage.Backend // for sync operations
+	jobs    jobs.Manager    // runs sync and verify, one at a time
+	startMu sync.Mutex      // held while checking for and starting a job
+
+	syncProgress atomic.Pointer[progress.Reporter] // the latest sync's, for the time left in /api/sync/status
+
+	// revision counts changes to the saved selections, so a save from a
+	// window that loaded them before another window's save is refused
+	// rather than silently undoing it. Guarded by startMu.
+	revision int
+
+	csrfToken string // required on requests that change anything; embedded in the page
+
+	// libraryMu guards library state below. Taken after startMu when
+	// both are needed; handlers holding neither copy what they need.
+	libraryMu      sync.Mutex
+	groups         []*systemGroup
+	remoteManifest *manifest.Manifest // for sync status diff
+	libraryVersion string             // storage.ManifestVersion of remoteManifest
+	libraryChange  libraryJSON        // what the last refresh changed
+}
+
+// libraryJSON reports whether the remote library changed since the page
+// loaded. Counts describe the most recent change.
+type libraryJSON struct {
+	Version  string `json:"version"`
+	Changed  bool   `json:"changed"`
+	Added    int    `json:"added"`
+	Modified int    `json:"modified"`
+	Removed  int    `json:"removed"`
+	Error    string `json:"error,omitempty"`
 }
 
 type systemJSON struct {
 	Dir                string     `json:"dir"`
+	Name               string     `json:"name"`
+	Icon               string     `json:"icon,omitempty"`
 	TotalSize          int64      `json:"totalSize"`
 	TotalSizeFormatted string     `json:"totalSizeFormatted"`
 	State              string     `json:"state"`
 	SelectedCount      int        `json:"selectedCount"`
 	FileCount          int        `json:"fileCount"`
+	PresentCount       int        `json:"presentCount"`
 	Files              []fileJSON `json:"files"`
 }
 
@@ -103,13 +117,32 @@ type fileJSON struct {
 	Size          int64  `json:"size"`
 	SizeFormatted string `json:"sizeFormatted"`
 	Selected      bool   `json:"selected"`
+	Present       bool   `json:"present"`
+}
+
+// treeJSON is a directory in a system's file tree, with selection totals
+// covering everything beneath it.
+type treeJSON struct {
+	Name               string     `json:"name"`
+	Path               string     `json:"path"`
+	State              string     `json:"state"`
+	FileCount          int        `json:"fileCount"`
+	SelectedCount      int        `json:"selectedCount"`
+	PresentCount       int        `json:"presentCount"`
+	TotalSize          int64      `json:"totalSize"`
+	TotalSizeFormatted string     `json:"totalSizeFormatted"`
+	Dirs               []treeJSON `json:"dirs"`
+	Files              []fileJSON `json:"files"`
 }
 
 type syncStatusJSON struct {
-	New       int `json:"new"`
-	Updated   int `json:"updated"`
-	Removed   int `json:"removed"`
-	Unchanged int `json:"unchanged"`
+	New                   int    `json:"new"`
+	Updated               int    `json:"updated"`
+	Removed               int    `json:"removed"`
+	Unchanged             int    `json:"unchanged"`
+	DownloadSize          int64  `json:"downloadSize"`
+	DownloadSizeFormatted string `json:"downloadSizeFormatted"`
+	EstimatedCost         string `json:"estimatedCost,omitempty"`
 }
 
 type systemsResponse struct {
@@ -120,74 +153,319 @@ type systemsResponse struct {
 	SelectedSizeFormatted string          `json:"selectedSizeFormatted"`
 	Delete                bool            `json:"delete"`
 	SyncStatus            *syncStatusJSON `json:"syncStatus,omitempty"`
+	Units                 string          `json:"units"`              // units.IEC or units.SI
+	Revision              int             `json:"revision,omitempty"` // web UI only; see webServer.revision
+	User                  string          `json:"user,omitempty"`     // whose selections these are on a shared machine
+	Users                 []string        `json:"users,omitempty"`    // users whose selections the page can switch to
+}
+
+type userRequest struct {
+	User string `json:"user"`
 }
 
 type saveRequest struct {
 	Selections map[string]bool `json:"selections"`
 	Exit       bool            `json:"exit"`
 	Delete     *bool           `json:"delete,omitempty"`
+	Revision   *int            `json:"revision,omitempty"` // revision the selections were loaded at; omitted by scripts
 }
 
 type saveResponse struct {
-	OK         bool   `json:"ok"`
-	ConfigPath string `json:"configPath,omitempty"`
-	Error      string `json:"error,omitempty"`
+	OK         bool     `json:"ok"`
+	ConfigPath string   `json:"configPath,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"` // stale or redundant sync_dirs/sync_exclude entries
+	Revision   int      `json:"revision,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// staleResponse is sent with 409 Conflict when a save or sync was based
+// on selections another window has since changed. State is the current
+// selections, so the page can show them before the user retries.
+type staleResponse struct {
+	Error string          `json:"error"`
+	State systemsResponse `json:"state"`
 }
 
+// csrfMeta is the placeholder in index.html that handleIndex fills in
+// with the session's CSRF token.
+const csrfMeta = `<meta name="emu-sync-csrf" content="">`
+
+// csrfHeader carries the CSRF token on mutating requests.
+const csrfHeader = "X-CSRF-Token"
+
 func (ws *webServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 	data, _ := webAssets.ReadFile("web_assets/index.html")
+	data = bytes.Replace(data, []byte(csrfMeta), []byte(`<meta name="emu-sync-csrf" content="`+ws.csrfToken+`">`), 1)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write(data)
 }
 
+// i18nResponse is the web UI's messages in Lang, keyed by message ID.
+type i18nResponse struct {
+	Lang     string            `json:"lang"`
+	Messages map[string]string `json:"messages"`
+}
+
+// handleI18n serves /api/i18n/<lang>.json, the web UI's messages for a
+// BCP 47 language tag such as de or pt-BR. See loadMessages.
+func (ws *webServer) handleI18n(w http.ResponseWriter, r *http.Request) {
+	tag, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/i18n/"), ".json")
+	if !ok || !validLangTag(tag) {
+		http.NotFound(w, r)
+		return
+	}
+	lang, messages, err := loadMessages(tag)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i18nResponse{Lang: lang, Messages: messages})
+}
+
+// loadMessages returns the web UI's messages in the translation that
+// best matches tag: its own (pt-br), then its base language's (pt), then
+// English. Messages the translation lacks are filled in from English.
+func loadMessages(tag string) (lang string, messages map[string]string, err error) {
+	messages, err = readMessages("en")
+	if err != nil {
+		return "", nil, err
+	}
+	tag = strings.ToLower(tag)
+	base, _, _ := strings.Cut(tag, "-")
+	for _, name := range []string{tag, base} {
+		translated, err := readMessages(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		maps.Copy(messages, translated)
+		return name, messages, nil
+	}
+	return "en", messages, nil
+}
+
+// readMessages reads the embedded translation for lang.
+func readMessages(lang string) (map[string]string, error) {
+	data, err := webAssets.ReadFile("web_assets/i18n/" + lang + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("parsing %s messages: %w", lang, err)
+	}
+	return messages, nil
+}
+
+// validLangTag reports whether tag looks like a language tag: letters,
+// digits, and hyphens, starting with a letter.
+func validLangTag(tag string) bool {
+	if tag == "" || len(tag) > 35 {
+		return false
+	}
+	for i, c := range tag {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newCSRFToken returns a random token for one run of the web server.
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// guard protects the API from other web pages. The server only listens
+// on loopback, but any page the browser opens can still send it
+// requests, and a page on a domain rebound to 127.0.0.1 can read the
+// responses too. So every request must name a loopback Host, and every
+// request that changes anything must come from this origin and carry
+// the token embedded in the page, which other origins can't read.
+func (ws *webServer) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if host != "127.0.0.1" && host != "localhost" {
+			http.Error(w, "unexpected Host header", http.StatusMisdirectedRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+				http.Error(w, "cross-origin request refused", http.StatusForbidden)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(ws.csrfToken)) != 1 {
+				http.Error(w, "missing or invalid CSRF token; reload the page", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (ws *webServer) handleSystems(w http.ResponseWriter, r *http.Request) {
+	ws.startMu.Lock()
+	revision := ws.revision
+	ws.startMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ws.systemsState(revision))
+}
+
+// systemsState is the /api/systems response for the selections at
+// revision.
+func (ws *webServer) systemsState(revision int) systemsResponse {
+	ws.libraryMu.Lock()
+	defer ws.libraryMu.Unlock()
+
+	// Refresh download state; a sync may have run since the last request
+	if ws.remoteManifest != nil {
+		markPresent(ws.groups, ws.remoteManifest, loadLocalManifest(ws.localManifestPath))
+	}
+
+	resp := newSystemsResponse(ws.groups)
+	resp.Delete = ws.cfg.Sync.Delete
+	resp.Revision = revision
+	resp.User = ws.user
+	resp.Users = slices.Sorted(maps.Keys(ws.cfg.Users))
+
+	// Compute sync status if we have a remote manifest
+	if ws.remoteManifest != nil {
+		resp.SyncStatus = ws.computeSyncStatus()
+	}
+	return resp
+}
+
+// checkRevision reports whether req was based on the current selections.
+// If not, it writes a 409 with the current ones. Requests without a
+// revision always pass. Called with startMu held.
+func (ws *webServer) checkRevision(w http.ResponseWriter, req saveRequest) bool {
+	if req.Revision == nil || *req.Revision == ws.revision {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(staleResponse{
+		Error: "selections were changed in another window; review them and try again",
+		State: ws.systemsState(ws.revision),
+	})
+	return false
+}
+
+// newSystemsResponse converts groups and their selection state to JSON
+// form. Used by the web UI and by choose --list --json.
+func newSystemsResponse(groups []*systemGroup) systemsResponse {
 	var totalSize, selectedSize int64
-	sysList := make([]systemJSON, 0, len(ws.groups))
+	sysList := make([]systemJSON, 0, len(groups))
 
-	for _, g := range ws.groups {
+	for _, g := range groups {
 		files := make([]fileJSON, 0, len(g.Files))
-		for _, f := range g.Files {
-			files = append(files, fileJSON{
-				Key:           f.Key,
-				Name:          f.Name,
-				Size:          f.Size,
-				SizeFormatted: formatSize(f.Size),
-				Selected:      f.Selected,
-			})
+		for i := range g.Files {
+			files = append(files, newFileJSON(&g.Files[i]))
 		}
 		totalSize += g.TotalSize
 		selectedSize += g.selectedSize()
 		sysList = append(sysList, systemJSON{
 			Dir:                g.Dir,
+			Name:               g.label(),
+			Icon:               g.Icon,
 			TotalSize:          g.TotalSize,
 			TotalSizeFormatted: formatSize(g.TotalSize),
 			State:              g.groupState(),
 			SelectedCount:      g.selectedCount(),
 			FileCount:          len(g.Files),
+			PresentCount:       g.presentCount(),
 			Files:              files,
 		})
 	}
 
-	resp := systemsResponse{
+	return systemsResponse{
 		Systems:               sysList,
 		TotalSize:             totalSize,
 		TotalSizeFormatted:    formatSize(totalSize),
 		SelectedSize:          selectedSize,
 		SelectedSizeFormatted: formatSize(selectedSize),
-		Delete:                ws.cfg.Sync.Delete,
+		Units:                 units.System(),
 	}
+}
 
-	// Compute sync status if we have a remote manifest
+func newFileJSON(f *fileInfo) fileJSON {
+	return fileJSON{
+		Key:           f.Key,
+		Name:          f.Name,
+		Size:          f.Size,
+		SizeFormatted: formatSize(f.Size),
+		Selected:      f.Selected,
+		Present:       f.Present,
+	}
+}
+
+// handleSystemTree serves /api/systems/{dir}/tree: the directory tree of a
+// system, or of any directory within one, at full depth.
+func (ws *webServer) handleSystemTree(w http.ResponseWriter, r *http.Request) {
+	dir, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/systems/"), "/tree")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	ws.libraryMu.Lock()
+	defer ws.libraryMu.Unlock()
 	if ws.remoteManifest != nil {
-		resp.SyncStatus = ws.computeSyncStatus()
+		markPresent(ws.groups, ws.remoteManifest, loadLocalManifest(ws.localManifestPath))
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	for _, g := range ws.groups {
+		if dir != g.Dir && !strings.HasPrefix(dir, g.Dir+"/") {
+			continue
+		}
+		if n := buildTree(g).find(dir); n != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(newTreeJSON(n))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func newTreeJSON(n *dirNode) treeJSON {
+	t := treeJSON{
+		Name:               n.Name,
+		Path:               n.Path,
+		State:              n.groupState(),
+		FileCount:          n.fileCount(),
+		SelectedCount:      n.selectedCount(),
+		PresentCount:       n.presentCount(),
+		TotalSize:          n.totalSize(),
+		TotalSizeFormatted: formatSize(n.totalSize()),
+		Dirs:               make([]treeJSON, 0, len(n.Dirs)),
+		Files:              make([]fileJSON, 0, len(n.Files)),
+	}
+	for _, d := range n.Dirs {
+		t.Dirs = append(t.Dirs, newTreeJSON(d))
+	}
+	for _, f := range n.Files {
+		t.Files = append(t.Files, newFileJSON(f))
+	}
+	return t
 }
 
 // computeSyncStatus diffs the remote manifest against the local manifest
 // and returns counts filtered to only files the config would sync.
+// Called with libraryMu held.
 func (ws *webServer) computeSyncStatus() *syncStatusJSON {
 	localPath := ws.localManifestPath
 	if localPath == "" {
@@ -196,52 +474,49 @@ func (ws *webServer) computeSyncStatus() *syncStatusJSON {
 	local, err := manifest.LoadJSON(localPath)
 	if err != nil {
 		// No local manifest = first sync, everything is new
-		newCount := 0
-		for key := range ws.remoteManifest.Files {
+		var status syncStatusJSON
+		for key, entry := range ws.remoteManifest.Files {
 			if ws.cfg.ShouldSync(key) {
-				newCount++
+				status.New++
+				status.DownloadSize += entry.Size
 			}
 		}
-		return &syncStatusJSON{New: newCount}
+		ws.setDownloadEstimate(&status)
+		return &status
 	}
 
-	diff := manifest.Diff(ws.remoteManifest, local)
-
 	var status syncStatusJSON
-	for _, key := range diff.Added {
-		if ws.cfg.ShouldSync(key) {
-			status.New++
-		}
-	}
-	for _, key := range diff.Modified {
-		if ws.cfg.ShouldSync(key) {
-			status.Updated++
-		}
-	}
-	for _, key := range diff.Deleted {
-		if ws.cfg.ShouldSync(key) {
-			status.Removed++
-		}
-	}
-
-	// Count unchanged selected files
-	for key := range ws.remoteManifest.Files {
+	for key, c := range manifest.Changes(ws.remoteManifest, local) {
 		if !ws.cfg.ShouldSync(key) {
 			continue
 		}
-		localEntry, exists := local.Files[key]
-		if !exists {
-			continue
-		}
-		remoteEntry := ws.remoteManifest.Files[key]
-		if localEntry.MD5 == remoteEntry.MD5 && localEntry.Size == remoteEntry.Size {
+		switch c {
+		case manifest.Added:
+			status.New++
+			status.DownloadSize += ws.remoteManifest.Files[key].Size
+		case manifest.Modified:
+			status.Updated++
+			status.DownloadSize += ws.remoteManifest.Files[key].Size
+		case manifest.Deleted:
+			status.Removed++
+		case manifest.Unchanged:
 			status.Unchanged++
 		}
 	}
 
+	ws.setDownloadEstimate(&status)
 	return &status
 }
 
+// setDownloadEstimate fills in the formatted download size and, if
+// provider pricing is configured, the estimated egress cost.
+func (ws *webServer) setDownloadEstimate(status *syncStatusJSON) {
+	status.DownloadSizeFormatted = formatSize(status.DownloadSize)
+	if cost := ws.cfg.Storage.Cost; cost.Enabled() {
+		status.EstimatedCost = units.FormatCost(cost.Estimate(0, status.DownloadSize))
+	}
+}
+
 func (ws *webServer) handleSave(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", "POST")
@@ -257,26 +532,43 @@ func (ws *webServer) handleSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ws.applySelections(req.Selections)
-	if req.Delete != nil {
-		ws.cfg.Sync.Delete = *req.Delete
+	ws.startMu.Lock()
+	defer ws.startMu.Unlock()
+	if !ws.checkRevision(w, req) {
+		return
 	}
 
-	if err := config.Write(ws.cfg, ws.cfgPath); err != nil {
+	if err := ws.saveSelections(req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(saveResponse{Error: err.Error()})
 		return
 	}
+	ws.revision++
+	ws.libraryMu.Lock()
+	warnings := selectionWarnings(ws.view(), ws.groups)
+	ws.libraryMu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(saveResponse{OK: true, ConfigPath: ws.cfgPath})
+	json.NewEncoder(w).Encode(saveResponse{OK: true, ConfigPath: ws.cfg.SelectionFile(ws.user, ws.cfgPath), Warnings: warnings, Revision: ws.revision})
 
 	if req.Exit {
 		ws.exitOnce.Do(func() { close(ws.done) })
 	}
 }
 
+// selectFiles marks the files in selections as selected or not, leaving
+// the config alone. Called with libraryMu held.
+func (ws *webServer) selectFiles(selections map[string]bool) {
+	for _, g := range ws.groups {
+		for i := range g.Files {
+			if sel, ok := selections[g.Files[i].Key]; ok {
+				g.Files[i].Selected = sel
+			}
+		}
+	}
+}
+
 func (ws *webServer) handleExit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", "POST")
@@ -296,32 +588,79 @@ func (ws *webServer) handleWait(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// applySelections marks the files in selections and sets the config's
+// selections to match. Called with libraryMu held.
 func (ws *webServer) applySelections(selections map[string]bool) {
-	for _, g := range ws.groups {
-		for i := range g.Files {
-			if sel, ok := selections[g.Files[i].Key]; ok {
-				g.Files[i].Selected = sel
+	ws.selectFiles(selections)
+	syncDirs, syncExclude := encodeSelections(ws.groups)
+	ws.cfg.SetSelection(ws.user, syncDirs, syncExclude)
+}
+
+// saveSelections applies and saves the selections and delete setting in
+// req. On a shared machine the selections are ws.user's, saved to their
+// selection file, and the shared config is only written if the delete
+// setting changed. Called with startMu held.
+func (ws *webServer) saveSelections(req saveRequest) error {
+	ws.libraryMu.Lock()
+	defer ws.libraryMu.Unlock()
+	ws.applySelections(req.Selections)
+	if req.Delete != nil && *req.Delete != ws.cfg.Sync.Delete {
+		ws.cfg.Sync.Delete = *req.Delete
+		if ws.user != "" {
+			if err := config.Write(ws.cfg, ws.cfgPath); err != nil {
+				return err
 			}
 		}
 	}
-	syncDirs, syncExclude := encodeSelections(ws.groups)
-	ws.cfg.Sync.SyncDirs = syncDirs
-	ws.cfg.Sync.SyncExclude = syncExclude
+	return ws.cfg.SaveSelection(ws.user, ws.cfgPath)
 }
 
-func (ws *webServer) runSync() {
-	log := ws.syncLog
-	defer func() {
-		log.finish()
-		close(ws.syncDone)
-	}()
+// view is the config as the page shows it: on a shared machine, with
+// only ws.user's selections (see config.ForUser).
+func (ws *webServer) view() *config.Config {
+	return ws.cfg.ForUser(ws.user)
+}
 
-	if err := ws.cfg.ValidateEmulationPath(); err != nil {
-		ws.syncMu.Lock()
-		ws.syncResult = &intsync.Result{Errors: []error{err}}
-		ws.syncMu.Unlock()
+// handleUser switches the page to another user's selections on a shared
+// machine and responds with them as /api/systems would.
+func (ws *webServer) handleUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ws.startMu.Lock()
+	defer ws.startMu.Unlock()
+	if _, ok := ws.cfg.Users[req.User]; !ok {
+		http.Error(w, fmt.Sprintf("no selections for user %q", req.User), http.StatusNotFound)
 		return
 	}
+	ws.libraryMu.Lock()
+	ws.user = req.User
+	if ws.remoteManifest != nil {
+		ws.groups = buildGroups(ws.remoteManifest, ws.view())
+	}
+	ws.libraryMu.Unlock()
+	// Saves from windows showing the previous user's selections would
+	// overwrite this user's with them.
+	ws.revision++
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ws.systemsState(ws.revision))
+}
+
+// syncJob runs a sync as a job. It always returns an *intsync.Result,
+// wrapping err when the sync couldn't start.
+func (ws *webServer) syncJob(ctx context.Context, log io.Writer) (any, error) {
+	if err := ws.cfg.ValidateEmulationPath(); err != nil {
+		return &intsync.Result{Errors: []error{err}}, err
+	}
 
 	workers := ws.cfg.Sync.Workers
 	if workers == 0 {
@@ -333,10 +672,13 @@ func (ws *webServer) runSync() {
 	}
 
 	opts := intsync.Options{
-		Workers:    workers,
-		MaxRetries: maxRetries,
-		Progress:   progress.NewReporterWriter(log),
+		Workers:         workers,
+		MaxRetries:      maxRetries,
+		DeleteThreshold: ws.cfg.SyncDeleteThreshold(),
+		Progress:        progress.NewReporterWriter(log),
 	}
+	attachWebhook(opts.Progress, ws.cfg)
+	ws.syncProgress.Store(opts.Progress)
 
 	if ws.cfg.Sync.SaveThreshold != "" {
 		bytes, err := config.ParseBandwidthLimit(ws.cfg.Sync.SaveThreshold)
@@ -345,15 +687,23 @@ func (ws *webServer) runSync() {
 		}
 	}
 
-	result, err := intsync.Run(context.Background(), ws.client, ws.cfg, opts)
+	if ws.cfg.Sync.MaxDuration != "" {
+		d, err := time.ParseDuration(ws.cfg.Sync.MaxDuration)
+		if err == nil && d > 0 {
+			opts.MaxDuration = d
+		}
+	}
 
-	ws.syncMu.Lock()
+	result, err := intsync.Run(ctx, ws.client, ws.cfg, opts)
+	if !errors.Is(err, intsync.ErrLocked) {
+		saveLastSync(ws.lastSyncPath, result, err)
+	}
 	if result != nil {
-		ws.syncResult = result
+		recordUsage(ws.usagePath, 0, result.Bytes)
 	} else {
-		ws.syncResult = &intsync.Result{Errors: []error{err}}
+		result = &intsync.Result{Errors: []error{err}}
 	}
-	ws.syncMu.Unlock()
+	return result, err
 }
 
 func (ws *webServer) handleSync(w http.ResponseWriter, r *http.Request) {
@@ -363,64 +713,123 @@ func (ws *webServer) handleSync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ws.syncMu.Lock()
-	if ws.syncLog != nil {
-		// Check if previous sync is still running
-		select {
-		case <-ws.syncDone:
-			// Previous sync finished, allow a new one
-		default:
-			ws.syncMu.Unlock()
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{"error": "sync already running"})
-			return
-		}
+	// Held until the job starts, so selections are only saved when the
+	// sync will actually run.
+	ws.startMu.Lock()
+	defer ws.startMu.Unlock()
+	if job := ws.jobs.Running(); job != nil {
+		writeBusy(w, job.Kind)
+		return
 	}
 
 	// Auto-save selections before starting sync
 	var req saveRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		ws.syncMu.Unlock()
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request body"})
 		return
 	}
 
-	ws.applySelections(req.Selections)
-	if req.Delete != nil {
-		ws.cfg.Sync.Delete = *req.Delete
+	if r.URL.Query().Get("dry_run") == "true" {
+		ws.startPreview(w, req)
+		return
+	}
+	if !ws.checkRevision(w, req) {
+		return
 	}
-	if err := config.Write(ws.cfg, ws.cfgPath); err != nil {
-		ws.syncMu.Unlock()
+
+	if err := ws.saveSelections(req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
+	ws.revision++
 
-	ws.syncLog = newEventLog()
-	ws.syncDone = make(chan struct{})
-	ws.syncResult = nil
-	ws.syncMu.Unlock()
-
-	go ws.runSync()
+	job, err := ws.jobs.Start(jobSync, ws.syncJob)
+	if err != nil {
+		writeBusy(w, jobSync)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "id": job.ID, "revision": ws.revision})
 
 	if req.Exit {
 		ws.exitOnce.Do(func() { close(ws.done) })
 	}
 }
 
+// startPreview starts a dry-run sync of the selections in req, for
+// /api/sync?dry_run=true. Nothing is saved: the config is copied with the
+// selections applied, and the preview reports what a sync of it would
+// download and delete as would_download and would_delete events on
+// /api/jobs/{id}/events. The plan isn't kept: the sync that follows
+// computes its own, since the bucket or local files may have changed in
+// between. Called with startMu held.
+func (ws *webServer) startPreview(w http.ResponseWriter, req saveRequest) {
+	ws.libraryMu.Lock()
+	ws.selectFiles(req.Selections)
+	preview := *ws.cfg
+	syncDirs, syncExclude := encodeSelections(ws.groups)
+	ws.libraryMu.Unlock()
+	preview.SetSelection(ws.user, syncDirs, syncExclude)
+	if req.Delete != nil {
+		preview.Sync.Delete = *req.Delete
+	}
+
+	job, err := ws.jobs.Start(jobPreview, func(ctx context.Context, log io.Writer) (any, error) {
+		return ws.previewJob(ctx, log, &preview)
+	})
+	if err != nil {
+		writeBusy(w, jobPreview)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "id": job.ID})
+}
+
+// previewJob runs a dry-run sync of cfg. Unlike syncJob it records
+// neither the last sync nor bandwidth usage, since nothing transfers.
+func (ws *webServer) previewJob(ctx context.Context, log io.Writer, cfg *config.Config) (any, error) {
+	if err := cfg.ValidateEmulationPath(); err != nil {
+		return &intsync.Result{Errors: []error{err}}, err
+	}
+	opts, err := syncOptions(cfg, 1)
+	if err != nil {
+		return &intsync.Result{Errors: []error{err}}, err
+	}
+	opts.DryRun = true
+	opts.LocalManifestPath = ws.localManifestPath
+	opts.Progress = progress.NewReporterWriter(log)
+
+	result, err := intsync.Run(ctx, ws.client, cfg, opts)
+	if result == nil {
+		result = &intsync.Result{Errors: []error{err}}
+	}
+	return result, err
+}
+
+// writeBusy answers 409 because a job of kind is already running.
+func writeBusy(w http.ResponseWriter, kind string) {
+	msg := kind + " is running"
+	if kind == jobSync {
+		msg = "sync already running"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
 func (ws *webServer) handleSyncEvents(w http.ResponseWriter, r *http.Request) {
-	ws.syncMu.Lock()
-	log := ws.syncLog
-	ws.syncMu.Unlock()
+	ws.streamEvents(w, r, ws.jobs.Latest(jobSync))
+}
 
-	if log == nil {
+// streamEvents sends job's progress lines as server-sent events until it
+// finishes, resuming after Last-Event-ID when the browser reconnects.
+func (ws *webServer) streamEvents(w http.ResponseWriter, r *http.Request, job *jobs.Job) {
+	if job == nil {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -443,7 +852,7 @@ func (ws *webServer) handleSyncEvents(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for {
-		lines, done := log.read(cursor)
+		lines, done := job.Log.Read(cursor)
 		for i, line := range lines {
 			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", cursor+i, line)
 		}
@@ -455,7 +864,7 @@ func (ws *webServer) handleSyncEvents(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		select {
-		case <-log.notify:
+		case <-job.Log.Notify():
 		case <-r.Context().Done():
 			return
 		case <-ws.shutdown:
@@ -465,35 +874,69 @@ func (ws *webServer) handleSyncEvents(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ws *webServer) handleSyncStatus(w http.ResponseWriter, r *http.Request) {
-	ws.syncMu.Lock()
-	log := ws.syncLog
-	result := ws.syncResult
-	ws.syncMu.Unlock()
-
+	job := ws.jobs.Latest(jobSync)
 	resp := map[string]interface{}{}
 
-	if log == nil {
+	if job == nil {
 		resp["state"] = "idle"
-	} else if result == nil {
-		resp["state"] = "running"
+		path := ws.lastSyncPath
+		if path == "" {
+			path = config.DefaultLastSyncPath()
+		}
+		if last, err := intsync.LoadLastRun(path); err == nil {
+			resp["last"] = last
+		}
 	} else {
-		if len(result.Errors) > 0 {
+		resp["id"] = job.ID
+		info := job.Info()
+		value, _ := job.Result()
+		result, _ := value.(*intsync.Result)
+		switch {
+		case info.State == jobs.Running:
+			resp["state"] = "running"
+			if rep := ws.syncProgress.Load(); rep != nil {
+				est := rep.Estimate()
+				resp["remaining_files"] = est.Files
+				resp["remaining_bytes"] = est.Bytes
+				if est.Rate > 0 {
+					resp["eta_seconds"] = est.Left.Round(time.Second).Seconds()
+				}
+			}
+		case info.State == jobs.Canceled:
+			resp["state"] = "canceled"
+		case result != nil && len(result.Errors) > 0:
 			resp["state"] = "failed"
-		} else {
+		default:
 			resp["state"] = "complete"
 		}
-		resp["downloaded"] = len(result.Downloaded)
-		resp["deleted"] = len(result.Deleted)
-		resp["retained"] = len(result.Retained)
-		resp["skipped"] = result.Skipped
-		resp["errors"] = len(result.Errors)
-		resp["summary"] = result.Summary()
+		if result != nil {
+			resp["downloaded"] = len(result.Downloaded)
+			resp["deleted"] = len(result.Deleted)
+			resp["retained"] = len(result.Retained)
+			resp["skipped"] = result.Skipped
+			resp["errors"] = len(result.Errors)
+			resp["summary"] = result.Summary()
+			if len(result.Transfers) > 0 {
+				resp["throughput"] = result.Throughput()
+				resp["slowest"] = result.Slowest(3)
+			}
+			if len(result.Errors) > 0 {
+				resp["failures"] = syncerr.JSONList(result.Errors)
+				resp["error_codes"] = syncerr.Counts(result.Errors)
+			}
+			if len(result.Warnings) > 0 {
+				resp["warnings"] = result.Warnings
+			}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleVerify starts re-hashing local files in the background, like
+// handleSync. Progress streams from /api/verify/events and the result
+// is read from /api/verify/status.
 func (ws *webServer) handleVerify(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.Header().Set("Allow", "POST")
@@ -501,21 +944,10 @@ func (ws *webServer) handleVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ws.syncMu.Lock()
-	running := ws.syncLog != nil
-	if running {
-		select {
-		case <-ws.syncDone:
-			running = false
-		default:
-		}
-	}
-	ws.syncMu.Unlock()
-
-	if running {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(map[string]string{"error": "sync is running"})
+	ws.startMu.Lock()
+	defer ws.startMu.Unlock()
+	if job := ws.jobs.Running(); job != nil {
+		writeBusy(w, job.Kind)
 		return
 	}
 
@@ -526,42 +958,818 @@ func (ws *webServer) handleVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := intsync.Verify(ws.cfg, ws.localManifestPath, false)
-	resp := map[string]interface{}{}
+	job, err := ws.jobs.Start(jobVerify, ws.verifyJob)
 	if err != nil {
-		resp["error"] = err.Error()
-	} else {
-		resp["ok"] = len(result.OK)
-		resp["mismatch"] = len(result.Mismatch)
-		resp["missing"] = len(result.Missing)
-		resp["errors"] = len(result.Errors)
-		resp["summary"] = result.Summary()
-		if len(result.Mismatch) > 0 {
-			resp["mismatch_files"] = result.Mismatch
-		}
-		if len(result.Missing) > 0 {
-			resp["missing_files"] = result.Missing
-		}
-		if len(result.Errors) > 0 {
-			errStrs := make([]string, len(result.Errors))
-			for i, e := range result.Errors {
-				errStrs[i] = e.Error()
-			}
-			resp["error_details"] = errStrs
-		}
+		writeBusy(w, jobVerify)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "id": job.ID})
 }
 
-func openBrowser(url string) {
-	var cmd string
-	var args []string
-	switch runtime.GOOS {
-	case "darwin":
-		cmd = "open"
-	case "windows":
+// verifyJob runs a verify as a job, ending its events with "done".
+func (ws *webServer) verifyJob(ctx context.Context, log io.Writer) (any, error) {
+	prog := progress.NewReporterWriter(log)
+	result, err := intsync.Verify(ctx, ws.cfg, ws.localManifestPath, prog)
+	errs := 1
+	if err == nil {
+		errs = len(result.Errors)
+	}
+	prog.Done(progress.Summary{Errors: errs})
+	return result, err
+}
+
+func (ws *webServer) handleVerifyEvents(w http.ResponseWriter, r *http.Request) {
+	ws.streamEvents(w, r, ws.jobs.Latest(jobVerify))
+}
+
+func (ws *webServer) handleVerifyStatus(w http.ResponseWriter, r *http.Request) {
+	job := ws.jobs.Latest(jobVerify)
+	resp := map[string]interface{}{}
+
+	if job == nil {
+		resp["state"] = "idle"
+	} else {
+		resp["id"] = job.ID
+		info := job.Info()
+		value, _ := job.Result()
+		result, _ := value.(*intsync.VerifyResult)
+		switch info.State {
+		case jobs.Running:
+			resp["state"] = "running"
+		case jobs.Canceled:
+			resp["state"] = "canceled"
+		case jobs.Failed:
+			resp["state"] = "failed"
+			resp["error"] = info.Error
+		default:
+			resp["state"] = "complete"
+		}
+		if result != nil && info.State == jobs.Complete {
+			resp["ok"] = len(result.OK)
+			resp["mismatch"] = len(result.Mismatch)
+			resp["missing"] = len(result.Missing)
+			resp["errors"] = len(result.Errors)
+			resp["summary"] = result.Summary()
+			if len(result.Mismatch) > 0 {
+				resp["mismatch_files"] = result.Mismatch
+			}
+			if len(result.Missing) > 0 {
+				resp["missing_files"] = result.Missing
+			}
+			if len(result.Errors) > 0 {
+				errStrs := make([]string, len(result.Errors))
+				for i, e := range result.Errors {
+					errStrs[i] = e.Error()
+				}
+				resp["error_details"] = errStrs
+				resp["failures"] = syncerr.JSONList(result.Errors)
+				resp["error_codes"] = syncerr.Counts(result.Errors)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// jobJSON is a job's status for /api/jobs, with the summary its result
+// provides once it finishes.
+type jobJSON struct {
+	jobs.Info
+	Summary string `json:"summary,omitempty"`
+}
+
+func newJobJSON(job *jobs.Job) jobJSON {
+	j := jobJSON{Info: job.Info()}
+	if result, _ := job.Result(); result != nil {
+		if s, ok := result.(interface{ Summary() string }); ok {
+			j.Summary = s.Summary()
+		}
+	}
+	return j
+}
+
+// handleJobs serves the background job API:
+//
+//	GET  /api/jobs              recent jobs, newest first
+//	GET  /api/jobs/{id}         one job's status
+//	GET  /api/jobs/{id}/events  its progress as server-sent events
+//	POST /api/jobs/{id}/cancel  ask it to stop
+func (ws *webServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs"), "/")
+	if rest == "" {
+		infos := ws.jobs.List()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jobs": infos})
+		return
+	}
+
+	id, action, _ := strings.Cut(rest, "/")
+	job := ws.jobs.Get(id)
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch action {
+	case "":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newJobJSON(job))
+	case "events":
+		ws.streamEvents(w, r, job)
+	case "cancel":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		job.Cancel()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+type usageMonthJSON struct {
+	Month               string `json:"month"`
+	Uploaded            int64  `json:"uploaded"`
+	UploadedFormatted   string `json:"uploadedFormatted"`
+	Downloaded          int64  `json:"downloaded"`
+	DownloadedFormatted string `json:"downloadedFormatted"`
+	Runs                int    `json:"runs"`
+	EstimatedCost       string `json:"estimatedCost,omitempty"`
+}
+
+type statsResponse struct {
+	CurrentMonth usageMonthJSON   `json:"currentMonth"`
+	Months       []usageMonthJSON `json:"months"`
+	Connection   *connectionJSON  `json:"connection,omitempty"`
+}
+
+// connectionJSON reports a connection check, requested with ?ping=1.
+type connectionJSON struct {
+	LatencyMs           int64  `json:"latencyMs,omitempty"`
+	Throughput          int64  `json:"throughput,omitempty"` // bytes/sec
+	ThroughputFormatted string `json:"throughputFormatted,omitempty"`
+	SampleBytes         int64  `json:"sampleBytes,omitempty"`
+	Hint                string `json:"hint,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+// checkConnection measures latency and throughput to the bucket.
+func (ws *webServer) checkConnection(ctx context.Context) *connectionJSON {
+	key := ""
+	ws.libraryMu.Lock()
+	if ws.remoteManifest != nil {
+		key = healthSampleKey(ws.remoteManifest)
+	}
+	ws.libraryMu.Unlock()
+	h, err := storage.CheckHealth(ctx, ws.client, key)
+	if err != nil {
+		return &connectionJSON{Error: err.Error()}
+	}
+	return &connectionJSON{
+		LatencyMs:           h.FirstByte.Milliseconds(),
+		Throughput:          int64(h.Throughput),
+		ThroughputFormatted: formatSize(int64(h.Throughput)) + "/s",
+		SampleBytes:         h.Bytes,
+		Hint:                h.Hint(),
+	}
+}
+
+func (ws *webServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	path := ws.usagePath
+	if path == "" {
+		path = config.DefaultUsagePath()
+	}
+	stats, err := usage.Load(path)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	toJSON := func(key string) usageMonthJSON {
+		m := stats.Get(key)
+		mj := usageMonthJSON{
+			Month:               key,
+			Uploaded:            m.Uploaded,
+			UploadedFormatted:   formatSize(m.Uploaded),
+			Downloaded:          m.Downloaded,
+			DownloadedFormatted: formatSize(m.Downloaded),
+			Runs:                m.Runs,
+		}
+		if ws.cfg != nil && ws.cfg.Storage.Cost.Enabled() {
+			mj.EstimatedCost = units.FormatCost(ws.cfg.Storage.Cost.Estimate(m.Uploaded, m.Downloaded))
+		}
+		return mj
+	}
+
+	resp := statsResponse{
+		CurrentMonth: toJSON(usage.MonthKey(time.Now())),
+		Months:       []usageMonthJSON{},
+	}
+	for _, key := range stats.Keys() {
+		resp.Months = append(resp.Months, toJSON(key))
+	}
+
+	if r.URL.Query().Get("ping") != "" && ws.client != nil {
+		resp.Connection = ws.checkConnection(r.Context())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// downloadLinkTTL is how long the presigned link /api/download
+// redirects to stays valid; long enough for a slow download to start.
+const downloadLinkTTL = time.Hour
+
+// handleDownload serves /api/download/{key}: one library file, for
+// saving from the browser on a machine without a sync client. The file
+// is streamed through the server, under the config's bandwidth_limit,
+// or with ?redirect=true the browser is sent a presigned link and
+// downloads from the bucket directly. Only files in the library as this
+// device sees it can be downloaded, so [policy] applies.
+func (ws *webServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/api/download/")
+	ws.libraryMu.Lock()
+	var entry manifest.FileEntry
+	ok := false
+	if ws.remoteManifest != nil {
+		entry, ok = ws.remoteManifest.Files[key]
+	}
+	client := ws.client
+	ws.libraryMu.Unlock()
+	if !ok {
+		http.Error(w, "not in the library", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("redirect") == "true" {
+		link, err := client.PresignGet(r.Context(), key, downloadLinkTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Redirect(w, r, link, http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", storage.ContentType(key))
+	w.Header().Set("Content-Disposition", storage.ContentDisposition(key))
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	// Once the body has started, an error can only cut it short; the
+	// browser sees a download shorter than Content-Length and fails it.
+	if err := client.DownloadTo(r.Context(), key, w); err != nil && r.Context().Err() == nil {
+		log.Printf("warning: download of %s failed: %v", key, err)
+	}
+}
+
+// handleLibrary reports whether the remote manifest changed since the
+// version in ?since=, refreshing the server's copy when it has. The page
+// polls this to offer a reload while someone else is uploading.
+func (ws *webServer) handleLibrary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws.libraryMu.Lock()
+	defer ws.libraryMu.Unlock()
+
+	version, err := storage.ManifestVersion(r.Context(), ws.client)
+	if err == nil && version != ws.libraryVersion {
+		err = ws.refreshLibrary(r.Context(), version)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(libraryJSON{Error: err.Error()})
+		return
+	}
+
+	resp := ws.libraryChange
+	resp.Version = ws.libraryVersion
+	since := r.URL.Query().Get("since")
+	resp.Changed = since != "" && since != ws.libraryVersion
+	json.NewEncoder(w).Encode(resp)
+}
+
+// refreshLibrary downloads the remote manifest and rebuilds the systems
+// list from it. Called with libraryMu held.
+func (ws *webServer) refreshLibrary(ctx context.Context, version string) error {
+	data, err := ws.client.DownloadManifest(ctx)
+	if err != nil {
+		return fmt.Errorf("downloading manifest: %w", err)
+	}
+	remote, err := manifest.ParseJSON(data)
+	if err != nil {
+		return fmt.Errorf("parsing manifest: %w", err)
+	}
+	ws.cfg.Policy.Hide(remote)
+
+	prev := ws.remoteManifest
+	if prev == nil {
+		prev = manifest.New()
+	}
+	var change libraryJSON
+	for _, c := range manifest.Changes(remote, prev) {
+		switch c {
+		case manifest.Added:
+			change.Added++
+		case manifest.Modified:
+			change.Modified++
+		case manifest.Deleted:
+			change.Removed++
+		}
+	}
+	groups := buildGroups(remote, ws.view())
+	markPresent(groups, remote, loadLocalManifest(ws.localManifestPath))
+
+	ws.groups = groups
+	ws.remoteManifest = remote
+	ws.libraryVersion = version
+	ws.libraryChange = change
+	return nil
+}
+
+// configPollInterval is how often the web server checks the config file
+// for edits made outside it.
+const configPollInterval = 2 * time.Second
+
+// reloadConfig re-reads the config file and applies it, rebuilding the
+// systems list and, if the storage settings changed, the client. Jobs
+// read the config as they run, so it refuses while one is running with
+// an error wrapping jobs.ErrBusy. changed reports whether the file
+// differed from the config in use.
+func (ws *webServer) reloadConfig() (changed bool, err error) {
+	ws.startMu.Lock()
+	defer ws.startMu.Unlock()
+	if job := ws.jobs.Running(); job != nil {
+		return false, fmt.Errorf("%s is running: %w", job.Kind, jobs.ErrBusy)
+	}
+
+	cfg, err := config.Load(ws.cfgPath)
+	if err != nil {
+		return false, err
+	}
+
+	if reflect.DeepEqual(cfg, ws.cfg) {
+		return false, nil
+	}
+	return true, ws.applyConfig(cfg)
+}
+
+// applyConfig makes cfg the config in use, rebuilding the systems list
+// and, if the storage settings changed, the client. Called with startMu
+// held and no job running.
+func (ws *webServer) applyConfig(cfg *config.Config) error {
+	ws.libraryMu.Lock()
+	defer ws.libraryMu.Unlock()
+	if storageChanged(ws.cfg, cfg) {
+		client, err := newSyncClient(cfg)
+		if err != nil {
+			return err
+		}
+		ws.client = client
+	}
+	if selectionsChanged(ws.cfg, cfg) {
+		ws.revision++
+	}
+	ws.cfg = cfg
+	if _, ok := cfg.Users[ws.user]; !ok {
+		// The user's selection file is gone, or they hadn't saved one.
+		ws.user, _ = cfg.CurrentUser()
+	}
+	units.SetSystem(cfg.Display.Units)
+	if ws.remoteManifest != nil {
+		ws.groups = buildGroups(ws.remoteManifest, ws.view())
+		markPresent(ws.groups, ws.remoteManifest, loadLocalManifest(ws.localManifestPath))
+	}
+	return nil
+}
+
+// selectionsChanged reports whether new selects different files, or
+// deletes differently, than old.
+func selectionsChanged(old, new *config.Config) bool {
+	return !slices.Equal(old.Sync.SyncDirs, new.Sync.SyncDirs) ||
+		!slices.Equal(old.Sync.SyncExclude, new.Sync.SyncExclude) ||
+		!reflect.DeepEqual(old.Users, new.Users) ||
+		old.Sync.Delete != new.Sync.Delete
+}
+
+// storageChanged reports whether a storage client built from old would
+// differ from one built from new.
+func storageChanged(old, new *config.Config) bool {
+	return !reflect.DeepEqual(old.Storage, new.Storage) ||
+		old.Network != new.Network ||
+		old.Sync.BandwidthLimit != new.Sync.BandwidthLimit
+}
+
+// watchConfig reloads the config whenever the file changes on disk,
+// waiting for a running job to finish first.
+func (ws *webServer) watchConfig(ctx context.Context) {
+	config.WatchFile(ctx, ws.cfgPath, configPollInterval, func() error {
+		changed, err := ws.reloadConfig()
+		switch {
+		case errors.Is(err, jobs.ErrBusy):
+			return err
+		case err != nil:
+			fmt.Printf("Config file changed but couldn't be reloaded: %v\n", err)
+		case changed:
+			fmt.Println("Config file changed; reloaded.")
+		}
+		return nil
+	})
+}
+
+// handleConfigReload re-reads the config file on request, for edits the
+// poller hasn't picked up yet.
+func (ws *webServer) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	changed, err := ws.reloadConfig()
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, jobs.ErrBusy) {
+			status = http.StatusConflict
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]bool{"ok": true, "changed": changed})
+}
+
+// handleConfig returns the config as JSON keyed like the TOML file, with
+// secrets masked (GET), or updates it (PUT). A PUT body holds only the
+// settings to change, in the same shape; sending the mask back for a
+// secret keeps it. Credentials, [trigger], and [policy] can't be changed
+// here (see lockedSettings). The result is validated like a loaded config
+// and saved before it's applied.
+func (ws *webServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	writeError := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ws.startMu.Lock()
+		m, err := configMap(ws.cfg)
+		ws.startMu.Unlock()
+		if err != nil {
+			writeError(http.StatusInternalServerError, err)
+			return
+		}
+		maskSecrets(m)
+		json.NewEncoder(w).Encode(m)
+
+	case http.MethodPut:
+		var changes map[string]any
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&changes); err != nil {
+			writeError(http.StatusBadRequest, errors.New("invalid request body"))
+			return
+		}
+		for _, path := range secretSettings {
+			if t, key := settingTable(changes, path); t != nil && t[key] == maskedKey {
+				delete(t, key)
+			}
+		}
+		for _, path := range lockedSettings {
+			if t, key := settingTable(changes, path); t != nil && changesSetting(t, key) {
+				writeError(http.StatusForbidden, fmt.Errorf("%s can't be changed from the web UI", strings.Join(path, ".")))
+				return
+			}
+		}
+
+		ws.startMu.Lock()
+		defer ws.startMu.Unlock()
+		if job := ws.jobs.Running(); job != nil {
+			writeBusy(w, job.Kind)
+			return
+		}
+
+		cfg, err := updateConfig(ws.cfg, changes)
+		if err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+		if err := cfg.ValidateEmulationPath(); err != nil {
+			writeError(http.StatusBadRequest, err)
+			return
+		}
+		if err := config.Write(cfg, ws.cfgPath); err != nil {
+			writeError(http.StatusInternalServerError, err)
+			return
+		}
+		if err := ws.applyConfig(cfg); err != nil {
+			writeError(http.StatusInternalServerError, err)
+			return
+		}
+
+		m, err := configMap(cfg)
+		if err != nil {
+			writeError(http.StatusInternalServerError, err)
+			return
+		}
+		maskSecrets(m)
+		json.NewEncoder(w).Encode(m)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeError(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// configMap converts cfg to nested maps keyed like its TOML file.
+func configMap(cfg *config.Config) (map[string]any, error) {
+	data, err := toml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("serializing config: %w", err)
+	}
+	var m map[string]any
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("serializing config: %w", err)
+	}
+	return m, nil
+}
+
+// secretSettings are masked with maskedKey in /api/config responses.
+var secretSettings = [][]string{
+	{"storage", "secret_key"},
+	{"trigger", "token"},
+}
+
+// lockedSettings are refused by PUT /api/config: anyone who can reach the
+// page could otherwise swap the bucket credentials or trigger token, or
+// lift the [policy] that's meant to come from the setup token.
+var lockedSettings = [][]string{
+	{"storage", "key_id"},
+	{"storage", "secret_key"},
+	{"trigger"},
+	{"policy"},
+}
+
+// settingTable returns the table in m holding the setting at path and
+// the setting's key there, or nil if m has no such table.
+func settingTable(m map[string]any, path []string) (map[string]any, string) {
+	for _, k := range path[:len(path)-1] {
+		sub, ok := m[k].(map[string]any)
+		if !ok {
+			return nil, ""
+		}
+		m = sub
+	}
+	return m, path[len(path)-1]
+}
+
+// changesSetting reports whether key in t, from a PUT body, changes
+// anything: it's present, even as null, and not an empty table such as
+// one left by a dropped mask.
+func changesSetting(t map[string]any, key string) bool {
+	v, ok := t[key]
+	if sub, isTable := v.(map[string]any); isTable {
+		return len(sub) > 0
+	}
+	return ok
+}
+
+// maskSecrets replaces the set secretSettings in a configMap with
+// maskedKey.
+func maskSecrets(m map[string]any) {
+	for _, path := range secretSettings {
+		if t, key := settingTable(m, path); t != nil && t[key] != nil && t[key] != "" {
+			t[key] = maskedKey
+		}
+	}
+}
+
+// updateConfig returns a copy of cfg with changes (decoded with
+// json.Number) merged in and validated. Unknown settings are rejected.
+func updateConfig(cfg *config.Config, changes map[string]any) (*config.Config, error) {
+	m, err := configMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mergeConfigMap(m, changes)
+
+	data, err := toml.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	var updated config.Config
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&updated); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// mergeConfigMap copies src into dst, recursing into tables so a change
+// to one setting leaves its siblings alone. JSON numbers become integers
+// where they can, since TOML won't put a float in an integer setting.
+func mergeConfigMap(dst, src map[string]any) {
+	for k, v := range src {
+		switch v := v.(type) {
+		case map[string]any:
+			sub, ok := dst[k].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				dst[k] = sub
+			}
+			mergeConfigMap(sub, v)
+		default:
+			dst[k] = jsonToTOML(v)
+		}
+	}
+}
+
+// jsonToTOML converts a decoded JSON value for TOML encoding.
+func jsonToTOML(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = jsonToTOML(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = jsonToTOML(e)
+		}
+		return out
+	}
+	return v
+}
+
+// Limits on the activity feed.
+const (
+	activityDays  = 30 // how far back to look for uploads
+	activityLimit = 50 // most entries returned
+)
+
+// activityJSON is one entry in the activity feed.
+type activityJSON struct {
+	Time           time.Time `json:"time"`
+	Kind           string    `json:"kind"` // "sync" or "upload"
+	Summary        string    `json:"summary"`
+	Files          int       `json:"files"`
+	Bytes          int64     `json:"bytes"`
+	BytesFormatted string    `json:"bytesFormatted"`
+	Games          string    `json:"games,omitempty"` // the games added, named; see systems.Announcement
+	Error          string    `json:"error,omitempty"`
+}
+
+// handleActivity returns recent library activity, newest first: this
+// device's last sync and the uploads found in the bucket, grouped by day
+// and system. Uploaders aren't recorded anywhere, so uploads are
+// described by where they went rather than who made them.
+func (ws *webServer) handleActivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ws.libraryMu.Lock()
+	remote := ws.remoteManifest
+	ws.libraryMu.Unlock()
+
+	objects, err := ws.client.ListObjects(r.Context(), "")
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	overrides := ws.cfg.SystemOverrides()
+	events := uploadActivity(objects, remote, overrides, time.Now().AddDate(0, 0, -activityDays))
+
+	path := ws.lastSyncPath
+	if path == "" {
+		path = config.DefaultLastSyncPath()
+	}
+	if last, err := intsync.LoadLastRun(path); err == nil {
+		events = append(events, syncActivity(last, overrides))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	if len(events) > activityLimit {
+		events = events[:activityLimit]
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+}
+
+// syncActivity describes a device's last sync.
+func syncActivity(last *intsync.LastRun, overrides map[string]systems.Info) activityJSON {
+	device, err := os.Hostname()
+	if err != nil || device == "" {
+		device = "This device"
+	}
+	files := last.Downloaded + last.Deleted
+	ev := activityJSON{
+		Time:           last.Time,
+		Kind:           "sync",
+		Files:          files,
+		Bytes:          last.Bytes,
+		BytesFormatted: formatSize(last.Bytes),
+		Games:          systems.Announcement(last.Added, overrides, systems.AnnounceMax),
+		Error:          last.Error,
+	}
+	switch last.Status {
+	case intsync.StatusFailed:
+		ev.Summary = device + " failed to sync"
+	case intsync.StatusNothingToDo:
+		ev.Summary = device + " synced (already up to date)"
+	default:
+		ev.Summary = fmt.Sprintf("%s synced %s", device, pluralFiles(files))
+		if last.Status == intsync.StatusPartial {
+			ev.Summary += fmt.Sprintf(" (%d errors)", len(last.Errors))
+		}
+	}
+	return ev
+}
+
+// uploadActivity groups library objects uploaded after since by local
+// calendar day and system. Objects not in the manifest (the manifest
+// itself, or leftovers) are ignored when m is known.
+func uploadActivity(objects []storage.ObjectInfo, m *manifest.Manifest, overrides map[string]systems.Info, since time.Time) []activityJSON {
+	type group struct {
+		day, system string
+	}
+	groups := make(map[group]*activityJSON)
+	keys := make(map[group][]string)
+	for _, obj := range objects {
+		if obj.LastModified.Before(since) {
+			continue
+		}
+		if m != nil {
+			if _, ok := m.Files[obj.Key]; !ok {
+				continue
+			}
+		} else if obj.Key == storage.ManifestKey || obj.Key == storage.ManifestGzipKey {
+			continue
+		}
+		sk := systems.Dir(obj.Key)
+		g := group{obj.LastModified.Local().Format("2006-01-02"), sk}
+		ev, ok := groups[g]
+		if !ok {
+			ev = &activityJSON{Kind: "upload"}
+			groups[g] = ev
+		}
+		ev.Files++
+		ev.Bytes += obj.Size
+		keys[g] = append(keys[g], obj.Key)
+		if obj.LastModified.After(ev.Time) {
+			ev.Time = obj.LastModified
+		}
+	}
+
+	events := make([]activityJSON, 0, len(groups))
+	for g, ev := range groups {
+		name := systems.Lookup(g.system, overrides).Name
+		if name == "" {
+			name = g.system
+		}
+		ev.Summary = fmt.Sprintf("%s uploaded to %s", pluralFiles(ev.Files), name)
+		ev.BytesFormatted = formatSize(ev.Bytes)
+		sort.Strings(keys[g])
+		ev.Games = systems.Announcement(keys[g], overrides, systems.AnnounceMax)
+		events = append(events, *ev)
+	}
+	return events
+}
+
+func openBrowser(url string) {
+	var cmd string
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = "open"
+	case "windows":
 		cmd = "rundll32"
 		args = []string{"url.dll,FileProtocolHandler"}
 	default:
@@ -578,7 +1786,13 @@ var webCmd = &cobra.Command{
 	Short: "Open a browser UI to select which games to sync",
 	Long: `Starts a local web server and opens a browser page where you can
 browse available systems, toggle individual games, save your
-selections, sync files, and verify local integrity.
+selections, sync files, and verify local integrity. Each game can also
+be downloaded through the browser, e.g. on a machine with no sync
+client (over an SSH tunnel to the web port).
+
+On a shared machine (see sync.user_selections), the page shows and
+saves your own selections; switch users from the header to see or
+edit someone else's. A sync covers everyone's selections.
 
 By default a random port is chosen. Use --port to specify one, or
 set web.port in the config file.`,
@@ -597,7 +1811,8 @@ set web.port in the config file.`,
 			return err
 		}
 
-		client := storage.NewClient(&cfg.Storage)
+		printUpdateNotice := startUpdateNotice(cmd, cfg)
+		client := storage.NewClient(&cfg.Storage, cfg.Network)
 
 		if cfg.Sync.BandwidthLimit != "" {
 			bps, err := config.ParseBandwidthLimit(cfg.Sync.BandwidthLimit)
@@ -621,8 +1836,13 @@ set web.port in the config file.`,
 		if err != nil {
 			return fmt.Errorf("parsing manifest: %w", err)
 		}
+		cfg.Policy.Hide(remote)
 
-		groups := buildGroups(remote, cfg)
+		user, err := cfg.CurrentUser()
+		if err != nil {
+			return fmt.Errorf("loading selections: %w", err)
+		}
+		groups := buildGroups(remote, cfg.ForUser(user))
 		if len(groups) == 0 {
 			fmt.Println("No files found in remote manifest.")
 			return nil
@@ -632,22 +1852,38 @@ set web.port in the config file.`,
 			groups:         groups,
 			cfg:            cfg,
 			cfgPath:        cfgPath,
+			user:           user,
 			remoteManifest: remote,
 			done:           make(chan struct{}),
 			shutdown:       make(chan struct{}),
 			client:         client,
+			csrfToken:      newCSRFToken(),
 		}
+		ws.libraryVersion, _ = storage.ManifestVersion(cmd.Context(), client)
 
 		mux := http.NewServeMux()
 		mux.HandleFunc("/", ws.handleIndex)
+		mux.HandleFunc("/api/i18n/", ws.handleI18n)
 		mux.HandleFunc("/api/systems", ws.handleSystems)
+		mux.HandleFunc("/api/systems/", ws.handleSystemTree)
 		mux.HandleFunc("/api/save", ws.handleSave)
+		mux.HandleFunc("/api/user", ws.handleUser)
 		mux.HandleFunc("/api/exit", ws.handleExit)
 		mux.HandleFunc("/api/wait", ws.handleWait)
 		mux.HandleFunc("/api/sync", ws.handleSync)
 		mux.HandleFunc("/api/sync/events", ws.handleSyncEvents)
 		mux.HandleFunc("/api/sync/status", ws.handleSyncStatus)
 		mux.HandleFunc("/api/verify", ws.handleVerify)
+		mux.HandleFunc("/api/verify/events", ws.handleVerifyEvents)
+		mux.HandleFunc("/api/verify/status", ws.handleVerifyStatus)
+		mux.HandleFunc("/api/jobs", ws.handleJobs)
+		mux.HandleFunc("/api/jobs/", ws.handleJobs)
+		mux.HandleFunc("/api/stats", ws.handleStats)
+		mux.HandleFunc("/api/library", ws.handleLibrary)
+		mux.HandleFunc("/api/download/", ws.handleDownload)
+		mux.HandleFunc("/api/activity", ws.handleActivity)
+		mux.HandleFunc("/api/config", ws.handleConfig)
+		mux.HandleFunc("/api/config/reload", ws.handleConfigReload)
 
 		port := webPort
 		if !cmd.Flags().Changed("port") && cfg.Web.Port > 0 {
@@ -659,7 +1895,7 @@ set web.port in the config file.`,
 			return fmt.Errorf("binding to port: %w", err)
 		}
 
-		ws.server = &http.Server{Handler: mux}
+		ws.server = &http.Server{Handler: ws.guard(mux)}
 		url := fmt.Sprintf("http://127.0.0.1:%d", listener.Addr().(*net.TCPAddr).Port)
 
 		fmt.Printf("Opening %s\n", url)
@@ -670,6 +1906,10 @@ set web.port in the config file.`,
 		errCh := make(chan error, 1)
 		go func() { errCh <- ws.server.Serve(listener) }()
 
+		watchCtx, stopWatch := context.WithCancel(cmd.Context())
+		defer stopWatch()
+		go ws.watchConfig(watchCtx)
+
 		// Wait for exit, Ctrl+C, or server error
 		select {
 		case <-ws.done:
@@ -684,25 +1924,24 @@ set web.port in the config file.`,
 		close(ws.shutdown)
 		ws.server.Shutdown(context.Background())
 
-		// Wait for sync to finish if one is running
-		ws.syncMu.Lock()
-		syncDone := ws.syncDone
-		ws.syncMu.Unlock()
-		if syncDone != nil {
+		// Wait for sync to finish if one is running; a verify or preview
+		// only reads, so it's stopped instead.
+		if job := ws.jobs.Running(); job != nil && job.Kind != jobSync {
+			job.Cancel()
+		}
+		if job := ws.jobs.Latest(jobSync); job != nil {
 			select {
-			case <-syncDone:
+			case <-job.Done():
 			default:
 				fmt.Println("\nSync in progress. Waiting for it to finish (Ctrl+C to force quit)...")
-				<-syncDone
+				<-job.Done()
 			}
-			ws.syncMu.Lock()
-			result := ws.syncResult
-			ws.syncMu.Unlock()
-			if result != nil {
-				fmt.Print(result.Summary())
+			if result, _ := job.Result(); result != nil {
+				fmt.Print(result.(*intsync.Result).Summary())
 			}
 		}
 
+		printUpdateNotice()
 		return nil
 	},
 }
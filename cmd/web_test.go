@@ -2,19 +2,28 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/jobs"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	intsync "github.com/jacobfgrant/emu-sync/internal/sync"
+	"github.com/jacobfgrant/emu-sync/internal/usage"
 )
 
 func testGroups() []*systemGroup {
@@ -78,8 +87,8 @@ func TestHandleSystems(t *testing.T) {
 	if snes.FileCount != 2 {
 		t.Errorf("expected fileCount 2, got %d", snes.FileCount)
 	}
-	if snes.TotalSizeFormatted != "3 MB" {
-		t.Errorf("expected '3 MB', got %q", snes.TotalSizeFormatted)
+	if snes.TotalSizeFormatted != "3.0 MiB" {
+		t.Errorf("expected '3.0 MiB', got %q", snes.TotalSizeFormatted)
 	}
 
 	// gba: all selected
@@ -117,6 +126,46 @@ func TestHandleSystems(t *testing.T) {
 	}
 }
 
+func TestHandleSystemsReportsPresent(t *testing.T) {
+	remote := manifest.New()
+	remote.Files["roms/snes/GameA.sfc"] = manifest.FileEntry{Size: 1024 * 1024, MD5: "a"}
+	remote.Files["roms/snes/GameB.sfc"] = manifest.FileEntry{Size: 2 * 1024 * 1024, MD5: "b2"}
+
+	// GameA is current locally; GameB's local copy is an older version
+	local := manifest.New()
+	local.Files["roms/snes/GameA.sfc"] = manifest.FileEntry{Size: 1024 * 1024, MD5: "a"}
+	local.Files["roms/snes/GameB.sfc"] = manifest.FileEntry{Size: 2 * 1024 * 1024, MD5: "b1"}
+	localPath := filepath.Join(t.TempDir(), "local-manifest.json")
+	if err := local.SaveJSON(localPath); err != nil {
+		t.Fatalf("saving local manifest: %v", err)
+	}
+
+	ws := &webServer{
+		groups:            testGroups()[:1],
+		cfg:               &config.Config{Sync: config.SyncConfig{SyncDirs: []string{"roms"}}},
+		remoteManifest:    remote,
+		localManifestPath: localPath,
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleSystems(rec, httptest.NewRequest("GET", "/api/systems", nil))
+
+	var resp systemsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	snes := resp.Systems[0]
+	if !snes.Files[0].Present {
+		t.Error("expected GameA present")
+	}
+	if snes.Files[1].Present {
+		t.Error("expected GameB not present (outdated locally)")
+	}
+	if snes.PresentCount != 1 {
+		t.Errorf("presentCount = %d, want 1", snes.PresentCount)
+	}
+}
+
 func TestHandleSave(t *testing.T) {
 	tmpDir := t.TempDir()
 	cfgPath := filepath.Join(tmpDir, "config.toml")
@@ -247,6 +296,133 @@ func TestHandleSaveDeleteToggle(t *testing.T) {
 	}
 }
 
+func TestHandleUserSwitchesSelections(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "config.toml")
+	remote := manifest.New()
+	for _, g := range testGroups() {
+		for _, f := range g.Files {
+			remote.Files[f.Key] = manifest.FileEntry{Size: f.Size}
+		}
+	}
+	cfg := &config.Config{
+		Sync: config.SyncConfig{SyncDirs: []string{"bios"}},
+		Users: map[string]config.Selection{
+			"alice": {SyncDirs: []string{"roms/snes"}, Path: filepath.Join(tmpDir, "alice.toml")},
+			"bob":   {SyncDirs: []string{"roms/gba"}, Path: filepath.Join(tmpDir, "bob.toml")},
+		},
+	}
+	ws := &webServer{
+		cfg:            cfg,
+		cfgPath:        cfgPath,
+		user:           "alice",
+		remoteManifest: remote,
+		groups:         buildGroups(remote, cfg.ForUser("alice")),
+		done:           make(chan struct{}),
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleUser(rec, httptest.NewRequest("POST", "/api/user", strings.NewReader(`{"user":"bob"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("switching user: %d %s", rec.Code, rec.Body)
+	}
+	var resp systemsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.User != "bob" || !slices.Equal(resp.Users, []string{"alice", "bob"}) {
+		t.Errorf("user = %q, users = %v", resp.User, resp.Users)
+	}
+	for _, sys := range resp.Systems {
+		if want := sys.Dir == "roms/gba"; (sys.SelectedCount > 0) != want {
+			t.Errorf("%s: %d selected in bob's view", sys.Dir, sys.SelectedCount)
+		}
+	}
+
+	// Saving writes bob's selection file and leaves the config and
+	// alice alone.
+	body := `{"selections":{"roms/snes/GameA.sfc":true,"roms/snes/GameB.sfc":true}}`
+	rec = httptest.NewRecorder()
+	ws.handleSave(rec, httptest.NewRequest("POST", "/api/save", strings.NewReader(body)))
+	if rec.Code != 200 {
+		t.Fatalf("saving: %d %s", rec.Code, rec.Body)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "bob.toml"))
+	if err != nil || !strings.Contains(string(data), "roms/snes") {
+		t.Errorf("bob's selections = %q, %v; want roms/snes added", data, err)
+	}
+	if _, err := os.Stat(cfgPath); !os.IsNotExist(err) {
+		t.Error("saving a user's selections wrote the shared config")
+	}
+	if !slices.Equal(ws.cfg.Users["alice"].SyncDirs, []string{"roms/snes"}) {
+		t.Errorf("alice's selections = %v, want unchanged", ws.cfg.Users["alice"].SyncDirs)
+	}
+
+	rec = httptest.NewRecorder()
+	ws.handleUser(rec, httptest.NewRequest("POST", "/api/user", strings.NewReader(`{"user":"carol"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: got %d, want 404", rec.Code)
+	}
+}
+
+func TestHandleSaveStaleRevision(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.toml")
+	ws := &webServer{
+		groups: testGroups(),
+		cfg: &config.Config{
+			Storage: config.StorageConfig{Bucket: "test", KeyID: "key", SecretKey: "secret"},
+			Sync:    config.SyncConfig{EmulationPath: "/tmp/emu", SyncDirs: []string{"roms"}},
+		},
+		cfgPath: cfgPath,
+		done:    make(chan struct{}),
+	}
+
+	save := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ws.handleSave(rec, httptest.NewRequest("POST", "/api/save", strings.NewReader(body)))
+		return rec
+	}
+
+	// Both windows loaded revision 0; the first one saves
+	rec := save(`{"selections":{"roms/gba/GameC.gba":false,"roms/gba/GameD.gba":false},"revision":0}`)
+	if rec.Code != 200 {
+		t.Fatalf("first save: expected 200, got %d", rec.Code)
+	}
+	var saved saveResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &saved); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if saved.Revision != 1 {
+		t.Errorf("expected revision 1 after save, got %d", saved.Revision)
+	}
+
+	// The second window's save would undo it
+	rec = save(`{"selections":{"roms/gba/GameC.gba":true,"roms/gba/GameD.gba":true},"revision":0}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("stale save: expected 409, got %d", rec.Code)
+	}
+	var stale staleResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stale); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if stale.State.Revision != 1 {
+		t.Errorf("expected current revision 1 in state, got %d", stale.State.Revision)
+	}
+	for _, sys := range stale.State.Systems {
+		if sys.Dir == "roms/gba" && sys.SelectedCount != 0 {
+			t.Errorf("expected state to show the first window's selections, got %d gba files selected", sys.SelectedCount)
+		}
+	}
+	if ws.groups[1].Files[0].Selected {
+		t.Error("stale save changed the selections")
+	}
+
+	// Requests without a revision (scripts) aren't checked
+	if rec := save(`{"selections":{"roms/gba/GameC.gba":true,"roms/gba/GameD.gba":true}}`); rec.Code != 200 {
+		t.Fatalf("save without revision: expected 200, got %d", rec.Code)
+	}
+}
+
 func TestHandleSaveRejectsNonPost(t *testing.T) {
 	ws := &webServer{
 		groups: testGroups(),
@@ -572,90 +748,6 @@ func TestEncodeSelectionsPartialFewerIncludes(t *testing.T) {
 	}
 }
 
-// --- eventLog tests ---
-
-func TestEventLogWriteAndRead(t *testing.T) {
-	el := newEventLog()
-
-	el.Write([]byte(`{"event":"start","file":"a.rom"}` + "\n"))
-	el.Write([]byte(`{"event":"complete","file":"a.rom"}` + "\n"))
-
-	lines, done := el.read(0)
-	if len(lines) != 2 {
-		t.Fatalf("expected 2 lines, got %d", len(lines))
-	}
-	if done {
-		t.Error("expected done=false")
-	}
-	if !strings.Contains(lines[0], "start") {
-		t.Errorf("expected start event, got %s", lines[0])
-	}
-}
-
-func TestEventLogReadFromOffset(t *testing.T) {
-	el := newEventLog()
-
-	el.Write([]byte("line0\n"))
-	el.Write([]byte("line1\n"))
-	el.Write([]byte("line2\n"))
-
-	lines, _ := el.read(1)
-	if len(lines) != 2 {
-		t.Fatalf("expected 2 lines from offset 1, got %d", len(lines))
-	}
-	if lines[0] != "line1" {
-		t.Errorf("expected line1, got %s", lines[0])
-	}
-}
-
-func TestEventLogFinish(t *testing.T) {
-	el := newEventLog()
-
-	el.Write([]byte("line0\n"))
-	el.finish()
-
-	lines, done := el.read(0)
-	if len(lines) != 1 {
-		t.Fatalf("expected 1 line, got %d", len(lines))
-	}
-	if !done {
-		t.Error("expected done=true after finish")
-	}
-}
-
-func TestEventLogReadPastEnd(t *testing.T) {
-	el := newEventLog()
-
-	el.Write([]byte("line0\n"))
-
-	lines, done := el.read(5)
-	if len(lines) != 0 {
-		t.Fatalf("expected 0 lines from offset 5, got %d", len(lines))
-	}
-	if done {
-		t.Error("expected done=false")
-	}
-}
-
-func TestEventLogNotify(t *testing.T) {
-	el := newEventLog()
-
-	// Drain notify channel if anything's there
-	select {
-	case <-el.notify:
-	default:
-	}
-
-	el.Write([]byte("line\n"))
-
-	select {
-	case <-el.notify:
-		// good
-	case <-time.After(100 * time.Millisecond):
-		t.Error("expected notify signal after Write")
-	}
-}
-
 // --- handleSync tests ---
 
 // setupSyncWebServer creates a webServer with a MockBackend seeded with a
@@ -703,12 +795,14 @@ func setupSyncWebServer(t *testing.T) (*webServer, string) {
 	}
 
 	ws := &webServer{
-		groups:   groups,
-		cfg:      cfg,
-		cfgPath:  cfgPath,
-		done:     make(chan struct{}),
-		shutdown: make(chan struct{}),
-		client:   mock,
+		groups:       groups,
+		cfg:          cfg,
+		cfgPath:      cfgPath,
+		usagePath:    filepath.Join(tmpDir, "usage.json"),
+		lastSyncPath: filepath.Join(tmpDir, "last-sync.json"),
+		done:         make(chan struct{}),
+		shutdown:     make(chan struct{}),
+		client:       mock,
 	}
 
 	return ws, tmpDir
@@ -746,18 +840,31 @@ func TestHandleSyncStartsSync(t *testing.T) {
 	}
 
 	// Wait for sync to finish
-	<-ws.syncDone
+	job := ws.jobs.Latest(jobSync)
+	<-job.Done()
 
-	ws.syncMu.Lock()
-	result := ws.syncResult
-	ws.syncMu.Unlock()
-	if result == nil {
+	if result, _ := job.Result(); result == nil {
 		t.Fatal("expected sync result")
 	}
+
+	// The page ends the sync on the one done event, which is the last
+	// line of the log.
+	lines, _ := job.Log.Read(0)
+	var dones int
+	for _, line := range lines {
+		if strings.Contains(line, `"event":"done"`) {
+			dones++
+		}
+	}
+	if dones != 1 || !strings.Contains(lines[len(lines)-1], `"event":"done"`) {
+		t.Errorf("want one done event, last; got log:\n%s", strings.Join(lines, "\n"))
+	}
 }
 
 func TestHandleSyncRejectsDuplicate(t *testing.T) {
 	ws, _ := setupSyncWebServer(t)
+	// Slow the mock down so the first sync is still running
+	ws.client.(*storage.MockBackend).Latency = 100 * time.Millisecond
 
 	// Start first sync
 	body := `{"selections":{"roms/snes/GameA.sfc":true}}`
@@ -770,20 +877,8 @@ func TestHandleSyncRejectsDuplicate(t *testing.T) {
 		t.Fatalf("first sync: expected 200, got %d", rec.Code)
 	}
 
-	// Try to start a second sync while first is running (or just finished)
-	// We need to ensure the first sync hasn't completed yet
-	// Use the syncDone channel - if it's not closed, sync is still running
-	ws.syncMu.Lock()
-	syncDone := ws.syncDone
-	ws.syncMu.Unlock()
-
-	select {
-	case <-syncDone:
-		// Sync already finished (mock is fast), start a new blocking one
-		// to test the conflict case. Use a slow mock instead.
-		t.Skip("sync completed too fast to test duplicate rejection")
-	default:
-	}
+	// Try to start a second sync while the first is running
+	syncDone := ws.jobs.Latest(jobSync).Done()
 
 	rec2 := httptest.NewRecorder()
 	req2 := httptest.NewRequest("POST", "/api/sync", strings.NewReader(body))
@@ -819,7 +914,7 @@ func TestHandleSyncAutoSavesConfig(t *testing.T) {
 		t.Error("expected roms/snes in config after auto-save")
 	}
 
-	<-ws.syncDone // clean up
+	<-ws.jobs.Latest(jobSync).Done() // clean up
 }
 
 func TestHandleSyncDeleteToggle(t *testing.T) {
@@ -839,7 +934,7 @@ func TestHandleSyncDeleteToggle(t *testing.T) {
 		t.Error("expected delete=true in config after sync")
 	}
 
-	<-ws.syncDone // clean up
+	<-ws.jobs.Latest(jobSync).Done() // clean up
 }
 
 // --- handleSyncEvents tests ---
@@ -869,7 +964,7 @@ func TestHandleSyncEventsStreams(t *testing.T) {
 	ws.handleSync(rec, req)
 
 	// Wait for sync to complete
-	<-ws.syncDone
+	<-ws.jobs.Latest(jobSync).Done()
 
 	// Now read events — they should all be available
 	server := httptest.NewServer(http.HandlerFunc(ws.handleSyncEvents))
@@ -932,7 +1027,7 @@ func TestHandleSyncStatusComplete(t *testing.T) {
 	req := httptest.NewRequest("POST", "/api/sync", strings.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	ws.handleSync(rec, req)
-	<-ws.syncDone
+	<-ws.jobs.Latest(jobSync).Done()
 
 	// Check status
 	rec2 := httptest.NewRecorder()
@@ -949,13 +1044,50 @@ func TestHandleSyncStatusComplete(t *testing.T) {
 	if _, ok := resp["summary"]; !ok {
 		t.Error("expected summary field in response")
 	}
+	slowest, _ := resp["slowest"].([]interface{})
+	if len(slowest) != 1 || slowest[0].(map[string]interface{})["key"] != "roms/snes/GameA.sfc" {
+		t.Errorf("slowest = %v, want the one download", resp["slowest"])
+	}
+	if _, ok := resp["throughput"].(float64); !ok {
+		t.Errorf("throughput = %v, want a number", resp["throughput"])
+	}
+}
+
+func TestHandleSyncStatusIdleIncludesLastSync(t *testing.T) {
+	ws, tmpDir := setupSyncWebServer(t)
+
+	body := `{"selections":{"roms/snes/GameA.sfc":true}}`
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/sync", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	ws.handleSync(rec, req)
+	<-ws.jobs.Latest(jobSync).Done()
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "last-sync.json")); err != nil {
+		t.Fatalf("last sync result not saved: %v", err)
+	}
+
+	// A fresh server (e.g. after restart) reports the saved result
+	idle := &webServer{lastSyncPath: ws.lastSyncPath}
+	rec2 := httptest.NewRecorder()
+	idle.handleSyncStatus(rec2, httptest.NewRequest("GET", "/api/sync/status", nil))
+
+	var resp struct {
+		State string           `json:"state"`
+		Last  *intsync.LastRun `json:"last"`
+	}
+	json.Unmarshal(rec2.Body.Bytes(), &resp)
+	if resp.State != "idle" {
+		t.Errorf("state = %q, want idle", resp.State)
+	}
+	if resp.Last == nil || resp.Last.Status == "" {
+		t.Fatalf("last = %+v, want saved result", resp.Last)
+	}
 }
 
 func TestHandleSyncStatusRunning(t *testing.T) {
 	ws := &webServer{}
-	ws.syncLog = newEventLog()
-	ws.syncDone = make(chan struct{})
-	// Don't close syncDone — simulate a running sync
+	defer startBlockingJob(t, ws, jobSync)()
 
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/api/sync/status", nil)
@@ -969,18 +1101,16 @@ func TestHandleSyncStatusRunning(t *testing.T) {
 }
 
 func TestHandleSyncEventsLastEventID(t *testing.T) {
-	el := newEventLog()
-	el.Write([]byte("line0\n"))
-	el.Write([]byte("line1\n"))
-	el.Write([]byte("line2\n"))
-	el.finish()
-
 	ws := &webServer{
 		shutdown: make(chan struct{}),
 	}
-	ws.syncLog = el
-	ws.syncDone = make(chan struct{})
-	close(ws.syncDone)
+	job, _ := ws.jobs.Start(jobSync, func(ctx context.Context, log io.Writer) (any, error) {
+		fmt.Fprintln(log, "line0")
+		fmt.Fprintln(log, "line1")
+		fmt.Fprintln(log, "line2")
+		return &intsync.Result{}, nil
+	})
+	<-job.Done()
 
 	server := httptest.NewServer(http.HandlerFunc(ws.handleSyncEvents))
 	defer server.Close()
@@ -1027,8 +1157,7 @@ func TestHandleVerifyRejectsGet(t *testing.T) {
 
 func TestHandleVerifyRejectsDuringSync(t *testing.T) {
 	ws := &webServer{}
-	ws.syncLog = newEventLog()
-	ws.syncDone = make(chan struct{}) // not closed — simulates running sync
+	defer startBlockingJob(t, ws, jobSync)()
 
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest("POST", "/api/verify", nil)
@@ -1047,16 +1176,7 @@ func TestHandleVerifyNoManifest(t *testing.T) {
 	}
 	ws := &webServer{cfg: cfg}
 
-	rec := httptest.NewRecorder()
-	req := httptest.NewRequest("POST", "/api/verify", nil)
-	ws.handleVerify(rec, req)
-
-	if rec.Code != 200 {
-		t.Fatalf("expected 200, got %d", rec.Code)
-	}
-
-	var resp map[string]interface{}
-	json.Unmarshal(rec.Body.Bytes(), &resp)
+	resp := runVerifyRequest(t, ws)
 
 	// With no local manifest, verify returns an empty result
 	if resp["ok"].(float64) != 0 {
@@ -1069,9 +1189,10 @@ func TestHandleVerifyNoManifest(t *testing.T) {
 
 func TestHandleVerifyAllowedAfterSyncComplete(t *testing.T) {
 	ws := &webServer{}
-	ws.syncLog = newEventLog()
-	ws.syncDone = make(chan struct{})
-	close(ws.syncDone) // sync is done
+	job, _ := ws.jobs.Start(jobSync, func(ctx context.Context, log io.Writer) (any, error) {
+		return &intsync.Result{}, nil
+	})
+	<-job.Done() // sync is done
 
 	cfg := &config.Config{
 		Sync: config.SyncConfig{
@@ -1113,16 +1234,7 @@ func TestHandleVerifyReturnsMissingFiles(t *testing.T) {
 	}
 	ws := &webServer{cfg: cfg, localManifestPath: localManifestPath}
 
-	rec := httptest.NewRecorder()
-	req := httptest.NewRequest("POST", "/api/verify", nil)
-	ws.handleVerify(rec, req)
-
-	if rec.Code != 200 {
-		t.Fatalf("expected 200, got %d", rec.Code)
-	}
-
-	var resp map[string]interface{}
-	json.Unmarshal(rec.Body.Bytes(), &resp)
+	resp := runVerifyRequest(t, ws)
 
 	// Should have 1 missing file
 	missing := resp["missing"].(float64)
@@ -1139,6 +1251,129 @@ func TestHandleVerifyReturnsMissingFiles(t *testing.T) {
 	}
 }
 
+func TestHandleVerifyStreamsProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+	emuPath := filepath.Join(tmpDir, "emu")
+	os.MkdirAll(filepath.Join(emuPath, "roms", "gba"), 0o755)
+	gamePath := filepath.Join(emuPath, "roms", "gba", "Game.gba")
+	os.WriteFile(gamePath, []byte("game"), 0o644)
+	hash, _ := manifest.HashFile(gamePath)
+
+	m := manifest.New()
+	m.Files["roms/gba/Game.gba"] = manifest.FileEntry{MD5: hash, Size: 4}
+	localManifestPath := filepath.Join(tmpDir, "local-manifest.json")
+	m.SaveJSON(localManifestPath)
+
+	ws := &webServer{
+		cfg:               &config.Config{Sync: config.SyncConfig{EmulationPath: emuPath}},
+		localManifestPath: localManifestPath,
+		shutdown:          make(chan struct{}),
+	}
+	resp := runVerifyRequest(t, ws)
+	if resp["state"] != "complete" || resp["ok"].(float64) != 1 {
+		t.Fatalf("status = %v, want complete with 1 OK", resp)
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleVerifyEvents(rec, httptest.NewRequest("GET", "/api/verify/events", nil))
+	body := rec.Body.String()
+	for _, want := range []string{`"event":"plan"`, `"event":"start","file":"roms/gba/Game.gba"`, `"event":"complete","file":"roms/gba/Game.gba"`, `"event":"done"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("events missing %s:\n%s", want, body)
+		}
+	}
+
+	// A sync can't start while verify is running
+	defer startBlockingJob(t, ws, jobVerify)()
+	rec = httptest.NewRecorder()
+	ws.handleSync(rec, httptest.NewRequest("POST", "/api/sync", strings.NewReader("{}")))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("sync during verify: expected 409, got %d", rec.Code)
+	}
+}
+
+func TestHandleJobs(t *testing.T) {
+	ws := &webServer{shutdown: make(chan struct{})}
+	done, _ := ws.jobs.Start(jobVerify, func(ctx context.Context, log io.Writer) (any, error) {
+		return &intsync.VerifyResult{OK: []string{"roms/gba/Game.gba"}}, nil
+	})
+	<-done.Done()
+	running, _ := ws.jobs.Start(jobSync, func(ctx context.Context, log io.Writer) (any, error) {
+		<-ctx.Done()
+		return &intsync.Result{}, ctx.Err()
+	})
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ws.handleJobs(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	var list struct {
+		Jobs []jobJSON `json:"jobs"`
+	}
+	json.Unmarshal(get("GET", "/api/jobs").Body.Bytes(), &list)
+	if len(list.Jobs) != 2 || list.Jobs[0].ID != running.ID || list.Jobs[0].State != jobs.Running {
+		t.Fatalf("jobs = %+v, want the running sync first", list.Jobs)
+	}
+
+	var one jobJSON
+	json.Unmarshal(get("GET", "/api/jobs/"+done.ID).Body.Bytes(), &one)
+	if one.Kind != jobVerify || one.State != jobs.Complete || !strings.Contains(one.Summary, "1 files OK") {
+		t.Errorf("job = %+v, want the finished verify with its summary", one)
+	}
+
+	if rec := get("GET", "/api/jobs/99"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job: expected 404, got %d", rec.Code)
+	}
+	if rec := get("GET", "/api/jobs/"+running.ID+"/cancel"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET cancel: expected 405, got %d", rec.Code)
+	}
+	if rec := get("POST", "/api/jobs/"+running.ID+"/cancel"); rec.Code != 200 {
+		t.Fatalf("cancel: expected 200, got %d", rec.Code)
+	}
+	<-running.Done()
+	if state := running.Info().State; state != jobs.Canceled {
+		t.Errorf("state after cancel = %s, want canceled", state)
+	}
+}
+
+// startBlockingJob starts a job of kind that runs until the returned
+// function is called.
+func startBlockingJob(t *testing.T, ws *webServer, kind string) (release func()) {
+	t.Helper()
+	stop := make(chan struct{})
+	job, err := ws.jobs.Start(kind, func(ctx context.Context, log io.Writer) (any, error) {
+		<-stop
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("starting %s job: %v", kind, err)
+	}
+	return func() {
+		close(stop)
+		<-job.Done()
+	}
+}
+
+// runVerifyRequest starts a verify through the API, waits for it to
+// finish, and returns /api/verify/status.
+func runVerifyRequest(t *testing.T, ws *webServer) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	ws.handleVerify(rec, httptest.NewRequest("POST", "/api/verify", nil))
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	<-ws.jobs.Latest(jobVerify).Done()
+
+	rec = httptest.NewRecorder()
+	ws.handleVerifyStatus(rec, httptest.NewRequest("GET", "/api/verify/status", nil))
+	var resp map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return resp
+}
+
 func TestHandleVerifyRejectsInvalidEmulationPath(t *testing.T) {
 	cfg := &config.Config{
 		Sync: config.SyncConfig{
@@ -1189,12 +1424,13 @@ func TestRunSyncRejectsInvalidEmulationPath(t *testing.T) {
 	}
 
 	ws := &webServer{
-		groups:   testGroups(),
-		cfg:      cfg,
-		cfgPath:  cfgPath,
-		done:     make(chan struct{}),
-		shutdown: make(chan struct{}),
-		client:   mock,
+		groups:       testGroups(),
+		cfg:          cfg,
+		cfgPath:      cfgPath,
+		lastSyncPath: filepath.Join(tmpDir, "last-sync.json"),
+		done:         make(chan struct{}),
+		shutdown:     make(chan struct{}),
+		client:       mock,
 	}
 
 	// Trigger sync via handleSync
@@ -1209,12 +1445,10 @@ func TestRunSyncRejectsInvalidEmulationPath(t *testing.T) {
 	}
 
 	// Wait for sync goroutine to finish
-	<-ws.syncDone
-
-	ws.syncMu.Lock()
-	result := ws.syncResult
-	ws.syncMu.Unlock()
+	<-ws.jobs.Latest(jobSync).Done()
 
+	value, _ := ws.jobs.Latest(jobSync).Result()
+	result, _ := value.(*intsync.Result)
 	if result == nil {
 		t.Fatal("expected sync result")
 	}
@@ -1226,25 +1460,7 @@ func TestRunSyncRejectsInvalidEmulationPath(t *testing.T) {
 	}
 }
 
-// --- systemKey / buildGroups / sub-group tests ---
-
-func TestSystemKey(t *testing.T) {
-	tests := []struct {
-		key  string
-		want string
-	}{
-		{"roms/snes/Game.sfc", "roms/snes"},
-		{"bios/pcsx2/resources/shader.glsl", "bios/pcsx2"},
-		{"bios/file.bin", "bios"},
-		{"roms/gba/sub/deep/file.gba", "roms/gba"},
-	}
-	for _, tt := range tests {
-		got := systemKey(tt.key)
-		if got != tt.want {
-			t.Errorf("systemKey(%q) = %q, want %q", tt.key, got, tt.want)
-		}
-	}
-}
+// --- buildGroups / sub-group tests ---
 
 func TestBuildGroupsSystemKey(t *testing.T) {
 	m := manifest.New()
@@ -1348,6 +1564,87 @@ func TestBuildSubGroups(t *testing.T) {
 	}
 }
 
+// testMAMEGroup returns a system nested three directories deep.
+func testMAMEGroup() *systemGroup {
+	return &systemGroup{
+		Dir: "roms/mame",
+		Files: []fileInfo{
+			{Key: "roms/mame/README.txt", Name: "README.txt", Size: 1},
+			{Key: "roms/mame/neogeo/bios/neogeo.zip", Name: "neogeo/bios/neogeo.zip", Size: 10, Selected: true},
+			{Key: "roms/mame/neogeo/games/mslug.zip", Name: "neogeo/games/mslug.zip", Size: 100, Selected: true},
+			{Key: "roms/mame/neogeo/games/kof98.zip", Name: "neogeo/games/kof98.zip", Size: 200},
+			{Key: "roms/mame/cps2/sfa3.zip", Name: "cps2/sfa3.zip", Size: 300},
+		},
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	root := buildTree(testMAMEGroup())
+
+	if len(root.Files) != 1 || root.Files[0].Name != "README.txt" {
+		t.Errorf("root files = %v, want README.txt", root.Files)
+	}
+	if len(root.Dirs) != 2 || root.Dirs[0].Name != "cps2" || root.Dirs[1].Name != "neogeo" {
+		t.Fatalf("root dirs = %v, want cps2 and neogeo", root.Dirs)
+	}
+
+	games := root.find("roms/mame/neogeo/games")
+	if games == nil {
+		t.Fatal("find(roms/mame/neogeo/games) = nil")
+	}
+	if games.fileCount() != 2 || games.selectedCount() != 1 || games.groupState() != "partial" {
+		t.Errorf("games: %d files, %d selected, state %s", games.fileCount(), games.selectedCount(), games.groupState())
+	}
+
+	neogeo := root.find("roms/mame/neogeo")
+	if neogeo.fileCount() != 3 || neogeo.totalSize() != 310 {
+		t.Errorf("neogeo: %d files, %d bytes; want 3 files, 310 bytes", neogeo.fileCount(), neogeo.totalSize())
+	}
+	if root.find("roms/mame/neo") != nil || root.find("roms/snes") != nil {
+		t.Error("find should not match partial or foreign paths")
+	}
+
+	// Toggling a deep directory changes the parent group's files
+	for _, f := range games.allFiles() {
+		f.Selected = false
+	}
+	if neogeo.selectedCount() != 1 {
+		t.Errorf("neogeo selected = %d after deselecting games, want 1", neogeo.selectedCount())
+	}
+}
+
+func TestHandleSystemTree(t *testing.T) {
+	ws := &webServer{
+		groups: []*systemGroup{testGroups()[0], testMAMEGroup()},
+		cfg:    &config.Config{},
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleSystemTree(rec, httptest.NewRequest("GET", "/api/systems/roms/mame/neogeo/tree", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var tree treeJSON
+	if err := json.Unmarshal(rec.Body.Bytes(), &tree); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if tree.Path != "roms/mame/neogeo" || tree.FileCount != 3 || tree.SelectedCount != 2 || tree.State != "partial" {
+		t.Errorf("tree = %+v", tree)
+	}
+	if len(tree.Dirs) != 2 || tree.Dirs[1].Name != "games" || len(tree.Dirs[1].Files) != 2 {
+		t.Errorf("dirs = %+v, want bios and games", tree.Dirs)
+	}
+
+	for _, p := range []string{"/api/systems/roms/mame/nope/tree", "/api/systems/roms/gba/tree", "/api/systems/roms/mame"} {
+		rec := httptest.NewRecorder()
+		ws.handleSystemTree(rec, httptest.NewRequest("GET", p, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status = %d, want 404", p, rec.Code)
+		}
+	}
+}
+
 func TestEncodeSelectionsSubGroups(t *testing.T) {
 	// Whole sub-group selected → directory path in sync_dirs
 	groups := []*systemGroup{
@@ -1463,3 +1760,583 @@ func TestEncodeSelectionsNoSubGroups(t *testing.T) {
 	}
 }
 
+func TestHandleStats(t *testing.T) {
+	usagePath := filepath.Join(t.TempDir(), "usage.json")
+	stats, _ := usage.Load(usagePath)
+	stats.Add(time.Now(), 1024, 3*1024*1024)
+	stats.Add(time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), 0, 50)
+	if err := stats.Save(usagePath); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := &webServer{usagePath: usagePath}
+	rec := httptest.NewRecorder()
+	ws.handleStats(rec, httptest.NewRequest("GET", "/api/stats", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var resp statsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.CurrentMonth.Downloaded != 3*1024*1024 {
+		t.Errorf("current downloaded = %d, want 3MB", resp.CurrentMonth.Downloaded)
+	}
+	if resp.CurrentMonth.DownloadedFormatted != "3.0 MiB" {
+		t.Errorf("formatted = %q, want '3.0 MiB'", resp.CurrentMonth.DownloadedFormatted)
+	}
+	if len(resp.Months) != 2 || resp.Months[1].Month != "2020-01" {
+		t.Errorf("months = %+v, want 2 entries newest first", resp.Months)
+	}
+}
+
+func TestHandleStatsPing(t *testing.T) {
+	mock := storage.NewMockBackend()
+	remote := manifest.New()
+	remote.Files["roms/snes/Small.sfc"] = manifest.FileEntry{Size: 10}
+	remote.Files["roms/snes/Medium.sfc"] = manifest.FileEntry{Size: 2048}
+	remote.Files["roms/ps2/Huge.iso"] = manifest.FileEntry{Size: 4 << 30}
+	mock.Objects["roms/snes/Medium.sfc"] = make([]byte, 2048)
+
+	ws := &webServer{
+		usagePath:      filepath.Join(t.TempDir(), "usage.json"),
+		client:         mock,
+		remoteManifest: remote,
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleStats(rec, httptest.NewRequest("GET", "/api/stats", nil))
+	var resp statsResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Connection != nil {
+		t.Error("connection check should only run with ?ping=1")
+	}
+
+	rec = httptest.NewRecorder()
+	ws.handleStats(rec, httptest.NewRequest("GET", "/api/stats?ping=1", nil))
+	resp = statsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Connection == nil || resp.Connection.Error != "" {
+		t.Fatalf("connection = %+v, want successful check", resp.Connection)
+	}
+	if resp.Connection.SampleBytes != 2048 {
+		t.Errorf("sample = %d bytes, want the largest file under the cap (2048)", resp.Connection.SampleBytes)
+	}
+}
+
+func TestComputeSyncStatusEstimatesCost(t *testing.T) {
+	remote := manifest.New()
+	remote.Files["roms/snes/GameA.sfc"] = manifest.FileEntry{Size: 1024 * 1024 * 1024, MD5: "a"}
+	remote.Files["roms/snes/GameB.sfc"] = manifest.FileEntry{Size: 1024 * 1024 * 1024, MD5: "b"}
+
+	cfg := &config.Config{
+		Storage: config.StorageConfig{Cost: config.CostConfig{EgressPerGB: 0.01}},
+		Sync:    config.SyncConfig{SyncDirs: []string{"roms"}},
+	}
+	ws := &webServer{
+		cfg:               cfg,
+		remoteManifest:    remote,
+		localManifestPath: filepath.Join(t.TempDir(), "missing.json"),
+	}
+
+	status := ws.computeSyncStatus()
+	if status.New != 2 {
+		t.Errorf("new = %d, want 2", status.New)
+	}
+	if status.DownloadSizeFormatted != "2.0 GiB" {
+		t.Errorf("download size = %q, want '2.0 GiB'", status.DownloadSizeFormatted)
+	}
+	if status.EstimatedCost != "$0.02" {
+		t.Errorf("estimated cost = %q, want '$0.02'", status.EstimatedCost)
+	}
+}
+
+func TestHandleDownload(t *testing.T) {
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/snes/Game A.sfc"] = []byte("snes rom")
+	mock.Objects["roms/n64/Hidden.z64"] = []byte("hidden")
+	remote := manifest.New()
+	remote.Files["roms/snes/Game A.sfc"] = manifest.FileEntry{Size: 8}
+	ws := &webServer{client: mock, remoteManifest: remote}
+
+	rec := httptest.NewRecorder()
+	ws.handleDownload(rec, httptest.NewRequest("GET", "/api/download/roms/snes/Game%20A.sfc", nil))
+	if rec.Code != 200 || rec.Body.String() != "snes rom" {
+		t.Fatalf("download: %d %q", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, `filename="Game A.sfc"`) {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "8" {
+		t.Errorf("Content-Length = %q, want 8", got)
+	}
+
+	rec = httptest.NewRecorder()
+	ws.handleDownload(rec, httptest.NewRequest("GET", "/api/download/roms/snes/Game%20A.sfc?redirect=true", nil))
+	if rec.Code != http.StatusFound || !strings.HasPrefix(rec.Header().Get("Location"), "https://mock.invalid/roms/snes/Game A.sfc") {
+		t.Errorf("redirect: %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Objects the library doesn't list (e.g. hidden by [policy]) aren't
+	// served even though they're in the bucket.
+	rec = httptest.NewRecorder()
+	ws.handleDownload(rec, httptest.NewRequest("GET", "/api/download/roms/n64/Hidden.z64", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unlisted file: got %d, want 404", rec.Code)
+	}
+}
+
+func TestHandleLibraryReportsChanges(t *testing.T) {
+	ws, _ := setupSyncWebServer(t)
+	mock := ws.client.(*storage.MockBackend)
+	ws.remoteManifest, _ = manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+	ws.libraryVersion, _ = storage.ManifestVersion(context.Background(), mock)
+
+	get := func(since string) libraryJSON {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		ws.handleLibrary(rec, httptest.NewRequest("GET", "/api/library?since="+since, nil))
+		var resp libraryJSON
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	first := get("")
+	if first.Changed || first.Version == "" {
+		t.Fatalf("initial = %+v, want unchanged with a version", first)
+	}
+	if again := get(first.Version); again.Changed {
+		t.Errorf("unchanged library reported as changed: %+v", again)
+	}
+
+	m := manifest.New()
+	m.Files["roms/snes/GameA.sfc"] = manifest.FileEntry{MD5: "abc123", Size: 100}
+	m.Files["roms/snes/GameB.sfc"] = manifest.FileEntry{MD5: "def456", Size: 200}
+	data, _ := json.Marshal(m)
+	mock.UploadManifest(context.Background(), data)
+
+	resp := get(first.Version)
+	if !resp.Changed || resp.Added != 1 || resp.Version == first.Version {
+		t.Errorf("after upload = %+v, want changed with 1 added", resp)
+	}
+	if n := len(ws.groups[0].Files); n != 2 {
+		t.Errorf("systems list has %d files after refresh, want 2", n)
+	}
+}
+
+func TestHandleLibraryConcurrentWithSystems(t *testing.T) {
+	ws, _ := setupSyncWebServer(t)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			// Forget the version so every poll refreshes the library.
+			ws.libraryMu.Lock()
+			ws.libraryVersion = ""
+			ws.libraryMu.Unlock()
+			ws.handleLibrary(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/library", nil))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			ws.handleSystems(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/systems", nil))
+		}
+	}()
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	ws.handleSystems(rec, httptest.NewRequest("GET", "/api/systems", nil))
+	var resp systemsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.SyncStatus == nil {
+		t.Errorf("systems after refreshes = %s, want a sync status", rec.Body.String())
+	}
+}
+
+func TestHandleActivity(t *testing.T) {
+	ws, _ := setupSyncWebServer(t)
+	mock := ws.client.(*storage.MockBackend)
+	now := time.Now()
+	mock.Objects["roms/ps2/A.iso"] = make([]byte, 10)
+	mock.Objects["roms/ps2/B.iso"] = make([]byte, 20)
+	mock.Objects["roms/ps2/Old.iso"] = make([]byte, 30)
+	mock.ModTimes["roms/snes/GameA.sfc"] = now.Add(-time.Hour)
+	mock.ModTimes["roms/ps2/A.iso"] = now.Add(-2 * time.Hour)
+	mock.ModTimes["roms/ps2/B.iso"] = now.Add(-2 * time.Hour)
+	mock.ModTimes["roms/ps2/Old.iso"] = now.AddDate(0, 0, -(activityDays + 1))
+	mock.ModTimes[storage.ManifestKey] = now
+
+	m := manifest.New()
+	for _, key := range []string{"roms/snes/GameA.sfc", "roms/ps2/A.iso", "roms/ps2/B.iso", "roms/ps2/Old.iso"} {
+		m.Files[key] = manifest.FileEntry{Size: int64(len(mock.Objects[key]))}
+	}
+	ws.remoteManifest = m
+
+	last := &intsync.LastRun{Time: now.Add(-30 * time.Minute), Status: intsync.StatusOK, Downloaded: 14, Added: []string{"roms/snes/GameA.sfc"}}
+	if err := last.Save(ws.lastSyncPath); err != nil {
+		t.Fatalf("saving last sync: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleActivity(rec, httptest.NewRequest("GET", "/api/activity", nil))
+	var resp struct {
+		Events []activityJSON `json:"events"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(resp.Events) != 3 {
+		t.Fatalf("events = %+v, want last sync, snes upload, and ps2 upload", resp.Events)
+	}
+	if ev := resp.Events[0]; ev.Kind != "sync" || !strings.Contains(ev.Summary, "synced 14 files") || ev.Games != "New games available: GameA (SNES)" {
+		t.Errorf("first event = %+v, want the last sync", ev)
+	}
+	ps2 := resp.Events[2]
+	if ps2.Kind != "upload" || ps2.Files != 2 || ps2.Bytes != 30 || !strings.Contains(ps2.Summary, "2 files uploaded to") {
+		t.Errorf("ps2 event = %+v, want 2 recent files (30 bytes)", ps2)
+	}
+	if want := "New games available: A (PS2), B (PS2)"; ps2.Games != want {
+		t.Errorf("ps2 games = %q, want %q", ps2.Games, want)
+	}
+}
+
+func TestHandleConfigReload(t *testing.T) {
+	ws, _ := setupSyncWebServer(t)
+	mock := ws.client
+
+	edited := *ws.cfg
+	edited.Sync.Workers = 4
+	if err := config.Write(&edited, ws.cfgPath); err != nil {
+		t.Fatal(err)
+	}
+
+	reload := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ws.handleConfigReload(rec, httptest.NewRequest("POST", "/api/config/reload", nil))
+		return rec
+	}
+
+	release := startBlockingJob(t, ws, jobSync)
+	if rec := reload(); rec.Code != http.StatusConflict {
+		t.Fatalf("during sync: expected 409, got %d", rec.Code)
+	}
+	release()
+
+	rec := reload()
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]bool
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !resp["changed"] {
+		t.Errorf("expected changed=true, got %v", resp)
+	}
+	if ws.cfg.Sync.Workers != 4 {
+		t.Errorf("workers = %d, want 4", ws.cfg.Sync.Workers)
+	}
+	if ws.client != mock {
+		t.Error("client was replaced though storage settings didn't change")
+	}
+
+	os.WriteFile(ws.cfgPath, []byte("not toml ["), 0o600)
+	if rec := reload(); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid config: expected 400, got %d", rec.Code)
+	}
+	if ws.cfg.Sync.Workers != 4 {
+		t.Error("invalid config replaced the one in use")
+	}
+}
+
+func TestHandleConfig(t *testing.T) {
+	ws, _ := setupSyncWebServer(t)
+	mock := ws.client
+
+	do := func(method, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		ws.handleConfig(rec, httptest.NewRequest(method, "/api/config", strings.NewReader(body)))
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	rec, resp := do("GET", "")
+	if rec.Code != 200 {
+		t.Fatalf("GET: expected 200, got %d", rec.Code)
+	}
+	storageJSON := resp["storage"].(map[string]interface{})
+	if storageJSON["secret_key"] != maskedKey {
+		t.Errorf("secret_key = %v, want it masked", storageJSON["secret_key"])
+	}
+	if storageJSON["bucket"] != "test" {
+		t.Errorf("bucket = %v, want test", storageJSON["bucket"])
+	}
+
+	body := `{"storage":{"secret_key":"` + maskedKey + `"},"sync":{"workers":4,"bandwidth_limit":"10MB","delete":true}}`
+	rec, resp = do("PUT", body)
+	if rec.Code != 200 {
+		t.Fatalf("PUT: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ws.cfg.Sync.Workers != 4 || ws.cfg.Sync.BandwidthLimit != "10MB" || !ws.cfg.Sync.Delete {
+		t.Errorf("settings not applied: %+v", ws.cfg.Sync)
+	}
+	if ws.cfg.Storage.SecretKey != "secret" {
+		t.Errorf("secret_key = %q, want the masked value to keep the old key", ws.cfg.Storage.SecretKey)
+	}
+	if ws.client == mock {
+		t.Error("client not rebuilt after bandwidth_limit changed")
+	}
+	if resp["storage"].(map[string]interface{})["secret_key"] != maskedKey {
+		t.Error("PUT response leaked the secret key")
+	}
+	saved, err := config.Load(ws.cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Sync.Workers != 4 || saved.Storage.SecretKey != "secret" {
+		t.Errorf("saved config = %+v", saved)
+	}
+
+	for _, bad := range []string{
+		`{"sync":{"bandwidth_limit":"fast"}}`,
+		`{"sync":{"workers":-1}}`,
+		`{"sync":{"emulation_path":"/nonexistent/emu-sync-test"}}`,
+		`{"sync":{"wrokers":2}}`,
+		`not json`,
+	} {
+		if rec, _ := do("PUT", bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: expected 400, got %d", bad, rec.Code)
+		}
+	}
+	if ws.cfg.Sync.Workers != 4 {
+		t.Error("a rejected PUT changed the config in use")
+	}
+
+	release := startBlockingJob(t, ws, jobSync)
+	defer release()
+	if rec, _ := do("PUT", `{"sync":{"workers":2}}`); rec.Code != http.StatusConflict {
+		t.Errorf("PUT during sync: expected 409, got %d", rec.Code)
+	}
+}
+
+func TestHandleConfigLockedSettings(t *testing.T) {
+	ws, _ := setupSyncWebServer(t)
+	ws.cfg.Policy.DenyTags = []string{"mature"}
+	ws.cfg.Trigger.Token = "hunter2"
+	if err := config.Write(ws.cfg, ws.cfgPath); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(ws.cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleConfig(rec, httptest.NewRequest("GET", "/api/config", nil))
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Errorf("GET leaked the trigger token: %s", rec.Body.String())
+	}
+
+	for _, body := range []string{
+		`{"policy":{"deny_tags":[]}}`,
+		`{"policy":null}`,
+		`{"trigger":{"token":"mine"}}`,
+		`{"storage":{"key_id":"other"}}`,
+		`{"storage":{"secret_key":"other"}}`,
+	} {
+		rec := httptest.NewRecorder()
+		ws.handleConfig(rec, httptest.NewRequest("PUT", "/api/config", strings.NewReader(body)))
+		if rec.Code < 400 || rec.Code >= 500 {
+			t.Errorf("PUT %s: got %d, want a 4xx", body, rec.Code)
+		}
+	}
+	after, _ := os.ReadFile(ws.cfgPath)
+	if string(after) != string(before) {
+		t.Errorf("config on disk changed:\n%s\nwant:\n%s", after, before)
+	}
+	if !slices.Equal(ws.cfg.Policy.DenyTags, []string{"mature"}) {
+		t.Errorf("policy in use = %+v, want it unchanged", ws.cfg.Policy)
+	}
+
+	// Sending the masks back, as the settings page does, changes nothing.
+	body := `{"storage":{"secret_key":"` + maskedKey + `"},"trigger":{"token":"` + maskedKey + `"},"sync":{"workers":3}}`
+	rec = httptest.NewRecorder()
+	ws.handleConfig(rec, httptest.NewRequest("PUT", "/api/config", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || ws.cfg.Trigger.Token != "hunter2" {
+		t.Errorf("PUT with masks: got %d, token %q", rec.Code, ws.cfg.Trigger.Token)
+	}
+}
+
+func TestHandleSyncDryRun(t *testing.T) {
+	ws, tmpDir := setupSyncWebServer(t)
+	ws.localManifestPath = filepath.Join(tmpDir, "local-manifest.json")
+
+	body := `{"selections":{"roms/snes/GameA.sfc":true}}`
+	rec := httptest.NewRecorder()
+	ws.handleSync(rec, httptest.NewRequest("POST", "/api/sync?dry_run=true", strings.NewReader(body)))
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	job := ws.jobs.Latest(jobPreview)
+	if job == nil {
+		t.Fatal("no preview job started")
+	}
+	<-job.Done()
+	if ws.jobs.Latest(jobSync) != nil {
+		t.Error("preview ran as a sync")
+	}
+	result, err := job.Result()
+	if err != nil {
+		t.Fatalf("preview failed: %v", err)
+	}
+	if got := result.(*intsync.Result).Downloaded; len(got) != 1 {
+		t.Errorf("would download %v, want GameA.sfc", got)
+	}
+
+	lines, _ := job.Log.Read(0)
+	if len(lines) == 0 || !strings.Contains(strings.Join(lines, "\n"), `"event":"would_download"`) {
+		t.Errorf("no would_download event in %v", lines)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "emu", "roms", "snes", "GameA.sfc")); !os.IsNotExist(err) {
+		t.Error("preview downloaded a file")
+	}
+	if _, err := os.Stat(ws.cfgPath); !os.IsNotExist(err) {
+		t.Error("preview saved the config")
+	}
+	if _, err := os.Stat(ws.lastSyncPath); !os.IsNotExist(err) {
+		t.Error("preview recorded a last sync")
+	}
+}
+
+func TestWebGuard(t *testing.T) {
+	ws := &webServer{csrfToken: "secret-token"}
+	handler := ws.guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		method string
+		host   string
+		origin string
+		token  string
+		want   int
+	}{
+		{"page load", "GET", "127.0.0.1:8080", "", "", http.StatusOK},
+		{"localhost", "GET", "localhost:8080", "", "", http.StatusOK},
+		{"rebound domain", "GET", "evil.example:8080", "", "", http.StatusMisdirectedRequest},
+		{"rebound domain with token", "POST", "evil.example:8080", "", "secret-token", http.StatusMisdirectedRequest},
+		{"post without token", "POST", "127.0.0.1:8080", "", "", http.StatusForbidden},
+		{"post with wrong token", "POST", "127.0.0.1:8080", "", "guess", http.StatusForbidden},
+		{"post from another site", "POST", "127.0.0.1:8080", "http://evil.example", "secret-token", http.StatusForbidden},
+		{"post from the page", "POST", "127.0.0.1:8080", "http://127.0.0.1:8080", "secret-token", http.StatusOK},
+		{"put with token", "PUT", "127.0.0.1:8080", "", "secret-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/sync", nil)
+		req.Host = tt.host
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.token != "" {
+			req.Header.Set(csrfHeader, tt.token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	ws.handleIndex(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), `<meta name="emu-sync-csrf" content="secret-token">`) {
+		t.Error("index page should embed the CSRF token")
+	}
+}
+
+func TestHandleI18n(t *testing.T) {
+	ws := &webServer{}
+	tests := []struct {
+		path     string
+		wantLang string
+		wantCode int
+	}{
+		{"/api/i18n/de.json", "de", 200},
+		{"/api/i18n/de-AT.json", "de", 200},
+		{"/api/i18n/es-419.json", "es", 200},
+		{"/api/i18n/en-US.json", "en", 200},
+		{"/api/i18n/xx.json", "en", 200},
+		{"/api/i18n/de", "", 404},
+		{"/api/i18n/..%2Fen.json", "", 404},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ws.handleI18n(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.wantCode, rec.Code)
+			continue
+		}
+		if tt.wantCode != 200 {
+			continue
+		}
+		var resp i18nResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: decoding response: %v", tt.path, err)
+		}
+		if resp.Lang != tt.wantLang {
+			t.Errorf("%s: expected lang %q, got %q", tt.path, tt.wantLang, resp.Lang)
+		}
+		if resp.Messages["button.save"] == "" {
+			t.Errorf("%s: missing button.save message", tt.path)
+		}
+	}
+}
+
+// Every translation's messages must exist in English and use the same
+// placeholders, or the page would show raw {names} or lose values.
+func TestI18nTranslations(t *testing.T) {
+	en, err := readMessages("en")
+	if err != nil {
+		t.Fatalf("reading English messages: %v", err)
+	}
+	placeholder := regexp.MustCompile(`\{\w+\}`)
+	placeholders := func(s string) []string {
+		found := placeholder.FindAllString(s, -1)
+		sort.Strings(found)
+		return found
+	}
+
+	entries, err := webAssets.ReadDir("web_assets/i18n")
+	if err != nil {
+		t.Fatalf("listing translations: %v", err)
+	}
+	for _, entry := range entries {
+		lang := strings.TrimSuffix(entry.Name(), ".json")
+		messages, err := readMessages(lang)
+		if err != nil {
+			t.Errorf("%s: %v", lang, err)
+			continue
+		}
+		for key, text := range messages {
+			english, ok := en[key]
+			if !ok {
+				// Plural forms other than one and other are allowed
+				if i := strings.LastIndex(key, "."); i > 0 {
+					english, ok = en[key[:i]+".other"]
+				}
+			}
+			if !ok {
+				t.Errorf("%s: message %q isn't in en.json", lang, key)
+				continue
+			}
+			if got, want := placeholders(text), placeholders(english); !slices.Equal(got, want) {
+				t.Errorf("%s: message %q has placeholders %v, English has %v", lang, key, got, want)
+			}
+		}
+	}
+}
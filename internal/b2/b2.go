@@ -0,0 +1,123 @@
+// Package b2 uses Backblaze B2's native API for what its S3-compatible
+// API can't do, such as creating application keys.
+package b2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// authorizeURL is where every B2 session starts; a var for tests.
+var authorizeURL = "https://api.backblazeb2.com/b2api/v2/b2_authorize_account"
+
+// ReadOnlyCapabilities are what a device needs to sync: find the
+// bucket, list it, and download from it.
+var ReadOnlyCapabilities = []string{"listBuckets", "listFiles", "readFiles"}
+
+// IsEndpoint reports whether endpoint is one of B2's S3 endpoints.
+func IsEndpoint(endpoint string) bool {
+	return strings.Contains(endpoint, ".backblazeb2.com")
+}
+
+// Key is a newly created application key. B2 shows Secret only once.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// keyNameRe matches what B2 accepts as a key name.
+var keyNameRe = regexp.MustCompile(`^[A-Za-z0-9-]{1,100}$`)
+
+// CreateReadOnlyKey creates a key named name that can only list and
+// read files in bucket whose names start with prefix (all of them for
+// ""). keyID and appKey must have the writeKeys capability, as an
+// account's master key does.
+func CreateReadOnlyKey(ctx context.Context, client *http.Client, keyID, appKey, bucket, prefix, name string) (*Key, error) {
+	if !keyNameRe.MatchString(name) {
+		return nil, fmt.Errorf("key name %q: use up to 100 letters, digits, and dashes", name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authorizeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(keyID, appKey)
+	var auth struct {
+		AccountID          string `json:"accountId"`
+		APIURL             string `json:"apiUrl"`
+		AuthorizationToken string `json:"authorizationToken"`
+	}
+	if err := do(client, req, &auth); err != nil {
+		return nil, fmt.Errorf("authorizing with B2: %w", err)
+	}
+
+	call := func(op string, body, out any) error {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.APIURL+"/b2api/v2/"+op, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", auth.AuthorizationToken)
+		if err := do(client, req, out); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	}
+
+	var buckets struct {
+		Buckets []struct {
+			BucketID string `json:"bucketId"`
+		} `json:"buckets"`
+	}
+	if err := call("b2_list_buckets", map[string]string{"accountId": auth.AccountID, "bucketName": bucket}, &buckets); err != nil {
+		return nil, err
+	}
+	if len(buckets.Buckets) == 0 {
+		return nil, fmt.Errorf("bucket %s not found in this B2 account", bucket)
+	}
+
+	create := map[string]any{
+		"accountId":    auth.AccountID,
+		"capabilities": ReadOnlyCapabilities,
+		"keyName":      name,
+		"bucketId":     buckets.Buckets[0].BucketID,
+	}
+	if prefix != "" {
+		create["namePrefix"] = prefix
+	}
+	var key struct {
+		ApplicationKeyID string `json:"applicationKeyId"`
+		ApplicationKey   string `json:"applicationKey"`
+	}
+	if err := call("b2_create_key", create, &key); err != nil {
+		return nil, err
+	}
+	return &Key{ID: key.ApplicationKeyID, Secret: key.ApplicationKey}, nil
+}
+
+// do sends req and decodes a JSON response into out, turning B2's error
+// responses into errors.
+func do(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("%s: %s (%s)", resp.Status, e.Message, e.Code)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
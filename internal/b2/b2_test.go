@@ -0,0 +1,63 @@
+package b2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCreateReadOnlyKey(t *testing.T) {
+	var created map[string]any
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/b2api/v2/b2_authorize_account":
+			if id, secret, _ := r.BasicAuth(); id != "master" || secret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"code":"unauthorized","message":"bad key"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"accountId": "acct", "apiUrl": srv.URL, "authorizationToken": "tok"})
+		case "/b2api/v2/b2_list_buckets":
+			json.NewEncoder(w).Encode(map[string]any{"buckets": []map[string]string{{"bucketId": "bkt1"}}})
+		case "/b2api/v2/b2_create_key":
+			if r.Header.Get("Authorization") != "tok" {
+				t.Errorf("create_key without the session token")
+			}
+			json.NewDecoder(r.Body).Decode(&created)
+			json.NewEncoder(w).Encode(map[string]string{"applicationKeyId": "new-id", "applicationKey": "new-secret"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	authorizeURL = srv.URL + "/b2api/v2/b2_authorize_account"
+
+	key, err := CreateReadOnlyKey(context.Background(), srv.Client(), "master", "secret", "roms", "games/", "emu-sync-family")
+	if err != nil {
+		t.Fatalf("CreateReadOnlyKey: %v", err)
+	}
+	if key.ID != "new-id" || key.Secret != "new-secret" {
+		t.Errorf("key = %+v", key)
+	}
+	if created["bucketId"] != "bkt1" || created["namePrefix"] != "games/" || created["keyName"] != "emu-sync-family" {
+		t.Errorf("create_key request = %v", created)
+	}
+	var caps []string
+	for _, c := range created["capabilities"].([]any) {
+		caps = append(caps, c.(string))
+	}
+	if !slices.Equal(caps, ReadOnlyCapabilities) {
+		t.Errorf("capabilities = %v, want read-only", caps)
+	}
+
+	if _, err := CreateReadOnlyKey(context.Background(), srv.Client(), "master", "wrong", "roms", "", "k"); err == nil {
+		t.Error("want an error for a rejected key")
+	}
+	if _, err := CreateReadOnlyKey(context.Background(), srv.Client(), "master", "secret", "roms", "", "bad name!"); err == nil {
+		t.Error("want an error for an invalid key name")
+	}
+}
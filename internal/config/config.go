@@ -1,36 +1,252 @@
 package config
 
 import (
+	"bytes"
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 
+	"github.com/jacobfgrant/emu-sync/internal/systems"
+	"github.com/jacobfgrant/emu-sync/internal/units"
 	"github.com/pelletier/go-toml/v2"
 )
 
 // StorageConfig holds S3-compatible storage credentials and settings.
+// Leaving KeyID and SecretKey empty uses the AWS default credential chain
+// (environment, shared config, SSO, or an EC2/ECS instance role).
 type StorageConfig struct {
-	EndpointURL string `toml:"endpoint_url"`
-	Bucket      string `toml:"bucket"`
-	KeyID       string `toml:"key_id"`
-	SecretKey   string `toml:"secret_key"`
-	Region      string `toml:"region"`
-	Prefix      string `toml:"prefix,omitempty"`
+	EndpointURL string     `toml:"endpoint_url"`
+	Bucket      string     `toml:"bucket"`
+	KeyID       string     `toml:"key_id"`
+	SecretKey   string     `toml:"secret_key"`
+	Region      string     `toml:"region"`
+	Prefix      string     `toml:"prefix,omitempty"`
+	ReadOnly    bool       `toml:"read_only,omitempty"` // key can only read; upload and watch refuse to run
+	Cost        CostConfig `toml:"cost,omitempty"`
+}
+
+// CostConfig holds provider pricing used to estimate transfer costs.
+// Prices are in dollars per GB; zero means free or unknown.
+type CostConfig struct {
+	EgressPerGB  float64 `toml:"egress_per_gb,omitempty"`
+	IngressPerGB float64 `toml:"ingress_per_gb,omitempty"`
+}
+
+// Enabled returns true if any pricing is configured.
+func (c CostConfig) Enabled() bool {
+	return c.EgressPerGB > 0 || c.IngressPerGB > 0
+}
+
+// Estimate returns the estimated dollar cost of uploading and
+// downloading the given number of bytes.
+func (c CostConfig) Estimate(uploaded, downloaded int64) float64 {
+	const gb = 1024 * 1024 * 1024
+	return float64(uploaded)/gb*c.IngressPerGB + float64(downloaded)/gb*c.EgressPerGB
 }
 
 // SyncConfig holds local sync settings.
 type SyncConfig struct {
-	EmulationPath  string   `toml:"emulation_path"`
-	SyncDirs       []string `toml:"sync_dirs"`
-	SyncExclude    []string `toml:"sync_exclude,omitempty"`
-	Delete         bool     `toml:"delete"`
-	Workers        int      `toml:"workers"`
-	MaxRetries     int      `toml:"max_retries"`
-	BandwidthLimit string   `toml:"bandwidth_limit,omitempty"`
-	SaveThreshold  string   `toml:"save_threshold,omitempty"`
-	SkipDotfiles   *bool    `toml:"skip_dotfiles,omitempty"`
+	EmulationPath   string                  `toml:"emulation_path"`
+	SyncDirs        []string                `toml:"sync_dirs"`
+	SyncExclude     []string                `toml:"sync_exclude,omitempty"`
+	Delete          bool                    `toml:"delete"`
+	DeleteDeselect  *bool                   `toml:"delete_on_deselect,omitempty"`       // delete files still in the library but no longer selected; nil = delete
+	DeleteRemoved   *bool                   `toml:"delete_on_remote_removal,omitempty"` // delete files removed from the library; nil = delete
+	Workers         int                     `toml:"workers"`
+	MaxRetries      int                     `toml:"max_retries"`
+	BandwidthLimit  string                  `toml:"bandwidth_limit,omitempty"`
+	SaveThreshold   string                  `toml:"save_threshold,omitempty"`
+	SkipDotfiles    *bool                   `toml:"skip_dotfiles,omitempty"`
+	OwnedDirs       []string                `toml:"owned_dirs,omitempty"`
+	DeleteThreshold float64                 `toml:"delete_threshold,omitempty"`
+	MaxDuration     string                  `toml:"max_duration,omitempty"`
+	OnBattery       string                  `toml:"on_battery,omitempty"`      // scheduled syncs on battery: "defer", "throttle", or "normal" (default)
+	StagingDir      string                  `toml:"staging_dir,omitempty"`     // download here, then move into place; "" = next to the destination
+	Dedupe          *bool                   `toml:"dedupe,omitempty"`          // link identical files instead of downloading them again; nil = true
+	OverlayDir      string                  `toml:"overlay_dir,omitempty"`     // local files that replace their library counterparts, laid out like the library
+	CaseCollisions  string                  `toml:"case_collisions,omitempty"` // upload with keys differing only in case: "fail" (default) or "rename"
+	Webhook         string                  `toml:"webhook,omitempty"`         // URL that receives sync summaries and warnings as JSON POSTs
+	Order           string                  `toml:"order,omitempty"`           // transfer order: "size" (default, smallest first) or "path"
+	Priority        []string                `toml:"priority,omitempty"`        // directories transferred before the rest, in the order listed
+	UserSelections  string                  `toml:"user_selections,omitempty"` // glob of per-user selection files on a shared machine, e.g. "/home/*/.config/emu-sync/selection.toml"
+	Tuning          map[string]TuningConfig `toml:"tuning,omitempty"`
+}
+
+// DedupeEnabled reports whether files identical to ones already synced
+// are linked rather than downloaded.
+func (s SyncConfig) DedupeEnabled() bool {
+	return s.Dedupe == nil || *s.Dedupe
+}
+
+// DeletesFile reports whether sync deletes a local file it no longer
+// syncs. inLibrary says why: true if the file is still in the library
+// but was deselected, false if it was removed from the library. Each
+// case has its own setting, falling back to delete.
+func (s SyncConfig) DeletesFile(inLibrary bool) bool {
+	setting := s.DeleteRemoved
+	if inLibrary {
+		setting = s.DeleteDeselect
+	}
+	if setting == nil {
+		return s.Delete
+	}
+	return *setting
+}
+
+// OnBatteryModes lists the accepted sync.on_battery values.
+var OnBatteryModes = []string{"defer", "throttle", "normal"}
+
+// CaseCollisionModes lists the accepted sync.case_collisions values.
+var CaseCollisionModes = []string{"fail", "rename"}
+
+// TransferOrders lists the accepted sync.order values.
+var TransferOrders = []string{"size", "path"}
+
+// TuningConfig overrides transfer settings for files under a directory
+// (e.g., [sync.tuning."roms/ps2"]). Zero values inherit the defaults.
+type TuningConfig struct {
+	Workers      int      `toml:"workers,omitempty"`
+	MaxRetries   int      `toml:"max_retries,omitempty"`
+	StorageClass string   `toml:"storage_class,omitempty"` // S3 storage class for uploads, e.g. STANDARD_IA
+	KeepVersions int      `toml:"keep_versions,omitempty"` // previous versions upload archives when a file is replaced
+	Tags         []string `toml:"tags,omitempty"`          // labels upload records in the manifest for files here, e.g. ["mature"] for policy.deny_tags
+}
+
+// StorageClasses lists the storage_class values accepted in tuning.
+// Classes other than STANDARD and INTELLIGENT_TIERING charge for
+// retrieval; GLACIER and DEEP_ARCHIVE objects must be restored before
+// they can be downloaded.
+var StorageClasses = []string{
+	"STANDARD", "STANDARD_IA", "ONEZONE_IA", "INTELLIGENT_TIERING",
+	"GLACIER_IR", "GLACIER", "DEEP_ARCHIVE",
+}
+
+// StorageClassFor returns the storage class for key from the longest
+// matching tuning directory that sets one, or "" for the bucket default.
+func StorageClassFor(key string, tuning map[string]TuningConfig) string {
+	best, class := "", ""
+	for dir, t := range tuning {
+		if t.StorageClass != "" && matchesDir(key, dir) && len(dir) > len(best) {
+			best, class = dir, t.StorageClass
+		}
+	}
+	return class
+}
+
+// KeepVersionsFor returns how many previous versions of key upload keeps,
+// from the longest matching tuning directory that sets keep_versions, or
+// 0 to keep none.
+func KeepVersionsFor(key string, tuning map[string]TuningConfig) int {
+	best, keep := "", 0
+	for dir, t := range tuning {
+		if t.KeepVersions > 0 && matchesDir(key, dir) && len(dir) > len(best) {
+			best, keep = dir, t.KeepVersions
+		}
+	}
+	return keep
+}
+
+// TagsFor returns the tags of every tuning directory key lies under,
+// sorted, or nil if there are none.
+func TagsFor(key string, tuning map[string]TuningConfig) []string {
+	var tags []string
+	for dir, t := range tuning {
+		if matchesDir(key, dir) {
+			tags = append(tags, t.Tags...)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// matchesDir reports whether key is dir or lies beneath it.
+func matchesDir(key, dir string) bool {
+	d := strings.TrimSuffix(dir, "/")
+	return key == d || strings.HasPrefix(key, d+"/")
+}
+
+// SortTransfers sorts keys into the order they're transferred in, so
+// runs are reproducible: keys under the priority directories first, in
+// the order the directories are listed, then the rest. Within each group
+// keys are ordered by path for order "path", and otherwise smallest
+// first (by size), so many small files finish before a large one ties
+// up a worker; equal sizes fall back to path order.
+func SortTransfers(keys []string, order string, priority []string, size func(string) int64) {
+	rank := func(key string) int {
+		for i, dir := range priority {
+			if matchesDir(key, dir) {
+				return i
+			}
+		}
+		return len(priority)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+			return c
+		}
+		if order != "path" {
+			if c := cmp.Compare(size(a), size(b)); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// TransferBatch is a set of keys that share the same transfer settings.
+type TransferBatch struct {
+	Dir        string // tuning directory; "" for keys using the defaults
+	Keys       []string
+	Workers    int
+	MaxRetries int
+}
+
+// BatchByTuning splits keys into batches by their longest matching tuning
+// directory that overrides workers or max_retries; keys without one use
+// the given defaults. Batches come in the order of their first key, and
+// key order is preserved within each, so the order SortTransfers chose
+// holds across batches as far as batching allows.
+func BatchByTuning(keys []string, tuning map[string]TuningConfig, workers, maxRetries int) []TransferBatch {
+	if len(tuning) == 0 {
+		return []TransferBatch{{Keys: keys, Workers: workers, MaxRetries: maxRetries}}
+	}
+
+	var batches []TransferBatch
+	index := make(map[string]int) // dir -> its batch in batches
+	for _, key := range keys {
+		best := ""
+		for dir, t := range tuning {
+			if (t.Workers > 0 || t.MaxRetries > 0) && matchesDir(key, dir) && len(dir) > len(best) {
+				best = dir
+			}
+		}
+		i, ok := index[best]
+		if !ok {
+			i = len(batches)
+			index[best] = i
+			b := TransferBatch{Dir: best, Workers: workers, MaxRetries: maxRetries}
+			if t := tuning[best]; best != "" {
+				if t.Workers > 0 {
+					b.Workers = t.Workers
+				}
+				if t.MaxRetries > 0 {
+					b.MaxRetries = t.MaxRetries
+				}
+			}
+			batches = append(batches, b)
+		}
+		batches[i].Keys = append(batches[i].Keys, key)
+	}
+	return batches
 }
 
 // WebConfig holds settings for the web UI.
@@ -38,16 +254,120 @@ type WebConfig struct {
 	Port int `toml:"port,omitempty"`
 }
 
+// DisplayConfig controls how output is formatted.
+type DisplayConfig struct {
+	Units string `toml:"units,omitempty"` // sizes in "iec" (KiB, MiB; default) or "si" (KB, MB, as providers bill); see units.Systems
+}
+
+// TriggerConfig holds push triggers: uploads POST to URLs after
+// publishing, and sync --follow listens for those POSTs, so devices sync
+// within seconds instead of at their next poll.
+type TriggerConfig struct {
+	Listen string   `toml:"listen,omitempty"` // address sync --follow serves /api/trigger on, e.g. ":8771"
+	Token  string   `toml:"token,omitempty"`  // shared bearer token; required with listen or urls
+	URLs   []string `toml:"urls,omitempty"`   // trigger endpoints upload and watch POST to after publishing
+}
+
+// NotificationsConfig controls notifications about syncs.
+type NotificationsConfig struct {
+	Desktop bool `toml:"desktop,omitempty"` // show a desktop notification after each scheduled sync that changes something or fails
+}
+
+// UpdateConfig controls the background check for new releases.
+type UpdateConfig struct {
+	Notify *bool `toml:"notify,omitempty"` // print a notice when a newer version exists; nil = true
+}
+
+// NotifyEnabled reports whether update notices should be shown.
+func (u UpdateConfig) NotifyEnabled() bool {
+	return u.Notify == nil || *u.Notify
+}
+
+// NetworkConfig holds HTTP settings for reaching the storage endpoint and
+// the update checker, e.g. a self-hosted MinIO with a self-signed cert.
+type NetworkConfig struct {
+	Proxy              string `toml:"proxy,omitempty"`                // proxy URL; "" = HTTP_PROXY/HTTPS_PROXY from the environment
+	CABundle           string `toml:"ca_bundle,omitempty"`            // PEM file of extra trusted CAs
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify,omitempty"` // storage only; never used for updates
+}
+
+// Transport returns an HTTP transport with the proxy and TLS settings
+// applied. allowInsecure controls whether InsecureSkipVerify is honored.
+func (n NetworkConfig) Transport(allowInsecure bool) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if n.Proxy != "" {
+		u, err := url.Parse(n.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", n.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if n.CABundle == "" && !(allowInsecure && n.InsecureSkipVerify) {
+		return t, nil
+	}
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: allowInsecure && n.InsecureSkipVerify}
+	if n.CABundle != "" {
+		pem, err := os.ReadFile(n.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle %s contains no PEM certificates", n.CABundle)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	t.TLSClientConfig = tlsCfg
+	return t, nil
+}
+
+// SystemConfig overrides how a system directory is displayed in choose
+// and the web UI (e.g., [systems."roms/snes"] or [systems.snes]).
+type SystemConfig struct {
+	Name string `toml:"name,omitempty"`
+	Icon string `toml:"icon,omitempty"`
+}
+
+// SystemOverrides converts the [systems] table for systems.Lookup.
+func (c *Config) SystemOverrides() map[string]systems.Info {
+	overrides := make(map[string]systems.Info, len(c.Systems))
+	for dir, sc := range c.Systems {
+		overrides[dir] = systems.Info{Name: sc.Name, Icon: sc.Icon}
+	}
+	return overrides
+}
+
 // Config is the top-level configuration.
 type Config struct {
-	Storage StorageConfig `toml:"storage"`
-	Sync    SyncConfig    `toml:"sync"`
-	Web     WebConfig     `toml:"web,omitempty"`
+	Version       int                     `toml:"config_version"` // file format version; see CurrentVersion
+	Storage       StorageConfig           `toml:"storage"`
+	Sync          SyncConfig              `toml:"sync"`
+	Web           WebConfig               `toml:"web,omitempty"`
+	Network       NetworkConfig           `toml:"network,omitempty"`
+	Update        UpdateConfig            `toml:"update,omitempty"`
+	Display       DisplayConfig           `toml:"display,omitempty"`
+	Trigger       TriggerConfig           `toml:"trigger,omitempty"`
+	Paths         PathsConfig             `toml:"paths,omitempty"`
+	Notifications NotificationsConfig     `toml:"notifications,omitempty"`
+	Policy        PolicyConfig            `toml:"policy,omitempty"`
+	Systems       map[string]SystemConfig `toml:"systems,omitempty"`
+
+	// Users holds each user's selections on a shared machine, by user
+	// name, loaded from sync.user_selections. ShouldSync selects their
+	// union with the config's own.
+	Users map[string]Selection `toml:"-"`
 }
 
-// DefaultConfigPath returns the config file path, using XDG_CONFIG_HOME
-// if set, otherwise ~/.config.
+// DefaultConfigPath returns the config file path: EMU_SYNC_CONFIG if
+// set, otherwise config.toml under XDG_CONFIG_HOME or ~/.config.
 func DefaultConfigPath() string {
+	if path := os.Getenv("EMU_SYNC_CONFIG"); path != "" {
+		return path
+	}
 	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
 		return filepath.Join(dir, "emu-sync", "config.toml")
 	}
@@ -55,66 +375,199 @@ func DefaultConfigPath() string {
 	return filepath.Join(home, ".config", "emu-sync", "config.toml")
 }
 
-// DefaultLocalManifestPath returns the local manifest path, using
-// XDG_DATA_HOME if set, otherwise ~/.local/share.
+// DefaultLocalManifestPath returns the local manifest path in StateDir,
+// named for the current library and emulation path (see TargetFile).
 func DefaultLocalManifestPath() string {
-	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
-		return filepath.Join(dir, "emu-sync", "local-manifest.json")
-	}
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".local", "share", "emu-sync", "local-manifest.json")
+	return statePath("local-manifest.json", TargetFile("local-manifest.json"))
+}
+
+// DefaultScanCachePath returns the path of the directory listings sync
+// uses to spot deleted files, named for the current library and
+// emulation path like the local manifest.
+func DefaultScanCachePath() string {
+	return statePath("scan-cache.json", TargetFile("scan-cache.json"))
 }
 
-// DefaultUploadCachePath returns the upload hash cache path, using
-// XDG_DATA_HOME if set, otherwise ~/.local/share.
+// DefaultUploadCachePath returns the upload hash cache path in StateDir,
+// named for the current library.
 func DefaultUploadCachePath() string {
-	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
-		return filepath.Join(dir, "emu-sync", "upload-cache.json")
-	}
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".local", "share", "emu-sync", "upload-cache.json")
+	return statePath("upload-cache.json", LibraryFile("upload-cache.json"))
 }
 
-// Load reads and parses a TOML config file.
+// DefaultPendingManifestPath returns where upload keeps a manifest it
+// couldn't publish, to retry, named for the current library.
+func DefaultPendingManifestPath() string {
+	return statePath("pending-manifest.json", LibraryFile("pending-manifest.json"))
+}
+
+// DefaultBucketCachePath returns the path of the cache of bucket object
+// hashes used by upload --from-bucket, named for the current library.
+func DefaultBucketCachePath() string {
+	return statePath("bucket-cache.json", LibraryFile("bucket-cache.json"))
+}
+
+// DefaultUsagePath returns the bandwidth usage stats path in StateDir.
+func DefaultUsagePath() string {
+	return filepath.Join(StateDir(), "usage.json")
+}
+
+// DefaultUpdateCheckPath returns the cached update check path in StateDir.
+func DefaultUpdateCheckPath() string {
+	return filepath.Join(StateDir(), "update-check.json")
+}
+
+// DefaultLastSyncPath returns the path where the last sync result is
+// saved in StateDir.
+func DefaultLastSyncPath() string {
+	return filepath.Join(StateDir(), "last-sync.json")
+}
+
+// DefaultRemoteVerifyPath returns the path where verify --remote --deep
+// records which bucket objects it has re-hashed, in StateDir.
+func DefaultRemoteVerifyPath() string {
+	return filepath.Join(StateDir(), "remote-verify.json")
+}
+
+// Load reads and parses a TOML config file. Files from older versions
+// of emu-sync are upgraded to CurrentVersion, and rewritten in place if
+// a setting had to change. It also sets the process's size units from
+// display.units (see units.SetSystem), and the state directory from
+// paths.state_dir (see StateDir), and the library its state files are
+// named for (see LibraryFile and TargetFile). On a shared machine it
+// loads each user's selections too (see Users).
 func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	original, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
+	data, from, rewrite, err := upgrade(original, migrations)
+	if err != nil {
+		return nil, err
+	}
 
 	var cfg Config
 	if err := toml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config file: %w", err)
 	}
+	cfg.Version = CurrentVersion
 
-	if err := cfg.validate(); err != nil {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadUsers(); err != nil {
 		return nil, err
 	}
+	units.SetSystem(cfg.Display.Units)
+	configStateDir.Store(cfg.Paths.StateDir)
+	libraryID.Store(cfg.Storage.LibraryID())
+	targetID.Store(cfg.TargetID())
 
+	if rewrite {
+		saveUpgraded(&cfg, path, original, from)
+	}
 	return &cfg, nil
 }
 
-func (c *Config) validate() error {
+// Validate checks a config the way Load does, filling in defaults and
+// expanding paths. Use it on configs edited outside the file, such as
+// from the web UI.
+func (c *Config) Validate() error {
 	if c.Storage.Bucket == "" {
 		return fmt.Errorf("config: storage.bucket is required")
 	}
-	if c.Storage.KeyID == "" {
-		return fmt.Errorf("config: storage.key_id is required")
+	// Both empty means use the AWS default credential chain
+	if c.Storage.KeyID == "" && c.Storage.SecretKey != "" {
+		return fmt.Errorf("config: storage.key_id is required when secret_key is set")
 	}
-	if c.Storage.SecretKey == "" {
-		return fmt.Errorf("config: storage.secret_key is required")
+	if c.Storage.SecretKey == "" && c.Storage.KeyID != "" {
+		return fmt.Errorf("config: storage.secret_key is required when key_id is set")
 	}
 	if c.Sync.EmulationPath == "" {
 		return fmt.Errorf("config: sync.emulation_path is required")
 	}
 	c.Sync.EmulationPath = expandPath(c.Sync.EmulationPath)
+	if c.Sync.UserSelections != "" {
+		c.Sync.UserSelections = expandPath(c.Sync.UserSelections)
+		if _, err := filepath.Match(c.Sync.UserSelections, ""); err != nil {
+			return fmt.Errorf("config: sync.user_selections: %w", err)
+		}
+	}
 	if len(c.Sync.SyncDirs) == 0 {
 		c.Sync.SyncDirs = []string{"roms", "bios"}
+		// On a shared machine the users choose the games.
+		if c.Sync.UserSelections != "" {
+			c.Sync.SyncDirs = []string{"bios"}
+		}
 	}
 	if c.Sync.SkipDotfiles == nil {
 		t := true
 		c.Sync.SkipDotfiles = &t
 	}
+	if c.Sync.StagingDir != "" {
+		c.Sync.StagingDir = expandPath(c.Sync.StagingDir)
+	}
+	if c.Sync.OverlayDir != "" {
+		c.Sync.OverlayDir = expandPath(c.Sync.OverlayDir)
+	}
+	if c.Network.CABundle != "" {
+		c.Network.CABundle = expandPath(c.Network.CABundle)
+	}
+	if c.Paths.StateDir != "" {
+		c.Paths.StateDir = expandPath(c.Paths.StateDir)
+	}
+	if _, err := c.Network.Transport(true); err != nil {
+		return fmt.Errorf("config: network: %w", err)
+	}
+	if c.Sync.Workers < 0 {
+		return fmt.Errorf("config: sync.workers cannot be negative")
+	}
+	if c.Sync.MaxRetries < 0 {
+		return fmt.Errorf("config: sync.max_retries cannot be negative")
+	}
+	if _, err := ParseBandwidthLimit(c.Sync.BandwidthLimit); err != nil {
+		return fmt.Errorf("config: sync.bandwidth_limit: %w", err)
+	}
+	if c.Sync.OnBattery != "" && !slices.Contains(OnBatteryModes, c.Sync.OnBattery) {
+		return fmt.Errorf("config: sync.on_battery %q must be one of %s",
+			c.Sync.OnBattery, strings.Join(OnBatteryModes, ", "))
+	}
+	if err := c.Policy.Validate(); err != nil {
+		return err
+	}
+	if c.Sync.Order != "" && !slices.Contains(TransferOrders, c.Sync.Order) {
+		return fmt.Errorf("config: sync.order %q must be one of %s",
+			c.Sync.Order, strings.Join(TransferOrders, ", "))
+	}
+	if c.Display.Units != "" && !slices.Contains(units.Systems, c.Display.Units) {
+		return fmt.Errorf("config: display.units %q must be one of %s",
+			c.Display.Units, strings.Join(units.Systems, ", "))
+	}
+	if c.Sync.CaseCollisions != "" && !slices.Contains(CaseCollisionModes, c.Sync.CaseCollisions) {
+		return fmt.Errorf("config: sync.case_collisions %q must be one of %s",
+			c.Sync.CaseCollisions, strings.Join(CaseCollisionModes, ", "))
+	}
+	if c.Sync.Webhook != "" {
+		if u, err := url.Parse(c.Sync.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: sync.webhook %q must be an http or https URL", c.Sync.Webhook)
+		}
+	}
+	if (c.Trigger.Listen != "" || len(c.Trigger.URLs) > 0) && c.Trigger.Token == "" {
+		return fmt.Errorf("config: trigger.token is required with trigger.listen or trigger.urls")
+	}
+	for _, t := range c.Trigger.URLs {
+		if u, err := url.Parse(t); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: trigger.urls entry %q must be an http or https URL", t)
+		}
+	}
+	for dir, t := range c.Sync.Tuning {
+		if t.StorageClass != "" && !slices.Contains(StorageClasses, t.StorageClass) {
+			return fmt.Errorf("config: sync.tuning.%q.storage_class %q must be one of %s",
+				dir, t.StorageClass, strings.Join(StorageClasses, ", "))
+		}
+		if t.KeepVersions < 0 {
+			return fmt.Errorf("config: sync.tuning.%q.keep_versions cannot be negative", dir)
+		}
+	}
 	return nil
 }
 
@@ -163,21 +616,88 @@ func (c *Config) ValidateEmulationPath() error {
 // ShouldSync returns true if the given key passes the sync_dirs include
 // filter and is not in sync_exclude. Keys match sync_dirs by prefix
 // (e.g., "roms/snes" matches "roms/snes/Game.sfc") or exact match
-// (for individual file entries).
+// (for individual file entries). On a shared machine, a key any user
+// selects is synced too (see Users).
 func (c *Config) ShouldSync(key string) bool {
-	for _, ex := range c.Sync.SyncExclude {
-		if key == ex || strings.HasPrefix(key, ex+"/") {
-			return false
-		}
+	if (Selection{SyncDirs: c.Sync.SyncDirs, SyncExclude: c.Sync.SyncExclude}).Selects(key) {
+		return true
 	}
-	for _, dir := range c.Sync.SyncDirs {
-		if key == dir || strings.HasPrefix(key, dir+"/") {
+	for _, sel := range c.Users {
+		if sel.Selects(key) {
 			return true
 		}
 	}
 	return false
 }
 
+// CheckSelections compares sync_dirs and sync_exclude with the keys in
+// the library and describes entries that don't do what they appear to:
+// sync_dirs that match nothing or are already covered, and excludes that
+// match nothing, lie outside sync_dirs, or repeat another exclude. Stale
+// entries like these silently shrink what gets synced.
+func (c *Config) CheckSelections(keys []string) []string {
+	matchesAny := func(entry string) bool {
+		for _, key := range keys {
+			if matchesDir(key, entry) {
+				return true
+			}
+		}
+		return false
+	}
+	// covering returns the entry in list, other than entry itself, that
+	// contains entry.
+	covering := func(entry string, list []string) string {
+		for _, other := range list {
+			if other != entry && matchesDir(entry, other) {
+				return other
+			}
+		}
+		return ""
+	}
+
+	var problems []string
+	for _, dir := range c.Sync.SyncDirs {
+		switch {
+		case covering(dir, c.Sync.SyncDirs) != "":
+			problems = append(problems, fmt.Sprintf("sync_dirs entry %q is already covered by %q", dir, covering(dir, c.Sync.SyncDirs)))
+		case covering(dir, c.Sync.SyncExclude) != "" || slices.Contains(c.Sync.SyncExclude, dir):
+			problems = append(problems, fmt.Sprintf("sync_dirs entry %q is excluded by sync_exclude, so nothing from it syncs", dir))
+		case !matchesAny(dir):
+			problems = append(problems, fmt.Sprintf("sync_dirs entry %q matches nothing in the library", dir))
+		}
+	}
+	for _, ex := range c.Sync.SyncExclude {
+		switch {
+		case covering(ex, c.Sync.SyncExclude) != "":
+			problems = append(problems, fmt.Sprintf("sync_exclude entry %q is already covered by %q", ex, covering(ex, c.Sync.SyncExclude)))
+		case covering(ex, c.Sync.SyncDirs) == "" && !slices.Contains(c.Sync.SyncDirs, ex):
+			problems = append(problems, fmt.Sprintf("sync_exclude entry %q is outside sync_dirs, so it has no effect", ex))
+		case !matchesAny(ex):
+			problems = append(problems, fmt.Sprintf("sync_exclude entry %q matches nothing in the library", ex))
+		}
+	}
+	return problems
+}
+
+// SyncDeleteThreshold returns the fraction of local files a sync may
+// delete because they vanished from the bucket. Defaults to 0.5 when
+// delete_threshold is not set; values of 1 or more disable the check.
+func (c *Config) SyncDeleteThreshold() float64 {
+	if c.Sync.DeleteThreshold <= 0 {
+		return 0.5
+	}
+	return c.Sync.DeleteThreshold
+}
+
+// UploadOwnedDirs returns the directories this uploader owns in merge
+// mode. Defaults to sync_dirs when owned_dirs is not set.
+func (c *Config) UploadOwnedDirs() []string {
+	if len(c.Sync.OwnedDirs) > 0 {
+		return c.Sync.OwnedDirs
+	}
+	return c.Sync.SyncDirs
+}
+
 // ParseBandwidthLimit parses a human-readable bandwidth string (e.g.,
 // "10MB", "500KB", "1024") into bytes per second. Returns 0 for empty
 // string or "0" (unlimited).
@@ -217,20 +737,96 @@ func ParseBandwidthLimit(s string) (int64, error) {
 	return int64(n * float64(multiplier)), nil
 }
 
-// Write serializes a Config to TOML and writes it to the given path.
+// Write serializes a Config to TOML and saves it to the given path. The
+// new file is written alongside the old one, flushed to disk, and renamed
+// into place, so a crash never leaves a truncated config, and the
+// previous version is kept as path.bak. Saves from the web UI, choose,
+// and other processes hold a lock on path.lock so their writes can't
+// interleave, but each writes the config it loaded earlier: when two
+// save at once, the last writer wins. The file is stamped with
+// CurrentVersion.
 func Write(cfg *Config, path string) error {
+	// Write through a symlink (e.g. a dotfiles checkout) instead of
+	// replacing it.
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("creating config directory: %w", err)
 	}
 
-	data, err := toml.Marshal(cfg)
+	current := *cfg
+	current.Version = CurrentVersion
+	data, err := toml.Marshal(&current)
 	if err != nil {
 		return fmt.Errorf("serializing config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0o600); err != nil {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking config file: %w", err)
+	}
+	defer unlock()
+
+	old, err := os.ReadFile(path)
+	if err == nil {
+		if bytes.Equal(old, data) {
+			return nil
+		}
+		if err := os.WriteFile(path+".bak", old, 0o600); err != nil {
+			return fmt.Errorf("backing up config file: %w", err)
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := writeFileSync(tmpPath, data); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("writing config file: %w", err)
 	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing config file: %w", err)
+	}
+	// The rename itself is only durable once the directory is.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
 
 	return nil
 }
+
+// writeFileSync writes data to path and flushes it to disk before
+// returning, so a rename over another file never exposes a partial one.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lockFile takes an exclusive lock on path, waiting for any other holder,
+// and returns a function that releases it.
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -1,9 +1,16 @@
 package config
 
 import (
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -72,6 +79,34 @@ secret_key = "xyz"
 	}
 }
 
+func TestLoadDefaultCredentialChain(t *testing.T) {
+	toml := `
+[storage]
+bucket = "b"
+[sync]
+emulation_path = "/tmp"
+`
+	path := writeTempConfig(t, toml)
+	if _, err := Load(path); err != nil {
+		t.Fatalf("empty key_id and secret_key should be allowed: %v", err)
+	}
+}
+
+func TestLoadPartialCredentials(t *testing.T) {
+	toml := `
+[storage]
+bucket = "b"
+key_id = "abc"
+[sync]
+emulation_path = "/tmp"
+`
+	path := writeTempConfig(t, toml)
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "secret_key") {
+		t.Fatalf("expected secret_key error, got %v", err)
+	}
+}
+
 func TestLoadDefaultSyncDirs(t *testing.T) {
 	toml := `
 [storage]
@@ -139,6 +174,78 @@ func TestWriteAndLoad(t *testing.T) {
 	}
 }
 
+func TestWriteKeepsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := Write(&Config{Storage: StorageConfig{Bucket: "first"}}, path); err != nil {
+		t.Fatalf("first Write: %v", err)
+	}
+	if err := Write(&Config{Storage: StorageConfig{Bucket: "second"}}, path); err != nil {
+		t.Fatalf("second Write: %v", err)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if !strings.Contains(string(backup), "first") {
+		t.Errorf("backup should hold the previous version, got:\n%s", backup)
+	}
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(current), "second") {
+		t.Errorf("config should hold the new version, got:\n%s", current)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temp file left behind")
+	}
+}
+
+func TestWriteConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				cfg := &Config{Storage: StorageConfig{Bucket: fmt.Sprintf("bucket-%d-%d", i, j)}}
+				if err := Write(cfg, path); err != nil {
+					t.Errorf("Write: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	if n := strings.Count(string(data), "bucket = "); n != 1 {
+		t.Errorf("config has %d bucket lines, want 1 (interleaved writes?):\n%s", n, data)
+	}
+}
+
+func TestWriteThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles-config.toml")
+	os.WriteFile(target, []byte("[storage]\nbucket = \"old\"\n"), 0o600)
+	link := filepath.Join(dir, "config.toml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	if err := Write(&Config{Storage: StorageConfig{Bucket: "new"}}, link); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced by a regular file")
+	}
+	data, _ := os.ReadFile(target)
+	if !strings.Contains(string(data), "new") {
+		t.Errorf("target not updated:\n%s", data)
+	}
+}
+
 func TestExpandTildePath(t *testing.T) {
 	toml := `
 [storage]
@@ -285,14 +392,14 @@ func TestShouldSync(t *testing.T) {
 		want bool
 	}{
 		{"roms/snes/Game.sfc", true},
-		{"roms/gba/Game.gba", false},  // excluded by directory prefix
-		{"roms/gba", false},           // exact match on excluded dir
+		{"roms/gba/Game.gba", false},    // excluded by directory prefix
+		{"roms/gba", false},             // exact match on excluded dir
 		{"roms/gbatest/Game.gba", true}, // "roms/gba" prefix but not "roms/gba/"
 		{"bios/scph5501.bin", true},
 		{"saves/game.sav", false},
-		{"roms/snes/Bad.sfc", false},  // excluded by exact match
-		{"roms", true},                // exact dir match
-		{"romshack/file", false},      // "roms" prefix but not "roms/"
+		{"roms/snes/Bad.sfc", false}, // excluded by exact match
+		{"roms", true},               // exact dir match
+		{"romshack/file", false},     // "roms" prefix but not "roms/"
 	}
 
 	for _, tt := range tests {
@@ -315,6 +422,417 @@ func TestShouldSyncEmptyExclude(t *testing.T) {
 	}
 }
 
+func TestCostEstimate(t *testing.T) {
+	cost := CostConfig{EgressPerGB: 0.01, IngressPerGB: 0.002}
+	if !cost.Enabled() {
+		t.Fatal("expected pricing to be enabled")
+	}
+	const gb = 1024 * 1024 * 1024
+	got := cost.Estimate(5*gb, 10*gb)
+	if got < 0.1099 || got > 0.1101 {
+		t.Errorf("Estimate = %v, want 0.11", got)
+	}
+	if (CostConfig{}).Enabled() {
+		t.Error("empty pricing should not be enabled")
+	}
+}
+
+func TestLoadCostConfig(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`
+[storage.cost]
+egress_per_gb = 0.01
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Storage.Cost.EgressPerGB != 0.01 {
+		t.Errorf("egress_per_gb = %v, want 0.01", cfg.Storage.Cost.EgressPerGB)
+	}
+}
+
+func TestSyncDeleteThresholdDefault(t *testing.T) {
+	cfg := &Config{}
+	if got := cfg.SyncDeleteThreshold(); got != 0.5 {
+		t.Errorf("SyncDeleteThreshold() = %v, want 0.5", got)
+	}
+	cfg.Sync.DeleteThreshold = 0.25
+	if got := cfg.SyncDeleteThreshold(); got != 0.25 {
+		t.Errorf("SyncDeleteThreshold() = %v, want 0.25", got)
+	}
+}
+
+func TestUploadOwnedDirs(t *testing.T) {
+	cfg := &Config{Sync: SyncConfig{SyncDirs: []string{"roms", "bios"}}}
+	if got := cfg.UploadOwnedDirs(); len(got) != 2 {
+		t.Errorf("UploadOwnedDirs() = %v, want sync_dirs fallback", got)
+	}
+
+	cfg.Sync.OwnedDirs = []string{"roms/snes"}
+	if got := cfg.UploadOwnedDirs(); len(got) != 1 || got[0] != "roms/snes" {
+		t.Errorf("UploadOwnedDirs() = %v, want [roms/snes]", got)
+	}
+}
+
+func TestLoadTuningConfig(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`
+[sync.tuning."roms/ps2"]
+workers = 2
+max_retries = 5
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tune, ok := cfg.Sync.Tuning["roms/ps2"]
+	if !ok {
+		t.Fatalf("tuning = %v, want roms/ps2 entry", cfg.Sync.Tuning)
+	}
+	if tune.Workers != 2 || tune.MaxRetries != 5 {
+		t.Errorf("tuning = %+v, want workers=2 max_retries=5", tune)
+	}
+}
+
+func TestLoadSystemsConfig(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`
+[systems."roms/hacks"]
+name = "ROM Hacks"
+icon = "HACK"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	sys := cfg.Systems["roms/hacks"]
+	if sys.Name != "ROM Hacks" || sys.Icon != "HACK" {
+		t.Errorf("systems = %+v, want roms/hacks override", cfg.Systems)
+	}
+}
+
+func TestLoadRejectsUnknownStorageClass(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`
+[sync.tuning."roms/ps2"]
+storage_class = "COLD"
+`)
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "storage_class") {
+		t.Errorf("Load error = %v, want storage_class error", err)
+	}
+}
+
+func TestLoadRejectsBadTransferSettings(t *testing.T) {
+	for _, tc := range []struct{ line, want string }{
+		{"workers = -2", "workers"},
+		{"max_retries = -1", "max_retries"},
+		{`bandwidth_limit = "fast"`, "bandwidth_limit"},
+	} {
+		path := writeTempConfig(t, validTOML+tc.line+"\n")
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Load error = %v, want %s error", tc.line, err, tc.want)
+		}
+	}
+}
+
+func TestLoadOnBattery(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`on_battery = "throttle"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Sync.OnBattery != "throttle" {
+		t.Errorf("on_battery = %q, want throttle", cfg.Sync.OnBattery)
+	}
+
+	path = writeTempConfig(t, validTOML+`on_battery = "sleep"
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "on_battery") {
+		t.Errorf("Load error = %v, want on_battery error", err)
+	}
+}
+
+func TestLoadWebhook(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`webhook = "https://hooks.example.com/emu-sync"
+`)
+	if _, err := Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	path = writeTempConfig(t, validTOML+`webhook = "hooks.example.com"
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "webhook") {
+		t.Errorf("Load error = %v, want webhook error", err)
+	}
+}
+
+func TestLoadTrigger(t *testing.T) {
+	path := writeTempConfig(t, validTOML+`
+[trigger]
+listen = ":8771"
+token = "s3cret"
+urls = ["http://deck.local:8771/api/trigger"]
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Trigger.Listen != ":8771" || len(cfg.Trigger.URLs) != 1 {
+		t.Errorf("Trigger = %+v", cfg.Trigger)
+	}
+
+	path = writeTempConfig(t, validTOML+`
+[trigger]
+listen = ":8771"
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "trigger.token") {
+		t.Errorf("Load error = %v, want trigger.token error", err)
+	}
+
+	path = writeTempConfig(t, validTOML+`
+[trigger]
+token = "s3cret"
+urls = ["deck.local"]
+`)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "trigger.urls") {
+		t.Errorf("Load error = %v, want trigger.urls error", err)
+	}
+}
+
+func TestLoadStagingDirExpanded(t *testing.T) {
+	t.Setenv("HOME", "/home/deck")
+	cfg, err := Load(writeTempConfig(t, validTOML+`staging_dir = "~/staging"
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Sync.StagingDir != "/home/deck/staging" {
+		t.Errorf("staging_dir = %q, want /home/deck/staging", cfg.Sync.StagingDir)
+	}
+}
+
+func TestLoadUpdateNotify(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, validTOML))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Update.NotifyEnabled() {
+		t.Error("update notices should be enabled by default")
+	}
+
+	cfg, err = Load(writeTempConfig(t, validTOML+`
+[update]
+notify = false
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Update.NotifyEnabled() {
+		t.Error("update.notify = false should disable notices")
+	}
+}
+
+func TestStorageClassFor(t *testing.T) {
+	tuning := map[string]TuningConfig{
+		"roms/ps2":     {StorageClass: "GLACIER_IR"},
+		"roms/ps2/hot": {StorageClass: "STANDARD"},
+		"roms/ps2/sub": {Workers: 2},
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"roms/ps2/a.iso", "GLACIER_IR"},
+		{"roms/ps2/hot/b.iso", "STANDARD"},
+		{"roms/ps2/sub/c.iso", "GLACIER_IR"},
+		{"roms/ps2x/d.iso", ""},
+		{"roms/snes/e.sfc", ""},
+	}
+	for _, tt := range tests {
+		if got := StorageClassFor(tt.key, tuning); got != tt.want {
+			t.Errorf("StorageClassFor(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestKeepVersionsFor(t *testing.T) {
+	tuning := map[string]TuningConfig{
+		"textures":             {KeepVersions: 3},
+		"textures/SLUS-20312":  {Workers: 2},
+		"roms/gba/Patched.gba": {KeepVersions: 1},
+	}
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"textures/pack.zip", 3},
+		{"textures/SLUS-20312/a.png", 3},
+		{"roms/gba/Patched.gba", 1},
+		{"roms/gba/Other.gba", 0},
+	}
+	for _, tt := range tests {
+		if got := KeepVersionsFor(tt.key, tuning); got != tt.want {
+			t.Errorf("KeepVersionsFor(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkTransportCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Untrusted by default
+	tr, err := NetworkConfig{}.Transport(true)
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	if _, err := (&http.Client{Transport: tr}).Get(srv.URL); err == nil {
+		t.Error("expected certificate error without ca_bundle")
+	}
+
+	tr, err = NetworkConfig{CABundle: bundle}.Transport(false)
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET with ca_bundle: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNetworkTransportInsecureSkipVerify(t *testing.T) {
+	n := NetworkConfig{InsecureSkipVerify: true}
+
+	tr, err := n.Transport(true)
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	if tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected InsecureSkipVerify when allowed")
+	}
+
+	tr, err = n.Transport(false)
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	if tr.TLSClientConfig != nil && tr.TLSClientConfig.InsecureSkipVerify {
+		t.Error("InsecureSkipVerify should be ignored when not allowed")
+	}
+}
+
+func TestNetworkTransportProxy(t *testing.T) {
+	tr, err := NetworkConfig{Proxy: "http://proxy.local:3128"}.Transport(false)
+	if err != nil {
+		t.Fatalf("Transport: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "https://example.com", nil)
+	u, err := tr.Proxy(req)
+	if err != nil || u == nil || u.Host != "proxy.local:3128" {
+		t.Errorf("proxy = %v, %v; want proxy.local:3128", u, err)
+	}
+}
+
+func TestLoadRejectsBadNetworkConfig(t *testing.T) {
+	for _, extra := range []string{
+		"[network]\nproxy = \"not a url\"\n",
+		"[network]\nca_bundle = \"/nonexistent/ca.pem\"\n",
+	} {
+		path := writeTempConfig(t, validTOML+extra)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "network") {
+			t.Errorf("Load with %q: err = %v, want network error", extra, err)
+		}
+	}
+}
+
+func TestBatchByTuning(t *testing.T) {
+	keys := []string{"roms/ps2/a.iso", "roms/snes/b.sfc", "roms/ps2x/c.iso", "roms/ps2/sub/d.iso", "bios/e.bin"}
+	tuning := map[string]TuningConfig{
+		"roms/ps2":     {Workers: 2, MaxRetries: 5},
+		"roms/ps2/sub": {MaxRetries: 9},
+	}
+
+	batches := BatchByTuning(keys, tuning, 8, 3)
+	if len(batches) != 3 {
+		t.Fatalf("got %d batches, want 3: %+v", len(batches), batches)
+	}
+
+	ps2 := batches[0]
+	if ps2.Dir != "roms/ps2" || ps2.Workers != 2 || ps2.MaxRetries != 5 || len(ps2.Keys) != 1 || ps2.Keys[0] != "roms/ps2/a.iso" {
+		t.Errorf("roms/ps2 batch = %+v, want it first since its key is", ps2)
+	}
+
+	def := batches[1]
+	if def.Dir != "" || def.Workers != 8 || def.MaxRetries != 3 || len(def.Keys) != 3 {
+		t.Errorf("default batch = %+v, want 3 keys with workers=8 max_retries=3", def)
+	}
+
+	sub := batches[2]
+	if sub.Dir != "roms/ps2/sub" || sub.Workers != 8 || sub.MaxRetries != 9 || len(sub.Keys) != 1 {
+		t.Errorf("roms/ps2/sub batch = %+v, want inherited workers=8", sub)
+	}
+}
+
+func TestBatchByTuningKeepsTransferOrder(t *testing.T) {
+	sizes := map[string]int64{"roms/gba/big.gba": 900, "roms/gba/small.gba": 5, "roms/ps2/a.iso": 4000, "roms/snes/b.sfc": 2, "bios/c.bin": 50}
+	keys := slices.Collect(maps.Keys(sizes))
+	SortTransfers(keys, "", []string{"roms/gba"}, func(key string) int64 { return sizes[key] })
+
+	tuning := map[string]TuningConfig{
+		"roms/ps2":  {Workers: 1},
+		"roms/snes": {Tags: []string{"retro"}}, // no transfer settings, so no batch of its own
+	}
+	var got []string
+	for _, b := range BatchByTuning(keys, tuning, 4, 1) {
+		got = append(got, b.Keys...)
+	}
+	want := []string{"roms/gba/small.gba", "roms/gba/big.gba", "roms/snes/b.sfc", "bios/c.bin", "roms/ps2/a.iso"}
+	if !slices.Equal(got, want) {
+		t.Errorf("transfer order = %v, want %v", got, want)
+	}
+}
+
+func TestBatchByTuningEmpty(t *testing.T) {
+	batches := BatchByTuning([]string{"a", "b"}, nil, 4, 1)
+	if len(batches) != 1 || len(batches[0].Keys) != 2 || batches[0].Workers != 4 {
+		t.Errorf("batches = %+v, want single default batch", batches)
+	}
+}
+
+func TestSortTransfers(t *testing.T) {
+	sizes := map[string]int64{"roms/ps2/a.iso": 4000, "roms/snes/b.sfc": 2, "roms/snes/c.sfc": 2, "bios/d.bin": 50, "roms/gba/e.gba": 8}
+	size := func(key string) int64 { return sizes[key] }
+	tests := []struct {
+		order    string
+		priority []string
+		want     []string
+	}{
+		{"", nil, []string{"roms/snes/b.sfc", "roms/snes/c.sfc", "roms/gba/e.gba", "bios/d.bin", "roms/ps2/a.iso"}},
+		{"path", nil, []string{"bios/d.bin", "roms/gba/e.gba", "roms/ps2/a.iso", "roms/snes/b.sfc", "roms/snes/c.sfc"}},
+		{"size", []string{"bios", "roms/ps2"}, []string{"bios/d.bin", "roms/ps2/a.iso", "roms/snes/b.sfc", "roms/snes/c.sfc", "roms/gba/e.gba"}},
+	}
+	for _, tt := range tests {
+		keys := []string{"roms/ps2/a.iso", "roms/snes/c.sfc", "bios/d.bin", "roms/gba/e.gba", "roms/snes/b.sfc"}
+		SortTransfers(keys, tt.order, tt.priority, size)
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("SortTransfers(%q, %v) = %v, want %v", tt.order, tt.priority, keys, tt.want)
+		}
+	}
+
+	cfg := &Config{
+		Storage: StorageConfig{EndpointURL: "https://s3.example.com", Bucket: "b", KeyID: "k", SecretKey: "s"},
+		Sync:    SyncConfig{EmulationPath: "/tmp", Order: "random"},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate accepted sync.order = \"random\"")
+	}
+}
+
 func TestParseBandwidthLimit(t *testing.T) {
 	tests := []struct {
 		input   string
@@ -396,6 +914,62 @@ func TestValidateEmulationPath(t *testing.T) {
 	}
 }
 
+func TestCheckSelections(t *testing.T) {
+	keys := []string{
+		"roms/snes/Chrono Trigger.sfc",
+		"roms/snes/beta/Star Fox 2.sfc",
+		"roms/gba/Golden Sun.gba",
+		"bios/scph1001.bin",
+	}
+	cfg := &Config{Sync: SyncConfig{
+		SyncDirs:    []string{"roms", "roms/snes", "bios/ps2", "saves"},
+		SyncExclude: []string{"roms/snes/beta", "roms/snes/beta/old", "bios/scph1001.bin", "roms/n64"},
+	}}
+
+	got := cfg.CheckSelections(keys)
+	want := []string{
+		`sync_dirs entry "roms/snes" is already covered by "roms"`,
+		`sync_dirs entry "bios/ps2" matches nothing in the library`,
+		`sync_dirs entry "saves" matches nothing in the library`,
+		`sync_exclude entry "roms/snes/beta/old" is already covered by "roms/snes/beta"`,
+		`sync_exclude entry "bios/scph1001.bin" is outside sync_dirs, so it has no effect`,
+		`sync_exclude entry "roms/n64" matches nothing in the library`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("CheckSelections =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	clean := &Config{Sync: SyncConfig{SyncDirs: []string{"roms"}, SyncExclude: []string{"roms/snes/beta"}}}
+	if got := clean.CheckSelections(keys); len(got) != 0 {
+		t.Errorf("clean config: got %v", got)
+	}
+
+	excluded := &Config{Sync: SyncConfig{SyncDirs: []string{"roms/gba"}, SyncExclude: []string{"roms"}}}
+	if got := excluded.CheckSelections(keys); len(got) != 2 || !strings.Contains(got[0], "is excluded by sync_exclude") {
+		t.Errorf("excluded sync_dirs entry: got %v", got)
+	}
+}
+
+func TestDeletesFile(t *testing.T) {
+	on, off := true, false
+	for _, tc := range []struct {
+		sync                SyncConfig
+		deselected, removed bool
+	}{
+		{SyncConfig{Delete: true}, true, true},
+		{SyncConfig{Delete: false}, false, false},
+		{SyncConfig{Delete: true, DeleteDeselect: &off}, false, true},
+		{SyncConfig{Delete: false, DeleteRemoved: &on}, false, true},
+	} {
+		if got := tc.sync.DeletesFile(true); got != tc.deselected {
+			t.Errorf("%+v: deselected = %v, want %v", tc.sync, got, tc.deselected)
+		}
+		if got := tc.sync.DeletesFile(false); got != tc.removed {
+			t.Errorf("%+v: removed = %v, want %v", tc.sync, got, tc.removed)
+		}
+	}
+}
+
 func writeTempConfig(t *testing.T, content string) string {
 	t.Helper()
 	dir := t.TempDir()
@@ -405,3 +979,126 @@ func writeTempConfig(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("HOME", "/home/deck")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("EMU_SYNC_STATE_DIR", "")
+	if got := DefaultLastSyncPath(); got != "/home/deck/.local/share/emu-sync/last-sync.json" {
+		t.Errorf("default = %q", got)
+	}
+
+	t.Setenv("EMU_SYNC_STATE_DIR", "/state")
+	if got := DefaultLastSyncPath(); got != "/state/last-sync.json" {
+		t.Errorf("with EMU_SYNC_STATE_DIR = %q, want /state/last-sync.json", got)
+	}
+
+	SetStateDir("/volume")
+	defer SetStateDir("")
+	if got := DefaultUsagePath(); got != "/volume/usage.json" {
+		t.Errorf("with SetStateDir = %q, want /volume/usage.json", got)
+	}
+}
+
+func TestDefaultConfigPathEnv(t *testing.T) {
+	t.Setenv("EMU_SYNC_CONFIG", "/config/config.toml")
+	if got := DefaultConfigPath(); got != "/config/config.toml" {
+		t.Errorf("DefaultConfigPath = %q, want /config/config.toml", got)
+	}
+}
+
+func TestLoadPathsStateDir(t *testing.T) {
+	t.Setenv("HOME", "/home/deck")
+	t.Setenv("EMU_SYNC_STATE_DIR", "")
+	defer configStateDir.Store("")
+	if _, err := Load(writeTempConfig(t, validTOML+`
+[paths]
+state_dir = "~/emu-state/work"
+`)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := StateDir(); got != "/home/deck/emu-state/work" {
+		t.Errorf("StateDir = %q, want paths.state_dir", got)
+	}
+
+	SetStateDir("/flag")
+	defer SetStateDir("")
+	if got := StateDir(); got != "/flag" {
+		t.Errorf("StateDir = %q, want --state-dir to win over paths.state_dir", got)
+	}
+}
+
+func TestLibraryStatePaths(t *testing.T) {
+	dir := t.TempDir()
+	SetStateDir(dir)
+	defer SetStateDir("")
+	defer libraryID.Store("")
+	defer targetID.Store("")
+
+	libraryID.Store("")
+	targetID.Store("")
+	if got := DefaultUploadCachePath(); got != filepath.Join(dir, "upload-cache.json") {
+		t.Errorf("before any config = %q, want the unkeyed name", got)
+	}
+
+	// State from before per-library names is adopted by the first library.
+	legacy := filepath.Join(dir, "upload-cache.json")
+	os.WriteFile(legacy, []byte("{}"), 0o644)
+	a := StorageConfig{EndpointURL: "https://s3.example.com/", Bucket: "roms"}
+	libraryID.Store(a.LibraryID())
+	pathA := DefaultUploadCachePath()
+	if pathA == legacy {
+		t.Fatalf("path = %q, want it named for the library", pathA)
+	}
+	if _, err := os.Stat(pathA); err != nil {
+		t.Errorf("legacy cache not adopted: %v", err)
+	}
+
+	b := a
+	b.Prefix = "family/"
+	if a.LibraryID() == b.LibraryID() {
+		t.Error("libraries with different prefixes should get different IDs")
+	}
+	libraryID.Store(b.LibraryID())
+	if pathB := DefaultUploadCachePath(); pathB == pathA {
+		t.Errorf("second library shares %q", pathB)
+	} else if _, err := os.Stat(pathB); err == nil {
+		t.Error("second library should start without a cache")
+	}
+
+	c := a
+	c.EndpointURL = "https://s3.example.com"
+	if a.LibraryID() != c.LibraryID() {
+		t.Error("a trailing slash on the endpoint shouldn't change the ID")
+	}
+}
+
+func TestTargetStatePaths(t *testing.T) {
+	dir := t.TempDir()
+	SetStateDir(dir)
+	defer SetStateDir("")
+	defer targetID.Store("")
+
+	deck := &Config{Storage: StorageConfig{Bucket: "roms"}, Sync: SyncConfig{EmulationPath: "/home/deck/Emulation"}}
+	sd := &Config{Storage: deck.Storage, Sync: SyncConfig{EmulationPath: "/run/media/sd/Emulation"}}
+	if deck.TargetID() == sd.TargetID() {
+		t.Fatal("emulation paths should get different target IDs")
+	}
+
+	targetID.Store(deck.TargetID())
+	deckManifest := DefaultLocalManifestPath()
+	targetID.Store(sd.TargetID())
+	sdManifest := DefaultLocalManifestPath()
+	if deckManifest == sdManifest {
+		t.Errorf("both emulation paths use %q", deckManifest)
+	}
+	if LockPath(sdManifest) == LockPath(deckManifest) {
+		t.Error("both emulation paths share a lock")
+	}
+	if want := filepath.Join(dir, "local-manifest-"+sd.TargetID()+".lock"); LockPath(sdManifest) != want {
+		t.Errorf("LockPath = %q, want %q", LockPath(sdManifest), want)
+	}
+	if want := filepath.Join(dir, "scan-cache-"+sd.TargetID()+".json"); DefaultScanCachePath() != want {
+		t.Errorf("DefaultScanCachePath = %q, want %q", DefaultScanCachePath(), want)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/jacobfgrant/emu-sync/internal/units"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// envSettings maps each environment variable LoadEnv reads to the
+// setting it overrides.
+var envSettings = map[string]func(*Config) *string{
+	"EMU_SYNC_ENDPOINT_URL":    func(c *Config) *string { return &c.Storage.EndpointURL },
+	"EMU_SYNC_BUCKET":          func(c *Config) *string { return &c.Storage.Bucket },
+	"EMU_SYNC_KEY_ID":          func(c *Config) *string { return &c.Storage.KeyID },
+	"EMU_SYNC_SECRET_KEY":      func(c *Config) *string { return &c.Storage.SecretKey },
+	"EMU_SYNC_REGION":          func(c *Config) *string { return &c.Storage.Region },
+	"EMU_SYNC_PREFIX":          func(c *Config) *string { return &c.Storage.Prefix },
+	"EMU_SYNC_EMULATION_PATH":  func(c *Config) *string { return &c.Sync.EmulationPath },
+	"EMU_SYNC_BANDWIDTH_LIMIT": func(c *Config) *string { return &c.Sync.BandwidthLimit },
+}
+
+// EnvVars returns the environment variables LoadEnv reads, sorted.
+func EnvVars() []string {
+	names := make([]string, 0, len(envSettings))
+	for name := range envSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadEnv is Load for CI jobs and containers: the config file is
+// optional, and the EMU_SYNC_* variables listed by EnvVars override its
+// settings, so credentials can come from the job's secrets. Older files
+// are upgraded in memory but never rewritten. If neither the file nor
+// the environment sets emulation_path, emulationPath is used.
+func LoadEnv(path, emulationPath string) (*Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading config file: %w", err)
+	default:
+		if data, _, _, err = upgrade(data, migrations); err != nil {
+			return nil, err
+		}
+		if err := toml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file: %w", err)
+		}
+	}
+	cfg.Version = CurrentVersion
+
+	for name, setting := range envSettings {
+		if v, ok := os.LookupEnv(name); ok {
+			*setting(&cfg) = v
+		}
+	}
+	if cfg.Sync.EmulationPath == "" {
+		cfg.Sync.EmulationPath = emulationPath
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	units.SetSystem(cfg.Display.Units)
+	configStateDir.Store(cfg.Paths.StateDir)
+	libraryID.Store(cfg.Storage.LibraryID())
+	targetID.Store(cfg.TargetID())
+	return &cfg, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadEnvWithoutFile(t *testing.T) {
+	t.Setenv("EMU_SYNC_BUCKET", "ci-roms")
+	t.Setenv("EMU_SYNC_KEY_ID", "ci-key")
+	t.Setenv("EMU_SYNC_SECRET_KEY", "ci-secret")
+
+	cfg, err := LoadEnv(filepath.Join(t.TempDir(), "missing.toml"), "/src/library")
+	if err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	if cfg.Storage.Bucket != "ci-roms" || cfg.Storage.KeyID != "ci-key" || cfg.Storage.SecretKey != "ci-secret" {
+		t.Errorf("Storage = %+v", cfg.Storage)
+	}
+	if cfg.Sync.EmulationPath != "/src/library" {
+		t.Errorf("EmulationPath = %q, want /src/library", cfg.Sync.EmulationPath)
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	path := writeTempConfig(t, validTOML)
+	t.Setenv("EMU_SYNC_BUCKET", "other-roms")
+
+	cfg, err := LoadEnv(path, "/ignored")
+	if err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	if cfg.Storage.Bucket != "other-roms" {
+		t.Errorf("Bucket = %q, want other-roms from the environment", cfg.Storage.Bucket)
+	}
+	if cfg.Storage.KeyID != "004abc" || cfg.Sync.EmulationPath != "/tmp/Emulation" {
+		t.Errorf("file settings lost: %+v %q", cfg.Storage, cfg.Sync.EmulationPath)
+	}
+}
+
+func TestLoadEnvRequiresBucket(t *testing.T) {
+	if _, err := LoadEnv(filepath.Join(t.TempDir(), "missing.toml"), "/src"); err == nil {
+		t.Error("LoadEnv should fail without a bucket")
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// CurrentVersion is the config_version of files written by this build.
+// Files without one predate versioning and are version 0.
+const CurrentVersion = 1
+
+// A migration upgrades a parsed config file by one version, e.g. by
+// renaming or splitting a setting. It edits doc in place.
+type migration func(doc map[string]any) error
+
+// migrations[i] upgrades version i to i+1, so len(migrations) must equal
+// CurrentVersion. Append a step when the file format changes and bump
+// CurrentVersion; never edit a released step.
+var migrations = []migration{
+	// 0 -> 1: introduces config_version; no settings changed.
+	func(doc map[string]any) error { return nil },
+}
+
+// upgrade applies the migrations needed to bring data up to
+// CurrentVersion. It returns the data to decode, the version the file
+// was at, and whether any migration changed a setting (so the file
+// should be rewritten). Data that only needs its version bumped is
+// returned as is.
+func upgrade(data []byte, steps []migration) ([]byte, int, bool, error) {
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, false, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	from := 0
+	if v, ok := doc["config_version"]; ok {
+		n, ok := v.(int64)
+		if !ok || n < 0 {
+			return nil, 0, false, fmt.Errorf("config: config_version must be a non-negative integer")
+		}
+		from = int(n)
+	}
+	if from > len(steps) {
+		return nil, from, false, fmt.Errorf("config: config_version %d is newer than this emu-sync understands (%d); upgrade emu-sync", from, len(steps))
+	}
+	if from == len(steps) {
+		return data, from, false, nil
+	}
+
+	before, err := toml.Marshal(doc)
+	if err != nil {
+		return nil, from, false, fmt.Errorf("parsing config file: %w", err)
+	}
+	for v := from; v < len(steps); v++ {
+		if err := steps[v](doc); err != nil {
+			return nil, from, false, fmt.Errorf("config: upgrading from version %d: %w", v, err)
+		}
+	}
+	after, err := toml.Marshal(doc)
+	if err != nil {
+		return nil, from, false, fmt.Errorf("config: upgrading from version %d: %w", from, err)
+	}
+	if bytes.Equal(before, after) {
+		return data, from, false, nil
+	}
+
+	doc["config_version"] = int64(len(steps))
+	migrated, err := toml.Marshal(doc)
+	if err != nil {
+		return nil, from, false, fmt.Errorf("config: upgrading from version %d: %w", from, err)
+	}
+	return migrated, from, true, nil
+}
+
+// saveUpgraded rewrites an upgraded config file, first copying the
+// original to path.v<from>.bak so it survives later saves. If the copy
+// can't be made the file is left alone; the upgrade is simply redone on
+// the next load.
+func saveUpgraded(cfg *Config, path string, original []byte, from int) {
+	if err := os.WriteFile(fmt.Sprintf("%s.v%d.bak", path, from), original, 0o600); err != nil {
+		return
+	}
+	Write(cfg, path)
+}
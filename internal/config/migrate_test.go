@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// renameWorkers is a sample migration that moves sync.threads to
+// sync.workers.
+func renameWorkers(doc map[string]any) error {
+	sync, ok := doc["sync"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	if v, ok := sync["threads"]; ok {
+		sync["workers"] = v
+		delete(sync, "threads")
+	}
+	return nil
+}
+
+func TestUpgradeRunsMigrations(t *testing.T) {
+	steps := []migration{migrations[0], renameWorkers}
+	data := []byte(validTOML + "threads = 4\n")
+
+	migrated, from, rewrite, err := upgrade(data, steps)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if from != 0 || !rewrite {
+		t.Errorf("from = %d, rewrite = %v; want 0, true", from, rewrite)
+	}
+	text := string(migrated)
+	if !strings.Contains(text, "config_version = 2") || !strings.Contains(text, "workers = 4") || strings.Contains(text, "threads") {
+		t.Errorf("migrated config:\n%s", text)
+	}
+
+	// Already at the latest version: nothing to do.
+	again, from, rewrite, err := upgrade(migrated, steps)
+	if err != nil || from != 2 || rewrite || string(again) != text {
+		t.Errorf("second upgrade: from = %d, rewrite = %v, err = %v", from, rewrite, err)
+	}
+}
+
+func TestUpgradeVersionOnly(t *testing.T) {
+	data := []byte(validTOML)
+	out, from, rewrite, err := upgrade(data, migrations)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if from != 0 || rewrite || string(out) != string(data) {
+		t.Errorf("from = %d, rewrite = %v; want the file left alone", from, rewrite)
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	path := writeTempConfig(t, "config_version = 99\n"+validTOML)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "upgrade emu-sync") {
+		t.Errorf("Load error = %v, want a newer-version error", err)
+	}
+}
+
+func TestLoadRewritesMigratedFile(t *testing.T) {
+	saved := migrations
+	migrations = []migration{saved[0], renameWorkers}
+	defer func() { migrations = saved }()
+
+	original := validTOML + "threads = 4\n"
+	path := writeTempConfig(t, original)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Sync.Workers != 4 {
+		t.Errorf("workers = %d, want 4", cfg.Sync.Workers)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil || string(backup) != original {
+		t.Errorf("backup = %q, %v; want the original file", backup, err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "threads") || !strings.Contains(string(data), "workers = 4") {
+		t.Errorf("config not rewritten:\n%s", data)
+	}
+}
+
+func TestWriteStampsVersion(t *testing.T) {
+	path := writeTempConfig(t, validTOML)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.Sync.Workers = 2
+	if err := Write(cfg, path); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), fmt.Sprintf("config_version = %d\n", CurrentVersion)) {
+		t.Errorf("written config doesn't start with config_version:\n%s", data)
+	}
+}
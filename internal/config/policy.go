@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/systems"
+)
+
+// PolicyConfig limits what a device can see of the library, e.g. on a
+// child's device. Files the policy hides are left out of choose, ls,
+// status, and the web UI, and sync treats them as deselected. It is
+// meant to come from whoever hands out the setup token (see
+// generate-token) rather than be edited on the device.
+//
+// Systems are given by directory ("roms/snes") or name ("snes"), as in
+// [systems]. Name patterns are shell globs matched against file names,
+// ignoring case.
+type PolicyConfig struct {
+	AllowSystems []string `toml:"allow_systems,omitempty" json:"allow_systems,omitempty"` // if set, only these systems' games are visible; BIOS and other files outside roms/ stay visible
+	DenySystems  []string `toml:"deny_systems,omitempty" json:"deny_systems,omitempty"`   // systems hidden entirely, including their BIOS directories
+	DenyTags     []string `toml:"deny_tags,omitempty" json:"deny_tags,omitempty"`         // hide files the uploader tagged (see [sync.tuning] tags), e.g. ["mature"]
+	DenyNames    []string `toml:"deny_names,omitempty" json:"deny_names,omitempty"`       // hide files whose names match, e.g. ["*Mortal Kombat*"]
+}
+
+// Empty reports whether the policy hides nothing.
+func (p PolicyConfig) Empty() bool {
+	return len(p.AllowSystems)+len(p.DenySystems)+len(p.DenyTags)+len(p.DenyNames) == 0
+}
+
+// Allows reports whether the file at key, carrying tags, is visible.
+func (p PolicyConfig) Allows(key string, tags []string) bool {
+	dir := systems.Dir(key)
+	if matchesSystem(dir, p.DenySystems) {
+		return false
+	}
+	if len(p.AllowSystems) > 0 && strings.HasPrefix(key, "roms/") && !matchesSystem(dir, p.AllowSystems) {
+		return false
+	}
+	for _, tag := range tags {
+		if slices.Contains(p.DenyTags, tag) {
+			return false
+		}
+	}
+	name := strings.ToLower(path.Base(key))
+	for _, pattern := range p.DenyNames {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Hide removes the files the policy hides from m, in place.
+func (p PolicyConfig) Hide(m *manifest.Manifest) {
+	if p.Empty() {
+		return
+	}
+	for key, entry := range m.Files {
+		if !p.Allows(key, entry.Tags) {
+			delete(m.Files, key)
+		}
+	}
+}
+
+// Validate checks that the name patterns are well-formed.
+func (p PolicyConfig) Validate() error {
+	for _, pattern := range p.DenyNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("config: policy.deny_names %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesSystem reports whether the system directory dir is one of
+// list, by directory or by its base name.
+func matchesSystem(dir string, list []string) bool {
+	base := strings.ToLower(path.Base(dir))
+	for _, s := range list {
+		s = strings.Trim(s, "/")
+		if s == dir || strings.ToLower(s) == base {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	p := PolicyConfig{
+		AllowSystems: []string{"snes", "roms/gba"},
+		DenySystems:  []string{"gba"},
+		DenyTags:     []string{"mature"},
+		DenyNames:    []string{"*mortal kombat*"},
+	}
+	tests := []struct {
+		key  string
+		tags []string
+		want bool
+	}{
+		{"roms/snes/Chrono Trigger.sfc", nil, true},
+		{"roms/snes/Mortal Kombat II (USA).sfc", nil, false},
+		{"roms/snes/Doom.sfc", []string{"mature"}, false},
+		{"roms/snes/Tetris.sfc", []string{"puzzle"}, true},
+		{"roms/psx/Spyro.bin", nil, false},          // not an allowed system
+		{"bios/scph5501.bin", nil, true},            // outside roms/, allow_systems doesn't apply
+		{"roms/gba/Metroid Fusion.gba", nil, false}, // denied wins over allowed
+		{"bios/gba/gba_bios.bin", nil, false},       // a denied system hides its BIOS too
+	}
+	for _, tt := range tests {
+		if got := p.Allows(tt.key, tt.tags); got != tt.want {
+			t.Errorf("Allows(%q, %v) = %v, want %v", tt.key, tt.tags, got, tt.want)
+		}
+	}
+	if !(PolicyConfig{}).Allows("roms/psx/Spyro.bin", []string{"mature"}) {
+		t.Error("an empty policy should allow everything")
+	}
+}
+
+func TestPolicyHide(t *testing.T) {
+	m := manifest.New()
+	m.Files["roms/snes/A.sfc"] = manifest.FileEntry{Size: 1}
+	m.Files["roms/snes/B.sfc"] = manifest.FileEntry{Size: 1, Tags: []string{"mature"}}
+	m.Files["roms/n64/C.z64"] = manifest.FileEntry{Size: 1}
+
+	PolicyConfig{DenySystems: []string{"n64"}, DenyTags: []string{"mature"}}.Hide(m)
+	if len(m.Files) != 1 {
+		t.Errorf("files after Hide = %v, want only roms/snes/A.sfc", m.Files)
+	}
+	if err := (PolicyConfig{DenyNames: []string{"[bad"}}).Validate(); err == nil {
+		t.Error("Validate accepted a malformed pattern")
+	}
+}
+
+func TestTagsFor(t *testing.T) {
+	tuning := map[string]TuningConfig{
+		"roms":      {Tags: []string{"game"}},
+		"roms/psx":  {Tags: []string{"mature", "game"}},
+		"roms/psx2": {Tags: []string{"other"}},
+	}
+	got := TagsFor("roms/psx/Silent Hill.bin", tuning)
+	if len(got) != 2 || got[0] != "game" || got[1] != "mature" {
+		t.Errorf("TagsFor = %v, want [game mature]", got)
+	}
+	if got := TagsFor("bios/x.bin", tuning); len(got) != 0 {
+		t.Errorf("TagsFor(untagged) = %v, want none", got)
+	}
+}
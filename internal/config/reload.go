@@ -0,0 +1,52 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Version identifies what's on disk at a config path, so long-running
+// commands can notice when it's edited by hand or by another emu-sync.
+type Version struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Equal reports whether v and o describe the same file contents.
+func (v Version) Equal(o Version) bool {
+	return v.ModTime.Equal(o.ModTime) && v.Size == o.Size
+}
+
+// FileVersion returns the Version of the file at path, following
+// symlinks. A missing file has the zero Version.
+func FileVersion(path string) Version {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Version{}
+	}
+	return Version{ModTime: info.ModTime(), Size: info.Size()}
+}
+
+// WatchFile checks the file at path every interval until ctx is done and
+// calls fn when it has changed. If fn returns an error the change is
+// offered again on the next check, e.g. to wait for a running job.
+func WatchFile(ctx context.Context, path string, interval time.Duration, fn func() error) {
+	seen := FileVersion(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := FileVersion(path)
+		if current.Equal(seen) {
+			continue
+		}
+		if fn() == nil {
+			seen = current
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if v := FileVersion(path); !v.Equal(Version{}) {
+		t.Errorf("missing file: got %v, want zero", v)
+	}
+
+	os.WriteFile(path, []byte("a"), 0o600)
+	before := FileVersion(path)
+	os.WriteFile(path, []byte("ab"), 0o600)
+	if FileVersion(path).Equal(before) {
+		t.Error("version unchanged after edit")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("a"), 0o600)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := make(chan int, 10)
+	n := 0
+	go WatchFile(ctx, path, 10*time.Millisecond, func() error {
+		n++
+		calls <- n
+		if n == 1 {
+			return errors.New("busy") // offered again next check
+		}
+		return nil
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case <-calls:
+		t.Fatal("called before the file changed")
+	default:
+	}
+
+	os.WriteFile(path, []byte("ab"), 0o600)
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-calls:
+			if got != want {
+				t.Fatalf("call %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("call %d never came", want)
+		}
+	}
+
+	// Handled successfully, so no more calls until the next edit.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case got := <-calls:
+		t.Fatalf("unexpected call %d after a successful reload", got)
+	default:
+	}
+}
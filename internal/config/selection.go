@@ -0,0 +1,215 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Selection is one user's choice of what to sync on a shared machine,
+// kept in their own selection file (see DefaultSelectionPath) alongside
+// a shared base config that sets sync.user_selections.
+type Selection struct {
+	SyncDirs    []string `toml:"sync_dirs"`
+	SyncExclude []string `toml:"sync_exclude,omitempty"`
+
+	Path string `toml:"-"` // the file the selection was loaded from
+}
+
+// Selects reports whether key is in SyncDirs and not in SyncExclude,
+// matching by prefix or exactly as sync_dirs and sync_exclude do.
+func (s Selection) Selects(key string) bool {
+	for _, ex := range s.SyncExclude {
+		if key == ex || strings.HasPrefix(key, ex+"/") {
+			return false
+		}
+	}
+	for _, dir := range s.SyncDirs {
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultSelectionPath returns the current user's selection file:
+// selection.toml under XDG_CONFIG_HOME or ~/.config. Unlike
+// DefaultConfigPath it ignores EMU_SYNC_CONFIG, which on a shared
+// machine points everyone at the base config.
+func DefaultSelectionPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "emu-sync", "selection.toml")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "emu-sync", "selection.toml")
+}
+
+// LoadSelection reads the selection file at path. A missing file is an
+// empty selection.
+func LoadSelection(path string) (Selection, error) {
+	sel := Selection{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sel, nil
+	}
+	if err != nil {
+		return sel, fmt.Errorf("reading selection file: %w", err)
+	}
+	if err := toml.Unmarshal(data, &sel); err != nil {
+		return sel, fmt.Errorf("parsing selection file %s: %w", path, err)
+	}
+	return sel, nil
+}
+
+// WriteSelection saves sel to sel.Path. The file is readable by
+// everyone, so a sync running as another user can merge it.
+func WriteSelection(sel Selection) error {
+	if err := os.MkdirAll(filepath.Dir(sel.Path), 0o755); err != nil {
+		return fmt.Errorf("creating selection directory: %w", err)
+	}
+	data, err := toml.Marshal(&sel)
+	if err != nil {
+		return fmt.Errorf("serializing selection: %w", err)
+	}
+	tmpPath := sel.Path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing selection file: %w", err)
+	}
+	if err := os.Rename(tmpPath, sel.Path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing selection file: %w", err)
+	}
+	return nil
+}
+
+// loadUsers fills in Users from the files matching sync.user_selections.
+func (c *Config) loadUsers() error {
+	c.Users = nil
+	if c.Sync.UserSelections == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(c.Sync.UserSelections)
+	if err != nil {
+		return fmt.Errorf("config: sync.user_selections: %w", err)
+	}
+	for _, path := range paths {
+		sel, err := LoadSelection(path)
+		if err != nil {
+			return err
+		}
+		if c.Users == nil {
+			c.Users = make(map[string]Selection)
+		}
+		c.Users[userName(c.Sync.UserSelections, path)] = sel
+	}
+	return nil
+}
+
+// userName names the user a selection file belongs to after the path
+// element matched by the first wildcard in pattern, e.g. "alice" for
+// /home/alice/.config/emu-sync/selection.toml matched by
+// /home/*/.config/emu-sync/selection.toml.
+func userName(pattern, path string) string {
+	patternElems := strings.Split(filepath.ToSlash(pattern), "/")
+	pathElems := strings.Split(filepath.ToSlash(path), "/")
+	for i, elem := range patternElems {
+		if strings.ContainsAny(elem, "*?[") && i < len(pathElems) {
+			return pathElems[i]
+		}
+	}
+	return path
+}
+
+// CurrentUser returns the name under which the user running emu-sync
+// keeps their selections on a shared machine, adding an empty selection
+// for them if they haven't saved one yet. It returns "" when the config
+// isn't shared, meaning selections live in the config itself.
+func (c *Config) CurrentUser() (string, error) {
+	if c.Sync.UserSelections == "" {
+		return "", nil
+	}
+	path := filepath.Clean(DefaultSelectionPath())
+	for name, sel := range c.Users {
+		if filepath.Clean(sel.Path) == path {
+			return name, nil
+		}
+	}
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	sel, err := LoadSelection(path)
+	if err != nil {
+		return "", err
+	}
+	users := maps.Clone(c.Users)
+	if users == nil {
+		users = make(map[string]Selection)
+	}
+	users[name] = sel
+	c.Users = users
+	return name, nil
+}
+
+// ForUser returns a view of c whose sync_dirs and sync_exclude are
+// user's selections alone, for showing and editing them. The view
+// mustn't be synced, since it would delete the other users' files. For
+// "" it returns c.
+func (c *Config) ForUser(user string) *Config {
+	if user == "" {
+		return c
+	}
+	view := *c
+	sel := c.Users[user]
+	view.Sync.SyncDirs, view.Sync.SyncExclude = sel.SyncDirs, sel.SyncExclude
+	view.Users = nil
+	return &view
+}
+
+// SetSelection replaces user's selections, or the config's own for "".
+// Users is copied rather than changed in place, so copies of c made
+// beforehand keep their selections.
+func (c *Config) SetSelection(user string, syncDirs, syncExclude []string) {
+	if user == "" {
+		c.Sync.SyncDirs, c.Sync.SyncExclude = syncDirs, syncExclude
+		return
+	}
+	users := maps.Clone(c.Users)
+	if users == nil {
+		users = make(map[string]Selection)
+	}
+	sel := users[user]
+	sel.SyncDirs, sel.SyncExclude = syncDirs, syncExclude
+	users[user] = sel
+	c.Users = users
+}
+
+// SelectionFile returns the file user's selections are saved to: their
+// selection file, or for "" the config file at cfgPath.
+func (c *Config) SelectionFile(user, cfgPath string) string {
+	if user == "" {
+		return cfgPath
+	}
+	return c.Users[user].Path
+}
+
+// SaveSelection saves user's selections to SelectionFile. For "" that
+// writes the whole config to cfgPath.
+func (c *Config) SaveSelection(user, cfgPath string) error {
+	if user == "" {
+		return Write(c, cfgPath)
+	}
+	sel, ok := c.Users[user]
+	if !ok {
+		return fmt.Errorf("no selections for user %q", user)
+	}
+	return WriteSelection(sel)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUserSelections(t *testing.T) {
+	home := t.TempDir()
+	for user, toml := range map[string]string{
+		"alice": "sync_dirs = [\"roms/snes\"]\n",
+		"bob":   "sync_dirs = [\"roms\"]\nsync_exclude = [\"roms/ps2\"]\n",
+	} {
+		dir := filepath.Join(home, user, "emu-sync")
+		os.MkdirAll(dir, 0o755)
+		if err := os.WriteFile(filepath.Join(dir, "selection.toml"), []byte(toml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := writeTempConfig(t, `
+[storage]
+bucket = "my-roms"
+
+[sync]
+emulation_path = "/tmp/Emulation"
+user_selections = "`+filepath.Join(home, "*", "emu-sync", "selection.toml")+`"
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !slices.Equal(cfg.Sync.SyncDirs, []string{"bios"}) {
+		t.Errorf("sync_dirs = %v, want [bios] on a shared machine", cfg.Sync.SyncDirs)
+	}
+	if len(cfg.Users) != 2 || cfg.Users["alice"].SyncDirs[0] != "roms/snes" {
+		t.Fatalf("Users = %+v, want alice and bob", cfg.Users)
+	}
+
+	// Sync covers everyone's selections; each user's view only theirs.
+	for key, want := range map[string]bool{
+		"bios/scph1001.bin":  true,
+		"roms/snes/Game.sfc": true,
+		"roms/gba/Game.gba":  true,
+		"roms/ps2/Game.iso":  false,
+	} {
+		if got := cfg.ShouldSync(key); got != want {
+			t.Errorf("ShouldSync(%q) = %v, want %v", key, got, want)
+		}
+	}
+	alice := cfg.ForUser("alice")
+	if alice.ShouldSync("roms/gba/Game.gba") || alice.ShouldSync("bios/scph1001.bin") || !alice.ShouldSync("roms/snes/Game.sfc") {
+		t.Error("alice's view should select only roms/snes")
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "alice"))
+	user, err := cfg.CurrentUser()
+	if err != nil || user != "alice" {
+		t.Fatalf("CurrentUser = %q, %v; want alice", user, err)
+	}
+
+	before := *cfg
+	cfg.SetSelection("alice", []string{"roms/gba"}, nil)
+	if before.Users["alice"].SyncDirs[0] != "roms/snes" {
+		t.Error("SetSelection changed a copy's selections")
+	}
+	if err := cfg.SaveSelection("alice", path); err != nil {
+		t.Fatalf("SaveSelection: %v", err)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !slices.Equal(reloaded.Users["alice"].SyncDirs, []string{"roms/gba"}) || reloaded.Users["bob"].SyncDirs[0] != "roms" {
+		t.Errorf("after save, Users = %+v", reloaded.Users)
+	}
+
+	// A user who hasn't saved selections yet starts with none.
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "carol"))
+	user, err = reloaded.CurrentUser()
+	if err != nil || user == "" || len(reloaded.Users[user].SyncDirs) != 0 {
+		t.Errorf("CurrentUser = %q, %v; want a new, empty selection", user, err)
+	}
+}
+
+func TestUserName(t *testing.T) {
+	got := userName("/home/*/.config/emu-sync/selection.toml", "/home/alice/.config/emu-sync/selection.toml")
+	if got != "alice" {
+		t.Errorf("userName = %q, want alice", got)
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// PathsConfig holds where emu-sync keeps its own files.
+type PathsConfig struct {
+	StateDir string `toml:"state_dir,omitempty"` // see StateDir; "" = ~/.local/share/emu-sync
+}
+
+var stateDir atomic.Value       // string set by SetStateDir
+var configStateDir atomic.Value // string from paths.state_dir, set by Load
+var libraryID atomic.Value      // string from Storage.LibraryID, set by Load
+var targetID atomic.Value       // string from TargetID, set by Load
+
+// SetStateDir sets the directory StateDir returns, as --state-dir does.
+// An empty dir restores the default.
+func SetStateDir(dir string) {
+	stateDir.Store(dir)
+}
+
+// StateDir returns the directory emu-sync keeps its state files in: the
+// one given to SetStateDir, else EMU_SYNC_STATE_DIR, else paths.state_dir
+// from the last config loaded, else emu-sync under XDG_DATA_HOME or
+// ~/.local/share. Containers set it to a volume so nothing depends on a
+// home directory; giving each config its own keeps profiles apart.
+func StateDir() string {
+	if dir, _ := stateDir.Load().(string); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("EMU_SYNC_STATE_DIR"); dir != "" {
+		return dir
+	}
+	if dir, _ := configStateDir.Load().(string); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "emu-sync")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "emu-sync")
+}
+
+// LibraryID returns a short hash of the endpoint, bucket, and prefix, so
+// state kept for one library is never applied to another.
+func (s StorageConfig) LibraryID() string {
+	key := strings.Join([]string{
+		strings.TrimSuffix(s.EndpointURL, "/"), s.Bucket, strings.Trim(s.Prefix, "/"),
+	}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// TargetID returns a short hash of the library and the emulation path,
+// so syncing one library into two places (say, internal storage and an
+// SD card) keeps a separate local manifest and lock for each.
+func (c *Config) TargetID() string {
+	sum := sha256.Sum256([]byte(c.Storage.LibraryID() + "\x00" + c.Sync.EmulationPath))
+	return hex.EncodeToString(sum[:6])
+}
+
+// LibraryFile returns name with the ID of the library from the last
+// config loaded inserted before its extension, e.g.
+// upload-cache-1a2b3c4d5e6f.json, so one device can use several
+// libraries (or one library through two prefixes) without their state
+// mixing. Before any config is loaded, it returns name unchanged.
+func LibraryFile(name string) string {
+	return withID(name, &libraryID)
+}
+
+// TargetFile is LibraryFile for state that also depends on where the
+// library is synced to; see TargetID.
+func TargetFile(name string) string {
+	return withID(name, &targetID)
+}
+
+func withID(name string, v *atomic.Value) string {
+	id, _ := v.Load().(string)
+	if id == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + id + ext
+}
+
+// statePath returns the path in StateDir of keyed, a state file named
+// by LibraryFile or TargetFile from name. State from before files were
+// named this way is adopted by the first library to look for it: a
+// device that has only ever synced one library keeps its state across
+// the upgrade.
+func statePath(name, keyed string) string {
+	dir := StateDir()
+	path := filepath.Join(dir, keyed)
+	if legacy := filepath.Join(dir, name); legacy != path {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			os.Rename(legacy, path)
+		}
+	}
+	return path
+}
+
+// LockFile is the file sync holds a lock on while it runs, named by
+// TargetFile; see LockPath.
+const LockFile = "local-manifest.lock"
+
+// LockPath returns the lock file that guards the local manifest at
+// manifestPath, so only syncs that would share a manifest exclude each
+// other: different libraries, emulation paths, or state directories can
+// sync at the same time.
+func LockPath(manifestPath string) string {
+	return strings.TrimSuffix(manifestPath, ".json") + ".lock"
+}
+
+// StateFile describes a file emu-sync keeps in StateDir.
+type StateFile struct {
+	Name       string // file name in StateDir
+	About      string // what it holds, for state show
+	Cache      bool   // rebuilt automatically if removed; state clean removes it
+	PerLibrary bool   // named for the current library; see LibraryFile
+	PerTarget  bool   // named for the current library and emulation path; see TargetFile
+}
+
+// Path returns where f is for the current library.
+func (f StateFile) Path() string {
+	if f.PerTarget {
+		return filepath.Join(StateDir(), TargetFile(f.Name))
+	}
+	if f.PerLibrary {
+		return filepath.Join(StateDir(), LibraryFile(f.Name))
+	}
+	return filepath.Join(StateDir(), f.Name)
+}
+
+// StateFiles lists the files emu-sync keeps in StateDir.
+var StateFiles = []StateFile{
+	{Name: "local-manifest.json", About: "files synced to this device", PerTarget: true},
+	{Name: "last-sync.json", About: "outcome of the last sync"},
+	{Name: "usage.json", About: "bandwidth used per day"},
+	{Name: LockFile, About: "held while a sync runs", PerTarget: true},
+	{Name: "upload-cache.json", About: "hashes of uploaded files", Cache: true, PerLibrary: true},
+	{Name: "bucket-cache.json", About: "hashes of bucket objects (upload --from-bucket)", Cache: true, PerLibrary: true},
+	{Name: "scan-cache.json", About: "directory listings sync uses to spot deleted files", Cache: true, PerTarget: true},
+	{Name: "remote-verify.json", About: "objects re-hashed by verify --remote --deep", Cache: true},
+	{Name: "update-check.json", About: "latest release seen by the update check", Cache: true},
+}
@@ -0,0 +1,41 @@
+// Package desktop shows notifications on the user's desktop, so a
+// scheduled sync can say what it did without anyone watching a terminal.
+package desktop
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Notify shows a notification with title and body using the platform's
+// own tool: notify-send on Linux and the BSDs, osascript on macOS.
+func Notify(title, body string) error {
+	name, args, err := command(runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// command returns the program and arguments that show a notification
+// on goos.
+func command(goos, title, body string) (string, []string, error) {
+	switch goos {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return "notify-send", []string{"--app-name=emu-sync", title, body}, nil
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return "osascript", []string{"-e", script}, nil
+	}
+	return "", nil, fmt.Errorf("desktop notifications aren't supported on %s", goos)
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
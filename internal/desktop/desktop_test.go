@@ -0,0 +1,24 @@
+package desktop
+
+import "testing"
+
+func TestCommand(t *testing.T) {
+	title, body := `emu-sync`, `Downloaded 2 files: "Mario's" \ Zelda`
+
+	name, args, err := command("linux", title, body)
+	if err != nil || name != "notify-send" || args[len(args)-2] != title || args[len(args)-1] != body {
+		t.Errorf("linux: %s %q, %v", name, args, err)
+	}
+
+	name, args, err = command("darwin", title, body)
+	want := `display notification "Downloaded 2 files: \"Mario's\" \\ Zelda" with title "emu-sync"`
+	if err != nil || name != "osascript" || args[1] != want {
+		t.Errorf("darwin: %s %q, %v\nwant script %s", name, args, err, want)
+	}
+
+	for _, goos := range []string{"windows", "plan9"} {
+		if _, _, err := command(goos, title, body); err == nil {
+			t.Errorf("%s: want an error", goos)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package jobs runs the web UI's long-running tasks (sync, verify) in the
+// background, one at a time, and keeps each one's progress events and
+// outcome so a browser that reconnects or reloads can catch up.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyLimit is how many finished jobs a Manager remembers.
+const historyLimit = 20
+
+// State is where a job is in its life.
+type State string
+
+const (
+	Running  State = "running"
+	Complete State = "complete"
+	Failed   State = "failed"
+	Canceled State = "canceled"
+)
+
+// ErrBusy is returned by Start while another job is running.
+var ErrBusy = errors.New("another job is running")
+
+// Func is the work a job does. It writes JSON progress lines to log,
+// should stop early once ctx is canceled, and returns its result, which
+// may be non-nil even with an error.
+type Func func(ctx context.Context, log io.Writer) (any, error)
+
+// Info is a job's status as reported by the API.
+type Info struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	State    State     `json:"state"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Job is one run of a task.
+type Job struct {
+	ID      string
+	Kind    string
+	Started time.Time
+	Log     *Log
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	state     State
+	finished  time.Time
+	result    any
+	err       error
+	cancelled bool
+}
+
+// Done is closed when the job has finished and its result is stored.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Cancel asks a running job to stop. It has no effect once the job
+// has finished.
+func (j *Job) Cancel() {
+	j.mu.Lock()
+	if j.state == Running {
+		j.cancelled = true
+	}
+	j.mu.Unlock()
+	j.cancel()
+}
+
+// Result returns what the job's Func returned; both are nil while it runs.
+func (j *Job) Result() (any, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.result, j.err
+}
+
+// Info returns the job's current status.
+func (j *Job) Info() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := Info{ID: j.ID, Kind: j.Kind, State: j.state, Started: j.Started, Finished: j.finished}
+	if j.err != nil {
+		info.Error = j.err.Error()
+	}
+	return info
+}
+
+func (j *Job) run(ctx context.Context, fn Func) {
+	defer func() {
+		j.Log.Finish()
+		close(j.done)
+	}()
+	result, err := fn(ctx, j.Log)
+	j.cancel()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.result, j.err = result, err
+	j.finished = time.Now()
+	switch {
+	case j.cancelled:
+		j.state = Canceled
+	case err != nil:
+		j.state = Failed
+	default:
+		j.state = Complete
+	}
+}
+
+// Manager starts jobs and keeps the recent ones. The zero value is ready
+// to use.
+type Manager struct {
+	mu      sync.Mutex
+	nextID  int
+	history []*Job // oldest first
+}
+
+// Start runs fn in the background as a new job of the given kind. Only
+// one job runs at a time; while one does, Start returns an error
+// wrapping ErrBusy that names the running kind.
+func (m *Manager) Start(kind string, fn Func) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if running := m.running(); running != nil {
+		return nil, fmt.Errorf("%s is running: %w", running.Kind, ErrBusy)
+	}
+
+	m.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:      strconv.Itoa(m.nextID),
+		Kind:    kind,
+		Started: time.Now(),
+		Log:     NewLog(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		state:   Running,
+	}
+	m.history = append(m.history, job)
+	if len(m.history) > historyLimit {
+		m.history = m.history[len(m.history)-historyLimit:]
+	}
+	go job.run(ctx, fn)
+	return job, nil
+}
+
+// Running returns the job that is running, or nil.
+func (m *Manager) Running() *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running()
+}
+
+func (m *Manager) running() *Job {
+	for _, job := range m.history {
+		select {
+		case <-job.done:
+		default:
+			return job
+		}
+	}
+	return nil
+}
+
+// Get returns the job with the given ID, or nil if it's unknown or has
+// dropped out of the history.
+func (m *Manager) Get(id string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range m.history {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// Latest returns the most recent job of kind, or nil.
+func (m *Manager) Latest(kind string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.history) - 1; i >= 0; i-- {
+		if m.history[i].Kind == kind {
+			return m.history[i]
+		}
+	}
+	return nil
+}
+
+// List returns the status of the remembered jobs, newest first.
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	jobs := append([]*Job(nil), m.history...)
+	m.mu.Unlock()
+
+	infos := make([]Info, 0, len(jobs))
+	for i := len(jobs) - 1; i >= 0; i-- {
+		infos = append(infos, jobs[i].Info())
+	}
+	return infos
+}
+
+// Log captures a job's JSON progress lines for clients that read them
+// as they arrive or later. It implements io.Writer so it can be passed
+// to progress.NewReporterWriter.
+type Log struct {
+	mu     sync.Mutex
+	lines  []string
+	done   bool
+	notify chan struct{} // buffered(1), signaled on new event or finish
+}
+
+// NewLog returns an empty log.
+func NewLog() *Log {
+	return &Log{notify: make(chan struct{}, 1)}
+}
+
+func (l *Log) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	l.mu.Lock()
+	l.lines = append(l.lines, line)
+	l.mu.Unlock()
+	l.signal()
+	return len(p), nil
+}
+
+// Finish marks the log complete; readers stop once they've caught up.
+func (l *Log) Finish() {
+	l.mu.Lock()
+	l.done = true
+	l.mu.Unlock()
+	l.signal()
+}
+
+// Read returns the lines from index from on, and whether the log is
+// finished.
+func (l *Log) Read(from int) ([]string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if from >= len(l.lines) {
+		return nil, l.done
+	}
+	return l.lines[from:], l.done
+}
+
+// Notify is signaled after a Write or Finish. It is buffered, so one
+// signal may stand for several writes; readers should Read everything
+// new each time it fires.
+func (l *Log) Notify() <-chan struct{} {
+	return l.notify
+}
+
+func (l *Log) signal() {
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+}
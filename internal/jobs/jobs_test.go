@@ -0,0 +1,191 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogWriteAndRead(t *testing.T) {
+	l := NewLog()
+
+	l.Write([]byte(`{"event":"start","file":"a.rom"}` + "\n"))
+	l.Write([]byte(`{"event":"complete","file":"a.rom"}` + "\n"))
+
+	lines, done := l.Read(0)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if done {
+		t.Error("expected done=false")
+	}
+	if !strings.Contains(lines[0], "start") {
+		t.Errorf("expected start event, got %s", lines[0])
+	}
+}
+
+func TestLogReadFromOffset(t *testing.T) {
+	l := NewLog()
+
+	l.Write([]byte("line0\n"))
+	l.Write([]byte("line1\n"))
+	l.Write([]byte("line2\n"))
+
+	lines, _ := l.Read(1)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines from offset 1, got %d", len(lines))
+	}
+	if lines[0] != "line1" {
+		t.Errorf("expected line1, got %s", lines[0])
+	}
+}
+
+func TestLogFinish(t *testing.T) {
+	l := NewLog()
+
+	l.Write([]byte("line0\n"))
+	l.Finish()
+
+	lines, done := l.Read(0)
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d", len(lines))
+	}
+	if !done {
+		t.Error("expected done=true after finish")
+	}
+}
+
+func TestLogReadPastEnd(t *testing.T) {
+	l := NewLog()
+
+	l.Write([]byte("line0\n"))
+
+	lines, done := l.Read(5)
+	if len(lines) != 0 {
+		t.Fatalf("expected 0 lines from offset 5, got %d", len(lines))
+	}
+	if done {
+		t.Error("expected done=false")
+	}
+}
+
+func TestLogNotify(t *testing.T) {
+	l := NewLog()
+
+	// Drain notify channel if anything's there
+	select {
+	case <-l.Notify():
+	default:
+	}
+
+	l.Write([]byte("line\n"))
+
+	select {
+	case <-l.Notify():
+		// good
+	case <-time.After(100 * time.Millisecond):
+		t.Error("expected notify signal after Write")
+	}
+}
+
+func TestManagerRunsOneJobAtATime(t *testing.T) {
+	var m Manager
+	release := make(chan struct{})
+	job, err := m.Start("sync", func(ctx context.Context, log io.Writer) (any, error) {
+		log.Write([]byte("working\n"))
+		<-release
+		return "result", nil
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if job.Info().State != Running || m.Running() != job {
+		t.Fatalf("job should be running, got %+v", job.Info())
+	}
+
+	_, err = m.Start("verify", func(ctx context.Context, log io.Writer) (any, error) { return nil, nil })
+	if !errors.Is(err, ErrBusy) || !strings.Contains(err.Error(), "sync is running") {
+		t.Errorf("second Start error = %v, want ErrBusy naming sync", err)
+	}
+
+	close(release)
+	<-job.Done()
+	if result, err := job.Result(); result != "result" || err != nil {
+		t.Errorf("Result() = %v, %v", result, err)
+	}
+	if info := job.Info(); info.State != Complete || info.Finished.IsZero() {
+		t.Errorf("info = %+v, want complete with a finish time", info)
+	}
+	if lines, done := job.Log.Read(0); len(lines) != 1 || !done {
+		t.Errorf("log = %v, done %v; want one line, finished", lines, done)
+	}
+	if m.Running() != nil {
+		t.Error("no job should be running")
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	var m Manager
+	job, _ := m.Start("verify", func(ctx context.Context, log io.Writer) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	job.Cancel()
+	select {
+	case <-job.Done():
+	case <-time.After(time.Second):
+		t.Fatal("canceled job didn't stop")
+	}
+	if state := job.Info().State; state != Canceled {
+		t.Errorf("state = %s, want canceled", state)
+	}
+
+	failed, _ := m.Start("sync", func(ctx context.Context, log io.Writer) (any, error) {
+		return nil, errors.New("boom")
+	})
+	<-failed.Done()
+	failed.Cancel() // no effect after it finished
+	if info := failed.Info(); info.State != Failed || info.Error != "boom" {
+		t.Errorf("info = %+v, want failed with its error", info)
+	}
+}
+
+func TestManagerHistory(t *testing.T) {
+	var m Manager
+	var last *Job
+	for i := 0; i < historyLimit+5; i++ {
+		kind := "sync"
+		if i%2 == 1 {
+			kind = "verify"
+		}
+		job, err := m.Start(kind, func(ctx context.Context, log io.Writer) (any, error) { return nil, nil })
+		if err != nil {
+			t.Fatalf("Start %d: %v", i, err)
+		}
+		<-job.Done()
+		last = job
+	}
+
+	list := m.List()
+	if len(list) != historyLimit {
+		t.Fatalf("history has %d jobs, want %d", len(list), historyLimit)
+	}
+	if list[0].ID != last.ID {
+		t.Errorf("newest job = %s, want %s", list[0].ID, last.ID)
+	}
+	if m.Get("1") != nil {
+		t.Error("oldest job should have dropped out of the history")
+	}
+	if m.Get(last.ID) != last || m.Latest("sync") != last {
+		t.Error("Get and Latest should find the newest job")
+	}
+	if v := m.Latest("verify"); v == nil || v.Kind != "verify" {
+		t.Errorf("Latest(verify) = %v", v)
+	}
+	if m.Latest("upload") != nil {
+		t.Error("Latest of an unknown kind should be nil")
+	}
+}
@@ -0,0 +1,35 @@
+// Package logging gates diagnostic output on the verbosity chosen with
+// -v flags, so every package logs the same things at the same level.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Verbosity levels, from -v and -vv.
+const (
+	Quiet = 0 // warnings and results only
+	Files = 1 // per-file actions: downloads, uploads, deletes, hashing
+	Debug = 2 // retries, cache hits, tuning, and HTTP requests
+)
+
+var level atomic.Int32
+
+// SetLevel sets the verbosity for the rest of the process.
+func SetLevel(l int) {
+	level.Store(int32(l))
+}
+
+// Enabled reports whether messages at level l are shown.
+func Enabled(l int) bool {
+	return int(level.Load()) >= l
+}
+
+// Printf logs a message if the verbosity is at least l.
+func Printf(l int, format string, args ...any) {
+	if Enabled(l) {
+		log.Output(2, fmt.Sprintf(format, args...))
+	}
+}
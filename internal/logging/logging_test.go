@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrintfRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer SetLevel(Quiet)
+
+	SetLevel(Files)
+	Printf(Files, "downloading: %s", "a.sfc")
+	Printf(Debug, "cached: %s", "b.sfc")
+
+	out := buf.String()
+	if !strings.Contains(out, "downloading: a.sfc") {
+		t.Errorf("level 1 message missing at -v: %q", out)
+	}
+	if strings.Contains(out, "cached") {
+		t.Errorf("level 2 message shown at -v: %q", out)
+	}
+
+	SetLevel(Debug)
+	Printf(Debug, "cached: %s", "b.sfc")
+	if !strings.Contains(buf.String(), "cached: b.sfc") {
+		t.Error("level 2 message missing at -vv")
+	}
+	if Enabled(3) {
+		t.Error("Enabled(3) at level 2")
+	}
+}
@@ -0,0 +1,51 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Case collision policies, recorded in Manifest.CaseCollisions by the
+// upload that wrote the manifest.
+const (
+	CaseFail   = "fail"   // upload refuses keys that differ only in case
+	CaseRename = "rename" // upload renames all but one of them
+)
+
+// CaseCollisions returns the groups of keys that differ only in letter
+// case, and so name the same file on case-insensitive filesystems
+// (macOS, Windows, exFAT SD cards). Each group and the list of groups
+// are sorted.
+func CaseCollisions(keys []string) [][]string {
+	byFold := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		fold := strings.ToLower(key)
+		byFold[fold] = append(byFold[fold], key)
+	}
+	var groups [][]string
+	for _, group := range byFold {
+		if len(group) > 1 {
+			sort.Strings(group)
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// CaseRenamedKey returns key with " (n)" added before its extension,
+// e.g. roms/snes/game (2).sfc, choosing the smallest n from 2 up for
+// which taken reports false.
+func CaseRenamedKey(key string, taken func(string) bool) string {
+	dir, base := path.Split(key)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s%s (%d)%s", dir, stem, n, ext)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
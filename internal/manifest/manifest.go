@@ -3,17 +3,26 @@ package manifest
 import (
 	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 // FileEntry holds metadata for a single file in the manifest.
 type FileEntry struct {
-	Size int64  `json:"size"`
-	MD5  string `json:"md5"`
+	Size         int64    `json:"size"`
+	MD5          string   `json:"md5"`
+	ContentType  string   `json:"content_type,omitempty"`  // as set on the uploaded object
+	StorageClass string   `json:"storage_class,omitempty"` // "" = bucket default
+	Tags         []string `json:"tags,omitempty"`          // labels from the uploader's [sync.tuning], e.g. content ratings for policy.deny_tags
+	// Zeros lists [offset, length] regions that are entirely zero bytes,
+	// so sync can leave them as holes instead of downloading them.
+	Zeros [][2]int64 `json:"zeros,omitempty"`
 }
 
 // Manifest represents the full file manifest stored in the bucket.
@@ -21,6 +30,14 @@ type Manifest struct {
 	Version     int                  `json:"version"`
 	GeneratedAt time.Time            `json:"generated_at"`
 	Files       map[string]FileEntry `json:"files"`
+	// CaseCollisions is the policy the uploader applied to keys that
+	// differ only in case (CaseFail or CaseRename); "" for manifests
+	// written before it was recorded or by other tools.
+	CaseCollisions string `json:"case_collisions,omitempty"`
+	// Renamed maps keys that differ from the source paths (relative,
+	// slash-separated) they were uploaded from, because of CaseRename or
+	// NormalizeKey, to those paths.
+	Renamed map[string]string `json:"renamed,omitempty"`
 }
 
 // DiffResult describes what changed between a remote and local manifest.
@@ -54,6 +71,9 @@ func LoadJSON(path string) (*Manifest, error) {
 	if m.Files == nil {
 		m.Files = make(map[string]FileEntry)
 	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
 
 	return &m, nil
 }
@@ -68,10 +88,51 @@ func ParseJSON(data []byte) (*Manifest, error) {
 	if m.Files == nil {
 		m.Files = make(map[string]FileEntry)
 	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
 
 	return &m, nil
 }
 
+// ErrInvalidKey is wrapped by ValidKey's errors.
+var ErrInvalidKey = errors.New("invalid key")
+
+// ValidKey checks that key is a relative, slash-separated path with no
+// empty, "." or ".." segments, so joining it to a directory can't reach
+// outside it.
+func ValidKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidKey)
+	}
+	if strings.HasPrefix(key, "/") {
+		return fmt.Errorf("%w %q: absolute path", ErrInvalidKey, key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("%w %q: empty, \".\", or \"..\" path segment", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
+
+// validate rejects a manifest with a key (or renamed source path) that
+// isn't a ValidKey. A corrupted or malicious manifest could otherwise
+// make sync write or delete files outside the emulation path.
+func (m *Manifest) validate() error {
+	for key := range m.Files {
+		if err := ValidKey(key); err != nil {
+			return err
+		}
+	}
+	for key, src := range m.Renamed {
+		if err := ValidKey(src); err != nil {
+			return fmt.Errorf("renamed %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
 // SaveJSON writes the manifest to a JSON file on disk.
 func (m *Manifest) SaveJSON(path string) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
@@ -101,25 +162,62 @@ func (m *Manifest) ToJSON() ([]byte, error) {
 	return json.MarshalIndent(m, "", "  ")
 }
 
-// Diff compares a remote manifest against a local one and returns what changed.
-func Diff(remote, local *Manifest) DiffResult {
-	var result DiffResult
+// Change is how a path differs between a remote and local manifest.
+type Change int
+
+const (
+	Unchanged Change = iota // in both with the same hash and size
+	Added                   // in remote but not local
+	Modified                // in both but different hash/size
+	Deleted                 // in local but not remote
+)
 
-	for path, remoteEntry := range remote.Files {
-		localEntry, exists := local.Files[path]
-		if !exists {
-			result.Added = append(result.Added, path)
-		} else if localEntry.MD5 != remoteEntry.MD5 || localEntry.Size != remoteEntry.Size {
-			result.Modified = append(result.Modified, path)
+// Changes yields every path in remote or local with how it changed, in
+// no particular order. Unlike Diff it builds nothing, so callers that
+// only count changes, or keep some of them, don't hold lists for all
+// of a very large library.
+func Changes(remote, local *Manifest) iter.Seq2[string, Change] {
+	return func(yield func(string, Change) bool) {
+		for path, remoteEntry := range remote.Files {
+			c := Unchanged
+			if localEntry, exists := local.Files[path]; !exists {
+				c = Added
+			} else if localEntry.MD5 != remoteEntry.MD5 || localEntry.Size != remoteEntry.Size {
+				c = Modified
+			}
+			if !yield(path, c) {
+				return
+			}
+		}
+		for path := range local.Files {
+			if _, exists := remote.Files[path]; !exists {
+				if !yield(path, Deleted) {
+					return
+				}
+			}
 		}
 	}
+}
 
-	for path := range local.Files {
-		if _, exists := remote.Files[path]; !exists {
-			result.Deleted = append(result.Deleted, path)
-		}
+// Add records a change reported by Changes in r. Unchanged paths are
+// ignored.
+func (r *DiffResult) Add(path string, c Change) {
+	switch c {
+	case Added:
+		r.Added = append(r.Added, path)
+	case Modified:
+		r.Modified = append(r.Modified, path)
+	case Deleted:
+		r.Deleted = append(r.Deleted, path)
 	}
+}
 
+// Diff compares a remote manifest against a local one and returns what changed.
+func Diff(remote, local *Manifest) DiffResult {
+	var result DiffResult
+	for path, c := range Changes(remote, local) {
+		result.Add(path, c)
+	}
 	return result
 }
 
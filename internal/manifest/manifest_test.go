@@ -3,6 +3,8 @@ package manifest
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -62,6 +64,22 @@ func TestParseJSON(t *testing.T) {
 	}
 }
 
+func TestParseJSONRejectsUnsafeKeys(t *testing.T) {
+	for _, key := range []string{"../../.bashrc", "roms/../../x", "/etc/passwd", "roms//x", "roms/./x", "roms/", ""} {
+		data := []byte(`{"version":1,"files":{"` + key + `":{"size":1,"md5":"aaa"}}}`)
+		if _, err := ParseJSON(data); err == nil {
+			t.Errorf("ParseJSON accepted key %q", key)
+		}
+	}
+	data := []byte(`{"version":1,"files":{"roms/a.sfc":{"size":1,"md5":"aaa"}},"renamed":{"roms/a.sfc":"../a.sfc"}}`)
+	if _, err := ParseJSON(data); err == nil {
+		t.Error("ParseJSON accepted an unsafe renamed source path")
+	}
+	if err := ValidKey("roms/snes/..Game.sfc"); err != nil {
+		t.Errorf("ValidKey rejected a name starting with dots: %v", err)
+	}
+}
+
 func TestParseJSONEmptyFiles(t *testing.T) {
 	data := []byte(`{"version":1,"generated_at":"2026-01-01T00:00:00Z"}`)
 
@@ -94,6 +112,41 @@ func TestDiffAdded(t *testing.T) {
 	}
 }
 
+func TestChanges(t *testing.T) {
+	remote := New()
+	remote.Files["roms/same.rom"] = FileEntry{Size: 1, MD5: "a"}
+	remote.Files["roms/new.rom"] = FileEntry{Size: 2, MD5: "b"}
+	remote.Files["roms/changed.rom"] = FileEntry{Size: 3, MD5: "c"}
+	local := New()
+	local.Files["roms/same.rom"] = FileEntry{Size: 1, MD5: "a"}
+	local.Files["roms/changed.rom"] = FileEntry{Size: 3, MD5: "old"}
+	local.Files["roms/gone.rom"] = FileEntry{Size: 4, MD5: "d"}
+
+	got := make(map[string]Change)
+	for path, c := range Changes(remote, local) {
+		got[path] = c
+	}
+	want := map[string]Change{
+		"roms/same.rom":    Unchanged,
+		"roms/new.rom":     Added,
+		"roms/changed.rom": Modified,
+		"roms/gone.rom":    Deleted,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Changes = %v, want %v", got, want)
+	}
+	for path, c := range want {
+		if got[path] != c {
+			t.Errorf("%s: %v, want %v", path, got[path], c)
+		}
+	}
+
+	// Stopping early is allowed.
+	for range Changes(remote, local) {
+		break
+	}
+}
+
 func TestDiffModified(t *testing.T) {
 	remote := New()
 	remote.Files["roms/game.rom"] = FileEntry{Size: 200, MD5: "new_hash"}
@@ -232,3 +285,17 @@ func TestToJSON(t *testing.T) {
 		t.Errorf("round-trip got %d files, want 1", len(roundtrip.Files))
 	}
 }
+
+func TestCaseCollisions(t *testing.T) {
+	groups := CaseCollisions([]string{"roms/b.sfc", "roms/Game.sfc", "roms/B.sfc", "roms/game.sfc", "roms/GAME.sfc", "roms/c.sfc"})
+	want := [][]string{{"roms/B.sfc", "roms/b.sfc"}, {"roms/GAME.sfc", "roms/Game.sfc", "roms/game.sfc"}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("CaseCollisions = %v, want %v", groups, want)
+	}
+
+	taken := map[string]bool{"roms/game (2).sfc": true}
+	got := CaseRenamedKey("roms/Game.sfc", func(k string) bool { return taken[strings.ToLower(k)] })
+	if got != "roms/Game (3).sfc" {
+		t.Errorf("CaseRenamedKey = %q, want the first free number", got)
+	}
+}
@@ -0,0 +1,12 @@
+package manifest
+
+import "golang.org/x/text/unicode/norm"
+
+// NormalizeKey returns key in Unicode normalization form C, the form
+// upload writes keys in. macOS often reports file names decomposed (NFD,
+// e.g. "ガ" as "カ" plus a combining mark), while Linux and Windows
+// keep them as typed, usually NFC, so without this the same file could
+// reach the manifest under two keys.
+func NormalizeKey(key string) string {
+	return norm.NFC.String(key)
+}
@@ -0,0 +1,92 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ZeroChunkSize is the granularity of zero-region detection. Regions are
+// aligned to it, which also keeps them aligned to filesystem blocks.
+const ZeroChunkSize = 1 << 20
+
+// minZeroRun is the shortest zero region worth recording. Shorter runs
+// would cost more in manifest size and extra range requests than they
+// save in transfer.
+const minZeroRun = 4 * ZeroChunkSize
+
+// ScanFile computes the MD5 hex digest of a file like HashFile, and also
+// returns the chunk-aligned regions of at least minZeroRun bytes that are
+// entirely zero, as recorded in FileEntry.Zeros. Padded disc and cartridge
+// images often end in hundreds of megabytes of them.
+func ScanFile(path string) (string, [][2]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("opening file for hashing: %w", err)
+	}
+	defer f.Close()
+	return ScanReader(f)
+}
+
+// ScanReader is ScanFile for content read from r.
+func ScanReader(r io.Reader) (string, [][2]int64, error) {
+	h := md5.New()
+	buf := make([]byte, ZeroChunkSize)
+	var zeros [][2]int64
+	var off, runStart, runLen int64
+	endRun := func() {
+		if runLen >= minZeroRun {
+			zeros = append(zeros, [2]int64{runStart, runLen})
+		}
+		runLen = 0
+	}
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			if n == ZeroChunkSize && allZero(buf) {
+				if runLen == 0 {
+					runStart = off
+				}
+				runLen += int64(n)
+			} else {
+				endRun()
+			}
+			off += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("hashing file: %w", err)
+		}
+	}
+	endRun()
+
+	return fmt.Sprintf("%x", h.Sum(nil)), zeros, nil
+}
+
+// allZero reports whether b contains only zero bytes. A buffer whose first
+// byte is zero and which equals itself shifted by one byte is all zeros.
+func allZero(b []byte) bool {
+	return len(b) > 0 && b[0] == 0 && bytes.Equal(b[1:], b[:len(b)-1])
+}
+
+// DataRanges returns the [offset, length] regions of the file that are not
+// covered by Zeros, in order. A file without zero regions is one range.
+func (e FileEntry) DataRanges() [][2]int64 {
+	var ranges [][2]int64
+	var off int64
+	for _, z := range e.Zeros {
+		if z[0] > off {
+			ranges = append(ranges, [2]int64{off, z[0] - off})
+		}
+		off = z[0] + z[1]
+	}
+	if off < e.Size {
+		ranges = append(ranges, [2]int64{off, e.Size - off})
+	}
+	return ranges
+}
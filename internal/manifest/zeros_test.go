@@ -0,0 +1,59 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScanFileFindsZeroRegions(t *testing.T) {
+	const mb = ZeroChunkSize
+	data := make([]byte, 12*mb+100)
+	data[0] = 1       // chunk 0 has data
+	data[4*mb] = 1    // chunks 1-3 are zero, but too short to record
+	data[6*mb+10] = 1 // chunk 6 has data; chunks 7-11 are zero
+	path := filepath.Join(t.TempDir(), "game.iso")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, zeros, err := ScanFile(path)
+	if err != nil {
+		t.Fatalf("ScanFile: %v", err)
+	}
+	want, _ := HashFile(path)
+	if hash != want {
+		t.Errorf("hash = %q, want %q", hash, want)
+	}
+	// The trailing partial chunk is never counted as zero
+	if !reflect.DeepEqual(zeros, [][2]int64{{7 * mb, 5 * mb}}) {
+		t.Errorf("zeros = %v, want [[%d %d]]", zeros, 7*mb, 5*mb)
+	}
+}
+
+func TestScanFileSmallFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sfc")
+	os.WriteFile(path, make([]byte, 1000), 0o644)
+
+	_, zeros, err := ScanFile(path)
+	if err != nil {
+		t.Fatalf("ScanFile: %v", err)
+	}
+	if zeros != nil {
+		t.Errorf("zeros = %v, want none", zeros)
+	}
+}
+
+func TestDataRanges(t *testing.T) {
+	e := FileEntry{Size: 100, Zeros: [][2]int64{{0, 10}, {40, 20}, {90, 10}}}
+	want := [][2]int64{{10, 30}, {60, 30}}
+	if got := e.DataRanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DataRanges = %v, want %v", got, want)
+	}
+
+	e = FileEntry{Size: 100}
+	if got := e.DataRanges(); !reflect.DeepEqual(got, [][2]int64{{0, 100}}) {
+		t.Errorf("DataRanges without zeros = %v, want whole file", got)
+	}
+}
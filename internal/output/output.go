@@ -0,0 +1,61 @@
+// Package output holds the display preferences chosen with the global
+// --ascii and --quiet flags, so every command formats what it prints the
+// same way.
+package output
+
+import "sync/atomic"
+
+var ascii, quiet atomic.Bool
+
+// SetASCII sets whether output spells out symbol markers, for screen
+// readers and basic consoles.
+func SetASCII(on bool) {
+	ascii.Store(on)
+}
+
+// ASCII reports whether --ascii is in effect.
+func ASCII() bool {
+	return ascii.Load()
+}
+
+// SetQuiet sets whether commands print only warnings and errors.
+func SetQuiet(on bool) {
+	quiet.Store(on)
+}
+
+// Quiet reports whether --quiet is in effect.
+func Quiet() bool {
+	return quiet.Load()
+}
+
+// Marker returns the list marker m, such as "+" or "~", or a space
+// under --ascii, where the list's heading already says what the entries
+// are and a screen reader would read the symbol aloud.
+func Marker(m string) string {
+	if ASCII() {
+		return " "
+	}
+	return m
+}
+
+// Check returns the selection marker for state ("all", "partial" or
+// "none"): [x], [~] or [ ], or under --ascii the words all, some or
+// none, padded to the same width as each other.
+func Check(state string) string {
+	if ASCII() {
+		switch state {
+		case "all":
+			return "all "
+		case "partial":
+			return "some"
+		}
+		return "none"
+	}
+	switch state {
+	case "all":
+		return "[x]"
+	case "partial":
+		return "[~]"
+	}
+	return "[ ]"
+}
@@ -0,0 +1,24 @@
+package output
+
+import "testing"
+
+func TestASCII(t *testing.T) {
+	defer SetASCII(false)
+
+	if got := Check("partial"); got != "[~]" {
+		t.Errorf("Check(partial) = %q, want [~]", got)
+	}
+	if got := Marker("+"); got != "+" {
+		t.Errorf("Marker(+) = %q, want +", got)
+	}
+
+	SetASCII(true)
+	for state, want := range map[string]string{"all": "all ", "partial": "some", "none": "none"} {
+		if got := Check(state); got != want {
+			t.Errorf("ASCII Check(%s) = %q, want %q", state, got, want)
+		}
+	}
+	if got := Marker("~"); got != " " {
+		t.Errorf("ASCII Marker(~) = %q, want a space", got)
+	}
+}
@@ -0,0 +1,61 @@
+// Package power reports whether the machine is running on battery or in
+// a low-power mode, so scheduled syncs can back off on laptops.
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// State is the machine's current power source.
+type State struct {
+	OnBattery bool // running on battery, not mains power
+	LowPower  bool // the OS is in a battery-saving mode (macOS Low Power Mode)
+}
+
+// Constrained reports whether syncs should back off.
+func (s State) Constrained() bool {
+	return s.OnBattery || s.LowPower
+}
+
+// parsePmset reads the output of `pmset -g batt` and `pmset -g`.
+func parsePmset(batt, settings string) State {
+	var s State
+	s.OnBattery = strings.Contains(batt, "'Battery Power'")
+	for _, line := range strings.Split(settings, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "lowpowermode" && fields[1] == "1" {
+			s.LowPower = true
+		}
+	}
+	return s
+}
+
+// readSysfs reads power supplies under dir (normally
+// /sys/class/power_supply). The machine is on battery if it has a
+// discharging battery and no online mains adapter.
+func readSysfs(dir string) State {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return State{}
+	}
+	read := func(supply, name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, supply, name))
+		return strings.TrimSpace(string(data))
+	}
+	var mainsOnline, discharging bool
+	for _, e := range entries {
+		switch read(e.Name(), "type") {
+		case "Mains", "USB":
+			if read(e.Name(), "online") == "1" {
+				mainsOnline = true
+			}
+		case "Battery":
+			if read(e.Name(), "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return State{OnBattery: discharging && !mainsOnline}
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+
+package power
+
+import "os/exec"
+
+// Check returns the current power state from pmset.
+func Check() (State, error) {
+	batt, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return State{}, err
+	}
+	// Low Power Mode is only reported on macOS 12 and later
+	settings, _ := exec.Command("pmset", "-g").Output()
+	return parsePmset(string(batt), string(settings)), nil
+}
@@ -0,0 +1,9 @@
+//go:build linux
+
+package power
+
+// Check returns the current power state from sysfs. Machines without a
+// battery report mains power.
+func Check() (State, error) {
+	return readSysfs("/sys/class/power_supply"), nil
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !linux
+
+package power
+
+// Check reports mains power on platforms without battery detection.
+func Check() (State, error) {
+	return State{}, nil
+}
@@ -0,0 +1,60 @@
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePmset(t *testing.T) {
+	battery := `Now drawing from 'Battery Power'
+ -InternalBattery-0 (id=1234)	81%; discharging; 5:12 remaining present: true
+`
+	ac := `Now drawing from 'AC Power'
+ -InternalBattery-0 (id=1234)	100%; charged; 0:00 remaining present: true
+`
+	settings := `System-wide power settings:
+Currently in use:
+ standby              1
+ lowpowermode         1
+ sleep                1
+`
+
+	if s := parsePmset(battery, ""); !s.OnBattery || s.LowPower {
+		t.Errorf("battery: got %+v", s)
+	}
+	if s := parsePmset(ac, ""); s.Constrained() {
+		t.Errorf("AC: got %+v, want unconstrained", s)
+	}
+	if s := parsePmset(ac, settings); !s.LowPower || !s.Constrained() {
+		t.Errorf("low power mode: got %+v", s)
+	}
+}
+
+func TestReadSysfs(t *testing.T) {
+	supply := func(dir, name string, files map[string]string) {
+		t.Helper()
+		os.MkdirAll(filepath.Join(dir, name), 0o755)
+		for f, v := range files {
+			os.WriteFile(filepath.Join(dir, name, f), []byte(v+"\n"), 0o644)
+		}
+	}
+
+	onBattery := t.TempDir()
+	supply(onBattery, "ACAD", map[string]string{"type": "Mains", "online": "0"})
+	supply(onBattery, "BAT1", map[string]string{"type": "Battery", "status": "Discharging"})
+	if s := readSysfs(onBattery); !s.OnBattery {
+		t.Errorf("discharging, adapter offline: got %+v", s)
+	}
+
+	pluggedIn := t.TempDir()
+	supply(pluggedIn, "ACAD", map[string]string{"type": "Mains", "online": "1"})
+	supply(pluggedIn, "BAT1", map[string]string{"type": "Battery", "status": "Charging"})
+	if s := readSysfs(pluggedIn); s.OnBattery {
+		t.Errorf("plugged in: got %+v", s)
+	}
+
+	if s := readSysfs(filepath.Join(t.TempDir(), "missing")); s.OnBattery {
+		t.Errorf("no power_supply dir: got %+v", s)
+	}
+}
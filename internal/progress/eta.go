@@ -0,0 +1,118 @@
+package progress
+
+import (
+	"strconv"
+	"time"
+)
+
+// ETAInterval is how often a Reporter emits eta events while files are
+// finishing. Transfers report progress per file, so a run busy with one
+// large file goes quiet until it finishes.
+const ETAInterval = 5 * time.Second
+
+// etaWeight is how much each new throughput sample counts in the moving
+// average, against the average so far. Lower is steadier but slower to
+// follow a change in speed.
+const etaWeight = 0.3
+
+// Estimate is how much of a run is left and how long it should take.
+type Estimate struct {
+	Files int           // files planned but not finished
+	Bytes int64         // their total size
+	Rate  float64       // moving-average throughput, bytes per second; 0 until measured
+	Left  time.Duration // Bytes at Rate; 0 until Rate is measured
+}
+
+// estimator keeps a moving average of a run's throughput from its plan,
+// start, and finish events. Not safe for concurrent use; a Reporter
+// guards its own.
+type estimator struct {
+	now        func() time.Time
+	totalFiles int
+	totalBytes int64
+	doneFiles  int
+	doneBytes  int64
+	sizes      map[string]int64 // started files -> size
+
+	sampleAt    time.Time // when the last throughput sample was taken
+	sampleBytes int64     // doneBytes at sampleAt
+	rate        float64
+}
+
+func newEstimator(now func() time.Time) *estimator {
+	return &estimator{now: now, sizes: make(map[string]int64)}
+}
+
+// observe updates the estimate for e and reports whether a new
+// throughput sample was taken, which is when an eta event is due.
+func (est *estimator) observe(e Event) bool {
+	switch e.Type {
+	case EventPlan:
+		// A later plan covers the earlier one's files too.
+		est.totalFiles, est.totalBytes = e.Total, e.Size
+		if est.sampleAt.IsZero() {
+			est.sampleAt = est.now()
+		}
+	case EventStart:
+		est.sizes[e.File] = e.Size
+	case EventComplete, EventError:
+		est.doneFiles++
+		est.doneBytes += est.sizes[e.File]
+		delete(est.sizes, e.File)
+		return est.sample()
+	case EventDone:
+		*est = *newEstimator(est.now)
+	}
+	return false
+}
+
+// sample folds the throughput since the last sample into the average,
+// at most once per ETAInterval.
+func (est *estimator) sample() bool {
+	if est.sampleAt.IsZero() {
+		return false
+	}
+	now := est.now()
+	elapsed := now.Sub(est.sampleAt)
+	if elapsed < ETAInterval {
+		return false
+	}
+	rate := float64(est.doneBytes-est.sampleBytes) / elapsed.Seconds()
+	if est.rate == 0 {
+		est.rate = rate
+	} else {
+		est.rate = etaWeight*rate + (1-etaWeight)*est.rate
+	}
+	est.sampleAt, est.sampleBytes = now, est.doneBytes
+	return true
+}
+
+func (est *estimator) estimate() Estimate {
+	e := Estimate{
+		Files: max(est.totalFiles-est.doneFiles, 0),
+		Bytes: max(est.totalBytes-est.doneBytes, 0),
+		Rate:  est.rate,
+	}
+	if e.Rate > 0 {
+		e.Left = time.Duration(float64(e.Bytes) / e.Rate * float64(time.Second))
+	}
+	return e
+}
+
+// FormatLeft describes d as time left, e.g. "about 14 minutes
+// remaining", for progress lines.
+func FormatLeft(d time.Duration) string {
+	switch minutes := int(d.Round(time.Minute).Minutes()); {
+	case minutes < 1:
+		return "less than a minute remaining"
+	case minutes == 1:
+		return "about 1 minute remaining"
+	case minutes < 60:
+		return "about " + strconv.Itoa(minutes) + " minutes remaining"
+	}
+	hours := int(d.Round(time.Hour).Hours())
+	if hours == 1 {
+		return "about 1 hour remaining"
+	}
+	return "about " + strconv.Itoa(hours) + " hours remaining"
+}
@@ -6,62 +6,182 @@ import (
 	"io"
 	"os"
 	gosync "sync"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
 )
 
 // Event types emitted as JSON lines.
 const (
+	EventPlan     = "plan"
 	EventStart    = "start"
 	EventComplete = "complete"
 	EventError    = "error"
 	EventDelete   = "delete"
 	EventSkip     = "skip"
 	EventRetain   = "retain"
+	EventWarning  = "warning"
 	EventDone     = "done"
+	EventETA      = "eta" // how much is left, every ETAInterval while files finish
+
+	// Emitted instead of transfers and deletions by a dry run.
+	EventWouldDownload = "would_download"
+	EventWouldDelete   = "would_delete"
 )
 
 // Event is a single progress event emitted as a JSON line.
 type Event struct {
-	Type       string `json:"event"`
-	File       string `json:"file,omitempty"`
-	Size       int64  `json:"size,omitempty"`
-	Error      string `json:"error,omitempty"`
-	Downloaded int    `json:"downloaded,omitempty"`
-	Deleted    int    `json:"deleted,omitempty"`
-	Retained   int    `json:"retained,omitempty"`
-	Errors     int    `json:"errors,omitempty"`
-	Skipped    int    `json:"skipped,omitempty"`
-}
-
-// Reporter emits progress events. Safe for concurrent use.
+	Type       string  `json:"event"`
+	File       string  `json:"file,omitempty"`
+	Size       int64   `json:"size,omitempty"`
+	Total      int     `json:"total,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	Code       string  `json:"code,omitempty"` // error: why it failed; see syncerr
+	Message    string  `json:"message,omitempty"`
+	Downloaded int     `json:"downloaded,omitempty"`
+	Uploaded   int     `json:"uploaded,omitempty"`
+	Deleted    int     `json:"deleted,omitempty"`
+	Retained   int     `json:"retained,omitempty"`
+	Errors     int     `json:"errors,omitempty"`
+	Skipped    int     `json:"skipped,omitempty"`
+	Bytes      int64   `json:"bytes,omitempty"`   // done: total size of the files transferred
+	Seconds    float64 `json:"seconds,omitempty"` // done: how long the run took; eta: estimated time left
+
+	// eta: files and bytes planned but not finished, and the moving
+	// average throughput in bytes per second the estimate is based on.
+	RemainingFiles int     `json:"remaining_files,omitempty"`
+	RemainingBytes int64   `json:"remaining_bytes,omitempty"`
+	Rate           float64 `json:"rate,omitempty"`
+}
+
+// Summary is the outcome of a run, sent as its done event.
+type Summary struct {
+	Downloaded int
+	Uploaded   int
+	Deleted    int
+	Retained   int
+	Errors     int
+	Skipped    int
+	Bytes      int64         // total size of the files transferred
+	Duration   time.Duration // how long the run took
+	Message    string        // what a person would want to hear about the run, e.g. the new games it synced; may be empty
+}
+
+// Sink receives progress events. A Reporter hands each event to its
+// sinks one at a time, in order, so a sink needs no locking of its own
+// unless it is shared between reporters. Sinks run on the transfer
+// path and should not block for long.
+type Sink interface {
+	Handle(e Event)
+}
+
+// JSONSink writes each event as a JSON line, the format behind
+// --progress-json and the web UI's event streams.
+type JSONSink struct {
+	w io.Writer
+}
+
+// NewJSONSink returns a sink that writes JSON lines to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// Handle writes e as one JSON line.
+func (s *JSONSink) Handle(e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(s.w, string(data))
+}
+
+// Reporter emits progress events to any number of sinks, so one run can
+// be followed by several observers at once (e.g., a terminal progress
+// bar and a webhook). A reporter with no sinks does nothing. Safe for
+// concurrent use.
 type Reporter struct {
-	mu      gosync.Mutex
-	w       io.Writer
-	enabled bool
+	mu    gosync.Mutex
+	sinks []Sink
+	done  bool // the done event has been sent
+	eta   *estimator
+}
+
+// New creates a reporter that sends events to sinks.
+func New(sinks ...Sink) *Reporter {
+	return &Reporter{sinks: sinks, eta: newEstimator(time.Now)}
 }
 
 // NewReporter creates a reporter that writes JSON lines to stdout.
-// If enabled is false, all methods are no-ops.
+// If enabled is false, it has no sinks until one is attached.
 func NewReporter(enabled bool) *Reporter {
-	return &Reporter{w: os.Stdout, enabled: enabled}
+	if !enabled {
+		return New()
+	}
+	return New(NewJSONSink(os.Stdout))
 }
 
 // NewReporterWriter creates a reporter that writes JSON lines to w.
 func NewReporterWriter(w io.Writer) *Reporter {
-	return &Reporter{w: w, enabled: true}
+	return New(NewJSONSink(w))
 }
 
-// Emit writes a single JSON event line.
+// Attach adds s to the sinks that receive later events.
+func (r *Reporter) Attach(s Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks = append(r.sinks, s)
+}
+
+// Next returns a reporter with the same sinks for another run, such as
+// the next sync under --follow, which gets a done event of its own.
+func (r *Reporter) Next() *Reporter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return New(r.sinks...)
+}
+
+// Emit sends a single event to every sink. Only the first done event
+// is sent, so observers can rely on seeing exactly one summary. A file
+// finishing is followed by an eta event when one is due.
 func (r *Reporter) Emit(e Event) {
-	if !r.enabled {
-		return
-	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	data, err := json.Marshal(e)
-	if err != nil {
-		return
+	if e.Type == EventDone {
+		if r.done {
+			return
+		}
+		r.done = true
+	}
+	for _, s := range r.sinks {
+		s.Handle(e)
+	}
+	if r.eta.observe(e) {
+		est := r.eta.estimate()
+		eta := Event{
+			Type:           EventETA,
+			Seconds:        est.Left.Round(time.Second).Seconds(),
+			RemainingFiles: est.Files,
+			RemainingBytes: est.Bytes,
+			Rate:           est.Rate,
+		}
+		for _, s := range r.sinks {
+			s.Handle(eta)
+		}
 	}
-	fmt.Fprintln(r.w, string(data))
+}
+
+// Estimate returns how much of the run is left, for callers that poll
+// rather than follow the events.
+func (r *Reporter) Estimate() Estimate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.eta.estimate()
+}
+
+// Plan emits the number and total size of files the run will transfer.
+// It may be emitted again if more files are found during the run.
+func (r *Reporter) Plan(files int, size int64) {
+	r.Emit(Event{Type: EventPlan, Total: files, Size: size})
 }
 
 // Start emits a file download/upload start event.
@@ -76,7 +196,7 @@ func (r *Reporter) Complete(file string) {
 
 // FileError emits a file error event.
 func (r *Reporter) FileError(file string, err error) {
-	r.Emit(Event{Type: EventError, File: file, Error: err.Error()})
+	r.Emit(Event{Type: EventError, File: file, Error: err.Error(), Code: syncerr.Code(err)})
 }
 
 // Delete emits a file deletion event.
@@ -94,14 +214,33 @@ func (r *Reporter) Retain(file string) {
 	r.Emit(Event{Type: EventRetain, File: file})
 }
 
-// Done emits a summary event.
-func (r *Reporter) Done(downloaded, deleted, retained, errors, skipped int) {
+// WouldDownload emits a dry-run event for a file a sync would download.
+func (r *Reporter) WouldDownload(file string, size int64) {
+	r.Emit(Event{Type: EventWouldDownload, File: file, Size: size})
+}
+
+// WouldDelete emits a dry-run event for a file a sync would delete.
+func (r *Reporter) WouldDelete(file string) {
+	r.Emit(Event{Type: EventWouldDelete, File: file})
+}
+
+// Warning emits a run-level warning that the user should see.
+func (r *Reporter) Warning(msg string) {
+	r.Emit(Event{Type: EventWarning, Message: msg})
+}
+
+// Done emits the run's summary. Calls after the first are ignored.
+func (r *Reporter) Done(s Summary) {
 	r.Emit(Event{
 		Type:       EventDone,
-		Downloaded: downloaded,
-		Deleted:    deleted,
-		Retained:   retained,
-		Errors:     errors,
-		Skipped:    skipped,
+		Downloaded: s.Downloaded,
+		Uploaded:   s.Uploaded,
+		Deleted:    s.Deleted,
+		Retained:   s.Retained,
+		Errors:     s.Errors,
+		Skipped:    s.Skipped,
+		Bytes:      s.Bytes,
+		Seconds:    s.Duration.Round(time.Millisecond).Seconds(),
+		Message:    s.Message,
 	})
 }
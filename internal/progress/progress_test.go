@@ -6,17 +6,18 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReporterEmitsJSON(t *testing.T) {
 	var buf bytes.Buffer
-	r := &Reporter{w: &buf, enabled: true}
+	r := NewReporterWriter(&buf)
 
 	r.Start("roms/snes/Game.sfc", 1024)
 	r.Complete("roms/snes/Game.sfc")
 	r.FileError("roms/bad.rom", fmt.Errorf("connection reset"))
 	r.Delete("roms/old.rom")
-	r.Done(1, 1, 0, 1, 0)
+	r.Done(Summary{Downloaded: 1, Deleted: 1, Errors: 1})
 
 	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
 	if len(lines) != 5 {
@@ -56,6 +57,35 @@ func TestReporterEmitsJSON(t *testing.T) {
 	}
 }
 
+// TestDoneEventJSON pins the done event's JSON, which the web UI's sync
+// result card and --progress-json consumers read.
+func TestDoneEventJSON(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewReporterWriter(&buf)
+
+	r.Done(Summary{
+		Downloaded: 2,
+		Deleted:    1,
+		Retained:   1,
+		Errors:     1,
+		Skipped:    3,
+		Bytes:      2048,
+		Duration:   1500*time.Millisecond + 300*time.Microsecond,
+	})
+	r.Done(Summary{Downloaded: 9})
+
+	want := `{"event":"done","downloaded":2,"deleted":1,"retained":1,"errors":1,"skipped":3,"bytes":2048,"seconds":1.5}` + "\n"
+	if buf.String() != want {
+		t.Errorf("done events =\n%s\nwant (exactly once)\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	NewReporterWriter(&buf).Done(Summary{})
+	if got := buf.String(); got != `{"event":"done"}`+"\n" {
+		t.Errorf("empty summary = %s", got)
+	}
+}
+
 func TestNewReporterWriter(t *testing.T) {
 	var buf bytes.Buffer
 	r := NewReporterWriter(&buf)
@@ -78,13 +108,92 @@ func TestNewReporterWriter(t *testing.T) {
 }
 
 func TestReporterDisabled(t *testing.T) {
-	var buf bytes.Buffer
-	r := &Reporter{w: &buf, enabled: false}
-
+	r := NewReporter(false)
+	if len(r.sinks) != 0 {
+		t.Errorf("disabled reporter has %d sinks, want 0", len(r.sinks))
+	}
 	r.Start("file", 100)
 	r.Complete("file")
+}
+
+type recordSink struct{ types []string }
+
+func (s *recordSink) Handle(e Event) { s.types = append(s.types, e.Type) }
+
+func TestReporterSinks(t *testing.T) {
+	var buf bytes.Buffer
+	rec := &recordSink{}
+	r := NewReporterWriter(&buf)
+	r.Attach(rec)
+
+	r.Start("roms/a.rom", 10)
+	r.Complete("roms/a.rom")
 
-	if buf.Len() != 0 {
-		t.Errorf("disabled reporter should produce no output, got %q", buf.String())
+	if got := strings.Count(buf.String(), "\n"); got != 2 {
+		t.Errorf("JSON sink got %d lines, want 2", got)
+	}
+	if strings.Join(rec.types, ",") != EventStart+","+EventComplete {
+		t.Errorf("attached sink got %v", rec.types)
+	}
+}
+
+type eventSink struct{ events []Event }
+
+func (s *eventSink) Handle(e Event) { s.events = append(s.events, e) }
+
+func TestReporterEmitsETA(t *testing.T) {
+	sink := &eventSink{}
+	r := New(sink)
+	now := time.Unix(0, 0)
+	r.eta.now = func() time.Time { return now }
+
+	r.Plan(4, 400<<20)
+	for i, elapsed := range []time.Duration{2 * time.Second, 5 * time.Second, 10 * time.Second} {
+		file := fmt.Sprintf("roms/%d.rom", i)
+		r.Start(file, 100<<20)
+		now = now.Add(elapsed)
+		r.Complete(file)
+	}
+
+	var etas []Event
+	for _, e := range sink.events {
+		if e.Type == EventETA {
+			etas = append(etas, e)
+		}
+	}
+	// The first file finishes too soon to measure; then 200 MiB in 7s,
+	// and 100 MiB in 10s folded into the average.
+	if len(etas) != 2 {
+		t.Fatalf("got %d eta events, want 2: %+v", len(etas), etas)
+	}
+	first := float64(200<<20) / 7
+	if etas[0].Rate != first || etas[0].RemainingFiles != 2 || etas[0].RemainingBytes != 200<<20 {
+		t.Errorf("first eta = %+v, want %.0f B/s with 2 files, 200 MiB left", etas[0], first)
+	}
+	rate := 0.3*float64(100<<20)/10 + 0.7*first
+	if etas[1].Rate != rate || etas[1].Seconds != (time.Duration(float64(100<<20)/rate*float64(time.Second))).Round(time.Second).Seconds() {
+		t.Errorf("second eta = %+v, want %.0f B/s", etas[1], rate)
+	}
+	if est := r.Estimate(); est.Files != 1 || est.Bytes != 100<<20 || est.Left == 0 {
+		t.Errorf("Estimate = %+v", est)
+	}
+
+	r.Done(Summary{})
+	if est := r.Estimate(); est != (Estimate{}) {
+		t.Errorf("Estimate after done = %+v, want zero", est)
+	}
+}
+
+func TestFormatLeft(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		20 * time.Second:                "less than a minute remaining",
+		70 * time.Second:                "about 1 minute remaining",
+		14*time.Minute + 10*time.Second: "about 14 minutes remaining",
+		59*time.Minute + 50*time.Second: "about 1 hour remaining",
+		2*time.Hour + 40*time.Minute:    "about 3 hours remaining",
+	} {
+		if got := FormatLeft(d); got != want {
+			t.Errorf("FormatLeft(%v) = %q, want %q", d, got, want)
+		}
 	}
 }
@@ -0,0 +1,221 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/units"
+)
+
+// Bar draws a one-line progress bar on a terminal, redrawn in place as
+// files finish, with the time left once there's an eta event:
+//
+//	[######--------------]  12/140 files, 3.2 GiB of 10 GiB, about 14 minutes remaining
+type Bar struct {
+	w         io.Writer
+	total     int
+	totalSize int64
+	done      int
+	doneSize  int64
+	sizes     map[string]int64 // started files -> size
+	left      string           // from the last eta event, e.g. "about 14 minutes remaining"
+	drawn     int              // length of the line on screen
+}
+
+// barWidth is the number of cells between the brackets.
+const barWidth = 20
+
+// NewBar returns a bar that draws on w, normally stderr.
+func NewBar(w io.Writer) *Bar {
+	return &Bar{w: w, sizes: make(map[string]int64)}
+}
+
+// Handle updates the bar for e and clears it when the run is done, so
+// the summary that follows starts on a clean line and the next run
+// starts from zero.
+func (b *Bar) Handle(e Event) {
+	switch e.Type {
+	case EventPlan:
+		b.total = e.Total
+		b.totalSize = e.Size
+	case EventStart:
+		b.sizes[e.File] = e.Size
+		return
+	case EventComplete, EventError:
+		b.done++
+		b.doneSize += b.sizes[e.File]
+		delete(b.sizes, e.File)
+	case EventETA:
+		b.left = ""
+		if e.Rate > 0 {
+			b.left = FormatLeft(time.Duration(e.Seconds * float64(time.Second)))
+		}
+	case EventDone:
+		b.clear()
+		b.total, b.totalSize, b.done, b.doneSize = 0, 0, 0, 0
+		b.left = ""
+		clear(b.sizes)
+		return
+	default:
+		return
+	}
+	b.draw()
+}
+
+func (b *Bar) draw() {
+	if b.total == 0 {
+		return
+	}
+	frac := float64(b.done) / float64(b.total)
+	if b.totalSize > 0 {
+		frac = float64(b.doneSize) / float64(b.totalSize)
+	}
+	filled := min(int(frac*barWidth), barWidth)
+	line := fmt.Sprintf("[%s%s]  %d/%d files, %s of %s",
+		strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled),
+		b.done, b.total, units.FormatSize(b.doneSize), units.FormatSize(b.totalSize))
+	if b.left != "" {
+		line += ", " + b.left
+	}
+	pad := max(b.drawn-len(line), 0)
+	fmt.Fprintf(b.w, "\r%s%s", line, strings.Repeat(" ", pad))
+	b.drawn = len(line)
+}
+
+func (b *Bar) clear() {
+	if b.drawn == 0 {
+		return
+	}
+	fmt.Fprintf(b.w, "\r%s\r", strings.Repeat(" ", b.drawn))
+	b.drawn = 0
+}
+
+// Log writes each event as a timestamped line of plain text, for a
+// progress log that reads more easily than JSON lines.
+type Log struct {
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewLog returns a sink that writes text lines to w.
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w, now: time.Now}
+}
+
+// Handle writes one line describing e.
+func (l *Log) Handle(e Event) {
+	var msg string
+	switch e.Type {
+	case EventPlan:
+		msg = fmt.Sprintf("planned %d files (%s)", e.Total, units.FormatSize(e.Size))
+	case EventStart:
+		msg = fmt.Sprintf("started %s (%s)", e.File, units.FormatSize(e.Size))
+	case EventComplete:
+		msg = "completed " + e.File
+	case EventError:
+		msg = fmt.Sprintf("failed %s: %s", e.File, e.Error)
+	case EventDelete:
+		msg = "deleted " + e.File
+	case EventSkip:
+		msg = "skipped " + e.File
+	case EventRetain:
+		msg = "kept " + e.File
+	case EventWouldDownload:
+		msg = fmt.Sprintf("would download %s (%s)", e.File, units.FormatSize(e.Size))
+	case EventWouldDelete:
+		msg = "would delete " + e.File
+	case EventWarning:
+		msg = "warning: " + e.Message
+	case EventETA:
+		if e.Rate == 0 {
+			return
+		}
+		msg = fmt.Sprintf("%s (%d files, %s at %s/s)", FormatLeft(time.Duration(e.Seconds*float64(time.Second))),
+			e.RemainingFiles, units.FormatSize(e.RemainingBytes), units.FormatSize(int64(e.Rate)))
+	case EventDone:
+		verb, n := "downloaded", e.Downloaded
+		if e.Uploaded > 0 {
+			verb, n = "uploaded", e.Uploaded
+		}
+		msg = fmt.Sprintf("finished: %d %s, %d deleted, %d kept, %d errors, %d skipped",
+			n, verb, e.Deleted, e.Retained, e.Errors, e.Skipped)
+		if e.Bytes > 0 {
+			msg += fmt.Sprintf(" (%s in %.1fs)", units.FormatSize(e.Bytes), e.Seconds)
+		}
+	default:
+		return
+	}
+	fmt.Fprintf(l.w, "%s %s\n", l.now().Format(time.RFC3339), msg)
+}
+
+// WebhookEvents lists the events a Webhook posts. The per-file events
+// are left out: a large sync emits thousands of them.
+var WebhookEvents = []string{EventWarning, EventDone}
+
+// Webhook posts events to a URL as JSON, one request per event, so an
+// unattended sync can notify a chat or home-automation service when it
+// finishes. Delivery failures are logged, never returned, so a
+// misbehaving endpoint can't fail a sync.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns a sink that posts to url through rt, or the default
+// transport if rt is nil.
+func NewWebhook(url string, rt http.RoundTripper) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Transport: rt, Timeout: 10 * time.Second}}
+}
+
+// Handle posts e if it is one of WebhookEvents.
+func (h *Webhook) Handle(e Event) {
+	if !slices.Contains(WebhookEvents, e.Type) {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Printf("warning: posting %s event to webhook: %v", e.Type, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("warning: webhook returned %s for %s event", resp.Status, e.Type)
+	}
+}
+
+// Annotations writes errors and warnings as GitHub Actions workflow
+// commands, so a CI upload's failures show up on the run's summary page
+// rather than only in its log.
+type Annotations struct {
+	w io.Writer
+}
+
+// NewAnnotations returns a sink that writes workflow commands to w.
+func NewAnnotations(w io.Writer) *Annotations {
+	return &Annotations{w: w}
+}
+
+// annotationEscaper escapes workflow command messages as the Actions
+// runner expects.
+var annotationEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// Handle writes an ::error:: or ::warning:: line for e, if it is one.
+func (a *Annotations) Handle(e Event) {
+	switch e.Type {
+	case EventError:
+		fmt.Fprintf(a.w, "::error::%s\n", annotationEscaper.Replace(e.File+": "+e.Error))
+	case EventWarning:
+		fmt.Fprintf(a.w, "::warning::%s\n", annotationEscaper.Replace(e.Message))
+	}
+}
@@ -0,0 +1,104 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBar(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(NewBar(&buf))
+
+	r.Plan(2, 4096)
+	r.Start("roms/a.rom", 1024)
+	r.Complete("roms/a.rom")
+
+	out := buf.String()
+	if !strings.Contains(out, "\r[#####---------------]  1/2 files, 1.0 KiB of 4.0 KiB") {
+		t.Errorf("bar after one file: %q", out)
+	}
+
+	buf.Reset()
+	r.Emit(Event{Type: EventETA, Seconds: 840, RemainingFiles: 1, RemainingBytes: 3072, Rate: 4})
+	if !strings.Contains(buf.String(), "1.0 KiB of 4.0 KiB, about 14 minutes remaining") {
+		t.Errorf("bar after eta: %q", buf.String())
+	}
+
+	buf.Reset()
+	r.Done(Summary{Downloaded: 1})
+	if got := strings.TrimSpace(buf.String()); got != "" {
+		t.Errorf("done should only clear the bar, got %q", got)
+	}
+	if !strings.HasPrefix(buf.String(), "\r") || !strings.HasSuffix(buf.String(), "\r") {
+		t.Errorf("done didn't return to the start of the line: %q", buf.String())
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLog(&buf)
+	l.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	r := New(l)
+
+	r.FileError("roms/bad.rom", errors.New("connection reset"))
+	r.Done(Summary{Downloaded: 3, Deleted: 1, Errors: 1})
+
+	want := "2026-01-02T03:04:05Z failed roms/bad.rom: connection reset\n" +
+		"2026-01-02T03:04:05Z finished: 3 downloaded, 1 deleted, 0 kept, 1 errors, 0 skipped\n"
+	if buf.String() != want {
+		t.Errorf("log =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body, _ := io.ReadAll(req.Body)
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("body isn't an event: %q", body)
+		}
+		got = append(got, e)
+	}))
+	defer srv.Close()
+
+	r := New(NewWebhook(srv.URL, nil))
+	r.Start("roms/a.rom", 10)
+	r.Complete("roms/a.rom")
+	r.Warning("disk nearly full")
+	r.Done(Summary{Downloaded: 1})
+
+	if len(got) != 2 {
+		t.Fatalf("got %d posts, want 2 (warning and done): %+v", len(got), got)
+	}
+	if got[0].Type != EventWarning || got[0].Message != "disk nearly full" {
+		t.Errorf("first post = %+v", got[0])
+	}
+	if got[1].Type != EventDone || got[1].Downloaded != 1 {
+		t.Errorf("second post = %+v", got[1])
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(NewAnnotations(&buf))
+	r.Start("roms/a.rom", 10)
+	r.FileError("roms/a.rom", errors.New("access denied\nretry later"))
+	r.Warning("50% of files skipped")
+	r.Done(Summary{Errors: 1})
+
+	want := "::error::roms/a.rom: access denied%0Aretry later\n::warning::50%25 of files skipped\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"math/rand"
 	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
 )
 
 // WithBackoff retries fn up to maxRetries times with exponential backoff
@@ -26,6 +28,7 @@ func WithBackoff(ctx context.Context, maxRetries int, fn func() error) error {
 		jitter := time.Duration(rand.Int63n(int64(time.Second)))
 		delay := backoff + jitter
 
+		logging.Printf(logging.Debug, "retrying in %s (attempt %d of %d): %v", delay.Round(time.Millisecond), attempt+2, maxRetries+1, err)
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
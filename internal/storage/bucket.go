@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// BucketAdmin is implemented by backends that can manage the bucket
+// itself rather than just its objects, for bootstrap-bucket.
+type BucketAdmin interface {
+	EnsureBucket(ctx context.Context) (created bool, err error)
+	SetLifecycle(ctx context.Context, rules []LifecycleRule) error
+	SetCORS(ctx context.Context, rules []CORSRule) error
+}
+
+// LifecycleRule is a bucket lifecycle rule emu-sync manages. Rules are
+// matched by ID, so applying them again replaces rather than repeats
+// them, and rules added by hand are kept.
+type LifecycleRule struct {
+	ID              string
+	Prefix          string // keys the rule covers, relative to the configured prefix
+	ExpireDays      int32  // delete objects this many days after upload; 0 = never
+	AbortUploadDays int32  // abort unfinished multipart uploads this many days after they start; 0 = never
+}
+
+// CORSRule lets pages served from Origins, such as the web UI, read
+// objects straight from the bucket (e.g. through a presigned link), so a
+// browser can download large files without going through emu-sync.
+// Rules are matched by ID, as LifecycleRule's are.
+type CORSRule struct {
+	ID      string
+	Origins []string // e.g. "http://127.0.0.1:8080"; each may contain one "*"
+	MaxAge  int32    // seconds a browser may cache the preflight response
+}
+
+// EnsureBucket checks that the bucket exists, creating it in the
+// client's region if it doesn't. created reports whether it did.
+func (c *Client) EnsureBucket(ctx context.Context) (created bool, err error) {
+	_, err = c.s3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(c.bucket)})
+	if err == nil {
+		return false, nil
+	}
+	var nf *types.NotFound
+	if !errors.As(err, &nf) {
+		return false, fmt.Errorf("checking bucket %s: %w", c.bucket, err)
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(c.bucket)}
+	// us-east-1 is the default and must not be given as a constraint.
+	if region := c.s3.Options().Region; region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+	if _, err := c.s3.CreateBucket(ctx, input); err != nil {
+		return false, fmt.Errorf("creating bucket %s: %w", c.bucket, err)
+	}
+	return true, nil
+}
+
+// SetLifecycle adds rules to the bucket's lifecycle configuration,
+// replacing any existing rules with the same IDs.
+func (c *Client) SetLifecycle(ctx context.Context, rules []LifecycleRule) error {
+	var current []types.LifecycleRule
+	out, err := c.s3.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(c.bucket),
+	})
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		current = out.Rules
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration":
+	default:
+		return fmt.Errorf("reading lifecycle rules: %w", err)
+	}
+
+	current = slices.DeleteFunc(current, func(r types.LifecycleRule) bool {
+		return slices.ContainsFunc(rules, func(ours LifecycleRule) bool { return ours.ID == aws.ToString(r.ID) })
+	})
+	for _, r := range rules {
+		rule := types.LifecycleRule{
+			ID:     aws.String(r.ID),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{Prefix: aws.String(c.prefixedKey(r.Prefix))},
+		}
+		if r.ExpireDays > 0 {
+			rule.Expiration = &types.LifecycleExpiration{Days: aws.Int32(r.ExpireDays)}
+		}
+		if r.AbortUploadDays > 0 {
+			rule.AbortIncompleteMultipartUpload = &types.AbortIncompleteMultipartUpload{
+				DaysAfterInitiation: aws.Int32(r.AbortUploadDays),
+			}
+		}
+		current = append(current, rule)
+	}
+
+	_, err = c.s3.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(c.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: current},
+	})
+	if err != nil {
+		return fmt.Errorf("setting lifecycle rules: %w", err)
+	}
+	return nil
+}
+
+// SetCORS adds rules to the bucket's CORS configuration, replacing any
+// existing rules with the same IDs. The rules allow GET and HEAD,
+// including ranged reads, and nothing that changes the bucket.
+func (c *Client) SetCORS(ctx context.Context, rules []CORSRule) error {
+	var current []types.CORSRule
+	out, err := c.s3.GetBucketCors(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(c.bucket)})
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		current = out.CORSRules
+	case errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchCORSConfiguration":
+	default:
+		return fmt.Errorf("reading CORS rules: %w", err)
+	}
+
+	current = slices.DeleteFunc(current, func(r types.CORSRule) bool {
+		return slices.ContainsFunc(rules, func(ours CORSRule) bool { return ours.ID == aws.ToString(r.ID) })
+	})
+	for _, r := range rules {
+		rule := types.CORSRule{
+			ID:             aws.String(r.ID),
+			AllowedOrigins: r.Origins,
+			AllowedMethods: []string{http.MethodGet, http.MethodHead},
+			AllowedHeaders: []string{"Range"},
+			ExposeHeaders:  []string{"Content-Length", "Content-Range", "Content-Disposition", "ETag"},
+		}
+		if r.MaxAge > 0 {
+			rule.MaxAgeSeconds = aws.Int32(r.MaxAge)
+		}
+		current = append(current, rule)
+	}
+
+	_, err = c.s3.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket:            aws.String(c.bucket),
+		CORSConfiguration: &types.CORSConfiguration{CORSRules: current},
+	})
+	if err != nil {
+		return fmt.Errorf("setting CORS rules: %w", err)
+	}
+	return nil
+}
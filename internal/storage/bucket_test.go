@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+)
+
+func TestEnsureBucket(t *testing.T) {
+	exists := false
+	var created string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			created = string(body)
+			exists = true
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.StorageConfig{EndpointURL: srv.URL, Bucket: "b", KeyID: "key", SecretKey: "secret", Region: "eu-west-1"}, config.NetworkConfig{})
+	ok, err := c.EnsureBucket(context.Background())
+	if err != nil || !ok {
+		t.Fatalf("EnsureBucket = %v, %v; want created", ok, err)
+	}
+	if !strings.Contains(created, "<LocationConstraint>eu-west-1</LocationConstraint>") {
+		t.Errorf("create request = %s, want the region as location constraint", created)
+	}
+	if ok, err := c.EnsureBucket(context.Background()); err != nil || ok {
+		t.Errorf("EnsureBucket on an existing bucket = %v, %v", ok, err)
+	}
+}
+
+func TestSetLifecycle(t *testing.T) {
+	var put string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["lifecycle"]; !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`<LifecycleConfiguration>
+<Rule><ID>by-hand</ID><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>30</Days></Expiration></Rule>
+<Rule><ID>emu-sync-abort-uploads</ID><Status>Enabled</Status><Filter><Prefix>games/</Prefix></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>
+</LifecycleConfiguration>`))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			put = string(body)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.StorageConfig{EndpointURL: srv.URL, Bucket: "b", Prefix: "games", KeyID: "key", SecretKey: "secret", Region: "us-east-1"}, config.NetworkConfig{})
+	err := c.SetLifecycle(context.Background(), []LifecycleRule{
+		{ID: "emu-sync-abort-uploads", AbortUploadDays: 7},
+		{ID: "emu-sync-expire-invites", Prefix: "invites/", ExpireDays: 8},
+	})
+	if err != nil {
+		t.Fatalf("SetLifecycle: %v", err)
+	}
+	for _, want := range []string{
+		"<ID>by-hand</ID>",
+		"<DaysAfterInitiation>7</DaysAfterInitiation>",
+		"<Prefix>games/invites/</Prefix>",
+		"<Days>8</Days>",
+	} {
+		if !strings.Contains(put, want) {
+			t.Errorf("lifecycle missing %s:\n%s", want, put)
+		}
+	}
+	if strings.Count(put, "emu-sync-abort-uploads") != 1 {
+		t.Errorf("rule applied twice:\n%s", put)
+	}
+}
+
+func TestSetCORS(t *testing.T) {
+	var put string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchCORSConfiguration</Code><Message>none</Message></Error>`))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			put = string(body)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.StorageConfig{EndpointURL: srv.URL, Bucket: "b", KeyID: "key", SecretKey: "secret", Region: "us-east-1"}, config.NetworkConfig{})
+	err := c.SetCORS(context.Background(), []CORSRule{{ID: "emu-sync-web", Origins: []string{"http://127.0.0.1:*"}, MaxAge: 3600}})
+	if err != nil {
+		t.Fatalf("SetCORS: %v", err)
+	}
+	for _, want := range []string{
+		"<AllowedOrigin>http://127.0.0.1:*</AllowedOrigin>",
+		"<AllowedMethod>GET</AllowedMethod>",
+		"<AllowedHeader>Range</AllowedHeader>",
+		"<MaxAgeSeconds>3600</MaxAgeSeconds>",
+	} {
+		if !strings.Contains(put, want) {
+			t.Errorf("CORS missing %s:\n%s", want, put)
+		}
+	}
+	if strings.Contains(put, "PUT") || strings.Contains(put, "DELETE") {
+		t.Errorf("CORS allows writes:\n%s", put)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// contentTypes maps file extensions to the Content-Type set on upload.
+// It's a fixed table rather than mime.TypeByExtension so every uploader
+// labels the same file the same way regardless of the host's mime files.
+var contentTypes = map[string]string{
+	// Media (box art, manuals, previews)
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+	".pdf":  "application/pdf",
+
+	// Text and metadata
+	".txt":  "text/plain; charset=utf-8",
+	".cfg":  "text/plain; charset=utf-8",
+	".ini":  "text/plain; charset=utf-8",
+	".cue":  "text/plain; charset=utf-8",
+	".m3u":  "audio/x-mpegurl",
+	".json": "application/json",
+	".xml":  "application/xml",
+
+	// Archives and disc images
+	".zip": "application/zip",
+	".7z":  "application/x-7z-compressed",
+	".gz":  "application/gzip",
+	".rar": "application/vnd.rar",
+	".iso": "application/x-iso9660-image",
+}
+
+// ContentType returns the Content-Type for a key, based on its extension.
+// Unknown extensions (most ROM formats) are application/octet-stream.
+func ContentType(key string) string {
+	if ct, ok := contentTypes[strings.ToLower(path.Ext(key))]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// ContentDisposition returns the Content-Disposition for a key: inline for
+// types a browser can display or play, attachment otherwise, so direct
+// links save ROMs under their real name instead of the object key.
+func ContentDisposition(key string) string {
+	disposition := "attachment"
+	ct := ContentType(key)
+	if strings.HasPrefix(ct, "image/") || strings.HasPrefix(ct, "video/") ||
+		strings.HasPrefix(ct, "audio/") || strings.HasPrefix(ct, "text/") || ct == "application/pdf" {
+		disposition = "inline"
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(key)})
+}
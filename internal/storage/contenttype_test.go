@@ -0,0 +1,38 @@
+package storage
+
+import "testing"
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"media/snes/covers/Game.PNG", "image/png"},
+		{"media/snes/videos/Game.mp4", "video/mp4"},
+		{"roms/snes/readme.txt", "text/plain; charset=utf-8"},
+		{"roms/psx/Game.zip", "application/zip"},
+		{"roms/snes/Game.sfc", "application/octet-stream"},
+		{"bios/scph1001", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := ContentType(tt.key); got != tt.want {
+			t.Errorf("ContentType(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"media/snes/covers/Game.png", `inline; filename=Game.png`},
+		{"roms/snes/Super Game (USA).sfc", `attachment; filename="Super Game (USA).sfc"`},
+		{"roms/snes/Pokémon.sfc", `attachment; filename*=utf-8''Pok%C3%A9mon.sfc`},
+	}
+	for _, tt := range tests {
+		if got := ContentDisposition(tt.key); got != tt.want {
+			t.Errorf("ContentDisposition(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
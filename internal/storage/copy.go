@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jacobfgrant/emu-sync/internal/config"
+)
+
+// maxCopySize is the largest object S3 copies in one request. Larger
+// objects are copied part by part.
+const maxCopySize = 5 << 30
+
+// copyPartSize is the part size for multipart copies.
+const copyPartSize = 512 << 20
+
+// CopyObject copies src to dst inside the bucket without downloading it.
+// Like UploadFile, the copy gets the Content-Type, Content-Disposition,
+// and storage class for its new key. The source's metadata is carried over,
+// since replacing the headers would otherwise drop it.
+func (c *Client) CopyObject(ctx context.Context, src, dst string) error {
+	info, err := c.HeadObject(ctx, src)
+	if err != nil {
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+	if info.Size > maxCopySize {
+		return c.copyMultipart(ctx, src, dst, info)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(c.bucket),
+		Key:                aws.String(c.prefixedKey(dst)),
+		CopySource:         aws.String(c.copySource(src)),
+		MetadataDirective:  types.MetadataDirectiveReplace,
+		ContentType:        aws.String(ContentType(dst)),
+		ContentDisposition: aws.String(ContentDisposition(dst)),
+		Metadata:           info.Metadata,
+	}
+	if class := config.StorageClassFor(dst, c.tuning); class != "" {
+		input.StorageClass = types.StorageClass(class)
+	}
+	if _, err := c.s3.CopyObject(ctx, input); err != nil {
+		return fmt.Errorf("copying %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+// copyMultipart copies an object too large for CopyObject in
+// copyPartSize ranges, aborting the upload if any part fails.
+func (c *Client) copyMultipart(ctx context.Context, src, dst string, info *ObjectInfo) error {
+	size := info.Size
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(c.bucket),
+		Key:                aws.String(c.prefixedKey(dst)),
+		ContentType:        aws.String(ContentType(dst)),
+		ContentDisposition: aws.String(ContentDisposition(dst)),
+		Metadata:           info.Metadata,
+	}
+	if class := config.StorageClassFor(dst, c.tuning); class != "" {
+		create.StorageClass = types.StorageClass(class)
+	}
+	upload, err := c.s3.CreateMultipartUpload(ctx, create)
+	if err != nil {
+		return fmt.Errorf("copying %s to %s: %w", src, dst, err)
+	}
+
+	var parts []types.CompletedPart
+	for off, n := int64(0), int32(1); off < size; off, n = off+copyPartSize, n+1 {
+		end := min(off+copyPartSize, size) - 1
+		out, err := c.s3.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(c.bucket),
+			Key:             aws.String(c.prefixedKey(dst)),
+			CopySource:      aws.String(c.copySource(src)),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", off, end)),
+			PartNumber:      aws.Int32(n),
+			UploadId:        upload.UploadId,
+		})
+		if err != nil {
+			c.s3.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(c.bucket),
+				Key:      aws.String(c.prefixedKey(dst)),
+				UploadId: upload.UploadId,
+			})
+			return fmt.Errorf("copying %s to %s: %w", src, dst, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int32(n)})
+	}
+
+	_, err = c.s3.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.bucket),
+		Key:             aws.String(c.prefixedKey(dst)),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("copying %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+// copySource returns the URL-encoded bucket/key form CopySource expects.
+func (c *Client) copySource(key string) string {
+	segments := strings.Split(c.bucket+"/"+c.prefixedKey(key), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
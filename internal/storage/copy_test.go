@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+)
+
+func TestCopyObject(t *testing.T) {
+	var copySource, disposition, meta string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Length", "4")
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("X-Amz-Meta-Emu-Sync-Md5", "0123456789abcdef0123456789abcdef")
+		case http.MethodPut:
+			if r.URL.Path != "/b/games/roms/gba/New Name.gba" {
+				t.Errorf("PUT path = %q", r.URL.Path)
+			}
+			copySource = r.Header.Get("X-Amz-Copy-Source")
+			disposition = r.Header.Get("Content-Disposition")
+			meta = r.Header.Get("X-Amz-Meta-Emu-Sync-Md5")
+			w.Write([]byte(`<CopyObjectResult><ETag>"abc"</ETag></CopyObjectResult>`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.StorageConfig{
+		EndpointURL: srv.URL,
+		Bucket:      "b",
+		Prefix:      "games",
+		KeyID:       "key",
+		SecretKey:   "secret",
+		Region:      "us-east-1",
+	}, config.NetworkConfig{})
+
+	if err := c.CopyObject(context.Background(), "roms/gba/Old (USA).gba", "roms/gba/New Name.gba"); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if want := "b/games/roms/gba/Old%20%28USA%29.gba"; copySource != want {
+		t.Errorf("copy source = %q, want %q", copySource, want)
+	}
+	if disposition == "" {
+		t.Error("copy should get a Content-Disposition for its new name")
+	}
+	if meta != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("copy metadata MD5 = %q, want the source's", meta)
+	}
+}
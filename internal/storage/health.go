@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Health is the result of a connection check.
+type Health struct {
+	FirstByte  time.Duration // round trip of a minimal request (an empty bucket listing)
+	Transfer   time.Duration // time to download the sample object
+	Bytes      int64         // size of the sample object
+	Throughput float64       // bytes/sec, excluding one round trip of request setup
+}
+
+// CheckHealth measures request latency with Ping, then downloads key (or
+// the manifest if key is empty) to estimate throughput. Latency reflects
+// distance to the bucket's region; throughput reflects the link.
+func CheckHealth(ctx context.Context, b Backend, key string) (*Health, error) {
+	if key == "" {
+		key = ManifestKey
+	}
+
+	start := time.Now()
+	if err := b.Ping(ctx); err != nil {
+		return nil, err
+	}
+	h := &Health{FirstByte: time.Since(start)}
+
+	start = time.Now()
+	data, err := b.DownloadBytes(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("downloading sample: %w", err)
+	}
+	h.Transfer = time.Since(start)
+	h.Bytes = int64(len(data))
+
+	body := h.Transfer - h.FirstByte
+	if body < time.Millisecond {
+		body = time.Millisecond
+	}
+	h.Throughput = float64(h.Bytes) / body.Seconds()
+	return h, nil
+}
+
+// Hint suggests what limits transfers, or returns "" if nothing stands out.
+// Thresholds are rough: a nearby region answers in well under 200 ms.
+func (h *Health) Hint() string {
+	const mb = 1024 * 1024
+	switch {
+	case h.FirstByte > 500*time.Millisecond:
+		return "High latency: the bucket's region may be far away, or a proxy is slowing requests. More --workers helps hide latency."
+	case h.Bytes >= mb && h.Throughput < mb:
+		return "Low throughput: the network link is the bottleneck, not request latency."
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Objects["roms/snes/Game.sfc"] = make([]byte, 4096)
+
+	h, err := CheckHealth(context.Background(), mock, "roms/snes/Game.sfc")
+	if err != nil {
+		t.Fatalf("CheckHealth: %v", err)
+	}
+	if h.Bytes != 4096 {
+		t.Errorf("bytes = %d, want 4096", h.Bytes)
+	}
+	if h.Throughput <= 0 {
+		t.Errorf("throughput = %f, want > 0", h.Throughput)
+	}
+}
+
+func TestCheckHealthDefaultsToManifest(t *testing.T) {
+	mock := NewMockBackend()
+	if _, err := CheckHealth(context.Background(), mock, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound for missing manifest", err)
+	}
+
+	mock.Objects[ManifestKey] = []byte(`{"version":1,"files":{}}`)
+	h, err := CheckHealth(context.Background(), mock, "")
+	if err != nil {
+		t.Fatalf("CheckHealth: %v", err)
+	}
+	if h.Bytes != int64(len(mock.Objects[ManifestKey])) {
+		t.Errorf("bytes = %d, want manifest size", h.Bytes)
+	}
+}
+
+func TestHealthHint(t *testing.T) {
+	const mb = 1024 * 1024
+	far := &Health{FirstByte: 800 * time.Millisecond, Bytes: 4 * mb, Throughput: 10 * mb}
+	if !strings.Contains(far.Hint(), "latency") {
+		t.Errorf("far hint = %q, want latency", far.Hint())
+	}
+	slow := &Health{FirstByte: 50 * time.Millisecond, Bytes: 4 * mb, Throughput: mb / 4}
+	if !strings.Contains(slow.Hint(), "throughput") {
+		t.Errorf("slow hint = %q, want throughput", slow.Hint())
+	}
+	fine := &Health{FirstByte: 50 * time.Millisecond, Bytes: 4 * mb, Throughput: 20 * mb}
+	if fine.Hint() != "" {
+		t.Errorf("fine hint = %q, want none", fine.Hint())
+	}
+}
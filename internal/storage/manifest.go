@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// downloadManifest fetches the compressed manifest, falling back to the
+// uncompressed one for buckets written before compression was added.
+func downloadManifest(ctx context.Context, b Backend) ([]byte, error) {
+	gz, err := b.HeadObject(ctx, ManifestGzipKey)
+	if errors.Is(err, ErrNotFound) {
+		return b.DownloadBytes(ctx, ManifestKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// An uploader that predates compression only rewrites the plain
+	// manifest, leaving a stale compressed copy behind. uploadManifest
+	// writes the compressed copy last, so a newer plain manifest means
+	// the compressed one is out of date.
+	plain, err := b.HeadObject(ctx, ManifestKey)
+	if err == nil && plain.LastModified.After(gz.LastModified) {
+		return b.DownloadBytes(ctx, ManifestKey)
+	}
+
+	data, err := b.DownloadBytes(ctx, ManifestGzipKey)
+	if err != nil {
+		return nil, err
+	}
+	return gunzip(data)
+}
+
+// ManifestVersion returns a string that changes whenever the remote
+// manifest does, using HEAD requests so callers can poll cheaply and
+// download the manifest only when it changed.
+func ManifestVersion(ctx context.Context, b Backend) (string, error) {
+	var parts []string
+	for _, key := range []string{ManifestKey, ManifestGzipKey} {
+		info, err := b.HeadObject(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			parts = append(parts, "-")
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, info.ETag)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// Sequence returns the library's publish sequence number from
+// SequenceKey, or 0 if nothing has been published since it was added.
+// It is one GET of a few bytes, cheap enough to poll every minute.
+func Sequence(ctx context.Context, b Backend) (int64, error) {
+	data, err := b.DownloadBytes(ctx, SequenceKey)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", SequenceKey, errors.Join(errBadSequence, err))
+	}
+	return n, nil
+}
+
+// errBadSequence marks a SequenceKey that exists but isn't a number.
+var errBadSequence = errors.New("not a sequence number")
+
+// uploadManifest writes the manifest uncompressed (for older clients) and
+// then compressed, then increments the publish sequence so followers
+// notice. The sequence is read first, so a failure to read it fails the
+// publish before anything is written.
+func uploadManifest(ctx context.Context, b Backend, data []byte) error {
+	// A sequence that can't be parsed (e.g., edited by hand) restarts
+	// from the clock rather than blocking the publish; that's far above
+	// any count reached by incrementing, so followers never see a number
+	// repeat.
+	n, err := Sequence(ctx, b)
+	if errors.Is(err, errBadSequence) {
+		n = time.Now().UnixMilli()
+	} else if err != nil {
+		return fmt.Errorf("reading %s: %w", SequenceKey, err)
+	}
+
+	if err := b.UploadBytes(ctx, ManifestKey, data); err != nil {
+		return err
+	}
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return err
+	}
+	if err := b.UploadBytes(ctx, ManifestGzipKey, compressed); err != nil {
+		return err
+	}
+	if err := b.UploadBytes(ctx, SequenceKey, []byte(strconv.FormatInt(n+1, 10))); err != nil {
+		return fmt.Errorf("updating %s: %w", SequenceKey, err)
+	}
+	return nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("compressing manifest: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing manifest: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing manifest: %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing manifest: %w", err)
+	}
+	return out, nil
+}
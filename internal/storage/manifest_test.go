@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManifestRoundTripCompressed(t *testing.T) {
+	mock := NewMockBackend()
+	data := []byte(`{"version":1,"files":{}}`)
+
+	if err := mock.UploadManifest(context.Background(), data); err != nil {
+		t.Fatalf("UploadManifest: %v", err)
+	}
+	if string(mock.Objects[ManifestKey]) != string(data) {
+		t.Error("plain manifest should be written for older clients")
+	}
+	if _, ok := mock.Objects[ManifestGzipKey]; !ok {
+		t.Fatal("compressed manifest not written")
+	}
+
+	mock.Calls = nil
+	got, err := mock.DownloadManifest(context.Background())
+	if err != nil {
+		t.Fatalf("DownloadManifest: %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("DownloadManifest = %q, want %q", got, data)
+	}
+	if mock.Calls[len(mock.Calls)-1] != "DownloadBytes:"+ManifestGzipKey {
+		t.Errorf("calls = %v, want compressed manifest downloaded", mock.Calls)
+	}
+}
+
+func TestManifestFallsBackToPlain(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Objects[ManifestKey] = []byte("plain")
+
+	got, err := mock.DownloadManifest(context.Background())
+	if err != nil {
+		t.Fatalf("DownloadManifest: %v", err)
+	}
+	if string(got) != "plain" {
+		t.Errorf("DownloadManifest = %q, want plain manifest", got)
+	}
+}
+
+func TestManifestIgnoresStaleCompressedCopy(t *testing.T) {
+	mock := NewMockBackend()
+	if err := mock.UploadManifest(context.Background(), []byte("old")); err != nil {
+		t.Fatalf("UploadManifest: %v", err)
+	}
+
+	// An older uploader rewrites only the plain manifest
+	mock.Objects[ManifestKey] = []byte("new")
+	mock.ModTimes[ManifestKey] = mock.ModTimes[ManifestGzipKey].Add(time.Minute)
+
+	got, err := mock.DownloadManifest(context.Background())
+	if err != nil {
+		t.Fatalf("DownloadManifest: %v", err)
+	}
+	if string(got) != "new" {
+		t.Errorf("DownloadManifest = %q, want newer plain manifest", got)
+	}
+}
+
+func TestManifestVersion(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockBackend()
+
+	empty, err := ManifestVersion(ctx, mock)
+	if err != nil {
+		t.Fatalf("ManifestVersion with no manifest: %v", err)
+	}
+
+	mock.UploadManifest(ctx, []byte(`{"version":1,"files":{}}`))
+	v1, _ := ManifestVersion(ctx, mock)
+	if v1 == empty {
+		t.Error("version should change when the manifest is written")
+	}
+	if v, _ := ManifestVersion(ctx, mock); v != v1 {
+		t.Errorf("version changed without a write: %q -> %q", v1, v)
+	}
+
+	mock.UploadManifest(ctx, []byte(`{"version":1,"files":{"a":{}}}`))
+	mock.Calls = nil
+	if v2, _ := ManifestVersion(ctx, mock); v2 == v1 {
+		t.Error("version should change when the manifest changes")
+	}
+	for _, call := range mock.Calls {
+		if strings.HasPrefix(call, "Download") {
+			t.Errorf("ManifestVersion should only HEAD, got %s", call)
+		}
+	}
+}
+
+func TestSequence(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockBackend()
+
+	if n, err := Sequence(ctx, mock); n != 0 || err != nil {
+		t.Fatalf("Sequence before any publish = %d, %v; want 0, nil", n, err)
+	}
+	mock.UploadManifest(ctx, []byte(`{"version":1,"files":{}}`))
+	mock.UploadManifest(ctx, []byte(`{"version":1,"files":{}}`))
+	if n, err := Sequence(ctx, mock); n != 2 || err != nil {
+		t.Errorf("Sequence after two publishes = %d, %v; want 2", n, err)
+	}
+
+	mock.Objects[SequenceKey] = []byte("garbage")
+	if _, err := Sequence(ctx, mock); err == nil {
+		t.Error("Sequence should fail on a corrupt value")
+	}
+	mock.UploadManifest(ctx, []byte(`{"version":1,"files":{}}`))
+	n, _ := Sequence(ctx, mock)
+	if n <= 2 {
+		t.Errorf("Sequence after publishing over a corrupt value = %d, want more than the 2 followers saw", n)
+	}
+
+	mock.DownloadErrors[SequenceKey] = errors.New("connection reset")
+	if err := mock.UploadManifest(ctx, []byte(`{"version":1,"files":{"a":{}}}`)); err == nil {
+		t.Error("UploadManifest should fail when the sequence can't be read")
+	}
+	delete(mock.DownloadErrors, SequenceKey)
+	if string(mock.Objects[ManifestKey]) != `{"version":1,"files":{}}` {
+		t.Errorf("manifest = %s, want the failed publish to write nothing", mock.Objects[ManifestKey])
+	}
+	if after, _ := Sequence(ctx, mock); after != n {
+		t.Errorf("Sequence after a failed read = %d, want it left at %d", after, n)
+	}
+}
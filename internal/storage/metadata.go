@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Object metadata keys set by UploadFile. S3 sends them as
+// x-amz-meta-emu-sync-md5 and x-amz-meta-emu-sync-mtime.
+const (
+	MetaMD5   = "emu-sync-md5"
+	MetaMtime = "emu-sync-mtime"
+)
+
+// md5ETag matches an ETag that is the object's MD5. Multipart uploads get
+// "<hash>-<parts>" instead, which isn't a hash of the content.
+var md5ETag = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// objectMetadata returns the metadata UploadFile stores with an object.
+// md5 may be empty if the caller didn't hash the file.
+func objectMetadata(md5 string, mtime time.Time) map[string]string {
+	meta := map[string]string{MetaMtime: mtime.UTC().Format(time.RFC3339)}
+	if md5 != "" {
+		meta[MetaMD5] = md5
+	}
+	return meta
+}
+
+// ContentMD5 returns the object's MD5 without downloading it: from the
+// metadata UploadFile stored, or from the ETag when that is a plain MD5.
+// It returns "" if neither is available, e.g. for a multipart upload made
+// by another tool. Metadata only comes back from HeadObject, so objects
+// from ListObjects rely on the ETag.
+func (o *ObjectInfo) ContentMD5() string {
+	if md5 := o.Metadata[MetaMD5]; md5ETag.MatchString(md5) {
+		return md5
+	}
+	if etag := strings.Trim(o.ETag, `"`); md5ETag.MatchString(etag) {
+		return etag
+	}
+	return ""
+}
@@ -2,37 +2,97 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+// ErrSimulated is returned by MockBackend calls that fail due to FailRate.
+var ErrSimulated = errors.New("simulated failure")
+
 // MockBackend is an in-memory Backend for testing.
 type MockBackend struct {
-	mu      sync.Mutex
-	Objects map[string][]byte // key -> content
-	Calls   []string          // log of method calls for assertions
+	mu       sync.Mutex
+	Objects  map[string][]byte            // key -> content
+	ModTimes map[string]time.Time         // key -> last upload time; zero for objects set directly
+	Calls    []string                     // log of method calls for assertions
+	ETags    map[string]string            // key -> ETag to report instead of the content MD5 (e.g. multipart-style)
+	Metadata map[string]map[string]string // key -> object metadata stored by UploadFile
 	// Set to simulate errors on specific keys
 	UploadErrors   map[string]error
 	DownloadErrors map[string]error
 	DeleteErrors   map[string]error
+
+	// Set to simulate a real connection. Delays are applied outside the
+	// lock, so parallel callers overlap as they would against a bucket.
+	Latency   time.Duration // added to every call
+	Bandwidth int64         // bytes/sec per transfer; 0 = unlimited
+	FailRate  float64       // fraction of calls (0-1) that fail with ErrSimulated
 }
 
 // NewMockBackend creates a MockBackend with initialized maps.
 func NewMockBackend() *MockBackend {
 	return &MockBackend{
 		Objects:        make(map[string][]byte),
+		ModTimes:       make(map[string]time.Time),
+		ETags:          make(map[string]string),
+		Metadata:       make(map[string]map[string]string),
 		UploadErrors:   make(map[string]error),
 		DownloadErrors: make(map[string]error),
 		DeleteErrors:   make(map[string]error),
 	}
 }
 
-func (m *MockBackend) Ping(_ context.Context) error {
+func (m *MockBackend) Ping(ctx context.Context) error {
+	return m.simulate(ctx, "Ping", "", 0)
+}
+
+// simulate applies Latency, Bandwidth (for a transfer of size bytes), and
+// FailRate to one call.
+func (m *MockBackend) simulate(ctx context.Context, op, key string, size int64) error {
+	delay := m.Latency
+	if m.Bandwidth > 0 && size > 0 {
+		delay += time.Duration(float64(size) / float64(m.Bandwidth) * float64(time.Second))
+	}
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if m.FailRate > 0 && rand.Float64() < m.FailRate {
+		return fmt.Errorf("%s %s: %w", op, key, ErrSimulated)
+	}
 	return nil
 }
 
-func (m *MockBackend) UploadFile(_ context.Context, key, localPath string) error {
+// objectSize returns the stored size of key, or 0 if it doesn't exist.
+func (m *MockBackend) objectSize(key string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.Objects[key]))
+}
+
+func (m *MockBackend) UploadFile(ctx context.Context, key, localPath, md5 string) error {
+	var size int64
+	var mtime time.Time
+	if info, err := os.Stat(localPath); err == nil {
+		size, mtime = info.Size(), info.ModTime()
+	}
+	if err := m.simulate(ctx, "UploadFile", key, size); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Calls = append(m.Calls, "UploadFile:"+key)
@@ -46,10 +106,16 @@ func (m *MockBackend) UploadFile(_ context.Context, key, localPath string) error
 		return err
 	}
 	m.Objects[key] = data
+	m.ModTimes[key] = time.Now()
+	m.Metadata[key] = objectMetadata(md5, mtime)
 	return nil
 }
 
-func (m *MockBackend) UploadBytes(_ context.Context, key string, data []byte) error {
+func (m *MockBackend) UploadBytes(ctx context.Context, key string, data []byte) error {
+	if err := m.simulate(ctx, "UploadBytes", key, int64(len(data))); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Calls = append(m.Calls, "UploadBytes:"+key)
@@ -59,10 +125,15 @@ func (m *MockBackend) UploadBytes(_ context.Context, key string, data []byte) er
 	}
 
 	m.Objects[key] = data
+	m.ModTimes[key] = time.Now()
 	return nil
 }
 
-func (m *MockBackend) DownloadFile(_ context.Context, key, localPath string) error {
+func (m *MockBackend) DownloadFile(ctx context.Context, key, localPath string) error {
+	if err := m.simulate(ctx, "DownloadFile", key, m.objectSize(key)); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Calls = append(m.Calls, "DownloadFile:"+key)
@@ -79,7 +150,58 @@ func (m *MockBackend) DownloadFile(_ context.Context, key, localPath string) err
 	return os.WriteFile(localPath, data, 0o644)
 }
 
-func (m *MockBackend) DownloadBytes(_ context.Context, key string) ([]byte, error) {
+func (m *MockBackend) DownloadTo(ctx context.Context, key string, w io.Writer) error {
+	if err := m.simulate(ctx, "DownloadTo", key, m.objectSize(key)); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.Calls = append(m.Calls, "DownloadTo:"+key)
+	err, failed := m.DownloadErrors[key]
+	data, ok := m.Objects[key]
+	m.mu.Unlock()
+
+	if failed {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("downloading %s: %w", key, ErrNotFound)
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+func (m *MockBackend) DownloadRange(ctx context.Context, key string, offset, length int64, w io.Writer) error {
+	if err := m.simulate(ctx, "DownloadRange", key, length); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.Calls = append(m.Calls, "DownloadRange:"+key)
+	err, failed := m.DownloadErrors[key]
+	data, ok := m.Objects[key]
+	m.mu.Unlock()
+
+	if failed {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("object not found: %s", key)
+	}
+	if offset < 0 || offset+length > int64(len(data)) {
+		return fmt.Errorf("range %d+%d out of bounds for %s", offset, length, key)
+	}
+
+	_, err = w.Write(data[offset : offset+length])
+	return err
+}
+
+func (m *MockBackend) DownloadBytes(ctx context.Context, key string) ([]byte, error) {
+	if err := m.simulate(ctx, "DownloadBytes", key, m.objectSize(key)); err != nil {
+		return nil, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Calls = append(m.Calls, "DownloadBytes:"+key)
@@ -90,13 +212,39 @@ func (m *MockBackend) DownloadBytes(_ context.Context, key string) ([]byte, erro
 
 	data, ok := m.Objects[key]
 	if !ok {
-		return nil, fmt.Errorf("object not found: %s", key)
+		return nil, fmt.Errorf("downloading %s: %w", key, ErrNotFound)
 	}
 
 	return data, nil
 }
 
-func (m *MockBackend) DeleteObject(_ context.Context, key string) error {
+func (m *MockBackend) CopyObject(ctx context.Context, src, dst string) error {
+	if err := m.simulate(ctx, "CopyObject", src, 0); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, "CopyObject:"+src+"->"+dst)
+
+	if err, ok := m.UploadErrors[dst]; ok {
+		return err
+	}
+	data, ok := m.Objects[src]
+	if !ok {
+		return fmt.Errorf("copying %s: %w", src, ErrNotFound)
+	}
+	m.Objects[dst] = data
+	m.ModTimes[dst] = time.Now()
+	m.Metadata[dst] = m.Metadata[src]
+	return nil
+}
+
+func (m *MockBackend) DeleteObject(ctx context.Context, key string) error {
+	if err := m.simulate(ctx, "DeleteObject", key, 0); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Calls = append(m.Calls, "DeleteObject:"+key)
@@ -106,13 +254,84 @@ func (m *MockBackend) DeleteObject(_ context.Context, key string) error {
 	}
 
 	delete(m.Objects, key)
+	delete(m.ModTimes, key)
+	delete(m.Metadata, key)
 	return nil
 }
 
+func (m *MockBackend) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := m.simulate(ctx, "HeadObject", key, 0); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, "HeadObject:"+key)
+
+	data, ok := m.Objects[key]
+	if !ok {
+		return nil, fmt.Errorf("head %s: %w", key, ErrNotFound)
+	}
+	etag, ok := m.ETags[key]
+	if !ok {
+		etag = fmt.Sprintf("%x", md5.Sum(data))
+	}
+
+	return &ObjectInfo{
+		Size:         int64(len(data)),
+		ETag:         etag,
+		LastModified: m.ModTimes[key],
+		Metadata:     m.Metadata[key],
+	}, nil
+}
+
+func (m *MockBackend) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, "PresignGet:"+key)
+	return fmt.Sprintf("https://mock.invalid/%s?expires=%d", key, int(ttl.Seconds())), nil
+}
+
+func (m *MockBackend) PresignPut(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, "PresignPut:"+key)
+	return fmt.Sprintf("https://mock.invalid/%s?expires=%d&method=PUT", key, int(ttl.Seconds())), nil
+}
+
+func (m *MockBackend) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := m.simulate(ctx, "ListObjects", prefix, 0); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, "ListObjects:"+prefix)
+
+	var objects []ObjectInfo
+	for key, data := range m.Objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		etag, ok := m.ETags[key]
+		if !ok {
+			etag = fmt.Sprintf("%x", md5.Sum(data))
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         int64(len(data)),
+			ETag:         `"` + etag + `"`,
+			LastModified: m.ModTimes[key],
+		})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
 func (m *MockBackend) DownloadManifest(ctx context.Context) ([]byte, error) {
-	return m.DownloadBytes(ctx, ManifestKey)
+	return downloadManifest(ctx, m)
 }
 
 func (m *MockBackend) UploadManifest(ctx context.Context, data []byte) error {
-	return m.UploadBytes(ctx, ManifestKey, data)
+	return uploadManifest(ctx, m, data)
 }
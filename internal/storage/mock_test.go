@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMockLatency(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Objects["a"] = []byte("data")
+	mock.Latency = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, err := mock.DownloadBytes(context.Background(), "a"); err != nil {
+		t.Fatalf("DownloadBytes: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < mock.Latency {
+		t.Errorf("elapsed = %v, want >= %v", elapsed, mock.Latency)
+	}
+}
+
+func TestMockLatencyOverlapsParallelCalls(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Latency = 100 * time.Millisecond
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mock.Ping(context.Background())
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed >= 4*mock.Latency {
+		t.Errorf("elapsed = %v, want parallel calls to overlap", elapsed)
+	}
+}
+
+func TestMockBandwidth(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Bandwidth = 10000 // 1000 bytes take 100ms
+
+	start := time.Now()
+	if err := mock.UploadBytes(context.Background(), "a", make([]byte, 1000)); err != nil {
+		t.Fatalf("UploadBytes: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("elapsed = %v, want >= 100ms", elapsed)
+	}
+}
+
+func TestMockLatencyRespectsCancel(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Latency = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mock.UploadBytes(ctx, "a", []byte("x")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if _, ok := mock.Objects["a"]; ok {
+		t.Error("cancelled upload should not store the object")
+	}
+}
+
+func TestMockFailRate(t *testing.T) {
+	mock := NewMockBackend()
+	mock.Objects["a"] = []byte("data")
+
+	mock.FailRate = 1
+	if _, err := mock.DownloadBytes(context.Background(), "a"); !errors.Is(err, ErrSimulated) {
+		t.Errorf("err = %v, want ErrSimulated", err)
+	}
+
+	mock.FailRate = 0.5
+	failed := 0
+	for range 200 {
+		if err := mock.Ping(context.Background()); err != nil {
+			failed++
+		}
+	}
+	if failed == 0 || failed == 200 {
+		t.Errorf("failed %d of 200 calls, want some but not all", failed)
+	}
+}
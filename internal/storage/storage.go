@@ -6,50 +6,117 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithylogging "github.com/aws/smithy-go/logging"
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
 	"github.com/jacobfgrant/emu-sync/internal/ratelimit"
 )
 
 const ManifestKey = "emu-sync-manifest.json"
 
+// ManifestGzipKey holds a gzip-compressed copy of the manifest. Clients
+// that predate it keep reading ManifestKey, which is still written too.
+const ManifestGzipKey = ManifestKey + ".gz"
+
+// SequenceKey holds a number incremented every time a manifest is
+// published, for clients that follow the library (sync --follow).
+const SequenceKey = "emu-sync-sequence"
+
+// ErrNotFound is returned (wrapped) by HeadObject and DownloadBytes when
+// the key does not exist in the bucket.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo holds metadata about a remote object.
+type ObjectInfo struct {
+	Key          string // set by ListObjects
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	StorageClass string            // set by ListObjects; "" = bucket default
+	Metadata     map[string]string // set by HeadObject; user metadata, lowercase keys
+}
+
 // Backend defines the operations that upload and sync workflows need.
 // storage.Client implements this; tests can substitute a mock.
 type Backend interface {
 	Ping(ctx context.Context) error
-	UploadFile(ctx context.Context, key, localPath string) error
+	UploadFile(ctx context.Context, key, localPath, md5 string) error
 	UploadBytes(ctx context.Context, key string, data []byte) error
 	DownloadFile(ctx context.Context, key, localPath string) error
+	DownloadTo(ctx context.Context, key string, w io.Writer) error
 	DownloadBytes(ctx context.Context, key string) ([]byte, error)
 	DeleteObject(ctx context.Context, key string) error
+	CopyObject(ctx context.Context, src, dst string) error
+	HeadObject(ctx context.Context, key string) (*ObjectInfo, error)
+	ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+	PresignPut(ctx context.Context, key string, ttl time.Duration) (string, error)
 	DownloadManifest(ctx context.Context) ([]byte, error)
 	UploadManifest(ctx context.Context, data []byte) error
 }
 
+// RangeDownloader is implemented by backends that can read part of an
+// object. Sync uses it to skip the zero regions of padded images.
+type RangeDownloader interface {
+	DownloadRange(ctx context.Context, key string, offset, length int64, w io.Writer) error
+}
+
 // Client wraps an S3 client for bucket operations.
 type Client struct {
 	s3      *s3.Client
 	bucket  string
 	prefix  string
-	limiter *ratelimit.Limiter // nil = unlimited
+	limiter *ratelimit.Limiter             // nil = unlimited
+	tuning  map[string]config.TuningConfig // per-directory storage classes for uploads
 }
 
-// NewClient creates a storage client from config.
-func NewClient(cfg *config.StorageConfig) *Client {
+// NewClient creates a storage client from config. Without a key ID and
+// secret, credentials (and the region, if unset) come from the AWS default
+// chain: environment, shared config, SSO, or an instance role. Proxy and
+// TLS settings come from network.
+func NewClient(cfg *config.StorageConfig, network config.NetworkConfig) *Client {
 	opts := s3.Options{
 		Region:       cfg.Region,
-		Credentials:  credentials.NewStaticCredentialsProvider(cfg.KeyID, cfg.SecretKey, ""),
 		UsePathStyle: true,
 	}
+	if network != (config.NetworkConfig{}) {
+		// Validated when the config was loaded, so an error here means the
+		// CA bundle changed since; fail requests rather than ignore it.
+		transport, err := network.Transport(true)
+		if err != nil {
+			opts.HTTPClient = failingHTTPClient{err}
+		} else {
+			opts.HTTPClient = &http.Client{Transport: transport}
+		}
+	}
+	if cfg.KeyID != "" || cfg.SecretKey != "" {
+		opts.Credentials = credentials.NewStaticCredentialsProvider(cfg.KeyID, cfg.SecretKey, "")
+	} else {
+		opts.Credentials, opts.Region = defaultCredentials(cfg.Region)
+	}
 	if cfg.EndpointURL != "" {
 		opts.BaseEndpoint = aws.String(cfg.EndpointURL)
 	}
+	if logging.Enabled(logging.Debug) {
+		// Requests are logged without bodies; signatures and key IDs
+		// appear in headers, but secret keys never do.
+		opts.ClientLogMode = aws.LogRetries | aws.LogRequest | aws.LogResponse
+		opts.Logger = smithylogging.LoggerFunc(func(_ smithylogging.Classification, format string, v ...any) {
+			log.Printf("s3: "+format, v...)
+		})
+	}
 
 	return &Client{
 		s3:     s3.New(opts),
@@ -58,11 +125,41 @@ func NewClient(cfg *config.StorageConfig) *Client {
 	}
 }
 
+// failingHTTPClient fails every request with err.
+type failingHTTPClient struct{ err error }
+
+func (c failingHTTPClient) Do(*http.Request) (*http.Response, error) {
+	return nil, c.err
+}
+
+// defaultCredentials loads the AWS default credential chain and region.
+// NewClient can't return an error, so a broken shared config is reported
+// by the first request instead.
+func defaultCredentials(region string) (aws.CredentialsProvider, string) {
+	var optFns []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), optFns...)
+	if err != nil {
+		return aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, fmt.Errorf("loading default AWS credentials: %w", err)
+		}), region
+	}
+	return awsCfg.Credentials, awsCfg.Region
+}
+
 // SetLimiter configures a shared bandwidth limiter for all transfers.
 func (c *Client) SetLimiter(l *ratelimit.Limiter) {
 	c.limiter = l
 }
 
+// SetStorageClasses configures per-directory storage classes (from
+// [sync.tuning]) applied to uploaded files.
+func (c *Client) SetStorageClasses(tuning map[string]config.TuningConfig) {
+	c.tuning = tuning
+}
+
 // wrapReader applies rate limiting to r if a limiter is configured.
 func (c *Client) wrapReader(r io.Reader) io.Reader {
 	if c.limiter != nil {
@@ -94,24 +191,39 @@ func (c *Client) Ping(ctx context.Context) error {
 	return nil
 }
 
-// UploadFile uploads a local file to the given key in the bucket.
+// UploadFile uploads a local file to the given key in the bucket, with
+// Content-Type and Content-Disposition set from its extension and the
+// storage class configured for its directory. The file's MD5 and mtime
+// are stored as object metadata (see MetaMD5).
 // Uses the S3 multipart upload manager for files over 5 MB.
-func (c *Client) UploadFile(ctx context.Context, key, localPath string) error {
+func (c *Client) UploadFile(ctx context.Context, key, localPath, md5 string) error {
 	f, err := os.Open(localPath)
 	if err != nil {
 		return fmt.Errorf("opening %s: %w", localPath, err)
 	}
 	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", localPath, err)
+	}
 
 	var body io.Reader = f
 	body = c.wrapReader(body)
 
+	input := &s3.PutObjectInput{
+		Bucket:             aws.String(c.bucket),
+		Key:                aws.String(c.prefixedKey(key)),
+		Body:               body,
+		ContentType:        aws.String(ContentType(key)),
+		ContentDisposition: aws.String(ContentDisposition(key)),
+		Metadata:           objectMetadata(md5, info.ModTime()),
+	}
+	if class := config.StorageClassFor(key, c.tuning); class != "" {
+		input.StorageClass = types.StorageClass(class)
+	}
+
 	uploader := manager.NewUploader(c.s3)
-	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(c.bucket),
-		Key:    aws.String(c.prefixedKey(key)),
-		Body:   body,
-	})
+	_, err = uploader.Upload(ctx, input)
 	if err != nil {
 		return fmt.Errorf("uploading %s: %w", key, err)
 	}
@@ -122,9 +234,10 @@ func (c *Client) UploadFile(ctx context.Context, key, localPath string) error {
 // UploadBytes uploads raw bytes to the given key.
 func (c *Client) UploadBytes(ctx context.Context, key string, data []byte) error {
 	_, err := c.s3.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(c.bucket),
-		Key:    aws.String(c.prefixedKey(key)),
-		Body:   bytes.NewReader(data),
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(c.prefixedKey(key)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(ContentType(key)),
 	})
 	if err != nil {
 		return fmt.Errorf("uploading %s: %w", key, err)
@@ -165,6 +278,57 @@ func (c *Client) DownloadFile(ctx context.Context, key, localPath string) error
 	return nil
 }
 
+// DownloadTo streams an object to w. Returns an error wrapping ErrNotFound
+// if the key does not exist.
+func (c *Client) DownloadTo(ctx context.Context, key string, w io.Writer) error {
+	result, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.prefixedKey(key)),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return fmt.Errorf("downloading %s: %w", key, ErrNotFound)
+		}
+		return fmt.Errorf("downloading %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	if _, err := io.Copy(w, c.wrapReader(result.Body)); err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// DownloadRange copies length bytes of an object, starting at offset, to w.
+// It fails rather than writing a short or oversized result, which guards
+// against endpoints that ignore the Range header.
+func (c *Client) DownloadRange(ctx context.Context, key string, offset, length int64, w io.Writer) error {
+	result, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.prefixedKey(key)),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	if result.ContentLength != nil && *result.ContentLength != length {
+		return fmt.Errorf("downloading %s: range returned %d bytes, want %d", key, *result.ContentLength, length)
+	}
+	n, err := io.Copy(w, io.LimitReader(c.wrapReader(result.Body), length))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", key, err)
+	}
+	if n != length {
+		return fmt.Errorf("reading %s: got %d bytes, want %d", key, n, length)
+	}
+
+	return nil
+}
+
 // DownloadBytes downloads an object and returns its contents as bytes.
 func (c *Client) DownloadBytes(ctx context.Context, key string) ([]byte, error) {
 	result, err := c.s3.GetObject(ctx, &s3.GetObjectInput{
@@ -172,6 +336,10 @@ func (c *Client) DownloadBytes(ctx context.Context, key string) ([]byte, error)
 		Key:    aws.String(c.prefixedKey(key)),
 	})
 	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, fmt.Errorf("downloading %s: %w", key, ErrNotFound)
+		}
 		return nil, fmt.Errorf("downloading %s: %w", key, err)
 	}
 	defer result.Body.Close()
@@ -197,12 +365,101 @@ func (c *Client) DeleteObject(ctx context.Context, key string) error {
 	return nil
 }
 
-// DownloadManifest downloads the remote manifest from the bucket.
+// HeadObject returns an object's metadata without downloading it.
+// Returns an error wrapping ErrNotFound if the key does not exist.
+func (c *Client) HeadObject(ctx context.Context, key string) (*ObjectInfo, error) {
+	result, err := c.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.prefixedKey(key)),
+	})
+	if err != nil {
+		var nf *types.NotFound
+		if errors.As(err, &nf) {
+			return nil, fmt.Errorf("head %s: %w", key, ErrNotFound)
+		}
+		return nil, fmt.Errorf("head %s: %w", key, err)
+	}
+
+	return &ObjectInfo{
+		Size:         aws.ToInt64(result.ContentLength),
+		ETag:         strings.Trim(aws.ToString(result.ETag), `"`),
+		LastModified: aws.ToTime(result.LastModified),
+		Metadata:     result.Metadata,
+	}, nil
+}
+
+// ListObjects returns every object whose key starts with prefix, with
+// keys relative to the configured bucket prefix.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	base := c.prefixedKey("")
+	paginator := s3.NewListObjectsV2Paginator(c.s3, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(base + prefix),
+	})
+	var objects []ObjectInfo
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing bucket: %w", err)
+		}
+		for _, obj := range page.Contents {
+			info := ObjectInfo{
+				Key:  strings.TrimPrefix(aws.ToString(obj.Key), base),
+				Size: aws.ToInt64(obj.Size),
+				ETag: aws.ToString(obj.ETag),
+			}
+			if obj.LastModified != nil {
+				info.LastModified = *obj.LastModified
+			}
+			if class := string(obj.StorageClass); class != "" && class != string(types.ObjectStorageClassStandard) {
+				info.StorageClass = class
+			}
+			objects = append(objects, info)
+		}
+	}
+	return objects, nil
+}
+
+// MaxPresignTTL is the longest a presigned URL can stay valid.
+const MaxPresignTTL = 7 * 24 * time.Hour
+
+// PresignGet returns a URL anyone can use to download key until ttl
+// passes, without credentials. S3 caps ttl at MaxPresignTTL.
+func (c *Client) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(c.s3).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.prefixedKey(key)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presigning %s: %w", key, err)
+	}
+
+	return req.URL, nil
+}
+
+// PresignPut returns a URL anyone can use to upload to key, with an HTTP
+// PUT of the file body, until ttl passes. Only that one key can be
+// written, and S3 caps ttl at MaxPresignTTL.
+func (c *Client) PresignPut(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(c.s3).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.prefixedKey(key)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presigning %s: %w", key, err)
+	}
+
+	return req.URL, nil
+}
+
+// DownloadManifest downloads the remote manifest from the bucket,
+// preferring the compressed copy.
 func (c *Client) DownloadManifest(ctx context.Context) ([]byte, error) {
-	return c.DownloadBytes(ctx, ManifestKey)
+	return downloadManifest(ctx, c)
 }
 
-// UploadManifest uploads a manifest to the bucket.
+// UploadManifest uploads a manifest to the bucket, both compressed and
+// uncompressed.
 func (c *Client) UploadManifest(ctx context.Context, data []byte) error {
-	return c.UploadBytes(ctx, ManifestKey, data)
+	return uploadManifest(ctx, c, data)
 }
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+)
+
+func TestNewClientStaticCredentials(t *testing.T) {
+	c := NewClient(&config.StorageConfig{Bucket: "b", KeyID: "key", SecretKey: "secret", Region: "us-west-002"}, config.NetworkConfig{})
+
+	creds, err := c.s3.Options().Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if creds.AccessKeyID != "key" || creds.SecretAccessKey != "secret" {
+		t.Errorf("credentials = %q/%q, want static key", creds.AccessKeyID, creds.SecretAccessKey)
+	}
+}
+
+func TestNewClientDefaultCredentialChain(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_REGION", "us-east-2")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/config")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/credentials")
+
+	c := NewClient(&config.StorageConfig{Bucket: "b"}, config.NetworkConfig{})
+
+	creds, err := c.s3.Options().Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if creds.AccessKeyID != "env-key" {
+		t.Errorf("access key = %q, want env-key from environment", creds.AccessKeyID)
+	}
+	if region := c.s3.Options().Region; region != "us-east-2" {
+		t.Errorf("region = %q, want us-east-2 from environment", region)
+	}
+
+	// A configured region wins over the environment
+	c = NewClient(&config.StorageConfig{Bucket: "b", Region: "eu-west-1"}, config.NetworkConfig{})
+	if region := c.s3.Options().Region; region != "eu-west-1" {
+		t.Errorf("region = %q, want configured eu-west-1", region)
+	}
+}
+
+func TestPresignGet(t *testing.T) {
+	c := NewClient(&config.StorageConfig{
+		EndpointURL: "https://s3.example.com",
+		Bucket:      "b",
+		Prefix:      "games/",
+		KeyID:       "key",
+		SecretKey:   "secret",
+		Region:      "us-west-002",
+	}, config.NetworkConfig{})
+
+	u, err := c.PresignGet(context.Background(), "invites/abc", time.Hour)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	if !strings.HasPrefix(u, "https://s3.example.com/b/games/invites/abc?") {
+		t.Errorf("URL = %s, want path-style URL for the prefixed key", u)
+	}
+	if !strings.Contains(u, "X-Amz-Expires=3600") {
+		t.Errorf("URL = %s, want X-Amz-Expires=3600", u)
+	}
+}
+
+func TestDownloadRange(t *testing.T) {
+	content := "0123456789"
+	ignoreRange := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/b/roms/game.iso" {
+			http.NotFound(w, r)
+			return
+		}
+		if ignoreRange {
+			w.Write([]byte(content))
+			return
+		}
+		if got := r.Header.Get("Range"); got != "bytes=2-5" {
+			t.Errorf("Range = %q, want bytes=2-5", got)
+		}
+		w.Header().Set("Content-Range", "bytes 2-5/10")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[2:6]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.StorageConfig{
+		EndpointURL: srv.URL,
+		Bucket:      "b",
+		KeyID:       "key",
+		SecretKey:   "secret",
+		Region:      "us-east-1",
+	}, config.NetworkConfig{})
+
+	var buf bytes.Buffer
+	if err := c.DownloadRange(context.Background(), "roms/game.iso", 2, 4, &buf); err != nil {
+		t.Fatalf("DownloadRange: %v", err)
+	}
+	if buf.String() != "2345" {
+		t.Errorf("got %q, want %q", buf.String(), "2345")
+	}
+
+	// An endpoint that ignores Range must not be mistaken for a partial read
+	ignoreRange = true
+	buf.Reset()
+	if err := c.DownloadRange(context.Background(), "roms/game.iso", 2, 4, &buf); err == nil {
+		t.Error("expected an error when the whole object is returned")
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("prefix"); got != "games/roms/" {
+			t.Errorf("prefix = %q, want games/roms/", got)
+		}
+		w.Write([]byte(`<ListBucketResult>
+<Contents><Key>games/roms/a.gba</Key><Size>4</Size><ETag>"abc"</ETag><StorageClass>STANDARD</StorageClass></Contents>
+<Contents><Key>games/roms/b.iso</Key><Size>9</Size><ETag>"def-2"</ETag><StorageClass>GLACIER_IR</StorageClass></Contents>
+<IsTruncated>false</IsTruncated>
+</ListBucketResult>`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(&config.StorageConfig{
+		EndpointURL: srv.URL,
+		Bucket:      "b",
+		Prefix:      "games",
+		KeyID:       "key",
+		SecretKey:   "secret",
+		Region:      "us-east-1",
+	}, config.NetworkConfig{})
+
+	objects, err := c.ListObjects(context.Background(), "roms/")
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("got %d objects, want 2", len(objects))
+	}
+	if objects[0].Key != "roms/a.gba" || objects[0].Size != 4 || objects[0].StorageClass != "" {
+		t.Errorf("objects[0] = %+v, want unprefixed key and default storage class", objects[0])
+	}
+	if objects[1].StorageClass != "GLACIER_IR" || objects[1].ETag != `"def-2"` {
+		t.Errorf("objects[1] = %+v", objects[1])
+	}
+}
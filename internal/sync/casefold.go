@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+// caseInsensitive reports whether dir is on a filesystem that ignores
+// letter case in names, by creating a file and looking it up in upper
+// case. A dir that doesn't exist yet is checked through its nearest
+// existing parent. It reports false if the check can't be made.
+func caseInsensitive(dir string) bool {
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	f, err := os.CreateTemp(dir, ".emu-sync-case-*")
+	if err != nil {
+		return false
+	}
+	name := f.Name()
+	f.Close()
+	defer os.Remove(name)
+
+	upper := filepath.Join(filepath.Dir(name), strings.ToUpper(filepath.Base(name)))
+	_, err = os.Stat(upper)
+	return err == nil
+}
+
+// caseCollisionError explains why keys that differ only in case can't be
+// synced to this device. policy is what the manifest says its uploader
+// did about them.
+func caseCollisionError(groups [][]string, policy string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "the library has files whose names differ only in case, which would overwrite each other on this device's filesystem:\n")
+	for _, group := range groups {
+		fmt.Fprintf(&b, "  %s\n", strings.Join(group, ", "))
+	}
+	if policy == "" {
+		b.WriteString("\nThe manifest was written by an older emu-sync or another tool. Upload again with a current emu-sync (sync.case_collisions = \"rename\" to rename them), or exclude them with sync_exclude.")
+	} else {
+		b.WriteString("\nRename them in the library, upload with sync.case_collisions = \"rename\", or exclude them with sync_exclude.")
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+// checkCaseCollisions fails if remote has keys that differ only in case
+// and emuPath can't hold them apart. On case-sensitive filesystems they
+// sync normally.
+func checkCaseCollisions(remote *manifest.Manifest, policy, emuPath string) error {
+	keys := make([]string, 0, len(remote.Files))
+	for key := range remote.Files {
+		keys = append(keys, key)
+	}
+	groups := manifest.CaseCollisions(keys)
+	if len(groups) == 0 || !caseInsensitive(emuPath) {
+		return nil
+	}
+	return caseCollisionError(groups, policy)
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+// skipModified checks files about to be replaced with a new version from
+// the library. A file whose content matches neither what was synced (the
+// local manifest) nor the new version was changed on this device, e.g. a
+// patched ROM, so it is left alone and recorded in result.Conflicts. A
+// file that already matches the new version is marked synced without
+// downloading. It returns the keys that still need downloading.
+func skipModified(emuPath string, remote, local *manifest.Manifest, modified []string, result *Result) []string {
+	var remaining []string
+	for _, key := range modified {
+		path := filepath.Join(emuPath, filepath.FromSlash(key))
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			remaining = append(remaining, key)
+			continue
+		}
+
+		synced, target := local.Files[key], remote.Files[key]
+		if info.Size() != synced.Size && info.Size() != target.Size {
+			logging.Printf(logging.Files, "changed on this device, keeping: %s", key)
+			result.Conflicts = append(result.Conflicts, key)
+			continue
+		}
+		hash, err := manifest.HashFile(path)
+		switch {
+		case err != nil:
+			logging.Printf(logging.Debug, "can't hash %s, replacing it: %v", key, err)
+			remaining = append(remaining, key)
+		case hash == synced.MD5 && info.Size() == synced.Size:
+			remaining = append(remaining, key)
+		case hash == target.MD5 && info.Size() == target.Size:
+			logging.Printf(logging.Files, "already up to date: %s", key)
+			local.Files[key] = target
+		default:
+			logging.Printf(logging.Files, "changed on this device, keeping: %s", key)
+			result.Conflicts = append(result.Conflicts, key)
+		}
+	}
+	return remaining
+}
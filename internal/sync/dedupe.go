@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+// contentKey identifies a file's content for dedupe.
+func contentKey(e manifest.FileEntry) string {
+	return fmt.Sprintf("%s:%d", e.MD5, e.Size)
+}
+
+// linkDuplicates creates each key in keys that has the same content as
+// a file already synced under another path by cloning or hardlinking
+// that file instead of downloading it. It returns the keys that still
+// need downloading, and separately those that duplicate another key in
+// the same batch: they can be linked once that key has downloaded.
+func linkDuplicates(emuPath string, filteredRemote, local *manifest.Manifest, keys []string, result *Result) (download, later []string) {
+	sources := make(map[string]string, len(local.Files))
+	for key, entry := range local.Files {
+		if entry.MD5 != "" {
+			sources[contentKey(entry)] = key
+		}
+	}
+
+	pending := make(map[string]bool)
+	for _, key := range keys {
+		entry := filteredRemote.Files[key]
+		ck := contentKey(entry)
+		if entry.MD5 == "" || entry.Size == 0 {
+			download = append(download, key)
+			continue
+		}
+		if src, ok := sources[ck]; ok && src != key {
+			err := linkFile(filepath.Join(emuPath, filepath.FromSlash(src)), filepath.Join(emuPath, filepath.FromSlash(key)), entry.Size)
+			if err == nil {
+				logging.Printf(logging.Files, "linked: %s (same as %s)", key, src)
+				local.Files[key] = entry
+				result.Linked = append(result.Linked, key)
+				continue
+			}
+			logging.Printf(logging.Debug, "can't link %s to %s, downloading: %v", key, src, err)
+		}
+		if pending[ck] {
+			later = append(later, key)
+			continue
+		}
+		pending[ck] = true
+		download = append(download, key)
+	}
+	return download, later
+}
+
+// linkFile makes dst a copy of src without transferring data: a
+// copy-on-write clone where the filesystem supports it (btrfs, XFS),
+// otherwise a hardlink. Downloads always replace files by rename, so a
+// later update to either path never changes the other. Fails if src
+// isn't the expected size, or if neither works (e.g. on exFAT SD cards).
+func linkFile(src, dst string, size int64) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() != size {
+		return fmt.Errorf("%s changed since it was synced", src)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	tmp := dst + tmpSuffix
+	os.Remove(tmp)
+	if err := reflink(src, tmp); err != nil {
+		os.Remove(tmp)
+		if err := os.Link(src, tmp); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
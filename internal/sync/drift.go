@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	gosync "sync"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/output"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+// DriftResult summarizes a deep check of manifest entries against the
+// objects actually present in the bucket.
+type DriftResult struct {
+	Checked      int
+	Missing      []string // in the manifest but not in the bucket
+	SizeMismatch []string // bucket object size differs from the manifest
+	HashMismatch []string // bucket object's known MD5 differs from the manifest
+	Errors       []error
+}
+
+// CheckDrift HEADs manifest entries in the bucket and reports entries
+// whose object is missing or has a different size. When the object's MD5
+// is known without downloading it (see storage.ObjectInfo.ContentMD5), it
+// is compared with the manifest too. If sample > 0, only
+// that many randomly chosen entries are checked; otherwise all are.
+func CheckDrift(ctx context.Context, client storage.Backend, m *manifest.Manifest, sample, workers int) *DriftResult {
+	keys := make([]string, 0, len(m.Files))
+	for key := range m.Files {
+		keys = append(keys, key)
+	}
+	if sample > 0 && sample < len(keys) {
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		keys = keys[:sample]
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	result := &DriftResult{Checked: len(keys)}
+	var mu gosync.Mutex
+	jobs := make(chan string, len(keys))
+	var wg gosync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				info, err := client.HeadObject(ctx, key)
+				mu.Lock()
+				switch {
+				case errors.Is(err, storage.ErrNotFound):
+					result.Missing = append(result.Missing, key)
+				case err != nil:
+					result.Errors = append(result.Errors, syncerr.New("head", key, err))
+				case info.Size != m.Files[key].Size:
+					result.SizeMismatch = append(result.SizeMismatch, key)
+				case info.ContentMD5() != "" && info.ContentMD5() != m.Files[key].MD5:
+					result.HashMismatch = append(result.HashMismatch, key)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, key := range keys {
+		jobs <- key
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.SizeMismatch)
+	sort.Strings(result.HashMismatch)
+	syncerr.Sort(result.Errors)
+	return result
+}
+
+// Clean reports whether every entry checked matched the bucket, with no
+// errors.
+func (r *DriftResult) Clean() bool {
+	return len(r.Missing)+len(r.SizeMismatch)+len(r.HashMismatch)+len(r.Errors) == 0
+}
+
+// Short returns the summary as a single line.
+func (r *DriftResult) Short() string {
+	var parts []string
+	if len(r.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing from bucket", len(r.Missing)))
+	}
+	if n := len(r.SizeMismatch) + len(r.HashMismatch); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d differ from manifest", n))
+	}
+	if len(r.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Checked %d entries: manifest matches bucket", r.Checked)
+	}
+	return fmt.Sprintf("Checked %d entries: %s", r.Checked, strings.Join(parts, ", "))
+}
+
+// MarshalJSON encodes the result for verify --summary-format json.
+func (r *DriftResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Checked      int                 `json:"checked"`
+		Missing      []string            `json:"missing,omitempty"`
+		SizeMismatch []string            `json:"size_mismatch,omitempty"`
+		HashMismatch []string            `json:"hash_mismatch,omitempty"`
+		Errors       []syncerr.ErrorJSON `json:"errors,omitempty"`
+	}{r.Checked, r.Missing, r.SizeMismatch, r.HashMismatch, syncerr.JSONList(r.Errors)})
+}
+
+// Summary returns a human-readable summary of the drift check.
+func (r *DriftResult) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Checked %d manifest entries against the bucket\n", r.Checked)
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&b, "Missing from bucket (%d):\n", len(r.Missing))
+		for _, f := range r.Missing {
+			fmt.Fprintf(&b, "  %s %s\n", output.Marker("!"), f)
+		}
+	}
+	if len(r.SizeMismatch) > 0 {
+		fmt.Fprintf(&b, "Size differs from manifest (%d):\n", len(r.SizeMismatch))
+		for _, f := range r.SizeMismatch {
+			fmt.Fprintf(&b, "  %s %s\n", output.Marker("~"), f)
+		}
+	}
+	if len(r.HashMismatch) > 0 {
+		fmt.Fprintf(&b, "Content differs from manifest (%d):\n", len(r.HashMismatch))
+		for _, f := range r.HashMismatch {
+			fmt.Fprintf(&b, "  %s %s\n", output.Marker("~"), f)
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&b, "Errors: %d\n", len(r.Errors))
+		for _, err := range r.Errors {
+			fmt.Fprintf(&b, "  - %v\n", err)
+		}
+	}
+	drifted := len(r.Missing) + len(r.SizeMismatch) + len(r.HashMismatch)
+	if drifted == 0 && len(r.Errors) == 0 {
+		fmt.Fprintln(&b, "Manifest matches bucket.")
+	} else if drifted > 0 {
+		fmt.Fprintln(&b, "Re-run 'emu-sync upload' from the source machine to repair the manifest.")
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestCheckDriftDetectsMissingAndSizeMismatch(t *testing.T) {
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game1.sfc": {content: "game1", size: 5},
+		"roms/snes/Game2.sfc": {content: "game2", size: 5},
+		"roms/snes/Game3.sfc": {content: "game3", size: 5},
+	})
+	delete(mock.Objects, "roms/snes/Game2.sfc")
+	mock.Objects["roms/snes/Game3.sfc"] = []byte("truncated-and-longer")
+
+	m, err := manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+	if err != nil {
+		t.Fatalf("parsing manifest: %v", err)
+	}
+
+	result := CheckDrift(context.Background(), mock, m, 0, 2)
+	if result.Checked != 3 {
+		t.Errorf("checked %d, want 3", result.Checked)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != "roms/snes/Game2.sfc" {
+		t.Errorf("missing = %v, want [roms/snes/Game2.sfc]", result.Missing)
+	}
+	if len(result.SizeMismatch) != 1 || result.SizeMismatch[0] != "roms/snes/Game3.sfc" {
+		t.Errorf("size mismatch = %v, want [roms/snes/Game3.sfc]", result.SizeMismatch)
+	}
+	if !strings.Contains(result.Summary(), "Missing from bucket (1)") {
+		t.Errorf("summary missing drift details:\n%s", result.Summary())
+	}
+}
+
+func TestCheckDriftSample(t *testing.T) {
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/a.rom": {content: "a", size: 1},
+		"roms/b.rom": {content: "b", size: 1},
+		"roms/c.rom": {content: "c", size: 1},
+	})
+	m, _ := manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+
+	result := CheckDrift(context.Background(), mock, m, 2, 1)
+	if result.Checked != 2 {
+		t.Errorf("checked %d, want 2", result.Checked)
+	}
+	if !strings.Contains(result.Summary(), "Manifest matches bucket.") {
+		t.Errorf("expected clean summary, got:\n%s", result.Summary())
+	}
+}
+
+func TestCheckDriftDetectsHashMismatch(t *testing.T) {
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/a.rom": {content: "a", size: 1},
+		"roms/b.rom": {content: "b", size: 1},
+	})
+	m, _ := manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+	mock.Objects["roms/b.rom"] = []byte("c")
+	mock.ETags["roms/b.rom"] = "multipart-1"
+	mock.Metadata["roms/b.rom"] = map[string]string{storage.MetaMD5: md5hex("c")}
+
+	result := CheckDrift(context.Background(), mock, m, 0, 1)
+	if len(result.HashMismatch) != 1 || result.HashMismatch[0] != "roms/b.rom" {
+		t.Errorf("hash mismatch = %v, want [roms/b.rom]", result.HashMismatch)
+	}
+	if !strings.Contains(result.Summary(), "Content differs from manifest (1)") {
+		t.Errorf("summary missing hash mismatch:\n%s", result.Summary())
+	}
+}
@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	gosync "sync"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+)
+
+const (
+	// scanWorkers is the number of directories listed concurrently.
+	scanWorkers = 8
+
+	// racyWindow guards against coarse filesystem timestamps (FAT/exFAT
+	// store mtimes with 2s resolution). Directories modified this recently
+	// aren't cached, since a later change could leave the mtime unchanged.
+	racyWindow = 2 * time.Second
+)
+
+// dirIndex caches directory listings by mtime so the missing-from-disk
+// scan can skip directories that haven't changed since the last sync.
+type dirIndex struct {
+	Dirs map[string]dirListing `json:"dirs"` // absolute directory path -> listing
+}
+
+type dirListing struct {
+	ModTime int64    `json:"mtime"` // UnixNano
+	Names   []string `json:"names"`
+}
+
+// loadDirIndex reads the scan cache, returning an empty index if it is
+// missing or unreadable.
+func loadDirIndex(path string) *dirIndex {
+	idx := &dirIndex{Dirs: make(map[string]dirListing)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return idx
+	}
+	if err := json.Unmarshal(data, idx); err != nil || idx.Dirs == nil {
+		return &dirIndex{Dirs: make(map[string]dirListing)}
+	}
+	return idx
+}
+
+// save writes the scan cache atomically.
+func (idx *dirIndex) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating scan cache directory: %w", err)
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("serializing scan cache: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing scan cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming scan cache: %w", err)
+	}
+	return nil
+}
+
+// missingFromDisk returns the keys whose files no longer exist under
+// emuPath. Rather than stat'ing every file, it lists each parent directory
+// once using a pool of workers, and reuses cached listings for directories
+// whose mtime is unchanged. If cachePath is "", no cache is used.
+func missingFromDisk(emuPath string, keys []string, cachePath string) []string {
+	byDir := make(map[string][]string)
+	for _, key := range keys {
+		dir := filepath.Join(emuPath, filepath.FromSlash(path.Dir(key)))
+		byDir[dir] = append(byDir[dir], key)
+	}
+
+	old := &dirIndex{Dirs: make(map[string]dirListing)}
+	if cachePath != "" {
+		old = loadDirIndex(cachePath)
+	}
+	idx := &dirIndex{Dirs: make(map[string]dirListing, len(byDir))}
+
+	var mu gosync.Mutex
+	var missing []string
+	cached := 0
+	jobs := make(chan string, len(byDir))
+	var wg gosync.WaitGroup
+	for i := 0; i < scanWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range jobs {
+				names, listing, hit := listDir(dir, old.Dirs[dir])
+
+				mu.Lock()
+				if hit {
+					cached++
+				}
+				if listing != nil {
+					idx.Dirs[dir] = *listing
+				}
+				for _, key := range byDir[dir] {
+					if names != nil && !names[path.Base(key)] {
+						logging.Printf(logging.Files, "file missing from disk, will re-download: %s", key)
+						missing = append(missing, key)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for dir := range byDir {
+		jobs <- dir
+	}
+	close(jobs)
+	wg.Wait()
+	sort.Strings(missing)
+
+	if len(byDir) > 0 {
+		logging.Printf(logging.Debug, "scanned %d directories (%d unchanged since last sync)", len(byDir), cached)
+	}
+	if cachePath != "" {
+		if err := idx.save(cachePath); err != nil {
+			logging.Printf(logging.Debug, "warning: saving scan cache: %v", err)
+		}
+	}
+	return missing
+}
+
+// listDir returns the set of entry names in dir, or nil if dir exists but
+// can't be read. If dir's mtime matches prev, the cached names are used
+// (hit = true). The returned listing is what should be cached for dir, or
+// nil if it shouldn't be cached.
+func listDir(dir string, prev dirListing) (names map[string]bool, listing *dirListing, hit bool) {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil, false // everything in it is missing
+	}
+	if err != nil {
+		return nil, nil, false
+	}
+	names = make(map[string]bool)
+	mtime := info.ModTime().UnixNano()
+
+	if prev.Names != nil && prev.ModTime == mtime {
+		for _, name := range prev.Names {
+			names[name] = true
+		}
+		return names, &prev, true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, false
+	}
+	l := dirListing{ModTime: mtime, Names: make([]string, 0, len(entries))}
+	for _, e := range entries {
+		names[e.Name()] = true
+		l.Names = append(l.Names, e.Name())
+	}
+	if time.Since(info.ModTime()) < racyWindow {
+		return names, nil, false
+	}
+	return names, &l, false
+}
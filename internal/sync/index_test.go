@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMissingFromDisk(t *testing.T) {
+	emuDir := t.TempDir()
+	os.MkdirAll(filepath.Join(emuDir, "roms/snes"), 0o755)
+	os.WriteFile(filepath.Join(emuDir, "roms/snes/Present.sfc"), []byte("x"), 0o644)
+
+	keys := []string{"roms/snes/Present.sfc", "roms/snes/Gone.sfc", "roms/gba/NoDir.gba"}
+	missing := missingFromDisk(emuDir, keys, "")
+
+	if len(missing) != 2 || missing[0] != "roms/gba/NoDir.gba" || missing[1] != "roms/snes/Gone.sfc" {
+		t.Errorf("missing = %v, want [roms/gba/NoDir.gba roms/snes/Gone.sfc]", missing)
+	}
+}
+
+func TestMissingFromDiskUsesCache(t *testing.T) {
+	emuDir := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "scan-cache.json")
+	dir := filepath.Join(emuDir, "roms/snes")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "Game.sfc"), []byte("x"), 0o644)
+
+	// Backdate the directory so its listing is outside the racy window
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(dir, old, old)
+
+	keys := []string{"roms/snes/Game.sfc"}
+	if missing := missingFromDisk(emuDir, keys, cachePath); len(missing) != 0 {
+		t.Fatalf("first scan missing = %v, want none", missing)
+	}
+	idx := loadDirIndex(cachePath)
+	if _, ok := idx.Dirs[dir]; !ok {
+		t.Fatalf("scan cache = %v, want entry for %s", idx.Dirs, dir)
+	}
+
+	// Remove the file but restore the mtime: the cached listing is trusted
+	os.Remove(filepath.Join(dir, "Game.sfc"))
+	os.Chtimes(dir, old, old)
+	if missing := missingFromDisk(emuDir, keys, cachePath); len(missing) != 0 {
+		t.Errorf("cached scan missing = %v, want none (directory unchanged)", missing)
+	}
+
+	// A changed mtime forces a fresh listing
+	newer := old.Add(time.Minute)
+	os.Chtimes(dir, newer, newer)
+	if missing := missingFromDisk(emuDir, keys, cachePath); len(missing) != 1 {
+		t.Errorf("rescan missing = %v, want [roms/snes/Game.sfc]", missing)
+	}
+}
+
+func TestMissingFromDiskSkipsRecentDirs(t *testing.T) {
+	emuDir := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "scan-cache.json")
+	dir := filepath.Join(emuDir, "roms/snes")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "Game.sfc"), []byte("x"), 0o644)
+
+	missingFromDisk(emuDir, []string{"roms/snes/Game.sfc"}, cachePath)
+
+	if idx := loadDirIndex(cachePath); len(idx.Dirs) != 0 {
+		t.Errorf("scan cache = %v, want recently modified dir left uncached", idx.Dirs)
+	}
+}
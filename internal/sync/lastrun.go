@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+// Outcomes of a sync run, as recorded in LastRun.Status.
+const (
+	StatusOK          = "ok"            // everything planned was done
+	StatusFailed      = "failed"        // the run stopped before syncing anything
+	StatusPartial     = "partial"       // the run finished but some files failed
+	StatusNothingToDo = "nothing-to-do" // already up to date
+)
+
+// LastRun is the saved outcome of the most recent sync, so scripts, hooks,
+// and the web UI can see how it went after the process has exited.
+type LastRun struct {
+	Time       time.Time           `json:"time"`
+	Status     string              `json:"status"`
+	Error      string              `json:"error,omitempty"` // fatal error, when Status is failed
+	Downloaded int                 `json:"downloaded"`
+	Added      []string            `json:"added,omitempty"` // new to this device, as opposed to updated
+	Deleted    int                 `json:"deleted"`
+	Retained   int                 `json:"retained"`
+	Deferred   int                 `json:"deferred"`
+	Skipped    int                 `json:"skipped"`
+	Bytes      int64               `json:"bytes"`
+	Throughput float64             `json:"throughput,omitempty"` // bytes per second while downloading
+	Slowest    []Transfer          `json:"slowest,omitempty"`    // the downloads that took longest
+	Errors     []string            `json:"errors,omitempty"`
+	Failures   []syncerr.ErrorJSON `json:"failures,omitempty"` // Errors with the file, operation, and cause of each
+	Warnings   []string            `json:"warnings,omitempty"`
+	Conflicts  []string            `json:"conflicts,omitempty"` // kept because they were changed on this device
+}
+
+// Status classifies the outcome of Run. err is the error Run returned.
+func Status(result *Result, err error) string {
+	switch {
+	case err != nil || result == nil:
+		return StatusFailed
+	case len(result.Errors) > 0:
+		return StatusPartial
+	case len(result.Downloaded) == 0 && len(result.Linked) == 0 && len(result.Renamed) == 0 && len(result.Deleted) == 0 && len(result.Deferred) == 0:
+		return StatusNothingToDo
+	}
+	return StatusOK
+}
+
+// NewLastRun records the outcome of Run at time t.
+func NewLastRun(result *Result, err error, t time.Time) *LastRun {
+	lr := &LastRun{Time: t, Status: Status(result, err)}
+	if err != nil {
+		lr.Error = err.Error()
+	}
+	if result == nil {
+		return lr
+	}
+	lr.Downloaded = len(result.Downloaded)
+	lr.Added = result.Added
+	lr.Deleted = len(result.Deleted)
+	lr.Retained = len(result.Retained)
+	lr.Deferred = len(result.Deferred)
+	lr.Skipped = result.Skipped
+	lr.Bytes = result.Bytes
+	lr.Throughput = result.Throughput()
+	lr.Slowest = result.Slowest(slowestShown)
+	lr.Warnings = result.Warnings
+	lr.Conflicts = result.Conflicts
+	for _, e := range result.Errors {
+		lr.Errors = append(lr.Errors, e.Error())
+	}
+	lr.Failures = syncerr.JSONList(result.Errors)
+	return lr
+}
+
+// LoadLastRun reads a saved LastRun.
+func LoadLastRun(path string) (*LastRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lr LastRun
+	if err := json.Unmarshal(data, &lr); err != nil {
+		return nil, fmt.Errorf("parsing last sync result: %w", err)
+	}
+	return &lr, nil
+}
+
+// Save writes the LastRun to path atomically.
+func (lr *LastRun) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(lr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing last sync result: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing last sync result: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming last sync result: %w", err)
+	}
+	return nil
+}
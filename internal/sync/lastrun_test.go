@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *Result
+		err    error
+		want   string
+	}{
+		{"fatal", nil, errors.New("boom"), StatusFailed},
+		{"file errors", &Result{Downloaded: []string{"a"}, Errors: []error{errors.New("b")}}, nil, StatusPartial},
+		{"nothing to do", &Result{Skipped: 3}, nil, StatusNothingToDo},
+		{"downloaded", &Result{Downloaded: []string{"a"}}, nil, StatusOK},
+		{"deleted", &Result{Deleted: []string{"a"}}, nil, StatusOK},
+		{"deferred", &Result{Deferred: []string{"a"}}, nil, StatusOK},
+	}
+	for _, tt := range tests {
+		if got := Status(tt.result, tt.err); got != tt.want {
+			t.Errorf("%s: Status = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLastRunSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "last-sync.json")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	result := &Result{
+		Downloaded: []string{"roms/snes/A.sfc", "roms/snes/B.sfc"},
+		Skipped:    5,
+		Bytes:      2048,
+		Errors:     []error{syncerr.New("download", "roms/gba/C.gba", fmt.Errorf("roms/gba/C.gba: %w", context.DeadlineExceeded))},
+	}
+
+	if err := NewLastRun(result, nil, now).Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	lr, err := LoadLastRun(path)
+	if err != nil {
+		t.Fatalf("LoadLastRun: %v", err)
+	}
+	if !lr.Time.Equal(now) || lr.Status != StatusPartial {
+		t.Errorf("time/status = %v/%q, want %v/partial", lr.Time, lr.Status, now)
+	}
+	if lr.Downloaded != 2 || lr.Skipped != 5 || lr.Bytes != 2048 {
+		t.Errorf("counts = %+v", lr)
+	}
+	if len(lr.Errors) != 1 || lr.Errors[0] != "roms/gba/C.gba: context deadline exceeded" {
+		t.Errorf("errors = %v", lr.Errors)
+	}
+	want := syncerr.ErrorJSON{Key: "roms/gba/C.gba", Op: "download", Code: syncerr.CodeCanceled, Error: lr.Errors[0]}
+	if len(lr.Failures) != 1 || lr.Failures[0] != want {
+		t.Errorf("failures = %+v, want %+v", lr.Failures, want)
+	}
+}
+
+func TestNewLastRunFatal(t *testing.T) {
+	lr := NewLastRun(nil, errors.New("downloading remote manifest: denied"), time.Now())
+	if lr.Status != StatusFailed || lr.Error != "downloading remote manifest: denied" {
+		t.Errorf("got %+v, want failed with error", lr)
+	}
+}
@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+// Overlays returns the files in sync.overlay_dir keyed by their path
+// relative to it, which is the library key each one replaces: a patched
+// overlay_dir/roms/gba/Game.gba stands in for roms/gba/Game.gba. Returns
+// nil if no overlay_dir is configured or it doesn't exist yet.
+func Overlays(cfg *config.Config) (map[string]string, error) {
+	dir := cfg.Sync.OverlayDir
+	if dir == "" {
+		return nil, nil
+	}
+	overlays := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		overlays[filepath.ToSlash(rel)] = path
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading overlay_dir: %w", err)
+	}
+	return overlays, nil
+}
+
+// Unshadowed returns m without the keys replaced by overlays, so sync
+// neither downloads, overwrites, nor deletes them. m is returned as is
+// when there are no overlays.
+func Unshadowed(m *manifest.Manifest, overlays map[string]string) *manifest.Manifest {
+	if len(overlays) == 0 {
+		return m
+	}
+	out := manifest.New()
+	out.GeneratedAt = m.GeneratedAt
+	for key, entry := range m.Files {
+		if _, ok := overlays[key]; !ok {
+			out.Files[key] = entry
+		}
+	}
+	return out
+}
+
+// applyOverlays copies each overlay file into the emulation path where
+// it isn't already in place.
+func applyOverlays(emuPath string, overlays map[string]string, dryRun bool, result *Result) {
+	keys := make([]string, 0, len(overlays))
+	for key := range overlays {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		src, dst := overlays[key], filepath.Join(emuPath, filepath.FromSlash(key))
+		if sameContent(src, dst) {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would apply override: %s\n", key)
+			result.Overridden = append(result.Overridden, key)
+			continue
+		}
+		logging.Printf(logging.Files, "applying override: %s", key)
+		if err := copyOverlay(src, dst); err != nil {
+			result.Errors = append(result.Errors, syncerr.New("override", key, fmt.Errorf("override %s: %w", key, err)))
+			continue
+		}
+		result.Overridden = append(result.Overridden, key)
+	}
+}
+
+// copyOverlay copies src over dst through a temporary file, so an
+// interrupted copy never leaves a truncated file at dst.
+func copyOverlay(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	partial := dst + tmpSuffix
+	if err := copyFileSync(src, partial); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return nil
+}
+
+// sameContent reports whether a and b are regular files with the same
+// size and MD5.
+func sameContent(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil || !bi.Mode().IsRegular() || ai.Size() != bi.Size() {
+		return false
+	}
+	ah, err := manifest.HashFile(a)
+	if err != nil {
+		return false
+	}
+	bh, err := manifest.HashFile(b)
+	return err == nil && ah == bh
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package sync
+
+import "errors"
+
+// reflink is only implemented on Linux; elsewhere linkFile hardlinks.
+func reflink(src, dst string) error {
+	return errors.ErrUnsupported
+}
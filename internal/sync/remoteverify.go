@@ -0,0 +1,226 @@
+package sync
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+	"github.com/jacobfgrant/emu-sync/internal/units"
+)
+
+// RemoteCheck records when a bucket object was last downloaded and found
+// to match the manifest.
+type RemoteCheck struct {
+	MD5  string    `json:"md5"`
+	Time time.Time `json:"time"`
+}
+
+// RemoteVerifyState tracks which objects VerifyRemote has checked, so
+// each run picks up where the last one left off.
+type RemoteVerifyState struct {
+	Checked map[string]RemoteCheck `json:"checked"`
+}
+
+// LoadRemoteVerifyState reads saved state, returning empty state if the
+// file doesn't exist or can't be parsed.
+func LoadRemoteVerifyState(path string) *RemoteVerifyState {
+	state := &RemoteVerifyState{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			logging.Printf(logging.Debug, "warning: corrupt remote verify state, starting over: %v", err)
+		}
+	}
+	if state.Checked == nil {
+		state.Checked = make(map[string]RemoteCheck)
+	}
+	return state
+}
+
+// Save writes the state to path atomically.
+func (s *RemoteVerifyState) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing remote verify state: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing remote verify state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming remote verify state: %w", err)
+	}
+	return nil
+}
+
+// RemoteVerifyResult summarizes a VerifyRemote run.
+type RemoteVerifyResult struct {
+	OK       []string // downloaded and matched the manifest
+	Corrupt  []string // downloaded content differs from the manifest
+	Missing  []string // in the manifest but not in the bucket
+	Errors   []error
+	Bytes    int64     // downloaded this run
+	TooLarge int       // entries bigger than the whole budget, never checked
+	Covered  int       // entries verified at their current content, this run or earlier
+	Total    int       // entries in the manifest
+	Oldest   time.Time // earliest check still counted in Covered
+}
+
+// VerifyRemote downloads and re-hashes up to budget bytes of bucket
+// objects to catch silent corruption that size and ETag checks miss.
+// Entries never checked (or changed since their last check) go first,
+// then those checked longest ago, in random order within each group, so
+// repeated runs cover the whole library over time. state is updated in
+// place; entries no longer in the manifest are dropped from it.
+func VerifyRemote(ctx context.Context, client storage.Backend, m *manifest.Manifest, budget int64, state *RemoteVerifyState, now time.Time) *RemoteVerifyResult {
+	for key, check := range state.Checked {
+		if entry, ok := m.Files[key]; !ok || entry.MD5 != check.MD5 {
+			delete(state.Checked, key)
+		}
+	}
+
+	keys := make([]string, 0, len(m.Files))
+	for key := range m.Files {
+		keys = append(keys, key)
+	}
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	sort.SliceStable(keys, func(i, j int) bool {
+		return state.Checked[keys[i]].Time.Before(state.Checked[keys[j]].Time)
+	})
+
+	result := &RemoteVerifyResult{Total: len(m.Files)}
+	remaining := budget
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		entry := m.Files[key]
+		if entry.Size > budget {
+			result.TooLarge++
+			continue
+		}
+		if entry.Size > remaining {
+			continue
+		}
+		remaining -= entry.Size
+
+		logging.Printf(logging.Files, "verifying: %s", key)
+		h := md5.New()
+		err := client.DownloadTo(ctx, key, h)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			result.Missing = append(result.Missing, key)
+		case err != nil:
+			result.Errors = append(result.Errors, syncerr.New("verify", key, fmt.Errorf("verify %s: %w", key, err)))
+		case fmt.Sprintf("%x", h.Sum(nil)) != entry.MD5:
+			result.Corrupt = append(result.Corrupt, key)
+		default:
+			result.OK = append(result.OK, key)
+			state.Checked[key] = RemoteCheck{MD5: entry.MD5, Time: now}
+		}
+		if err == nil {
+			result.Bytes += entry.Size
+		}
+	}
+
+	for _, check := range state.Checked {
+		result.Covered++
+		if result.Oldest.IsZero() || check.Time.Before(result.Oldest) {
+			result.Oldest = check.Time
+		}
+	}
+	sort.Strings(result.Corrupt)
+	sort.Strings(result.Missing)
+	return result
+}
+
+// Short returns the summary as a single line.
+func (r *RemoteVerifyResult) Short() string {
+	line := fmt.Sprintf("Re-hashed %d objects (%s)", len(r.OK)+len(r.Corrupt), units.FormatSize(r.Bytes))
+	var parts []string
+	if len(r.Corrupt) > 0 {
+		parts = append(parts, fmt.Sprintf("%d differ from manifest", len(r.Corrupt)))
+	}
+	if len(r.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing from bucket", len(r.Missing)))
+	}
+	if len(r.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	if len(parts) == 0 {
+		return line + ": all match"
+	}
+	return line + ": " + strings.Join(parts, ", ")
+}
+
+// MarshalJSON encodes the result for verify --summary-format json.
+func (r *RemoteVerifyResult) MarshalJSON() ([]byte, error) {
+	var oldest *time.Time
+	if !r.Oldest.IsZero() {
+		oldest = &r.Oldest
+	}
+	return json.Marshal(struct {
+		OK       int                 `json:"ok"`
+		Corrupt  []string            `json:"corrupt,omitempty"`
+		Missing  []string            `json:"missing,omitempty"`
+		Errors   []syncerr.ErrorJSON `json:"errors,omitempty"`
+		Bytes    int64               `json:"bytes"`
+		TooLarge int                 `json:"too_large,omitempty"`
+		Covered  int                 `json:"covered"`
+		Total    int                 `json:"total"`
+		Oldest   *time.Time          `json:"oldest_check,omitempty"`
+	}{len(r.OK), r.Corrupt, r.Missing, syncerr.JSONList(r.Errors), r.Bytes, r.TooLarge, r.Covered, r.Total, oldest})
+}
+
+// Summary returns a human-readable summary of the run.
+func (r *RemoteVerifyResult) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Re-hashed %d objects (%s) from the bucket\n", len(r.OK)+len(r.Corrupt), units.FormatSize(r.Bytes))
+	if len(r.Corrupt) > 0 {
+		fmt.Fprintf(&b, "Content differs from manifest (%d):\n", len(r.Corrupt))
+		for _, f := range r.Corrupt {
+			fmt.Fprintf(&b, "  ~ %s\n", f)
+		}
+	}
+	if len(r.Missing) > 0 {
+		fmt.Fprintf(&b, "Missing from bucket (%d):\n", len(r.Missing))
+		for _, f := range r.Missing {
+			fmt.Fprintf(&b, "  ! %s\n", f)
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(&b, "Errors: %d\n", len(r.Errors))
+		for _, err := range r.Errors {
+			fmt.Fprintf(&b, "  - %v\n", err)
+		}
+	}
+	if r.Total > 0 {
+		fmt.Fprintf(&b, "Coverage: %d of %d files verified (%d%%)", r.Covered, r.Total, r.Covered*100/r.Total)
+		if !r.Oldest.IsZero() {
+			fmt.Fprintf(&b, ", oldest check %s", r.Oldest.Local().Format("2006-01-02"))
+		}
+		fmt.Fprintln(&b)
+	}
+	if r.TooLarge > 0 {
+		fmt.Fprintf(&b, "Skipped %d files larger than the budget; raise --budget to include them\n", r.TooLarge)
+	}
+	if len(r.Corrupt) > 0 || len(r.Missing) > 0 {
+		fmt.Fprintln(&b, "Re-upload the affected files from the source machine to repair the bucket.")
+	}
+	return b.String()
+}
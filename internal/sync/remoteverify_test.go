@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestVerifyRemoteDetectsCorruption(t *testing.T) {
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/a.rom": {content: "aaaa", size: 4},
+		"roms/b.rom": {content: "bbbb", size: 4},
+	})
+	m, _ := manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+	mock.Objects["roms/b.rom"] = []byte("bbbX") // same size, so only a re-hash notices
+
+	state := &RemoteVerifyState{Checked: map[string]RemoteCheck{}}
+	result := VerifyRemote(context.Background(), mock, m, 1<<20, state, time.Now())
+	if len(result.OK) != 1 || result.OK[0] != "roms/a.rom" {
+		t.Errorf("OK = %v, want [roms/a.rom]", result.OK)
+	}
+	if len(result.Corrupt) != 1 || result.Corrupt[0] != "roms/b.rom" {
+		t.Errorf("Corrupt = %v, want [roms/b.rom]", result.Corrupt)
+	}
+	if _, ok := state.Checked["roms/b.rom"]; ok {
+		t.Error("corrupt object should not count as verified")
+	}
+	if !strings.Contains(result.Summary(), "Coverage: 1 of 2 files verified (50%)") {
+		t.Errorf("summary missing coverage:\n%s", result.Summary())
+	}
+}
+
+func TestVerifyRemoteBudgetCoversLibraryOverRuns(t *testing.T) {
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/a.rom":   {content: "aaaa", size: 4},
+		"roms/b.rom":   {content: "bbbb", size: 4},
+		"roms/c.rom":   {content: "cccc", size: 4},
+		"roms/big.iso": {content: "0123456789", size: 10},
+	})
+	m, _ := manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+	statePath := filepath.Join(t.TempDir(), "remote-verify.json")
+
+	now := time.Now()
+	for run := 1; run <= 3; run++ {
+		state := LoadRemoteVerifyState(statePath)
+		result := VerifyRemote(context.Background(), mock, m, 5, state, now.Add(time.Duration(run)*time.Hour))
+		if result.Bytes > 5 {
+			t.Errorf("run %d downloaded %d bytes, budget is 5", run, result.Bytes)
+		}
+		if result.TooLarge != 1 {
+			t.Errorf("run %d: TooLarge = %d, want 1", run, result.TooLarge)
+		}
+		if result.Covered != run {
+			t.Errorf("run %d: Covered = %d, want %d", run, result.Covered, run)
+		}
+		if err := state.Save(statePath); err != nil {
+			t.Fatalf("saving state: %v", err)
+		}
+	}
+
+	// Everything that fits has been checked once; the next run rechecks
+	// the oldest.
+	state := LoadRemoteVerifyState(statePath)
+	oldest := ""
+	for key, check := range state.Checked {
+		if oldest == "" || check.Time.Before(state.Checked[oldest].Time) {
+			oldest = key
+		}
+	}
+	result := VerifyRemote(context.Background(), mock, m, 5, state, now.Add(4*time.Hour))
+	if len(result.OK) != 1 || result.OK[0] != oldest {
+		t.Errorf("fourth run checked %v, want the oldest check %s", result.OK, oldest)
+	}
+}
+
+func TestVerifyRemoteForgetsChangedEntries(t *testing.T) {
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/a.rom": {content: "aaaa", size: 4},
+	})
+	m, _ := manifest.ParseJSON(mock.Objects[storage.ManifestKey])
+	state := &RemoteVerifyState{Checked: map[string]RemoteCheck{
+		"roms/a.rom":    {MD5: "stale", Time: time.Now()},
+		"roms/gone.rom": {MD5: md5hex("gone"), Time: time.Now()},
+	}}
+
+	result := VerifyRemote(context.Background(), mock, m, 0, state, time.Now())
+	if result.Covered != 0 || len(state.Checked) != 0 {
+		t.Errorf("Covered = %d, state = %v; want stale and removed entries dropped", result.Covered, state.Checked)
+	}
+}
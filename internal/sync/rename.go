@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+// renameMoved detects files moved in the library: a new key whose
+// content matches a local file about to be deleted. The local file is
+// renamed instead of downloading the new key and deleting the old one.
+// It returns the added and deleted keys that still need handling.
+func renameMoved(emuPath string, filteredRemote, local *manifest.Manifest, added, deleted []string, result *Result) (remainingAdded, remainingDeleted []string) {
+	sources := make(map[string][]string)
+	for _, key := range deleted {
+		if entry, ok := local.Files[key]; ok && entry.MD5 != "" {
+			ck := contentKey(entry)
+			sources[ck] = append(sources[ck], key)
+		}
+	}
+	if len(sources) == 0 {
+		return added, deleted
+	}
+
+	moved := make(map[string]bool)
+	for _, key := range added {
+		entry := filteredRemote.Files[key]
+		ck := contentKey(entry)
+		if len(sources[ck]) == 0 {
+			remainingAdded = append(remainingAdded, key)
+			continue
+		}
+		src := sources[ck][0]
+		err := renameFile(filepath.Join(emuPath, filepath.FromSlash(src)), filepath.Join(emuPath, filepath.FromSlash(key)), entry.Size)
+		if err != nil {
+			logging.Printf(logging.Debug, "can't rename %s to %s, downloading: %v", src, key, err)
+			remainingAdded = append(remainingAdded, key)
+			continue
+		}
+		logging.Printf(logging.Files, "renamed: %s -> %s", src, key)
+		sources[ck] = sources[ck][1:]
+		moved[src] = true
+		delete(local.Files, src)
+		local.Files[key] = entry
+		result.Renamed = append(result.Renamed, key)
+	}
+
+	for _, key := range deleted {
+		if !moved[key] {
+			remainingDeleted = append(remainingDeleted, key)
+		}
+	}
+	return remainingAdded, remainingDeleted
+}
+
+// renameFile moves src to dst, checking first that src is still the file
+// that was synced and that nothing is in the way at dst.
+func renameFile(src, dst string, size int64) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() != size {
+		return fmt.Errorf("%s changed since it was synced", src)
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
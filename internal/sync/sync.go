@@ -2,36 +2,48 @@ package sync
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	gosync "sync"
 	"syscall"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/output"
 	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/retry"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+	"github.com/jacobfgrant/emu-sync/internal/systems"
+	"github.com/jacobfgrant/emu-sync/internal/units"
 )
 
 const tmpSuffix = ".emu-sync-tmp"
 
-func acquireLock() (*os.File, error) {
-	lockDir := filepath.Dir(config.DefaultLocalManifestPath())
-	os.MkdirAll(lockDir, 0o755)
-	f, err := os.OpenFile(
-		filepath.Join(lockDir, "sync.lock"),
-		os.O_CREATE|os.O_RDWR, 0o644,
-	)
+// ErrLocked is returned by Run when another sync holds the lock.
+var ErrLocked = errors.New("another sync is already running")
+
+// acquireLock locks the local manifest at manifestPath for one sync.
+func acquireLock(manifestPath string) (*os.File, error) {
+	os.MkdirAll(filepath.Dir(manifestPath), 0o755)
+	f, err := os.OpenFile(config.LockPath(manifestPath), os.O_CREATE|os.O_RDWR, 0o644)
 	if err != nil {
 		return nil, fmt.Errorf("opening lock file: %w", err)
 	}
 	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
 		f.Close()
-		return nil, fmt.Errorf("another sync is already running")
+		return nil, ErrLocked
 	}
 	return f, nil
 }
@@ -45,34 +57,63 @@ func releaseLock(f *os.File) {
 type Options struct {
 	DryRun            bool
 	NoDelete          bool
-	Verbose           bool
 	Workers           int                // number of parallel downloads; 0 or 1 = sequential
 	MaxRetries        int                // per-file retries with backoff; 0 = no retries
 	SaveThreshold     int64              // bytes downloaded before mid-sync manifest save; 0 = default (50 MB)
+	DeleteThreshold   float64            // skip deletes if more than this fraction of local files were removed from remote; 0 = no limit
+	MaxDuration       time.Duration      // stop starting new downloads after this long; 0 = no limit
 	Progress          *progress.Reporter // emits JSON progress events; nil = no-op
 	LocalManifestPath string             // overrides default; used by tests
+	OverwriteModified bool               // replace files changed on this device instead of keeping them
 }
 
 // Result summarizes what a sync run did.
 type Result struct {
 	Downloaded []string
+	Added      []string // the part of Downloaded and Linked new to this device, sorted
 	Deleted    []string
-	Retained   []string // deselected files kept on disk (delete disabled)
+	Removed    []string // the part of Deleted removed from the library; the rest were deselected
+	Retained   []string // files no longer synced but kept on disk (delete disabled)
+	Orphaned   []string // the part of Retained removed from the library; the rest were deselected
 	Skipped    int
 	Errors     []error
+	Warnings   []string      // run-level problems that need the user's attention
+	Bytes      int64         // total size of downloaded files
+	Cost       float64       // estimated egress cost in dollars; 0 if pricing isn't configured
+	Deferred   []string      // not started because MaxDuration was reached
+	Linked     []string      // cloned or hardlinked from an identical local file instead of downloaded
+	Renamed    []string      // moved in the library and renamed locally instead of downloaded
+	Conflicts  []string      // changed on this device and in the library; kept instead of overwritten
+	Overridden []string      // copied into place from sync.overlay_dir
+	Transfers  []Transfer    // per-file download times, in completion order
+	Elapsed    time.Duration // wall time spent downloading, across all workers
+}
+
+// keep records a file that no longer syncs but stays on disk.
+func (r *Result) keep(key string, inLibrary bool) {
+	r.Retained = append(r.Retained, key)
+	if !inLibrary {
+		r.Orphaned = append(r.Orphaned, key)
+	}
 }
 
 // downloadResult is sent back from worker goroutines.
 type downloadResult struct {
-	key   string
-	entry manifest.FileEntry
-	err   error
+	key      string
+	entry    manifest.FileEntry
+	err      error
+	deferred bool          // skipped because the deadline passed
+	elapsed  time.Duration // how long the download took, including retries
 }
 
 // Run downloads the remote manifest, diffs against local, and syncs files.
 func Run(ctx context.Context, client storage.Backend, cfg *config.Config, opts Options) (*Result, error) {
+	localManifestPath := opts.LocalManifestPath
+	if localManifestPath == "" {
+		localManifestPath = config.DefaultLocalManifestPath()
+	}
 	if !opts.DryRun {
-		lock, err := acquireLock()
+		lock, err := acquireLock(localManifestPath)
 		if err != nil {
 			return nil, err
 		}
@@ -80,6 +121,23 @@ func Run(ctx context.Context, client storage.Backend, cfg *config.Config, opts O
 	}
 
 	result := &Result{}
+	start := time.Now()
+
+	var deadline time.Time
+	if opts.MaxDuration > 0 {
+		deadline = time.Now().Add(opts.MaxDuration)
+	}
+
+	// Load the local manifest while the remote one downloads
+	localCh := make(chan *manifest.Manifest, 1)
+	go func() {
+		local, err := manifest.LoadJSON(localManifestPath)
+		if err != nil {
+			logging.Printf(logging.Debug, "no local manifest found, treating as first sync: %v", err)
+			local = manifest.New()
+		}
+		localCh <- local
+	}()
 
 	// Download remote manifest
 	remoteData, err := client.DownloadManifest(ctx)
@@ -92,61 +150,75 @@ func Run(ctx context.Context, client storage.Backend, cfg *config.Config, opts O
 		return nil, fmt.Errorf("parsing remote manifest: %w", err)
 	}
 
-	// Load local manifest (or start empty)
-	localManifestPath := opts.LocalManifestPath
-	if localManifestPath == "" {
-		localManifestPath = config.DefaultLocalManifestPath()
-	}
-	local, err := manifest.LoadJSON(localManifestPath)
-	if err != nil {
-		if opts.Verbose {
-			log.Printf("no local manifest found, treating as first sync: %v", err)
-		}
-		local = manifest.New()
-	}
+	local := <-localCh
 
-	// Filter remote manifest to configured sync_dirs / sync_exclude
-	filteredRemote := manifest.New()
-	filteredRemote.GeneratedAt = remote.GeneratedAt
+	// Filter the remote manifest to sync_dirs / sync_exclude and the
+	// policy in place rather than copying it, which doubles peak memory
+	// on a library of hundreds of thousands of files. Deletes still need
+	// to tell a deselected file from one removed from the library, so the
+	// dropped keys this device has are remembered.
+	deselected := make(map[string]bool)
 	for key, entry := range remote.Files {
-		if cfg.ShouldSync(key) {
-			filteredRemote.Files[key] = entry
+		if !cfg.ShouldSync(key) || !cfg.Policy.Allows(key, entry.Tags) {
+			if _, ok := local.Files[key]; ok {
+				deselected[key] = true
+			}
+			delete(remote.Files, key)
 		}
 	}
+	filteredRemote := remote
+	isInLibrary := func(key string) bool {
+		_, ok := remote.Files[key]
+		return ok || deselected[key]
+	}
 
-	diff := manifest.Diff(filteredRemote, local)
-
-	// Check for files that the local manifest says exist but are
-	// missing from disk (e.g., accidentally deleted by the user).
-	// Build a set of keys already queued for download (Added + Modified)
-	// to avoid duplicates.
-	queued := make(map[string]bool, len(diff.Added)+len(diff.Modified))
-	for _, key := range diff.Added {
-		queued[key] = true
+	// Files replaced by an overlay are left out entirely; the overlay is
+	// copied into place after the downloads.
+	overlays, err := Overlays(cfg)
+	if err != nil {
+		return nil, err
 	}
-	for _, key := range diff.Modified {
-		queued[key] = true
+	filteredRemote = Unshadowed(filteredRemote, overlays)
+	local = Unshadowed(local, overlays)
+
+	if err := checkCaseCollisions(filteredRemote, remote.CaseCollisions, cfg.Sync.EmulationPath); err != nil {
+		return nil, err
 	}
-	for key := range filteredRemote.Files {
-		if queued[key] {
-			continue // already scheduled for download
-		}
-		if _, inLocal := local.Files[key]; !inLocal {
-			continue // not in local manifest, already in diff.Added
-		}
-		localPath := filepath.Join(cfg.Sync.EmulationPath, filepath.FromSlash(key))
-		if _, err := os.Stat(localPath); os.IsNotExist(err) {
-			if opts.Verbose {
-				log.Printf("file missing from disk, will re-download: %s", key)
-			}
-			diff.Added = append(diff.Added, key)
-			delete(local.Files, key)
+
+	matchNormalization(cfg.Sync.EmulationPath, filteredRemote, local, opts.DryRun)
+
+	// Unchanged files are checked for being missing from disk (e.g.,
+	// accidentally deleted by the user); added and modified ones are
+	// downloaded anyway. Scanning is slow on SD cards, so it runs in the
+	// background while the queued files download; anything it finds is
+	// downloaded in a second pass.
+	var diff manifest.DiffResult
+	var candidates []string
+	for key, c := range manifest.Changes(filteredRemote, local) {
+		if c == manifest.Unchanged {
+			candidates = append(candidates, key)
+		} else {
+			diff.Add(key, c)
 		}
 	}
+	scanCachePath := config.DefaultScanCachePath()
+	if opts.LocalManifestPath != "" {
+		scanCachePath = filepath.Join(filepath.Dir(localManifestPath), "scan-cache.json")
+	}
+	missingCh := make(chan []string, 1)
+	go func() {
+		missingCh <- missingFromDisk(cfg.Sync.EmulationPath, candidates, scanCachePath)
+	}()
 
 	// Clean up any leftover temp files from interrupted syncs
 	if !opts.DryRun {
-		cleanTempFiles(cfg.Sync.EmulationPath, opts.Verbose)
+		cleanTempFiles(cfg.Sync.EmulationPath)
+		if cfg.Sync.StagingDir != "" {
+			if err := os.MkdirAll(cfg.Sync.StagingDir, 0o755); err != nil {
+				return nil, fmt.Errorf("creating staging_dir: %w", err)
+			}
+			cleanTempFiles(cfg.Sync.StagingDir)
+		}
 	}
 
 	// Resolve save threshold (default 50 MB)
@@ -155,66 +227,186 @@ func Run(ctx context.Context, client storage.Backend, cfg *config.Config, opts O
 		threshold = 50 * 1024 * 1024
 	}
 
-	// Download new and modified files
+	// Decided before downloading, since files moved in the library are
+	// renamed locally only if the old path would be deleted anyway.
+	// Deselected files and files removed from the library are governed by
+	// separate settings.
+	deleteAllowed := !opts.NoDelete
+	if deleteAllowed && cfg.Sync.DeletesFile(false) {
+		if msg := checkDeleteThreshold(diff.Deleted, isInLibrary, local, opts.DeleteThreshold); msg != "" {
+			log.Printf("WARNING: %s", msg)
+			result.Warnings = append(result.Warnings, msg)
+			if opts.Progress != nil {
+				opts.Progress.Warning(msg)
+			}
+			deleteAllowed = false
+		}
+	}
+	deletes := func(key string) bool {
+		return deleteAllowed && cfg.Sync.DeletesFile(isInLibrary(key))
+	}
+	if !opts.DryRun {
+		var deletable, kept []string
+		for _, key := range diff.Deleted {
+			if deletes(key) {
+				deletable = append(deletable, key)
+			} else {
+				kept = append(kept, key)
+			}
+		}
+		if len(deletable) > 0 {
+			diff.Added, deletable = renameMoved(cfg.Sync.EmulationPath, filteredRemote, local, diff.Added, deletable, result)
+			diff.Deleted = append(deletable, kept...)
+		}
+	}
+
+	if !opts.OverwriteModified {
+		diff.Modified = skipModified(cfg.Sync.EmulationPath, filteredRemote, local, diff.Modified, result)
+		sort.Strings(result.Conflicts)
+		if len(result.Conflicts) > 0 {
+			msg := fmt.Sprintf("%d files changed on this device also have a new version in the library; kept the local copies (sync --overwrite-modified replaces them)", len(result.Conflicts))
+			log.Printf("WARNING: %s", msg)
+			result.Warnings = append(result.Warnings, msg)
+			if opts.Progress != nil {
+				opts.Progress.Warning(msg)
+			}
+		}
+	}
+
+	added := make(map[string]bool, len(diff.Added))
+	for _, key := range diff.Added {
+		added[key] = true
+	}
+
+	// Download new and modified files, then anything the scan found
 	toDownload := append(diff.Added, diff.Modified...)
+	fileSize := func(key string) int64 { return filteredRemote.Files[key].Size }
+	config.SortTransfers(toDownload, cfg.Sync.Order, cfg.Sync.Priority, fileSize)
+	if msg := coldStorageWarning(filteredRemote, toDownload); msg != "" {
+		log.Printf("WARNING: %s", msg)
+		result.Warnings = append(result.Warnings, msg)
+		if opts.Progress != nil {
+			opts.Progress.Warning(msg)
+		}
+	}
+	if opts.Progress != nil {
+		opts.Progress.Plan(len(toDownload), sumSizes(filteredRemote, toDownload))
+	}
+	fetch, later := toDownload, []string(nil)
+	if !opts.DryRun && cfg.Sync.DedupeEnabled() {
+		fetch, later = linkDuplicates(cfg.Sync.EmulationPath, filteredRemote, local, toDownload, result)
+	}
+	downloadKeys(ctx, client, cfg, filteredRemote, fetch, opts, result, local, localManifestPath, threshold, deadline)
+	if len(later) > 0 {
+		fetch, _ = linkDuplicates(cfg.Sync.EmulationPath, filteredRemote, local, later, result)
+		downloadKeys(ctx, client, cfg, filteredRemote, fetch, opts, result, local, localManifestPath, threshold, deadline)
+	}
 
-	if opts.DryRun {
-		for _, key := range toDownload {
-			fmt.Printf("would download: %s\n", key)
-			result.Downloaded = append(result.Downloaded, key)
+	missing := <-missingCh
+	config.SortTransfers(missing, cfg.Sync.Order, cfg.Sync.Priority, fileSize)
+	for _, key := range missing {
+		delete(local.Files, key)
+	}
+	if opts.Progress != nil && len(missing) > 0 {
+		all := append(toDownload[:len(toDownload):len(toDownload)], missing...)
+		opts.Progress.Plan(len(all), sumSizes(filteredRemote, all))
+	}
+	downloadKeys(ctx, client, cfg, filteredRemote, missing, opts, result, local, localManifestPath, threshold, deadline)
+	toDownload = append(toDownload, missing...)
+
+	applyOverlays(cfg.Sync.EmulationPath, overlays, opts.DryRun, result)
+
+	if len(result.Deferred) > 0 {
+		msg := fmt.Sprintf("max duration reached; %d files (%s) deferred to the next sync",
+			len(result.Deferred), units.FormatSize(sumSizes(filteredRemote, result.Deferred)))
+		log.Print(msg)
+		if opts.Progress != nil {
+			opts.Progress.Warning(msg)
 		}
-	} else if opts.Workers > 1 && len(toDownload) > 1 {
-		downloadParallel(ctx, client, cfg, filteredRemote, toDownload, opts, result, local, localManifestPath, threshold)
-	} else {
-		downloadSequential(ctx, client, cfg, filteredRemote, toDownload, opts, result, local, localManifestPath, threshold)
 	}
 
 	// Delete local files removed from remote
-	deleteAllowed := cfg.Sync.Delete && !opts.NoDelete
+	sort.Strings(diff.Deleted)
 	for _, key := range diff.Deleted {
 		localPath := filepath.Join(cfg.Sync.EmulationPath, filepath.FromSlash(key))
 
+		inLibrary := isInLibrary(key)
+		allowed := deletes(key)
+
 		if opts.DryRun {
-			if deleteAllowed {
+			switch {
+			case allowed && opts.Progress != nil:
+				opts.Progress.WouldDelete(key)
+			case allowed:
 				fmt.Printf("would delete: %s\n", key)
-			} else {
+			case opts.Progress != nil:
+				opts.Progress.Retain(key)
+			default:
 				fmt.Printf("would delete (skipped, delete disabled): %s\n", key)
 			}
-			result.Deleted = append(result.Deleted, key)
+			if allowed {
+				result.Deleted = append(result.Deleted, key)
+				if !inLibrary {
+					result.Removed = append(result.Removed, key)
+				}
+			} else {
+				result.keep(key, inLibrary)
+			}
 			continue
 		}
 
-		if !deleteAllowed {
-			if opts.Verbose {
-				log.Printf("skipping delete (disabled): %s", key)
-			}
-			result.Retained = append(result.Retained, key)
+		if !allowed {
+			logging.Printf(logging.Files, "skipping delete (disabled): %s", key)
+			result.keep(key, inLibrary)
 			if opts.Progress != nil {
 				opts.Progress.Retain(key)
 			}
 			continue
 		}
 
-		if opts.Verbose {
-			log.Printf("deleting: %s", key)
-		}
+		logging.Printf(logging.Files, "deleting: %s", key)
 
+		if err := manifest.ValidKey(key); err != nil {
+			result.Errors = append(result.Errors, syncerr.New("delete", key, fmt.Errorf("delete: %w", err)))
+			continue
+		}
 		if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
-			result.Errors = append(result.Errors, fmt.Errorf("delete %s: %w", key, err))
+			result.Errors = append(result.Errors, syncerr.New("delete", key, fmt.Errorf("delete %s: %w", key, err)))
 			continue
 		}
 
 		delete(local.Files, key)
 		result.Deleted = append(result.Deleted, key)
+		if !inLibrary {
+			result.Removed = append(result.Removed, key)
+		}
 		if opts.Progress != nil {
 			opts.Progress.Delete(key)
 		}
 	}
 
-	result.Skipped = len(filteredRemote.Files) - len(toDownload)
+	result.Skipped = len(filteredRemote.Files) - len(toDownload) - len(result.Renamed) - len(result.Conflicts)
+	result.Bytes = sumSizes(filteredRemote, result.Downloaded)
+	result.Cost = cfg.Storage.Cost.Estimate(0, result.Bytes)
 
+	for _, key := range append(result.Downloaded[:len(result.Downloaded):len(result.Downloaded)], result.Linked...) {
+		if added[key] {
+			result.Added = append(result.Added, key)
+		}
+	}
+	sort.Strings(result.Added)
+	syncerr.Sort(result.Errors)
 	if opts.Progress != nil {
-		opts.Progress.Done(len(result.Downloaded), len(result.Deleted), len(result.Retained), len(result.Errors), result.Skipped)
+		opts.Progress.Done(progress.Summary{
+			Downloaded: len(result.Downloaded),
+			Deleted:    len(result.Deleted),
+			Retained:   len(result.Retained),
+			Errors:     len(result.Errors),
+			Skipped:    result.Skipped,
+			Bytes:      result.Bytes,
+			Duration:   time.Since(start),
+			Message:    systems.Announcement(result.Added, cfg.SystemOverrides(), systems.AnnounceMax),
+		})
 	}
 
 	// Save updated local manifest
@@ -227,19 +419,123 @@ func Run(ctx context.Context, client storage.Backend, cfg *config.Config, opts O
 	return result, nil
 }
 
-func downloadSequential(ctx context.Context, client storage.Backend, cfg *config.Config, filteredRemote *manifest.Manifest, keys []string, opts Options, result *Result, local *manifest.Manifest, localManifestPath string, saveThreshold int64) {
+// checkDeleteThreshold returns a warning message if the keys removed from
+// the remote manifest exceed threshold as a fraction of the local
+// manifest, or "" if deletion is safe. Deselected keys that still exist
+// remotely don't count — only files that vanished from the bucket, which
+// is what an accidentally emptied bucket or truncated manifest looks like.
+func checkDeleteThreshold(deleted []string, inLibrary func(string) bool, local *manifest.Manifest, threshold float64) string {
+	if threshold <= 0 || len(local.Files) == 0 {
+		return ""
+	}
+	removed := 0
+	for _, key := range deleted {
+		if !inLibrary(key) {
+			removed++
+		}
+	}
+	frac := float64(removed) / float64(len(local.Files))
+	if removed == 0 || frac <= threshold {
+		return ""
+	}
+	return fmt.Sprintf("remote manifest would delete %d of %d local files (%.0f%%), above the %.0f%% safety threshold; deletions skipped. Check the bucket, or raise sync.delete_threshold if this is intended.",
+		removed, len(local.Files), frac*100, threshold*100)
+}
+
+// coldStorageWarning returns a warning if any keys to download were
+// uploaded to a storage class that charges for retrieval or needs a
+// restore first, or "" if none were.
+func coldStorageWarning(remote *manifest.Manifest, keys []string) string {
+	counts := make(map[string]int)
+	var cold []string
+	var size int64
+	for _, key := range keys {
+		entry := remote.Files[key]
+		switch entry.StorageClass {
+		case "", "STANDARD", "INTELLIGENT_TIERING", "REDUCED_REDUNDANCY":
+			continue
+		}
+		if counts[entry.StorageClass] == 0 {
+			cold = append(cold, entry.StorageClass)
+		}
+		counts[entry.StorageClass]++
+		size += entry.Size
+	}
+	if len(cold) == 0 {
+		return ""
+	}
+	sort.Strings(cold)
+
+	n := 0
+	parts := make([]string, len(cold))
+	restore := false
+	for i, class := range cold {
+		n += counts[class]
+		parts[i] = fmt.Sprintf("%s: %d", class, counts[class])
+		if class == "GLACIER" || class == "DEEP_ARCHIVE" {
+			restore = true
+		}
+	}
+	msg := fmt.Sprintf("%d files to download (%s) are in cold storage (%s); retrieval fees may apply",
+		n, units.FormatSize(size), strings.Join(parts, ", "))
+	if restore {
+		msg += ". GLACIER and DEEP_ARCHIVE files fail to download until they are restored in the bucket"
+	}
+	return msg
+}
+
+// downloadKeys downloads keys (or reports them in dry-run mode). Directories
+// with [sync.tuning] overrides are downloaded as separate batches with their
+// own worker and retry settings.
+func downloadKeys(ctx context.Context, client storage.Backend, cfg *config.Config, filteredRemote *manifest.Manifest, keys []string, opts Options, result *Result, local *manifest.Manifest, localManifestPath string, saveThreshold int64, deadline time.Time) {
+	if opts.DryRun {
+		for _, key := range keys {
+			if opts.Progress != nil {
+				opts.Progress.WouldDownload(key, filteredRemote.Files[key].Size)
+			} else {
+				fmt.Printf("would download: %s\n", key)
+			}
+			result.Downloaded = append(result.Downloaded, key)
+		}
+		return
+	}
+
+	start := time.Now()
+	defer func() { result.Elapsed += time.Since(start) }()
+	for _, batch := range config.BatchByTuning(keys, cfg.Sync.Tuning, opts.Workers, opts.MaxRetries) {
+		batchOpts := opts
+		batchOpts.Workers = batch.Workers
+		batchOpts.MaxRetries = batch.MaxRetries
+		if batch.Dir != "" {
+			logging.Printf(logging.Debug, "tuning %s: workers=%d max_retries=%d", batch.Dir, batch.Workers, batch.MaxRetries)
+		}
+		if batchOpts.Workers > 1 && len(batch.Keys) > 1 {
+			downloadParallel(ctx, client, cfg, filteredRemote, batch.Keys, batchOpts, result, local, localManifestPath, saveThreshold, deadline)
+		} else {
+			downloadSequential(ctx, client, cfg, filteredRemote, batch.Keys, batchOpts, result, local, localManifestPath, saveThreshold, deadline)
+		}
+	}
+}
+
+func downloadSequential(ctx context.Context, client storage.Backend, cfg *config.Config, filteredRemote *manifest.Manifest, keys []string, opts Options, result *Result, local *manifest.Manifest, localManifestPath string, saveThreshold int64, deadline time.Time) {
 	prog := opts.Progress
 	maxRetries := opts.MaxRetries
 	var unsavedBytes int64
-	for _, key := range keys {
+	for i, key := range keys {
+		if pastDeadline(deadline) {
+			result.Deferred = append(result.Deferred, keys[i:]...)
+			break
+		}
 		entry := filteredRemote.Files[key]
 		if prog != nil {
 			prog.Start(key, entry.Size)
 		}
+		start := time.Now()
 		err := retry.WithBackoff(ctx, maxRetries, func() error {
-			return downloadOne(ctx, client, cfg.Sync.EmulationPath, key, opts.Verbose)
+			return downloadOne(ctx, client, cfg.Sync.EmulationPath, cfg.Sync.StagingDir, key, entry)
 		})
 		if err != nil {
+			err = syncerr.New("download", key, err)
 			result.Errors = append(result.Errors, err)
 			if prog != nil {
 				prog.FileError(key, err)
@@ -248,22 +544,21 @@ func downloadSequential(ctx context.Context, client storage.Backend, cfg *config
 		}
 		local.Files[key] = entry
 		result.Downloaded = append(result.Downloaded, key)
+		result.addTransfer(key, entry.Size, time.Since(start))
 		if prog != nil {
 			prog.Complete(key)
 		}
 		unsavedBytes += entry.Size
 		if unsavedBytes >= saveThreshold {
 			if err := local.SaveJSON(localManifestPath); err != nil {
-				if opts.Verbose {
-					log.Printf("warning: mid-sync manifest save: %v", err)
-				}
+				logging.Printf(logging.Files, "warning: mid-sync manifest save: %v", err)
 			}
 			unsavedBytes = 0
 		}
 	}
 }
 
-func downloadParallel(ctx context.Context, client storage.Backend, cfg *config.Config, filteredRemote *manifest.Manifest, keys []string, opts Options, result *Result, local *manifest.Manifest, localManifestPath string, saveThreshold int64) {
+func downloadParallel(ctx context.Context, client storage.Backend, cfg *config.Config, filteredRemote *manifest.Manifest, keys []string, opts Options, result *Result, local *manifest.Manifest, localManifestPath string, saveThreshold int64, deadline time.Time) {
 	// Channel for sending keys to workers
 	jobs := make(chan string, len(keys))
 	// Channel for collecting results from workers
@@ -279,16 +574,22 @@ func downloadParallel(ctx context.Context, client storage.Backend, cfg *config.C
 			defer wg.Done()
 			for key := range jobs {
 				entry := filteredRemote.Files[key]
+				if pastDeadline(deadline) {
+					results <- downloadResult{key: key, entry: entry, deferred: true}
+					continue
+				}
 				if opts.Progress != nil {
 					opts.Progress.Start(key, entry.Size)
 				}
+				start := time.Now()
 				err := retry.WithBackoff(ctx, maxRetries, func() error {
-					return downloadOne(ctx, client, cfg.Sync.EmulationPath, key, opts.Verbose)
+					return downloadOne(ctx, client, cfg.Sync.EmulationPath, cfg.Sync.StagingDir, key, entry)
 				})
 				results <- downloadResult{
-					key:   key,
-					entry: entry,
-					err:   err,
+					key:     key,
+					entry:   entry,
+					err:     syncerr.New("download", key, err),
+					elapsed: time.Since(start),
 				}
 			}
 		}()
@@ -310,6 +611,10 @@ func downloadParallel(ctx context.Context, client storage.Backend, cfg *config.C
 	prog := opts.Progress
 	var unsavedBytes int64
 	for dr := range results {
+		if dr.deferred {
+			result.Deferred = append(result.Deferred, dr.key)
+			continue
+		}
 		if dr.err != nil {
 			result.Errors = append(result.Errors, dr.err)
 			if prog != nil {
@@ -319,40 +624,72 @@ func downloadParallel(ctx context.Context, client storage.Backend, cfg *config.C
 		}
 		local.Files[dr.key] = dr.entry
 		result.Downloaded = append(result.Downloaded, dr.key)
+		result.addTransfer(dr.key, dr.entry.Size, dr.elapsed)
 		if prog != nil {
 			prog.Complete(dr.key)
 		}
 		unsavedBytes += dr.entry.Size
 		if unsavedBytes >= saveThreshold {
 			if err := local.SaveJSON(localManifestPath); err != nil {
-				if opts.Verbose {
-					log.Printf("warning: mid-sync manifest save: %v", err)
-				}
+				logging.Printf(logging.Files, "warning: mid-sync manifest save: %v", err)
 			}
 			unsavedBytes = 0
 		}
 	}
 }
 
+// pastDeadline reports whether a non-zero deadline has passed.
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// sumSizes returns the total manifest size of the given keys.
+func sumSizes(m *manifest.Manifest, keys []string) int64 {
+	var total int64
+	for _, key := range keys {
+		total += m.Files[key].Size
+	}
+	return total
+}
+
 // downloadOne downloads a single file atomically.
-func downloadOne(ctx context.Context, client storage.Backend, emuPath, key string, verbose bool) error {
+func downloadOne(ctx context.Context, client storage.Backend, emuPath, stagingDir, key string, entry manifest.FileEntry) error {
+	// Manifests are checked when parsed; this guards any other caller.
+	if err := manifest.ValidKey(key); err != nil {
+		return err
+	}
 	localPath := filepath.Join(emuPath, filepath.FromSlash(key))
 	tmpPath := localPath + tmpSuffix
-
-	if verbose {
-		log.Printf("downloading: %s", key)
+	if stagingDir != "" {
+		tmpPath = filepath.Join(stagingDir, stagingName(key))
 	}
 
+	logging.Printf(logging.Files, "downloading: %s", key)
+
 	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
 		return fmt.Errorf("mkdir for %s: %w", key, err)
 	}
 
-	if err := client.DownloadFile(ctx, key, tmpPath); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("download %s: %w", key, err)
+	sparse := false
+	if rd, ok := client.(storage.RangeDownloader); ok && len(entry.Zeros) > 0 {
+		err := downloadSparse(ctx, rd, key, entry, tmpPath)
+		if err == nil {
+			sparse = true
+		} else if ctx.Err() != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("download %s: %w", key, err)
+		} else {
+			logging.Printf(logging.Debug, "sparse download of %s failed, downloading whole file: %v", key, err)
+		}
+	}
+	if !sparse {
+		if err := client.DownloadFile(ctx, key, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("download %s: %w", key, err)
+		}
 	}
 
-	if err := os.Rename(tmpPath, localPath); err != nil {
+	if err := moveIntoPlace(tmpPath, localPath); err != nil {
 		os.Remove(tmpPath)
 		return fmt.Errorf("rename %s: %w", key, err)
 	}
@@ -360,16 +697,97 @@ func downloadOne(ctx context.Context, client storage.Backend, emuPath, key strin
 	return nil
 }
 
+// downloadSparse writes a file with entry.Zeros left as holes: the file is
+// extended to its full size without writing, and only the regions between
+// the zeros are fetched. Filesystems without sparse file support fill the
+// holes with zeros, so the content is the same either way.
+func downloadSparse(ctx context.Context, rd storage.RangeDownloader, key string, entry manifest.FileEntry, tmpPath string) error {
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(entry.Size); err != nil {
+		return err
+	}
+	var skipped int64
+	for _, z := range entry.Zeros {
+		skipped += z[1]
+	}
+	logging.Printf(logging.Debug, "sparse download: %s (skipping %s of zeros)", key, units.FormatSize(skipped))
+	for _, r := range entry.DataRanges() {
+		if err := rd.DownloadRange(ctx, key, r[0], r[1], io.NewOffsetWriter(f, r[0])); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// stagingName returns a flat, collision-free file name in staging_dir
+// for key.
+func stagingName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:12]) + "-" + path.Base(key) + tmpSuffix
+}
+
+// moveIntoPlace renames a downloaded file to its destination. When the
+// staging directory is on another volume, it copies instead: the old
+// file is removed first so the destination volume never holds both
+// copies, and the new one is synced before it takes the final name.
+func moveIntoPlace(tmpPath, localPath string) error {
+	err := os.Rename(tmpPath, localPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	logging.Printf(logging.Debug, "staging_dir is on another volume, copying: %s", localPath)
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	partial := localPath + tmpSuffix
+	if err := copyFileSync(tmpPath, partial); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, localPath); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Remove(tmpPath)
+}
+
+// copyFileSync copies src to dst and fsyncs dst.
+func copyFileSync(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // cleanTempFiles removes leftover .emu-sync-tmp files from interrupted syncs.
-func cleanTempFiles(basePath string, verbose bool) {
+func cleanTempFiles(basePath string) {
 	filepath.WalkDir(basePath, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
 		if !d.IsDir() && strings.HasSuffix(path, tmpSuffix) {
-			if verbose {
-				log.Printf("cleaning up temp file: %s", path)
-			}
+			logging.Printf(logging.Files, "cleaning up temp file: %s", path)
 			os.Remove(path)
 		}
 		return nil
@@ -379,18 +797,105 @@ func cleanTempFiles(basePath string, verbose bool) {
 // Summary returns a human-readable summary of the sync result.
 func (r *Result) Summary() string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "Downloaded: %d files\n", len(r.Downloaded))
-	fmt.Fprintf(&b, "Deleted: %d files\n", len(r.Deleted))
-	if len(r.Retained) > 0 {
-		fmt.Fprintf(&b, "Retained: %d files (deselected, delete disabled)\n", len(r.Retained))
+	r.writeWarnings(&b)
+	fmt.Fprintf(&b, "Downloaded: %d files (%s)\n", len(r.Downloaded), units.FormatSize(r.Bytes))
+	r.writeTiming(&b)
+	if len(r.Linked) > 0 {
+		fmt.Fprintf(&b, "Linked: %d files (identical to files already on this device)\n", len(r.Linked))
+	}
+	if len(r.Renamed) > 0 {
+		fmt.Fprintf(&b, "Renamed: %d files (moved in the library)\n", len(r.Renamed))
+	}
+	if r.Cost > 0 {
+		fmt.Fprintf(&b, "Estimated cost: %s\n", units.FormatCost(r.Cost))
+	}
+	if len(r.Deferred) > 0 {
+		fmt.Fprintf(&b, "Deferred: %d files (max duration reached, will continue next sync)\n", len(r.Deferred))
+	}
+	if len(r.Removed) > 0 && len(r.Removed) < len(r.Deleted) {
+		fmt.Fprintf(&b, "Deleted: %d files (%d removed from the library, %d deselected)\n", len(r.Deleted), len(r.Removed), len(r.Deleted)-len(r.Removed))
+	} else {
+		fmt.Fprintf(&b, "Deleted: %d files\n", len(r.Deleted))
+	}
+	if n := len(r.Retained) - len(r.Orphaned); n > 0 {
+		fmt.Fprintf(&b, "Retained: %d files (deselected, delete disabled)\n", n)
+	}
+	if len(r.Orphaned) > 0 {
+		fmt.Fprintf(&b, "Orphaned: %d files (removed from the library, kept on disk)\n", len(r.Orphaned))
 	}
 	fmt.Fprintf(&b, "Unchanged: %d files\n", r.Skipped)
+	if len(r.Overridden) > 0 {
+		fmt.Fprintf(&b, "Overrides applied: %d files (from overlay_dir)\n", len(r.Overridden))
+	}
+	r.writeProblems(&b)
+	fmt.Fprintf(&b, "Total: %d files\n", len(r.Downloaded)+len(r.Linked)+len(r.Renamed)+len(r.Conflicts)+r.Skipped)
+	return b.String()
+}
+
+// Problems is the part of Summary that needs attention: warnings, local
+// changes kept, and errors. It is empty after a clean sync.
+func (r *Result) Problems() string {
+	var b strings.Builder
+	r.writeWarnings(&b)
+	r.writeProblems(&b)
+	return b.String()
+}
+
+// Short returns the summary as a single line, for desktop notifications
+// and mail subjects, e.g. "Downloaded 12 files (3.1 GB), deleted 2 files".
+func (r *Result) Short() string {
+	var parts []string
+	if len(r.Downloaded) > 0 {
+		parts = append(parts, fmt.Sprintf("downloaded %d files (%s)", len(r.Downloaded), units.FormatSize(r.Bytes)))
+	}
+	if n := len(r.Linked) + len(r.Renamed); n > 0 {
+		parts = append(parts, fmt.Sprintf("linked or renamed %d files", n))
+	}
+	if len(r.Deleted) > 0 {
+		parts = append(parts, fmt.Sprintf("deleted %d files", len(r.Deleted)))
+	}
+	if len(r.Deferred) > 0 {
+		parts = append(parts, fmt.Sprintf("deferred %d files", len(r.Deferred)))
+	}
+	if len(r.Conflicts) > 0 {
+		parts = append(parts, fmt.Sprintf("kept %d local changes", len(r.Conflicts)))
+	}
 	if len(r.Errors) > 0 {
-		fmt.Fprintf(&b, "Errors: %d\n", len(r.Errors))
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	if len(r.Warnings) > 0 {
+		parts = append(parts, fmt.Sprintf("%d warnings", len(r.Warnings)))
+	}
+	return shortLine(parts, fmt.Sprintf("Up to date (%d files)", len(r.Downloaded)+len(r.Linked)+len(r.Renamed)+len(r.Conflicts)+r.Skipped))
+}
+
+// shortLine joins the parts of a Short summary into a sentence, or
+// returns none if there are no parts.
+func shortLine(parts []string, none string) string {
+	if len(parts) == 0 {
+		return none
+	}
+	line := strings.Join(parts, ", ")
+	return strings.ToUpper(line[:1]) + line[1:]
+}
+
+func (r *Result) writeWarnings(b *strings.Builder) {
+	for _, w := range r.Warnings {
+		fmt.Fprintf(b, "WARNING: %s\n\n", w)
+	}
+}
+
+func (r *Result) writeProblems(b *strings.Builder) {
+	if len(r.Conflicts) > 0 {
+		fmt.Fprintf(b, "Kept local changes: %d files\n", len(r.Conflicts))
+		for _, key := range r.Conflicts {
+			fmt.Fprintf(b, "  %s %s\n", output.Marker("~"), key)
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(b, "Errors: %d\n", len(r.Errors))
 		for _, err := range r.Errors {
-			fmt.Fprintf(&b, "  - %v\n", err)
+			fmt.Fprintf(b, "  - %v\n", err)
 		}
 	}
-	fmt.Fprintf(&b, "Total: %d files\n", len(r.Downloaded)+r.Skipped)
-	return b.String()
 }
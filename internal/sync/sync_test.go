@@ -3,14 +3,19 @@ package sync
 import (
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
 )
 
@@ -189,6 +194,143 @@ func TestSyncDryRun(t *testing.T) {
 	}
 }
 
+func TestSyncDryRunReportsProgress(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game.sfc": {content: "data", size: 4},
+	})
+	local := manifest.New()
+	local.Files["roms/snes/Old.sfc"] = manifest.FileEntry{MD5: "x", Size: 3}
+	local.SaveJSON(manifestPath)
+	os.MkdirAll(filepath.Join(emuDir, "roms/snes"), 0o755)
+	os.WriteFile(filepath.Join(emuDir, "roms/snes/Old.sfc"), []byte("old"), 0o644)
+
+	for _, del := range []bool{true, false} {
+		cfg := testConfig(emuDir)
+		cfg.Sync.Delete = del
+		var events strings.Builder
+		result, err := Run(context.Background(), mock, cfg, Options{
+			LocalManifestPath: manifestPath,
+			DryRun:            true,
+			Progress:          progress.NewReporterWriter(&events),
+		})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+
+		out := events.String()
+		if !strings.Contains(out, `{"event":"would_download","file":"roms/snes/Game.sfc","size":4}`) {
+			t.Errorf("delete=%v: no would_download event in:\n%s", del, out)
+		}
+		wantDelete := `{"event":"would_delete","file":"roms/snes/Old.sfc"}`
+		wantRetain := `{"event":"retain","file":"roms/snes/Old.sfc"}`
+		if del && (!strings.Contains(out, wantDelete) || len(result.Deleted) != 1) {
+			t.Errorf("delete=true: want a would_delete event and 1 deleted, got %v:\n%s", result.Deleted, out)
+		}
+		if !del && (!strings.Contains(out, wantRetain) || len(result.Retained) != 1 || len(result.Deleted) != 0) {
+			t.Errorf("delete=false: want a retain event and 1 retained, got %v / %v:\n%s", result.Retained, result.Deleted, out)
+		}
+		if _, err := os.Stat(filepath.Join(emuDir, "roms/snes/Old.sfc")); err != nil {
+			t.Errorf("delete=%v: dry run removed a file: %v", del, err)
+		}
+	}
+}
+
+func TestSyncDeleteSettingsSplit(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game.sfc": {content: "data", size: 4},
+		"roms/gba/Kept.gba":  {content: "kept", size: 4},
+	})
+	local := manifest.New()
+	for _, key := range []string{"roms/snes/Game.sfc", "roms/gba/Kept.gba", "roms/gba/Gone.gba"} {
+		local.Files[key] = manifest.FileEntry{MD5: md5hex(key), Size: int64(len(key))}
+		os.MkdirAll(filepath.Join(emuDir, filepath.Dir(key)), 0o755)
+		os.WriteFile(filepath.Join(emuDir, key), []byte(key), 0o644)
+	}
+	local.SaveJSON(manifestPath)
+
+	// gba is deselected: keep what's still in the library, but follow
+	// upstream removals.
+	cfg := testConfig(emuDir)
+	cfg.Sync.SyncExclude = []string{"roms/gba"}
+	off := false
+	cfg.Sync.DeleteDeselect = &off
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(result.Deleted) != 1 || len(result.Removed) != 1 || result.Removed[0] != "roms/gba/Gone.gba" {
+		t.Errorf("deleted %v (removed %v), want Gone.gba removed from the library", result.Deleted, result.Removed)
+	}
+	if len(result.Retained) != 1 || result.Retained[0] != "roms/gba/Kept.gba" || len(result.Orphaned) != 0 {
+		t.Errorf("retained %v (orphaned %v), want deselected Kept.gba", result.Retained, result.Orphaned)
+	}
+	if _, err := os.Stat(filepath.Join(emuDir, "roms/gba/Kept.gba")); err != nil {
+		t.Errorf("deselected file deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(emuDir, "roms/gba/Gone.gba")); !os.IsNotExist(err) {
+		t.Error("file removed from the library still on disk")
+	}
+}
+
+func TestSyncMatchesUnicodeNormalization(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+	nfd := "roms/snes/\u30ab\u3099.sfc" // ガ decomposed, as uploaded from macOS
+	nfc := "roms/snes/\u30ac.sfc"
+
+	// The library was uploaded again with NFC names; the device has the
+	// file under its old NFD name.
+	mock := mockWithManifest(t, map[string]mockFile{nfc: {content: "game", size: 4}})
+	local := manifest.New()
+	local.Files[nfd] = manifest.FileEntry{MD5: md5hex("game"), Size: 4}
+	local.SaveJSON(manifestPath)
+	os.MkdirAll(filepath.Join(emuDir, "roms/snes"), 0o755)
+	os.WriteFile(filepath.Join(emuDir, nfd), []byte("game"), 0o644)
+
+	result, err := Run(context.Background(), mock, testConfig(emuDir), Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Downloaded) != 0 || len(result.Deleted) != 0 {
+		t.Errorf("downloaded %v and deleted %v, want the file recognized under its new name", result.Downloaded, result.Deleted)
+	}
+	assertFileContent(t, filepath.Join(emuDir, nfc), "game")
+
+	saved, _ := manifest.LoadJSON(manifestPath)
+	if _, ok := saved.Files[nfc]; !ok || len(saved.Files) != 1 {
+		t.Errorf("local manifest keys = %v, want only the NFC key", saved.Files)
+	}
+}
+
+func TestSyncRejectsKeysOutsideEmulationPath(t *testing.T) {
+	parent := t.TempDir()
+	emuDir := filepath.Join(parent, "Emulation")
+	os.MkdirAll(emuDir, 0o755)
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game.sfc": {content: "game", size: 4},
+		"roms/../../evil":    {content: "evil", size: 4},
+	})
+
+	_, err := Run(context.Background(), mock, testConfig(emuDir), Options{LocalManifestPath: filepath.Join(t.TempDir(), "local.json")})
+	if err == nil {
+		t.Fatal("Run should refuse a manifest with a key outside the emulation path")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "evil")); !os.IsNotExist(err) {
+		t.Error("file written outside the emulation path")
+	}
+
+	if err := downloadOne(context.Background(), mock, emuDir, "", "../evil", manifest.FileEntry{Size: 4}); err == nil {
+		t.Error("downloadOne should refuse an unsafe key")
+	}
+}
+
 func TestSyncFiltersBySyncDirs(t *testing.T) {
 	emuDir := t.TempDir()
 	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
@@ -411,17 +553,52 @@ func TestSyncModifiedAndMissingNotDuplicated(t *testing.T) {
 	assertFileContent(t, filepath.Join(emuDir, "roms/snes/Game.sfc"), "v2 data updated")
 }
 
+func TestSyncDownloadsQueuedBeforeMissing(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Old.sfc": {content: "old", size: 3},
+	})
+	cfg := testConfig(emuDir)
+
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// Remove the synced file and add a new one remotely
+	os.Remove(filepath.Join(emuDir, "roms/snes/Old.sfc"))
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Old.sfc": {content: "old", size: 3},
+		"roms/snes/New.sfc": {content: "new", size: 3},
+	})
+
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(result.Downloaded) != 2 {
+		t.Fatalf("downloaded %d, want 2", len(result.Downloaded))
+	}
+	// New files start without waiting for the missing-from-disk scan
+	if result.Downloaded[0] != "roms/snes/New.sfc" || result.Downloaded[1] != "roms/snes/Old.sfc" {
+		t.Errorf("download order = %v, want new file before missing file", result.Downloaded)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/snes/Old.sfc"), "old")
+	assertFileContent(t, filepath.Join(emuDir, "roms/snes/New.sfc"), "new")
+}
+
 func TestSyncLockPreventsOverlap(t *testing.T) {
 	// Acquire the lock directly to simulate another sync in progress
-	lock, err := acquireLock()
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+	lock, err := acquireLock(manifestPath)
 	if err != nil {
 		t.Fatalf("acquireLock: %v", err)
 	}
 	defer releaseLock(lock)
 
-	emuDir := t.TempDir()
-	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
-
 	mock := mockWithManifest(t, map[string]mockFile{
 		"roms/snes/Game.sfc": {content: "data", size: 4},
 	})
@@ -436,16 +613,39 @@ func TestSyncLockPreventsOverlap(t *testing.T) {
 	}
 }
 
-func TestSyncLockSkippedForDryRun(t *testing.T) {
-	// Hold the lock — dry-run should still succeed
-	lock, err := acquireLock()
+func TestSyncLockPerManifest(t *testing.T) {
+	// A sync into another emulation path (with its own local manifest)
+	// doesn't block this one.
+	other, err := acquireLock(filepath.Join(t.TempDir(), "local-manifest.json"))
 	if err != nil {
 		t.Fatalf("acquireLock: %v", err)
 	}
-	defer releaseLock(lock)
+	defer releaseLock(other)
 
+	emuDir := t.TempDir()
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game.sfc": {content: "data", size: 4},
+	})
+	result, err := Run(context.Background(), mock, testConfig(emuDir), Options{
+		LocalManifestPath: filepath.Join(t.TempDir(), "local-manifest.json"),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Downloaded) != 1 {
+		t.Errorf("downloaded %d, want 1", len(result.Downloaded))
+	}
+}
+
+func TestSyncLockSkippedForDryRun(t *testing.T) {
+	// Hold the lock — dry-run should still succeed
 	emuDir := t.TempDir()
 	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+	lock, err := acquireLock(manifestPath)
+	if err != nil {
+		t.Fatalf("acquireLock: %v", err)
+	}
+	defer releaseLock(lock)
 
 	mock := mockWithManifest(t, map[string]mockFile{
 		"roms/snes/Game.sfc": {content: "data", size: 4},
@@ -630,6 +830,686 @@ func TestSyncFiltersBySyncExclude(t *testing.T) {
 	}
 }
 
+func TestSyncDeleteThresholdSkipsMassDelete(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game1.sfc": {content: "game1", size: 5},
+		"roms/snes/Game2.sfc": {content: "game2", size: 5},
+		"roms/snes/Game3.sfc": {content: "game3", size: 5},
+	})
+
+	cfg := testConfig(emuDir)
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// Bucket accidentally emptied
+	mock = mockWithManifest(t, map[string]mockFile{})
+
+	result, err := Run(context.Background(), mock, cfg, Options{
+		LocalManifestPath: manifestPath,
+		DeleteThreshold:   0.5,
+	})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(result.Deleted) != 0 {
+		t.Errorf("deleted %d, want 0 above threshold", len(result.Deleted))
+	}
+	if len(result.Retained) != 3 {
+		t.Errorf("retained %d, want 3", len(result.Retained))
+	}
+	if len(result.Warnings) != 1 {
+		t.Fatalf("warnings = %d, want 1", len(result.Warnings))
+	}
+	if !strings.Contains(result.Summary(), "WARNING") {
+		t.Error("summary should include the warning")
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/snes/Game1.sfc"), "game1")
+}
+
+func TestResultProblems(t *testing.T) {
+	clean := &Result{Downloaded: []string{"roms/a.sfc"}, Skipped: 3}
+	if got := clean.Problems(); got != "" {
+		t.Errorf("Problems() for a clean sync = %q, want empty", got)
+	}
+
+	r := &Result{
+		Warnings:  []string{"deletions skipped"},
+		Conflicts: []string{"roms/b.sfc"},
+		Skipped:   3,
+	}
+	got := r.Problems()
+	for _, want := range []string{"WARNING: deletions skipped", "Kept local changes: 1 files", "roms/b.sfc"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Problems() missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Unchanged") {
+		t.Errorf("Problems() includes counts:\n%s", got)
+	}
+}
+
+func TestSyncAdded(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+	cfg := testConfig(emuDir)
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game1.sfc": {content: "game1", size: 5},
+	})
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// An updated file was already on this device; only the new one is added
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game1.sfc": {content: "game1 v2", size: 8},
+		"roms/gba/Game2.gba":  {content: "game2", size: 5},
+	})
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Downloaded) != 2 || !slices.Equal(result.Added, []string{"roms/gba/Game2.gba"}) {
+		t.Errorf("Downloaded = %v, Added = %v, want only Game2 added", result.Downloaded, result.Added)
+	}
+}
+
+func TestSyncPolicyHidesFiles(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game1.sfc": {content: "game1", size: 5},
+		"roms/n64/Game2.z64":  {content: "game2", size: 5},
+	})
+
+	cfg := testConfig(emuDir)
+	cfg.Policy.DenySystems = []string{"n64"}
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Downloaded) != 1 || result.Downloaded[0] != "roms/snes/Game1.sfc" {
+		t.Errorf("Downloaded = %v, want only the allowed system", result.Downloaded)
+	}
+	if _, err := os.Stat(filepath.Join(emuDir, "roms/n64/Game2.z64")); !os.IsNotExist(err) {
+		t.Errorf("hidden file was synced (stat err %v)", err)
+	}
+}
+
+func TestResultShort(t *testing.T) {
+	tests := []struct {
+		r    *Result
+		want string
+	}{
+		{&Result{Skipped: 7}, "Up to date (7 files)"},
+		{&Result{Downloaded: []string{"a", "b"}, Bytes: 10, Skipped: 5}, "Downloaded 2 files (10 B)"},
+		{
+			&Result{Deleted: []string{"a"}, Errors: []error{errors.New("x")}, Warnings: []string{"w"}},
+			"Deleted 1 files, 1 errors, 1 warnings",
+		},
+	}
+	for _, tt := range tests {
+		got := tt.r.Short()
+		if got != tt.want {
+			t.Errorf("Short() = %q, want %q", got, tt.want)
+		}
+		if strings.Contains(got, "\n") {
+			t.Errorf("Short() = %q spans lines", got)
+		}
+	}
+}
+
+func TestSyncDeleteThresholdIgnoresDeselected(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game1.sfc": {content: "game1", size: 5},
+		"roms/gba/Game2.gba":  {content: "game2", size: 5},
+	})
+
+	cfg := testConfig(emuDir)
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// Deselecting a system is not a mass deletion from the bucket
+	cfg.Sync.SyncExclude = []string{"roms/gba"}
+	result, err := Run(context.Background(), mock, cfg, Options{
+		LocalManifestPath: manifestPath,
+		DeleteThreshold:   0.1,
+	})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(result.Deleted) != 1 {
+		t.Errorf("deleted %d, want 1", len(result.Deleted))
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("warnings = %v, want none", result.Warnings)
+	}
+}
+
+func TestSyncMaxDurationDefersRemaining(t *testing.T) {
+	for _, workers := range []int{1, 2} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			emuDir := t.TempDir()
+			manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+			mock := mockWithManifest(t, map[string]mockFile{
+				"roms/snes/Game1.sfc": {content: "game1", size: 5},
+				"roms/snes/Game2.sfc": {content: "game2", size: 5},
+			})
+
+			cfg := testConfig(emuDir)
+			result, err := Run(context.Background(), mock, cfg, Options{
+				LocalManifestPath: manifestPath,
+				Workers:           workers,
+				MaxDuration:       time.Nanosecond,
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if len(result.Deferred) != 2 {
+				t.Errorf("deferred %d, want 2", len(result.Deferred))
+			}
+			if len(result.Downloaded) != 0 {
+				t.Errorf("downloaded %d, want 0", len(result.Downloaded))
+			}
+			if !strings.Contains(result.Summary(), "Deferred: 2 files") {
+				t.Errorf("summary missing deferred count:\n%s", result.Summary())
+			}
+
+			// Next run without a deadline picks up where it left off
+			result, err = Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+			if err != nil {
+				t.Fatalf("second Run: %v", err)
+			}
+			if len(result.Downloaded) != 2 {
+				t.Errorf("second run downloaded %d, want 2", len(result.Downloaded))
+			}
+		})
+	}
+}
+
+func TestSyncTuningOverridesRetries(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/ps2/Game.iso":  {content: "ps2", size: 3},
+		"roms/snes/Game.sfc": {content: "snes", size: 4},
+		"bios/bios.bin":      {content: "bios", size: 4},
+	})
+	mock.DownloadErrors["roms/ps2/Game.iso"] = fmt.Errorf("simulated download error")
+	mock.DownloadErrors["roms/snes/Game.sfc"] = fmt.Errorf("simulated download error")
+
+	cfg := testConfig(emuDir)
+	cfg.Sync.Tuning = map[string]config.TuningConfig{
+		"roms/ps2": {MaxRetries: 1},
+	}
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(result.Errors) != 2 {
+		t.Errorf("errors = %d, want 2", len(result.Errors))
+	}
+	assertFileContent(t, filepath.Join(emuDir, "bios/bios.bin"), "bios")
+
+	calls := make(map[string]int)
+	for _, c := range mock.Calls {
+		calls[c]++
+	}
+	if got := calls["DownloadFile:roms/ps2/Game.iso"]; got != 2 {
+		t.Errorf("roms/ps2 attempts = %d, want 2 (tuned max_retries=1)", got)
+	}
+	if got := calls["DownloadFile:roms/snes/Game.sfc"]; got != 1 {
+		t.Errorf("roms/snes attempts = %d, want 1 (default max_retries=0)", got)
+	}
+}
+
+func TestSyncStagingDir(t *testing.T) {
+	emuDir := t.TempDir()
+	stagingDir := filepath.Join(t.TempDir(), "staging")
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/snes/Game.sfc": {content: "snes rom data", size: 13},
+		"roms/gba/Game.gba":  {content: "gba rom", size: 7},
+	})
+
+	cfg := testConfig(emuDir)
+	cfg.Sync.StagingDir = stagingDir
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Downloaded) != 2 {
+		t.Errorf("downloaded %d, want 2", len(result.Downloaded))
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/snes/Game.sfc"), "snes rom data")
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "gba rom")
+
+	entries, _ := os.ReadDir(stagingDir)
+	if len(entries) != 0 {
+		t.Errorf("staging_dir not emptied: %d entries left", len(entries))
+	}
+}
+
+func TestSyncLinksDuplicateContent(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	// First sync: one copy of the game
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/psx/Game.bin": {content: "disc image", size: 10},
+	})
+	cfg := testConfig(emuDir)
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// The library adds the same image under another name, plus two new
+	// identical files
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/psx/Game.bin":        {content: "disc image", size: 10},
+		"roms/psx/Game (Copy).bin": {content: "disc image", size: 10},
+		"roms/gba/A.gba":           {content: "same rom", size: 8},
+		"roms/gba/B.gba":           {content: "same rom", size: 8},
+	})
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(result.Linked) != 2 || len(result.Downloaded) != 1 {
+		t.Errorf("linked %v, downloaded %v; want 2 linked and 1 downloaded", result.Linked, result.Downloaded)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/psx/Game (Copy).bin"), "disc image")
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/A.gba"), "same rom")
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/B.gba"), "same rom")
+	for _, call := range mock.Calls {
+		if call == "DownloadFile:roms/psx/Game (Copy).bin" {
+			t.Error("duplicate of a local file was downloaded")
+		}
+	}
+
+	local, _ := manifest.LoadJSON(manifestPath)
+	if len(local.Files) != 4 {
+		t.Errorf("local manifest has %d entries, want 4", len(local.Files))
+	}
+}
+
+func TestSyncDedupeDisabled(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/gba/A.gba": {content: "same rom", size: 8},
+		"roms/gba/B.gba": {content: "same rom", size: 8},
+	})
+	cfg := testConfig(emuDir)
+	off := false
+	cfg.Sync.Dedupe = &off
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Linked) != 0 || len(result.Downloaded) != 2 {
+		t.Errorf("linked %v, downloaded %v; want both downloaded", result.Linked, result.Downloaded)
+	}
+}
+
+func TestSyncRenamesMovedFiles(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/psx/Game.chd":  {content: "disc image", size: 10},
+		"roms/psx/Other.chd": {content: "other disc", size: 10},
+	})
+	cfg := testConfig(emuDir)
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// The curator moves one game to another folder
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/ps1/Game.chd":  {content: "disc image", size: 10},
+		"roms/psx/Other.chd": {content: "other disc", size: 10},
+	})
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(result.Renamed) != 1 || len(result.Downloaded) != 0 || len(result.Deleted) != 0 {
+		t.Errorf("renamed %v, downloaded %v, deleted %v; want only the rename", result.Renamed, result.Downloaded, result.Deleted)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/ps1/Game.chd"), "disc image")
+	if _, err := os.Stat(filepath.Join(emuDir, "roms/psx/Game.chd")); !os.IsNotExist(err) {
+		t.Error("old path should be gone after the rename")
+	}
+	local, _ := manifest.LoadJSON(manifestPath)
+	if _, ok := local.Files["roms/psx/Game.chd"]; ok {
+		t.Error("old key still in the local manifest")
+	}
+	if _, ok := local.Files["roms/ps1/Game.chd"]; !ok {
+		t.Error("new key missing from the local manifest")
+	}
+}
+
+func TestSyncMovedFileKeptWhenDeleteDisabled(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/psx/Game.chd": {content: "disc image", size: 10},
+	})
+	cfg := testConfig(emuDir)
+	cfg.Sync.Delete = false
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/ps1/Game.chd": {content: "disc image", size: 10},
+	})
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Renamed) != 0 {
+		t.Errorf("renamed %v with delete disabled", result.Renamed)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/psx/Game.chd"), "disc image")
+	assertFileContent(t, filepath.Join(emuDir, "roms/ps1/Game.chd"), "disc image")
+}
+
+func TestSyncSparseDownload(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	content := "AB" + strings.Repeat("\x00", 10) + "C"
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/ps2/Game.iso"] = []byte(content)
+	m := manifest.New()
+	m.Files["roms/ps2/Game.iso"] = manifest.FileEntry{
+		Size:  int64(len(content)),
+		MD5:   md5hex(content),
+		Zeros: [][2]int64{{2, 10}},
+	}
+	data, _ := m.ToJSON()
+	mock.Objects[storage.ManifestKey] = data
+
+	result, err := Run(context.Background(), mock, testConfig(emuDir), Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Downloaded) != 1 {
+		t.Fatalf("downloaded %v, want Game.iso", result.Downloaded)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms", "ps2", "Game.iso"), content)
+
+	ranges := 0
+	for _, call := range mock.Calls {
+		if call == "DownloadFile:roms/ps2/Game.iso" {
+			t.Error("sparse file was downloaded whole")
+		}
+		if call == "DownloadRange:roms/ps2/Game.iso" {
+			ranges++
+		}
+	}
+	if ranges != 2 {
+		t.Errorf("%d range downloads, want 2 (before and after the zeros)", ranges)
+	}
+}
+
+func TestSyncSparseDownloadFallsBack(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	// The object is shorter than the manifest says, so the range past the
+	// zeros can't be read and the whole object is downloaded instead
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/ps2/Game.iso"] = []byte("AB")
+	m := manifest.New()
+	m.Files["roms/ps2/Game.iso"] = manifest.FileEntry{Size: 13, MD5: "x", Zeros: [][2]int64{{2, 10}}}
+	data, _ := m.ToJSON()
+	mock.Objects[storage.ManifestKey] = data
+
+	result, err := Run(context.Background(), mock, testConfig(emuDir), Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Fatalf("errors: %v", result.Errors)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms", "ps2", "Game.iso"), "AB")
+}
+
+func TestSyncKeepsLocallyModifiedFile(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/gba/Game.gba": {content: "v1", size: 2},
+	})
+	cfg := testConfig(emuDir)
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// The user patches the ROM, and the curator uploads a new version
+	writeFile(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "patched")
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/gba/Game.gba": {content: "v2", size: 2},
+	})
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Conflicts) != 1 || len(result.Downloaded) != 0 {
+		t.Errorf("conflicts %v, downloaded %v; want the file kept", result.Conflicts, result.Downloaded)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Summary(), "Kept local changes: 1 files") {
+		t.Errorf("conflict not reported:\n%s", result.Summary())
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "patched")
+
+	result, err = Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath, OverwriteModified: true})
+	if err != nil {
+		t.Fatalf("third Run: %v", err)
+	}
+	if len(result.Downloaded) != 1 || len(result.Conflicts) != 0 {
+		t.Errorf("downloaded %v, conflicts %v; want the file replaced", result.Downloaded, result.Conflicts)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "v2")
+}
+
+func TestSyncModifiedFileMatchingNewVersion(t *testing.T) {
+	emuDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/gba/Game.gba": {content: "v1", size: 2},
+	})
+	cfg := testConfig(emuDir)
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// The user already applied the same update the curator uploads
+	writeFile(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "v2")
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/gba/Game.gba": {content: "v2", size: 2},
+	})
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Conflicts) != 0 || len(result.Downloaded) != 0 || result.Skipped != 1 {
+		t.Errorf("conflicts %v, downloaded %v, skipped %d; want it treated as up to date", result.Conflicts, result.Downloaded, result.Skipped)
+	}
+	local, _ := manifest.LoadJSON(manifestPath)
+	if local.Files["roms/gba/Game.gba"].MD5 != md5hex("v2") {
+		t.Error("local manifest should record the new version")
+	}
+}
+
+func TestSyncOverlayShadowsLibraryFile(t *testing.T) {
+	emuDir := t.TempDir()
+	overlayDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "local-manifest.json")
+
+	mock := mockWithManifest(t, map[string]mockFile{
+		"roms/gba/Game.gba":  {content: "v1", size: 2},
+		"roms/gba/Other.gba": {content: "other", size: 5},
+	})
+	cfg := testConfig(emuDir)
+	cfg.Sync.OverlayDir = overlayDir
+	writeFile(t, filepath.Join(overlayDir, "roms/gba/Game.gba"), "romhack")
+
+	result, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if len(result.Downloaded) != 1 || len(result.Overridden) != 1 {
+		t.Errorf("downloaded %v, overridden %v; want Other.gba downloaded and Game.gba overridden", result.Downloaded, result.Overridden)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "romhack")
+
+	// A library update doesn't touch the override, and an applied
+	// override isn't copied again
+	mock = mockWithManifest(t, map[string]mockFile{
+		"roms/gba/Game.gba":  {content: "v2", size: 2},
+		"roms/gba/Other.gba": {content: "other", size: 5},
+	})
+	result, err = Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath})
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Downloaded) != 0 || len(result.Overridden) != 0 || len(result.Conflicts) != 0 {
+		t.Errorf("downloaded %v, overridden %v, conflicts %v; want nothing to do", result.Downloaded, result.Overridden, result.Conflicts)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "romhack")
+
+	vr, err := Verify(context.Background(), cfg, manifestPath, nil)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(vr.Overridden) != 1 || len(vr.Mismatch) != 0 {
+		t.Errorf("verify overridden %v, mismatch %v; want the override recognized", vr.Overridden, vr.Mismatch)
+	}
+
+	// Removing the override restores the library version
+	os.Remove(filepath.Join(overlayDir, "roms/gba/Game.gba"))
+	if _, err := Run(context.Background(), mock, cfg, Options{LocalManifestPath: manifestPath}); err != nil {
+		t.Fatalf("third Run: %v", err)
+	}
+	assertFileContent(t, filepath.Join(emuDir, "roms/gba/Game.gba"), "v2")
+}
+
+func TestCopyFileSync(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	os.WriteFile(src, []byte("data"), 0o644)
+
+	if err := copyFileSync(src, dst); err != nil {
+		t.Fatalf("copyFileSync: %v", err)
+	}
+	assertFileContent(t, dst, "data")
+}
+
+func TestMoveIntoPlaceAcrossVolumes(t *testing.T) {
+	staging, err := os.MkdirTemp("/dev/shm", "emu-sync-test")
+	if err != nil {
+		t.Skip("no /dev/shm")
+	}
+	defer os.RemoveAll(staging)
+	tmp := filepath.Join(staging, "Game.sfc"+tmpSuffix)
+	os.WriteFile(tmp, []byte("new"), 0o644)
+	dst := filepath.Join(t.TempDir(), "Game.sfc")
+	if err := os.Link(tmp, dst); !errors.Is(err, syscall.EXDEV) {
+		t.Skip("/dev/shm is on the same volume as the temp dir")
+	}
+	os.WriteFile(dst, []byte("old"), 0o644)
+
+	if err := moveIntoPlace(tmp, dst); err != nil {
+		t.Fatalf("moveIntoPlace: %v", err)
+	}
+	assertFileContent(t, dst, "new")
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("staged file should be removed after copying")
+	}
+	if _, err := os.Stat(dst + tmpSuffix); !os.IsNotExist(err) {
+		t.Error("partial copy left next to destination")
+	}
+}
+
+func TestColdStorageWarning(t *testing.T) {
+	remote := manifest.New()
+	remote.Files["roms/snes/A.sfc"] = manifest.FileEntry{Size: 1024}
+	remote.Files["roms/ps2/B.iso"] = manifest.FileEntry{Size: 2048, StorageClass: "GLACIER_IR"}
+	remote.Files["roms/ps2/C.iso"] = manifest.FileEntry{Size: 2048, StorageClass: "GLACIER_IR"}
+	remote.Files["roms/ps3/D.iso"] = manifest.FileEntry{Size: 4096, StorageClass: "DEEP_ARCHIVE"}
+
+	if msg := coldStorageWarning(remote, []string{"roms/snes/A.sfc"}); msg != "" {
+		t.Errorf("standard files: got warning %q", msg)
+	}
+
+	msg := coldStorageWarning(remote, []string{"roms/snes/A.sfc", "roms/ps2/B.iso", "roms/ps2/C.iso"})
+	if !strings.Contains(msg, "2 files") || !strings.Contains(msg, "GLACIER_IR: 2") {
+		t.Errorf("warning = %q, want 2 GLACIER_IR files", msg)
+	}
+	if strings.Contains(msg, "restored") {
+		t.Errorf("GLACIER_IR needs no restore: %q", msg)
+	}
+
+	msg = coldStorageWarning(remote, []string{"roms/ps3/D.iso"})
+	if !strings.Contains(msg, "DEEP_ARCHIVE: 1") || !strings.Contains(msg, "restored") {
+		t.Errorf("warning = %q, want DEEP_ARCHIVE restore note", msg)
+	}
+}
+
+func BenchmarkSyncParallelDownloads(b *testing.B) {
+	files := make(map[string]mockFile)
+	for i := range 32 {
+		files[fmt.Sprintf("roms/snes/Game%02d.sfc", i)] = mockFile{content: strings.Repeat("x", 64*1024), size: 64 * 1024}
+	}
+
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				mock := mockWithManifest(b, files)
+				mock.Latency = 2 * time.Millisecond
+				mock.Bandwidth = 32 << 20
+				emuDir := b.TempDir()
+				b.StartTimer()
+
+				_, err := Run(context.Background(), mock, testConfig(emuDir), Options{
+					LocalManifestPath: filepath.Join(emuDir, "local-manifest.json"),
+					Workers:           workers,
+				})
+				if err != nil {
+					b.Fatalf("Run: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // --- helpers ---
 
 type mockFile struct {
@@ -637,7 +1517,7 @@ type mockFile struct {
 	size    int64
 }
 
-func mockWithManifest(t *testing.T, files map[string]mockFile) *storage.MockBackend {
+func mockWithManifest(t testing.TB, files map[string]mockFile) *storage.MockBackend {
 	t.Helper()
 	mock := storage.NewMockBackend()
 
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/units"
+)
+
+// Transfer is how long one file took to download. Comparing files' speeds
+// with the run's aggregate throughput helps tell whether the network or
+// the device's storage is the bottleneck.
+type Transfer struct {
+	Key     string  `json:"key"`
+	Size    int64   `json:"size"`
+	Seconds float64 `json:"seconds"` // including retries
+}
+
+// Speed returns the transfer's speed in bytes per second.
+func (t Transfer) Speed() float64 {
+	if t.Seconds <= 0 {
+		return 0
+	}
+	return float64(t.Size) / t.Seconds
+}
+
+func (t Transfer) String() string {
+	return fmt.Sprintf("%s (%s in %s, %s/s)", t.Key, units.FormatSize(t.Size),
+		formatSeconds(t.Seconds), units.FormatSize(int64(t.Speed())))
+}
+
+// slowestShown is how many of the slowest files Summary lists.
+const slowestShown = 3
+
+func (r *Result) addTransfer(key string, size int64, d time.Duration) {
+	r.Transfers = append(r.Transfers, Transfer{Key: key, Size: size, Seconds: d.Seconds()})
+}
+
+// Throughput returns the bytes downloaded per second of wall time spent
+// downloading, across all workers, or 0 if nothing was downloaded.
+func (r *Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Elapsed.Seconds()
+}
+
+// Slowest returns up to n transfers that took longest, longest first.
+func (r *Result) Slowest(n int) []Transfer {
+	sorted := append([]Transfer(nil), r.Transfers...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Seconds > sorted[j].Seconds })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func (r *Result) writeTiming(b *strings.Builder) {
+	if r.Bytes == 0 || r.Elapsed <= 0 {
+		return
+	}
+	fmt.Fprintf(b, "Throughput: %s/s (%s in %s)\n", units.FormatSize(int64(r.Throughput())),
+		units.FormatSize(r.Bytes), formatSeconds(r.Elapsed.Seconds()))
+	if len(r.Transfers) < 2 {
+		return
+	}
+	b.WriteString("Slowest:\n")
+	for _, t := range r.Slowest(slowestShown) {
+		fmt.Fprintf(b, "  %s\n", t)
+	}
+}
+
+// formatSeconds formats s like a time.Duration rounded to a tenth of a
+// second, e.g. "1m2.5s".
+func formatSeconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultTiming(t *testing.T) {
+	r := &Result{Bytes: 30 << 20, Elapsed: 10 * time.Second}
+	r.addTransfer("roms/a.iso", 20<<20, 8*time.Second)
+	r.addTransfer("roms/b.iso", 8<<20, 2*time.Second)
+	r.addTransfer("roms/c.sfc", 2<<20, 250*time.Millisecond)
+
+	if got := r.Throughput(); got != 3<<20 {
+		t.Errorf("Throughput = %v, want %v", got, 3<<20)
+	}
+	slowest := r.Slowest(2)
+	if len(slowest) != 2 || slowest[0].Key != "roms/a.iso" || slowest[1].Key != "roms/b.iso" {
+		t.Errorf("Slowest(2) = %v", slowest)
+	}
+	if got := slowest[0].Speed(); got != 2.5*(1<<20) {
+		t.Errorf("Speed = %v", got)
+	}
+
+	var b strings.Builder
+	r.writeTiming(&b)
+	want := "Throughput: 3.0 MiB/s (30 MiB in 10s)\n" +
+		"Slowest:\n" +
+		"  roms/a.iso (20 MiB in 8s, 2.5 MiB/s)\n" +
+		"  roms/b.iso (8.0 MiB in 2s, 4.0 MiB/s)\n" +
+		"  roms/c.sfc (2.0 MiB in 300ms, 8.0 MiB/s)\n"
+	if b.String() != want {
+		t.Errorf("timing =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestResultTimingNothingDownloaded(t *testing.T) {
+	r := &Result{}
+	var b strings.Builder
+	r.writeTiming(&b)
+	if b.Len() != 0 || r.Throughput() != 0 {
+		t.Errorf("no downloads should report no timing, got %q", b.String())
+	}
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+// matchNormalization moves local entries whose key differs from a remote
+// key only in Unicode normalization (see manifest.NormalizeKey) to the
+// remote key, renaming the file on disk to match. That happens when a
+// library uploaded with decomposed names from macOS is uploaded again
+// in NFC. Otherwise the file would look added under one name and
+// deleted under the other, and on filesystems that ignore normalization
+// (macOS) deleting the old name would delete the new download. Dry
+// runs only move the entries.
+func matchNormalization(emuPath string, remote, local *manifest.Manifest, dryRun bool) {
+	// Index the few local keys missing from remote rather than every
+	// remote key, which on a large library is a second copy of its names.
+	byNormal := make(map[string]string)
+	for key := range local.Files {
+		if _, ok := remote.Files[key]; !ok {
+			byNormal[manifest.NormalizeKey(key)] = key
+		}
+	}
+	if len(byNormal) == 0 {
+		return
+	}
+	for remoteKey := range remote.Files {
+		normal := manifest.NormalizeKey(remoteKey)
+		key, ok := byNormal[normal]
+		if !ok {
+			continue
+		}
+		if _, ok := local.Files[remoteKey]; ok {
+			continue
+		}
+		entry := local.Files[key]
+		src := filepath.Join(emuPath, filepath.FromSlash(key))
+		dst := filepath.Join(emuPath, filepath.FromSlash(remoteKey))
+		if srcInfo, err := os.Lstat(src); err == nil && !dryRun {
+			// On filesystems that ignore normalization src and dst are
+			// the same file and the rename is a no-op; elsewhere a
+			// different file already at dst is left alone.
+			if dstInfo, err := os.Lstat(dst); err == nil && !os.SameFile(srcInfo, dstInfo) {
+				continue
+			}
+			if err := os.Rename(src, dst); err != nil {
+				logging.Printf(logging.Debug, "can't rename %s to its normalized name: %v", key, err)
+				continue
+			}
+		}
+		logging.Printf(logging.Debug, "normalized: %s", remoteKey)
+		delete(local.Files, key)
+		delete(byNormal, normal)
+		local.Files[remoteKey] = entry
+	}
+}
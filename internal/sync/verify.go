@@ -1,14 +1,22 @@
 package sync
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/progress"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
 )
 
 // VerifyResult summarizes a verification run.
@@ -17,12 +25,18 @@ type VerifyResult struct {
 	Mismatch []string // files with wrong hash or size
 	Missing  []string // files in manifest but not on disk
 	Errors   []error
+
+	Overridden []string // files that match their overlay_dir replacement
+	Unapplied  []string // overlay_dir files not in place yet
 }
 
 // Verify re-hashes local files against the local manifest and reports
 // any that don't match. Mismatched entries are removed from the local
-// manifest so the next sync re-downloads them.
-func Verify(cfg *config.Config, localManifestPath string, verbose bool) (*VerifyResult, error) {
+// manifest so the next sync re-downloads them. Files replaced by an
+// overlay are checked against the overlay instead. If prog is non-nil,
+// each file emits a start event followed by complete or error. If ctx is
+// canceled, the files checked so far are reported along with ctx.Err().
+func Verify(ctx context.Context, cfg *config.Config, localManifestPath string, prog *progress.Reporter) (*VerifyResult, error) {
 	if localManifestPath == "" {
 		localManifestPath = config.DefaultLocalManifestPath()
 	}
@@ -36,44 +50,60 @@ func Verify(cfg *config.Config, localManifestPath string, verbose bool) (*Verify
 	}
 
 	result := &VerifyResult{}
-	var toRemove []string
 
-	for key, entry := range local.Files {
-		localPath := filepath.Join(cfg.Sync.EmulationPath, filepath.FromSlash(key))
+	overlays, err := Overlays(cfg)
+	if err != nil {
+		return nil, err
+	}
+	unshadowed := Unshadowed(local, overlays)
+	if prog != nil {
+		var size int64
+		for _, entry := range unshadowed.Files {
+			size += entry.Size
+		}
+		prog.Plan(len(overlays)+len(unshadowed.Files), size)
+	}
 
-		info, err := os.Stat(localPath)
-		if os.IsNotExist(err) {
-			result.Missing = append(result.Missing, key)
-			toRemove = append(toRemove, key)
-			continue
+	for key, src := range overlays {
+		logging.Printf(logging.Files, "hashing: %s", key)
+		if prog != nil {
+			prog.Start(key, 0)
 		}
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("stat %s: %w", key, err))
-			continue
+		if sameContent(src, filepath.Join(cfg.Sync.EmulationPath, filepath.FromSlash(key))) {
+			result.Overridden = append(result.Overridden, key)
+			if prog != nil {
+				prog.Complete(key)
+			}
+		} else {
+			result.Unapplied = append(result.Unapplied, key)
+			if prog != nil {
+				prog.FileError(key, errors.New("override not applied"))
+			}
 		}
+	}
+	sort.Strings(result.Unapplied)
 
-		if info.Size() != entry.Size {
-			result.Mismatch = append(result.Mismatch, key)
-			toRemove = append(toRemove, key)
-			continue
+	for _, key := range slices.Sorted(maps.Keys(unshadowed.Files)) {
+		entry := unshadowed.Files[key]
+		if ctx.Err() != nil {
+			break
 		}
-
-		hash, err := manifest.HashFile(localPath)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("hashing %s: %w", key, err))
-			continue
+		if prog != nil {
+			prog.Start(key, entry.Size)
 		}
-
-		if hash != entry.MD5 {
-			result.Mismatch = append(result.Mismatch, key)
-			toRemove = append(toRemove, key)
+		problem := verifyFile(cfg.Sync.EmulationPath, key, entry, result)
+		if prog == nil {
 			continue
 		}
-
-		result.OK = append(result.OK, key)
+		if problem != nil {
+			prog.FileError(key, problem)
+		} else {
+			prog.Complete(key)
+		}
 	}
 
 	// Remove mismatched/missing entries so next sync re-downloads them
+	toRemove := append(append([]string(nil), result.Missing...), result.Mismatch...)
 	if len(toRemove) > 0 {
 		for _, key := range toRemove {
 			delete(local.Files, key)
@@ -83,17 +113,104 @@ func Verify(cfg *config.Config, localManifestPath string, verbose bool) (*Verify
 		}
 	}
 
-	return result, nil
+	return result, ctx.Err()
+}
+
+// verifyFile checks one file against its manifest entry and records the
+// outcome in result. It returns what was wrong, or nil if the file is OK.
+func verifyFile(emuPath, key string, entry manifest.FileEntry, result *VerifyResult) error {
+	localPath := filepath.Join(emuPath, filepath.FromSlash(key))
+
+	info, err := os.Stat(localPath)
+	if os.IsNotExist(err) {
+		logging.Printf(logging.Files, "missing: %s", key)
+		result.Missing = append(result.Missing, key)
+		return &syncerr.SyncError{Key: key, Op: "verify", Code: syncerr.CodeNotFound, Err: errors.New("missing")}
+	}
+	if err != nil {
+		err = syncerr.New("verify", key, fmt.Errorf("stat %s: %w", key, err))
+		result.Errors = append(result.Errors, err)
+		return err
+	}
+
+	if info.Size() != entry.Size {
+		logging.Printf(logging.Files, "size mismatch: %s", key)
+		result.Mismatch = append(result.Mismatch, key)
+		return &syncerr.SyncError{Key: key, Op: "verify", Code: syncerr.CodeChecksum, Err: errors.New("size mismatch")}
+	}
+
+	logging.Printf(logging.Files, "hashing: %s", key)
+	hash, err := manifest.HashFile(localPath)
+	if err != nil {
+		err = syncerr.New("verify", key, fmt.Errorf("hashing %s: %w", key, err))
+		result.Errors = append(result.Errors, err)
+		return err
+	}
+
+	if hash != entry.MD5 {
+		logging.Printf(logging.Files, "checksum mismatch: %s", key)
+		result.Mismatch = append(result.Mismatch, key)
+		return syncerr.New("verify", key, syncerr.ErrChecksum)
+	}
+
+	result.OK = append(result.OK, key)
+	return nil
+}
+
+// Short returns the summary as a single line.
+func (r *VerifyResult) Short() string {
+	checked := len(r.OK) + len(r.Mismatch) + len(r.Missing) + len(r.Errors) + len(r.Overridden) + len(r.Unapplied)
+	if checked == 0 {
+		return "No local manifest found"
+	}
+	var parts []string
+	if len(r.Mismatch) > 0 {
+		parts = append(parts, fmt.Sprintf("%d mismatched", len(r.Mismatch)))
+	}
+	if len(r.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing", len(r.Missing)))
+	}
+	if len(r.Unapplied) > 0 {
+		parts = append(parts, fmt.Sprintf("%d overrides not applied", len(r.Unapplied)))
+	}
+	if len(r.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Verified %d files: all match", checked)
+	}
+	return fmt.Sprintf("Verified %d files: %s", checked, strings.Join(parts, ", "))
+}
+
+// MarshalJSON encodes the result for verify --summary-format json.
+func (r *VerifyResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		OK         int                 `json:"ok"`
+		Mismatch   []string            `json:"mismatched,omitempty"`
+		Missing    []string            `json:"missing,omitempty"`
+		Overridden int                 `json:"overridden,omitempty"`
+		Unapplied  []string            `json:"unapplied,omitempty"`
+		Errors     []syncerr.ErrorJSON `json:"errors,omitempty"`
+	}{len(r.OK), r.Mismatch, r.Missing, len(r.Overridden), r.Unapplied, syncerr.JSONList(r.Errors)})
 }
 
 // Summary returns a human-readable summary of the verification.
 func (r *VerifyResult) Summary() string {
 	var b strings.Builder
-	if len(r.OK) == 0 && len(r.Mismatch) == 0 && len(r.Missing) == 0 && len(r.Errors) == 0 {
+	if len(r.OK) == 0 && len(r.Mismatch) == 0 && len(r.Missing) == 0 && len(r.Errors) == 0 && len(r.Overridden) == 0 && len(r.Unapplied) == 0 {
 		fmt.Fprintln(&b, "No local manifest found. Run sync first.")
 		return b.String()
 	}
 	fmt.Fprintf(&b, "Verified: %d files OK\n", len(r.OK))
+	if len(r.Overridden) > 0 {
+		fmt.Fprintf(&b, "Overridden: %d files match overlay_dir\n", len(r.Overridden))
+	}
+	if len(r.Unapplied) > 0 {
+		fmt.Fprintf(&b, "Overrides not applied: %d files (next sync copies them from overlay_dir)\n", len(r.Unapplied))
+		for _, f := range r.Unapplied {
+			fmt.Fprintf(&b, "  ~ %s\n", f)
+		}
+	}
 	if len(r.Mismatch) > 0 {
 		fmt.Fprintf(&b, "Mismatched: %d files (will re-download on next sync)\n", len(r.Mismatch))
 		for _, f := range r.Mismatch {
@@ -112,7 +229,7 @@ func (r *VerifyResult) Summary() string {
 			fmt.Fprintf(&b, "  ! %v\n", err)
 		}
 	}
-	if len(r.Mismatch) == 0 && len(r.Missing) == 0 && len(r.Errors) == 0 {
+	if len(r.Mismatch) == 0 && len(r.Missing) == 0 && len(r.Errors) == 0 && len(r.Unapplied) == 0 {
 		fmt.Fprintln(&b, "All files match the manifest.")
 	}
 	return b.String()
@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -23,7 +24,7 @@ func TestVerifyAllOK(t *testing.T) {
 	m.SaveJSON(manifestPath)
 
 	cfg := testConfig(emuDir)
-	result, err := Verify(cfg, manifestPath, false)
+	result, err := Verify(context.Background(), cfg, manifestPath, nil)
 	if err != nil {
 		t.Fatalf("Verify: %v", err)
 	}
@@ -54,7 +55,7 @@ func TestVerifyDetectsMismatch(t *testing.T) {
 	m.SaveJSON(manifestPath)
 
 	cfg := testConfig(emuDir)
-	result, err := Verify(cfg, manifestPath, false)
+	result, err := Verify(context.Background(), cfg, manifestPath, nil)
 	if err != nil {
 		t.Fatalf("Verify: %v", err)
 	}
@@ -84,7 +85,7 @@ func TestVerifyDetectsSizeMismatch(t *testing.T) {
 	m.SaveJSON(manifestPath)
 
 	cfg := testConfig(emuDir)
-	result, err := Verify(cfg, manifestPath, false)
+	result, err := Verify(context.Background(), cfg, manifestPath, nil)
 	if err != nil {
 		t.Fatalf("Verify: %v", err)
 	}
@@ -107,7 +108,7 @@ func TestVerifyDetectsMissing(t *testing.T) {
 	m.SaveJSON(manifestPath)
 
 	cfg := testConfig(emuDir)
-	result, err := Verify(cfg, manifestPath, false)
+	result, err := Verify(context.Background(), cfg, manifestPath, nil)
 	if err != nil {
 		t.Fatalf("Verify: %v", err)
 	}
@@ -137,7 +138,7 @@ func TestVerifyMixed(t *testing.T) {
 	m.SaveJSON(manifestPath)
 
 	cfg := testConfig(emuDir)
-	result, err := Verify(cfg, manifestPath, false)
+	result, err := Verify(context.Background(), cfg, manifestPath, nil)
 	if err != nil {
 		t.Fatalf("Verify: %v", err)
 	}
@@ -167,7 +168,7 @@ func TestVerifyMissingManifest(t *testing.T) {
 	manifestPath := filepath.Join(t.TempDir(), "does-not-exist.json")
 
 	cfg := testConfig(emuDir)
-	result, err := Verify(cfg, manifestPath, false)
+	result, err := Verify(context.Background(), cfg, manifestPath, nil)
 	if err != nil {
 		t.Fatalf("Verify should not error on missing manifest: %v", err)
 	}
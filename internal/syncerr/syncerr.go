@@ -0,0 +1,169 @@
+// Package syncerr classifies the per-file failures collected by sync,
+// upload, and verify, so JSON output and the web API can group them by
+// cause (a flaky network, a read-only SD card, a corrupt download)
+// rather than only listing messages.
+package syncerr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+// Codes for SyncError.Code.
+const (
+	CodeNetwork    = "network"    // the storage endpoint couldn't be reached or failed
+	CodePermission = "permission" // denied by the local filesystem or the bucket
+	CodeChecksum   = "checksum"   // content doesn't match the manifest
+	CodeNotFound   = "not_found"  // the file or object doesn't exist
+	CodeDiskFull   = "disk_full"  // no space left on the device
+	CodeCanceled   = "canceled"   // the run was canceled or ran out of time
+	CodeInvalid    = "invalid"    // the key can't be used as a path
+	CodeOther      = "other"
+)
+
+// ErrChecksum is wrapped by errors for content that doesn't match its
+// manifest entry.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// SyncError is a failure on one file. Its message is the wrapped error's,
+// which already names the file; Key, Op, and Code are there for grouping.
+type SyncError struct {
+	Key  string // library key, e.g. "roms/snes/Game.sfc"
+	Op   string // what failed: "download", "upload", "delete", "verify", ...
+	Code string // one of the Code constants
+	Err  error
+}
+
+// New returns err as a SyncError for key, classified by Classify. An err
+// that is already a SyncError is returned unchanged.
+func New(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var se *SyncError
+	if errors.As(err, &se) {
+		return err
+	}
+	return &SyncError{Key: key, Op: op, Code: Classify(err), Err: err}
+}
+
+func (e *SyncError) Error() string { return e.Err.Error() }
+
+func (e *SyncError) Unwrap() error { return e.Err }
+
+// MarshalJSON encodes the error as
+// {"key": ..., "op": ..., "code": ..., "error": message}.
+func (e *SyncError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(JSON(e))
+}
+
+// ErrorJSON is the JSON form of a failure, as written to last-sync.json
+// and returned by the web API.
+type ErrorJSON struct {
+	Key   string `json:"key,omitempty"`
+	Op    string `json:"op,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// JSON describes err for JSON output. Errors that aren't SyncErrors
+// are classified without a key.
+func JSON(err error) ErrorJSON {
+	var se *SyncError
+	if errors.As(err, &se) {
+		return ErrorJSON{Key: se.Key, Op: se.Op, Code: se.Code, Error: err.Error()}
+	}
+	return ErrorJSON{Code: Classify(err), Error: err.Error()}
+}
+
+// JSONList describes each of errs; nil if there are none.
+func JSONList(errs []error) []ErrorJSON {
+	if len(errs) == 0 {
+		return nil
+	}
+	out := make([]ErrorJSON, len(errs))
+	for i, err := range errs {
+		out[i] = JSON(err)
+	}
+	return out
+}
+
+// Sort orders errs by message, so reports list them the same way from
+// run to run however parallel transfers happened to finish.
+func Sort(errs []error) {
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+}
+
+// Code returns err's code: its own if it is a SyncError, otherwise what
+// Classify makes of it.
+func Code(err error) string {
+	var se *SyncError
+	if errors.As(err, &se) {
+		return se.Code
+	}
+	return Classify(err)
+}
+
+// Counts returns how many of errs have each code.
+func Counts(errs []error) map[string]int {
+	if len(errs) == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, err := range errs {
+		counts[Code(err)]++
+	}
+	return counts
+}
+
+// httpStatus is implemented by the AWS SDK's response errors.
+type httpStatus interface {
+	HTTPStatusCode() int
+}
+
+// Classify guesses why err happened from the errors it wraps.
+func Classify(err error) string {
+	var status httpStatus
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	case errors.Is(err, ErrChecksum):
+		return CodeChecksum
+	case errors.Is(err, manifest.ErrInvalidKey):
+		return CodeInvalid
+	case errors.Is(err, syscall.ENOSPC):
+		return CodeDiskFull
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.EROFS):
+		return CodePermission
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, storage.ErrNotFound):
+		return CodeNotFound
+	case errors.As(err, &status):
+		switch code := status.HTTPStatusCode(); {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return CodePermission
+		case code == http.StatusNotFound:
+			return CodeNotFound
+		}
+		return CodeNetwork
+	case errors.As(err, &netErr):
+		return CodeNetwork
+	}
+	// The SDK reports failed response checksums only in the message.
+	if strings.Contains(strings.ToLower(err.Error()), "checksum") {
+		return CodeChecksum
+	}
+	return CodeOther
+}
@@ -0,0 +1,85 @@
+package syncerr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+type statusErr int
+
+func (e statusErr) Error() string       { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) HTTPStatusCode() int { return int(e) }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("download: %w", context.Canceled), CodeCanceled},
+		{fmt.Errorf("download: %w", ErrChecksum), CodeChecksum},
+		{&fs.PathError{Op: "write", Path: "x", Err: syscall.ENOSPC}, CodeDiskFull},
+		{&fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}, CodePermission},
+		{&fs.PathError{Op: "rename", Path: "x", Err: syscall.EROFS}, CodePermission},
+		{fmt.Errorf("downloading x: %w", storage.ErrNotFound), CodeNotFound},
+		{manifest.ValidKey("../x"), CodeInvalid},
+		{fmt.Errorf("upload: %w", statusErr(403)), CodePermission},
+		{fmt.Errorf("upload: %w", statusErr(404)), CodeNotFound},
+		{fmt.Errorf("upload: %w", statusErr(503)), CodeNetwork},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, CodeNetwork},
+		{errors.New("response checksum validation failed"), CodeChecksum},
+		{errors.New("something else"), CodeOther},
+	}
+	for _, tc := range tests {
+		if got := Classify(tc.err); got != tc.want {
+			t.Errorf("Classify(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	cause := fmt.Errorf("download roms/a.rom: %w", fs.ErrPermission)
+	err := New("download", "roms/a.rom", cause)
+
+	if err.Error() != cause.Error() {
+		t.Errorf("Error() = %q, want the wrapped message %q", err, cause)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Error("SyncError doesn't unwrap to its cause")
+	}
+	if again := New("retry", "other", err); again != err {
+		t.Errorf("New rewrapped a SyncError: %#v", again)
+	}
+	if New("download", "x", nil) != nil {
+		t.Error("New(nil) should be nil")
+	}
+
+	data, _ := json.Marshal(err)
+	want := `{"key":"roms/a.rom","op":"download","code":"permission","error":"download roms/a.rom: permission denied"}`
+	if string(data) != want {
+		t.Errorf("JSON = %s\nwant %s", data, want)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	errs := []error{
+		New("download", "a", context.Canceled),
+		New("download", "b", context.Canceled),
+		errors.New("plain"),
+	}
+	got := Counts(errs)
+	if got[CodeCanceled] != 2 || got[CodeOther] != 1 || len(got) != 2 {
+		t.Errorf("Counts = %v", got)
+	}
+	if Counts(nil) != nil || JSONList(nil) != nil {
+		t.Error("no errors should give nil")
+	}
+}
@@ -0,0 +1,135 @@
+package systemd
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	gosync "sync"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/progress"
+	"github.com/jacobfgrant/emu-sync/internal/units"
+)
+
+// ProgressSink turns progress events into sd_notify STATUS updates and
+// journal entries. Attach it to a progress.Reporter.
+type ProgressSink struct {
+	mu        gosync.Mutex
+	notify    bool
+	journal   bool
+	total     int
+	totalSize int64
+	done      int
+	doneSize  int64
+	sizes     map[string]int64 // started files -> size
+	left      time.Duration    // from the last eta event; 0 = unknown
+	warned    bool             // journal write failed; stop trying
+}
+
+// NewProgressSink returns a sink that reports to whichever of
+// sd_notify and the journal are available.
+func NewProgressSink() *ProgressSink {
+	return &ProgressSink{
+		notify:  Notifying(),
+		journal: Journaling(),
+		sizes:   make(map[string]int64),
+	}
+}
+
+// Handle reports one progress event. Failures are logged at most once,
+// so a missing or misbehaving systemd can't interrupt a sync.
+func (p *ProgressSink) Handle(e progress.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch e.Type {
+	case progress.EventPlan:
+		p.total = e.Total
+		p.totalSize = e.Size
+	case progress.EventStart:
+		p.sizes[e.File] = e.Size
+	case progress.EventComplete:
+		p.journalEntry(PriInfo, "downloaded "+e.File, e)
+		p.finish(e.File)
+	case progress.EventError:
+		p.journalEntry(PriErr, fmt.Sprintf("failed %s: %s", e.File, e.Error), e)
+		p.finish(e.File)
+	case progress.EventDelete:
+		p.journalEntry(PriInfo, "deleted "+e.File, e)
+	case progress.EventWarning:
+		p.journalEntry(PriWarning, e.Message, e)
+	case progress.EventETA:
+		p.left = 0
+		if e.Rate > 0 {
+			p.left = max(time.Duration(e.Seconds*float64(time.Second)), time.Second)
+		}
+	case progress.EventDone:
+		p.journalEntry(PriInfo, "sync finished", e)
+		p.status(fmt.Sprintf("finished: %d downloaded, %d deleted, %d errors",
+			e.Downloaded, e.Deleted, e.Errors))
+		// Start the next run (sync --follow) from zero.
+		p.total, p.totalSize, p.done, p.doneSize = 0, 0, 0, 0
+		p.left = 0
+		clear(p.sizes)
+		return
+	default:
+		return
+	}
+
+	if e.Type != progress.EventDelete && e.Type != progress.EventWarning {
+		p.status(p.Status())
+	}
+}
+
+// Status returns the current download progress, e.g.
+// "downloading 12/140, 3.2 GB remaining", with the time left once it's
+// known: "downloading 12/140, 3.2 GB remaining (about 14m)".
+func (p *ProgressSink) Status() string {
+	remaining := p.totalSize - p.doneSize
+	if remaining < 0 {
+		remaining = 0
+	}
+	s := fmt.Sprintf("downloading %d/%d, %s remaining", p.done, p.total, units.FormatSize(remaining))
+	if p.left > 0 {
+		s += " (about " + units.FormatDuration(p.left) + ")"
+	}
+	return s
+}
+
+func (p *ProgressSink) finish(file string) {
+	p.done++
+	p.doneSize += p.sizes[file]
+	delete(p.sizes, file)
+}
+
+func (p *ProgressSink) status(s string) {
+	if p.notify {
+		Notify("STATUS=" + s)
+	}
+}
+
+func (p *ProgressSink) journalEntry(priority int, msg string, e progress.Event) {
+	if !p.journal || p.warned {
+		return
+	}
+	fields := map[string]string{"EMU_SYNC_EVENT": e.Type}
+	if e.File != "" {
+		fields["EMU_SYNC_FILE"] = e.File
+	}
+	if size, ok := p.sizes[e.File]; ok && size > 0 {
+		fields["EMU_SYNC_SIZE"] = strconv.FormatInt(size, 10)
+	}
+	if e.Error != "" {
+		fields["EMU_SYNC_ERROR"] = e.Error
+		fields["EMU_SYNC_ERROR_CODE"] = e.Code
+	}
+	if e.Type == progress.EventDone {
+		fields["EMU_SYNC_DOWNLOADED"] = strconv.Itoa(e.Downloaded)
+		fields["EMU_SYNC_DELETED"] = strconv.Itoa(e.Deleted)
+		fields["EMU_SYNC_ERRORS"] = strconv.Itoa(e.Errors)
+	}
+	if err := Journal(priority, msg, fields); err != nil {
+		log.Printf("warning: writing to journal: %v", err)
+		p.warned = true
+	}
+}
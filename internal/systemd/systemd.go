@@ -0,0 +1,84 @@
+// Package systemd reports progress to systemd when emu-sync runs as a
+// service: sd_notify status lines for `systemctl status`, and journal
+// entries with structured fields for `journalctl`.
+package systemd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// journalSocket is where journald accepts native-protocol entries.
+var journalSocket = "/run/systemd/journal/socket"
+
+// Journal priorities, as in syslog(3).
+const (
+	PriErr     = 3
+	PriWarning = 4
+	PriInfo    = 6
+)
+
+// Notifying reports whether systemd is listening for sd_notify messages.
+func Notifying() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// Journaling reports whether output is going to the journal.
+func Journaling() bool {
+	return os.Getenv("JOURNAL_STREAM") != ""
+}
+
+// Notify sends an sd_notify state string such as "READY=1" or
+// "STATUS=downloading". It does nothing when not running under systemd.
+func Notify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	if strings.HasPrefix(addr, "@") {
+		addr = "\x00" + addr[1:] // abstract namespace
+	}
+	return send(addr, []byte(state))
+}
+
+// Journal writes an entry to the journal with the given priority,
+// message, and extra fields. Field names must be uppercase letters,
+// digits, and underscores.
+func Journal(priority int, msg string, fields map[string]string) error {
+	var buf bytes.Buffer
+	writeField(&buf, "PRIORITY", fmt.Sprint(priority))
+	writeField(&buf, "SYSLOG_IDENTIFIER", "emu-sync")
+	writeField(&buf, "MESSAGE", msg)
+	for k, v := range fields {
+		writeField(&buf, k, v)
+	}
+	return send(journalSocket, buf.Bytes())
+}
+
+// writeField encodes one field in journald's native protocol. Values
+// containing newlines use the length-prefixed binary form.
+func writeField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", key, value)
+		return
+	}
+	buf.WriteString(key)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func send(addr string, data []byte) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(data)
+	return err
+}
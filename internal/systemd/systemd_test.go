@@ -0,0 +1,103 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/progress"
+)
+
+// listen opens a unixgram socket in a temp dir and returns its path and
+// a function that reads the next datagram.
+func listen(t *testing.T) (string, func() string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return path, func() string {
+		t.Helper()
+		buf := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestNotify(t *testing.T) {
+	path, read := listen(t)
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if err := Notify("READY=1"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := read(); got != "READY=1" {
+		t.Errorf("got %q, want READY=1", got)
+	}
+}
+
+func TestNotifyWithoutSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify("READY=1"); err != nil {
+		t.Errorf("Notify without socket: %v", err)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	path, read := listen(t)
+	orig := journalSocket
+	journalSocket = path
+	t.Cleanup(func() { journalSocket = orig })
+
+	err := Journal(PriErr, "failed roms/a.sfc", map[string]string{
+		"EMU_SYNC_FILE":  "roms/a.sfc",
+		"EMU_SYNC_ERROR": "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Journal: %v", err)
+	}
+	got := read()
+	for _, want := range []string{
+		"PRIORITY=3\n",
+		"SYSLOG_IDENTIFIER=emu-sync\n",
+		"MESSAGE=failed roms/a.sfc\n",
+		"EMU_SYNC_FILE=roms/a.sfc\n",
+		"EMU_SYNC_ERROR\n\x11\x00\x00\x00\x00\x00\x00\x00line one\nline two\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("entry missing %q:\n%q", want, got)
+		}
+	}
+}
+
+func TestProgressSinkStatus(t *testing.T) {
+	path, read := listen(t)
+	t.Setenv("NOTIFY_SOCKET", path)
+	t.Setenv("JOURNAL_STREAM", "")
+
+	r := progress.New(NewProgressSink())
+	r.Plan(2, 3<<30)
+	if got := read(); got != "STATUS=downloading 0/2, 3.0 GiB remaining" {
+		t.Errorf("after plan: %q", got)
+	}
+
+	r.Start("roms/ps2/A.iso", 1<<30)
+	read()
+	r.Complete("roms/ps2/A.iso")
+	if got := read(); got != "STATUS=downloading 1/2, 2.0 GiB remaining" {
+		t.Errorf("after complete: %q", got)
+	}
+
+	r.Done(progress.Summary{Downloaded: 1})
+	if got := read(); !strings.HasPrefix(got, "STATUS=finished: 1 downloaded") {
+		t.Errorf("after done: %q", got)
+	}
+}
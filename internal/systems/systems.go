@@ -0,0 +1,190 @@
+// Package systems maps emulation directory names to friendly platform
+// names for display (e.g., "roms/snes" -> "Super Nintendo").
+package systems
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Info is how a system is presented in choose and the web UI.
+type Info struct {
+	Name string // display name, e.g. "Super Nintendo"
+	Icon string // short badge shown next to the name, e.g. "SNES"
+}
+
+// builtin is keyed by the system's directory name as used by EmuDeck and
+// ES-DE. Emulator-named BIOS directories (e.g. bios/pcsx2) are included
+// so they group under the platform they serve.
+var builtin = map[string]Info{
+	"3do":             {"3DO Interactive Multiplayer", "3DO"},
+	"3ds":             {"Nintendo 3DS", "3DS"},
+	"amiga":           {"Commodore Amiga", "AMIGA"},
+	"amstradcpc":      {"Amstrad CPC", "CPC"},
+	"arcade":          {"Arcade", "ARC"},
+	"atari2600":       {"Atari 2600", "2600"},
+	"atari5200":       {"Atari 5200", "5200"},
+	"atari7800":       {"Atari 7800", "7800"},
+	"atarilynx":       {"Atari Lynx", "LYNX"},
+	"c64":             {"Commodore 64", "C64"},
+	"cemu":            {"Nintendo Wii U", "WIIU"},
+	"citra":           {"Nintendo 3DS", "3DS"},
+	"coleco":          {"ColecoVision", "CV"},
+	"dolphin-emu":     {"GameCube / Wii", "GC"},
+	"dos":             {"DOS", "DOS"},
+	"dreamcast":       {"Sega Dreamcast", "DC"},
+	"duckstation":     {"PlayStation", "PS1"},
+	"fbneo":           {"FinalBurn Neo", "FBN"},
+	"gamegear":        {"Sega Game Gear", "GG"},
+	"gb":              {"Game Boy", "GB"},
+	"gba":             {"Game Boy Advance", "GBA"},
+	"gbc":             {"Game Boy Color", "GBC"},
+	"gc":              {"Nintendo GameCube", "GC"},
+	"genesis":         {"Sega Genesis", "GEN"},
+	"intellivision":   {"Intellivision", "INTV"},
+	"mame":            {"MAME", "MAME"},
+	"mastersystem":    {"Sega Master System", "SMS"},
+	"megacd":          {"Sega Mega-CD", "MCD"},
+	"megadrive":       {"Sega Mega Drive", "MD"},
+	"msx":             {"MSX", "MSX"},
+	"n3ds":            {"Nintendo 3DS", "3DS"},
+	"n64":             {"Nintendo 64", "N64"},
+	"naomi":           {"Sega NAOMI", "NAOMI"},
+	"nds":             {"Nintendo DS", "NDS"},
+	"neogeo":          {"Neo Geo", "NEO"},
+	"neogeocd":        {"Neo Geo CD", "NGCD"},
+	"nes":             {"Nintendo Entertainment System", "NES"},
+	"ngp":             {"Neo Geo Pocket", "NGP"},
+	"ngpc":            {"Neo Geo Pocket Color", "NGPC"},
+	"pc":              {"PC", "PC"},
+	"pce":             {"PC Engine", "PCE"},
+	"pcengine":        {"PC Engine", "PCE"},
+	"pcenginecd":      {"PC Engine CD", "PCECD"},
+	"pcsx2":           {"PlayStation 2", "PS2"},
+	"ppsspp":          {"PlayStation Portable", "PSP"},
+	"ps2":             {"PlayStation 2", "PS2"},
+	"ps3":             {"PlayStation 3", "PS3"},
+	"psp":             {"PlayStation Portable", "PSP"},
+	"psvita":          {"PlayStation Vita", "VITA"},
+	"psx":             {"PlayStation", "PS1"},
+	"rpcs3":           {"PlayStation 3", "PS3"},
+	"saturn":          {"Sega Saturn", "SAT"},
+	"scummvm":         {"ScummVM", "SCUMM"},
+	"sega32x":         {"Sega 32X", "32X"},
+	"segacd":          {"Sega CD", "SCD"},
+	"sg-1000":         {"Sega SG-1000", "SG"},
+	"snes":            {"Super Nintendo", "SNES"},
+	"switch":          {"Nintendo Switch", "NSW"},
+	"tg16":            {"TurboGrafx-16", "TG16"},
+	"tg-cd":           {"TurboGrafx-CD", "TGCD"},
+	"vectrex":         {"Vectrex", "VEC"},
+	"virtualboy":      {"Virtual Boy", "VB"},
+	"wii":             {"Nintendo Wii", "WII"},
+	"wiiu":            {"Nintendo Wii U", "WIIU"},
+	"wonderswan":      {"WonderSwan", "WS"},
+	"wonderswancolor": {"WonderSwan Color", "WSC"},
+	"xbox":            {"Xbox", "XBOX"},
+	"xbox360":         {"Xbox 360", "X360"},
+	"yuzu":            {"Nintendo Switch", "NSW"},
+}
+
+// Lookup returns display info for a system directory such as "roms/snes".
+// An override keyed by the full directory wins, then one keyed by its base
+// name, then the built-in table; fields left empty in an override fall
+// through. Unknown systems are named after their directory with no icon.
+func Lookup(dir string, overrides map[string]Info) Info {
+	base := strings.ToLower(path.Base(dir))
+	info := builtin[base]
+	for _, key := range []string{base, dir} {
+		o, ok := overrides[key]
+		if !ok {
+			continue
+		}
+		if o.Name != "" {
+			info.Name = o.Name
+		}
+		if o.Icon != "" {
+			info.Icon = o.Icon
+		}
+	}
+	if info.Name == "" {
+		info.Name = dir
+	}
+	return info
+}
+
+// Dir returns the system directory of a manifest key: its first two path
+// segments (e.g., "bios/pcsx2", "roms/snes"), or path.Dir for keys with
+// fewer than three.
+func Dir(key string) string {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) <= 2 {
+		return path.Dir(key)
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// tagSuffix matches a trailing tag in a ROM's name, such as " (USA)",
+// " (Rev 1)", or " [!]".
+var tagSuffix = regexp.MustCompile(`\s*(\([^()]*\)|\[[^\[\]]*\])$`)
+
+// Game returns the display name of the game at key, a file under roms/:
+// the file (or, for games kept in a folder, the folder) named without
+// its extension and trailing region and revision tags, followed by the
+// system's icon, e.g. "Chrono Trigger (SNES)" for
+// roms/snes/Chrono Trigger (USA).sfc. Systems without an icon use their
+// name.
+func Game(key string, overrides map[string]Info) string {
+	dir := Dir(key)
+	name, _, inFolder := strings.Cut(strings.TrimPrefix(key, dir+"/"), "/")
+	if !inFolder {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	for {
+		trimmed := tagSuffix.ReplaceAllString(name, "")
+		if trimmed == name || trimmed == "" {
+			break
+		}
+		name = trimmed
+	}
+	info := Lookup(dir, overrides)
+	label := info.Icon
+	if label == "" {
+		label = info.Name
+	}
+	return fmt.Sprintf("%s (%s)", name, label)
+}
+
+// AnnounceMax is how many games notifications, webhooks, and the web
+// activity feed name before summing up the rest.
+const AnnounceMax = 5
+
+// Announcement returns a message naming the games among keys, e.g. "New
+// games available: Chrono Trigger (SNES), Metroid Fusion (GBA) and 3
+// more", listing at most max of them. Keys outside roms/ (BIOS files,
+// say) aren't games and are left out, as are further discs of a game
+// already named. It returns "" if keys has no games.
+func Announcement(keys []string, overrides map[string]Info, max int) string {
+	var games []string
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "roms/") || strings.Count(key, "/") < 2 {
+			continue
+		}
+		game := Game(key, overrides)
+		if !seen[game] {
+			seen[game] = true
+			games = append(games, game)
+		}
+	}
+	if len(games) == 0 {
+		return ""
+	}
+	msg := "New games available: "
+	if len(games) <= max {
+		return msg + strings.Join(games, ", ")
+	}
+	return fmt.Sprintf("%s%s and %d more", msg, strings.Join(games[:max], ", "), len(games)-max)
+}
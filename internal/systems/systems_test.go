@@ -0,0 +1,67 @@
+package systems
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	overrides := map[string]Info{
+		"roms/hacks": {Name: "ROM Hacks"},
+		"gba":        {Icon: "ADV"},
+		"roms/snes":  {Name: "Super Famicom"},
+	}
+	tests := []struct {
+		dir  string
+		want Info
+	}{
+		{"roms/snes", Info{Name: "Super Famicom", Icon: "SNES"}},
+		{"bios/snes", Info{Name: "Super Nintendo", Icon: "SNES"}},
+		{"roms/gba", Info{Name: "Game Boy Advance", Icon: "ADV"}},
+		{"bios/PCSX2", Info{Name: "PlayStation 2", Icon: "PS2"}},
+		{"roms/hacks", Info{Name: "ROM Hacks"}},
+		{"roms/unknown", Info{Name: "roms/unknown"}},
+	}
+	for _, tt := range tests {
+		if got := Lookup(tt.dir, overrides); got != tt.want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestDir(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"roms/snes/Game.sfc", "roms/snes"},
+		{"bios/pcsx2/resources/shader.glsl", "bios/pcsx2"},
+		{"bios/file.bin", "bios"},
+		{"roms/gba/sub/deep/file.gba", "roms/gba"},
+	}
+	for _, tt := range tests {
+		if got := Dir(tt.key); got != tt.want {
+			t.Errorf("Dir(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	overrides := map[string]Info{"roms/hacks": {Name: "ROM Hacks"}}
+	keys := []string{
+		"bios/scph5501.bin",
+		"roms/snes/Chrono Trigger (USA).sfc",
+		"roms/psx/Final Fantasy VII (USA)/Final Fantasy VII (USA) (Disc 1).bin",
+		"roms/psx/Final Fantasy VII (USA)/Final Fantasy VII (USA) (Disc 2).bin",
+		"roms/gba/Metroid Fusion (USA) (Rev 1) [!].gba",
+		"roms/hacks/Super Mario World - Return.sfc",
+	}
+	want := "New games available: Chrono Trigger (SNES), Final Fantasy VII (PS1), Metroid Fusion (GBA), Super Mario World - Return (ROM Hacks)"
+	if got := Announcement(keys, overrides, 10); got != want {
+		t.Errorf("Announcement = %q\nwant %q", got, want)
+	}
+	want = "New games available: Chrono Trigger (SNES) and 3 more"
+	if got := Announcement(keys, overrides, 1); got != want {
+		t.Errorf("Announcement(max 1) = %q, want %q", got, want)
+	}
+	if got := Announcement([]string{"bios/scph5501.bin"}, nil, 10); got != "" {
+		t.Errorf("Announcement(BIOS only) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,141 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// An invite is a setup token sealed with a short code and published in
+// the bucket, so recipients get a link that survives chat apps plus an
+// 8-character code to type, instead of a long base64 blob.
+
+// codeAlphabet is Crockford's base32: no I, L, O, or U to misread.
+const codeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// InvitePrefix is where published invites are stored in the bucket.
+const InvitePrefix = "emu-sync-invites/"
+
+// pbkdf2Iterations slows guessing of the code by anyone holding the link.
+const pbkdf2Iterations = 600_000
+
+// ErrInviteExpired is returned by OpenInvite for an invite past its expiry.
+var ErrInviteExpired = errors.New("invite has expired")
+
+// sealedInvite is the stored form of an invite.
+type sealedInvite struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// invitePayload is what the code protects.
+type invitePayload struct {
+	Token   string    `json:"token"`
+	Key     string    `json:"key"` // object key, so the recipient can delete it
+	Expires time.Time `json:"expires"`
+}
+
+// NewInviteCode returns a random 8-character code formatted as XXXX-XXXX.
+func NewInviteCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+// normalizeCode uppercases code, drops separators and spaces, and maps
+// the letters Crockford base32 treats as look-alikes.
+func normalizeCode(code string) string {
+	r := strings.NewReplacer("-", "", " ", "", "I", "1", "L", "1", "O", "0")
+	return r.Replace(strings.ToUpper(code))
+}
+
+// InviteKey returns a fresh random object key for an invite.
+func InviteKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%x", InvitePrefix, b), nil
+}
+
+// SealInvite encrypts tokenStr with code. key is the object key the
+// invite will be stored under.
+func SealInvite(tokenStr, code, key string, expires time.Time) ([]byte, error) {
+	payload, err := json.Marshal(invitePayload{Token: tokenStr, Key: key, Expires: expires})
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := inviteCipher(code, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(sealedInvite{
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, payload, nil),
+	})
+}
+
+// OpenInvite decrypts an invite with code and returns the setup token
+// and the invite's object key.
+func OpenInvite(data []byte, code string, now time.Time) (tokenStr, key string, err error) {
+	var sealed sealedInvite
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return "", "", fmt.Errorf("invalid invite: %w", err)
+	}
+	gcm, err := inviteCipher(code, sealed.Salt)
+	if err != nil {
+		return "", "", err
+	}
+	if len(sealed.Nonce) != gcm.NonceSize() {
+		return "", "", fmt.Errorf("invalid invite: bad nonce")
+	}
+	plain, err := gcm.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("wrong code for this invite")
+	}
+
+	var p invitePayload
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return "", "", fmt.Errorf("invalid invite: %w", err)
+	}
+	if now.After(p.Expires) {
+		return "", "", ErrInviteExpired
+	}
+	return p.Token, p.Key, nil
+}
+
+func inviteCipher(code string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, normalizeCode(code), salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
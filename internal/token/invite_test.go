@@ -0,0 +1,46 @@
+package token
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewInviteCode(t *testing.T) {
+	code, err := NewInviteCode()
+	if err != nil {
+		t.Fatalf("NewInviteCode: %v", err)
+	}
+	if !regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{4}-[0-9A-HJKMNP-TV-Z]{4}$`).MatchString(code) {
+		t.Errorf("code = %q, want XXXX-XXXX in Crockford base32", code)
+	}
+}
+
+func TestSealAndOpenInvite(t *testing.T) {
+	now := time.Now()
+	sealed, err := SealInvite("tok", "AB0C-DE1F", InvitePrefix+"k", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SealInvite: %v", err)
+	}
+	if strings.Contains(string(sealed), "tok") {
+		t.Error("sealed invite contains the plaintext token")
+	}
+
+	// Codes are forgiving about case, separators, and look-alike letters
+	tok, key, err := OpenInvite(sealed, "abOc de1f", now)
+	if err != nil {
+		t.Fatalf("OpenInvite: %v", err)
+	}
+	if tok != "tok" || key != InvitePrefix+"k" {
+		t.Errorf("got token %q key %q", tok, key)
+	}
+
+	if _, _, err := OpenInvite(sealed, "AB0C-DE1G", now); err == nil {
+		t.Error("expected error for wrong code")
+	}
+	if _, _, err := OpenInvite(sealed, "AB0C-DE1F", now.Add(2*time.Hour)); !errors.Is(err, ErrInviteExpired) {
+		t.Errorf("err = %v, want ErrInviteExpired", err)
+	}
+}
@@ -19,6 +19,16 @@ type Data struct {
 	EmulationPath string   `json:"emulation_path"`
 	SyncDirs      []string `json:"sync_dirs,omitempty"`
 	Delete        *bool    `json:"delete,omitempty"`
+
+	// Optional client tuning; older tokens omit these and get defaults.
+	SyncExclude    []string `json:"sync_exclude,omitempty"`
+	BandwidthLimit string   `json:"bandwidth_limit,omitempty"`
+	Workers        int      `json:"workers,omitempty"`
+	ReadOnly       bool     `json:"read_only,omitempty"` // key can only read its prefix
+	WebPort        int      `json:"web_port,omitempty"`
+
+	// Parental controls: what the recipient's device may see.
+	Policy *config.PolicyConfig `json:"policy,omitempty"`
 }
 
 // Encode creates a base64 token from token data.
@@ -45,6 +55,14 @@ func Decode(tokenStr string) (*Data, error) {
 	if d.Bucket == "" || d.KeyID == "" || d.SecretKey == "" {
 		return nil, fmt.Errorf("invalid token: missing required fields (bucket, key_id, secret_key)")
 	}
+	if d.BandwidthLimit != "" {
+		if _, err := config.ParseBandwidthLimit(d.BandwidthLimit); err != nil {
+			return nil, fmt.Errorf("invalid token: bandwidth_limit: %w", err)
+		}
+	}
+	if d.Workers < 0 || d.WebPort < 0 || d.WebPort > 65535 {
+		return nil, fmt.Errorf("invalid token: workers or web_port out of range")
+	}
 
 	return &d, nil
 }
@@ -61,7 +79,7 @@ func (d *Data) ToConfig() *config.Config {
 		deleteFiles = *d.Delete
 	}
 
-	return &config.Config{
+	cfg := &config.Config{
 		Storage: config.StorageConfig{
 			EndpointURL: d.EndpointURL,
 			Bucket:      d.Bucket,
@@ -69,19 +87,28 @@ func (d *Data) ToConfig() *config.Config {
 			SecretKey:   d.SecretKey,
 			Region:      d.Region,
 			Prefix:      d.Prefix,
+			ReadOnly:    d.ReadOnly,
 		},
 		Sync: config.SyncConfig{
-			EmulationPath: d.EmulationPath,
-			SyncDirs:      syncDirs,
-			Delete:        deleteFiles,
+			EmulationPath:  d.EmulationPath,
+			SyncDirs:       syncDirs,
+			SyncExclude:    d.SyncExclude,
+			Delete:         deleteFiles,
+			Workers:        d.Workers,
+			BandwidthLimit: d.BandwidthLimit,
 		},
+		Web: config.WebConfig{Port: d.WebPort},
 	}
+	if d.Policy != nil {
+		cfg.Policy = *d.Policy
+	}
+	return cfg
 }
 
 // FromConfig creates token data from an existing config.
 func FromConfig(cfg *config.Config) *Data {
 	delete := cfg.Sync.Delete
-	return &Data{
+	d := &Data{
 		EndpointURL:   cfg.Storage.EndpointURL,
 		Bucket:        cfg.Storage.Bucket,
 		KeyID:         cfg.Storage.KeyID,
@@ -91,5 +118,16 @@ func FromConfig(cfg *config.Config) *Data {
 		EmulationPath: cfg.Sync.EmulationPath,
 		SyncDirs:      cfg.Sync.SyncDirs,
 		Delete:        &delete,
+
+		SyncExclude:    cfg.Sync.SyncExclude,
+		BandwidthLimit: cfg.Sync.BandwidthLimit,
+		Workers:        cfg.Sync.Workers,
+		ReadOnly:       cfg.Storage.ReadOnly,
+		WebPort:        cfg.Web.Port,
+	}
+	if !cfg.Policy.Empty() {
+		policy := cfg.Policy
+		d.Policy = &policy
 	}
+	return d
 }
@@ -179,3 +179,51 @@ func TestBackwardCompatibility(t *testing.T) {
 		t.Error("old token should get delete=true default")
 	}
 }
+
+func TestClientSettingsRoundTrip(t *testing.T) {
+	cfg := &config.Config{
+		Storage: config.StorageConfig{Bucket: "test", KeyID: "key", SecretKey: "secret", ReadOnly: true},
+		Sync: config.SyncConfig{
+			EmulationPath:  "/tmp/emu",
+			SyncDirs:       []string{"roms"},
+			SyncExclude:    []string{"roms/ps2"},
+			Workers:        4,
+			BandwidthLimit: "10MB",
+		},
+		Web:    config.WebConfig{Port: 9000},
+		Policy: config.PolicyConfig{DenySystems: []string{"n64"}, DenyTags: []string{"mature"}},
+	}
+
+	encoded, err := Encode(FromConfig(cfg))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	got := decoded.ToConfig()
+
+	if len(got.Sync.SyncExclude) != 1 || got.Sync.SyncExclude[0] != "roms/ps2" {
+		t.Errorf("sync_exclude = %v", got.Sync.SyncExclude)
+	}
+	if got.Sync.Workers != 4 || got.Sync.BandwidthLimit != "10MB" {
+		t.Errorf("workers = %d, bandwidth_limit = %q", got.Sync.Workers, got.Sync.BandwidthLimit)
+	}
+	if !got.Storage.ReadOnly {
+		t.Error("read_only should round-trip")
+	}
+	if got.Web.Port != 9000 {
+		t.Errorf("web port = %d, want 9000", got.Web.Port)
+	}
+	if len(got.Policy.DenySystems) != 1 || len(got.Policy.DenyTags) != 1 || got.Policy.DenyTags[0] != "mature" {
+		t.Errorf("policy = %+v, want it to round-trip", got.Policy)
+	}
+}
+
+func TestDecodeInvalidBandwidthLimit(t *testing.T) {
+	encoded, _ := Encode(&Data{Bucket: "b", KeyID: "k", SecretKey: "s", BandwidthLimit: "fast"})
+	if _, err := Decode(encoded); err == nil {
+		t.Error("expected error for invalid bandwidth_limit")
+	}
+}
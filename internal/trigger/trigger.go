@@ -0,0 +1,58 @@
+// Package trigger lets an uploader (or CI) tell following devices that
+// the library was just published, so they sync within seconds instead
+// of waiting for their next poll. The uploader POSTs to each device's
+// /api/trigger with a shared bearer token.
+package trigger
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Path is where Handler is served.
+const Path = "/api/trigger"
+
+// Handler returns an HTTP handler that calls fire for each POST carrying
+// "Authorization: Bearer <token>". fire must not block; the handler
+// answers 202 as soon as it returns.
+func Handler(token string, fire func()) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fire()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// Send POSTs a trigger to url with token through rt, or the default
+// transport if rt is nil.
+func Send(ctx context.Context, url, token string, rt http.RoundTripper) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package trigger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	fired := 0
+	srv := httptest.NewServer(Handler("s3cret", func() { fired++ }))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if err := Send(ctx, srv.URL+Path, "wrong", nil); err == nil {
+		t.Error("Send with the wrong token should fail")
+	}
+	if err := Send(ctx, srv.URL+Path, "s3cret", nil); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if fired != 1 {
+		t.Errorf("fired %d times, want 1", fired)
+	}
+
+	resp, err := http.Get(srv.URL + Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || fired != 1 {
+		t.Errorf("GET = %d (fired %d), want 405 without firing", resp.StatusCode, fired)
+	}
+}
+
+func TestHandlerNoToken(t *testing.T) {
+	srv := httptest.NewServer(Handler("", func() { t.Error("fired without a configured token") }))
+	defer srv.Close()
+	if err := Send(context.Background(), srv.URL, "", nil); err == nil {
+		t.Error("Send should fail when no token is configured")
+	}
+}
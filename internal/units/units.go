@@ -0,0 +1,80 @@
+package units
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Unit systems for FormatSize, chosen with display.units.
+const (
+	IEC = "iec" // powers of 1024: KiB, MiB, GiB, TiB
+	SI  = "si"  // powers of 1000: KB, MB, GB, TB, as storage providers bill
+)
+
+// Systems lists the accepted display.units values.
+var Systems = []string{IEC, SI}
+
+var si atomic.Bool
+
+// SetSystem makes FormatSize use system, IEC or SI, for the rest of the
+// process. "" means IEC.
+func SetSystem(system string) {
+	si.Store(system == SI)
+}
+
+// System returns the unit system FormatSize uses.
+func System() string {
+	if si.Load() {
+		return SI
+	}
+	return IEC
+}
+
+// FormatSize formats a byte count for display in the unit system set by
+// SetSystem, with one decimal place below 10 and none above (e.g.,
+// "1.5 GiB", "300 MiB").
+func FormatSize(bytes int64) string {
+	if bytes < 0 {
+		return "-" + FormatSize(-bytes)
+	}
+	base, names := 1024.0, []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	if si.Load() {
+		base, names = 1000, []string{"B", "KB", "MB", "GB", "TB"}
+	}
+	if float64(bytes) < base {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	v, i := float64(bytes), 0
+	for v >= base && i < len(names)-1 {
+		v /= base
+		i++
+	}
+	if v < 9.95 {
+		return fmt.Sprintf("%.1f %s", v, names[i])
+	}
+	return fmt.Sprintf("%.0f %s", v, names[i])
+}
+
+// FormatCost formats a dollar amount for display. Amounts that round to
+// zero but aren't zero are shown as "<$0.01".
+func FormatCost(dollars float64) string {
+	if dollars > 0 && dollars < 0.005 {
+		return "<$0.01"
+	}
+	return fmt.Sprintf("$%.2f", dollars)
+}
+
+// FormatDuration formats an estimated duration coarsely enough not to
+// suggest precision: to the minute from an hour up (e.g., "6h05m"), in
+// minutes from a minute up ("14m"), and in seconds below ("40s").
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Hour-30*time.Second:
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute-500*time.Millisecond:
+		return fmt.Sprintf("%dm", int(d.Round(time.Minute).Minutes()))
+	}
+	return fmt.Sprintf("%ds", int(d.Round(time.Second).Seconds()))
+}
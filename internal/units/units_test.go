@@ -0,0 +1,87 @@
+package units
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1023, "1023 B"},
+		{2048, "2.0 KiB"},
+		{3 * 1024 * 1024, "3.0 MiB"},
+		{300 * 1024 * 1024, "300 MiB"},
+		{1536 * 1024 * 1024, "1.5 GiB"},
+		{10*1024*1024*1024 - 1, "10 GiB"},
+		{2048 * 1024 * 1024 * 1024, "2.0 TiB"},
+		{-2048, "-2.0 KiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSizeSI(t *testing.T) {
+	SetSystem(SI)
+	defer SetSystem(IEC)
+
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{999, "999 B"},
+		{1000, "1.0 KB"},
+		{3 * 1024 * 1024, "3.1 MB"},
+		{1_500_000_000, "1.5 GB"},
+		{250_000_000_000, "250 GB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCost(t *testing.T) {
+	tests := []struct {
+		dollars float64
+		want    string
+	}{
+		{0, "$0.00"},
+		{0.001, "<$0.01"},
+		{0.126, "$0.13"},
+		{12.5, "$12.50"},
+	}
+	for _, tt := range tests {
+		if got := FormatCost(tt.dollars); got != tt.want {
+			t.Errorf("FormatCost(%v) = %q, want %q", tt.dollars, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{40 * time.Second, "40s"},
+		{59*time.Second + 600*time.Millisecond, "1m"},
+		{14*time.Minute + 20*time.Second, "14m"},
+		{59*time.Minute + 45*time.Second, "1h00m"},
+		{6*time.Hour + 5*time.Minute + 10*time.Second, "6h05m"},
+		{30 * time.Hour, "30h00m"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package update
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkCache records the last release check so commands check GitHub at
+// most once per CheckInterval.
+type checkCache struct {
+	Checked time.Time `json:"checked"`
+	Latest  string    `json:"latest,omitempty"`
+}
+
+// CheckInterval is how often CachedLatestVersion queries GitHub.
+const CheckInterval = 24 * time.Hour
+
+// CachedLatestVersion returns the latest release tag, querying GitHub
+// only if the cache at path is older than CheckInterval. A failed check
+// is cached too, so an offline device doesn't retry on every run; the
+// previously known tag (possibly "") is returned in that case.
+func CachedLatestVersion(path string) string {
+	var cache checkCache
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	if time.Since(cache.Checked) < CheckInterval {
+		return cache.Latest
+	}
+
+	if latest, err := CheckLatestVersion(); err == nil {
+		cache.Latest = latest
+	}
+	cache.Checked = time.Now()
+	if data, err := json.Marshal(cache); err == nil {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, data, 0o644)
+	}
+	return cache.Latest
+}
@@ -0,0 +1,56 @@
+package update
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCachedLatestVersion(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Location", "https://github.com/jacobfgrant/emu-sync/releases/tag/v0.8.0")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer srv.Close()
+
+	origURL := latestReleaseURL
+	latestReleaseURL = srv.URL
+	defer func() { latestReleaseURL = origURL }()
+
+	path := filepath.Join(t.TempDir(), "update-check.json")
+	if got := CachedLatestVersion(path); got != "v0.8.0" {
+		t.Errorf("first check = %q, want v0.8.0", got)
+	}
+	if got := CachedLatestVersion(path); got != "v0.8.0" {
+		t.Errorf("cached check = %q, want v0.8.0", got)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1 (second check should use the cache)", n)
+	}
+}
+
+func TestCachedLatestVersionOffline(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	origURL := latestReleaseURL
+	latestReleaseURL = srv.URL
+	defer func() { latestReleaseURL = origURL }()
+
+	path := filepath.Join(t.TempDir(), "update-check.json")
+	if got := CachedLatestVersion(path); got != "" {
+		t.Errorf("failed check = %q, want empty", got)
+	}
+	CachedLatestVersion(path)
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1 (failed check should be cached)", n)
+	}
+}
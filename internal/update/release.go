@@ -0,0 +1,194 @@
+package update
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var releaseDownloadURL = "https://github.com/jacobfgrant/emu-sync/releases/download"
+
+// binaryName is the executable inside each release archive.
+const binaryName = "emu-sync"
+
+// AssetName returns the release archive for this platform, matching the
+// names produced by goreleaser and used by install.sh.
+func AssetName() string {
+	return fmt.Sprintf("emu-sync_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+}
+
+// PreviousPath returns where the binary replaced by the last update is
+// kept, for Rollback.
+func PreviousPath(binPath string) string {
+	return binPath + ".previous"
+}
+
+// DownloadRelease downloads this platform's archive for version, checks
+// it against the release's checksums.txt, and returns the binary inside.
+// Nothing is written to disk.
+func DownloadRelease(version string) ([]byte, error) {
+	base := releaseDownloadURL + "/" + version
+	asset := AssetName()
+
+	sums, err := fetch(base + "/checksums.txt")
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("release %s not found", version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("downloading checksums: %w", err)
+	}
+	archive, err := fetch(base + "/" + asset)
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%s has no release for %s/%s", version, runtime.GOOS, runtime.GOARCH)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", asset, err)
+	}
+	if err := verifyChecksum(archive, asset, sums); err != nil {
+		return nil, err
+	}
+	return extractBinary(archive)
+}
+
+// Install replaces the binary at binPath with data, keeping the current
+// binary at PreviousPath. The new binary is written next to the old one
+// and renamed over it, so binPath always holds a complete executable and
+// a failed write leaves the install untouched.
+func Install(binPath string, data []byte) error {
+	newPath := binPath + ".new"
+	if err := os.WriteFile(newPath, data, 0o755); err != nil {
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file, so set it explicitly
+	if err := os.Chmod(newPath, 0o755); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+
+	if err := keepPrevious(binPath); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("keeping previous binary: %w", err)
+	}
+	if err := os.Rename(newPath, binPath); err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("installing new binary: %w", err)
+	}
+	return nil
+}
+
+// keepPrevious preserves the current binary at PreviousPath without
+// moving it, by hard link where possible and by copy otherwise.
+func keepPrevious(binPath string) error {
+	prevPath := PreviousPath(binPath)
+	if err := os.Remove(prevPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(binPath, prevPath); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(binPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(prevPath, data, 0o755)
+}
+
+// Rollback swaps the binary at binPath with the one kept by the last
+// Install. Running it again swaps back.
+func Rollback(binPath string) error {
+	prevPath := PreviousPath(binPath)
+	if _, err := os.Stat(prevPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no previous version to roll back to (%s not found)", prevPath)
+		}
+		return err
+	}
+
+	tmpPath := binPath + ".rollback"
+	if err := os.Rename(binPath, tmpPath); err != nil {
+		return fmt.Errorf("moving current binary: %w", err)
+	}
+	if err := os.Rename(prevPath, binPath); err != nil {
+		os.Rename(tmpPath, binPath)
+		return fmt.Errorf("restoring previous binary: %w", err)
+	}
+	if err := os.Rename(tmpPath, prevPath); err != nil {
+		return fmt.Errorf("keeping replaced binary: %w", err)
+	}
+	return nil
+}
+
+// errNotFound is returned by fetch for a 404.
+var errNotFound = errors.New("not found")
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyChecksum checks data against name's SHA-256 in a checksums.txt
+// ("<hex>  <name>" per line).
+func verifyChecksum(data []byte, name string, sums []byte) error {
+	var expected string
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			expected = fields[0]
+			break
+		}
+	}
+	if expected == "" {
+		return fmt.Errorf("checksum not found for %s", name)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+	return nil
+}
+
+// extractBinary returns the emu-sync executable from a .tar.gz archive.
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in archive", binaryName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && filepath.Base(hdr.Name) == binaryName {
+			return io.ReadAll(tr)
+		}
+	}
+}
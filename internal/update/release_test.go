@@ -0,0 +1,158 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeArchive builds a release .tar.gz containing an emu-sync binary.
+func makeArchive(t *testing.T, binary []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0o644, Size: 2, Typeflag: tar.TypeReg})
+	tw.Write([]byte("hi"))
+	tw.WriteHeader(&tar.Header{Name: "emu-sync", Mode: 0o755, Size: int64(len(binary)), Typeflag: tar.TypeReg})
+	tw.Write(binary)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// serveRelease serves v1.2.3's archive and checksums.txt.
+func serveRelease(t *testing.T, archive []byte, checksums string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.2.3/checksums.txt":
+			w.Write([]byte(checksums))
+		case "/v1.2.3/" + AssetName():
+			w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	orig := releaseDownloadURL
+	releaseDownloadURL = srv.URL
+	t.Cleanup(func() { releaseDownloadURL = orig })
+}
+
+func TestDownloadRelease(t *testing.T) {
+	archive := makeArchive(t, []byte("new binary"))
+	sum := sha256.Sum256(archive)
+	serveRelease(t, archive, fmt.Sprintf("%s  other.tar.gz\n%s  %s\n",
+		strings.Repeat("0", 64), hex.EncodeToString(sum[:]), AssetName()))
+
+	got, err := DownloadRelease("v1.2.3")
+	if err != nil {
+		t.Fatalf("DownloadRelease: %v", err)
+	}
+	if string(got) != "new binary" {
+		t.Errorf("binary = %q, want %q", got, "new binary")
+	}
+}
+
+func TestDownloadReleaseChecksumMismatch(t *testing.T) {
+	archive := makeArchive(t, []byte("tampered"))
+	serveRelease(t, archive, fmt.Sprintf("%s  %s\n", strings.Repeat("a", 64), AssetName()))
+
+	_, err := DownloadRelease("v1.2.3")
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("err = %v, want checksum mismatch", err)
+	}
+}
+
+func TestDownloadReleaseMissingChecksum(t *testing.T) {
+	archive := makeArchive(t, []byte("new binary"))
+	serveRelease(t, archive, "")
+
+	_, err := DownloadRelease("v1.2.3")
+	if err == nil || !strings.Contains(err.Error(), "checksum not found") {
+		t.Errorf("err = %v, want checksum not found", err)
+	}
+}
+
+func TestDownloadReleaseUnknownVersion(t *testing.T) {
+	serveRelease(t, nil, "")
+	_, err := DownloadRelease("v9.9.9")
+	if err == nil || !strings.Contains(err.Error(), "release v9.9.9 not found") {
+		t.Errorf("err = %v, want release not found", err)
+	}
+}
+
+func TestInstallReplacesOlderPrevious(t *testing.T) {
+	binPath := filepath.Join(t.TempDir(), "emu-sync")
+	os.WriteFile(binPath, []byte("v1"), 0o755)
+
+	if err := Install(binPath, []byte("v2")); err != nil {
+		t.Fatalf("first Install: %v", err)
+	}
+	if err := Install(binPath, []byte("v3")); err != nil {
+		t.Fatalf("second Install: %v", err)
+	}
+	assertContent(t, binPath, "v3")
+	assertContent(t, PreviousPath(binPath), "v2")
+	if _, err := os.Stat(binPath + ".new"); !os.IsNotExist(err) {
+		t.Error("temporary .new file should not remain")
+	}
+}
+
+func TestInstallAndRollback(t *testing.T) {
+	binPath := filepath.Join(t.TempDir(), "emu-sync")
+	os.WriteFile(binPath, []byte("old"), 0o755)
+
+	if err := Install(binPath, []byte("new")); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	assertContent(t, binPath, "new")
+	assertContent(t, PreviousPath(binPath), "old")
+	if info, _ := os.Stat(binPath); info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("installed binary mode = %v, want executable", info.Mode())
+	}
+
+	if err := Rollback(binPath); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	assertContent(t, binPath, "old")
+	assertContent(t, PreviousPath(binPath), "new")
+
+	// Rolling back again swaps back
+	if err := Rollback(binPath); err != nil {
+		t.Fatalf("second Rollback: %v", err)
+	}
+	assertContent(t, binPath, "new")
+}
+
+func TestRollbackWithoutPrevious(t *testing.T) {
+	binPath := filepath.Join(t.TempDir(), "emu-sync")
+	os.WriteFile(binPath, []byte("current"), 0o755)
+
+	if err := Rollback(binPath); err == nil || !strings.Contains(err.Error(), "no previous version") {
+		t.Errorf("err = %v, want no previous version", err)
+	}
+	assertContent(t, binPath, "current")
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", filepath.Base(path), data, want)
+	}
+}
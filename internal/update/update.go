@@ -21,13 +21,21 @@ const (
 
 var latestReleaseURL = "https://github.com/jacobfgrant/emu-sync/releases/latest"
 
-const installScriptURL = "https://raw.githubusercontent.com/jacobfgrant/emu-sync/master/install.sh"
+// transport is used for requests to GitHub; nil = http.DefaultTransport.
+var transport http.RoundTripper
+
+// SetTransport configures the HTTP transport used to check for and
+// download updates, e.g. to go through a proxy.
+func SetTransport(rt http.RoundTripper) {
+	transport = rt
+}
 
 // CheckLatestVersion queries GitHub for the latest release tag.
 // Uses an HTTP HEAD with redirect capture to avoid reading the response body.
 func CheckLatestVersion() (string, error) {
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Transport: transport,
+		Timeout:   10 * time.Second,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -128,33 +136,12 @@ func RunBrewUpgrade() error {
 	return cmd.Run()
 }
 
-// RunScriptUpdate downloads and runs the install script for the given version.
-func RunScriptUpdate(version string) error {
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(installScriptURL)
-	if err != nil {
-		return fmt.Errorf("downloading install script: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status %d downloading install script", resp.StatusCode)
-	}
-
-	tmpFile, err := os.CreateTemp("", "emu-sync-install-*.sh")
-	if err != nil {
-		return fmt.Errorf("creating temp file: %w", err)
-	}
-	defer os.Remove(tmpFile.Name())
-
-	if _, err := tmpFile.ReadFrom(resp.Body); err != nil {
-		tmpFile.Close()
-		return fmt.Errorf("writing install script: %w", err)
-	}
-	tmpFile.Close()
-
-	cmd := exec.Command("sh", tmpFile.Name())
-	cmd.Env = append(os.Environ(), "EMU_SYNC_VERSION="+version)
+// RefreshSchedule reinstalls the sync schedule and shortcuts using the
+// binary at binPath, as install.sh does on upgrade, so installed units
+// match that version's flags.
+func RefreshSchedule(binPath string) error {
+	exec.Command(binPath, "uninstall").Run()
+	cmd := exec.Command(binPath, "install")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
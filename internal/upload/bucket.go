@@ -0,0 +1,229 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+// bucketCacheEntry records the MD5 found for an object whose ETag isn't
+// one, so it is only looked up or downloaded again if the object changes.
+type bucketCacheEntry struct {
+	ETag  string     `json:"etag"`
+	Size  int64      `json:"size"`
+	MD5   string     `json:"md5"`
+	Zeros [][2]int64 `json:"zeros,omitempty"`
+}
+
+// manifestFromBucket rebuilds the manifest from a listing of the bucket
+// instead of the source directory, for curators who change the bucket
+// with other tools. Each object's MD5 comes from its ETag when that is a
+// plain MD5, then from the metadata emu-sync stores on upload, and
+// otherwise from downloading and hashing it once. The
+// manifest is only written if every object could be hashed, since a
+// missing entry would make recipients delete the file.
+func manifestFromBucket(ctx context.Context, client storage.Backend, opts Options) (*Result, error) {
+	result := &Result{}
+
+	scanDirs := opts.SyncDirs
+	if opts.Merge && len(opts.OwnedDirs) > 0 {
+		scanDirs = opts.OwnedDirs
+	}
+
+	oldManifest, err := loadRemoteManifest(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Listing bucket...")
+	objects, err := client.ListObjects(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := config.DefaultBucketCachePath()
+	if opts.CachePath != "" {
+		cachePath = filepath.Join(filepath.Dir(opts.CachePath), "bucket-cache.json")
+	}
+	cache := loadBucketCache(cachePath)
+
+	newManifest := manifest.New()
+	for _, obj := range objects {
+		if strings.HasSuffix(obj.Key, "/") || !underDirs(obj.Key, scanDirs) {
+			continue
+		}
+		if opts.SkipDotfiles && hasDotComponent(obj.Key) {
+			continue
+		}
+		// One bad key would make every client reject the whole manifest.
+		if err := manifest.ValidKey(obj.Key); err != nil {
+			msg := fmt.Sprintf("skipping %s: %v", obj.Key, err)
+			log.Printf("warning: %s", msg)
+			if opts.Progress != nil {
+				opts.Progress.Warning(msg)
+			}
+			continue
+		}
+		entry, err := bucketEntry(ctx, client, obj, oldManifest.Files[obj.Key], cache, result)
+		if err != nil {
+			result.Errors = append(result.Errors, syncerr.New("hash", obj.Key, err))
+			continue
+		}
+		entry.Tags = config.TagsFor(obj.Key, opts.Tuning)
+		newManifest.Files[obj.Key] = entry
+	}
+	log.Printf("Found %d files (%d downloaded to hash)", len(newManifest.Files), result.Rehashed)
+
+	if !opts.DryRun {
+		for key := range cache {
+			if _, ok := newManifest.Files[key]; !ok {
+				delete(cache, key)
+			}
+		}
+		saveBucketCache(cache, cachePath)
+	}
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("%d objects could not be hashed (first: %v); manifest not updated", len(result.Errors), result.Errors[0])
+	}
+
+	if opts.Merge {
+		result.Preserved = mergeManifest(newManifest, oldManifest, scanDirs)
+	}
+	// Objects can't be renamed without copying them, so keys that differ
+	// only in case are always refused here.
+	keys := make([]string, 0, len(newManifest.Files))
+	for key := range newManifest.Files {
+		keys = append(keys, key)
+	}
+	if groups := manifest.CaseCollisions(keys); len(groups) > 0 {
+		return nil, caseCollisionError(groups, "Rename them in the bucket (emu-sync mv) and run again.")
+	}
+	newManifest.CaseCollisions = manifest.CaseFail
+	result.Skipped = len(newManifest.Files) - result.Preserved
+
+	var dropped int
+	for key := range oldManifest.Files {
+		if _, ok := newManifest.Files[key]; !ok {
+			dropped++
+		}
+	}
+	if err := checkDeleteThreshold(dropped, len(oldManifest.Files), opts); err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if err := publishManifest(ctx, client, newManifest, opts); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// bucketEntry returns the manifest entry for a listed object.
+func bucketEntry(ctx context.Context, client storage.Backend, obj storage.ObjectInfo, old manifest.FileEntry, cache map[string]bucketCacheEntry, result *Result) (manifest.FileEntry, error) {
+	etag := strings.Trim(obj.ETag, `"`)
+	entry := manifest.FileEntry{
+		Size:         obj.Size,
+		ContentType:  storage.ContentType(obj.Key),
+		StorageClass: obj.StorageClass,
+	}
+
+	if hash := obj.ContentMD5(); hash != "" {
+		entry.MD5 = hash
+		return keepOld(entry, old), nil
+	}
+	if cached, ok := cache[obj.Key]; ok && cached.ETag == etag && cached.Size == obj.Size {
+		entry.MD5, entry.Zeros = cached.MD5, cached.Zeros
+		result.CacheHits++
+		return keepOld(entry, old), nil
+	}
+
+	// Listings don't include metadata, so check for the MD5 emu-sync
+	// stored on upload before falling back to a download.
+	if info, err := client.HeadObject(ctx, obj.Key); err == nil && info.ContentMD5() != "" {
+		entry.MD5 = info.ContentMD5()
+		cache[obj.Key] = bucketCacheEntry{ETag: etag, Size: obj.Size, MD5: entry.MD5}
+		return keepOld(entry, old), nil
+	}
+
+	logging.Printf(logging.Files, "hashing: %s", obj.Key)
+	hash, zeros, err := hashObject(ctx, client, obj.Key)
+	if err != nil {
+		return entry, fmt.Errorf("hashing %s: %w", obj.Key, err)
+	}
+	entry.MD5, entry.Zeros = hash, zeros
+	cache[obj.Key] = bucketCacheEntry{ETag: etag, Size: obj.Size, MD5: hash, Zeros: zeros}
+	result.Rehashed++
+	return keepOld(entry, old), nil
+}
+
+// keepOld copies the Content-Type and zero regions from the old manifest
+// entry when the content is unchanged.
+func keepOld(entry, old manifest.FileEntry) manifest.FileEntry {
+	if old.MD5 == entry.MD5 && old.Size == entry.Size {
+		entry.ContentType = old.ContentType
+		if entry.Zeros == nil {
+			entry.Zeros = old.Zeros
+		}
+	}
+	return entry
+}
+
+// hashObject streams an object through manifest.ScanReader.
+func hashObject(ctx context.Context, client storage.Backend, key string) (string, [][2]int64, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(client.DownloadTo(ctx, key, pw))
+	}()
+	hash, zeros, err := manifest.ScanReader(pr)
+	pr.Close()
+	return hash, zeros, err
+}
+
+// hasDotComponent reports whether any element of key starts with ".".
+func hasDotComponent(key string) bool {
+	for _, part := range strings.Split(key, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func loadBucketCache(path string) map[string]bucketCacheEntry {
+	cache := make(map[string]bucketCacheEntry)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Printf("warning: corrupt bucket cache, rebuilding: %v", err)
+		return make(map[string]bucketCacheEntry)
+	}
+	return cache
+}
+
+func saveBucketCache(cache map[string]bucketCacheEntry, path string) {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		logging.Printf(logging.Debug, "warning: failed to save bucket cache: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestManifestFromBucket(t *testing.T) {
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/gba/Game.gba"] = []byte("game")
+	mock.Objects["roms/ps2/Big.iso"] = []byte("big image")
+	mock.ETags["roms/ps2/Big.iso"] = "0123456789abcdef0123456789abcdef-3"
+	mock.Objects["roms/gba/.DS_Store"] = []byte("junk")
+	mock.Objects["other/notes.txt"] = []byte("not synced")
+
+	opts := Options{
+		SyncDirs:     []string{"roms"},
+		ManifestOnly: true,
+		FromBucket:   true,
+		SkipDotfiles: true,
+		CachePath:    filepath.Join(t.TempDir(), "upload-cache.json"),
+	}
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Rehashed != 1 {
+		t.Errorf("rehashed %d objects, want only the multipart one", result.Rehashed)
+	}
+
+	m := remoteManifest(t, mock)
+	if len(m.Files) != 2 {
+		t.Fatalf("manifest = %v, want Game.gba and Big.iso only", m.Files)
+	}
+	for key, content := range map[string]string{"roms/gba/Game.gba": "game", "roms/ps2/Big.iso": "big image"} {
+		want, _, _ := manifest.ScanReader(strings.NewReader(content))
+		if m.Files[key].MD5 != want {
+			t.Errorf("%s MD5 = %q, want %q", key, m.Files[key].MD5, want)
+		}
+	}
+
+	// A second run reuses the hash computed for the multipart object
+	mock.Calls = nil
+	result, err = Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if result.Rehashed != 0 || result.CacheHits != 1 {
+		t.Errorf("rehashed %d, cache hits %d; want 0 and 1", result.Rehashed, result.CacheHits)
+	}
+}
+
+func TestManifestFromBucketSkipsInvalidKeys(t *testing.T) {
+	mock := storage.NewMockBackend()
+	mock.Objects["roms/gba/Game.gba"] = []byte("game")
+	mock.Objects["roms//Lost.gba"] = []byte("lost")
+	mock.Objects["roms/gba/../Escape.gba"] = []byte("escape")
+
+	opts := Options{
+		SyncDirs:     []string{"roms"},
+		ManifestOnly: true,
+		FromBucket:   true,
+		CachePath:    filepath.Join(t.TempDir(), "upload-cache.json"),
+	}
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	m := remoteManifest(t, mock)
+	if len(m.Files) != 1 || m.Files["roms/gba/Game.gba"].MD5 == "" {
+		t.Errorf("manifest = %v, want only roms/gba/Game.gba", m.Files)
+	}
+}
+
+func TestManifestFromBucketUsesMetadataMD5(t *testing.T) {
+	src := t.TempDir()
+	path := filepath.Join(src, "Big.iso")
+	os.WriteFile(path, []byte("big image"), 0o644)
+	want, _, _ := manifest.ScanFile(path)
+
+	mock := storage.NewMockBackend()
+	mock.UploadFile(context.Background(), "roms/ps2/Big.iso", path, want)
+	mock.ETags["roms/ps2/Big.iso"] = "0123456789abcdef0123456789abcdef-3"
+	mock.DownloadErrors["roms/ps2/Big.iso"] = storage.ErrSimulated
+
+	result, err := Run(context.Background(), mock, Options{
+		SyncDirs:     []string{"roms"},
+		ManifestOnly: true,
+		FromBucket:   true,
+		CachePath:    filepath.Join(t.TempDir(), "upload-cache.json"),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if result.Rehashed != 0 {
+		t.Errorf("rehashed %d objects, want 0 (MD5 is in the metadata)", result.Rehashed)
+	}
+	if m := remoteManifest(t, mock); m.Files["roms/ps2/Big.iso"].MD5 != want {
+		t.Errorf("MD5 = %q, want %q", m.Files["roms/ps2/Big.iso"].MD5, want)
+	}
+}
+
+func TestManifestFromBucketKeepsManifestOnHashError(t *testing.T) {
+	mock := storage.NewMockBackend()
+	old := manifest.New()
+	old.Files["roms/ps2/Big.iso"] = manifest.FileEntry{Size: 9, MD5: "old"}
+	data, _ := old.ToJSON()
+	mock.UploadManifest(context.Background(), data)
+	mock.Objects["roms/ps2/Big.iso"] = []byte("big image")
+	mock.ETags["roms/ps2/Big.iso"] = "0123456789abcdef0123456789abcdef-3"
+	mock.DownloadErrors["roms/ps2/Big.iso"] = storage.ErrSimulated
+
+	_, err := Run(context.Background(), mock, Options{
+		SyncDirs:     []string{"roms"},
+		ManifestOnly: true,
+		FromBucket:   true,
+		CachePath:    filepath.Join(t.TempDir(), "upload-cache.json"),
+	})
+	if err == nil {
+		t.Fatal("expected an error when an object can't be hashed")
+	}
+	if m := remoteManifest(t, mock); m.Files["roms/ps2/Big.iso"].MD5 != "old" {
+		t.Error("manifest was rewritten despite the error")
+	}
+}
@@ -9,13 +9,29 @@ import (
 )
 
 type cacheEntry struct {
-	Size  int64     `json:"size"`
-	Mtime time.Time `json:"mtime"`
-	MD5   string    `json:"md5"`
+	Size  int64      `json:"size"`
+	Mtime time.Time  `json:"mtime"`
+	MD5   string     `json:"md5"`
+	Zeros [][2]int64 `json:"zeros,omitempty"`
+}
+
+// dirIndexRacyWindow is how old a directory's mtime must be before it is
+// indexed. FAT/exFAT timestamps have 2s resolution, so a directory changed
+// again within that window could keep the same mtime.
+const dirIndexRacyWindow = 2 * time.Second
+
+// dirEntry records a directory's mtime and direct children as of the last
+// scan. If the mtime is unchanged, no entries were added, removed, or
+// renamed, so the directory doesn't need to be listed again.
+type dirEntry struct {
+	Mtime   time.Time `json:"mtime"`
+	Files   []string  `json:"files,omitempty"`
+	Subdirs []string  `json:"subdirs,omitempty"`
 }
 
 type hashCache struct {
 	Files map[string]cacheEntry `json:"files"`
+	Dirs  map[string]dirEntry   `json:"dirs,omitempty"`
 }
 
 func newHashCache() *hashCache {
@@ -53,19 +69,19 @@ func (c *hashCache) save(path string) error {
 	return os.WriteFile(path, data, 0o644)
 }
 
-func (c *hashCache) lookup(key string, size int64, mtime time.Time) (string, bool) {
+func (c *hashCache) lookup(key string, size int64, mtime time.Time) (cacheEntry, bool) {
 	entry, ok := c.Files[key]
 	if !ok {
-		return "", false
+		return cacheEntry{}, false
 	}
 	if entry.Size != size || !entry.Mtime.Equal(mtime) {
-		return "", false
+		return cacheEntry{}, false
 	}
-	return entry.MD5, true
+	return entry, true
 }
 
-func (c *hashCache) update(key string, size int64, mtime time.Time, md5 string) {
-	c.Files[key] = cacheEntry{Size: size, Mtime: mtime, MD5: md5}
+func (c *hashCache) update(key string, size int64, mtime time.Time, md5 string, zeros [][2]int64) {
+	c.Files[key] = cacheEntry{Size: size, Mtime: mtime, MD5: md5, Zeros: zeros}
 }
 
 // prune removes entries not present in the given key set.
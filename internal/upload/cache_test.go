@@ -10,21 +10,21 @@ import (
 func TestCacheLookupHit(t *testing.T) {
 	c := newHashCache()
 	mtime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
-	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123")
+	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123", nil)
 
-	hash, ok := c.lookup("roms/snes/Game.sfc", 1024, mtime)
+	cached, ok := c.lookup("roms/snes/Game.sfc", 1024, mtime)
 	if !ok {
 		t.Fatal("expected cache hit")
 	}
-	if hash != "abc123" {
-		t.Errorf("hash = %q, want %q", hash, "abc123")
+	if cached.MD5 != "abc123" {
+		t.Errorf("hash = %q, want %q", cached.MD5, "abc123")
 	}
 }
 
 func TestCacheLookupMissWrongSize(t *testing.T) {
 	c := newHashCache()
 	mtime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
-	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123")
+	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123", nil)
 
 	_, ok := c.lookup("roms/snes/Game.sfc", 2048, mtime)
 	if ok {
@@ -35,7 +35,7 @@ func TestCacheLookupMissWrongSize(t *testing.T) {
 func TestCacheLookupMissWrongMtime(t *testing.T) {
 	c := newHashCache()
 	mtime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
-	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123")
+	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123", nil)
 
 	_, ok := c.lookup("roms/snes/Game.sfc", 1024, mtime.Add(time.Second))
 	if ok {
@@ -58,8 +58,8 @@ func TestCacheSaveAndLoad(t *testing.T) {
 
 	c := newHashCache()
 	mtime := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
-	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123")
-	c.update("bios/scph5501.bin", 512, mtime, "def456")
+	c.update("roms/snes/Game.sfc", 1024, mtime, "abc123", nil)
+	c.update("bios/scph5501.bin", 512, mtime, "def456", nil)
 
 	if err := c.save(path); err != nil {
 		t.Fatalf("save: %v", err)
@@ -70,9 +70,9 @@ func TestCacheSaveAndLoad(t *testing.T) {
 		t.Fatalf("loaded %d entries, want 2", len(loaded.Files))
 	}
 
-	hash, ok := loaded.lookup("roms/snes/Game.sfc", 1024, mtime)
-	if !ok || hash != "abc123" {
-		t.Errorf("round-trip failed: ok=%v hash=%q", ok, hash)
+	cached, ok := loaded.lookup("roms/snes/Game.sfc", 1024, mtime)
+	if !ok || cached.MD5 != "abc123" {
+		t.Errorf("round-trip failed: ok=%v hash=%q", ok, cached.MD5)
 	}
 }
 
@@ -97,8 +97,8 @@ func TestLoadCacheCorruptFile(t *testing.T) {
 func TestCachePrune(t *testing.T) {
 	c := newHashCache()
 	mtime := time.Now()
-	c.update("keep-me", 100, mtime, "aaa")
-	c.update("remove-me", 200, mtime, "bbb")
+	c.update("keep-me", 100, mtime, "aaa", nil)
+	c.update("remove-me", 200, mtime, "bbb", nil)
 
 	c.prune(map[string]struct{}{"keep-me": {}})
 
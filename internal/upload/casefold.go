@@ -0,0 +1,99 @@
+package upload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+)
+
+// resolveCaseCollisions applies policy to keys in m that differ only in
+// case and records the policy in m. Under manifest.CaseRename every key
+// in a group but the first (in byte order) gets a numbered name, e.g.
+// game (2).sfc, so each upload of the same files picks the same names.
+// Only keys under owned are renamed; one kept from another uploader
+// keeps its name. It returns the renamed keys, mapped to their source
+// paths.
+func resolveCaseCollisions(m *manifest.Manifest, owned []string, policy string) (map[string]string, error) {
+	if policy == "" {
+		policy = manifest.CaseFail
+	}
+	m.CaseCollisions = policy
+
+	keys := make([]string, 0, len(m.Files))
+	for key := range m.Files {
+		keys = append(keys, key)
+	}
+	groups := manifest.CaseCollisions(keys)
+	if len(groups) == 0 {
+		return nil, nil
+	}
+	if policy != manifest.CaseRename {
+		return nil, caseCollisionError(groups, "Rename them in the source, or set sync.case_collisions = \"rename\" to upload them under numbered names.")
+	}
+
+	folded := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		folded[strings.ToLower(key)] = true
+	}
+	taken := func(key string) bool { return folded[strings.ToLower(key)] }
+
+	renamed := make(map[string]string)
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return !underDirs(group[i], owned) && underDirs(group[j], owned)
+		})
+		for _, key := range group[1:] {
+			if !underDirs(key, owned) {
+				continue
+			}
+			newKey := manifest.CaseRenamedKey(key, taken)
+			folded[strings.ToLower(newKey)] = true
+			src := sourceKey(m, key)
+			m.Files[newKey] = m.Files[key]
+			delete(m.Files, key)
+			delete(m.Renamed, key)
+			if m.Renamed == nil {
+				m.Renamed = make(map[string]string)
+			}
+			m.Renamed[newKey] = src
+			renamed[newKey] = src
+		}
+	}
+	return renamed, nil
+}
+
+// caseCollisionError lists groups of keys that differ only in case,
+// followed by hint.
+func caseCollisionError(groups [][]string, hint string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d sets of files have names that differ only in case and would overwrite each other on macOS, Windows, and exFAT SD cards:\n", len(groups))
+	for _, group := range groups {
+		fmt.Fprintf(&b, "  %s\n", strings.Join(group, ", "))
+	}
+	b.WriteString("\n" + hint)
+	return fmt.Errorf("%s", b.String())
+}
+
+// checkCaseCollision returns an error if adding key to m would give it
+// two keys that differ only in case.
+func checkCaseCollision(m *manifest.Manifest, key string) error {
+	fold := strings.ToLower(key)
+	for existing := range m.Files {
+		if existing != key && strings.ToLower(existing) == fold {
+			return fmt.Errorf("%s differs only in case from %s, which is already in the library; the two would overwrite each other on macOS, Windows, and exFAT SD cards", key, existing)
+		}
+	}
+	return nil
+}
+
+// sourceKey returns the path, relative to the source directory, that
+// key in m was uploaded from. It differs from key only for keys renamed
+// for case collisions.
+func sourceKey(m *manifest.Manifest, key string) string {
+	if src, ok := m.Renamed[key]; ok {
+		return src
+	}
+	return key
+}
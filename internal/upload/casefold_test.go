@@ -0,0 +1,96 @@
+package upload
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestUploadCaseCollisions(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game.sfc":  "upper",
+		"roms/snes/game.sfc":  "lower",
+		"roms/snes/Other.sfc": "other",
+	})
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+
+	_, err := Run(context.Background(), mock, opts)
+	if err == nil || !strings.Contains(err.Error(), "roms/snes/Game.sfc, roms/snes/game.sfc") {
+		t.Fatalf("Run = %v, want an error listing the collision", err)
+	}
+	if len(mock.Objects) != 0 {
+		t.Error("nothing should be uploaded when collisions are refused")
+	}
+
+	opts.CaseCollisions = manifest.CaseRename
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("Run with rename: %v", err)
+	}
+	if result.CaseRenamed["roms/snes/game (2).sfc"] != "roms/snes/game.sfc" {
+		t.Errorf("renamed = %v, want game.sfc uploaded as game (2).sfc", result.CaseRenamed)
+	}
+	if string(mock.Objects["roms/snes/game (2).sfc"]) != "lower" || string(mock.Objects["roms/snes/Game.sfc"]) != "upper" {
+		t.Error("bucket should hold each file under its own name")
+	}
+	m := remoteManifest(t, mock)
+	if m.CaseCollisions != manifest.CaseRename || m.Renamed["roms/snes/game (2).sfc"] != "roms/snes/game.sfc" {
+		t.Errorf("manifest policy = %q, renamed = %v", m.CaseCollisions, m.Renamed)
+	}
+	if _, ok := m.Files["roms/snes/game.sfc"]; ok {
+		t.Error("the colliding key should not be in the manifest")
+	}
+
+	// The same files get the same names next time, so nothing changes
+	result, err = Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Uploaded) != 0 || len(result.Deleted) != 0 {
+		t.Errorf("second run uploaded %v and deleted %v, want no changes", result.Uploaded, result.Deleted)
+	}
+}
+
+func TestPutRejectsCaseCollision(t *testing.T) {
+	mock := storage.NewMockBackend()
+	existing := manifest.New()
+	existing.Files["roms/gba/Game.gba"] = manifest.FileEntry{Size: 5, MD5: "abc"}
+	data, _ := existing.ToJSON()
+	mock.UploadManifest(context.Background(), data)
+
+	local := setupSourceDir(t, map[string]string{"game.gba": "new game"})
+	if _, err := Put(context.Background(), mock, local+"/game.gba", "roms/gba/game.gba", nil, 0); err == nil {
+		t.Error("Put should refuse a key that differs only in case from an existing one")
+	}
+}
+
+func TestUploadNormalizesKeys(t *testing.T) {
+	nfd := "roms/snes/\u30ab\u3099.sfc" // ガ decomposed, as macOS may name it
+	nfc := "roms/snes/\u30ac.sfc"
+	source := setupSourceDir(t, map[string]string{nfd: "game"})
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	m := remoteManifest(t, mock)
+	if _, ok := m.Files[nfc]; !ok || m.Renamed[nfc] != nfd {
+		t.Errorf("manifest keys = %v, renamed = %v; want the NFC key uploaded from the NFD file", m.Files, m.Renamed)
+	}
+	if string(mock.Objects[nfc]) != "game" {
+		t.Error("file should be uploaded under its NFC key")
+	}
+
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Uploaded) != 0 || len(result.Deleted) != 0 || result.CacheHits != 1 {
+		t.Errorf("second run uploaded %v, deleted %v, cache hits %d; want no changes", result.Uploaded, result.Deleted, result.CacheHits)
+	}
+}
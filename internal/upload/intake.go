@@ -0,0 +1,147 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/retry"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+// IntakePrefix is where files contributed through presigned upload links
+// land. Nothing under it is in the manifest until the curator accepts it.
+const IntakePrefix = "intake/"
+
+// IntakeKey returns the bucket key for a contribution named name, which
+// may be given with or without the intake/ prefix.
+func IntakeKey(name string) (string, error) {
+	name = strings.TrimPrefix(name, IntakePrefix)
+	if err := libraryKey(name); err != nil {
+		return "", err
+	}
+	return IntakePrefix + name, nil
+}
+
+// ListIntake returns the contributions waiting under IntakePrefix, oldest
+// first.
+func ListIntake(ctx context.Context, client storage.Backend) ([]storage.ObjectInfo, error) {
+	objects, err := client.ListObjects(ctx, IntakePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var waiting []storage.ObjectInfo
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, "/") {
+			waiting = append(waiting, obj)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		if !waiting[i].LastModified.Equal(waiting[j].LastModified) {
+			return waiting[i].LastModified.Before(waiting[j].LastModified)
+		}
+		return waiting[i].Key < waiting[j].Key
+	})
+	return waiting, nil
+}
+
+// Accept moves a contribution from the intake into the library under
+// key and adds it to the remote manifest, like Put but copying inside
+// the bucket instead of uploading. The intake object is deleted once the
+// manifest is updated; failing to delete it only logs a warning.
+func Accept(ctx context.Context, client storage.Backend, src, key string, tuning map[string]config.TuningConfig, maxRetries int) (*PutResult, error) {
+	if strings.HasPrefix(key, IntakePrefix) {
+		return nil, fmt.Errorf("invalid key %q: choose a library path outside %s", key, IntakePrefix)
+	}
+	if err := libraryKey(key); err != nil {
+		return nil, err
+	}
+
+	result, err := copyIn(ctx, client, src, key, tuning, maxRetries)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Reject(ctx, client, src, maxRetries); err != nil {
+		log.Printf("warning: %s left in the intake: %v", src, err)
+	}
+	return result, nil
+}
+
+// copyIn copies src to key inside the bucket and adds it to the remote
+// manifest, like Put but without uploading. If key is replaced and keeps
+// versions, its current content is archived first.
+func copyIn(ctx context.Context, client storage.Backend, src, key string, tuning map[string]config.TuningConfig, maxRetries int) (*PutResult, error) {
+	info, err := client.HeadObject(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	hash := info.ContentMD5()
+	var zeros [][2]int64
+	if hash == "" {
+		logging.Printf(logging.Files, "hashing: %s", src)
+		hash, zeros, err = hashObject(ctx, client, src)
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %w", src, err)
+		}
+	}
+	result := &PutResult{Key: key, Entry: manifest.FileEntry{
+		Size:         info.Size,
+		MD5:          hash,
+		ContentType:  storage.ContentType(key),
+		StorageClass: config.StorageClassFor(key, tuning),
+		Tags:         config.TagsFor(key, tuning),
+		Zeros:        zeros,
+	}}
+
+	remote, err := loadRemoteManifest(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if old, ok := remote.Files[key]; ok {
+		if old.MD5 == hash && old.Size == info.Size {
+			result.Unchanged = true
+		} else {
+			result.Replaced = true
+		}
+	} else if err := checkCaseCollision(remote, key); err != nil {
+		return nil, err
+	}
+
+	if !result.Unchanged {
+		keep := config.KeepVersionsFor(key, tuning)
+		if result.Replaced && keep > 0 {
+			if err := archive(ctx, client, key, maxRetries); err != nil {
+				return nil, err
+			}
+			defer pruneVersions(ctx, client, key, keep, maxRetries)
+		}
+		logging.Printf(logging.Files, "copying: %s -> %s", src, key)
+		err = retry.WithBackoff(ctx, maxRetries, func() error {
+			return client.CopyObject(ctx, src, key)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("copy %s: %w", src, err)
+		}
+		err = updateManifest(ctx, client, func(m *manifest.Manifest) {
+			m.Files[key] = result.Entry
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Reject deletes a contribution from the intake.
+func Reject(ctx context.Context, client storage.Backend, src string, maxRetries int) error {
+	logging.Printf(logging.Files, "deleting from bucket: %s", src)
+	return retry.WithBackoff(ctx, maxRetries, func() error {
+		return client.DeleteObject(ctx, src)
+	})
+}
@@ -0,0 +1,48 @@
+package upload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestAcceptMovesIntakeIntoLibrary(t *testing.T) {
+	mock := storage.NewMockBackend()
+	data, _ := manifest.New().ToJSON()
+	mock.UploadManifest(context.Background(), data)
+	mock.Objects["intake/Game.sfc"] = []byte("contributed")
+
+	result, err := Accept(context.Background(), mock, "intake/Game.sfc", "roms/snes/Game.sfc", nil, 0)
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if string(mock.Objects["roms/snes/Game.sfc"]) != "contributed" {
+		t.Error("contribution was not copied into the library")
+	}
+	if _, ok := mock.Objects["intake/Game.sfc"]; ok {
+		t.Error("intake object should be deleted")
+	}
+	entry := remoteManifest(t, mock).Files["roms/snes/Game.sfc"]
+	if entry.MD5 == "" || entry.MD5 != result.Entry.MD5 || entry.Size != int64(len("contributed")) {
+		t.Errorf("manifest entry = %+v, want the contribution's size and MD5", entry)
+	}
+
+	// Accepting content the library already has leaves the manifest alone
+	mock.Objects["intake/Copy.sfc"] = []byte("contributed")
+	result, err = Accept(context.Background(), mock, "intake/Copy.sfc", "roms/snes/Game.sfc", nil, 0)
+	if err != nil {
+		t.Fatalf("second Accept: %v", err)
+	}
+	if !result.Unchanged {
+		t.Error("identical content should be reported unchanged")
+	}
+	if _, ok := mock.Objects["intake/Copy.sfc"]; ok {
+		t.Error("duplicate should still be removed from the intake")
+	}
+
+	if _, err := Accept(context.Background(), mock, "intake/Game.sfc", "intake/Other.sfc", nil, 0); err == nil {
+		t.Error("accepting into the intake should fail")
+	}
+}
@@ -0,0 +1,111 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/retry"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+// Move is one key being renamed.
+type Move struct {
+	From, To string
+}
+
+// MoveResult describes what MoveKeys did.
+type MoveResult struct {
+	Moved  []Move
+	Errors []error // old objects that couldn't be deleted after the move
+}
+
+// PlanMoves returns the renames for moving src to dst. If src is a file
+// in the manifest, dst is its new key, or the directory to move it into
+// if dst ends with a slash. Otherwise src is a directory and everything
+// under it moves under dst. Fails if nothing matches or a destination
+// key is already taken.
+func PlanMoves(m *manifest.Manifest, src, dst string) ([]Move, error) {
+	src = strings.TrimSuffix(src, "/")
+	var moves []Move
+	if _, ok := m.Files[src]; ok {
+		key, err := PutKey(src, dst)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, Move{src, key})
+	} else {
+		dir := strings.TrimSuffix(dst, "/")
+		if err := libraryKey(dir); err != nil {
+			return nil, err
+		}
+		for _, key := range MatchKeys(m, []string{src}) {
+			moves = append(moves, Move{key, dir + strings.TrimPrefix(key, src)})
+		}
+	}
+	if len(moves) == 0 {
+		return nil, fmt.Errorf("nothing in the library matches %s", src)
+	}
+
+	for _, mv := range moves {
+		if mv.From == mv.To {
+			return nil, fmt.Errorf("%s is already at %s", mv.From, mv.To)
+		}
+		if _, ok := m.Files[mv.To]; ok {
+			return nil, fmt.Errorf("%s already exists", mv.To)
+		}
+	}
+	sort.Slice(moves, func(i, j int) bool { return moves[i].From < moves[j].From })
+	return moves, nil
+}
+
+// MoveKeys renames keys in the bucket: each object is copied server-side,
+// the manifest is rewritten, and then the old objects are deleted. Copies
+// come first so recipients never see an entry they can't download. A
+// recipient that has the old file renames it on its next sync rather
+// than downloading it again, since the content is unchanged.
+func MoveKeys(ctx context.Context, client storage.Backend, moves []Move, tuning map[string]config.TuningConfig, maxRetries int) (*MoveResult, error) {
+	for _, mv := range moves {
+		logging.Printf(logging.Files, "copying: %s -> %s", mv.From, mv.To)
+		err := retry.WithBackoff(ctx, maxRetries, func() error {
+			return client.CopyObject(ctx, mv.From, mv.To)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("copy %s: %w", mv.From, err)
+		}
+	}
+
+	err := updateManifest(ctx, client, func(m *manifest.Manifest) {
+		for _, mv := range moves {
+			entry, ok := m.Files[mv.From]
+			if !ok {
+				continue
+			}
+			delete(m.Files, mv.From)
+			entry.ContentType = storage.ContentType(mv.To)
+			entry.StorageClass = config.StorageClassFor(mv.To, tuning)
+			entry.Tags = config.TagsFor(mv.To, tuning)
+			m.Files[mv.To] = entry
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MoveResult{Moved: moves}
+	for _, mv := range moves {
+		logging.Printf(logging.Files, "deleting from bucket: %s", mv.From)
+		err := retry.WithBackoff(ctx, maxRetries, func() error {
+			return client.DeleteObject(ctx, mv.From)
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, syncerr.New("delete", mv.From, fmt.Errorf("delete %s: %w", mv.From, err)))
+		}
+	}
+	return result, nil
+}
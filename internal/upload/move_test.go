@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestPlanMoves(t *testing.T) {
+	m := manifest.New()
+	m.Files["roms/gba/Pokemon Emrald.gba"] = manifest.FileEntry{Size: 1, MD5: "a"}
+	m.Files["roms/psx/A.chd"] = manifest.FileEntry{Size: 1, MD5: "b"}
+	m.Files["roms/psx/disc/B.chd"] = manifest.FileEntry{Size: 1, MD5: "c"}
+	m.Files["roms/psx2/C.iso"] = manifest.FileEntry{Size: 1, MD5: "d"}
+
+	moves, err := PlanMoves(m, "roms/gba/Pokemon Emrald.gba", "roms/gba/Pokemon Emerald.gba")
+	if err != nil || len(moves) != 1 || moves[0].To != "roms/gba/Pokemon Emerald.gba" {
+		t.Errorf("file rename = %v, %v", moves, err)
+	}
+
+	moves, err = PlanMoves(m, "roms/psx", "roms/ps1/")
+	if err != nil {
+		t.Fatalf("directory move: %v", err)
+	}
+	want := []Move{{"roms/psx/A.chd", "roms/ps1/A.chd"}, {"roms/psx/disc/B.chd", "roms/ps1/disc/B.chd"}}
+	if len(moves) != len(want) || moves[0] != want[0] || moves[1] != want[1] {
+		t.Errorf("directory move = %v, want %v (not roms/psx2)", moves, want)
+	}
+
+	if _, err := PlanMoves(m, "roms/psx/A.chd", "roms/psx2/C.iso"); err == nil {
+		t.Error("moving onto an existing key should fail")
+	}
+	if _, err := PlanMoves(m, "roms/n64", "roms/nintendo64"); err == nil {
+		t.Error("moving nothing should fail")
+	}
+}
+
+func TestMoveKeys(t *testing.T) {
+	mock := storage.NewMockBackend()
+	m := manifest.New()
+	m.Files["roms/psx/A.chd"] = manifest.FileEntry{Size: 4, MD5: "a"}
+	mock.Objects["roms/psx/A.chd"] = []byte("data")
+	data, _ := m.ToJSON()
+	mock.UploadManifest(context.Background(), data)
+
+	result, err := MoveKeys(context.Background(), mock, []Move{{"roms/psx/A.chd", "roms/ps1/A.chd"}}, nil, 0)
+	if err != nil {
+		t.Fatalf("MoveKeys: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("errors: %v", result.Errors)
+	}
+	if string(mock.Objects["roms/ps1/A.chd"]) != "data" {
+		t.Error("object was not copied")
+	}
+	if _, ok := mock.Objects["roms/psx/A.chd"]; ok {
+		t.Error("old object was not deleted")
+	}
+	remote := remoteManifest(t, mock)
+	if _, ok := remote.Files["roms/psx/A.chd"]; ok {
+		t.Error("old key still in manifest")
+	}
+	if remote.Files["roms/ps1/A.chd"].MD5 != "a" {
+		t.Error("new key missing from manifest or content changed")
+	}
+}
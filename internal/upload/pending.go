@@ -0,0 +1,97 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+// ErrPendingSuperseded is returned by PublishPending when the bucket's
+// manifest was published after the pending one was built, e.g. by
+// another uploader, so publishing it would undo their changes. The
+// pending manifest is discarded.
+var ErrPendingSuperseded = errors.New("the bucket's manifest is newer than the pending one")
+
+// pendingPath returns where a manifest that failed to upload is kept:
+// beside the upload cache when that's overridden, as in tests.
+func pendingPath(opts Options) string {
+	if opts.CachePath != "" {
+		return filepath.Join(filepath.Dir(opts.CachePath), "pending-manifest.json")
+	}
+	return config.DefaultPendingManifestPath()
+}
+
+// publishManifest uploads m as the bucket's manifest. If that fails, m
+// is kept locally, so the files uploaded and hashed for it aren't
+// wasted: the next run, or PublishPending, publishes it.
+func publishManifest(ctx context.Context, client storage.Backend, m *manifest.Manifest, opts Options) error {
+	data, err := m.ToJSON()
+	if err != nil {
+		return fmt.Errorf("serializing manifest: %w", err)
+	}
+	if err := client.UploadManifest(ctx, data); err != nil {
+		path := pendingPath(opts)
+		if saveErr := m.SaveJSON(path); saveErr != nil {
+			return fmt.Errorf("uploading manifest: %w (and keeping it to retry: %v)", err, saveErr)
+		}
+		return fmt.Errorf("uploading manifest: %w\n\nThe manifest was saved to %s; run upload --publish-pending, or upload again, to publish it.", err, path)
+	}
+	// A pending manifest from an earlier run is out of date now.
+	if err := os.Remove(pendingPath(opts)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logging.Printf(logging.Debug, "warning: removing pending manifest: %v", err)
+	}
+	return nil
+}
+
+// PublishPending uploads the manifest a previous run failed to publish,
+// if there is one, without scanning or uploading any files. It reports
+// whether there was one to publish. A pending manifest older than the
+// bucket's is discarded with ErrPendingSuperseded.
+func PublishPending(ctx context.Context, client storage.Backend, opts Options) (bool, error) {
+	path := pendingPath(opts)
+	pending, err := manifest.LoadJSON(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading pending manifest: %w", err)
+	}
+
+	remote, err := loadRemoteManifest(ctx, client)
+	if err != nil {
+		return false, err
+	}
+	if !remote.IsEmpty() && remote.GeneratedAt.After(pending.GeneratedAt) {
+		os.Remove(path)
+		return false, ErrPendingSuperseded
+	}
+
+	log.Printf("Publishing the manifest left by the last upload (%d files)...", len(pending.Files))
+	if err := publishManifest(ctx, client, pending, opts); err != nil {
+		return false, err
+	}
+	return true, saveLocalManifest(pending, opts)
+}
+
+// retryPending publishes a pending manifest before a run starts, so the
+// run compares against what the last run actually uploaded instead of
+// uploading it all again. Failing is only a warning: the run's own
+// manifest replaces the pending one.
+func retryPending(ctx context.Context, client storage.Backend, opts Options) {
+	if _, err := PublishPending(ctx, client, opts); err != nil {
+		msg := fmt.Sprintf("pending manifest not published: %v", err)
+		log.Printf("warning: %s", msg)
+		if opts.Progress != nil {
+			opts.Progress.Warning(msg)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestFailedManifestUploadIsRetried(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game.sfc": "snes rom data",
+	})
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+
+	mock.UploadErrors[storage.ManifestKey] = errors.New("connection reset")
+	if _, err := Run(context.Background(), mock, opts); err == nil {
+		t.Fatal("Run should fail when the manifest can't be uploaded")
+	}
+	pending, err := manifest.LoadJSON(pendingPath(opts))
+	if err != nil {
+		t.Fatalf("pending manifest not kept: %v", err)
+	}
+	if _, ok := pending.Files["roms/snes/Game.sfc"]; !ok {
+		t.Error("pending manifest is missing the uploaded file")
+	}
+
+	// The next run publishes it first, so the file isn't uploaded again.
+	delete(mock.UploadErrors, storage.ManifestKey)
+	mock.Calls = nil
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(result.Uploaded) != 0 || slices.Contains(mock.Calls, "UploadFile:roms/snes/Game.sfc") {
+		t.Errorf("uploaded %v again; calls %v", result.Uploaded, mock.Calls)
+	}
+	if _, ok := remoteManifest(t, mock).Files["roms/snes/Game.sfc"]; !ok {
+		t.Error("manifest is missing the file")
+	}
+	if _, err := os.Stat(pendingPath(opts)); !os.IsNotExist(err) {
+		t.Errorf("pending manifest not removed: %v", err)
+	}
+}
+
+func TestPublishPending(t *testing.T) {
+	mock := storage.NewMockBackend()
+	opts := Options{CachePath: tempCachePath(t)}
+
+	if published, err := PublishPending(context.Background(), mock, opts); published || err != nil {
+		t.Fatalf("PublishPending with nothing pending = %v, %v", published, err)
+	}
+
+	pending := manifest.New()
+	pending.Files["roms/gba/Game.gba"] = manifest.FileEntry{Size: 4, MD5: "abc"}
+	if err := pending.SaveJSON(pendingPath(opts)); err != nil {
+		t.Fatal(err)
+	}
+	published, err := PublishPending(context.Background(), mock, opts)
+	if !published || err != nil {
+		t.Fatalf("PublishPending = %v, %v; want published", published, err)
+	}
+	if _, ok := remoteManifest(t, mock).Files["roms/gba/Game.gba"]; !ok {
+		t.Error("pending manifest wasn't published")
+	}
+
+	// A manifest published since, e.g. by another uploader, wins.
+	pending.GeneratedAt = time.Now().Add(-time.Hour)
+	if err := pending.SaveJSON(pendingPath(opts)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PublishPending(context.Background(), mock, opts); !errors.Is(err, ErrPendingSuperseded) {
+		t.Errorf("PublishPending over a newer manifest = %v, want ErrPendingSuperseded", err)
+	}
+	if _, err := os.Stat(pendingPath(opts)); !os.IsNotExist(err) {
+		t.Error("superseded pending manifest wasn't discarded")
+	}
+}
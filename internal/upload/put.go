@@ -0,0 +1,166 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/retry"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+// manifestAttempts is how many times updateManifest re-reads and re-applies
+// a change when another uploader changes the manifest first.
+const manifestAttempts = 5
+
+// PutResult describes what Put did.
+type PutResult struct {
+	Key       string
+	Entry     manifest.FileEntry
+	Unchanged bool // the bucket already had identical content under Key
+	Replaced  bool // Key existed with different content
+}
+
+// PutKey returns the key a file is published under: dest itself, or the
+// file's name inside dest if dest ends with a slash, normalized like
+// upload's keys. Keys must be relative and may not contain "." or ".."
+// elements.
+func PutKey(localPath, dest string) (string, error) {
+	key := dest
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += path.Base(strings.ReplaceAll(localPath, "\\", "/"))
+	}
+	key = manifest.NormalizeKey(key)
+	if err := libraryKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// libraryKey checks that key is a manifest.ValidKey that upload may
+// write to: not one reserved for the manifest or archived versions.
+func libraryKey(key string) error {
+	if err := manifest.ValidKey(key); err != nil {
+		return fmt.Errorf("%w; use a relative path like roms/gba/Game.gba", err)
+	}
+	if key == storage.ManifestKey || key == storage.ManifestGzipKey {
+		return fmt.Errorf("invalid key %q: reserved for the manifest", key)
+	}
+	if strings.HasPrefix(key, VersionsPrefix) {
+		return fmt.Errorf("invalid key %q: %s is reserved for archived versions", key, VersionsPrefix)
+	}
+	return nil
+}
+
+// Put uploads one file under key and adds it to the remote manifest
+// without scanning the rest of the library. The object is uploaded before
+// the manifest changes, so recipients never see an entry they can't
+// download. Content the bucket already has is not uploaded again. If the
+// file replaces one that keeps versions, the old content is archived.
+func Put(ctx context.Context, client storage.Backend, localPath, key string, tuning map[string]config.TuningConfig, maxRetries int) (*PutResult, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", localPath)
+	}
+
+	logging.Printf(logging.Files, "hashing: %s", localPath)
+	hash, zeros, err := manifest.ScanFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("hashing %s: %w", localPath, err)
+	}
+	result := &PutResult{Key: key, Entry: manifest.FileEntry{
+		Size:         info.Size(),
+		MD5:          hash,
+		ContentType:  storage.ContentType(key),
+		StorageClass: config.StorageClassFor(key, tuning),
+		Tags:         config.TagsFor(key, tuning),
+		Zeros:        zeros,
+	}}
+
+	remote, err := loadRemoteManifest(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if old, ok := remote.Files[key]; ok {
+		if old.MD5 == hash && old.Size == info.Size() {
+			result.Unchanged = true
+			return result, nil
+		}
+		result.Replaced = true
+	} else if err := checkCaseCollision(remote, key); err != nil {
+		return nil, err
+	}
+
+	if keep := config.KeepVersionsFor(key, tuning); result.Replaced && keep > 0 {
+		if err := archive(ctx, client, key, maxRetries); err != nil {
+			return nil, err
+		}
+		defer pruneVersions(ctx, client, key, keep, maxRetries)
+	}
+
+	logging.Printf(logging.Files, "uploading: %s", key)
+	err = retry.WithBackoff(ctx, maxRetries, func() error {
+		return client.UploadFile(ctx, key, localPath, hash)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("upload %s: %w", key, err)
+	}
+
+	err = updateManifest(ctx, client, func(m *manifest.Manifest) {
+		m.Files[key] = result.Entry
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// updateManifest applies change to the current remote manifest and writes
+// it back. If the manifest changes while it is being read and modified,
+// it starts over from the new version, so a concurrent upload's entries
+// aren't lost. Buckets offer no portable compare-and-swap, so this only
+// narrows the window rather than closing it.
+func updateManifest(ctx context.Context, client storage.Backend, change func(m *manifest.Manifest)) error {
+	for attempt := 1; ; attempt++ {
+		before, err := storage.ManifestVersion(ctx, client)
+		if err != nil {
+			return fmt.Errorf("checking manifest: %w", err)
+		}
+		m, err := loadRemoteManifest(ctx, client)
+		if err != nil {
+			return err
+		}
+		change(m)
+		m.GeneratedAt = time.Now().UTC()
+		data, err := m.ToJSON()
+		if err != nil {
+			return fmt.Errorf("serializing manifest: %w", err)
+		}
+
+		after, err := storage.ManifestVersion(ctx, client)
+		if err != nil {
+			return fmt.Errorf("checking manifest: %w", err)
+		}
+		if after != before {
+			if attempt == manifestAttempts {
+				return fmt.Errorf("manifest kept changing during update; try again")
+			}
+			logging.Printf(logging.Debug, "manifest changed during update, retrying")
+			continue
+		}
+
+		if err := client.UploadManifest(ctx, data); err != nil {
+			return fmt.Errorf("uploading manifest: %w", err)
+		}
+		return nil
+	}
+}
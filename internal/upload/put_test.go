@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestPutKey(t *testing.T) {
+	tests := []struct {
+		local, dest, want string
+	}{
+		{"./Game.gba", "roms/gba/", "roms/gba/Game.gba"},
+		{"/tmp/x/Game.gba", "roms/gba/Renamed.gba", "roms/gba/Renamed.gba"},
+		{"Game.gba", "", "Game.gba"},
+	}
+	for _, tt := range tests {
+		got, err := PutKey(tt.local, tt.dest)
+		if err != nil || got != tt.want {
+			t.Errorf("PutKey(%q, %q) = %q, %v; want %q", tt.local, tt.dest, got, err, tt.want)
+		}
+	}
+
+	for _, dest := range []string{"/roms/gba/", "../roms/", "roms/../../x", "roms//gba/x", storage.ManifestKey} {
+		if _, err := PutKey("Game.gba", dest); err == nil {
+			t.Errorf("PutKey(%q) should fail", dest)
+		}
+	}
+}
+
+func TestPutAddsToManifest(t *testing.T) {
+	mock := storage.NewMockBackend()
+	existing := manifest.New()
+	existing.Files["roms/snes/Other.sfc"] = manifest.FileEntry{Size: 5, MD5: "abc"}
+	data, _ := existing.ToJSON()
+	mock.UploadManifest(context.Background(), data)
+
+	local := filepath.Join(t.TempDir(), "Game.gba")
+	os.WriteFile(local, []byte("new game"), 0o644)
+
+	result, err := Put(context.Background(), mock, local, "roms/gba/Game.gba", nil, 0)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if result.Unchanged || result.Replaced {
+		t.Errorf("result = %+v, want a new file", result)
+	}
+	if string(mock.Objects["roms/gba/Game.gba"]) != "new game" {
+		t.Error("file was not uploaded")
+	}
+
+	m := remoteManifest(t, mock)
+	if m.Files["roms/gba/Game.gba"].MD5 != result.Entry.MD5 {
+		t.Error("manifest is missing the new file")
+	}
+	if _, ok := m.Files["roms/snes/Other.sfc"]; !ok {
+		t.Error("existing manifest entry was lost")
+	}
+
+	// Putting the same content again uploads nothing
+	calls := len(mock.Calls)
+	result, err = Put(context.Background(), mock, local, "roms/gba/Game.gba", nil, 0)
+	if err != nil {
+		t.Fatalf("second Put: %v", err)
+	}
+	if !result.Unchanged {
+		t.Error("identical content should be reported unchanged")
+	}
+	for _, call := range mock.Calls[calls:] {
+		if call == "UploadFile:roms/gba/Game.gba" {
+			t.Error("identical content was uploaded again")
+		}
+	}
+}
+
+func TestUpdateManifestRetriesAfterConcurrentChange(t *testing.T) {
+	mock := storage.NewMockBackend()
+	data, _ := manifest.New().ToJSON()
+	mock.UploadManifest(context.Background(), data)
+
+	attempts := 0
+	err := updateManifest(context.Background(), mock, func(m *manifest.Manifest) {
+		attempts++
+		if attempts == 1 {
+			// Another uploader writes the manifest while this change is applied
+			other := manifest.New()
+			other.Files["roms/snes/Theirs.sfc"] = manifest.FileEntry{Size: 1, MD5: "t"}
+			data, _ := other.ToJSON()
+			mock.UploadManifest(context.Background(), data)
+		}
+		m.Files["roms/gba/Mine.gba"] = manifest.FileEntry{Size: 1, MD5: "m"}
+	})
+	if err != nil {
+		t.Fatalf("updateManifest: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("change applied %d times, want 2", attempts)
+	}
+	m := remoteManifest(t, mock)
+	if len(m.Files) != 2 {
+		t.Errorf("manifest files = %v, want both uploaders' entries", m.Files)
+	}
+}
+
+func remoteManifest(t *testing.T, mock *storage.MockBackend) *manifest.Manifest {
+	t.Helper()
+	data, err := mock.DownloadManifest(context.Background())
+	if err != nil {
+		t.Fatalf("DownloadManifest: %v", err)
+	}
+	m, err := manifest.ParseJSON(data)
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	return m
+}
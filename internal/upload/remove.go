@@ -0,0 +1,74 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/retry"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+)
+
+// RemoveResult describes what Remove did.
+type RemoveResult struct {
+	Removed []string // keys dropped from the manifest
+	Deleted []string // objects deleted from the bucket
+	Errors  []error  // objects that couldn't be deleted
+}
+
+// MatchKeys returns the manifest keys equal to or under any of targets,
+// sorted. A trailing slash on a target is optional.
+func MatchKeys(m *manifest.Manifest, targets []string) []string {
+	var keys []string
+	for key := range m.Files {
+		for _, t := range targets {
+			t = strings.TrimSuffix(t, "/")
+			if key == t || strings.HasPrefix(key, t+"/") {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Remove drops keys from the remote manifest and then, unless keepObjects
+// is set, deletes their objects. The manifest is updated first so
+// recipients never see an entry whose object is gone; an object that
+// fails to delete is only left behind as unreferenced storage.
+func Remove(ctx context.Context, client storage.Backend, keys []string, keepObjects bool, maxRetries int) (*RemoveResult, error) {
+	result := &RemoveResult{}
+	err := updateManifest(ctx, client, func(m *manifest.Manifest) {
+		result.Removed = result.Removed[:0]
+		for _, key := range keys {
+			if _, ok := m.Files[key]; ok {
+				delete(m.Files, key)
+				result.Removed = append(result.Removed, key)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if keepObjects {
+		return result, nil
+	}
+
+	for _, key := range keys {
+		logging.Printf(logging.Files, "deleting from bucket: %s", key)
+		err := retry.WithBackoff(ctx, maxRetries, func() error {
+			return client.DeleteObject(ctx, key)
+		})
+		if err != nil {
+			result.Errors = append(result.Errors, syncerr.New("delete", key, fmt.Errorf("delete %s: %w", key, err)))
+			continue
+		}
+		result.Deleted = append(result.Deleted, key)
+	}
+	return result, nil
+}
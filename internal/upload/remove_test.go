@@ -0,0 +1,57 @@
+package upload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestRemove(t *testing.T) {
+	mock := storage.NewMockBackend()
+	m := manifest.New()
+	for _, key := range []string{"roms/psx/A.chd", "roms/psx/B.chd", "roms/psx2/C.iso", "roms/gba/D.gba"} {
+		m.Files[key] = manifest.FileEntry{Size: 1, MD5: key}
+		mock.Objects[key] = []byte("x")
+	}
+	data, _ := m.ToJSON()
+	mock.UploadManifest(context.Background(), data)
+
+	keys := MatchKeys(m, []string{"roms/psx/", "roms/gba/D.gba"})
+	want := []string{"roms/gba/D.gba", "roms/psx/A.chd", "roms/psx/B.chd"}
+	if len(keys) != len(want) {
+		t.Fatalf("MatchKeys = %v, want %v (not roms/psx2)", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("MatchKeys = %v, want %v", keys, want)
+		}
+	}
+
+	result, err := Remove(context.Background(), mock, keys[:1], true, 0)
+	if err != nil {
+		t.Fatalf("Remove --keep-object: %v", err)
+	}
+	if len(result.Removed) != 1 || len(result.Deleted) != 0 {
+		t.Errorf("result = %+v, want one manifest entry removed and no objects deleted", result)
+	}
+	if _, ok := mock.Objects["roms/gba/D.gba"]; !ok {
+		t.Error("--keep-object deleted the object")
+	}
+
+	result, err = Remove(context.Background(), mock, keys[1:], false, 0)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if len(result.Deleted) != 2 {
+		t.Errorf("deleted %v, want both psx files", result.Deleted)
+	}
+	if _, ok := mock.Objects["roms/psx/A.chd"]; ok {
+		t.Error("object was not deleted")
+	}
+	remaining := remoteManifest(t, mock)
+	if len(remaining.Files) != 1 {
+		t.Errorf("manifest = %v, want only roms/psx2/C.iso", remaining.Files)
+	}
+}
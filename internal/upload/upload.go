@@ -2,17 +2,25 @@ package upload
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/retry"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
+	"github.com/jacobfgrant/emu-sync/internal/syncerr"
+	"github.com/jacobfgrant/emu-sync/internal/units"
 )
 
 // Options controls upload behavior.
@@ -20,22 +28,54 @@ type Options struct {
 	SourcePath        string
 	SyncDirs          []string
 	DryRun            bool
-	Verbose           bool
 	ManifestOnly      bool
-	Workers           int    // number of parallel uploads; 0 or 1 = sequential
-	MaxRetries        int    // per-file retries with backoff; 0 = no retries
-	SkipDotfiles      bool   // skip files and directories starting with "."
-	CachePath         string // overrides default upload cache path; used by tests
-	LocalManifestPath string // if set, save the manifest locally after successful upload
+	Workers           int                            // number of parallel uploads; 0 or 1 = sequential
+	MaxRetries        int                            // per-file retries with backoff; 0 = no retries
+	SkipDotfiles      bool                           // skip files and directories starting with "."
+	CachePath         string                         // overrides default upload cache path; used by tests
+	LocalManifestPath string                         // if set, save the manifest locally after successful upload
+	Merge             bool                           // preserve remote entries outside OwnedDirs instead of deleting them
+	OwnedDirs         []string                       // directories this uploader owns in merge mode; defaults to SyncDirs
+	NoDelete          bool                           // keep remote files that are missing locally
+	DeleteThreshold   float64                        // abort if more than this fraction of the manifest would be deleted; 0 = no limit
+	Force             bool                           // proceed even if DeleteThreshold is exceeded
+	Tuning            map[string]config.TuningConfig // per-directory worker/retry overrides
+	FullScan          bool                           // list every directory instead of reusing the file lists of unchanged ones
+	FromBucket        bool                           // with ManifestOnly, build the manifest from a bucket listing instead of SourcePath
+	CaseCollisions    string                         // keys differing only in case: manifest.CaseFail (default) or manifest.CaseRename
+	Order             string                         // upload order; see config.SortTransfers
+	Priority          []string                       // directories uploaded first; see config.SortTransfers
+	Progress          *progress.Reporter             // emits JSON progress events; nil = no-op
 }
 
 // Result summarizes what an upload run did.
 type Result struct {
-	Uploaded  []string
-	Skipped   int
-	Deleted   []string
-	Errors    []error
-	CacheHits int
+	Uploaded      []string
+	Skipped       int
+	Deleted       []string
+	Errors        []error
+	CacheHits     int
+	Preserved     int               // remote entries outside owned dirs kept in merge mode
+	Retained      []string          // remote files missing locally, kept because delete is disabled
+	Bytes         int64             // total size of uploaded files
+	UnchangedDirs int               // directories reused from the last scan without being listed
+	Rehashed      int               // objects downloaded to compute their MD5 (FromBucket)
+	Archived      int               // replaced files whose previous version was kept under VersionsPrefix
+	CaseRenamed   map[string]string // keys renamed because another differs only in case, mapped to their source paths
+}
+
+// summary returns the run's outcome as a progress done event, for a run
+// that began at start.
+func (r *Result) summary(start time.Time) progress.Summary {
+	return progress.Summary{
+		Uploaded: len(r.Uploaded),
+		Deleted:  len(r.Deleted),
+		Retained: len(r.Retained),
+		Errors:   len(r.Errors),
+		Skipped:  r.Skipped,
+		Bytes:    r.Bytes,
+		Duration: time.Since(start),
+	}
 }
 
 // uploadResult is sent back from worker goroutines.
@@ -47,11 +87,19 @@ type uploadResult struct {
 // Run walks the source directory, computes hashes, uploads changed files,
 // and writes a new manifest to the bucket.
 func Run(ctx context.Context, client storage.Backend, opts Options) (*Result, error) {
+	if opts.FromBucket {
+		return manifestFromBucket(ctx, client, opts)
+	}
 	if err := config.ValidatePath(opts.SourcePath); err != nil {
 		return nil, fmt.Errorf("source path: %w", err)
 	}
 
 	result := &Result{}
+	start := time.Now()
+
+	if !opts.DryRun {
+		retryPending(ctx, client, opts)
+	}
 
 	cachePath := opts.CachePath
 	if cachePath == "" {
@@ -61,31 +109,90 @@ func Run(ctx context.Context, client storage.Backend, opts Options) (*Result, er
 	// Load hash cache for skipping unchanged files
 	cache := loadHashCache(cachePath)
 
+	// In merge mode only the owned directories are scanned
+	scanDirs := opts.SyncDirs
+	if opts.Merge && len(opts.OwnedDirs) > 0 {
+		scanDirs = opts.OwnedDirs
+	}
+
 	// Build a new manifest from local files
 	log.Printf("Scanning local files...")
-	newManifest, cacheHits := buildManifest(opts.SourcePath, opts.SyncDirs, opts.SkipDotfiles, opts.Verbose, cache)
+	newManifest, cacheHits, dirHits := buildManifest(opts.SourcePath, scanDirs, opts.SkipDotfiles, opts.FullScan, cache)
 	result.CacheHits = cacheHits
-	if cacheHits > 0 {
+	result.UnchangedDirs = dirHits
+	if dirHits > 0 {
+		log.Printf("Found %d files (%d cached, %d directories unchanged)", len(newManifest.Files), cacheHits, dirHits)
+	} else if cacheHits > 0 {
 		log.Printf("Found %d files (%d cached)", len(newManifest.Files), cacheHits)
 	} else {
 		log.Printf("Found %d files", len(newManifest.Files))
 	}
 
+	// In merge mode the remote manifest is needed up front so entries
+	// owned by other uploaders can be carried over unchanged.
+	var oldManifest *manifest.Manifest
+	if opts.Merge {
+		var err error
+		oldManifest, err = loadRemoteManifest(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		result.Preserved = mergeManifest(newManifest, oldManifest, scanDirs)
+		log.Printf("Merge mode: preserving %d entries outside %v", result.Preserved, scanDirs)
+	}
+
+	// Keys that differ only in case would collide on case-insensitive
+	// devices, so they're refused or renamed before anything is uploaded.
+	caseRenamed, err := resolveCaseCollisions(newManifest, scanDirs, opts.CaseCollisions)
+	if err != nil {
+		return nil, err
+	}
+	result.CaseRenamed = caseRenamed
+
 	// Save hash cache early so interrupted uploads don't lose hashes
 	if !opts.DryRun {
-		saveCache(cache, cachePath, newManifest, opts.Verbose)
+		saveCache(cache, cachePath, newManifest)
 	}
 
 	if opts.ManifestOnly {
-		result.Skipped = len(newManifest.Files)
-		if !opts.DryRun {
-			saveCache(cache, cachePath, newManifest, opts.Verbose)
-			manifestData, err := newManifest.ToJSON()
+		// The manifest alone still decides what recipients delete, so an
+		// unmounted source drive is caught here too.
+		if oldManifest == nil {
+			oldManifest, err = loadRemoteManifest(ctx, client)
 			if err != nil {
-				return nil, fmt.Errorf("serializing manifest: %w", err)
+				return nil, err
 			}
-			if err := client.UploadManifest(ctx, manifestData); err != nil {
-				return nil, fmt.Errorf("uploading manifest: %w", err)
+		}
+		var dropped []string
+		for key := range oldManifest.Files {
+			if _, ok := newManifest.Files[key]; !ok {
+				dropped = append(dropped, key)
+			}
+		}
+		sort.Strings(dropped)
+		if opts.NoDelete {
+			for _, key := range dropped {
+				newManifest.Files[key] = oldManifest.Files[key]
+				result.Retained = append(result.Retained, key)
+				if opts.Progress != nil {
+					opts.Progress.Retain(key)
+				}
+			}
+			dropped = nil
+		}
+		if err := checkDeleteThreshold(len(dropped), len(oldManifest.Files), opts); err != nil {
+			return nil, err
+		}
+
+		result.Skipped = len(newManifest.Files) - result.Preserved - len(result.Retained)
+		syncerr.Sort(result.Errors)
+		if opts.Progress != nil {
+			opts.Progress.Done(result.summary(start))
+		}
+		if !opts.DryRun {
+			saveCache(cache, cachePath, newManifest)
+			if err := publishManifest(ctx, client, newManifest, opts); err != nil {
+				return nil, err
 			}
 			if err := saveLocalManifest(newManifest, opts); err != nil {
 				return result, err
@@ -95,63 +202,146 @@ func Run(ctx context.Context, client storage.Backend, opts Options) (*Result, er
 	}
 
 	// Download existing remote manifest for diffing
-	var oldManifest *manifest.Manifest
-	remoteData, err := client.DownloadManifest(ctx)
-	if err != nil {
-		if opts.Verbose {
-			log.Printf("no existing remote manifest, assuming first upload")
-		}
-		oldManifest = manifest.New()
-	} else {
-		oldManifest, err = manifest.ParseJSON(remoteData)
+	if oldManifest == nil {
+		var err error
+		oldManifest, err = loadRemoteManifest(ctx, client)
 		if err != nil {
-			return nil, fmt.Errorf("parsing remote manifest: %w", err)
+			return nil, err
 		}
 	}
 
 	diff := manifest.Diff(newManifest, oldManifest)
 
+	// Unchanged files aren't re-uploaded, so their manifest entries keep
+	// the Content-Type and storage class their objects actually have.
+	// Tags live only in the manifest and always follow the tuning.
+	for key, entry := range newManifest.Files {
+		if old, ok := oldManifest.Files[key]; ok && old.MD5 == entry.MD5 && old.Size == entry.Size {
+			entry.ContentType = old.ContentType
+			entry.StorageClass = old.StorageClass
+		} else {
+			entry.StorageClass = config.StorageClassFor(key, opts.Tuning)
+		}
+		entry.Tags = config.TagsFor(key, opts.Tuning)
+		newManifest.Files[key] = entry
+	}
+
+	// With delete disabled, files missing locally stay in the bucket and
+	// in the manifest so recipients keep them too.
+	if opts.NoDelete {
+		for _, key := range diff.Deleted {
+			newManifest.Files[key] = oldManifest.Files[key]
+			result.Retained = append(result.Retained, key)
+			if opts.Progress != nil {
+				opts.Progress.Retain(key)
+			}
+		}
+		diff.Deleted = nil
+	}
+
+	if err := checkDeleteThreshold(len(diff.Deleted), len(oldManifest.Files), opts); err != nil {
+		return nil, err
+	}
+
+	// Files that keep versions are archived before they're overwritten.
+	// One that can't be archived isn't replaced this run.
+	var modified, archived []string
+	for _, key := range diff.Modified {
+		if config.KeepVersionsFor(key, opts.Tuning) == 0 {
+			modified = append(modified, key)
+			continue
+		}
+		if opts.DryRun {
+			fmt.Printf("would archive: %s\n", key)
+		} else if err := archive(ctx, client, key, opts.MaxRetries); err != nil {
+			err = syncerr.New("archive", key, err)
+			result.Errors = append(result.Errors, err)
+			if opts.Progress != nil {
+				opts.Progress.FileError(key, err)
+			}
+			newManifest.Files[key] = oldManifest.Files[key]
+			continue
+		}
+		result.Archived++
+		archived = append(archived, key)
+		modified = append(modified, key)
+	}
+
 	// Upload new and modified files
-	toUpload := append(diff.Added, diff.Modified...)
+	toUpload := append(diff.Added, modified...)
+	config.SortTransfers(toUpload, opts.Order, opts.Priority, func(key string) int64 {
+		return newManifest.Files[key].Size
+	})
 
 	if opts.DryRun {
 		for _, key := range toUpload {
 			fmt.Printf("would upload: %s\n", key)
 			result.Uploaded = append(result.Uploaded, key)
 		}
-	} else if opts.Workers > 1 && len(toUpload) > 1 {
-		uploadParallel(ctx, client, opts, toUpload, result)
 	} else {
-		uploadSequential(ctx, client, opts, toUpload, result)
+		if opts.Progress != nil {
+			var size int64
+			for _, key := range toUpload {
+				size += newManifest.Files[key].Size
+			}
+			opts.Progress.Plan(len(toUpload), size)
+		}
+		for _, batch := range config.BatchByTuning(toUpload, opts.Tuning, opts.Workers, opts.MaxRetries) {
+			batchOpts := opts
+			batchOpts.Workers = batch.Workers
+			batchOpts.MaxRetries = batch.MaxRetries
+			if batch.Dir != "" {
+				logging.Printf(logging.Debug, "tuning %s: workers=%d max_retries=%d", batch.Dir, batch.Workers, batch.MaxRetries)
+			}
+			if batchOpts.Workers > 1 && len(batch.Keys) > 1 {
+				uploadParallel(ctx, client, batchOpts, batch.Keys, newManifest, result)
+			} else {
+				uploadSequential(ctx, client, batchOpts, batch.Keys, newManifest, result)
+			}
+		}
 	}
 
 	// Delete remote files that no longer exist locally
+	sort.Strings(diff.Deleted)
 	for _, key := range diff.Deleted {
 		if opts.DryRun {
 			fmt.Printf("would delete from bucket: %s\n", key)
 		} else {
-			if opts.Verbose {
-				log.Printf("deleting from bucket: %s", key)
-			}
+			logging.Printf(logging.Files, "deleting from bucket: %s", key)
 			if err := client.DeleteObject(ctx, key); err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("delete %s: %w", key, err))
+				result.Errors = append(result.Errors, syncerr.New("delete", key, fmt.Errorf("delete %s: %w", key, err)))
+				if opts.Progress != nil {
+					opts.Progress.FileError(key, err)
+				}
 				continue
 			}
+			if opts.Progress != nil {
+				opts.Progress.Delete(key)
+			}
 		}
 		result.Deleted = append(result.Deleted, key)
 	}
 
-	result.Skipped = len(newManifest.Files) - len(toUpload)
+	if !opts.DryRun {
+		for _, key := range archived {
+			pruneVersions(ctx, client, key, config.KeepVersionsFor(key, opts.Tuning), opts.MaxRetries)
+		}
+	}
+
+	result.Skipped = len(newManifest.Files) - len(toUpload) - result.Preserved - len(result.Retained)
+	for _, key := range result.Uploaded {
+		result.Bytes += newManifest.Files[key].Size
+	}
+	syncerr.Sort(result.Errors)
+	if opts.Progress != nil {
+		opts.Progress.Done(result.summary(start))
+	}
 
 	// Upload the new manifest and save cache
 	if !opts.DryRun {
-		saveCache(cache, cachePath, newManifest, opts.Verbose)
-		manifestData, err := newManifest.ToJSON()
-		if err != nil {
-			return nil, fmt.Errorf("serializing manifest: %w", err)
-		}
-		if err := client.UploadManifest(ctx, manifestData); err != nil {
-			return nil, fmt.Errorf("uploading manifest: %w", err)
+		saveCache(cache, cachePath, newManifest)
+		if err := publishManifest(ctx, client, newManifest, opts); err != nil {
+			return nil, err
 		}
 		if err := saveLocalManifest(newManifest, opts); err != nil {
 			return result, err
@@ -161,13 +351,92 @@ func Run(ctx context.Context, client storage.Backend, opts Options) (*Result, er
 	return result, nil
 }
 
+// checkDeleteThreshold returns an error if deleting n of total manifest
+// entries would exceed the configured safety threshold. A source drive
+// that failed to mount looks like every file was removed, so large
+// deletions require Force. Dry runs only log a warning.
+func checkDeleteThreshold(n, total int, opts Options) error {
+	if opts.DeleteThreshold <= 0 || opts.Force || total == 0 || n == 0 {
+		return nil
+	}
+	frac := float64(n) / float64(total)
+	if frac <= opts.DeleteThreshold {
+		return nil
+	}
+	msg := fmt.Sprintf("upload would delete %d of %d files (%.0f%%) from the bucket, above the %.0f%% safety threshold",
+		n, total, frac*100, opts.DeleteThreshold*100)
+	if opts.DryRun {
+		log.Printf("warning: %s", msg)
+		if opts.Progress != nil {
+			opts.Progress.Warning(msg)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s\n\nCheck that the source directory is mounted and complete, or re-run with --force to delete anyway.", msg)
+}
+
+// loadRemoteManifest downloads and parses the remote manifest. A missing
+// manifest is treated as a first upload and returns an empty manifest.
+// Any other error is returned: mistaking an unreachable bucket for an
+// empty one would publish a manifest without everyone else's entries.
+func loadRemoteManifest(ctx context.Context, client storage.Backend) (*manifest.Manifest, error) {
+	remoteData, err := client.DownloadManifest(ctx)
+	if errors.Is(err, storage.ErrNotFound) {
+		logging.Printf(logging.Debug, "no existing remote manifest, assuming first upload")
+		return manifest.New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("downloading remote manifest: %w", err)
+	}
+	m, err := manifest.ParseJSON(remoteData)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote manifest: %w", err)
+	}
+	return m, nil
+}
+
+// mergeManifest restricts m to keys under the owned directories and
+// copies every remote entry outside them into m, so a merge upload only
+// adds, updates, or deletes keys it owns. Returns the number of remote
+// entries preserved.
+func mergeManifest(m, remote *manifest.Manifest, owned []string) int {
+	for key := range m.Files {
+		if !underDirs(key, owned) {
+			delete(m.Files, key)
+		}
+	}
+	kept := 0
+	for key, entry := range remote.Files {
+		if !underDirs(key, owned) {
+			m.Files[key] = entry
+			if src, ok := remote.Renamed[key]; ok {
+				if m.Renamed == nil {
+					m.Renamed = make(map[string]string)
+				}
+				m.Renamed[key] = src
+			}
+			kept++
+		}
+	}
+	return kept
+}
+
+// underDirs reports whether key equals or is nested under any of dirs.
+func underDirs(key string, dirs []string) bool {
+	for _, dir := range dirs {
+		dir = strings.TrimSuffix(dir, "/")
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func saveLocalManifest(m *manifest.Manifest, opts Options) error {
 	if opts.LocalManifestPath == "" {
 		return nil
 	}
-	if opts.Verbose {
-		log.Printf("saving local manifest to %s", opts.LocalManifestPath)
-	}
+	logging.Printf(logging.Debug, "saving local manifest to %s", opts.LocalManifestPath)
 	if err := m.SaveJSON(opts.LocalManifestPath); err != nil {
 		return fmt.Errorf("saving local manifest: %w", err)
 	}
@@ -175,35 +444,43 @@ func saveLocalManifest(m *manifest.Manifest, opts Options) error {
 }
 
 // saveCache prunes the cache to only keys in the manifest and writes it to disk.
-func saveCache(cache *hashCache, path string, m *manifest.Manifest, verbose bool) {
+func saveCache(cache *hashCache, path string, m *manifest.Manifest) {
 	validKeys := make(map[string]struct{}, len(m.Files))
 	for key := range m.Files {
-		validKeys[key] = struct{}{}
+		validKeys[sourceKey(m, key)] = struct{}{}
 	}
 	cache.prune(validKeys)
-	if err := cache.save(path); err != nil && verbose {
-		log.Printf("warning: failed to save upload cache: %v", err)
+	if err := cache.save(path); err != nil {
+		logging.Printf(logging.Debug, "warning: failed to save upload cache: %v", err)
 	}
 }
 
-func uploadSequential(ctx context.Context, client storage.Backend, opts Options, keys []string, result *Result) {
+func uploadSequential(ctx context.Context, client storage.Backend, opts Options, keys []string, m *manifest.Manifest, result *Result) {
+	prog := opts.Progress
 	for _, key := range keys {
-		localPath := filepath.Join(opts.SourcePath, filepath.FromSlash(key))
-		if opts.Verbose {
-			log.Printf("uploading: %s", key)
+		localPath := filepath.Join(opts.SourcePath, filepath.FromSlash(sourceKey(m, key)))
+		logging.Printf(logging.Files, "uploading: %s", key)
+		if prog != nil {
+			prog.Start(key, m.Files[key].Size)
 		}
 		err := retry.WithBackoff(ctx, opts.MaxRetries, func() error {
-			return client.UploadFile(ctx, key, localPath)
+			return client.UploadFile(ctx, key, localPath, m.Files[key].MD5)
 		})
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("upload %s: %w", key, err))
+			result.Errors = append(result.Errors, syncerr.New("upload", key, fmt.Errorf("upload %s: %w", key, err)))
+			if prog != nil {
+				prog.FileError(key, err)
+			}
 			continue
 		}
 		result.Uploaded = append(result.Uploaded, key)
+		if prog != nil {
+			prog.Complete(key)
+		}
 	}
 }
 
-func uploadParallel(ctx context.Context, client storage.Backend, opts Options, keys []string, result *Result) {
+func uploadParallel(ctx context.Context, client storage.Backend, opts Options, keys []string, m *manifest.Manifest, result *Result) {
 	jobs := make(chan string, len(keys))
 	results := make(chan uploadResult, len(keys))
 
@@ -213,12 +490,13 @@ func uploadParallel(ctx context.Context, client storage.Backend, opts Options, k
 		go func() {
 			defer wg.Done()
 			for key := range jobs {
-				localPath := filepath.Join(opts.SourcePath, filepath.FromSlash(key))
-				if opts.Verbose {
-					log.Printf("uploading: %s", key)
+				localPath := filepath.Join(opts.SourcePath, filepath.FromSlash(sourceKey(m, key)))
+				logging.Printf(logging.Files, "uploading: %s", key)
+				if opts.Progress != nil {
+					opts.Progress.Start(key, m.Files[key].Size)
 				}
 				err := retry.WithBackoff(ctx, opts.MaxRetries, func() error {
-					return client.UploadFile(ctx, key, localPath)
+					return client.UploadFile(ctx, key, localPath, m.Files[key].MD5)
 				})
 				results <- uploadResult{key: key, err: err}
 			}
@@ -235,109 +513,308 @@ func uploadParallel(ctx context.Context, client storage.Backend, opts Options, k
 		close(results)
 	}()
 
+	prog := opts.Progress
 	for ur := range results {
 		if ur.err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("upload %s: %w", ur.key, ur.err))
+			result.Errors = append(result.Errors, syncerr.New("upload", ur.key, fmt.Errorf("upload %s: %w", ur.key, ur.err)))
+			if prog != nil {
+				prog.FileError(ur.key, ur.err)
+			}
 			continue
 		}
 		result.Uploaded = append(result.Uploaded, ur.key)
+		if prog != nil {
+			prog.Complete(ur.key)
+		}
 	}
 }
 
+// scanner builds a manifest from the files under a source directory.
+type scanner struct {
+	sourcePath   string
+	skipDotfiles bool
+	fullScan     bool
+	cache        *hashCache
+	dirs         map[string]dirEntry // directory index for the next run
+	m            *manifest.Manifest
+	cacheHits    int
+	dirHits      int
+}
+
 // buildManifest walks the source directory and hashes all files.
-// When cache is non-nil, files with matching mtime+size reuse the cached hash.
-// Returns the manifest and the number of cache hits.
-func buildManifest(sourcePath string, syncDirs []string, skipDotfiles bool, verbose bool, cache *hashCache) (*manifest.Manifest, int) {
-	m := manifest.New()
-	cacheHits := 0
+// When cache is non-nil, files with matching mtime+size reuse the cached hash,
+// and directories unchanged since the last scan are reused unless fullScan
+// is set. Returns the manifest, the number of cache hits, and the number of
+// unchanged directories.
+func buildManifest(sourcePath string, syncDirs []string, skipDotfiles bool, fullScan bool, cache *hashCache) (*manifest.Manifest, int, int) {
+	s := &scanner{
+		sourcePath:   sourcePath,
+		skipDotfiles: skipDotfiles,
+		fullScan:     fullScan,
+		cache:        cache,
+		dirs:         make(map[string]dirEntry),
+		m:            manifest.New(),
+	}
 	for _, dir := range syncDirs {
 		dirPath := filepath.Join(sourcePath, dir)
 		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
-			if verbose {
-				log.Printf("skipping %s: directory does not exist", dir)
-			}
+			logging.Printf(logging.Files, "skipping %s: directory does not exist", dir)
 			continue
 		}
 
-		err := filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				if skipDotfiles && strings.HasPrefix(d.Name(), ".") {
-					return filepath.SkipDir
+		if err := s.scanDir(dirPath); err != nil {
+			logging.Printf(logging.Files, "error walking %s: %v", dirPath, err)
+		}
+	}
+	if cache != nil {
+		cache.Dirs = s.dirs
+	}
+	return s.m, s.cacheHits, s.dirHits
+}
+
+// scanDir adds the files under path to the manifest. A directory whose
+// mtime matches the last run's index had no files added, removed, or
+// renamed, so its file list is taken from the index instead of listing
+// it; each file is still stat'ed, since editing one in place doesn't
+// change its directory's mtime. Subdirectories are visited either way.
+func (s *scanner) scanDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	key, err := s.key(path)
+	if err != nil {
+		return err
+	}
+
+	if !s.fullScan && s.cache != nil {
+		if prev, ok := s.cache.Dirs[key]; ok && prev.Mtime.Equal(info.ModTime()) && s.reuse(path, key, prev) {
+			s.dirs[key] = prev
+			s.dirHits++
+			for _, sub := range prev.Subdirs {
+				if err := s.scanDir(filepath.Join(path, sub)); err != nil {
+					return err
 				}
-				return nil
-			}
-			if skipDotfiles && strings.HasPrefix(d.Name(), ".") {
-				return nil
 			}
+			return nil
+		}
+	}
 
-			relPath, err := filepath.Rel(sourcePath, path)
-			if err != nil {
-				return fmt.Errorf("computing relative path for %s: %w", path, err)
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	entry := dirEntry{Mtime: info.ModTime()}
+	for _, d := range entries {
+		if s.skipDotfiles && strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		childPath := filepath.Join(path, d.Name())
+		if d.IsDir() {
+			entry.Subdirs = append(entry.Subdirs, d.Name())
+			if err := s.scanDir(childPath); err != nil {
+				return err
 			}
-			key := filepath.ToSlash(relPath)
+			continue
+		}
+		if err := s.addFile(childPath, d); err != nil {
+			return err
+		}
+		entry.Files = append(entry.Files, d.Name())
+	}
 
-			info, err := d.Info()
-			if err != nil {
-				return fmt.Errorf("stat %s: %w", path, err)
-			}
+	// Directories modified within the filesystem's timestamp resolution
+	// could change again without a new mtime, so they're left unindexed.
+	if time.Since(info.ModTime()) >= dirIndexRacyWindow {
+		s.dirs[key] = entry
+	}
+	return nil
+}
 
-			var hash string
-			if cache != nil {
-				if cached, ok := cache.lookup(key, info.Size(), info.ModTime()); ok {
-					hash = cached
-					cacheHits++
-					if verbose {
-						log.Printf("cached: %s", key)
-					}
-				}
-			}
-			if hash == "" {
-				if verbose {
-					log.Printf("hashing: %s", key)
-				}
-				var err error
-				hash, err = manifest.HashFile(path)
-				if err != nil {
-					return fmt.Errorf("hashing %s: %w", path, err)
-				}
-				if cache != nil {
-					cache.update(key, info.Size(), info.ModTime(), hash)
-				}
-			}
+// reuse adds the files recorded for the unchanged directory at path
+// using their cached hashes. It reports false, adding nothing, if any
+// file is gone or its size or mtime no longer match the hash cache, so
+// the directory is listed and the changed files rehashed.
+func (s *scanner) reuse(path, dirKey string, prev dirEntry) bool {
+	cached := make([]cacheEntry, len(prev.Files))
+	for i, name := range prev.Files {
+		info, err := os.Stat(filepath.Join(path, name))
+		if err != nil {
+			return false
+		}
+		entry, ok := s.cache.lookup(dirKey+"/"+name, info.Size(), info.ModTime())
+		if !ok {
+			return false
+		}
+		cached[i] = entry
+	}
+	for i, name := range prev.Files {
+		fileKey := dirKey + "/" + name
+		s.add(fileKey, manifest.FileEntry{Size: cached[i].Size, MD5: cached[i].MD5, ContentType: storage.ContentType(fileKey), Zeros: cached[i].Zeros})
+		s.cacheHits++
+	}
+	return true
+}
 
-			m.Files[key] = manifest.FileEntry{
-				Size: info.Size(),
-				MD5:  hash,
-			}
-			return nil
-		})
+// addFile hashes a file (or takes its hash from the cache if its size and
+// mtime are unchanged) and adds it to the manifest.
+func (s *scanner) addFile(path string, d os.DirEntry) error {
+	key, err := s.key(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := d.Info()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	var hash string
+	var zeros [][2]int64
+	if s.cache != nil {
+		if cached, ok := s.cache.lookup(key, info.Size(), info.ModTime()); ok {
+			hash, zeros = cached.MD5, cached.Zeros
+			s.cacheHits++
+			logging.Printf(logging.Debug, "cached: %s", key)
+		}
+	}
+	if hash == "" {
+		logging.Printf(logging.Files, "hashing: %s", key)
+		var err error
+		hash, zeros, err = manifest.ScanFile(path)
 		if err != nil {
-			if verbose {
-				log.Printf("error walking %s: %v", dirPath, err)
-			}
+			return fmt.Errorf("hashing %s: %w", path, err)
+		}
+		if s.cache != nil {
+			s.cache.update(key, info.Size(), info.ModTime(), hash, zeros)
 		}
 	}
-	return m, cacheHits
+
+	s.add(key, manifest.FileEntry{
+		Size:        info.Size(),
+		MD5:         hash,
+		ContentType: storage.ContentType(key),
+		Zeros:       zeros,
+	})
+	return nil
+}
+
+// add puts the file at source path key in the manifest under its
+// normalized key (see manifest.NormalizeKey), recording the source path
+// if the two differ. If two files normalize to the same key, which only
+// a filesystem that keeps both forms (Linux) allows, the second is left
+// out with a warning.
+func (s *scanner) add(key string, entry manifest.FileEntry) {
+	normalized := manifest.NormalizeKey(key)
+	if _, taken := s.m.Files[normalized]; taken {
+		log.Printf("warning: skipping %s: another file has the same name once Unicode-normalized (%s)", key, sourceKey(s.m, normalized))
+		return
+	}
+	if normalized != key {
+		if s.m.Renamed == nil {
+			s.m.Renamed = make(map[string]string)
+		}
+		s.m.Renamed[normalized] = key
+	}
+	s.m.Files[normalized] = entry
+}
+
+// key returns the source path key (slash-separated, relative to the
+// source directory) for path. The hash cache and directory index use it
+// as is; add normalizes it for the manifest.
+func (s *scanner) key(path string) (string, error) {
+	relPath, err := filepath.Rel(s.sourcePath, path)
+	if err != nil {
+		return "", fmt.Errorf("computing relative path for %s: %w", path, err)
+	}
+	return filepath.ToSlash(relPath), nil
 }
 
 // Summary returns a human-readable summary of the upload result.
 func (r *Result) Summary() string {
 	var b strings.Builder
-	fmt.Fprintf(&b, "Uploaded: %d files\n", len(r.Uploaded))
+	fmt.Fprintf(&b, "Uploaded: %d files (%s)\n", len(r.Uploaded), units.FormatSize(r.Bytes))
 	fmt.Fprintf(&b, "Skipped (unchanged): %d files\n", r.Skipped)
 	fmt.Fprintf(&b, "Deleted from bucket: %d files\n", len(r.Deleted))
+	if len(r.Retained) > 0 {
+		fmt.Fprintf(&b, "Retained in bucket: %d files (missing locally, delete disabled)\n", len(r.Retained))
+	}
+	if len(r.CaseRenamed) > 0 {
+		fmt.Fprintf(&b, "Renamed (names differ only in case): %d files\n", len(r.CaseRenamed))
+		keys := make([]string, 0, len(r.CaseRenamed))
+		for key := range r.CaseRenamed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "  %s -> %s\n", r.CaseRenamed[key], key)
+		}
+	}
+	if r.Preserved > 0 {
+		fmt.Fprintf(&b, "Preserved (other uploaders): %d files\n", r.Preserved)
+	}
+	if r.Archived > 0 {
+		fmt.Fprintf(&b, "Archived previous versions: %d files\n", r.Archived)
+	}
 	if r.CacheHits > 0 {
 		fmt.Fprintf(&b, "Hash cache hits: %d files\n", r.CacheHits)
 	}
+	if r.Rehashed > 0 {
+		fmt.Fprintf(&b, "Downloaded to hash: %d files\n", r.Rehashed)
+	}
+	r.writeErrors(&b)
+	fmt.Fprintf(&b, "Total: %d files\n", len(r.Uploaded)+r.Skipped)
+	return b.String()
+}
+
+// Short returns the summary as a single line, for desktop notifications
+// and mail subjects.
+func (r *Result) Short() string {
+	var parts []string
+	if len(r.Uploaded) > 0 {
+		parts = append(parts, fmt.Sprintf("uploaded %d files (%s)", len(r.Uploaded), units.FormatSize(r.Bytes)))
+	}
+	if len(r.Deleted) > 0 {
+		parts = append(parts, fmt.Sprintf("deleted %d files from bucket", len(r.Deleted)))
+	}
 	if len(r.Errors) > 0 {
-		fmt.Fprintf(&b, "Errors: %d\n", len(r.Errors))
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Bucket up to date (%d files)", r.Skipped)
+	}
+	line := strings.Join(parts, ", ")
+	return strings.ToUpper(line[:1]) + line[1:]
+}
+
+// MarshalJSON encodes the result for upload --summary-format json.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Uploaded    int                 `json:"uploaded"`
+		Skipped     int                 `json:"skipped"`
+		Deleted     int                 `json:"deleted"`
+		Retained    int                 `json:"retained,omitempty"`
+		Preserved   int                 `json:"preserved,omitempty"`
+		Archived    int                 `json:"archived,omitempty"`
+		CaseRenamed map[string]string   `json:"case_renamed,omitempty"`
+		Bytes       int64               `json:"bytes"`
+		Errors      []syncerr.ErrorJSON `json:"errors,omitempty"`
+	}{len(r.Uploaded), r.Skipped, len(r.Deleted), len(r.Retained), r.Preserved, r.Archived, r.CaseRenamed, r.Bytes, syncerr.JSONList(r.Errors)})
+}
+
+// Problems is the part of Summary that needs attention: the errors. It
+// is empty after a clean upload.
+func (r *Result) Problems() string {
+	var b strings.Builder
+	r.writeErrors(&b)
+	return b.String()
+}
+
+func (r *Result) writeErrors(b *strings.Builder) {
+	if len(r.Errors) > 0 {
+		fmt.Fprintf(b, "Errors: %d\n", len(r.Errors))
 		for _, err := range r.Errors {
-			fmt.Fprintf(&b, "  - %v\n", err)
+			fmt.Fprintf(b, "  - %v\n", err)
 		}
 	}
-	fmt.Fprintf(&b, "Total: %d files\n", len(r.Uploaded)+r.Skipped)
-	return b.String()
 }
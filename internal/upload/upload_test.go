@@ -1,6 +1,7 @@
 package upload
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -9,7 +10,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jacobfgrant/emu-sync/internal/config"
 	"github.com/jacobfgrant/emu-sync/internal/manifest"
+	"github.com/jacobfgrant/emu-sync/internal/progress"
 	"github.com/jacobfgrant/emu-sync/internal/storage"
 )
 
@@ -644,8 +647,359 @@ func TestUploadRejectsMissingSourcePath(t *testing.T) {
 	}
 }
 
+func TestUploadMergePreservesOtherOwners(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game.sfc": "snes data",
+	})
+
+	// Another curator already published roms/gba
+	mock := storage.NewMockBackend()
+	remote := manifest.New()
+	remote.Files["roms/gba/Other.gba"] = manifest.FileEntry{Size: 5, MD5: "abc"}
+	remote.Files["roms/snes/Old.sfc"] = manifest.FileEntry{Size: 3, MD5: "def"}
+	data, _ := remote.ToJSON()
+	mock.Objects[storage.ManifestKey] = data
+
+	result, err := Run(context.Background(), mock, Options{
+		SourcePath: source,
+		SyncDirs:   []string{"roms"},
+		OwnedDirs:  []string{"roms/snes"},
+		Merge:      true,
+		CachePath:  tempCachePath(t),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if result.Preserved != 1 {
+		t.Errorf("preserved %d, want 1", result.Preserved)
+	}
+	if len(result.Uploaded) != 1 {
+		t.Errorf("uploaded %d, want 1", len(result.Uploaded))
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != "roms/snes/Old.sfc" {
+		t.Errorf("deleted = %v, want [roms/snes/Old.sfc]", result.Deleted)
+	}
+
+	m := verifyManifest(t, mock)
+	if _, ok := m.Files["roms/gba/Other.gba"]; !ok {
+		t.Error("entry owned by another uploader should be preserved")
+	}
+	if _, ok := m.Files["roms/snes/Game.sfc"]; !ok {
+		t.Error("owned entry should be in manifest")
+	}
+	for _, call := range mock.Calls {
+		if call == "DeleteObject:roms/gba/Other.gba" {
+			t.Error("merge upload should not delete objects outside owned dirs")
+		}
+	}
+}
+
+func TestUploadMergeRemoteManifestError(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game.sfc": "snes data",
+	})
+
+	mock := storage.NewMockBackend()
+	remote := manifest.New()
+	remote.Files["roms/gba/Other.gba"] = manifest.FileEntry{Size: 5, MD5: "abc"}
+	data, _ := remote.ToJSON()
+	mock.Objects[storage.ManifestKey] = data
+	mock.DownloadErrors[storage.ManifestKey] = fmt.Errorf("503 slow down")
+
+	_, err := Run(context.Background(), mock, Options{
+		SourcePath: source,
+		SyncDirs:   []string{"roms"},
+		OwnedDirs:  []string{"roms/snes"},
+		Merge:      true,
+		CachePath:  tempCachePath(t),
+	})
+	if err == nil {
+		t.Fatal("Run should fail when the remote manifest can't be read")
+	}
+	delete(mock.DownloadErrors, storage.ManifestKey)
+	if _, ok := verifyManifest(t, mock).Files["roms/gba/Other.gba"]; !ok {
+		t.Error("another uploader's entry was dropped from the manifest")
+	}
+}
+
+func TestUploadMergeManifestOnly(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"bios/scph5501.bin": "bios",
+	})
+
+	mock := storage.NewMockBackend()
+	remote := manifest.New()
+	remote.Files["roms/gba/Other.gba"] = manifest.FileEntry{Size: 5, MD5: "abc"}
+	data, _ := remote.ToJSON()
+	mock.Objects[storage.ManifestKey] = data
+
+	_, err := Run(context.Background(), mock, Options{
+		SourcePath:   source,
+		SyncDirs:     []string{"bios"},
+		Merge:        true,
+		ManifestOnly: true,
+		CachePath:    tempCachePath(t),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	m := verifyManifest(t, mock)
+	if len(m.Files) != 2 {
+		t.Errorf("manifest has %d entries, want 2", len(m.Files))
+	}
+}
+
+func TestUploadNoDeleteKeepsRemoteFiles(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game1.sfc": "game 1",
+		"roms/snes/Game2.sfc": "game 2",
+	})
+
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	os.Remove(filepath.Join(source, "roms/snes/Game2.sfc"))
+
+	opts.NoDelete = true
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	if len(result.Deleted) != 0 {
+		t.Errorf("deleted %d, want 0 with NoDelete", len(result.Deleted))
+	}
+	if len(result.Retained) != 1 {
+		t.Errorf("retained %d, want 1", len(result.Retained))
+	}
+	if _, ok := mock.Objects["roms/snes/Game2.sfc"]; !ok {
+		t.Error("Game2.sfc should remain in bucket")
+	}
+	m := verifyManifest(t, mock)
+	if _, ok := m.Files["roms/snes/Game2.sfc"]; !ok {
+		t.Error("Game2.sfc should remain in manifest")
+	}
+}
+
+func TestUploadManifestOnlyDeletes(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game1.sfc": "game 1",
+		"roms/snes/Game2.sfc": "game 2",
+		"roms/snes/Game3.sfc": "game 3",
+	})
+
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// An unmounted drive looks like everything was removed.
+	os.RemoveAll(filepath.Join(source, "roms"))
+	opts.ManifestOnly = true
+	opts.DeleteThreshold = 0.2
+	if _, err := Run(context.Background(), mock, opts); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("Run = %v, want the delete threshold error", err)
+	}
+	if m := verifyManifest(t, mock); len(m.Files) != 3 {
+		t.Errorf("manifest has %d files, want all 3 kept", len(m.Files))
+	}
+
+	opts.NoDelete = true
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("Run with NoDelete: %v", err)
+	}
+	if len(result.Retained) != 3 {
+		t.Errorf("retained %d, want 3", len(result.Retained))
+	}
+	if m := verifyManifest(t, mock); len(m.Files) != 3 {
+		t.Errorf("manifest has %d files, want all 3 kept", len(m.Files))
+	}
+}
+
+func TestUploadDeleteThreshold(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game1.sfc": "game 1",
+		"roms/snes/Game2.sfc": "game 2",
+		"roms/snes/Game3.sfc": "game 3",
+	})
+
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	os.Remove(filepath.Join(source, "roms/snes/Game2.sfc"))
+	os.Remove(filepath.Join(source, "roms/snes/Game3.sfc"))
+
+	opts.DeleteThreshold = 0.2
+	_, err := Run(context.Background(), mock, opts)
+	if err == nil {
+		t.Fatal("expected error when delete threshold exceeded")
+	}
+	if !strings.Contains(err.Error(), "--force") {
+		t.Errorf("error should mention --force, got: %v", err)
+	}
+	if _, ok := mock.Objects["roms/snes/Game2.sfc"]; !ok {
+		t.Error("nothing should be deleted when threshold is exceeded")
+	}
+
+	opts.Force = true
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("forced Run: %v", err)
+	}
+	if len(result.Deleted) != 2 {
+		t.Errorf("deleted %d, want 2 with Force", len(result.Deleted))
+	}
+}
+
+func TestUploadReusesUnchangedDirectories(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game1.sfc": "game1 data",
+		"roms/gba/Game2.gba":  "game2 data",
+	})
+	backdateDirs(t, source)
+
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if result.UnchangedDirs != 3 {
+		t.Errorf("unchanged dirs = %d, want 3 (roms, roms/snes, roms/gba)", result.UnchangedDirs)
+	}
+	if result.CacheHits != 2 {
+		t.Errorf("cache hits = %d, want 2", result.CacheHits)
+	}
+
+	// Adding a file changes the directory's mtime, so it is listed again
+	os.WriteFile(filepath.Join(source, "roms/snes/Game3.sfc"), []byte("game3 data"), 0o644)
+	result, err = Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("third Run: %v", err)
+	}
+	if len(result.Uploaded) != 1 || result.Uploaded[0] != "roms/snes/Game3.sfc" {
+		t.Errorf("uploaded = %v, want [roms/snes/Game3.sfc]", result.Uploaded)
+	}
+	if result.UnchangedDirs != 2 {
+		t.Errorf("unchanged dirs = %d, want 2", result.UnchangedDirs)
+	}
+}
+
+func TestUploadDetectsInPlaceEdits(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game.sfc": "original data",
+	})
+	backdateDirs(t, source)
+
+	mock := storage.NewMockBackend()
+	opts := Options{SourcePath: source, SyncDirs: []string{"roms"}, CachePath: tempCachePath(t)}
+	if _, err := Run(context.Background(), mock, opts); err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// Rewriting an existing file doesn't change its directory's mtime,
+	// but the file's own size and mtime give it away
+	os.WriteFile(filepath.Join(source, "roms/snes/Game.sfc"), []byte("modified data"), 0o644)
+
+	result, err := Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if len(result.Uploaded) != 1 {
+		t.Errorf("uploaded = %v, want the edited file", result.Uploaded)
+	}
+	if result.UnchangedDirs != 1 {
+		t.Errorf("unchanged dirs = %d, want 1 (roms, not roms/snes)", result.UnchangedDirs)
+	}
+
+	opts.FullScan = true
+	result, err = Run(context.Background(), mock, opts)
+	if err != nil {
+		t.Fatalf("full scan Run: %v", err)
+	}
+	if len(result.Uploaded) != 0 || result.UnchangedDirs != 0 {
+		t.Errorf("full scan: uploaded %v, %d unchanged dirs; want nothing reused or uploaded", result.Uploaded, result.UnchangedDirs)
+	}
+}
+
 // --- helpers ---
 
+func TestUploadRecordsContentType(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/Game.sfc":      "snes data",
+		"roms/snes/Unchanged.sfc": "old data",
+		"media/snes/Game.png":     "png data",
+	})
+
+	// Unchanged.sfc was uploaded before content types were recorded
+	mock := storage.NewMockBackend()
+	remote := manifest.New()
+	hash, _ := manifest.HashFile(filepath.Join(source, "roms/snes/Unchanged.sfc"))
+	remote.Files["roms/snes/Unchanged.sfc"] = manifest.FileEntry{Size: 8, MD5: hash}
+	data, _ := remote.ToJSON()
+	mock.Objects[storage.ManifestKey] = data
+
+	_, err := Run(context.Background(), mock, Options{
+		SourcePath: source,
+		SyncDirs:   []string{"roms", "media"},
+		CachePath:  tempCachePath(t),
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	m := verifyManifest(t, mock)
+	if ct := m.Files["roms/snes/Game.sfc"].ContentType; ct != "application/octet-stream" {
+		t.Errorf("Game.sfc content type = %q", ct)
+	}
+	if ct := m.Files["media/snes/Game.png"].ContentType; ct != "image/png" {
+		t.Errorf("Game.png content type = %q", ct)
+	}
+	if ct := m.Files["roms/snes/Unchanged.sfc"].ContentType; ct != "" {
+		t.Errorf("Unchanged.sfc content type = %q, want empty (object not re-uploaded)", ct)
+	}
+}
+
+func TestUploadRecordsStorageClass(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/ps2/Game.iso":  "ps2 data",
+		"roms/snes/Game.sfc": "snes data",
+	})
+
+	mock := storage.NewMockBackend()
+	_, err := Run(context.Background(), mock, Options{
+		SourcePath: source,
+		SyncDirs:   []string{"roms"},
+		CachePath:  tempCachePath(t),
+		Tuning:     map[string]config.TuningConfig{"roms/ps2": {StorageClass: "STANDARD_IA"}},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	m := verifyManifest(t, mock)
+	if sc := m.Files["roms/ps2/Game.iso"].StorageClass; sc != "STANDARD_IA" {
+		t.Errorf("ps2 storage class = %q, want STANDARD_IA", sc)
+	}
+	if sc := m.Files["roms/snes/Game.sfc"].StorageClass; sc != "" {
+		t.Errorf("snes storage class = %q, want default", sc)
+	}
+}
+
 // setupSourceDir creates a temp directory tree with the given files.
 func setupSourceDir(t *testing.T, files map[string]string) string {
 	t.Helper()
@@ -681,3 +1035,53 @@ func verifyManifest(t *testing.T, mock *storage.MockBackend) *manifest.Manifest
 	}
 	return m
 }
+
+// backdateDirs sets every directory under root to an old mtime so the
+// upload directory index treats them as settled.
+func backdateDirs(t *testing.T, root string) {
+	t.Helper()
+	old := time.Now().Add(-time.Hour)
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chtimes(path, old, old)
+		}
+		return nil
+	})
+}
+
+func TestUploadProgressEvents(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"roms/snes/A.sfc": "aaaa",
+		"roms/snes/B.sfc": "bbbbbb",
+	})
+
+	for _, workers := range []int{1, 2} {
+		mock := storage.NewMockBackend()
+		mock.Objects[storage.ManifestKey] = []byte(`{"files":{"roms/snes/Old.sfc":{"md5":"x","size":1}}}`)
+
+		var events bytes.Buffer
+		_, err := Run(context.Background(), mock, Options{
+			SourcePath: source,
+			SyncDirs:   []string{"roms"},
+			CachePath:  tempCachePath(t),
+			Workers:    workers,
+			Progress:   progress.NewReporterWriter(&events),
+		})
+		if err != nil {
+			t.Fatalf("workers=%d: Run: %v", workers, err)
+		}
+
+		out := events.String()
+		for _, want := range []string{
+			`{"event":"plan","size":10,"total":2}`,
+			`{"event":"start","file":"roms/snes/A.sfc","size":4}`,
+			`{"event":"complete","file":"roms/snes/B.sfc"}`,
+			`{"event":"delete","file":"roms/snes/Old.sfc"}`,
+			`{"event":"done","uploaded":2,"deleted":1,"bytes":10,`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("workers=%d: missing %s in:\n%s", workers, want, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/logging"
+	"github.com/jacobfgrant/emu-sync/internal/retry"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+// VersionsPrefix is where upload archives the previous contents of files
+// whose tuning sets keep_versions. Each version of key is stored under
+// versions/<key>/<id>, where id is when it was archived. Nothing under it
+// is in the manifest, so recipients never download old versions.
+const VersionsPrefix = "versions/"
+
+// versionIDFormat names archived versions so they sort oldest first.
+const versionIDFormat = "20060102T150405.000Z"
+
+// Version is one archived copy of a library file.
+type Version struct {
+	ID       string
+	Size     int64
+	Archived time.Time
+}
+
+// versionKey returns the bucket key of version id of key.
+func versionKey(key, id string) string {
+	return VersionsPrefix + key + "/" + id
+}
+
+// ListVersions returns the archived versions of key, newest first.
+func ListVersions(ctx context.Context, client storage.Backend, key string) ([]Version, error) {
+	if err := libraryKey(key); err != nil {
+		return nil, err
+	}
+	prefix := versionKey(key, "")
+	objects, err := client.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var versions []Version
+	for _, obj := range objects {
+		id := strings.TrimPrefix(obj.Key, prefix)
+		archived, err := time.Parse(versionIDFormat, id)
+		if err != nil {
+			// A deeper key (versions of roms/a/b under roms/a) or a stray object
+			continue
+		}
+		versions = append(versions, Version{ID: id, Size: obj.Size, Archived: archived})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID > versions[j].ID })
+	return versions, nil
+}
+
+// archive copies the current object under key into its versions.
+func archive(ctx context.Context, client storage.Backend, key string, maxRetries int) error {
+	dst := versionKey(key, time.Now().UTC().Format(versionIDFormat))
+	logging.Printf(logging.Files, "archiving: %s -> %s", key, dst)
+	err := retry.WithBackoff(ctx, maxRetries, func() error {
+		return client.CopyObject(ctx, key, dst)
+	})
+	if err != nil {
+		return fmt.Errorf("archive %s: %w", key, err)
+	}
+	return nil
+}
+
+// pruneVersions deletes the oldest archived versions of key beyond keep.
+// Failures are only logged; an extra old version does no harm.
+func pruneVersions(ctx context.Context, client storage.Backend, key string, keep, maxRetries int) {
+	versions, err := ListVersions(ctx, client, key)
+	if err != nil {
+		logging.Printf(logging.Debug, "warning: listing versions of %s: %v", key, err)
+		return
+	}
+	for _, v := range versions[min(keep, len(versions)):] {
+		old := versionKey(key, v.ID)
+		logging.Printf(logging.Files, "deleting from bucket: %s", old)
+		err := retry.WithBackoff(ctx, maxRetries, func() error {
+			return client.DeleteObject(ctx, old)
+		})
+		if err != nil {
+			logging.Printf(logging.Debug, "warning: deleting %s: %v", old, err)
+		}
+	}
+}
+
+// Restore makes archived version id of key the current one, copying it
+// back into place and updating the manifest. The version being replaced
+// is archived first if key keeps versions, so a restore can be undone.
+func Restore(ctx context.Context, client storage.Backend, key, id string, tuning map[string]config.TuningConfig, maxRetries int) (*PutResult, error) {
+	if err := libraryKey(key); err != nil {
+		return nil, err
+	}
+	src := versionKey(key, id)
+	if _, err := time.Parse(versionIDFormat, id); err != nil {
+		return nil, fmt.Errorf("invalid version %q: use an ID from 'emu-sync versions %s'", id, key)
+	}
+	return copyIn(ctx, client, src, key, tuning, maxRetries)
+}
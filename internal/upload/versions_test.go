@@ -0,0 +1,76 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacobfgrant/emu-sync/internal/config"
+	"github.com/jacobfgrant/emu-sync/internal/storage"
+)
+
+func TestUploadKeepsVersions(t *testing.T) {
+	source := setupSourceDir(t, map[string]string{
+		"textures/pack.zip":  "v1",
+		"roms/snes/Game.sfc": "game",
+	})
+	mock := storage.NewMockBackend()
+	opts := Options{
+		SourcePath: source,
+		SyncDirs:   []string{"textures", "roms"},
+		CachePath:  tempCachePath(t),
+		Tuning:     map[string]config.TuningConfig{"textures": {KeepVersions: 2}},
+	}
+
+	for _, content := range []string{"v1", "v2.", "v3..", "v4..."} {
+		os.WriteFile(filepath.Join(source, "textures/pack.zip"), []byte(content), 0o644)
+		os.WriteFile(filepath.Join(source, "roms/snes/Game.sfc"), []byte(content+"game"), 0o644)
+		if _, err := Run(context.Background(), mock, opts); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // versions are named by the time they're archived
+	}
+
+	versions, err := ListVersions(context.Background(), mock, "textures/pack.zip")
+	if err != nil {
+		t.Fatalf("ListVersions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("got %d versions, want 2", len(versions))
+	}
+	if got := string(mock.Objects[versionKey("textures/pack.zip", versions[0].ID)]); got != "v3.." {
+		t.Errorf("newest version = %q, want v3..", got)
+	}
+	if got := string(mock.Objects[versionKey("textures/pack.zip", versions[1].ID)]); got != "v2." {
+		t.Errorf("oldest version = %q, want v2.", got)
+	}
+	if others, _ := ListVersions(context.Background(), mock, "roms/snes/Game.sfc"); len(others) != 0 {
+		t.Errorf("files without keep_versions got %d versions", len(others))
+	}
+	if _, ok := remoteManifest(t, mock).Files[versionKey("textures/pack.zip", versions[0].ID)]; ok {
+		t.Error("archived versions should not be in the manifest")
+	}
+
+	result, err := Restore(context.Background(), mock, "textures/pack.zip", versions[1].ID, opts.Tuning, 0)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !result.Replaced || string(mock.Objects["textures/pack.zip"]) != "v2." {
+		t.Errorf("after restore the file is %q, want v2.", mock.Objects["textures/pack.zip"])
+	}
+	if entry := remoteManifest(t, mock).Files["textures/pack.zip"]; entry.MD5 != result.Entry.MD5 || entry.Size != 3 {
+		t.Errorf("manifest entry = %+v, want the restored version", entry)
+	}
+
+	// The replaced version was archived, and the oldest pruned
+	versions, _ = ListVersions(context.Background(), mock, "textures/pack.zip")
+	if len(versions) != 2 || string(mock.Objects[versionKey("textures/pack.zip", versions[0].ID)]) != "v4..." {
+		t.Errorf("after restore versions = %+v, want v4... archived first", versions)
+	}
+
+	if _, err := Restore(context.Background(), mock, "textures/pack.zip", "latest", nil, 0); err == nil {
+		t.Error("restoring an invalid version ID should fail")
+	}
+}
@@ -0,0 +1,109 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Month holds accumulated transfer totals for one calendar month.
+type Month struct {
+	Uploaded   int64 `json:"uploaded"`
+	Downloaded int64 `json:"downloaded"`
+	Runs       int   `json:"runs"`
+}
+
+// Stats holds monthly transfer totals keyed by "YYYY-MM".
+type Stats struct {
+	Months map[string]*Month `json:"months"`
+}
+
+// MonthKey returns the "YYYY-MM" key for t in UTC.
+func MonthKey(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Load reads usage stats from disk. Returns empty stats if the file is
+// missing.
+func Load(path string) (*Stats, error) {
+	s := &Stats{Months: make(map[string]*Month)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading usage stats: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing usage stats: %w", err)
+	}
+	if s.Months == nil {
+		s.Months = make(map[string]*Month)
+	}
+	return s, nil
+}
+
+// Save writes usage stats to disk atomically.
+func (s *Stats) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating usage directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing usage stats: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing usage stats: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming usage stats: %w", err)
+	}
+	return nil
+}
+
+// Add accumulates one run's transfer totals into the month containing t.
+func (s *Stats) Add(t time.Time, uploaded, downloaded int64) {
+	key := MonthKey(t)
+	m, ok := s.Months[key]
+	if !ok {
+		m = &Month{}
+		s.Months[key] = m
+	}
+	m.Uploaded += uploaded
+	m.Downloaded += downloaded
+	m.Runs++
+}
+
+// Get returns the totals for the given month key, or a zero Month.
+func (s *Stats) Get(key string) Month {
+	if m, ok := s.Months[key]; ok {
+		return *m
+	}
+	return Month{}
+}
+
+// Keys returns month keys sorted newest first.
+func (s *Stats) Keys() []string {
+	keys := make([]string, 0, len(s.Months))
+	for k := range s.Months {
+		keys = append(keys, k)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return keys
+}
+
+// Record loads the stats at path, adds one run's totals for the current
+// month, and saves them back.
+func Record(path string, uploaded, downloaded int64) error {
+	s, err := Load(path)
+	if err != nil {
+		return err
+	}
+	s.Add(time.Now(), uploaded, downloaded)
+	return s.Save(path)
+}
@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAddAccumulatesByMonth(t *testing.T) {
+	s := &Stats{Months: make(map[string]*Month)}
+	oct := time.Date(2026, 10, 3, 12, 0, 0, 0, time.UTC)
+	nov := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	s.Add(oct, 100, 0)
+	s.Add(oct, 0, 250)
+	s.Add(nov, 0, 10)
+
+	got := s.Get("2026-10")
+	if got.Uploaded != 100 || got.Downloaded != 250 || got.Runs != 2 {
+		t.Errorf("2026-10 = %+v, want uploaded=100 downloaded=250 runs=2", got)
+	}
+	if keys := s.Keys(); len(keys) != 2 || keys[0] != "2026-11" {
+		t.Errorf("Keys() = %v, want newest first", keys)
+	}
+	if got := s.Get("2025-01"); got.Runs != 0 {
+		t.Errorf("missing month = %+v, want zero", got)
+	}
+}
+
+func TestRecordPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	if err := Record(path, 10, 20); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := Record(path, 5, 5); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got := s.Get(MonthKey(time.Now()))
+	if got.Uploaded != 15 || got.Downloaded != 25 || got.Runs != 2 {
+		t.Errorf("current month = %+v, want uploaded=15 downloaded=25 runs=2", got)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "nope.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.Months) != 0 {
+		t.Errorf("expected empty stats, got %d months", len(s.Months))
+	}
+}
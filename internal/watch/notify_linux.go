@@ -0,0 +1,128 @@
+//go:build linux
+
+package watch
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// inotify tracks one watch per directory under the roots.
+type inotify struct {
+	fd           int
+	file         *os.File
+	dirs         map[int32]string // watch descriptor -> directory
+	skipDotfiles bool
+}
+
+// notify watches roots with inotify, falling back to polling if inotify
+// is unavailable or the per-user watch limit is too low for the library.
+func notify(ctx context.Context, roots []string, opts Options) (<-chan string, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		log.Printf("inotify unavailable, polling instead: %v", err)
+		return poll(ctx, roots, opts), nil
+	}
+
+	w := &inotify{fd: fd, dirs: make(map[int32]string), skipDotfiles: opts.SkipDotfiles}
+	for _, root := range roots {
+		if err := w.addTree(root, nil); err != nil {
+			syscall.Close(fd)
+			log.Printf("inotify: %v; polling instead (raise fs.inotify.max_user_watches to avoid this)", err)
+			return poll(ctx, roots, opts), nil
+		}
+	}
+
+	// A non-blocking fd wrapped in an os.File uses the runtime poller, so
+	// closing the file unblocks a pending Read.
+	w.file = os.NewFile(uintptr(fd), "inotify")
+	events := make(chan string, 256)
+	go func() {
+		<-ctx.Done()
+		w.file.Close()
+	}()
+	go w.read(ctx, events)
+	return events, nil
+}
+
+// addTree watches dir and every directory beneath it. If found is non-nil,
+// it is called for each file already present, which covers files written
+// into a new directory before its watch was added.
+func (w *inotify) addTree(dir string, found func(path string)) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if w.skipDotfiles && path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			if found != nil {
+				found(path)
+			}
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+// read decodes inotify events and sends the affected paths until the
+// file is closed.
+func (w *inotify) read(ctx context.Context, events chan<- string) {
+	defer close(events)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + syscall.SizeofInotifyEvent
+			off = start + nameLen
+			if off > n {
+				break
+			}
+			name := strings.TrimRight(string(buf[start:off]), "\x00")
+
+			if mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, wd) // directory was removed
+				continue
+			}
+			dir, ok := w.dirs[wd]
+			if !ok || name == "" {
+				continue
+			}
+			path := filepath.Join(dir, name)
+
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				err := w.addTree(path, func(p string) { send(ctx, events, p) })
+				if err != nil {
+					log.Printf("inotify: %v", err)
+				}
+			}
+			if !send(ctx, events, path) {
+				return
+			}
+		}
+	}
+}
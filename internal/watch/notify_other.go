@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "context"
+
+// notify falls back to polling on platforms without inotify.
+func notify(ctx context.Context, roots []string, opts Options) (<-chan string, error) {
+	return poll(ctx, roots, opts), nil
+}
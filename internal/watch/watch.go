@@ -0,0 +1,181 @@
+// Package watch reports file changes under a set of directories.
+//
+// On Linux it uses inotify; elsewhere (or if inotify watches run out) it
+// falls back to periodically rescanning the directories.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options controls watcher behavior.
+type Options struct {
+	Debounce     time.Duration // quiet period before a batch is delivered; 0 = default (5s)
+	PollInterval time.Duration // rescan interval when polling; 0 = default (10s)
+	SkipDotfiles bool          // ignore files and directories starting with "."
+	ForcePoll    bool          // poll even if native events are available; used by tests
+}
+
+// Run watches roots until ctx is cancelled. Changed paths are collected
+// until no new change has arrived for opts.Debounce, then passed to fn as
+// one sorted batch. fn runs on the watcher goroutine; changes that arrive
+// while it runs are delivered in the next batch.
+func Run(ctx context.Context, roots []string, opts Options, fn func(paths []string)) error {
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = 5 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 10 * time.Second
+	}
+
+	var events <-chan string
+	var err error
+	if opts.ForcePoll {
+		events = poll(ctx, roots, opts)
+	} else {
+		events, err = notify(ctx, roots, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	pending := make(map[string]bool)
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		case path, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if opts.SkipDotfiles && hasDotComponent(roots, path) {
+				continue
+			}
+			pending[path] = true
+			if timer == nil {
+				timer = time.NewTimer(debounce)
+			} else {
+				timer.Reset(debounce)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			batch := make([]string, 0, len(pending))
+			for path := range pending {
+				batch = append(batch, path)
+			}
+			sort.Strings(batch)
+			pending = make(map[string]bool)
+			fn(batch)
+		}
+	}
+}
+
+// hasDotComponent reports whether any path element below its root starts
+// with ".".
+func hasDotComponent(roots []string, path string) bool {
+	rel := path
+	for _, root := range roots {
+		if r, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+			break
+		}
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// fileState is what polling compares between scans.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// poll rescans roots every opts.PollInterval and sends the paths of files
+// that were added, removed, or changed since the previous scan.
+func poll(ctx context.Context, roots []string, opts Options) <-chan string {
+	events := make(chan string, 256)
+	go func() {
+		defer close(events)
+		prev := snapshot(roots, opts.SkipDotfiles)
+		ticker := time.NewTicker(opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cur := snapshot(roots, opts.SkipDotfiles)
+			for path, st := range cur {
+				if old, ok := prev[path]; !ok || old != st {
+					if !send(ctx, events, path) {
+						return
+					}
+				}
+			}
+			for path := range prev {
+				if _, ok := cur[path]; !ok {
+					if !send(ctx, events, path) {
+						return
+					}
+				}
+			}
+			prev = cur
+		}
+	}()
+	return events
+}
+
+// snapshot records the size and mtime of every file under roots.
+func snapshot(roots []string, skipDotfiles bool) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if skipDotfiles && path != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+			return nil
+		})
+	}
+	return files
+}
+
+// send delivers path unless ctx is cancelled first.
+func send(ctx context.Context, events chan<- string, path string) bool {
+	select {
+	case events <- path:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunDeliversDebouncedBatch(t *testing.T) {
+	for _, forcePoll := range []bool{false, true} {
+		name := "native"
+		if forcePoll {
+			name = "poll"
+		}
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			os.MkdirAll(filepath.Join(root, "snes"), 0o755)
+
+			batches := runWatcher(t, root, Options{
+				Debounce:     100 * time.Millisecond,
+				PollInterval: 20 * time.Millisecond,
+				SkipDotfiles: true,
+				ForcePoll:    forcePoll,
+			})
+
+			// Give the watcher time to take its initial snapshot / add watches
+			time.Sleep(50 * time.Millisecond)
+			os.WriteFile(filepath.Join(root, "snes", "A.sfc"), []byte("a"), 0o644)
+			os.WriteFile(filepath.Join(root, "snes", "B.sfc"), []byte("b"), 0o644)
+			os.WriteFile(filepath.Join(root, "snes", ".DS_Store"), []byte("x"), 0o644)
+
+			select {
+			case batch := <-batches:
+				want := map[string]bool{
+					filepath.Join(root, "snes", "A.sfc"): true,
+					filepath.Join(root, "snes", "B.sfc"): true,
+				}
+				if len(batch) != len(want) {
+					t.Fatalf("batch = %v, want A.sfc and B.sfc", batch)
+				}
+				for _, p := range batch {
+					if !want[p] {
+						t.Errorf("unexpected path in batch: %s", p)
+					}
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for batch")
+			}
+		})
+	}
+}
+
+func TestRunWatchesNewDirectories(t *testing.T) {
+	root := t.TempDir()
+	batches := runWatcher(t, root, Options{Debounce: 100 * time.Millisecond})
+
+	time.Sleep(50 * time.Millisecond)
+	os.MkdirAll(filepath.Join(root, "gba"), 0o755)
+	time.Sleep(50 * time.Millisecond)
+	os.WriteFile(filepath.Join(root, "gba", "Game.gba"), []byte("g"), 0o644)
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case batch := <-batches:
+			for _, p := range batch {
+				if p == filepath.Join(root, "gba", "Game.gba") {
+					return
+				}
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for file in new directory")
+		}
+	}
+}
+
+func TestHasDotComponent(t *testing.T) {
+	roots := []string{"/data/.hidden-root"}
+	if hasDotComponent(roots, "/data/.hidden-root/roms/a.sfc") {
+		t.Error("dot in root should not count")
+	}
+	if !hasDotComponent(roots, "/data/.hidden-root/roms/.cache/a.sfc") {
+		t.Error("dot directory below root should count")
+	}
+}
+
+// runWatcher starts Run in the background and returns its batches.
+func runWatcher(t *testing.T, root string, opts Options) <-chan []string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan []string, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := Run(ctx, []string{root}, opts, func(paths []string) { batches <- paths })
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return batches
+}